@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"podsink/internal/daemon"
+)
+
+// controlDaemon sends one of the daemon subcommands to a running daemon and
+// returns the process exit code.
+func controlDaemon(ctx context.Context, socketPath string, args []string, asJSON bool) int {
+	client := daemon.NewClient(socketPath)
+	var reply any
+	var err error
+	switch strings.ToLower(args[0]) {
+	case "status":
+		if len(args) != 1 {
+			return daemonUsage()
+		}
+		var status daemon.Status
+		if status, err = client.Status(ctx); err == nil && !asJSON {
+			fmt.Printf("Daemon running (pid %d) since %s: %d download worker(s), %d queued, %d downloaded.\n",
+				status.PID, status.StartedAt.Local().Format("2006-01-02 15:04"), status.Workers, status.Queued, status.Downloaded)
+			return exitOK
+		}
+		reply = status
+	case "enqueue":
+		if len(args) != 2 {
+			return daemonUsage()
+		}
+		reply, err = client.Enqueue(ctx, args[1])
+	case "refresh":
+		if len(args) > 2 {
+			return daemonUsage()
+		}
+		podcastID := ""
+		if len(args) == 2 {
+			podcastID = args[1]
+		}
+		reply, err = client.Refresh(ctx, podcastID)
+	default:
+		return daemonUsage()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		if errors.Is(err, daemon.ErrNotRunning) {
+			return exitFailure
+		}
+		return exitCodeFor(err)
+	}
+
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(reply); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return exitFailure
+		}
+		return exitOK
+	}
+	var result struct {
+		Message string `json:"message"`
+	}
+	if raw, ok := reply.(json.RawMessage); ok && json.Unmarshal(raw, &result) == nil && result.Message != "" {
+		fmt.Println(result.Message)
+	}
+	return exitOK
+}
+
+func daemonUsage() int {
+	fmt.Fprintln(os.Stderr, "Usage: daemon [status | enqueue <episode_id> | refresh [podcast_id]]")
+	return exitFailure
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kballard/go-shellquote"
+
+	"podsink/internal/app"
+	"podsink/internal/domain"
+)
+
+// runCommand executes a single REPL command without the TUI and prints its
+// result to stdout. It returns the process exit code.
+func runCommand(ctx context.Context, application *app.App, args []string, asJSON bool) int {
+	if !knownCommand(application, args[0]) {
+		fmt.Fprintf(os.Stderr, "error: unknown command: %s\n", args[0])
+		return exitFailure
+	}
+
+	stopProgress := func() {}
+	if strings.EqualFold(args[0], "import") {
+		stopProgress = followImportProgress(application, os.Stderr)
+	}
+	result, err := application.Execute(ctx, shellquote.Join(args...))
+	stopProgress()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return exitCodeFor(err)
+	}
+	if strings.HasPrefix(result.Message, "Usage:") {
+		fmt.Fprintln(os.Stderr, result.Message)
+		return exitFailure
+	}
+
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(result); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return exitFailure
+		}
+		return resultExitCode(result)
+	}
+	printResult(os.Stdout, result)
+	return resultExitCode(result)
+}
+
+// resultExitCode maps the summary of a refresh or import onto the exit code
+// contract; other commands that return a result succeeded.
+func resultExitCode(result app.CommandResult) int {
+	switch {
+	case result.RefreshSummary != nil:
+		return refreshExitCode(*result.RefreshSummary)
+	case result.ImportSummary != nil:
+		return importExitCode(*result.ImportSummary)
+	default:
+		return exitOK
+	}
+}
+
+// followImportProgress prints a line to out as each outline of an OPML
+// import is done. The returned function stops following once the lines
+// printed so far are out.
+func followImportProgress(application *app.App, out io.Writer) func() {
+	events, unsubscribe := application.Events().Subscribe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for event := range events {
+			if event.Kind != app.EventImportProgress || event.Title == "" {
+				continue
+			}
+			line := fmt.Sprintf("[%d/%d] %s", event.FeedsDone, event.FeedsTotal, event.Title)
+			if event.Err != nil {
+				line += fmt.Sprintf(": %v", event.Err)
+			}
+			fmt.Fprintln(out, line)
+		}
+	}()
+	return func() {
+		unsubscribe()
+		<-done
+	}
+}
+
+func knownCommand(application *app.App, name string) bool {
+	name = strings.ToLower(name)
+	for _, known := range application.CommandNames() {
+		if known == name {
+			return true
+		}
+	}
+	return false
+}
+
+// printResult renders a command result as plain text, one tab-aligned row
+// per podcast, episode, file or history entry.
+func printResult(out io.Writer, result app.CommandResult) {
+	if msg := strings.TrimRight(result.Message, "\n"); msg != "" {
+		fmt.Fprintln(out, msg)
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	defer w.Flush()
+	for _, r := range result.SearchResults {
+		var notes []string
+		if r.IsSubscribed {
+			notes = append(notes, fmt.Sprintf("subscribed, %d new", r.NewCount))
+		}
+		if r.DiskBytes > 0 {
+			notes = append(notes, fmt.Sprintf("%.1f MB on disk", float64(r.DiskBytes)/(1024*1024)))
+		}
+		if r.SameTitle && r.Podcast.EpisodeCount > 0 {
+			notes = append(notes, fmt.Sprintf("%d episodes", r.Podcast.EpisodeCount))
+		}
+		if r.LikelyDuplicate {
+			notes = append(notes, "likely duplicate")
+		}
+		if len(r.Tags) > 0 {
+			notes = append(notes, "#"+strings.Join(r.Tags, " #"))
+		}
+		status := strings.Join(notes, ", ")
+		fmt.Fprintf(w, "%s\t%s\t%s\n", r.Podcast.ID, r.Podcast.Title, status)
+	}
+	for _, r := range result.EpisodeResults {
+		printEpisode(w, r)
+	}
+	if result.EpisodesMore {
+		fmt.Fprintf(w, "More episodes follow; add --offset to list them.\n")
+	}
+	for _, r := range result.QueuedEpisodeResults {
+		lastError := ""
+		if r.RetryCount > 0 {
+			lastError = r.LastError
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d retries\t%s\t%s\t%s\n", r.Episode.ID, r.Status(), r.RetryCount, r.PodcastTitle, r.Episode.Title, lastError)
+	}
+	for _, r := range result.DownloadedEpisodeResults {
+		printEpisode(w, r)
+	}
+	if len(result.DanglingFiles) > 0 {
+		fmt.Fprintf(w, "\n%d dangling file(s):\n", len(result.DanglingFiles))
+		for _, f := range result.DanglingFiles {
+			fmt.Fprintf(w, "%s\t%d bytes\n", f.Path, f.SizeBytes)
+		}
+	}
+	if len(result.RefreshFailures) > 0 {
+		fmt.Fprintf(w, "\n%d feed(s) failed:\n", len(result.RefreshFailures))
+		for _, f := range result.RefreshFailures {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", f.PodcastID, f.Title, f.Err)
+		}
+	}
+	if len(result.ImportFailures) > 0 {
+		fmt.Fprintf(w, "\n%d outline(s) failed to import:\n", len(result.ImportFailures))
+		for _, f := range result.ImportFailures {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", f.Title, f.FeedURL, f.Err)
+		}
+	}
+	if len(result.ImportConflicts) > 0 {
+		fmt.Fprintf(w, "\n%d podcast(s) subscribed under another title or feed URL:\n", len(result.ImportConflicts))
+		for _, c := range result.ImportConflicts {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", c.PodcastID, c.Title, c.FeedURL, c.ImportedTitle, c.ImportedFeedURL)
+		}
+	}
+	for _, e := range result.SubscriptionHistory {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", e.At.Local().Format("2006-01-02 15:04"), e.Action, e.PodcastID, e.Title, e.Source)
+	}
+}
+
+func printEpisode(w io.Writer, r domain.EpisodeResult) {
+	published := "-"
+	if r.Episode.HasPublish {
+		published = r.Episode.PublishedAt.Format("2006-01-02")
+	}
+	duration := "-"
+	if r.Episode.DurationSec > 0 {
+		duration = (time.Duration(r.Episode.DurationSec) * time.Second).String()
+	}
+	fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", r.Episode.ID, r.Episode.State, published, duration, r.PodcastTitle, r.Episode.Title)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"podsink/internal/app"
+	"podsink/internal/domain"
+)
+
+func TestResultExitCode(t *testing.T) {
+	t.Parallel()
+
+	failure := domain.RefreshFailure{PodcastID: "1", Title: "Broken"}
+	importFailure := domain.ImportFailure{FeedURL: "https://example.com/broken.xml"}
+
+	tests := []struct {
+		name   string
+		result app.CommandResult
+		want   int
+	}{
+		{"plain result", app.CommandResult{Message: "Episode queued."}, exitOK},
+		{"refresh added", app.CommandResult{RefreshSummary: &app.RefreshResult{Refreshed: 2, Added: 3}}, exitOK},
+		{"refresh nothing new", app.CommandResult{RefreshSummary: &app.RefreshResult{Refreshed: 2}}, exitNothingToDo},
+		{"refresh no subscriptions", app.CommandResult{RefreshSummary: &app.RefreshResult{}}, exitNothingToDo},
+		{"refresh some failed", app.CommandResult{RefreshSummary: &app.RefreshResult{Refreshed: 1, Added: 1, Failures: []domain.RefreshFailure{failure}}}, exitPartial},
+		{"refresh all failed", app.CommandResult{RefreshSummary: &app.RefreshResult{Failures: []domain.RefreshFailure{failure}}}, exitFailure},
+		{"import added", app.CommandResult{ImportSummary: &app.OPMLImportResult{Imported: 2}}, exitOK},
+		{"import updated", app.CommandResult{ImportSummary: &app.OPMLImportResult{Updated: 1, Skipped: 1}}, exitOK},
+		{"import all skipped", app.CommandResult{ImportSummary: &app.OPMLImportResult{Skipped: 2}}, exitNothingToDo},
+		{"import some failed", app.CommandResult{ImportSummary: &app.OPMLImportResult{Imported: 1, Errors: []domain.ImportFailure{importFailure}}}, exitPartial},
+		{"import all failed", app.CommandResult{ImportSummary: &app.OPMLImportResult{Errors: []domain.ImportFailure{importFailure}}}, exitFailure},
+	}
+	for _, tt := range tests {
+		if got := resultExitCode(tt.result); got != tt.want {
+			t.Errorf("%s: exit code %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestExitCodeForEmptyImportFile(t *testing.T) {
+	t.Parallel()
+
+	if got := exitCodeFor(fmt.Errorf("import: %w", app.ErrNoSubscriptionsInOPML)); got != exitNothingToDo {
+		t.Fatalf("exit code %d for an OPML file without subscriptions, want %d", got, exitNothingToDo)
+	}
+	if got := exitCodeFor(app.ErrNoSubscriptionsInJSON); got != exitNothingToDo {
+		t.Fatalf("exit code %d for a settings file without subscriptions, want %d", got, exitNothingToDo)
+	}
+}
@@ -2,45 +2,90 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
+	"net"
+	"net/url"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strings"
 	"syscall"
+	"time"
 
+	"podsink/internal/api"
 	"podsink/internal/app"
 	"podsink/internal/config"
+	"podsink/internal/daemon"
 	"podsink/internal/logging"
 	"podsink/internal/repl"
 	"podsink/internal/storage"
+	"podsink/internal/websub"
+)
+
+// Exit codes form a stable contract for scripts and cron wrappers.
+const (
+	exitOK           = 0
+	exitFailure      = 1 // unclassified error or invalid usage
+	exitConfigError  = 2
+	exitDBError      = 3
+	exitNetworkError = 4
+	exitPartial      = 5 // some items succeeded, others failed
+	exitNothingToDo  = 6
 )
 
 func main() {
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Podsink - A command-line podcast manager\n\n")
-		fmt.Fprintf(os.Stderr, "Usage: %s [OPTIONS]\n\n", os.Args[0])
-		fmt.Fprintf(os.Stderr, "Run without options to start the interactive REPL interface.\n\n")
+		fmt.Fprintf(os.Stderr, "Usage: %s [OPTIONS] [COMMAND [ARGS...]]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Run without options to start the interactive REPL interface.\n")
+		fmt.Fprintf(os.Stderr, "Pass a REPL command, e.g. \"%s episodes --json\", to run it once and exit.\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "\"%s daemon\" keeps refreshing and downloading in the background;\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "\"%s daemon status|enqueue <episode_id>|refresh [podcast_id]\" controls it.\n\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "Options:\n")
 		flag.PrintDefaults()
+		fmt.Fprintf(os.Stderr, "\nExit codes:\n")
+		fmt.Fprintf(os.Stderr, "  %d  success\n", exitOK)
+		fmt.Fprintf(os.Stderr, "  %d  unclassified error or invalid usage\n", exitFailure)
+		fmt.Fprintf(os.Stderr, "  %d  configuration error\n", exitConfigError)
+		fmt.Fprintf(os.Stderr, "  %d  database error\n", exitDBError)
+		fmt.Fprintf(os.Stderr, "  %d  network error\n", exitNetworkError)
+		fmt.Fprintf(os.Stderr, "  %d  partial failure\n", exitPartial)
+		fmt.Fprintf(os.Stderr, "  %d  nothing to do\n", exitNothingToDo)
 	}
 
-	importOPML := flag.String("import-opml", "", "import subscriptions from an OPML file and exit")
-	exportOPML := flag.String("export-opml", "", "export subscriptions to an OPML file and exit")
+	importOPML := flag.String("import-opml", "", "import subscriptions from an OPML file, or a JSON settings file ending in .json, and exit")
+	onConflict := flag.String("on-conflict", app.ConflictKeep, "with -import-opml, what to do with podcasts subscribed under another title or feed URL: keep, imported, merge or ask (list them)")
+	exportOPML := flag.String("export-opml", "", "export subscriptions to an OPML file, or with their settings to a file ending in .json, and exit")
+	jsonOutput := flag.Bool("json", false, "print command results as JSON (default from output_format)")
+	readOnly := flag.Bool("read-only", false, "open the database read-only and refuse commands that change it")
 	flag.Parse()
+	jsonSet := false
+	flag.Visit(func(f *flag.Flag) {
+		jsonSet = jsonSet || f.Name == "json"
+	})
+
+	// Go's flag parsing stops at the command name, so also accept a
+	// trailing --json as in "podsink episodes --json".
+	args := flag.Args()
+	if n := len(args); n > 0 && (args[n-1] == "--json" || args[n-1] == "-json") {
+		args = args[:n-1]
+		*jsonOutput, jsonSet = true, true
+	}
 
 	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer cancel()
 
 	home, err := os.UserHomeDir()
 	if err != nil {
-		log.Fatalf("failed to resolve home directory: %v", err)
+		fatal(exitConfigError, "failed to resolve home directory: %v", err)
 	}
 
 	baseDir := filepath.Join(home, ".podsink")
 	if err := os.MkdirAll(baseDir, 0o700); err != nil {
-		log.Fatalf("failed to create config directory: %v", err)
+		fatal(exitConfigError, "failed to create config directory: %v", err)
 	}
 
 	logPath := filepath.Join(baseDir, "podsink.log")
@@ -49,57 +94,196 @@ func main() {
 	configPath := filepath.Join(baseDir, "config.yaml")
 	cfg, err := config.Ensure(ctx, configPath)
 	if err != nil {
-		log.Fatalf("failed to load configuration: %v", err)
+		fatal(exitConfigError, "failed to load configuration: %v", err)
+	}
+	asJSON := cfg.OutputFormat == config.OutputJSON
+	if jsonSet {
+		asJSON = *jsonOutput
+	}
+
+	socketPath := filepath.Join(baseDir, "podsink.sock")
+	daemonMode := len(args) > 0 && strings.EqualFold(args[0], "daemon")
+	if daemonMode && len(args) > 1 {
+		os.Exit(controlDaemon(ctx, socketPath, args[1:], asJSON))
+	}
+
+	if daemonMode && *readOnly {
+		fatal(exitFailure, "--read-only cannot be used with daemon")
 	}
 
 	dbPath := filepath.Join(baseDir, "app.db")
-	db, err := storage.Open(dbPath)
+	open := storage.OpenTuned
+	if *readOnly {
+		open = storage.OpenReadOnlyTuned
+	}
+	db, err := open(dbPath, databaseTuning(cfg))
 	if err != nil {
-		log.Fatalf("failed to open database: %v", err)
+		fatal(exitDBError, "failed to open database: %v", err)
 	}
 	defer db.Close()
 
-	application := app.New(cfg, configPath, db)
+	application := app.NewWithDependencies(ctx, cfg, configPath, db, app.Dependencies{Headless: len(args) > 0 && !daemonMode, ReadOnly: *readOnly})
 	defer application.Close()
 
 	// Initialize and correct database state
 	if err := application.Initialize(ctx); err != nil {
-		log.Fatalf("failed to initialize application: %v", err)
+		fatal(exitDBError, "failed to initialize application: %v", err)
 	}
 
 	if *importOPML != "" && *exportOPML != "" {
 		fmt.Fprintln(os.Stderr, "error: --import-opml and --export-opml cannot be used together")
-		os.Exit(1)
+		os.Exit(exitFailure)
 	}
 
 	if *exportOPML != "" {
 		count, err := application.ExportOPML(ctx, *exportOPML)
 		if err != nil {
+			if errors.Is(err, app.ErrNoSubscriptionsToExport) {
+				fmt.Fprintln(os.Stdout, "No subscriptions to export.")
+				os.Exit(exitNothingToDo)
+			}
 			fmt.Fprintf(os.Stderr, "error exporting OPML: %v\n", err)
-			os.Exit(1)
+			os.Exit(exitCodeFor(err))
 		}
 		fmt.Fprintf(os.Stdout, "Exported %d subscriptions to %s.\n", count, *exportOPML)
 		return
 	}
 
 	if *importOPML != "" {
-		result, err := application.ImportOPML(ctx, *importOPML)
+		stopProgress := followImportProgress(application, os.Stderr)
+		result, err := application.ImportOPML(ctx, *importOPML, *onConflict)
+		stopProgress()
 		if err != nil {
+			if errors.Is(err, app.ErrNoSubscriptionsInOPML) || errors.Is(err, app.ErrNoSubscriptionsInJSON) {
+				fmt.Fprintln(os.Stdout, "No subscriptions found in import file.")
+				os.Exit(exitNothingToDo)
+			}
 			fmt.Fprintf(os.Stderr, "error importing OPML: %v\n", err)
-			os.Exit(1)
+			os.Exit(exitCodeFor(err))
+		}
+		fmt.Fprintf(os.Stdout, "Imported %d subscriptions, updated %d, skipped %d already subscribed.\n", result.Imported, result.Updated, result.Skipped)
+		if len(result.Conflicts) > 0 {
+			fmt.Fprintln(os.Stdout, "Subscribed under another title or feed URL:")
+			for _, c := range result.Conflicts {
+				fmt.Fprintf(os.Stdout, "  %s: %s (%s) in the file, %s (%s) here\n", c.PodcastID, c.ImportedTitle, c.ImportedFeedURL, c.Title, c.FeedURL)
+			}
 		}
-		fmt.Fprintf(os.Stdout, "Imported %d subscriptions, skipped %d already subscribed.\n", result.Imported, result.Skipped)
 		if len(result.Errors) > 0 {
 			fmt.Fprintln(os.Stdout, "Errors encountered:")
-			for _, msg := range result.Errors {
-				fmt.Fprintf(os.Stdout, "  %s\n", msg)
+			for _, f := range result.Errors {
+				fmt.Fprintf(os.Stdout, "  %s: %s\n", f.Title, f.Err)
+			}
+		}
+		if code := importExitCode(result); code != exitOK {
+			os.Exit(code)
+		}
+		return
+	}
+
+	// The HTTP API only makes sense while podsink keeps running, so single
+	// commands run from the shell never start it. A read-only instance
+	// leaves it to the one owning the database.
+	if cfg.APIListen != "" && (len(args) == 0 || daemonMode) && !*readOnly {
+		var routes []api.Route
+		var subscriber *websub.Subscriber
+		if cfg.WebSubCallbackURL != "" {
+			subscriber = websub.New(application, application.HTTPClient(), cfg.WebSubCallbackURL)
+			routes = append(routes, api.Route{Pattern: websub.CallbackPrefix, Handler: subscriber.Handler()})
+		}
+		server, err := api.Start(ctx, application, cfg.APIListen, cfg.APIToken, routes...)
+		if err != nil {
+			fatal(exitFailure, "%v", err)
+		}
+		defer server.Close()
+		// Hubs verify subscriptions right away, so only subscribe once the
+		// callbacks are served.
+		if subscriber != nil {
+			go subscriber.Run(ctx)
+		}
+	}
+
+	if len(args) > 0 {
+		if *importOPML != "" || *exportOPML != "" {
+			fmt.Fprintln(os.Stderr, "error: OPML options cannot be combined with a command")
+			os.Exit(exitFailure)
+		}
+		if daemonMode {
+			if err := daemon.Run(ctx, application, socketPath, filepath.Join(baseDir, "podsink.pid")); err != nil {
+				fatal(exitFailure, "daemon: %v", err)
 			}
+			return
+		}
+		if code := runCommand(ctx, application, args, asJSON); code != exitOK {
+			os.Exit(code)
 		}
 		return
 	}
 
 	if err := repl.Run(ctx, application); err != nil {
 		fmt.Fprintf(os.Stderr, "error: %v\n", err)
-		os.Exit(1)
+		os.Exit(exitCodeFor(err))
+	}
+}
+
+// databaseTuning passes the db_* settings to storage.
+func databaseTuning(cfg config.Config) storage.Tuning {
+	return storage.Tuning{
+		BusyTimeout:  time.Duration(cfg.DBBusyTimeoutMS) * time.Millisecond,
+		CacheSizeKB:  cfg.DBCacheSizeKB,
+		Synchronous:  cfg.DBSynchronous,
+		MmapSizeMB:   cfg.DBMmapSizeMB,
+		MaxOpenConns: cfg.DBMaxOpenConns,
+	}
+}
+
+// fatal reports a startup failure on stderr and in the log, then exits with code.
+func fatal(code int, format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	log.Println(msg)
+	fmt.Fprintf(os.Stderr, "error: %s\n", msg)
+	os.Exit(code)
+}
+
+// exitCodeFor classifies an error returned by a CLI path.
+func exitCodeFor(err error) int {
+	if err == nil {
+		return exitOK
+	}
+	if errors.Is(err, app.ErrNoSubscriptionsInOPML) || errors.Is(err, app.ErrNoSubscriptionsInJSON) {
+		return exitNothingToDo
+	}
+	var urlErr *url.Error
+	var netErr net.Error
+	if errors.As(err, &urlErr) || errors.As(err, &netErr) {
+		return exitNetworkError
+	}
+	return exitFailure
+}
+
+// refreshExitCode maps a feed refresh summary onto the exit code contract.
+func refreshExitCode(result app.RefreshResult) int {
+	switch {
+	case len(result.Failures) > 0 && result.Refreshed == 0:
+		return exitFailure
+	case len(result.Failures) > 0:
+		return exitPartial
+	case result.Added == 0:
+		return exitNothingToDo
+	default:
+		return exitOK
+	}
+}
+
+// importExitCode maps an OPML import summary onto the exit code contract.
+func importExitCode(result app.OPMLImportResult) int {
+	switch {
+	case len(result.Errors) > 0 && result.Imported == 0 && result.Skipped == 0:
+		return exitFailure
+	case len(result.Errors) > 0:
+		return exitPartial
+	case result.Imported == 0 && result.Updated == 0:
+		return exitNothingToDo
+	default:
+		return exitOK
 	}
 }
@@ -0,0 +1,214 @@
+// Package api serves an optional HTTP API for remote control of podsink.
+// Every request must carry the configured token as a bearer token, and the
+// responses are command results in the same JSON form the CLI prints with
+// --json.
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/kballard/go-shellquote"
+
+	"podsink/internal/app"
+)
+
+// Controller is the part of the application the API exposes.
+type Controller interface {
+	Execute(ctx context.Context, input string) (app.CommandResult, error)
+	QueueEpisode(ctx context.Context, episodeID string) (app.CommandResult, error)
+}
+
+// Request is the body accepted by the POST endpoints.
+type Request struct {
+	FeedURL   string `json:"feed_url,omitempty"`
+	PodcastID string `json:"podcast_id,omitempty"`
+	EpisodeID string `json:"episode_id,omitempty"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// Handler serves the API below /api/:
+//
+//	GET    /api/subscriptions                 subscribed podcasts
+//	POST   /api/subscriptions                 {"feed_url"} or {"podcast_id"} subscribes
+//	DELETE /api/subscriptions/{id}            unsubscribes
+//	POST   /api/subscriptions/{id}/refresh    refreshes one feed
+//	POST   /api/refresh                       refreshes every feed
+//	GET    /api/episodes                      recent episodes
+//	POST   /api/episodes/{id}/download        queues an episode for download
+//	GET    /api/queue                         the download queue
+//	POST   /api/queue/process                 starts downloading the queue
+//	POST   /api/queue/{id}/{pause|resume|cancel}
+//	GET    /api/downloads                     downloaded episodes
+//
+// Requests without "Authorization: Bearer <token>" are rejected.
+func Handler(ctrl Controller, token string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/subscriptions", command(ctrl, "list", "subscriptions"))
+	mux.HandleFunc("POST /api/subscriptions", func(w http.ResponseWriter, r *http.Request) {
+		req, ok := readRequest(w, r)
+		if !ok {
+			return
+		}
+		target := strings.TrimSpace(req.FeedURL)
+		if target == "" {
+			target = strings.TrimSpace(req.PodcastID)
+		}
+		if target == "" {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: "feed_url or podcast_id is required"})
+			return
+		}
+		execute(w, r, ctrl, "subscribe", target)
+	})
+	mux.HandleFunc("DELETE /api/subscriptions/{id}", func(w http.ResponseWriter, r *http.Request) {
+		execute(w, r, ctrl, "unsubscribe", r.PathValue("id"))
+	})
+	mux.HandleFunc("POST /api/subscriptions/{id}/refresh", func(w http.ResponseWriter, r *http.Request) {
+		execute(w, r, ctrl, "refresh", r.PathValue("id"))
+	})
+	mux.HandleFunc("POST /api/refresh", command(ctrl, "refresh"))
+	mux.HandleFunc("GET /api/episodes", command(ctrl, "episodes"))
+	mux.HandleFunc("POST /api/episodes/{id}/download", func(w http.ResponseWriter, r *http.Request) {
+		// Not run as "queue <id>", where "process" or "workers" would be
+		// read as subcommands
+		result, err := ctrl.QueueEpisode(r.Context(), r.PathValue("id"))
+		reply(w, result, err)
+	})
+	mux.HandleFunc("GET /api/queue", command(ctrl, "queue"))
+	mux.HandleFunc("POST /api/queue/process", command(ctrl, "queue", "process"))
+	mux.HandleFunc("POST /api/queue/{id}/{action}", func(w http.ResponseWriter, r *http.Request) {
+		action := r.PathValue("action")
+		switch action {
+		case "pause", "resume", "cancel":
+			execute(w, r, ctrl, "queue", action, r.PathValue("id"))
+		default:
+			writeJSON(w, http.StatusNotFound, errorResponse{Error: fmt.Sprintf("unknown queue action %q", action)})
+		}
+	})
+	mux.HandleFunc("GET /api/downloads", command(ctrl, "downloads"))
+	return requireToken(token, mux)
+}
+
+// requireToken rejects requests that do not present token. An empty token
+// rejects everything.
+func requireToken(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		presented, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" || subtle.ConstantTimeCompare([]byte(presented), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="podsink"`)
+			writeJSON(w, http.StatusUnauthorized, errorResponse{Error: "missing or invalid token"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func command(ctrl Controller, args ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		execute(w, r, ctrl, args...)
+	}
+}
+
+func readRequest(w http.ResponseWriter, r *http.Request) (Request, bool) {
+	var req Request
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: fmt.Sprintf("invalid request body: %v", err)})
+			return Request{}, false
+		}
+	}
+	return req, true
+}
+
+func execute(w http.ResponseWriter, r *http.Request, ctrl Controller, args ...string) {
+	result, err := ctrl.Execute(r.Context(), shellquote.Join(args...))
+	reply(w, result, err)
+}
+
+func reply(w http.ResponseWriter, result app.CommandResult, err error) {
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("api: write response: %v", err)
+	}
+}
+
+// Route is a handler served next to the API without the token check, for
+// callers that cannot present it, such as WebSub hubs.
+type Route struct {
+	Pattern string
+	Handler http.Handler
+}
+
+// Server is a running API server.
+type Server struct {
+	http     *http.Server
+	listener net.Listener
+}
+
+// Start listens on addr and serves the API, and any extra routes, in the
+// background until Close is called. Request contexts derive from ctx.
+func Start(ctx context.Context, ctrl Controller, addr, token string, routes ...Route) (*Server, error) {
+	if token == "" {
+		return nil, errors.New("api: a token is required")
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("api: listen on %s: %w", addr, err)
+	}
+	var handler http.Handler = Handler(ctrl, token)
+	if len(routes) > 0 {
+		mux := http.NewServeMux()
+		mux.Handle("/", handler)
+		for _, route := range routes {
+			mux.Handle(route.Pattern, route.Handler)
+		}
+		handler = mux
+	}
+	s := &Server{
+		http: &http.Server{
+			Handler:           handler,
+			ReadHeaderTimeout: 10 * time.Second,
+			BaseContext:       func(net.Listener) context.Context { return ctx },
+		},
+		listener: listener,
+	}
+	go func() {
+		if err := s.http.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("api: serve: %v", err)
+		}
+	}()
+	log.Printf("api: listening on %s", listener.Addr())
+	return s, nil
+}
+
+// Addr returns the address the server listens on.
+func (s *Server) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// Close stops the server, giving in-flight requests a few seconds to finish.
+func (s *Server) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return s.http.Shutdown(ctx)
+}
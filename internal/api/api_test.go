@@ -0,0 +1,148 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"podsink/internal/app"
+)
+
+type fakeController struct {
+	mu       sync.Mutex
+	commands []string
+}
+
+func (f *fakeController) Execute(_ context.Context, input string) (app.CommandResult, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.commands = append(f.commands, input)
+	if input == "downloads" {
+		return app.CommandResult{}, errors.New("database is locked")
+	}
+	return app.CommandResult{Message: "ran " + input}, nil
+}
+
+func (f *fakeController) QueueEpisode(_ context.Context, episodeID string) (app.CommandResult, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.commands = append(f.commands, "enqueue "+episodeID)
+	return app.CommandResult{Message: "queued " + episodeID}, nil
+}
+
+func TestHandlerRequiresToken(t *testing.T) {
+	ctrl := &fakeController{}
+	server := httptest.NewServer(Handler(ctrl, "s3cret-token-value"))
+	defer server.Close()
+
+	for _, auth := range []string{"", "Bearer wrong", "s3cret-token-value", "Basic s3cret-token-value"} {
+		req, _ := http.NewRequest(http.MethodGet, server.URL+"/api/queue", nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("GET: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("Authorization %q: status %d, want 401", auth, resp.StatusCode)
+		}
+	}
+	if len(ctrl.commands) != 0 {
+		t.Fatalf("unauthorized requests ran commands %q", ctrl.commands)
+	}
+}
+
+func TestHandlerRoutesToCommands(t *testing.T) {
+	ctrl := &fakeController{}
+	server := httptest.NewServer(Handler(ctrl, "s3cret-token-value"))
+	defer server.Close()
+
+	do := func(method, path, body string) (int, string) {
+		t.Helper()
+		req, _ := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer s3cret-token-value")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(data)
+	}
+
+	steps := []struct {
+		method, path, body string
+		status             int
+		contains           string
+	}{
+		{"GET", "/api/subscriptions", "", 200, `"message":"ran list subscriptions"`},
+		{"POST", "/api/subscriptions", `{"feed_url":"https://example.com/feed.xml"}`, 200, "ran subscribe"},
+		{"POST", "/api/subscriptions", `{"podcast_id":"42"}`, 200, "ran subscribe 42"},
+		{"POST", "/api/subscriptions", `{}`, 400, "feed_url or podcast_id is required"},
+		{"POST", "/api/subscriptions", `{`, 400, "invalid request body"},
+		{"DELETE", "/api/subscriptions/42", "", 200, "ran unsubscribe 42"},
+		{"POST", "/api/subscriptions/42/refresh", "", 200, "ran refresh 42"},
+		{"POST", "/api/refresh", "", 200, "ran refresh"},
+		{"GET", "/api/episodes", "", 200, "ran episodes"},
+		{"POST", "/api/episodes/ep%201/download", "", 200, "queued ep 1"},
+		{"POST", "/api/episodes/process/download", "", 200, "queued process"},
+		{"GET", "/api/queue", "", 200, "ran queue"},
+		{"POST", "/api/queue/process", "", 200, "ran queue process"},
+		{"POST", "/api/queue/ep1/pause", "", 200, "ran queue pause ep1"},
+		{"POST", "/api/queue/ep1/explode", "", 404, "unknown queue action"},
+		{"GET", "/api/downloads", "", 500, `"error":"database is locked"`},
+		{"PUT", "/api/queue", "", 405, ""},
+	}
+	for _, step := range steps {
+		status, body := do(step.method, step.path, step.body)
+		if status != step.status || !strings.Contains(body, step.contains) {
+			t.Fatalf("%s %s: got %d %s, want %d containing %q", step.method, step.path, status, body, step.status, step.contains)
+		}
+	}
+
+	want := []string{
+		"list subscriptions",
+		"subscribe https://example.com/feed.xml",
+		"subscribe 42",
+		"unsubscribe 42",
+		"refresh 42",
+		"refresh",
+		"episodes",
+		"enqueue ep 1",
+		"enqueue process",
+		"queue",
+		"queue process",
+		"queue pause ep1",
+		"downloads",
+	}
+	if fmt.Sprint(ctrl.commands) != fmt.Sprint(want) {
+		t.Fatalf("unexpected commands %q, want %q", ctrl.commands, want)
+	}
+}
+
+func TestStartRequiresToken(t *testing.T) {
+	if _, err := Start(context.Background(), &fakeController{}, "127.0.0.1:0", ""); err == nil {
+		t.Fatal("expected Start to refuse an empty token")
+	}
+	server, err := Start(context.Background(), &fakeController{}, "127.0.0.1:0", "s3cret-token-value")
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer server.Close()
+	resp, err := http.Get("http://" + server.Addr().String() + "/api/queue")
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("status %d, want 401", resp.StatusCode)
+	}
+}
@@ -2,33 +2,46 @@ package app
 
 import (
 	"context"
-	"crypto/tls"
 	"database/sql"
 	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/kballard/go-shellquote"
 	"gopkg.in/yaml.v3"
 
+	"podsink/internal/artwork"
 	"podsink/internal/config"
 	"podsink/internal/domain"
 	"podsink/internal/downloads"
 	"podsink/internal/episodes"
 	"podsink/internal/fuzzy"
+	"podsink/internal/gpodder"
+	"podsink/internal/hooks"
 	"podsink/internal/itunes"
+	"podsink/internal/notify"
+	"podsink/internal/podcastindex"
+	"podsink/internal/refresh"
 	"podsink/internal/repository"
 	"podsink/internal/subscriptions"
+	"podsink/internal/watch"
 )
 
 type commandHandler func(context.Context, []string) (CommandResult, error)
 
 type command struct {
+	name    string
 	usage   string
 	summary string
 	handler commandHandler
@@ -41,6 +54,8 @@ const (
 	stateQueued     = domain.EpisodeStateQueued
 	stateDownloaded = domain.EpisodeStateDownloaded
 	stateDeleted    = domain.EpisodeStateDeleted
+	stateCorrupt    = domain.EpisodeStateCorrupt
+	statePlayed     = domain.EpisodeStatePlayed
 )
 
 type CommandResult struct {
@@ -51,9 +66,21 @@ type CommandResult struct {
 	SearchHint               string
 	SearchContext            string
 	EpisodeResults           []domain.EpisodeResult
+	EpisodeQuery             string
+	EpisodeFilter            string // The expression of a query command, to repeat it
+	EpisodeSearch            string // The terms of a search episodes command, to repeat it
+	EpisodePlaylist          string // The name of the playlist listed, to repeat it
+	EpisodesMore             bool   // A page of episodes was asked for and more follow it
+	EpisodePodcast           string // The podcast an episodes listing is limited to, if any
 	QueuedEpisodeResults     []domain.QueuedEpisodeResult
 	DownloadedEpisodeResults []domain.EpisodeResult
 	DanglingFiles            []domain.DanglingFile
+	RefreshFailures          []domain.RefreshFailure
+	ImportFailures           []domain.ImportFailure
+	ImportConflicts          []domain.ImportConflict    // Left for the user to resolve by import --on-conflict ask
+	SubscriptionHistory      []domain.SubscriptionEvent // Set, possibly empty, by the history command
+	RefreshSummary           *RefreshResult             // Set by the refresh command
+	ImportSummary            *OPMLImportResult          // Set by the import command for a subscriptions file
 }
 
 type SearchResult struct {
@@ -62,7 +89,20 @@ type SearchResult struct {
 	IsSubscribed  bool
 	NewCount      int
 	UnplayedCount int
+	PlayedCount   int
 	TotalCount    int
+	LastRefreshed time.Time
+	NextRefresh   time.Time
+	Funding       []domain.Funding    // Support links from the feed, for subscriptions
+	Value         []domain.ValueBlock // Value-for-value payment details, for subscriptions
+	DownloadDir   string              // Overrides download_root, for subscriptions
+	Tags          []string            // OPML groups, for subscriptions
+	DiskBytes     int64               // Size of the downloaded files on disk, for subscriptions
+	// SameTitle is set on search results whose title another result shares,
+	// and LikelyDuplicate on those that also share its author but have
+	// fewer episodes, such as re-uploads.
+	SameTitle       bool
+	LikelyDuplicate bool
 }
 
 type EpisodeResult = domain.EpisodeResult
@@ -73,41 +113,85 @@ type QueuedEpisodeResult = domain.QueuedEpisodeResult
 
 type DanglingFile = domain.DanglingFile
 
+type RefreshFailure = domain.RefreshFailure
+
+type ImportFailure = domain.ImportFailure
+
+type ImportConflict = domain.ImportConflict
+
+// Ways of resolving an import conflict; see subscriptions.ConflictPolicies.
+const (
+	ConflictKeep     = subscriptions.ConflictKeep
+	ConflictImported = subscriptions.ConflictImported
+	ConflictMerge    = subscriptions.ConflictMerge
+	ConflictAsk      = subscriptions.ConflictAsk
+)
+
+type SubscriptionEvent = domain.SubscriptionEvent
+
 var (
 	ErrNoSubscriptionsToExport = subscriptions.ErrNoSubscriptionsToExport
 	ErrNoSubscriptionsInOPML   = subscriptions.ErrNoSubscriptionsInOPML
+	ErrNoSubscriptionsInJSON   = subscriptions.ErrNoSubscriptionsInJSON
+	// ErrReadOnly is returned for changes refused in read-only mode.
+	ErrReadOnly = errors.New("not available in read-only mode")
 )
 
 type App struct {
+	baseCtx       context.Context // Parent context for background workers
 	config        config.Config
 	configPath    string
 	db            *sql.DB
 	httpClient    *http.Client
-	itunes        *itunes.Client
+	directory     itunes.Directory
 	commands      map[string]*command
 	subscriptions *subscriptions.Service
 	episodes      *episodes.Service
 	downloads     *downloads.Service
 	downloadMgr   *downloads.Manager
+	refresher     *refresh.Scheduler
+	watcher       *watch.Watcher // Follows the download root with watch_downloads
+	events        *EventBus
+	notifier      *notify.Notifier
+	hooks         *hooks.Runner
+	artwork       *artwork.Cache
+	readOnly      bool
+	background    bool // Download workers and schedulers run
+	checkingFiles atomic.Bool
+	checks        sync.WaitGroup // Background deleted-file checks
 }
 
 type Dependencies struct {
 	HTTPClient *http.Client
 	ITunes     *itunes.Client
-	Sleep      downloads.SleepFunc
+	// PodcastIndex replaces the Podcast Index client when the directory
+	// setting uses it.
+	PodcastIndex *podcastindex.Client
+	Sleep        downloads.SleepFunc
+	// Headless starts no download workers and no refresh scheduler, for
+	// single commands run from the shell that exit straight afterwards.
+	Headless bool
+	// ReadOnly refuses commands that change the library, for a database
+	// opened with storage.OpenReadOnly. Like Headless, it starts no workers
+	// and no refresh scheduler.
+	ReadOnly bool
 }
 
 type OPMLImportResult = subscriptions.ImportResult
 
-func New(cfg config.Config, configPath string, db *sql.DB) *App {
-	return NewWithDependencies(cfg, configPath, db, Dependencies{})
+type RefreshResult = subscriptions.RefreshResult
+
+// New builds the application. Background download workers stop when ctx is
+// cancelled, so a signal context aborts in-flight transfers.
+func New(ctx context.Context, cfg config.Config, configPath string, db *sql.DB) *App {
+	return NewWithDependencies(ctx, cfg, configPath, db, Dependencies{})
 }
 
-func NewWithDependencies(cfg config.Config, configPath string, db *sql.DB, deps Dependencies) *App {
+func NewWithDependencies(ctx context.Context, cfg config.Config, configPath string, db *sql.DB, deps Dependencies) *App {
 	httpClient := deps.HTTPClient
 	if httpClient == nil {
 		transport := &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: !cfg.TLSVerify},
+			TLSClientConfig: newTLSConfig(cfg),
 		}
 		if proxyURL := strings.TrimSpace(cfg.Proxy); proxyURL != "" {
 			if parsed, err := url.Parse(proxyURL); err == nil {
@@ -117,38 +201,61 @@ func NewWithDependencies(cfg config.Config, configPath string, db *sql.DB, deps
 		httpClient = &http.Client{Timeout: 15 * time.Second, Transport: transport}
 	}
 
-	itunesClient := deps.ITunes
-	if itunesClient == nil {
-		itunesClient = itunes.NewClient(httpClient, "")
-	}
+	directory := newDirectory(cfg, httpClient, deps)
 
 	store := repository.New(db)
 
-	subsSvc := subscriptions.NewService(store, httpClient, itunesClient)
-	episodesSvc := episodes.NewService(store)
-	downloadsSvc := downloads.NewService(cfg, store, httpClient, deps.Sleep)
+	events := NewEventBus()
+	subsSvc := subscriptions.NewService(store, httpClient, directory, events)
+	subsSvc.SetStripTrackingPrefixes(cfg.StripTrackingPrefixes)
+	subsSvc.SetMaxFeedSizeMB(cfg.MaxFeedSizeMB)
+	subsSvc.SetFeedTimeoutSeconds(cfg.FeedTimeoutSeconds)
+	subsSvc.SetImportConcurrency(cfg.ImportConcurrency)
+	subsSvc.SetMaxStoredEpisodes(cfg.MaxStoredEpisodes)
+	subsSvc.SetRefreshIntervalMinutes(cfg.RefreshIntervalMinutes)
+	subsSvc.SetIngestFilters(cfg.IngestFilters)
+	episodesSvc := episodes.NewService(store, events)
+	downloadsSvc := downloads.NewService(cfg, store, httpClient, deps.Sleep, events)
+	artworkCache := artwork.NewCache(filepath.Join(filepath.Dir(configPath), "artwork"), httpClient, cfg.UserAgent)
+	downloadsSvc.SetArtwork(artworkCache)
 
 	application := &App{
+		baseCtx:       ctx,
 		config:        cfg,
 		configPath:    configPath,
 		db:            db,
 		httpClient:    httpClient,
-		itunes:        itunesClient,
+		directory:     directory,
 		commands:      make(map[string]*command),
 		subscriptions: subsSvc,
 		episodes:      episodesSvc,
 		downloads:     downloadsSvc,
+		events:        events,
+		artwork:       artworkCache,
+		readOnly:      deps.ReadOnly,
+		background:    !deps.Headless && !deps.ReadOnly,
 	}
 	application.registerCommands()
 
-	workers := cfg.ParallelDownloads
-	if workers < 0 {
-		workers = 0
+	// Subscribed before any worker starts so no event is missed.
+	notifications, _ := events.Subscribe()
+	application.notifier = notify.New(cfg.NotifyOnNew, cfg.NotifyCommand)
+	application.notifier.Start(notifications)
+	if len(cfg.Hooks) > 0 {
+		hookEvents, _ := events.Subscribe()
+		application.hooks = hooks.New(cfg.Hooks, httpClient, cfg.UserAgent)
+		application.hooks.Start(hookEvents)
 	}
-	if workers > 0 {
-		application.downloadMgr = downloads.NewManager(downloadsSvc, episodesSvc, workers)
-		application.downloadMgr.Notify()
+
+	workers, interval := cfg.ParallelDownloads, time.Duration(cfg.RefreshIntervalMinutes)*time.Minute
+	if deps.Headless || deps.ReadOnly {
+		workers, interval = 0, 0
 	}
+	// The manager always exists so the pool can be resized at runtime.
+	application.downloadMgr = downloads.NewManager(ctx, downloadsSvc, episodesSvc, workers)
+	application.downloadMgr.Notify()
+	application.refresher = refresh.Start(ctx, subsSvc, interval)
+	application.startWatcher(cfg)
 
 	return application
 }
@@ -166,10 +273,45 @@ func (a *App) CommandNames() []string {
 	return names
 }
 
+// CommandInfo describes a command for the command palette.
+type CommandInfo struct {
+	Name    string
+	Usage   string
+	Summary string
+}
+
+// Commands describes every command once, without its aliases, sorted by
+// name.
+func (a *App) Commands() []CommandInfo {
+	infos := make([]CommandInfo, 0, len(a.commands))
+	for name, cmd := range a.commands {
+		if name != cmd.name {
+			continue
+		}
+		infos = append(infos, CommandInfo{Name: cmd.name, Usage: cmd.usage, Summary: cmd.summary})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// Events returns the bus that services publish background state changes to.
+func (a *App) Events() *EventBus {
+	return a.events
+}
+
 func (a *App) Close() error {
+	a.refresher.Stop()
+	a.watcher.Stop()
+	a.checks.Wait()
 	if a.downloadMgr != nil {
 		a.downloadMgr.Stop()
 	}
+	a.events.Close()
+	// Let notifications and hooks of the last events finish before exiting.
+	a.notifier.Wait()
+	if a.hooks != nil {
+		a.hooks.Wait()
+	}
 	if a.db != nil {
 		return a.db.Close()
 	}
@@ -178,10 +320,23 @@ func (a *App) Close() error {
 
 // Initialize performs startup checks and corrections on the database state.
 func (a *App) Initialize(ctx context.Context) error {
+	if a.readOnly {
+		return nil
+	}
 	// Correct episodes stuck in QUEUED state that are already downloaded
 	if err := a.episodes.CorrectQueuedStates(ctx); err != nil {
 		return fmt.Errorf("correct queued states: %w", err)
 	}
+	if crossDevice, err := a.downloads.CrossDevice(); err != nil {
+		log.Printf("tmp dir check failed: %v", err)
+	} else if crossDevice {
+		log.Printf("warning: tmp_dir %s is on a different filesystem than download_root %s; finished downloads will be copied instead of renamed", a.config.TmpDir, a.config.DownloadRoot)
+	}
+	if purged, err := a.downloads.PurgeTrash(ctx, false); err != nil {
+		log.Printf("purge trash failed: %v", err)
+	} else if purged > 0 {
+		log.Printf("purged %d file(s) older than %d day(s) from the trash", purged, a.config.TrashDays)
+	}
 	return nil
 }
 
@@ -204,31 +359,76 @@ func (a *App) Execute(ctx context.Context, input string) (CommandResult, error)
 	if !ok {
 		return CommandResult{Message: fmt.Sprintf("unknown command: %s", args[0])}, nil
 	}
+	if a.readOnly && changesLibrary(cmd.name, args[1:]) {
+		return CommandResult{}, fmt.Errorf("%s: %w", cmd.name, ErrReadOnly)
+	}
 
 	return cmd.handler(ctx, args[1:])
 }
 
+// ReadOnly reports whether commands that change the library are refused.
+func (a *App) ReadOnly() bool {
+	return a.readOnly
+}
+
+// changesLibrary reports whether a command writes to the database, the
+// download directory or the config file.
+func changesLibrary(name string, args []string) bool {
+	switch name {
+	case "exit", "search", "list", "episodes", "downloads", "query", "playlist", "export", "history", "support-bundle":
+		return false
+	case "config":
+		return len(args) > 0 && !strings.EqualFold(args[0], "show")
+	case "queue", "dedupe", "dangling", "trash", "postprocess":
+		return len(args) > 0
+	default:
+		return true
+	}
+}
+
 func (a *App) LookupPodcast(ctx context.Context, id string) (itunes.Podcast, error) {
-	return a.itunes.LookupPodcast(ctx, id)
+	return a.directory.LookupPodcast(ctx, id)
 }
 
 func (a *App) registerCommands() {
 	a.registerCommand("config", "config [show]", "View or edit application configuration", a.configCommand)
 	a.registerCommand("exit", "exit", "Exit the application", a.exitCommand, "quit")
-	a.registerCommand("search", "search <query>", "Search for podcasts via the iTunes API", a.searchCommand, "s")
-	a.registerCommand("list", "list subscriptions [filter]", "List all podcast subscriptions (optionally filtered)", a.listCommand, "ls")
-	a.registerCommand("episodes", "episodes", "View recent episodes across subscriptions", a.episodesCommand, "e", "le")
-	a.registerCommand("queue", "queue [episode_id]", "View download queue status or queue an episode", a.queueCommand, "q")
+	a.registerCommand("search", searchUsage, "Search for podcasts via the iTunes API, or episodes by title and description", a.searchCommand, "s")
+	a.registerCommand("subscribe", "subscribe <podcast_id | feed_url>", "Subscribe to a podcast by iTunes ID or feed URL", a.subscribeCommand)
+	a.registerCommand("unsubscribe", "unsubscribe <podcast_id>", "Remove a podcast subscription", a.unsubscribeCommand)
+	a.registerCommand("history", "history [podcast_id | filter]", "Show when subscriptions were added and removed", a.historyCommand)
+	a.registerCommand("podcast", "podcast set-dir <podcast_id> [path]", "Download a podcast somewhere other than download_root", a.podcastCommand)
+	a.registerCommand("list", listUsage, "List all podcast subscriptions (optionally filtered)", a.listCommand, "ls")
+	a.registerCommand("tag", "tag <podcast_id> <tag>", "Tag a subscription, e.g. with a category", a.tagCommand)
+	a.registerCommand("untag", "untag <podcast_id> <tag>", "Remove a tag from a subscription", a.untagCommand)
+	a.registerCommand("episodes", episodesArgs, "View recent episodes across subscriptions or of one podcast, optionally filtered", a.episodesCommand, "e", "le")
+	a.registerCommand("query", queryUsage, "List episodes matching a filter expression", a.queryCommand)
+	a.registerCommand("playlist", "playlist [name]", "List saved queries or the episodes of one", a.playlistCommand)
+	a.registerCommand("queue", queueUsage, "View download queue status or queue an episode", a.queueCommand, "q")
 	a.registerCommand("downloads", "downloads", "View all downloaded episodes", a.downloadsCommand, "d")
-	a.registerCommand("import", "import <file>", "Import subscriptions from an OPML file", a.importCommand)
+	a.registerCommand("refresh", "refresh [podcast_id]", "Fetch one or all subscribed feeds for new episodes", a.refreshCommand)
+	a.registerCommand("import", "import <file> [--on-conflict keep|imported|merge|ask] | import state-from <other-app.db>", "Import subscriptions from an OPML or JSON settings file, or episode states from another podsink database", a.importCommand)
 	// Register download and ignore commands (available for shortcuts)
-	a.commands["download"] = &command{usage: "download <episode_id>", summary: "Download an episode immediately", handler: a.downloadCommand}
-	a.commands["ignore"] = &command{usage: "ignore <episode_id>", summary: "Toggle the ignored state for an episode", handler: a.ignoreCommand}
-	a.registerCommand("export", "export <file>", "Export subscriptions to an OPML file", a.exportCommand)
+	a.commands["download"] = &command{name: "download", usage: "download <episode_id>", summary: "Download an episode immediately", handler: a.downloadCommand}
+	a.commands["ignore"] = &command{name: "ignore", usage: ignoreUsage, summary: "Toggle the ignored state for an episode, or ignore a podcast's backlog", handler: a.ignoreCommand}
+	a.registerCommand("export", "export <file> | export playlist <file> [options] | export actions <file>", "Export subscriptions to OPML or, for a .json file, with their settings, downloads to an M3U playlist or history as gpodder episode actions", a.exportCommand)
+	a.registerCommand("redownload", "redownload <deleted|corrupt> [podcast_id]", "Queue all deleted or corrupt episodes for re-download", a.redownloadCommand)
+	a.registerCommand("rename-files", "rename-files [podcast_id]", "Rename downloaded files to match filename_template", a.renameFilesCommand)
+	a.registerCommand("verify", verifyUsage, "Re-hash downloaded files, or a random sample of them, to find corrupt ones", a.verifyCommand)
+	a.registerCommand("dedupe", "dedupe [link]", "Report duplicate downloads or replace them with hard links", a.dedupeCommand)
+	a.registerCommand("mark", markUsage, "Mark an episode as played or seen", a.markCommand)
+	a.registerCommand("mark-seen", "mark-seen <all | podcast_id>", "Mark all new episodes, or those of one podcast, as seen", a.markSeenCommand)
+	a.registerCommand("delete", "delete <episode_id>", "Delete the downloaded file of an episode", a.deleteCommand)
+	a.registerCommand("trash", trashUsage, "List, restore or empty deleted files kept in the trash", a.trashCommand)
+	a.registerCommand("sync", "sync", "Sync subscriptions and episode actions with a gpodder.net server", a.syncCommand)
+	a.registerCommand("adopt", "adopt <dir>", "Adopt existing audio files as downloaded episodes", a.adoptCommand)
+	a.registerCommand("dangling", "dangling [adopt <file> | delete <file> | clean]", "List, adopt or delete untracked files in the download directory", a.danglingCommand)
+	a.registerCommand("postprocess", postprocessUsage, "List failed post-processing steps of downloads and retry them", a.postprocessCommand)
+	a.registerCommand("support-bundle", "support-bundle [file]", "Collect logs, redacted config and database statistics for a bug report", a.supportBundleCommand)
 }
 
 func (a *App) registerCommand(name, usage, summary string, handler commandHandler, aliases ...string) {
-	cmd := &command{usage: usage, summary: summary, handler: handler}
+	cmd := &command{name: name, usage: usage, summary: summary, handler: handler}
 	names := append([]string{name}, aliases...)
 	for _, alias := range names {
 		a.commands[alias] = cmd
@@ -259,25 +459,80 @@ func (a *App) editConfig(ctx context.Context) (CommandResult, error) {
 	if err := config.Save(a.configPath, updated); err != nil {
 		return CommandResult{}, err
 	}
+	if updated.ParallelDownloads != a.config.ParallelDownloads {
+		a.downloadMgr.Resize(updated.ParallelDownloads)
+	}
+	a.subscriptions.SetStripTrackingPrefixes(updated.StripTrackingPrefixes)
+	a.subscriptions.SetMaxFeedSizeMB(updated.MaxFeedSizeMB)
+	a.subscriptions.SetFeedTimeoutSeconds(updated.FeedTimeoutSeconds)
+	a.subscriptions.SetImportConcurrency(updated.ImportConcurrency)
+	a.subscriptions.SetMaxStoredEpisodes(updated.MaxStoredEpisodes)
+	a.subscriptions.SetRefreshIntervalMinutes(updated.RefreshIntervalMinutes)
+	a.subscriptions.SetIngestFilters(updated.IngestFilters)
+	a.downloads.Configure(updated)
+	a.notifier.Configure(updated.NotifyOnNew, updated.NotifyCommand)
+	if updated.RefreshIntervalMinutes != a.config.RefreshIntervalMinutes {
+		a.refresher.Stop()
+		a.refresher = refresh.Start(a.baseCtx, a.subscriptions, time.Duration(updated.RefreshIntervalMinutes)*time.Minute)
+	}
+	if updated.WatchDownloads != a.config.WatchDownloads || updated.DownloadRoot != a.config.DownloadRoot || updated.TmpDir != a.config.TmpDir {
+		a.watcher.Stop()
+		a.watcher = nil
+		a.startWatcher(updated)
+	}
 	a.config = updated
 	log.Println("configuration updated")
 	return CommandResult{Message: "Configuration saved."}, nil
 }
 
+// SetSecret saves a credential from config.SecretKeys, or clears it when
+// value is empty. Like the config command, the directory and the HTTP API
+// pick up new credentials on the next start.
+func (a *App) SetSecret(key, value string) error {
+	if a.readOnly {
+		return ErrReadOnly
+	}
+	updated := a.config
+	if err := updated.SetSecret(key, value); err != nil {
+		return err
+	}
+	if err := config.Save(a.configPath, updated); err != nil {
+		return err
+	}
+	a.config = updated
+	log.Printf("%s updated", key)
+	return nil
+}
+
 func (a *App) exitCommand(_ context.Context, _ []string) (CommandResult, error) {
 	return CommandResult{Quit: true}, nil
 }
 
+const searchUsage = `search <query> [lang:<language>] | search episodes <word|"phrase"|prefix*>... [--limit <n>] [--offset <n>]`
+
 func (a *App) searchCommand(ctx context.Context, args []string) (CommandResult, error) {
-	if len(args) == 0 {
-		return CommandResult{Message: "Usage: search <query>"}, nil
+	if len(args) > 0 && strings.EqualFold(args[0], "episodes") {
+		return a.searchEpisodesCommand(ctx, args[1:])
+	}
+	language, terms := splitLanguageFilter(args)
+	if len(terms) == 0 {
+		return CommandResult{Message: "Usage: " + searchUsage}, nil
 	}
 
-	term := strings.Join(args, " ")
-	results, err := a.itunes.Search(ctx, term, 25)
+	term := strings.Join(terms, " ")
+	results, err := a.directory.Search(ctx, term, 25)
 	if err != nil {
 		return CommandResult{}, err
 	}
+	if language != "" {
+		filtered := make([]itunes.Podcast, 0, len(results))
+		for _, r := range results {
+			if matchesLanguage(r.Language, language) {
+				filtered = append(filtered, r)
+			}
+		}
+		results = filtered
+	}
 	if len(results) == 0 {
 		return CommandResult{Message: "No podcasts found."}, nil
 	}
@@ -324,6 +579,7 @@ func (a *App) searchCommand(ctx context.Context, args []string) (CommandResult,
 			IsSubscribed: subscribed,
 		}
 	}
+	a.markDuplicateTitles(ctx, searchResults)
 
 	return CommandResult{
 		SearchResults: searchResults,
@@ -333,7 +589,58 @@ func (a *App) searchCommand(ctx context.Context, args []string) (CommandResult,
 	}, nil
 }
 
+// markDuplicateTitles flags search results that share a title so they can be
+// told apart by feed host and episode count. Missing episode counts are
+// looked up in the directory. Of results that also share an author, all but
+// the one with the most episodes are marked as likely duplicates.
+func (a *App) markDuplicateTitles(ctx context.Context, results []SearchResult) {
+	groups := make(map[string][]int)
+	for i, r := range results {
+		key := strings.ToLower(strings.Join(strings.Fields(r.Podcast.Title), " "))
+		groups[key] = append(groups[key], i)
+	}
+	for _, indexes := range groups {
+		if len(indexes) < 2 {
+			continue
+		}
+		for _, i := range indexes {
+			results[i].SameTitle = true
+			if results[i].Podcast.EpisodeCount > 0 {
+				continue
+			}
+			if full, err := a.directory.LookupPodcast(ctx, results[i].Podcast.ID); err == nil {
+				results[i].Podcast.EpisodeCount = full.EpisodeCount
+			}
+		}
+
+		authors := make(map[string][]int)
+		for _, i := range indexes {
+			author := strings.ToLower(strings.TrimSpace(results[i].Podcast.Author))
+			if author != "" {
+				authors[author] = append(authors[author], i)
+			}
+		}
+		for _, same := range authors {
+			if len(same) < 2 {
+				continue
+			}
+			keep := same[0]
+			for _, i := range same[1:] {
+				if results[i].Podcast.EpisodeCount > results[keep].Podcast.EpisodeCount {
+					keep = i
+				}
+			}
+			for _, i := range same {
+				results[i].LikelyDuplicate = i != keep
+			}
+		}
+	}
+}
+
 func (a *App) SubscribePodcast(ctx context.Context, podcast itunes.Podcast) (CommandResult, error) {
+	if a.readOnly {
+		return CommandResult{}, ErrReadOnly
+	}
 	result, err := a.subscriptions.Subscribe(ctx, podcast)
 	if err != nil {
 		switch {
@@ -358,6 +665,9 @@ func (a *App) SubscribePodcast(ctx context.Context, podcast itunes.Podcast) (Com
 }
 
 func (a *App) UnsubscribePodcast(ctx context.Context, podcastID string) (CommandResult, error) {
+	if a.readOnly {
+		return CommandResult{}, ErrReadOnly
+	}
 	ok, err := a.subscriptions.Unsubscribe(ctx, podcastID)
 	if err != nil {
 		if errors.Is(err, subscriptions.ErrMissingPodcastID) {
@@ -371,9 +681,124 @@ func (a *App) UnsubscribePodcast(ctx context.Context, podcastID string) (Command
 	return CommandResult{Message: "Subscription removed."}, nil
 }
 
+// Artwork returns the cached cover art of a podcast, downloading imageURL
+// into the artwork directory next to the config file when needed.
+func (a *App) Artwork(ctx context.Context, podcastID, imageURL string) (string, error) {
+	if strings.TrimSpace(imageURL) == "" {
+		return "", errors.New("podcast has no artwork")
+	}
+	return a.artwork.Fetch(ctx, podcastID, imageURL)
+}
+
+func (a *App) subscribeCommand(ctx context.Context, args []string) (CommandResult, error) {
+	if len(args) != 1 {
+		return CommandResult{Message: "Usage: subscribe <podcast_id | feed_url>"}, nil
+	}
+	target := args[0]
+	if !strings.Contains(target, "://") {
+		return a.SubscribePodcast(ctx, itunes.Podcast{ID: target})
+	}
+	result, err := a.subscriptions.SubscribeFeed(ctx, target)
+	if err != nil {
+		if errors.Is(err, subscriptions.ErrAlreadySubscribed) {
+			return CommandResult{Message: fmt.Sprintf("Already subscribed to %s.", result.Title)}, nil
+		}
+		return CommandResult{}, err
+	}
+	return CommandResult{Message: fmt.Sprintf("Subscribed to %s (%d new episodes).", result.Title, result.Added)}, nil
+}
+
+func (a *App) unsubscribeCommand(ctx context.Context, args []string) (CommandResult, error) {
+	if len(args) != 1 {
+		return CommandResult{Message: "Usage: unsubscribe <podcast_id>"}, nil
+	}
+	return a.UnsubscribePodcast(ctx, args[0])
+}
+
+func (a *App) historyCommand(ctx context.Context, args []string) (CommandResult, error) {
+	filter := strings.TrimSpace(strings.Join(args, " "))
+	events, err := a.subscriptions.History(ctx, filter)
+	if err != nil {
+		return CommandResult{}, err
+	}
+	result := CommandResult{SubscriptionHistory: events}
+	switch {
+	case len(events) > 0:
+	case filter != "":
+		result.Message = fmt.Sprintf("No subscription changes match %q.", filter)
+	default:
+		result.Message = "No subscription changes recorded."
+	}
+	return result, nil
+}
+
+func (a *App) podcastCommand(ctx context.Context, args []string) (CommandResult, error) {
+	const usage = "Usage: podcast set-dir <podcast_id> [path]"
+	if len(args) < 2 || strings.ToLower(args[0]) != "set-dir" {
+		return CommandResult{Message: usage}, nil
+	}
+	dir := strings.TrimSpace(strings.Join(args[2:], " "))
+	if dir != "" {
+		expanded, err := config.ExpandPath(dir)
+		if err != nil {
+			return CommandResult{}, err
+		}
+		if dir, err = filepath.Abs(expanded); err != nil {
+			return CommandResult{}, err
+		}
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return CommandResult{}, fmt.Errorf("create download directory: %w", err)
+		}
+	}
+	ok, err := a.subscriptions.SetDownloadDir(ctx, args[1], dir)
+	if err != nil {
+		if errors.Is(err, subscriptions.ErrMissingPodcastID) {
+			return CommandResult{Message: "Podcast ID cannot be empty."}, nil
+		}
+		return CommandResult{}, err
+	}
+	if !ok {
+		return CommandResult{Message: "No subscription found for that podcast."}, nil
+	}
+	if dir == "" {
+		return CommandResult{Message: "Episodes of this podcast now download to download_root."}, nil
+	}
+	return CommandResult{Message: fmt.Sprintf("Episodes of this podcast now download to %s.", dir)}, nil
+}
+
+const listUsage = "list subscriptions [filter] [lang:<language>] [--tag <tag>] [--sort size]"
+
+// splitSortOption pulls a --sort size or --sort=size option out of args
+// and reports whether it was given.
+func splitSortOption(args []string) (bool, []string, error) {
+	var bySize bool
+	rest := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		var order string
+		switch arg := args[i]; {
+		case arg == "--sort":
+			if i+1 >= len(args) {
+				return false, nil, errors.New("--sort needs an order")
+			}
+			i++
+			order = args[i]
+		case strings.HasPrefix(arg, "--sort="):
+			order = strings.TrimPrefix(arg, "--sort=")
+		default:
+			rest = append(rest, arg)
+			continue
+		}
+		if !strings.EqualFold(order, "size") {
+			return false, nil, fmt.Errorf("unknown order: %s", order)
+		}
+		bySize = true
+	}
+	return bySize, rest, nil
+}
+
 func (a *App) listCommand(ctx context.Context, args []string) (CommandResult, error) {
 	if len(args) == 0 {
-		return CommandResult{Message: "Usage: list subscriptions [filter]"}, nil
+		return CommandResult{Message: "Usage: " + listUsage}, nil
 	}
 
 	switch strings.ToLower(args[0]) {
@@ -386,38 +811,66 @@ func (a *App) listCommand(ctx context.Context, args []string) (CommandResult, er
 			return CommandResult{Message: "No subscriptions yet."}, nil
 		}
 
-		if len(args) > 1 {
-			filter := strings.Join(args[1:], " ")
+		bySize, filterArgs, err := splitSortOption(args[1:])
+		if err != nil {
+			return CommandResult{Message: "Usage: " + listUsage}, nil
+		}
+		if len(filterArgs) > 0 {
+			tag, rest, err := splitTagFilter(filterArgs)
+			if err != nil {
+				return CommandResult{Message: "Usage: " + listUsage}, nil
+			}
+			language, terms := splitLanguageFilter(rest)
+			filter := strings.Join(terms, " ")
 			filtered := make([]domain.SubscriptionSummary, 0, len(summaries))
 			for _, s := range summaries {
-				if fuzzy.ContainsFuzzy(s.Title, filter) || fuzzy.ContainsFuzzy(s.ID, filter) {
+				if language != "" && !matchesLanguage(s.Language, language) {
+					continue
+				}
+				if tag != "" && !matchesTag(s.Tags, tag) {
+					continue
+				}
+				if filter == "" || fuzzy.ContainsFuzzy(s.Title, filter) || fuzzy.ContainsFuzzy(s.ID, filter) {
 					filtered = append(filtered, s)
 				}
 			}
 			summaries = filtered
 			if len(summaries) == 0 {
-				return CommandResult{Message: fmt.Sprintf("No subscriptions matching '%s'.", filter)}, nil
+				return CommandResult{Message: fmt.Sprintf("No subscriptions matching '%s'.", strings.Join(filterArgs, " "))}, nil
 			}
 		}
+		if bySize {
+			sort.SliceStable(summaries, func(i, j int) bool { return summaries[i].DiskBytes > summaries[j].DiskBytes })
+		}
 
 		results := make([]SearchResult, 0, len(summaries))
 		for _, s := range summaries {
 			results = append(results, SearchResult{
 				Podcast: itunes.Podcast{
-					ID:    s.ID,
-					Title: s.Title,
+					ID:       s.ID,
+					Title:    s.Title,
+					Language: s.Language,
+					Artwork:  s.ArtworkURL,
 				},
 				IsSubscribed:  true,
 				NewCount:      s.NewCount,
 				UnplayedCount: s.UnplayedCount,
+				PlayedCount:   s.PlayedCount,
 				TotalCount:    s.TotalCount,
+				LastRefreshed: s.LastRefreshed,
+				NextRefresh:   s.NextRefresh,
+				Funding:       s.Funding,
+				Value:         s.Value,
+				DownloadDir:   s.DownloadDir,
+				Tags:          s.Tags,
+				DiskBytes:     s.DiskBytes,
 			})
 		}
 
 		return CommandResult{
 			SearchResults: results,
 			SearchTitle:   "Subscriptions",
-			SearchHint:    "Use ↑↓/jk to navigate, Enter for details, [u] unsubscribe, [x]/Esc to exit",
+			SearchHint:    "Use ↑↓/jk to navigate, Enter for episodes, [i] details, [u] unsubscribe, [r] refresh, [o] sort by size, [x]/Esc to exit",
 			SearchContext: "subscriptions",
 		}, nil
 	default:
@@ -426,64 +879,143 @@ func (a *App) listCommand(ctx context.Context, args []string) (CommandResult, er
 }
 
 func (a *App) episodesCommand(ctx context.Context, args []string) (CommandResult, error) {
-	if len(args) > 0 {
-		return CommandResult{Message: "Usage: episodes"}, nil
+	query, err := parseEpisodeQuery(args)
+	if err != nil {
+		return CommandResult{Message: episodesUsage}, nil
 	}
 
-	episodes, err := a.episodes.List(ctx)
+	episodes, more, err := fetchPage(query.page, func(page repository.Page) ([]EpisodeResult, error) {
+		if query.podcastID != "" {
+			return a.episodes.ListByPodcast(ctx, query.podcastID, query.listing(), page)
+		}
+		return a.episodes.List(ctx, query.listing(), page)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return CommandResult{Message: "No subscription found for that podcast."}, nil
+	}
 	if err != nil {
 		return CommandResult{}, err
 	}
 	if len(episodes) == 0 {
-		return CommandResult{Message: "No episodes recorded yet."}, nil
+		if query.String() == query.podcastID && query.page.Offset == 0 {
+			return CommandResult{Message: "No episodes recorded yet."}, nil
+		}
+		return CommandResult{Message: "No episodes match."}, nil
 	}
 
-	if err := a.episodes.MarkAllSeen(ctx); err != nil {
-		return CommandResult{}, err
+	// Listing marks episodes seen only with mark_seen_on_list. Later pages
+	// of the same listing are already marked.
+	if !a.readOnly && a.config.MarkSeenOnList && query.page.Offset == 0 {
+		if query.podcastID != "" {
+			_, err = a.episodes.MarkPodcastSeen(ctx, query.podcastID)
+		} else {
+			_, err = a.episodes.MarkAllSeen(ctx)
+		}
+		if err != nil {
+			return CommandResult{}, err
+		}
 	}
 
-	return CommandResult{EpisodeResults: episodes}, nil
+	return CommandResult{EpisodeResults: episodes, EpisodeQuery: query.String(), EpisodePodcast: query.podcastID, EpisodesMore: more}, nil
 }
 
-func (a *App) queueCommand(ctx context.Context, args []string) (CommandResult, error) {
-	// With arguments: queue an episode
-	if len(args) == 1 {
-		episodeID := strings.TrimSpace(args[0])
-		if episodeID == "" {
-			return CommandResult{Message: "Episode ID cannot be empty."}, nil
-		}
+// searchEpisodesCommand looks the terms up in the full-text index of
+// episode titles and descriptions.
+func (a *App) searchEpisodesCommand(ctx context.Context, args []string) (CommandResult, error) {
+	terms, page, err := parsePage(args)
+	if err != nil || len(terms) == 0 {
+		return CommandResult{Message: "Usage: " + searchUsage}, nil
+	}
+	episodes, more, err := fetchPage(page, func(page repository.Page) ([]EpisodeResult, error) {
+		return a.episodes.Search(ctx, terms, page)
+	})
+	if err != nil {
+		return CommandResult{}, err
+	}
+	if len(episodes) == 0 {
+		return CommandResult{Message: "No episodes match."}, nil
+	}
+	return CommandResult{EpisodeResults: episodes, EpisodeSearch: shellquote.Join(terms...), EpisodesMore: more}, nil
+}
 
-		info, err := a.episodes.FetchEpisodeInfo(ctx, episodeID)
-		if err != nil {
-			if errors.Is(err, sql.ErrNoRows) {
-				return CommandResult{Message: "Episode not found."}, nil
-			}
-			return CommandResult{}, err
+func (a *App) queryCommand(ctx context.Context, args []string) (CommandResult, error) {
+	expr := strings.TrimSpace(strings.Join(args, " "))
+	if expr == "" {
+		return CommandResult{Message: fmt.Sprintf("Usage: %s\nFields: %s", queryUsage, strings.Join(repository.QueryFields(), ", "))}, nil
+	}
+	episodes, err := a.episodes.Query(ctx, expr)
+	if err != nil {
+		if errors.Is(err, repository.ErrInvalidQuery) {
+			return CommandResult{Message: fmt.Sprintf("Usage: %s\n%v", queryUsage, err)}, nil
 		}
+		return CommandResult{}, err
+	}
+	if len(episodes) == 0 {
+		return CommandResult{Message: "No episodes match."}, nil
+	}
+	return CommandResult{EpisodeResults: episodes, EpisodeFilter: expr}, nil
+}
 
-		switch info.State {
-		case stateIgnored:
-			return CommandResult{Message: "Episode is ignored. Unignore before queueing."}, nil
-		case stateQueued:
-			return CommandResult{Message: "Episode is already queued."}, nil
+func (a *App) playlistCommand(ctx context.Context, args []string) (CommandResult, error) {
+	if len(args) == 0 {
+		if len(a.config.Playlists) == 0 {
+			return CommandResult{Message: "No playlists; add them under playlists in config.yaml."}, nil
 		}
-
-		if err := a.downloads.EnqueueEpisode(ctx, info.ID); err != nil {
-			return CommandResult{}, err
+		lines := []string{"Playlists:"}
+		for _, playlist := range a.config.Playlists {
+			lines = append(lines, fmt.Sprintf("  %s: %s", playlist.Name, playlist.Query))
 		}
-		if a.downloadMgr != nil {
-			a.downloadMgr.Notify()
+		return CommandResult{Message: strings.Join(lines, "\n")}, nil
+	}
+	name := strings.Join(args, " ")
+	playlist, ok := a.config.FindPlaylist(name)
+	if !ok {
+		return CommandResult{Message: fmt.Sprintf("No playlist named %q.", name)}, nil
+	}
+	episodes, err := a.episodes.Query(ctx, playlist.Query)
+	if err != nil {
+		if errors.Is(err, repository.ErrInvalidQuery) {
+			return CommandResult{Message: fmt.Sprintf("Playlist %q: %v", playlist.Name, err)}, nil
 		}
+		return CommandResult{}, err
+	}
+	if len(episodes) == 0 {
+		return CommandResult{Message: fmt.Sprintf("No episodes in playlist %q.", playlist.Name)}, nil
+	}
+	return CommandResult{EpisodeResults: episodes, EpisodeFilter: playlist.Query, EpisodePlaylist: playlist.Name}, nil
+}
 
-		if info.State == stateDownloaded {
-			return CommandResult{Message: fmt.Sprintf("Episode %s queued for re-download.", info.ID)}, nil
+const queueUsage = "queue [episode_id [--priority low|normal|high] | latest <podcast_id> [n] | process | workers [n] | pause|resume|cancel <episode_id>]"
+
+func (a *App) queueCommand(ctx context.Context, args []string) (CommandResult, error) {
+	if len(args) > 0 && strings.EqualFold(args[0], "workers") {
+		return a.queueWorkersCommand(args[1:])
+	}
+	if len(args) == 1 && strings.EqualFold(args[0], "process") {
+		return a.queueProcessCommand(ctx)
+	}
+	if len(args) > 0 {
+		switch strings.ToLower(args[0]) {
+		case "pause", "resume", "cancel":
+			return a.queueControlCommand(ctx, strings.ToLower(args[0]), args[1:])
+		case "latest":
+			return a.queueLatestCommand(ctx, args[1:])
 		}
-		return CommandResult{Message: fmt.Sprintf("Episode %s queued for download.", info.ID)}, nil
+	}
+
+	args, priority, hasPriority, ok := splitPriorityFlag(args)
+	if !ok {
+		return CommandResult{Message: "Usage: " + queueUsage}, nil
+	}
+
+	// With arguments: queue an episode
+	if len(args) == 1 {
+		return a.queueEpisode(ctx, args[0], priority, hasPriority)
 	}
 
 	// Without arguments: list queued episodes
-	if len(args) != 0 {
-		return CommandResult{Message: "Usage: queue [episode_id]"}, nil
+	if len(args) != 0 || hasPriority {
+		return CommandResult{Message: "Usage: " + queueUsage}, nil
 	}
 
 	queuedEpisodes, err := a.episodes.ListQueued(ctx)
@@ -495,15 +1027,109 @@ func (a *App) queueCommand(ctx context.Context, args []string) (CommandResult, e
 	return CommandResult{QueuedEpisodeResults: queuedEpisodes}, nil
 }
 
+func (a *App) queueEpisode(ctx context.Context, episodeID string, priority int, hasPriority bool) (CommandResult, error) {
+	episodeID = strings.TrimSpace(episodeID)
+	if episodeID == "" {
+		return CommandResult{Message: "Episode ID cannot be empty."}, nil
+	}
+
+	info, err := a.episodes.FetchEpisodeInfo(ctx, episodeID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return CommandResult{Message: "Episode not found."}, nil
+		}
+		return CommandResult{}, err
+	}
+
+	active, err := a.downloads.IsDownloadActive(ctx, info.ID)
+	if err != nil {
+		return CommandResult{}, err
+	}
+	if active {
+		return CommandResult{Message: alreadyDownloadingMessage}, nil
+	}
+
+	switch info.State {
+	case stateIgnored:
+		return CommandResult{Message: "Episode is ignored. Unignore before queueing."}, nil
+	case stateQueued:
+		if hasPriority {
+			if _, err := a.downloads.SetPriority(ctx, info.ID, priority); err != nil {
+				return CommandResult{}, err
+			}
+			return CommandResult{Message: fmt.Sprintf("Episode %s now has %s priority.", info.ID, domain.PriorityName(priority))}, nil
+		}
+		return CommandResult{Message: "Episode is already queued."}, nil
+	}
+
+	if err := a.downloads.EnqueueEpisode(ctx, info.ID); err != nil {
+		if errors.Is(err, repository.ErrAlreadyDownloading) {
+			return CommandResult{Message: alreadyDownloadingMessage}, nil
+		}
+		return CommandResult{}, err
+	}
+	if hasPriority {
+		if _, err := a.downloads.SetPriority(ctx, info.ID, priority); err != nil {
+			return CommandResult{}, err
+		}
+	}
+	if a.downloadMgr != nil {
+		a.downloadMgr.Notify()
+	}
+
+	msg := fmt.Sprintf("Episode %s queued for download.", info.ID)
+	if info.State == stateDownloaded {
+		msg = fmt.Sprintf("Episode %s queued for re-download.", info.ID)
+	}
+	if hasPriority && priority != domain.PriorityNormal {
+		msg = strings.TrimSuffix(msg, ".") + fmt.Sprintf(" with %s priority.", domain.PriorityName(priority))
+	}
+	if a.DownloadWorkers() == 0 {
+		msg += " " + noWorkersHint
+	}
+	return CommandResult{Message: msg}, nil
+}
+
+// splitPriorityFlag removes "--priority <level>" or "--priority=<level>"
+// from args. It reports false for a missing or unknown level.
+func splitPriorityFlag(args []string) (rest []string, priority int, found, ok bool) {
+	for i := 0; i < len(args); i++ {
+		name, value, hasValue := strings.Cut(args[i], "=")
+		if name != "--priority" {
+			rest = append(rest, args[i])
+			continue
+		}
+		if !hasValue {
+			if i+1 >= len(args) {
+				return nil, 0, true, false
+			}
+			i++
+			value = args[i]
+		}
+		if priority, ok = domain.ParsePriority(value); !ok {
+			return nil, 0, true, false
+		}
+		found = true
+	}
+	return rest, priority, found, true
+}
+
 func (a *App) downloadsCommand(ctx context.Context, args []string) (CommandResult, error) {
 	// List all downloaded episodes (DOWNLOADED or DELETED state)
 	if len(args) != 0 {
 		return CommandResult{Message: "Usage: downloads"}, nil
 	}
 
-	// Check for deleted files and update states
-	if err := a.episodes.CheckDeletedFiles(ctx); err != nil {
-		return CommandResult{}, err
+	// Check for deleted files and update states. Interactive instances list
+	// what is known right away; the view reloads if the check finds any.
+	switch {
+	case a.readOnly:
+	case a.background:
+		a.checkDeletedFilesInBackground()
+	default:
+		if _, err := a.episodes.CheckDeletedFiles(ctx); err != nil {
+			return CommandResult{}, err
+		}
 	}
 
 	downloadedEpisodes, err := a.episodes.ListDownloaded(ctx)
@@ -512,7 +1138,7 @@ func (a *App) downloadsCommand(ctx context.Context, args []string) (CommandResul
 	}
 
 	// Find dangling files (files in download directory not tracked in database)
-	danglingFiles, err := a.episodes.FindDanglingFiles(ctx, a.config.DownloadRoot)
+	danglingFiles, err := a.episodes.FindDanglingFiles(ctx, a.config.DownloadRoot, a.config.TmpDir, config.TrashDir(a.config.DownloadRoot), config.OriginalsDir(a.config.DownloadRoot))
 	if err != nil {
 		return CommandResult{}, err
 	}
@@ -546,9 +1172,22 @@ func (a *App) downloadCommand(ctx context.Context, args []string) (CommandResult
 	if info.State == stateIgnored {
 		return CommandResult{Message: "Episode is ignored. Unignore before downloading."}, nil
 	}
+	active, err := a.downloads.IsDownloadActive(ctx, info.ID)
+	if err != nil {
+		return CommandResult{}, err
+	}
+	if active {
+		return CommandResult{Message: alreadyDownloadingMessage}, nil
+	}
 
 	isRedownload := info.State == stateDownloaded
 	finalPath, err := a.downloads.DownloadEpisode(ctx, info)
+	if errors.Is(err, downloads.ErrEpisodeTooLarge) {
+		if err := a.downloads.SkipEpisode(ctx, info); err != nil {
+			return CommandResult{}, err
+		}
+		return CommandResult{Message: fmt.Sprintf("Skipped %s: %v.", info.Title, err)}, nil
+	}
 	if err != nil {
 		return CommandResult{}, err
 	}
@@ -559,11 +1198,13 @@ func (a *App) downloadCommand(ctx context.Context, args []string) (CommandResult
 	return CommandResult{Message: fmt.Sprintf("Downloaded %s to %s.", info.Title, finalPath)}, nil
 }
 
-func (a *App) ignoreCommand(ctx context.Context, args []string) (CommandResult, error) {
-	if len(args) != 1 {
-		return CommandResult{Message: "Usage: ignore <episode_id>"}, nil
+const markUsage = "mark played|seen <episode_id>"
+
+func (a *App) markCommand(ctx context.Context, args []string) (CommandResult, error) {
+	if len(args) != 2 || (!strings.EqualFold(args[0], "played") && !strings.EqualFold(args[0], "seen")) {
+		return CommandResult{Message: "Usage: " + markUsage}, nil
 	}
-	episodeID := strings.TrimSpace(args[0])
+	episodeID := strings.TrimSpace(args[1])
 	if episodeID == "" {
 		return CommandResult{Message: "Episode ID cannot be empty."}, nil
 	}
@@ -575,27 +1216,795 @@ func (a *App) ignoreCommand(ctx context.Context, args []string) (CommandResult,
 		}
 		return CommandResult{}, err
 	}
-
-	switch info.State {
-	case stateIgnored:
-		if err := a.episodes.UpdateEpisodeState(ctx, info.ID, stateSeen); err != nil {
-			return CommandResult{}, err
-		}
-		return CommandResult{Message: fmt.Sprintf("Episode %s unignored.", info.ID)}, nil
-	default:
-		if err := a.downloads.RemoveFromQueue(ctx, info.ID); err != nil {
-			return CommandResult{}, err
+	if strings.EqualFold(args[0], "seen") {
+		switch info.State {
+		case stateNew, stateIgnored:
+		case stateSeen:
+			return CommandResult{Message: "Episode is already marked as seen."}, nil
+		default:
+			return CommandResult{Message: "Only new or ignored episodes can be marked as seen."}, nil
 		}
-		if err := a.episodes.UpdateEpisodeState(ctx, info.ID, stateIgnored); err != nil {
+		if _, err := a.episodes.MarkSeen(ctx, []string{info.ID}); err != nil {
+			return CommandResult{}, err
+		}
+		return CommandResult{Message: fmt.Sprintf("Episode %s marked as seen.", info.ID)}, nil
+	}
+
+	switch info.State {
+	case statePlayed:
+		return CommandResult{Message: "Episode is already marked as played."}, nil
+	case stateQueued:
+		return CommandResult{Message: "Episode is queued for download. Mark it played once the download finishes."}, nil
+	}
+
+	if err := a.episodes.MarkPlayed(ctx, info.ID); err != nil {
+		return CommandResult{}, err
+	}
+	return CommandResult{Message: fmt.Sprintf("Episode %s marked as played.", info.ID)}, nil
+}
+
+// markSeenCommand marks the NEW episodes of the library, or of one
+// podcast, as SEEN without listing them.
+func (a *App) markSeenCommand(ctx context.Context, args []string) (CommandResult, error) {
+	if len(args) != 1 {
+		return CommandResult{Message: "Usage: mark-seen <all | podcast_id>"}, nil
+	}
+	if strings.EqualFold(args[0], "all") {
+		count, err := a.episodes.MarkAllSeen(ctx)
+		if err != nil {
+			return CommandResult{}, err
+		}
+		return CommandResult{Message: fmt.Sprintf("Marked %d new episode(s) as seen.", count)}, nil
+	}
+	podcastID := strings.TrimSpace(args[0])
+	exists, _, err := a.subscriptions.IsSubscribed(ctx, podcastID)
+	if err != nil {
+		return CommandResult{}, err
+	}
+	if !exists {
+		return CommandResult{Message: "No subscription found for that podcast."}, nil
+	}
+	count, err := a.episodes.MarkPodcastSeen(ctx, podcastID)
+	if err != nil {
+		return CommandResult{}, err
+	}
+	return CommandResult{Message: fmt.Sprintf("Marked %d new episode(s) as seen.", count)}, nil
+}
+
+func (a *App) deleteCommand(ctx context.Context, args []string) (CommandResult, error) {
+	if len(args) != 1 {
+		return CommandResult{Message: "Usage: delete <episode_id>"}, nil
+	}
+	episodeID := strings.TrimSpace(args[0])
+	if episodeID == "" {
+		return CommandResult{Message: "Episode ID cannot be empty."}, nil
+	}
+
+	info, err := a.episodes.FetchEpisodeInfo(ctx, episodeID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return CommandResult{Message: "Episode not found."}, nil
+		}
+		return CommandResult{}, err
+	}
+	switch info.State {
+	case stateQueued:
+		return CommandResult{Message: "Episode is queued for download. Cancel it with 'queue cancel' first."}, nil
+	case stateDeleted:
+		return CommandResult{Message: "Episode file is already deleted."}, nil
+	case stateDownloaded, stateCorrupt, statePlayed:
+		if info.FilePath != "" {
+			break
+		}
+		fallthrough
+	default:
+		return CommandResult{Message: "Episode has no downloaded file."}, nil
+	}
+
+	if _, err := a.downloads.DeleteEpisode(ctx, info, a.config.PruneEmptyDirs); err != nil {
+		return CommandResult{}, err
+	}
+	if a.config.TrashDays > 0 {
+		return CommandResult{Message: fmt.Sprintf("Moved %s to the trash for %d day(s); 'trash restore %s' brings it back.", info.FilePath, a.config.TrashDays, info.ID)}, nil
+	}
+	return CommandResult{Message: fmt.Sprintf("Deleted %s.", info.FilePath)}, nil
+}
+
+const trashUsage = "trash [restore <episode_id | all> | empty]"
+
+func (a *App) trashCommand(ctx context.Context, args []string) (CommandResult, error) {
+	entries, err := a.downloads.ListTrash(ctx)
+	if err != nil {
+		return CommandResult{}, err
+	}
+
+	if len(args) == 0 {
+		if len(entries) == 0 {
+			return CommandResult{Message: "The trash is empty."}, nil
+		}
+		lines := []string{fmt.Sprintf("%d file(s) in the trash, purged after %d day(s):", len(entries), a.config.TrashDays)}
+		for _, entry := range entries {
+			title := entry.Title
+			if entry.PodcastTitle != "" {
+				title = entry.PodcastTitle + " - " + title
+			}
+			lines = append(lines, fmt.Sprintf("  %s  %s  %s (%s)", entry.TrashedAt.Local().Format("2006-01-02 15:04"), entry.EpisodeID, title, entry.OriginalPath))
+		}
+		return CommandResult{Message: strings.Join(lines, "\n")}, nil
+	}
+
+	switch {
+	case len(args) == 1 && strings.EqualFold(args[0], "empty"):
+		purged, err := a.downloads.PurgeTrash(ctx, true)
+		if err != nil {
+			return CommandResult{}, err
+		}
+		return CommandResult{Message: fmt.Sprintf("Deleted %d file(s) from the trash.", purged)}, nil
+	case len(args) == 2 && strings.EqualFold(args[0], "restore"):
+	default:
+		return CommandResult{Message: "Usage: " + trashUsage}, nil
+	}
+
+	target := strings.TrimSpace(args[1])
+	if !strings.EqualFold(target, "all") {
+		for _, entry := range entries {
+			if entry.EpisodeID == target {
+				return a.restoreTrash(ctx, entry)
+			}
+		}
+		return CommandResult{Message: "No file of that episode is in the trash."}, nil
+	}
+	restored := 0
+	var failures []string
+	for _, entry := range entries {
+		if err := a.downloads.RestoreTrash(ctx, entry); err != nil {
+			failures = append(failures, fmt.Sprintf("  %s: %v", entry.EpisodeID, err))
+			continue
+		}
+		restored++
+	}
+	lines := append([]string{fmt.Sprintf("Restored %d of %d file(s) from the trash.", restored, len(entries))}, failures...)
+	return CommandResult{Message: strings.Join(lines, "\n")}, nil
+}
+
+func (a *App) restoreTrash(ctx context.Context, entry domain.TrashEntry) (CommandResult, error) {
+	err := a.downloads.RestoreTrash(ctx, entry)
+	switch {
+	case errors.Is(err, downloads.ErrRestoreConflict):
+		return CommandResult{Message: fmt.Sprintf("Cannot restore: %s already exists.", entry.OriginalPath)}, nil
+	case errors.Is(err, downloads.ErrNotRestorable):
+		return CommandResult{Message: "Cannot restore: the episode was downloaded again or removed."}, nil
+	case err != nil:
+		return CommandResult{}, err
+	}
+	return CommandResult{Message: fmt.Sprintf("Restored %s.", entry.OriginalPath)}, nil
+}
+
+const postprocessUsage = "postprocess [retry <episode_id | all>]"
+
+func (a *App) postprocessCommand(ctx context.Context, args []string) (CommandResult, error) {
+	if len(a.config.PostProcess) == 0 {
+		return CommandResult{Message: "No post_process steps are configured."}, nil
+	}
+	failed, err := a.downloads.ListFailedPostProcess(ctx)
+	if err != nil {
+		return CommandResult{}, err
+	}
+
+	if len(args) == 0 {
+		if len(failed) == 0 {
+			return CommandResult{Message: "No failed post-processing steps."}, nil
+		}
+		lines := []string{fmt.Sprintf("%d failed post-processing step(s):", len(failed))}
+		for _, record := range failed {
+			lines = append(lines, fmt.Sprintf("  %s  %s - %s  %s: %s", record.EpisodeID, record.PodcastTitle, record.Title, record.Step, record.Error))
+		}
+		return CommandResult{Message: strings.Join(lines, "\n")}, nil
+	}
+	if len(args) != 2 || !strings.EqualFold(args[0], "retry") {
+		return CommandResult{Message: "Usage: " + postprocessUsage}, nil
+	}
+
+	target := strings.TrimSpace(args[1])
+	if !strings.EqualFold(target, "all") {
+		path, err := a.downloads.RetryPostProcess(ctx, target)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return CommandResult{Message: "Episode not found."}, nil
+		case errors.Is(err, downloads.ErrNothingToProcess):
+			return CommandResult{Message: "Nothing to post-process for that episode."}, nil
+		case err != nil && ctx.Err() == nil:
+			return CommandResult{Message: fmt.Sprintf("Post-processing failed: %v", err)}, nil
+		case err != nil:
+			return CommandResult{}, err
+		}
+		return CommandResult{Message: fmt.Sprintf("Post-processed %s.", path)}, nil
+	}
+
+	retried := map[string]bool{}
+	succeeded := 0
+	var failures []string
+	for _, record := range failed {
+		if retried[record.EpisodeID] {
+			continue
+		}
+		retried[record.EpisodeID] = true
+		if _, err := a.downloads.RetryPostProcess(ctx, record.EpisodeID); err != nil {
+			if ctx.Err() != nil {
+				return CommandResult{}, ctx.Err()
+			}
+			failures = append(failures, fmt.Sprintf("  %s: %v", record.EpisodeID, err))
+			continue
+		}
+		succeeded++
+	}
+	lines := append([]string{fmt.Sprintf("Post-processed %d of %d episode(s).", succeeded, len(retried))}, failures...)
+	return CommandResult{Message: strings.Join(lines, "\n")}, nil
+}
+
+// QueueEpisode queues one episode for download as "queue <episode_id>"
+// does. The ID is never read as a queue subcommand such as "process", so it
+// can come straight from a request.
+func (a *App) QueueEpisode(ctx context.Context, episodeID string) (CommandResult, error) {
+	if a.readOnly {
+		return CommandResult{}, ErrReadOnly
+	}
+	return a.queueEpisode(ctx, episodeID, domain.PriorityNormal, false)
+}
+
+// QueueEpisodes queues several episodes for download in one transaction,
+// skipping ignored episodes, running downloads and episodes already queued.
+func (a *App) QueueEpisodes(ctx context.Context, episodeIDs []string) (CommandResult, error) {
+	if a.readOnly {
+		return CommandResult{}, ErrReadOnly
+	}
+	count, err := a.downloads.EnqueueEpisodes(ctx, episodeIDs)
+	if err != nil {
+		return CommandResult{}, err
+	}
+	if count > 0 && a.downloadMgr != nil {
+		a.downloadMgr.Notify()
+	}
+	return CommandResult{Message: fmt.Sprintf("Queued %d of %d episode(s) for download.", count, len(episodeIDs))}, nil
+}
+
+// IgnoreEpisodes ignores several episodes in one transaction, dropping them
+// from the download queue. Unlike the ignore command it does not toggle.
+func (a *App) IgnoreEpisodes(ctx context.Context, episodeIDs []string) (CommandResult, error) {
+	if a.readOnly {
+		return CommandResult{}, ErrReadOnly
+	}
+	count, err := a.episodes.Ignore(ctx, episodeIDs)
+	if err != nil {
+		return CommandResult{}, err
+	}
+	return CommandResult{Message: fmt.Sprintf("Ignored %d of %d episode(s).", count, len(episodeIDs))}, nil
+}
+
+// MarkEpisodesSeen marks several NEW or IGNORED episodes as SEEN in one
+// transaction.
+func (a *App) MarkEpisodesSeen(ctx context.Context, episodeIDs []string) (CommandResult, error) {
+	if a.readOnly {
+		return CommandResult{}, ErrReadOnly
+	}
+	count, err := a.episodes.MarkSeen(ctx, episodeIDs)
+	if err != nil {
+		return CommandResult{}, err
+	}
+	return CommandResult{Message: fmt.Sprintf("Marked %d of %d episode(s) as seen.", count, len(episodeIDs))}, nil
+}
+
+// DeleteEpisodes deletes the downloaded files of several episodes, skipping
+// those the delete command would refuse. Files are removed one at a time, so
+// an error leaves the files deleted before it gone.
+func (a *App) DeleteEpisodes(ctx context.Context, episodeIDs []string) (CommandResult, error) {
+	if a.readOnly {
+		return CommandResult{}, ErrReadOnly
+	}
+	var count int
+	for _, id := range episodeIDs {
+		info, err := a.episodes.FetchEpisodeInfo(ctx, id)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return CommandResult{}, err
+		}
+		switch info.State {
+		case stateDownloaded, stateCorrupt, statePlayed:
+		default:
+			continue
+		}
+		if info.FilePath == "" {
+			continue
+		}
+		if _, err := a.downloads.DeleteEpisode(ctx, info, a.config.PruneEmptyDirs); err != nil {
+			return CommandResult{}, err
+		}
+		count++
+	}
+	return CommandResult{Message: fmt.Sprintf("Deleted the files of %d of %d episode(s).", count, len(episodeIDs))}, nil
+}
+
+const ignoreUsage = "ignore <episode_id> | ignore podcast <podcast_id>"
+
+func (a *App) ignoreCommand(ctx context.Context, args []string) (CommandResult, error) {
+	if len(args) == 2 && strings.EqualFold(args[0], "podcast") {
+		return a.ignorePodcastCommand(ctx, strings.TrimSpace(args[1]))
+	}
+	if len(args) != 1 {
+		return CommandResult{Message: "Usage: " + ignoreUsage}, nil
+	}
+	episodeID := strings.TrimSpace(args[0])
+	if episodeID == "" {
+		return CommandResult{Message: "Episode ID cannot be empty."}, nil
+	}
+
+	info, err := a.episodes.FetchEpisodeInfo(ctx, episodeID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return CommandResult{Message: "Episode not found."}, nil
+		}
+		return CommandResult{}, err
+	}
+
+	switch info.State {
+	case stateIgnored:
+		if err := a.episodes.UpdateEpisodeState(ctx, info.ID, stateSeen); err != nil {
+			return CommandResult{}, err
+		}
+		return CommandResult{Message: fmt.Sprintf("Episode %s unignored.", info.ID)}, nil
+	default:
+		if err := a.downloads.RemoveFromQueue(ctx, info.ID); err != nil {
+			return CommandResult{}, err
+		}
+		if err := a.episodes.UpdateEpisodeState(ctx, info.ID, stateIgnored); err != nil {
 			return CommandResult{}, err
 		}
 		return CommandResult{Message: fmt.Sprintf("Episode %s ignored.", info.ID)}, nil
 	}
 }
 
+// ignorePodcastCommand ignores the backlog of a podcast: its NEW and SEEN
+// episodes. Episodes queued or downloaded already keep their state.
+func (a *App) ignorePodcastCommand(ctx context.Context, podcastID string) (CommandResult, error) {
+	exists, _, err := a.subscriptions.IsSubscribed(ctx, podcastID)
+	if err != nil {
+		return CommandResult{}, err
+	}
+	if !exists {
+		return CommandResult{Message: "No subscription found for that podcast."}, nil
+	}
+	count, err := a.episodes.IgnorePodcast(ctx, podcastID)
+	if err != nil {
+		return CommandResult{}, err
+	}
+	return CommandResult{Message: fmt.Sprintf("Ignored %d episode(s) of %s.", count, podcastID)}, nil
+}
+
+// queueLatestCommand queues the newest episodes of a podcast, one unless a
+// count is given, skipping those not NEW or SEEN.
+func (a *App) queueLatestCommand(ctx context.Context, args []string) (CommandResult, error) {
+	if len(args) < 1 || len(args) > 2 {
+		return CommandResult{Message: "Usage: " + queueUsage}, nil
+	}
+	n := 1
+	if len(args) == 2 {
+		parsed, err := strconv.Atoi(args[1])
+		if err != nil || parsed < 1 {
+			return CommandResult{Message: "Usage: " + queueUsage}, nil
+		}
+		n = parsed
+	}
+	podcastID := strings.TrimSpace(args[0])
+	exists, _, err := a.subscriptions.IsSubscribed(ctx, podcastID)
+	if err != nil {
+		return CommandResult{}, err
+	}
+	if !exists {
+		return CommandResult{Message: "No subscription found for that podcast."}, nil
+	}
+	count, err := a.downloads.EnqueueLatest(ctx, podcastID, n)
+	if err != nil {
+		return CommandResult{}, err
+	}
+	if count > 0 && a.downloadMgr != nil {
+		a.downloadMgr.Notify()
+	}
+	return CommandResult{Message: fmt.Sprintf("Queued %d of the %d newest episode(s) for download.", count, n)}, nil
+}
+
+// alreadyDownloadingMessage is shown when an episode is claimed by a worker.
+const alreadyDownloadingMessage = "Episode is already downloading."
+
+// noWorkersHint explains how to make progress when parallel_downloads is 0.
+const noWorkersHint = "No download workers are running; use 'queue process' to download now or 'queue workers <n>' to start workers."
+
+// DownloadWorkers returns the number of running background download workers.
+func (a *App) DownloadWorkers() int {
+	return a.downloadMgr.Workers()
+}
+
+// queueProcessCommand downloads everything waiting in the queue before returning.
+func (a *App) queueProcessCommand(ctx context.Context) (CommandResult, error) {
+	result, err := a.downloadMgr.ProcessQueue(ctx)
+	if err != nil {
+		return CommandResult{}, err
+	}
+	if result.Downloaded == 0 && result.Skipped == 0 && result.Failed == 0 {
+		return CommandResult{Message: "Nothing waiting in the queue."}, nil
+	}
+	msg := fmt.Sprintf("Downloaded %d episode(s)", result.Downloaded)
+	if result.Skipped > 0 {
+		msg += fmt.Sprintf(", %d skipped as too large", result.Skipped)
+	}
+	if result.Failed > 0 {
+		msg += fmt.Sprintf(", %d failed", result.Failed)
+	}
+	return CommandResult{Message: msg + "."}, nil
+}
+
+// queueControlCommand pauses, resumes or cancels a single queued download,
+// stopping its transfer when a worker is downloading it.
+func (a *App) queueControlCommand(ctx context.Context, action string, args []string) (CommandResult, error) {
+	if len(args) != 1 || strings.TrimSpace(args[0]) == "" {
+		return CommandResult{Message: fmt.Sprintf("Usage: queue %s <episode_id>", action)}, nil
+	}
+	info, err := a.episodes.FetchEpisodeInfo(ctx, strings.TrimSpace(args[0]))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return CommandResult{Message: "Episode not found."}, nil
+		}
+		return CommandResult{}, err
+	}
+	if info.State != stateQueued {
+		return CommandResult{Message: "Episode is not in the download queue."}, nil
+	}
+
+	switch action {
+	case "pause":
+		paused, err := a.downloads.PauseDownload(ctx, info.ID)
+		if err != nil {
+			return CommandResult{}, err
+		}
+		if !paused {
+			return CommandResult{Message: "Download is already paused."}, nil
+		}
+		if a.downloadMgr != nil {
+			a.downloadMgr.Abort(info.ID, downloads.ErrDownloadPaused)
+		}
+		return CommandResult{Message: fmt.Sprintf("Download of %s paused.", info.ID)}, nil
+	case "resume":
+		resumed, err := a.downloads.ResumeDownload(ctx, info.ID)
+		if err != nil {
+			return CommandResult{}, err
+		}
+		if !resumed {
+			return CommandResult{Message: "Download is not paused."}, nil
+		}
+		if a.downloadMgr != nil {
+			a.downloadMgr.Notify()
+		}
+		msg := fmt.Sprintf("Download of %s resumed.", info.ID)
+		if a.DownloadWorkers() == 0 {
+			msg += " " + noWorkersHint
+		}
+		return CommandResult{Message: msg}, nil
+	default:
+		if a.downloadMgr != nil {
+			a.downloadMgr.Abort(info.ID, downloads.ErrDownloadCancelled)
+		}
+		if err := a.downloads.CancelDownload(ctx, info); err != nil {
+			return CommandResult{}, err
+		}
+		return CommandResult{Message: fmt.Sprintf("Download of %s cancelled.", info.ID)}, nil
+	}
+}
+
+// queueWorkersCommand reports or changes the number of download workers
+// for this session; use config to persist a new default.
+func (a *App) queueWorkersCommand(args []string) (CommandResult, error) {
+	if len(args) == 0 {
+		return CommandResult{Message: fmt.Sprintf("%d download worker(s) running.", a.downloadMgr.Workers())}, nil
+	}
+	n, err := strconv.Atoi(args[0])
+	if len(args) > 1 || err != nil || n < 0 {
+		return CommandResult{Message: "Usage: queue workers [n]"}, nil
+	}
+	a.downloadMgr.Resize(n)
+	a.downloadMgr.Notify()
+	return CommandResult{Message: fmt.Sprintf("Download workers set to %d.", n)}, nil
+}
+
+func (a *App) redownloadCommand(ctx context.Context, args []string) (CommandResult, error) {
+	if len(args) < 1 || len(args) > 2 {
+		return CommandResult{Message: "Usage: redownload <deleted|corrupt> [podcast_id]"}, nil
+	}
+
+	var state, label string
+	switch strings.ToLower(args[0]) {
+	case "deleted":
+		state, label = stateDeleted, "deleted"
+	case "corrupt":
+		state, label = stateCorrupt, "corrupt"
+	default:
+		return CommandResult{Message: "Usage: redownload <deleted|corrupt> [podcast_id]"}, nil
+	}
+
+	podcastID := ""
+	if len(args) == 2 {
+		podcastID = strings.TrimSpace(args[1])
+		exists, _, err := a.subscriptions.IsSubscribed(ctx, podcastID)
+		if err != nil {
+			return CommandResult{}, err
+		}
+		if !exists {
+			return CommandResult{Message: "No subscription found for that podcast."}, nil
+		}
+	}
+
+	switch state {
+	case stateDeleted:
+		// Pick up files removed since the downloads view was last opened.
+		if _, err := a.episodes.CheckDeletedFiles(ctx); err != nil {
+			return CommandResult{}, err
+		}
+	case stateCorrupt:
+		// Re-hash the downloads to find files changed since they were recorded.
+		if _, err := a.downloads.Verify(ctx, podcastID, 1); err != nil {
+			return CommandResult{}, err
+		}
+	}
+
+	count, err := a.downloads.EnqueueByState(ctx, state, podcastID)
+	if err != nil {
+		return CommandResult{}, err
+	}
+	if count == 0 {
+		return CommandResult{Message: fmt.Sprintf("No %s episodes to re-download.", label)}, nil
+	}
+	if a.downloadMgr != nil {
+		a.downloadMgr.Notify()
+	}
+	return CommandResult{Message: fmt.Sprintf("Queued %d %s episode(s) for re-download.", count, label)}, nil
+}
+
+func (a *App) dedupeCommand(ctx context.Context, args []string) (CommandResult, error) {
+	link := false
+	switch {
+	case len(args) == 0:
+	case len(args) == 1 && strings.EqualFold(args[0], "link"):
+		link = true
+	default:
+		return CommandResult{Message: "Usage: dedupe [link]"}, nil
+	}
+
+	result, err := a.downloads.Dedupe(ctx, link)
+	if err != nil {
+		return CommandResult{}, err
+	}
+	if result.Duplicates == 0 {
+		return CommandResult{Message: "No duplicate downloads found."}, nil
+	}
+
+	sizeMB := float64(result.BytesSaved) / (1024 * 1024)
+	if link {
+		return CommandResult{Message: fmt.Sprintf("Linked %d duplicate file(s), saved %.1f MB.", result.Linked, sizeMB)}, nil
+	}
+	return CommandResult{Message: fmt.Sprintf("Found %d duplicate file(s) in %d group(s), %.1f MB reclaimable. Run 'dedupe link' to replace them with hard links.", result.Duplicates, result.Groups, sizeMB)}, nil
+}
+
+func (a *App) renameFilesCommand(ctx context.Context, args []string) (CommandResult, error) {
+	if len(args) > 1 {
+		return CommandResult{Message: "Usage: rename-files [podcast_id]"}, nil
+	}
+
+	podcastID := ""
+	if len(args) == 1 {
+		podcastID = strings.TrimSpace(args[0])
+		exists, _, err := a.subscriptions.IsSubscribed(ctx, podcastID)
+		if err != nil {
+			return CommandResult{}, err
+		}
+		if !exists {
+			return CommandResult{Message: "No subscription found for that podcast."}, nil
+		}
+	}
+
+	result, err := a.downloads.RenameFiles(ctx, podcastID, a.config.PruneEmptyDirs)
+	if err != nil {
+		return CommandResult{}, err
+	}
+	if result.Renamed == 0 && result.Missing == 0 && len(result.Conflicts) == 0 {
+		return CommandResult{Message: "All downloaded files already match the filename template."}, nil
+	}
+
+	lines := []string{fmt.Sprintf("Renamed %d file(s); %d already matched the template.", result.Renamed, result.Unchanged)}
+	if result.Missing > 0 {
+		lines = append(lines, fmt.Sprintf("%d file(s) are missing from disk.", result.Missing))
+	}
+	for _, conflict := range result.Conflicts {
+		lines = append(lines, fmt.Sprintf("Skipped, target exists: %s", conflict))
+	}
+	return CommandResult{Message: strings.Join(lines, "\n")}, nil
+}
+
+const verifyUsage = "verify [podcast_id] [--sample <percent>%]"
+
+// splitSampleOption pulls a --sample 5% or --sample=5% option out of args
+// and returns the share of files to check, 1 without it.
+func splitSampleOption(args []string) (float64, []string, error) {
+	sample := 1.0
+	rest := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		var value string
+		switch arg := args[i]; {
+		case arg == "--sample":
+			if i+1 >= len(args) {
+				return 0, nil, errors.New("--sample needs a percentage")
+			}
+			i++
+			value = args[i]
+		case strings.HasPrefix(arg, "--sample="):
+			value = strings.TrimPrefix(arg, "--sample=")
+		default:
+			rest = append(rest, arg)
+			continue
+		}
+		percent, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+		if err != nil || percent <= 0 || percent > 100 {
+			return 0, nil, fmt.Errorf("invalid sample: %s, must be a percentage above 0 and up to 100", value)
+		}
+		sample = percent / 100
+	}
+	return sample, rest, nil
+}
+
+// verifyCommand re-hashes downloaded files and marks those that no longer
+// match their recorded hash corrupt. With --sample it checks a random share
+// of the files and extrapolates how many are corrupt overall.
+func (a *App) verifyCommand(ctx context.Context, args []string) (CommandResult, error) {
+	sample, rest, err := splitSampleOption(args)
+	if err != nil {
+		return CommandResult{Message: fmt.Sprintf("Usage: %s\n%v", verifyUsage, err)}, nil
+	}
+	if len(rest) > 1 {
+		return CommandResult{Message: "Usage: " + verifyUsage}, nil
+	}
+
+	podcastID := ""
+	if len(rest) == 1 {
+		podcastID = strings.TrimSpace(rest[0])
+		exists, _, err := a.subscriptions.IsSubscribed(ctx, podcastID)
+		if err != nil {
+			return CommandResult{}, err
+		}
+		if !exists {
+			return CommandResult{Message: "No subscription found for that podcast."}, nil
+		}
+	}
+
+	result, err := a.downloads.Verify(ctx, podcastID, sample)
+	if err != nil {
+		return CommandResult{}, err
+	}
+	if result.Total == 0 {
+		return CommandResult{Message: "No downloaded files to verify."}, nil
+	}
+
+	lines := []string{fmt.Sprintf("Verified %d of %d file(s): %d corrupt.", result.Checked, result.Total, len(result.Corrupt))}
+	for _, id := range result.Corrupt {
+		lines = append(lines, "Corrupt: "+id)
+	}
+	if sample < 1 && result.Checked > 0 {
+		lines = append(lines, fmt.Sprintf("Corruption rate %.1f%%; about %d of %d file(s) expected to be corrupt.",
+			100*float64(len(result.Corrupt))/float64(result.Checked), result.EstimatedCorrupt(), result.Total))
+	}
+	if result.Recovered > 0 {
+		lines = append(lines, fmt.Sprintf("%d corrupt file(s) match their hash again.", result.Recovered))
+	}
+	if result.Missing > 0 {
+		lines = append(lines, fmt.Sprintf("%d file(s) are missing from disk.", result.Missing))
+	}
+	if result.Unhashed > 0 {
+		lines = append(lines, fmt.Sprintf("%d file(s) have no recorded hash.", result.Unhashed))
+	}
+	if len(result.Corrupt) > 0 {
+		lines = append(lines, "Use 'redownload corrupt' to fetch corrupt files again.")
+	}
+	return CommandResult{Message: strings.Join(lines, "\n")}, nil
+}
+
+func (a *App) adoptCommand(ctx context.Context, args []string) (CommandResult, error) {
+	if len(args) != 1 {
+		return CommandResult{Message: "Usage: adopt <dir>"}, nil
+	}
+
+	result, err := a.downloads.Adopt(ctx, args[0])
+	if err != nil {
+		return CommandResult{}, err
+	}
+	if result.Scanned == 0 {
+		return CommandResult{Message: "No files found to adopt."}, nil
+	}
+	return CommandResult{Message: fmt.Sprintf("Adopted %d of %d file(s); %d unmatched.", result.Adopted, result.Scanned, len(result.Unmatched))}, nil
+}
+
+func (a *App) refreshCommand(ctx context.Context, args []string) (CommandResult, error) {
+	var (
+		result RefreshResult
+		err    error
+	)
+	switch len(args) {
+	case 0:
+		result, err = a.subscriptions.Refresh(ctx)
+	case 1:
+		result, err = a.subscriptions.RefreshPodcast(ctx, args[0])
+		if errors.Is(err, sql.ErrNoRows) {
+			return CommandResult{Message: "No subscription found for that podcast."}, nil
+		}
+	default:
+		return CommandResult{Message: "Usage: refresh [podcast_id]"}, nil
+	}
+	if err != nil {
+		return CommandResult{}, err
+	}
+
+	msg := fmt.Sprintf("Refreshed %d feed(s), %d new episode(s)", result.Refreshed, result.Added)
+	if len(result.Failures) > 0 {
+		msg += fmt.Sprintf(", %d error(s)", len(result.Failures))
+	}
+	var added []string
+	for _, podcast := range result.Podcasts {
+		if podcast.Added > 0 {
+			added = append(added, fmt.Sprintf("%s +%d", podcast.Title, podcast.Added))
+		}
+	}
+	if len(added) > 0 {
+		msg += ": " + strings.Join(added, ", ")
+	}
+	return CommandResult{Message: msg + ".", RefreshFailures: result.Failures, RefreshSummary: &result}, nil
+}
+
+func (a *App) syncCommand(ctx context.Context, args []string) (CommandResult, error) {
+	if len(args) != 0 {
+		return CommandResult{Message: "Usage: sync"}, nil
+	}
+	if strings.TrimSpace(a.config.GpodderServer) == "" || strings.TrimSpace(a.config.GpodderUsername) == "" {
+		return CommandResult{Message: "gpodder sync is not configured. Set gpodder_server, gpodder_username and gpodder_password via 'config'."}, nil
+	}
+
+	client := gpodder.NewClient(a.httpClient, a.config.GpodderServer, a.config.GpodderUsername, a.config.GpodderPassword, a.config.GpodderDevice)
+	result, err := a.subscriptions.Sync(ctx, client)
+	if err != nil {
+		return CommandResult{}, err
+	}
+
+	msg := fmt.Sprintf("Synced as %s: %d subscription(s) added, %d change(s) uploaded, %d download(s) and %d play position(s) reported, %d episode(s) marked seen",
+		client.Device(), result.Subscribed, result.Pushed, result.ActionsSent, result.PlaysSent, result.MarkedSeen)
+	if result.RemoteRemoved > 0 {
+		msg += fmt.Sprintf(", %d removed elsewhere (kept here)", result.RemoteRemoved)
+	}
+	if len(result.Errors) > 0 {
+		msg += fmt.Sprintf(", %d error(s)", len(result.Errors))
+	}
+	return CommandResult{Message: msg + "."}, nil
+}
+
+const (
+	exportPlaylistUsage = "Usage: export playlist <file> [--podcast <podcast_id>] [--tag <tag>] [--state downloaded|played]"
+	exportActionsUsage  = "Usage: export actions <file>"
+)
+
 func (a *App) exportCommand(ctx context.Context, args []string) (CommandResult, error) {
+	if len(args) > 0 && strings.EqualFold(args[0], "playlist") {
+		return a.exportPlaylistCommand(ctx, args[1:])
+	}
+	if len(args) > 0 && strings.EqualFold(args[0], "actions") {
+		return a.exportActionsCommand(ctx, args[1:])
+	}
 	if len(args) != 1 {
-		return CommandResult{Message: "Usage: export <file>"}, nil
+		return CommandResult{Message: "Usage: export <file>\n" + exportPlaylistUsage + "\n" + exportActionsUsage}, nil
 	}
 	count, err := a.ExportOPML(ctx, args[0])
 	if err != nil {
@@ -604,34 +2013,211 @@ func (a *App) exportCommand(ctx context.Context, args []string) (CommandResult,
 	return CommandResult{Message: fmt.Sprintf("Exported %d subscriptions.", count)}, nil
 }
 
-func (a *App) importCommand(ctx context.Context, args []string) (CommandResult, error) {
+// exportPlaylistCommand writes downloaded episodes to an M3U playlist,
+// optionally only those of a podcast, a tag or a state.
+func (a *App) exportPlaylistCommand(ctx context.Context, args []string) (CommandResult, error) {
+	tag, rest, err := splitTagFilter(args)
+	if err != nil {
+		return CommandResult{Message: fmt.Sprintf("%s\n%v", exportPlaylistUsage, err)}, nil
+	}
+	filter := repository.PlaylistFilter{Tag: tag}
+	var file string
+	for i := 0; i < len(rest); i++ {
+		switch option := strings.ToLower(rest[i]); option {
+		case "--podcast", "--state":
+			if i+1 >= len(rest) || strings.TrimSpace(rest[i+1]) == "" {
+				return CommandResult{Message: fmt.Sprintf("%s\n%s needs a value", exportPlaylistUsage, option)}, nil
+			}
+			i++
+			value := strings.TrimSpace(rest[i])
+			if option == "--podcast" {
+				filter.PodcastID = value
+				continue
+			}
+			filter.State = strings.ToUpper(value)
+			if filter.State != stateDownloaded && filter.State != statePlayed {
+				return CommandResult{Message: fmt.Sprintf("%s\nunknown state: %s", exportPlaylistUsage, value)}, nil
+			}
+		default:
+			if file != "" || strings.HasPrefix(option, "--") {
+				return CommandResult{Message: fmt.Sprintf("%s\nunknown option: %s", exportPlaylistUsage, rest[i])}, nil
+			}
+			file = rest[i]
+		}
+	}
+	if file == "" {
+		return CommandResult{Message: exportPlaylistUsage}, nil
+	}
+	count, err := a.episodes.ExportPlaylist(ctx, file, filter)
+	if errors.Is(err, episodes.ErrNoEpisodesToExport) {
+		return CommandResult{Message: "No downloaded episodes to export."}, nil
+	}
+	if err != nil {
+		return CommandResult{}, err
+	}
+	return CommandResult{Message: fmt.Sprintf("Exported %d episodes to %s.", count, file)}, nil
+}
+
+// exportActionsCommand writes the download and play history as gpodder
+// episode actions.
+func (a *App) exportActionsCommand(ctx context.Context, args []string) (CommandResult, error) {
 	if len(args) != 1 {
-		return CommandResult{Message: "Usage: import <file>"}, nil
+		return CommandResult{Message: exportActionsUsage}, nil
+	}
+	count, err := a.subscriptions.ExportActions(ctx, args[0], a.config.GpodderDevice)
+	if errors.Is(err, subscriptions.ErrNoActionsToExport) {
+		return CommandResult{Message: "No downloaded or played episodes to export."}, nil
 	}
-	result, err := a.ImportOPML(ctx, args[0])
+	if err != nil {
+		return CommandResult{}, err
+	}
+	return CommandResult{Message: fmt.Sprintf("Exported %d episode actions to %s.", count, args[0])}, nil
+}
+
+const (
+	importUsage      = "Usage: import <file> [--on-conflict keep|imported|merge|ask]"
+	importStateUsage = "Usage: import state-from <other-app.db>"
+)
+
+func (a *App) importCommand(ctx context.Context, args []string) (CommandResult, error) {
+	if len(args) > 0 && strings.EqualFold(args[0], "state-from") {
+		return a.importStateCommand(ctx, args[1:])
+	}
+	onConflict, rest, err := splitConflictOption(args)
+	if err != nil {
+		return CommandResult{Message: fmt.Sprintf("%s\n%v", importUsage, err)}, nil
+	}
+	if len(rest) != 1 {
+		return CommandResult{Message: importUsage + "\n" + importStateUsage}, nil
+	}
+	result, err := a.ImportOPML(ctx, rest[0], onConflict)
 	if err != nil {
 		return CommandResult{}, err
 	}
 	msg := fmt.Sprintf("Imported %d subscriptions", result.Imported)
+	if result.Updated > 0 {
+		msg += fmt.Sprintf(", updated %d", result.Updated)
+	}
 	if result.Skipped > 0 {
 		msg += fmt.Sprintf(", skipped %d", result.Skipped)
 	}
+	if len(result.Conflicts) > 0 {
+		msg += fmt.Sprintf(", %d conflicts", len(result.Conflicts))
+	}
 	if len(result.Errors) > 0 {
 		msg += fmt.Sprintf(", %d errors", len(result.Errors))
 	}
-	return CommandResult{Message: msg}, nil
+	return CommandResult{Message: msg, ImportFailures: result.Errors, ImportConflicts: result.Conflicts, ImportSummary: &result}, nil
+}
+
+// splitConflictOption takes --on-conflict <policy> or --on-conflict=<policy>
+// out of args. The policy is ConflictKeep when the option is missing.
+func splitConflictOption(args []string) (string, []string, error) {
+	policy := ConflictKeep
+	rest := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; {
+		case arg == "--on-conflict":
+			if i+1 >= len(args) {
+				return "", nil, errors.New("--on-conflict needs a policy")
+			}
+			i++
+			policy = args[i]
+		case strings.HasPrefix(arg, "--on-conflict="):
+			policy = strings.TrimPrefix(arg, "--on-conflict=")
+		default:
+			rest = append(rest, arg)
+			continue
+		}
+		policy = strings.ToLower(policy)
+		if !slices.Contains(subscriptions.ConflictPolicies, policy) {
+			return "", nil, fmt.Errorf("unknown conflict policy: %s", policy)
+		}
+	}
+	return policy, rest, nil
+}
+
+// importStateCommand merges the episode states and play positions of
+// another podsink database into this one.
+func (a *App) importStateCommand(ctx context.Context, args []string) (CommandResult, error) {
+	if len(args) != 1 {
+		return CommandResult{Message: importStateUsage}, nil
+	}
+	path, err := config.ExpandPath(args[0])
+	if err != nil {
+		return CommandResult{}, err
+	}
+	result, err := a.episodes.ImportStates(ctx, path)
+	if err != nil {
+		return CommandResult{}, err
+	}
+	msg := fmt.Sprintf("Merged %d episodes: %d played, %d ignored, %d seen, %d positions moved forward", result.Matched, result.Played, result.Ignored, result.Seen, result.Positions)
+	if result.Unmatched > 0 {
+		msg += fmt.Sprintf("; %d not in this library", result.Unmatched)
+	}
+	return CommandResult{Message: msg + "."}, nil
 }
 
+// ExportOPML writes the subscriptions to an OPML file, or with their
+// settings to a JSON settings file when filePath ends in .json.
 func (a *App) ExportOPML(ctx context.Context, filePath string) (int, error) {
+	if subscriptions.IsSettingsFile(filePath) {
+		return a.subscriptions.ExportSettings(ctx, filePath)
+	}
 	return a.subscriptions.ExportOPML(ctx, filePath)
 }
 
-func (a *App) ImportOPML(ctx context.Context, filePath string) (OPMLImportResult, error) {
-	return a.subscriptions.ImportOPML(ctx, filePath)
+// ImportOPML subscribes to the outlines of an OPML file, or to the podcasts
+// of a JSON settings file when filePath ends in .json; onConflict is one of
+// the Conflict* policies, empty for ConflictKeep.
+func (a *App) ImportOPML(ctx context.Context, filePath, onConflict string) (OPMLImportResult, error) {
+	if a.readOnly {
+		return OPMLImportResult{}, ErrReadOnly
+	}
+	if subscriptions.IsSettingsFile(filePath) {
+		return a.subscriptions.ImportSettings(ctx, filePath, onConflict)
+	}
+	return a.subscriptions.ImportOPML(ctx, filePath, onConflict)
+}
+
+// ResolveImportConflict applies ConflictKeep, ConflictImported or
+// ConflictMerge to a conflict an import listed.
+func (a *App) ResolveImportConflict(ctx context.Context, conflict ImportConflict, choice string) (CommandResult, error) {
+	if a.readOnly {
+		return CommandResult{}, ErrReadOnly
+	}
+	changed, err := a.subscriptions.ResolveImportConflict(ctx, conflict, choice)
+	if err != nil {
+		return CommandResult{}, err
+	}
+	if !changed {
+		return CommandResult{Message: fmt.Sprintf("Kept %s as it is.", conflict.Title)}, nil
+	}
+	return CommandResult{Message: fmt.Sprintf("Updated %s from the import.", conflict.Title)}, nil
 }
 
 func (a *App) EpisodeDetails(ctx context.Context, episodeID string) (EpisodeDetail, error) {
-	return a.episodes.EpisodeDetails(ctx, episodeID)
+	detail, err := a.episodes.EpisodeDetails(ctx, episodeID)
+	if err != nil || a.readOnly || detail.State != stateNew {
+		return detail, err
+	}
+	// Opening the details of a new episode marks it seen.
+	if _, err := a.episodes.MarkSeen(ctx, []string{detail.ID}); err != nil {
+		return EpisodeDetail{}, err
+	}
+	detail.State = stateSeen
+	return detail, nil
+}
+
+// WebSubTopics lists the subscriptions whose feed advertises a WebSub hub.
+func (a *App) WebSubTopics(ctx context.Context) ([]domain.WebSubTopic, error) {
+	return a.subscriptions.WebSubTopics(ctx)
+}
+
+// HTTPClient returns the client used for feeds and downloads, configured
+// with the proxy and TLS settings.
+func (a *App) HTTPClient() *http.Client {
+	return a.httpClient
 }
 
 // CountQueued returns the count of episodes in QUEUED state.
@@ -639,7 +2225,128 @@ func (a *App) CountQueued(ctx context.Context) (int, error) {
 	return a.episodes.CountQueued(ctx)
 }
 
-// CountDownloaded returns the count of episodes in DOWNLOADED or DELETED state.
+// CountNew returns the count of episodes in NEW state.
+func (a *App) CountNew(ctx context.Context) (int, error) {
+	return a.episodes.CountNew(ctx)
+}
+
+// Filter modes of the episode list besides hiding ignored episodes.
+const (
+	EpisodeFilterAll        = "all"
+	EpisodeFilterIgnored    = "ignored"
+	EpisodeFilterDownloaded = "downloaded"
+)
+
+// EpisodeView is how the episode list is shown, kept across sessions.
+type EpisodeView struct {
+	Filter string // An EpisodeFilter constant, or "" to hide ignored episodes
+	Order  string // repository.OrderShortest, OrderLongest or "" for newest first
+}
+
+// EpisodeView returns the episode list's filter mode and order as last
+// saved. Unknown values saved by other versions read as the defaults.
+func (a *App) EpisodeView(ctx context.Context) (EpisodeView, error) {
+	filter, order, err := a.episodes.ViewSettings(ctx)
+	if err != nil {
+		return EpisodeView{}, err
+	}
+	var view EpisodeView
+	switch filter {
+	case EpisodeFilterAll, EpisodeFilterIgnored, EpisodeFilterDownloaded:
+		view.Filter = filter
+	}
+	switch order {
+	case repository.OrderShortest, repository.OrderLongest:
+		view.Order = order
+	}
+	return view, nil
+}
+
+// SaveEpisodeView remembers the episode list's filter mode and order for
+// the next session. In read-only mode nothing is saved.
+func (a *App) SaveEpisodeView(ctx context.Context, view EpisodeView) error {
+	if a.readOnly {
+		return nil
+	}
+	return a.episodes.SaveViewSettings(ctx, view.Filter, view.Order)
+}
+
+// CountFailed returns the count of queued episodes whose download failed.
+func (a *App) CountFailed(ctx context.Context) (int, error) {
+	return a.episodes.CountFailed(ctx)
+}
+
+// CountDownloaded returns the count of episodes in DOWNLOADED, DELETED or CORRUPT state.
 func (a *App) CountDownloaded(ctx context.Context) (int, error) {
 	return a.episodes.CountDownloaded(ctx)
 }
+
+func (a *App) danglingCommand(ctx context.Context, args []string) (CommandResult, error) {
+	const usage = "Usage: dangling [adopt <file> | delete <file> | clean]"
+	files, err := a.episodes.FindDanglingFiles(ctx, a.config.DownloadRoot, a.config.TmpDir, config.TrashDir(a.config.DownloadRoot), config.OriginalsDir(a.config.DownloadRoot))
+	if err != nil {
+		return CommandResult{}, err
+	}
+
+	if len(args) == 0 {
+		if len(files) == 0 {
+			return CommandResult{Message: "No dangling files."}, nil
+		}
+		lines := []string{fmt.Sprintf("%d dangling file(s):", len(files))}
+		for _, file := range files {
+			lines = append(lines, fmt.Sprintf("  %s (%.1f MB)", file.Path, float64(file.SizeBytes)/(1024*1024)))
+		}
+		return CommandResult{Message: strings.Join(lines, "\n")}, nil
+	}
+
+	switch strings.ToLower(args[0]) {
+	case "clean":
+		if len(args) != 1 {
+			return CommandResult{Message: usage}, nil
+		}
+		if len(files) == 0 {
+			return CommandResult{Message: "No dangling files."}, nil
+		}
+		removed := 0
+		for _, file := range files {
+			if err := a.downloads.RemoveDanglingFile(file.Path, a.config.PruneEmptyDirs); err != nil {
+				return CommandResult{Message: fmt.Sprintf("Deleted %d dangling file(s); stopped at %s: %v", removed, file.Path, err)}, nil
+			}
+			removed++
+		}
+		return CommandResult{Message: fmt.Sprintf("Deleted %d dangling file(s).", removed)}, nil
+	case "adopt", "delete":
+		if len(args) != 2 {
+			return CommandResult{Message: usage}, nil
+		}
+	default:
+		return CommandResult{Message: usage}, nil
+	}
+
+	// Only files the scan reports are acted on, never arbitrary paths.
+	var path string
+	for _, file := range files {
+		if filepath.Clean(file.Path) == filepath.Clean(args[1]) {
+			path = file.Path
+			break
+		}
+	}
+	if path == "" {
+		return CommandResult{Message: "Not a dangling file: " + args[1]}, nil
+	}
+
+	if strings.EqualFold(args[0], "delete") {
+		if err := a.downloads.RemoveDanglingFile(path, a.config.PruneEmptyDirs); err != nil {
+			return CommandResult{}, err
+		}
+		return CommandResult{Message: fmt.Sprintf("Deleted %s.", path)}, nil
+	}
+	episode, err := a.downloads.AdoptFile(ctx, path)
+	if err != nil {
+		if errors.Is(err, downloads.ErrNoEpisodeMatch) {
+			return CommandResult{Message: "No episode matches " + filepath.Base(path) + "."}, nil
+		}
+		return CommandResult{}, err
+	}
+	return CommandResult{Message: fmt.Sprintf("Adopted %s as %s.", filepath.Base(path), episode.Title)}, nil
+}
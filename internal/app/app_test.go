@@ -1,22 +1,39 @@
 package app
 
 import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
 	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"podsink/internal/config"
+	"podsink/internal/domain"
+	"podsink/internal/gpodder"
 	"podsink/internal/itunes"
+	"podsink/internal/repository"
 	"podsink/internal/storage"
+	"podsink/internal/subscriptions"
 )
 
 type recordingSleeper struct {
@@ -95,7 +112,7 @@ func TestImportOPML(t *testing.T) {
 		ITunes:     itunes.NewClient(server.Client(), server.URL),
 	}
 
-	application := NewWithDependencies(cfg, filepath.Join(dir, "config.yaml"), db, deps)
+	application := NewWithDependencies(context.Background(), cfg, filepath.Join(dir, "config.yaml"), db, deps)
 	t.Cleanup(func() {
 		application.Close()
 	})
@@ -112,7 +129,7 @@ func TestImportOPML(t *testing.T) {
 		t.Fatalf("write OPML file: %v", err)
 	}
 
-	result, err := application.ImportOPML(ctx, opmlPath)
+	result, err := application.ImportOPML(ctx, opmlPath, "")
 	if err != nil {
 		t.Fatalf("ImportOPML error = %v", err)
 	}
@@ -135,46 +152,83 @@ func TestImportOPML(t *testing.T) {
 	}
 }
 
-func TestExitCommandSetsQuit(t *testing.T) {
-	app := newTestApp(t)
+func TestExportAndImportSettingsAsJSON(t *testing.T) {
+	ctx := context.Background()
+	server := newMockPodcastServer(t)
 
-	result, err := app.Execute(context.Background(), "quit")
-	if err != nil {
-		t.Fatalf("Execute(quit) error = %v", err)
+	source := newTestAppWithClient(t, server.Client())
+	opmlPath := filepath.Join(t.TempDir(), "import.opml")
+	contents := fmt.Sprintf(`<opml version="2.0"><body>
+  <outline text="News"><outline type="rss" text="Example Podcast" xmlUrl="%s/feed" /></outline>
+</body></opml>`, server.URL)
+	if err := os.WriteFile(opmlPath, []byte(contents), 0o600); err != nil {
+		t.Fatalf("write OPML file: %v", err)
 	}
-
-	if !result.Quit {
-		t.Fatal("expected quit result")
+	if _, err := source.ImportOPML(ctx, opmlPath, ""); err != nil {
+		t.Fatalf("ImportOPML error = %v", err)
+	}
+	list, err := source.Execute(ctx, "list subscriptions")
+	if err != nil || len(list.SearchResults) != 1 {
+		t.Fatalf("list subscriptions: %+v, %v", list, err)
+	}
+	podcastDir := filepath.Join(t.TempDir(), "example")
+	if _, err := source.Execute(ctx, "podcast set-dir "+list.SearchResults[0].Podcast.ID+" "+podcastDir); err != nil {
+		t.Fatalf("podcast set-dir: %v", err)
 	}
-}
-
-func TestListCommandUsage(t *testing.T) {
-	app := newTestApp(t)
 
-	result, err := app.Execute(context.Background(), "list")
+	settingsPath := filepath.Join(t.TempDir(), "settings.JSON")
+	count, err := source.ExportOPML(ctx, settingsPath)
+	if err != nil || count != 1 {
+		t.Fatalf("ExportOPML(json) = %d, %v", count, err)
+	}
+	data, err := os.ReadFile(settingsPath)
 	if err != nil {
-		t.Fatalf("Execute(list) error = %v", err)
+		t.Fatalf("read settings file: %v", err)
 	}
-
-	if !strings.Contains(result.Message, "Usage: list subscriptions") {
-		t.Fatalf("unexpected list response: %s", result.Message)
+	if !strings.Contains(string(data), `"version": 1`) || !strings.Contains(string(data), `"feed_url": "`+server.URL+`/feed"`) {
+		t.Fatalf("unexpected settings file:\n%s", data)
 	}
-}
-
-func TestConfigShowRendersYaml(t *testing.T) {
-	app := newTestApp(t)
 
-	result, err := app.Execute(context.Background(), "config show")
+	target := newTestAppWithClient(t, server.Client())
+	result, err := target.ImportOPML(ctx, settingsPath, "")
 	if err != nil {
-		t.Fatalf("Execute(config show) error = %v", err)
+		t.Fatalf("ImportOPML(json) error = %v", err)
+	}
+	if result.Imported != 1 || len(result.Errors) != 0 {
+		t.Fatalf("unexpected import result %+v", result)
+	}
+	list, err = target.Execute(ctx, "list subscriptions")
+	if err != nil || len(list.SearchResults) != 1 {
+		t.Fatalf("list subscriptions: %+v, %v", list, err)
+	}
+	imported := list.SearchResults[0]
+	if tags := strings.Join(imported.Tags, ","); tags != "News" {
+		t.Fatalf("expected the exported tag, got %q", tags)
+	}
+	if imported.DownloadDir != podcastDir {
+		t.Fatalf("expected download dir %q, got %q", podcastDir, imported.DownloadDir)
+	}
+	if result, err := target.ImportOPML(ctx, settingsPath, ""); err != nil || result.Skipped != 1 {
+		t.Fatalf("expected the second import to skip the podcast, got %+v, %v", result, err)
 	}
 
-	if !strings.Contains(result.Message, "download_root:") {
-		t.Fatalf("expected download_root in config output: %s", result.Message)
+	emptyPath := filepath.Join(t.TempDir(), "empty.json")
+	if err := os.WriteFile(emptyPath, []byte(`{"version":1,"podcasts":[]}`), 0o600); err != nil {
+		t.Fatalf("write settings file: %v", err)
+	}
+	if _, err := target.ImportOPML(ctx, emptyPath, ""); !errors.Is(err, ErrNoSubscriptionsInJSON) {
+		t.Fatalf("expected ErrNoSubscriptionsInJSON, got %v", err)
+	}
+	newerPath := filepath.Join(t.TempDir(), "newer.json")
+	if err := os.WriteFile(newerPath, []byte(`{"version":2,"podcasts":[]}`), 0o600); err != nil {
+		t.Fatalf("write settings file: %v", err)
+	}
+	if _, err := target.ImportOPML(ctx, newerPath, ""); err == nil {
+		t.Fatal("expected a newer settings file to be refused")
 	}
 }
 
-func TestPodcastLifecycle(t *testing.T) {
+func TestImportOPMLTagsNestedGroupsAndExportsThem(t *testing.T) {
 	ctx := context.Background()
 	server := newMockPodcastServer(t)
 
@@ -184,10 +238,6 @@ func TestPodcastLifecycle(t *testing.T) {
 	cfg.DownloadRoot = filepath.Join(dir, "downloads")
 	cfg.TmpDir = filepath.Join(dir, "tmp")
 
-	if err := os.MkdirAll(cfg.DownloadRoot, 0o755); err != nil {
-		t.Fatalf("mkdir downloads: %v", err)
-	}
-
 	db, err := storage.Open(filepath.Join(dir, "app.db"))
 	if err != nil {
 		t.Fatalf("storage.Open() error = %v", err)
@@ -196,158 +246,168 @@ func TestPodcastLifecycle(t *testing.T) {
 		db.Close()
 	})
 
-	httpClient := server.Client()
 	deps := Dependencies{
-		HTTPClient: httpClient,
-		ITunes:     itunes.NewClient(httpClient, server.URL),
+		HTTPClient: server.Client(),
+		ITunes:     itunes.NewClient(server.Client(), server.URL),
+		Headless:   true,
 	}
-
-	application := NewWithDependencies(cfg, filepath.Join(dir, "config.yaml"), db, deps)
+	application := NewWithDependencies(ctx, cfg, filepath.Join(dir, "config.yaml"), db, deps)
 	t.Cleanup(func() {
 		application.Close()
 	})
 
-	exec := func(command string) CommandResult {
-		result, err := application.Execute(ctx, command)
-		if err != nil {
-			t.Fatalf("Execute(%s) error = %v", command, err)
-		}
-		return result
-	}
-
-	subscribe := func(podcast itunes.Podcast) CommandResult {
-		result, err := application.SubscribePodcast(ctx, podcast)
-		if err != nil {
-			t.Fatalf("SubscribePodcast(%s) error = %v", podcast.ID, err)
-		}
-		return result
+	opmlPath := filepath.Join(dir, "import.opml")
+	contents := fmt.Sprintf(`<opml version="2.0"><body>
+  <outline text="News">
+    <outline text="Tech">
+      <outline type="rss" text="Example Podcast" xmlUrl="%[1]s/feed" />
+    </outline>
+  </outline>
+  <outline text="Favourites">
+    <outline type="rss" text="Example Podcast" xmlUrl="%[1]s/feed" />
+  </outline>
+</body></opml>`, server.URL)
+	if err := os.WriteFile(opmlPath, []byte(contents), 0o600); err != nil {
+		t.Fatalf("write OPML file: %v", err)
 	}
 
-	searchResult := exec("search Example")
-	found := false
-	var targetPodcast itunes.Podcast
-	for _, sr := range searchResult.SearchResults {
-		if sr.Podcast.ID == "12345" {
-			found = true
-			targetPodcast = sr.Podcast
-			break
-		}
+	result, err := application.ImportOPML(ctx, opmlPath, "")
+	if err != nil {
+		t.Fatalf("ImportOPML error = %v", err)
 	}
-	if !found {
-		t.Fatalf("search results missing podcast id 12345")
+	if result.Imported != 1 || result.Skipped != 0 || len(result.Errors) != 0 {
+		t.Fatalf("unexpected import result %+v", result)
 	}
 
-	if msg := subscribe(targetPodcast).Message; !strings.Contains(msg, "Subscribed to Example Podcast") {
-		t.Fatalf("subscribe output unexpected: %s", msg)
+	list, err := application.Execute(ctx, "list subscriptions")
+	if err != nil {
+		t.Fatalf("list subscriptions: %v", err)
 	}
-
-	var count int
-	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM episodes").Scan(&count); err != nil {
-		t.Fatalf("query episodes count: %v", err)
+	if len(list.SearchResults) != 1 {
+		t.Fatalf("expected 1 subscription, got %d", len(list.SearchResults))
 	}
-	if count != 2 {
-		t.Fatalf("expected 2 episodes, got %d", count)
+	if tags := strings.Join(list.SearchResults[0].Tags, ","); tags != "Favourites,News/Tech" {
+		t.Fatalf("expected tags from both groups, got %q", tags)
 	}
 
-	listResult := exec("list subscriptions")
-	if len(listResult.SearchResults) == 0 {
-		t.Fatal("list subscriptions should return search results")
+	exportPath := filepath.Join(dir, "export.opml")
+	if _, err := application.ExportOPML(ctx, exportPath); err != nil {
+		t.Fatalf("ExportOPML error = %v", err)
 	}
-	listFound := false
-	for _, sr := range listResult.SearchResults {
-		if sr.Podcast.Title == "Example Podcast" {
-			listFound = true
-			break
-		}
+	exported, err := os.ReadFile(exportPath)
+	if err != nil {
+		t.Fatalf("read export: %v", err)
 	}
-	if !listFound {
-		t.Fatalf("list output missing subscription: %+v", listResult.SearchResults)
+	for _, group := range []string{`text="Favourites"`, `text="News"`, `text="Tech"`} {
+		if !strings.Contains(string(exported), group) {
+			t.Fatalf("expected export to contain group %s:\n%s", group, exported)
+		}
 	}
+}
 
-	episodesResult := exec("episodes")
-	if len(episodesResult.EpisodeResults) != 2 {
-		t.Fatalf("expected 2 episodes, got %d", len(episodesResult.EpisodeResults))
+func TestTagCommandsAndListFilter(t *testing.T) {
+	ctx := context.Background()
+	server := newMockPodcastServer(t)
+
+	dir := t.TempDir()
+	cfg := config.Defaults()
+	cfg.DownloadRoot = filepath.Join(dir, "downloads")
+	cfg.TmpDir = filepath.Join(dir, "tmp")
+
+	db, err := storage.Open(filepath.Join(dir, "app.db"))
+	if err != nil {
+		t.Fatalf("storage.Open() error = %v", err)
 	}
-	if episodesResult.EpisodeResults[0].Episode.Title != "Episode Two" {
-		t.Fatalf("expected most recent episode first, got %s", episodesResult.EpisodeResults[0].Episode.Title)
+	t.Cleanup(func() {
+		db.Close()
+	})
+
+	deps := Dependencies{
+		HTTPClient: server.Client(),
+		ITunes:     itunes.NewClient(server.Client(), server.URL),
+		Headless:   true,
 	}
-	if episodesResult.EpisodeResults[0].PodcastTitle != "Example Podcast" {
-		t.Fatalf("expected podcast title to be included, got %s", episodesResult.EpisodeResults[0].PodcastTitle)
+	application := NewWithDependencies(ctx, cfg, filepath.Join(dir, "config.yaml"), db, deps)
+	t.Cleanup(func() {
+		application.Close()
+	})
+
+	if _, err := application.Execute(ctx, "subscribe 12345"); err != nil {
+		t.Fatalf("subscribe error = %v", err)
 	}
-	foundEp1, foundEp2 := false, false
-	for _, ep := range episodesResult.EpisodeResults {
-		if ep.Episode.Title == "Episode One" {
-			foundEp1 = true
+	listTags := func(input string) []string {
+		t.Helper()
+		result, err := application.Execute(ctx, input)
+		if err != nil {
+			t.Fatalf("%s: %v", input, err)
 		}
-		if ep.Episode.Title == "Episode Two" {
-			foundEp2 = true
+		if len(result.SearchResults) == 0 {
+			return nil
 		}
+		return result.SearchResults[0].Tags
 	}
-	if !foundEp1 || !foundEp2 {
-		t.Fatalf("episodes missing Episode One or Episode Two")
-	}
-
-	if state := episodeState(t, ctx, db, "ep1"); state != stateSeen {
-		t.Fatalf("expected ep1 state %s after viewing episodes, got %s", stateSeen, state)
-	}
-
-	usage := exec("episodes 12345")
-	if usage.Message != "Usage: episodes" {
-		t.Fatalf("expected usage message for extra args, got %q", usage.Message)
+	// The directory's genre is the first tag
+	if tags := listTags("list subscriptions"); strings.Join(tags, ",") != "News" {
+		t.Fatalf("expected genre tag, got %v", tags)
 	}
 
-	if msg := exec("queue ep1").Message; !strings.Contains(msg, "queued") {
-		t.Fatalf("queue output unexpected: %s", msg)
+	for input, want := range map[string]string{
+		"tag 12345 Tech/Gadgets": `Tagged 12345 with "Tech/Gadgets".`,
+		"tag 12345 news":         `Tagged 12345 with "news".`,
+		"tag 12345 /":            "Tag cannot be empty.",
+		"tag unknown Tech":       "No subscription found for that podcast.",
+		"tag 12345":              "Usage: tag <podcast_id> <tag>",
+	} {
+		result, err := application.Execute(ctx, input)
+		if err != nil {
+			t.Fatalf("%s: %v", input, err)
+		}
+		if result.Message != want {
+			t.Fatalf("%s: message %q, want %q", input, result.Message, want)
+		}
 	}
-	if state := episodeState(t, ctx, db, "ep1"); state != stateQueued {
-		t.Fatalf("expected ep1 state %s after queue, got %s", stateQueued, state)
+	if tags := listTags("list subscriptions"); strings.Join(tags, ",") != "News,Tech/Gadgets" {
+		t.Fatalf("expected tags kept once regardless of case, got %v", tags)
 	}
 
-	downloadMsg := exec("download ep1").Message
-	if !strings.Contains(downloadMsg, "Downloaded Episode One") {
-		t.Fatalf("download output unexpected: %s", downloadMsg)
+	if tags := listTags("list subscriptions --tag tech"); len(tags) == 0 {
+		t.Fatal("expected --tag tech to match the nested tag")
 	}
-	if state := episodeState(t, ctx, db, "ep1"); state != stateDownloaded {
-		t.Fatalf("expected ep1 state %s after download, got %s", stateDownloaded, state)
+	if tags := listTags("list subscriptions example --tag=NEWS"); len(tags) == 0 {
+		t.Fatal("expected --tag to combine with a name filter")
 	}
-	var filePath string
-	if err := db.QueryRowContext(ctx, "SELECT file_path FROM episodes WHERE id = ?", "ep1").Scan(&filePath); err != nil {
-		t.Fatalf("query file_path: %v", err)
+	result, err := application.Execute(ctx, "list subscriptions --tag gadgets")
+	if err != nil {
+		t.Fatalf("list --tag gadgets: %v", err)
 	}
-	if _, err := os.Stat(filePath); err != nil {
-		t.Fatalf("downloaded file not found: %v", err)
+	if len(result.SearchResults) != 0 {
+		t.Fatalf("expected a nested tag name alone not to match, got %d results", len(result.SearchResults))
 	}
 
-	if msg := exec("ignore ep2").Message; !strings.Contains(msg, "ignored") {
-		t.Fatalf("ignore output unexpected: %s", msg)
-	}
-	if state := episodeState(t, ctx, db, "ep2"); state != stateIgnored {
-		t.Fatalf("expected ep2 state %s after ignore, got %s", stateIgnored, state)
+	result, err = application.Execute(ctx, "untag 12345 tech/gadgets")
+	if err != nil {
+		t.Fatalf("untag: %v", err)
 	}
-
-	if msg := exec("ignore ep2").Message; !strings.Contains(msg, "unignored") {
-		t.Fatalf("second ignore output unexpected: %s", msg)
+	if result.Message != `Removed tag "tech/gadgets" from 12345.` {
+		t.Fatalf("unexpected untag message %q", result.Message)
 	}
-	if state := episodeState(t, ctx, db, "ep2"); state != stateSeen {
-		t.Fatalf("expected ep2 state %s after unignore, got %s", stateSeen, state)
+	if tags := listTags("list subscriptions"); strings.Join(tags, ",") != "News" {
+		t.Fatalf("expected tag removed, got %v", tags)
 	}
 }
 
-func TestDownloadRetriesAndResume(t *testing.T) {
+func TestPlaylistCommandRunsSavedQueries(t *testing.T) {
 	ctx := context.Background()
+	server := newMockPodcastServer(t)
+
 	dir := t.TempDir()
 	cfg := config.Defaults()
-	cfg.ParallelDownloads = 0
 	cfg.DownloadRoot = filepath.Join(dir, "downloads")
 	cfg.TmpDir = filepath.Join(dir, "tmp")
-	cfg.RetryCount = 2
-	cfg.RetryBackoffMaxSec = 2
-
-	if err := os.MkdirAll(cfg.DownloadRoot, 0o755); err != nil {
-		t.Fatalf("mkdir downloads: %v", err)
-	}
-	if err := os.MkdirAll(cfg.TmpDir, 0o755); err != nil {
-		t.Fatalf("mkdir tmp: %v", err)
+	cfg.Playlists = []config.Playlist{
+		{Name: "News", Query: "tag = news and state = new"},
+		{Name: "Tech", Query: "tag ~ tech"},
+		{Name: "Broken", Query: "tag < news"},
 	}
 
 	db, err := storage.Open(filepath.Join(dir, "app.db"))
@@ -358,338 +418,369 @@ func TestDownloadRetriesAndResume(t *testing.T) {
 		db.Close()
 	})
 
-	const (
-		podcastID   = "555"
-		episodeID   = "ep1"
-		content     = "hello world"
-		partialSize = 6
-	)
-
-	var (
-		requests      int
-		receivedRange []string
-	)
-
-	handler := func(baseURL string) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			switch r.URL.Path {
-			case "/search", "/lookup":
-				w.Header().Set("Content-Type", "application/json")
-				fmt.Fprintf(w, `{"results":[{"collectionId":%s,"collectionName":"Retry Podcast","artistName":"Retry Author","feedUrl":"%s/feed"}]}`, podcastID, baseURL)
-			case "/feed":
-				w.Header().Set("Content-Type", "application/rss+xml")
-				fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>
-<rss version="2.0">
-  <channel>
-    <title>Retry Podcast</title>
-    <item>
-      <guid>%s</guid>
-      <title>Episode One</title>
-      <enclosure url="%s/audio/%s.mp3" length="%d" type="audio/mpeg" />
-    </item>
-  </channel>
-</rss>`, episodeID, baseURL, episodeID, len(content))
-			case "/audio/" + episodeID + ".mp3":
-				requests++
-				receivedRange = append(receivedRange, r.Header.Get("Range"))
-				if requests == 1 {
-					w.WriteHeader(http.StatusInternalServerError)
-					return
-				}
-				if r.Header.Get("Range") != fmt.Sprintf("bytes=%d-", partialSize) {
-					t.Fatalf("expected range header bytes=%d-, got %q", partialSize, r.Header.Get("Range"))
-				}
-				w.Header().Set("Content-Length", fmt.Sprintf("%d", len(content)-partialSize))
-				w.WriteHeader(http.StatusPartialContent)
-				w.Write([]byte(content[partialSize:]))
-			default:
-				http.NotFound(w, r)
-			}
-		})
-	}
-
-	var server *httptest.Server
-	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		handler(server.URL).ServeHTTP(w, r)
-	}))
-	t.Cleanup(server.Close)
-
-	sleeper := &recordingSleeper{}
 	deps := Dependencies{
 		HTTPClient: server.Client(),
 		ITunes:     itunes.NewClient(server.Client(), server.URL),
-		Sleep:      sleeper.Sleep,
+		Headless:   true,
 	}
-	app := NewWithDependencies(cfg, filepath.Join(dir, "config.yaml"), db, deps)
+	application := NewWithDependencies(ctx, cfg, filepath.Join(dir, "config.yaml"), db, deps)
 	t.Cleanup(func() {
-		app.Close()
+		application.Close()
 	})
+	// Tagged News by its directory genre
+	if _, err := application.Execute(ctx, "subscribe 12345"); err != nil {
+		t.Fatalf("subscribe error = %v", err)
+	}
 
-	exec := func(command string) CommandResult {
-		result, err := app.Execute(ctx, command)
-		if err != nil {
-			t.Fatalf("Execute(%s) error = %v", command, err)
-		}
-		return result
+	result, err := application.Execute(ctx, "playlist news")
+	if err != nil {
+		t.Fatalf("playlist news: %v", err)
+	}
+	if len(result.EpisodeResults) != 2 || result.EpisodePlaylist != "News" || result.EpisodeFilter != "tag = news and state = new" {
+		t.Fatalf("unexpected playlist result %+v", result)
 	}
 
-	subscribe := func(podcast itunes.Podcast) CommandResult {
-		result, err := app.SubscribePodcast(ctx, podcast)
+	for input, want := range map[string]string{
+		"playlist Tech":    `No episodes in playlist "Tech".`,
+		"playlist missing": `No playlist named "missing".`,
+		"playlist Broken":  `Playlist "Broken": invalid query: tag does not support <`,
+	} {
+		result, err := application.Execute(ctx, input)
 		if err != nil {
-			t.Fatalf("SubscribePodcast(%s) error = %v", podcast.ID, err)
+			t.Fatalf("%s: %v", input, err)
+		}
+		if result.Message != want {
+			t.Fatalf("%s: message %q, want %q", input, result.Message, want)
 		}
-		return result
 	}
 
-	searchResult := exec("search Retry")
-	found := false
-	var targetPodcast itunes.Podcast
-	for _, sr := range searchResult.SearchResults {
-		if sr.Podcast.ID == podcastID {
-			found = true
-			targetPodcast = sr.Podcast
-			break
+	result, err = application.Execute(ctx, "playlist")
+	if err != nil {
+		t.Fatalf("playlist: %v", err)
+	}
+	if !strings.Contains(result.Message, "News: tag = news and state = new") {
+		t.Fatalf("expected the playlists to be listed, got %q", result.Message)
+	}
+}
+
+func TestExportPlaylistWritesDownloadedFiles(t *testing.T) {
+	app := newTestApp(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	for _, podcast := range [][2]string{{"pod1", "Tech Talk"}, {"pod2", "Daily News"}} {
+		if _, err := app.db.ExecContext(ctx, `INSERT INTO podcasts (id, title, feed_url, subscribed_at) VALUES (?, ?, ?, ?)`,
+			podcast[0], podcast[1], "http://example.com/"+podcast[0], time.Now().UTC()); err != nil {
+			t.Fatalf("insert podcast: %v", err)
 		}
 	}
-	if !found {
-		t.Fatalf("search results missing podcast id %s", podcastID)
+	if _, err := app.db.ExecContext(ctx, `INSERT INTO podcast_tags (podcast_id, tag) VALUES ('pod1', 'Tech/Gadgets')`); err != nil {
+		t.Fatalf("insert tag: %v", err)
 	}
-	if msg := subscribe(targetPodcast).Message; !strings.Contains(msg, "Subscribed") {
-		t.Fatalf("subscribe output unexpected: %s", msg)
+	outside := filepath.Join(t.TempDir(), "news.mp3")
+	for _, episode := range []struct {
+		id, podcast, title, state, path, published string
+		duration                                   int
+	}{
+		{"ep2", "pod1", "Second", stateDownloaded, filepath.Join(dir, "tech", "second.mp3"), "2024-02-01T00:00:00Z", 0},
+		{"ep1", "pod1", "First", statePlayed, filepath.Join(dir, "tech", "first.mp3"), "2024-01-01T00:00:00Z", 1800},
+		{"ep3", "pod2", "Headlines", stateDownloaded, outside, "2024-03-01T00:00:00Z", 600},
+		{"ep4", "pod2", "Gone", stateDownloaded, filepath.Join(dir, "missing.mp3"), "2024-03-02T00:00:00Z", 0},
+		{"ep5", "pod2", "Queued", stateQueued, "", "2024-03-03T00:00:00Z", 0},
+	} {
+		if _, err := app.db.ExecContext(ctx, `INSERT INTO episodes (id, podcast_id, title, state, enclosure_url, file_path, published_at, duration_seconds) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			episode.id, episode.podcast, episode.title, episode.state, "http://example.com/"+episode.id, episode.path, episode.published, episode.duration); err != nil {
+			t.Fatalf("insert episode: %v", err)
+		}
+		if episode.path == "" || episode.id == "ep4" {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(episode.path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(episode.path, []byte("audio"), 0o644); err != nil {
+			t.Fatal(err)
+		}
 	}
 
-	partialPath := filepath.Join(cfg.TmpDir, "podsink-"+episodeID+".partial")
-	if err := os.WriteFile(partialPath, []byte(content[:partialSize]), 0o600); err != nil {
-		t.Fatalf("write partial: %v", err)
+	playlist := filepath.Join(dir, "all.m3u8")
+	result, err := app.Execute(ctx, "export playlist "+playlist)
+	if err != nil {
+		t.Fatalf("export playlist: %v", err)
+	}
+	if result.Message != "Exported 3 episodes to "+playlist+"." {
+		t.Fatalf("unexpected message %q", result.Message)
+	}
+	data, err := os.ReadFile(playlist)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// By podcast, oldest first; files below the playlist are relative
+	want := "#EXTM3U\n" +
+		"#EXTINF:600,Daily News - Headlines\n" + outside + "\n" +
+		"#EXTINF:1800,Tech Talk - First\n" + filepath.Join("tech", "first.mp3") + "\n" +
+		"#EXTINF:-1,Tech Talk - Second\n" + filepath.Join("tech", "second.mp3") + "\n"
+	if string(data) != want {
+		t.Fatalf("playlist =\n%s\nwant\n%s", data, want)
 	}
 
-	downloadMsg := exec("download " + episodeID).Message
-	if !strings.Contains(downloadMsg, "Downloaded") {
-		t.Fatalf("download message unexpected: %s", downloadMsg)
+	for input, want := range map[string]string{
+		"export playlist " + playlist + " --tag tech":                    "Exported 2 episodes to " + playlist + ".",
+		"export playlist " + playlist + " --tag tech --state played":     "Exported 1 episodes to " + playlist + ".",
+		"export playlist " + playlist + " --podcast pod2":                "Exported 1 episodes to " + playlist + ".",
+		"export playlist " + playlist + " --podcast pod2 --state played": "No downloaded episodes to export.",
+		"export playlist " + playlist + " --state queued":                exportPlaylistUsage + "\nunknown state: queued",
+		"export playlist": exportPlaylistUsage,
+	} {
+		result, err := app.Execute(ctx, input)
+		if err != nil {
+			t.Fatalf("%s: %v", input, err)
+		}
+		if result.Message != want {
+			t.Fatalf("%s: message %q, want %q", input, result.Message, want)
+		}
 	}
+}
 
-	if requests != 2 {
-		t.Fatalf("expected 2 download attempts, got %d", requests)
+func TestExportActionsWritesGpodderEpisodeActions(t *testing.T) {
+	app := newTestApp(t)
+	ctx := context.Background()
+	app.config.GpodderDevice = "laptop"
+
+	if _, err := app.db.ExecContext(ctx, `INSERT INTO podcasts (id, title, feed_url, subscribed_at) VALUES (?, ?, ?, ?)`,
+		"pod1", "Example Podcast", "http://example.com/feed", time.Now().UTC()); err != nil {
+		t.Fatalf("insert podcast: %v", err)
 	}
-	if len(receivedRange) != 2 {
-		t.Fatalf("expected 2 recorded ranges, got %d", len(receivedRange))
+	path := filepath.Join(t.TempDir(), "actions.json")
+	result, err := app.Execute(ctx, "export actions "+path)
+	if err != nil {
+		t.Fatalf("export actions: %v", err)
 	}
-	if receivedRange[0] != fmt.Sprintf("bytes=%d-", partialSize) {
-		t.Fatalf("first range header unexpected: %q", receivedRange[0])
+	if result.Message != "No downloaded or played episodes to export." {
+		t.Fatalf("unexpected message %q", result.Message)
 	}
 
-	if len(sleeper.calls) != 1 {
-		t.Fatalf("expected one backoff call, got %d", len(sleeper.calls))
+	for _, episode := range []struct{ id, state, downloadedAt string }{
+		{"ep1", stateDownloaded, "2024-01-02T10:00:00Z"},
+		{"ep2", stateDownloaded, "2024-01-01T09:30:00Z"},
+		{"ep3", stateNew, ""},
+	} {
+		if _, err := app.db.ExecContext(ctx, `INSERT INTO episodes (id, podcast_id, title, state, enclosure_url, downloaded_at, duration_seconds) VALUES (?, ?, ?, ?, ?, NULLIF(?, ''), 1200)`,
+			episode.id, "pod1", episode.id, episode.state, "http://example.com/"+episode.id+".mp3", episode.downloadedAt); err != nil {
+			t.Fatalf("insert episode: %v", err)
+		}
 	}
-	if sleeper.calls[0] != time.Second {
-		t.Fatalf("expected 1s backoff, got %v", sleeper.calls[0])
+	if _, err := app.Execute(ctx, "mark played ep1"); err != nil {
+		t.Fatalf("mark played: %v", err)
 	}
 
-	var filePath string
-	if err := db.QueryRowContext(ctx, "SELECT file_path FROM episodes WHERE id = ?", episodeID).Scan(&filePath); err != nil {
-		t.Fatalf("query file_path: %v", err)
+	result, err = app.Execute(ctx, "export actions "+path)
+	if err != nil {
+		t.Fatalf("export actions: %v", err)
 	}
-	data, err := os.ReadFile(filePath)
+	if result.Message != "Exported 3 episode actions to "+path+"." {
+		t.Fatalf("unexpected message %q", result.Message)
+	}
+	data, err := os.ReadFile(path)
 	if err != nil {
-		t.Fatalf("read downloaded file: %v", err)
+		t.Fatal(err)
 	}
-	if string(data) != content {
-		t.Fatalf("downloaded file mismatch: got %q", string(data))
+	var actions []gpodder.EpisodeAction
+	if err := json.Unmarshal(data, &actions); err != nil {
+		t.Fatalf("decode %s: %v", data, err)
 	}
-
-	var retryCount int
-	if err := db.QueryRowContext(ctx, "SELECT retry_count FROM episodes WHERE id = ?", episodeID).Scan(&retryCount); err != nil {
-		t.Fatalf("query retry_count: %v", err)
+	if len(actions) != 3 {
+		t.Fatalf("expected 3 actions, got %+v", actions)
 	}
-	if retryCount != 0 {
-		t.Fatalf("expected retry_count reset to 0, got %d", retryCount)
+	// Oldest first, the play after the downloads
+	want := []gpodder.EpisodeAction{
+		{Podcast: "http://example.com/feed", Episode: "http://example.com/ep2.mp3", Device: "laptop", Action: "download", Timestamp: "2024-01-01T09:30:00"},
+		{Podcast: "http://example.com/feed", Episode: "http://example.com/ep1.mp3", Device: "laptop", Action: "download", Timestamp: "2024-01-02T10:00:00"},
+		{Podcast: "http://example.com/feed", Episode: "http://example.com/ep1.mp3", Device: "laptop", Action: "play", Position: 1200, Total: 1200},
 	}
-
-	if _, err := os.Stat(partialPath); !os.IsNotExist(err) {
-		t.Fatalf("partial file should be removed, stat err=%v", err)
+	for i, action := range actions {
+		if i == 2 {
+			if action.Timestamp == "" {
+				t.Fatalf("play action lacks a timestamp: %+v", action)
+			}
+			action.Timestamp = ""
+		}
+		if action != want[i] {
+			t.Fatalf("action %d = %+v, want %+v", i, action, want[i])
+		}
 	}
 }
 
-func TestDownloadQueueProcessesInParallel(t *testing.T) {
+func TestImportStateFromMergesAnotherDatabase(t *testing.T) {
+	app := newTestApp(t)
 	ctx := context.Background()
-	dir := t.TempDir()
-	cfg := config.Defaults()
-	cfg.DownloadRoot = filepath.Join(dir, "downloads")
-	cfg.TmpDir = filepath.Join(dir, "tmp")
-	cfg.ParallelDownloads = 2
-
-	if err := os.MkdirAll(cfg.DownloadRoot, 0o755); err != nil {
-		t.Fatalf("mkdir downloads: %v", err)
-	}
-	if err := os.MkdirAll(cfg.TmpDir, 0o755); err != nil {
-		t.Fatalf("mkdir tmp: %v", err)
-	}
 
-	db, err := storage.Open(filepath.Join(dir, "app.db"))
+	otherPath := filepath.Join(t.TempDir(), "other.db")
+	other, err := storage.Open(otherPath)
 	if err != nil {
 		t.Fatalf("storage.Open() error = %v", err)
 	}
-	t.Cleanup(func() { db.Close() })
-
-	const podcastID = "777"
-
-	startCh := make(chan string, 4)
-	releaseCh := make(chan struct{}, 4)
-	var (
-		mu        sync.Mutex
-		active    int
-		maxActive int
-	)
-
-	handler := func(baseURL string) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			switch r.URL.Path {
-			case "/search", "/lookup":
-				w.Header().Set("Content-Type", "application/json")
-				fmt.Fprintf(w, `{"results":[{"collectionId":%s,"collectionName":"Parallel Podcast","artistName":"Parallel Author","feedUrl":"%s/feed"}]}`, podcastID, baseURL)
-			case "/feed":
-				w.Header().Set("Content-Type", "application/rss+xml")
-				fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>
-<rss version="2.0">
-  <channel>
-    <title>Parallel Podcast</title>
-    <item>
-      <guid>ep1</guid>
-      <title>Episode One</title>
-      <enclosure url="%s/audio/ep1.mp3" length="100" type="audio/mpeg" />
-    </item>
-    <item>
-      <guid>ep2</guid>
-      <title>Episode Two</title>
-      <enclosure url="%s/audio/ep2.mp3" length="100" type="audio/mpeg" />
-    </item>
-  </channel>
-</rss>`, baseURL, baseURL)
-			case "/audio/ep1.mp3", "/audio/ep2.mp3":
-				mu.Lock()
-				active++
-				if active > maxActive {
-					maxActive = active
-				}
-				mu.Unlock()
-				startCh <- r.URL.Path
-				<-releaseCh
-				w.WriteHeader(http.StatusOK)
-				fmt.Fprintf(w, "data-%s", strings.TrimPrefix(r.URL.Path, "/audio/"))
-				mu.Lock()
-				active--
-				mu.Unlock()
-			default:
-				http.NotFound(w, r)
-			}
-		})
+	for _, db := range []*sql.DB{app.db, other} {
+		if _, err := db.ExecContext(ctx, `INSERT INTO podcasts (id, title, feed_url, subscribed_at, podcast_guid) VALUES (?, ?, ?, ?, ?)`,
+			"pod1", "Example Podcast", "http://example.com/feed", time.Now().UTC(), nil); err != nil {
+			t.Fatalf("insert podcast: %v", err)
+		}
 	}
-
-	var server *httptest.Server
-	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		handler(server.URL).ServeHTTP(w, r)
-	}))
-	t.Cleanup(server.Close)
-
-	deps := Dependencies{
-		HTTPClient: server.Client(),
-		ITunes:     itunes.NewClient(server.Client(), server.URL),
+	// The same show under another ID and feed URL, known by its guid
+	if _, err := app.db.ExecContext(ctx, `INSERT INTO podcasts (id, title, feed_url, subscribed_at, podcast_guid) VALUES ('pod2', 'Moved', 'http://new.example.com/feed', ?, 'abc')`, time.Now().UTC()); err != nil {
+		t.Fatalf("insert podcast: %v", err)
+	}
+	if _, err := other.ExecContext(ctx, `INSERT INTO podcasts (id, title, feed_url, subscribed_at, podcast_guid) VALUES ('old2', 'Moved', 'http://old.example.com/feed', ?, 'ABC')`, time.Now().UTC()); err != nil {
+		t.Fatalf("insert podcast: %v", err)
 	}
-	app := NewWithDependencies(cfg, filepath.Join(dir, "config.yaml"), db, deps)
-	t.Cleanup(func() {
-		app.Close()
-	})
 
-	exec := func(command string) CommandResult {
-		result, err := app.Execute(ctx, command)
-		if err != nil {
-			t.Fatalf("Execute(%s) error = %v", command, err)
+	insert := func(db *sql.DB, id, podcastID, state, enclosure string, position int) {
+		t.Helper()
+		if _, err := db.ExecContext(ctx, `INSERT INTO episodes (id, podcast_id, title, state, enclosure_url, position_seconds, duration_seconds) VALUES (?, ?, ?, ?, ?, ?, 1800)`,
+			id, podcastID, id, state, enclosure, position); err != nil {
+			t.Fatalf("insert episode: %v", err)
 		}
-		return result
 	}
+	for _, ep := range []struct {
+		id, state string
+		position  int
+	}{
+		{"played", stateNew, 0},
+		{"queued", stateQueued, 0},
+		{"ignored", stateSeen, 0},
+		{"seen", stateNew, 0},
+		{"partial", stateDownloaded, 300},
+	} {
+		insert(app.db, ep.id, "pod1", ep.state, "http://example.com/"+ep.id+".mp3", ep.position)
+	}
+	insert(app.db, "moved-local-id", "pod2", stateNew, "http://cdn.example.com/moved.mp3", 0)
 
-	subscribe := func(podcast itunes.Podcast) CommandResult {
-		result, err := app.SubscribePodcast(ctx, podcast)
-		if err != nil {
-			t.Fatalf("SubscribePodcast(%s) error = %v", podcast.ID, err)
-		}
-		return result
+	for _, ep := range []struct {
+		id, state string
+		position  int
+	}{
+		{"played", statePlayed, 1800},
+		{"queued", statePlayed, 1800},
+		{"ignored", stateIgnored, 0},
+		{"seen", stateDownloaded, 0},
+		{"partial", stateDeleted, 900},
+		{"elsewhere", statePlayed, 1800},
+	} {
+		insert(other, ep.id, "pod1", ep.state, "http://example.com/"+ep.id+".mp3", ep.position)
 	}
+	insert(other, "moved-remote-id", "old2", stateIgnored, "http://cdn.example.com/moved.mp3", 0)
+	if _, err := other.ExecContext(ctx, `UPDATE episodes SET played_at = '2024-05-01T12:00:00Z' WHERE id = 'played'`); err != nil {
+		t.Fatal(err)
+	}
+	other.Close()
 
-	searchResult := exec("search Parallel")
-	found := false
-	var targetPodcast itunes.Podcast
-	for _, sr := range searchResult.SearchResults {
-		if sr.Podcast.ID == podcastID {
-			found = true
-			targetPodcast = sr.Podcast
-			break
+	result, err := app.Execute(ctx, "import state-from "+otherPath)
+	if err != nil {
+		t.Fatalf("import state-from: %v", err)
+	}
+	if want := "Merged 6 episodes: 1 played, 2 ignored, 1 seen, 1 positions moved forward; 1 not in this library."; result.Message != want {
+		t.Fatalf("message %q, want %q", result.Message, want)
+	}
+	for id, want := range map[string]string{
+		"played":         statePlayed,
+		"queued":         stateQueued,
+		"ignored":        stateIgnored,
+		"seen":           stateSeen,
+		"partial":        stateDownloaded,
+		"moved-local-id": stateIgnored,
+	} {
+		if state := episodeState(t, ctx, app.db, id); state != want {
+			t.Fatalf("%s: state %s, want %s", id, state, want)
 		}
 	}
-	if !found {
-		t.Fatalf("search results missing podcast id %s", podcastID)
+	var position int
+	var playedAt string
+	if err := app.db.QueryRowContext(ctx, `SELECT position_seconds FROM episodes WHERE id = 'partial'`).Scan(&position); err != nil || position != 900 {
+		t.Fatalf("expected the later position, got %d, %v", position, err)
 	}
-	if msg := subscribe(targetPodcast).Message; !strings.Contains(msg, "Subscribed") {
-		t.Fatalf("subscribe output unexpected: %s", msg)
+	if err := app.db.QueryRowContext(ctx, `SELECT played_at FROM episodes WHERE id = 'played'`).Scan(&playedAt); err != nil || playedAt != "2024-05-01T12:00:00Z" {
+		t.Fatalf("expected the play time to be kept, got %q, %v", playedAt, err)
 	}
 
-	if msg := exec("queue ep1").Message; !strings.Contains(msg, "queued") {
-		t.Fatalf("queue ep1 unexpected: %s", msg)
+	// Merging again changes nothing
+	result, err = app.Execute(ctx, "import state-from "+otherPath)
+	if err != nil {
+		t.Fatalf("import state-from: %v", err)
 	}
-	if msg := exec("queue ep2").Message; !strings.Contains(msg, "queued") {
-		t.Fatalf("queue ep2 unexpected: %s", msg)
+	if want := "Merged 6 episodes: 0 played, 0 ignored, 0 seen, 0 positions moved forward; 1 not in this library."; result.Message != want {
+		t.Fatalf("message %q, want %q", result.Message, want)
 	}
+}
 
-	seen := make(map[string]struct{})
-	deadline := time.After(3 * time.Second)
-	for len(seen) < 2 {
-		select {
-		case path := <-startCh:
-			seen[path] = struct{}{}
-		case <-deadline:
-			t.Fatalf("timeout waiting for parallel downloads, saw %d", len(seen))
+func TestListSubscriptionsShowsDiskUsage(t *testing.T) {
+	app := newTestApp(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	for _, podcast := range [][2]string{{"pod1", "Alpha"}, {"pod2", "Beta"}, {"pod3", "Gamma"}} {
+		if _, err := app.db.ExecContext(ctx, `INSERT INTO podcasts (id, title, feed_url, subscribed_at) VALUES (?, ?, ?, ?)`,
+			podcast[0], podcast[1], "http://example.com/"+podcast[0], time.Now().UTC()); err != nil {
+			t.Fatalf("insert podcast: %v", err)
 		}
 	}
-
-	releaseCh <- struct{}{}
-	releaseCh <- struct{}{}
-
-	waitDeadline := time.Now().Add(5 * time.Second)
-	for time.Now().Before(waitDeadline) {
-		if episodeState(t, ctx, db, "ep1") == stateDownloaded && episodeState(t, ctx, db, "ep2") == stateDownloaded {
-			break
+	for _, episode := range []struct {
+		id, podcast, state string
+		size               int
+	}{
+		{"a1", "pod1", stateDownloaded, 100},
+		{"b1", "pod2", stateDownloaded, 300},
+		{"b2", "pod2", statePlayed, 200},
+		{"b3", "pod2", stateDeleted, 0}, // File gone
+		{"b4", "pod2", stateDownloaded, -1},
+	} {
+		path := filepath.Join(dir, episode.id+".mp3")
+		if episode.size > 0 {
+			if err := os.WriteFile(path, make([]byte, episode.size), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err := app.db.ExecContext(ctx, `INSERT INTO episodes (id, podcast_id, title, state, enclosure_url, file_path, size_bytes) VALUES (?, ?, ?, ?, ?, ?, 5000)`,
+			episode.id, episode.podcast, episode.id, episode.state, "http://example.com/"+episode.id, path); err != nil {
+			t.Fatalf("insert episode: %v", err)
 		}
-		time.Sleep(25 * time.Millisecond)
 	}
 
-	if episodeState(t, ctx, db, "ep1") != stateDownloaded || episodeState(t, ctx, db, "ep2") != stateDownloaded {
-		t.Fatalf("episodes not downloaded in time: ep1=%s ep2=%s", episodeState(t, ctx, db, "ep1"), episodeState(t, ctx, db, "ep2"))
+	usage := func(input string) string {
+		t.Helper()
+		result, err := app.Execute(ctx, input)
+		if err != nil {
+			t.Fatalf("%s: %v", input, err)
+		}
+		var parts []string
+		for _, r := range result.SearchResults {
+			parts = append(parts, fmt.Sprintf("%s=%d", r.Podcast.ID, r.DiskBytes))
+		}
+		return strings.Join(parts, " ")
 	}
-
-	mu.Lock()
-	observed := maxActive
-	mu.Unlock()
-	if observed < 2 {
-		t.Fatalf("expected at least 2 concurrent downloads, saw %d", observed)
+	// Only files on disk count, whatever size the feed reported
+	if got := usage("list subscriptions"); got != "pod1=100 pod2=500 pod3=0" {
+		t.Fatalf("unexpected usage by title %s", got)
 	}
-
-	for _, id := range []string{"ep1", "ep2"} {
-		var filePath string
-		if err := db.QueryRowContext(ctx, "SELECT file_path FROM episodes WHERE id = ?", id).Scan(&filePath); err != nil {
-			t.Fatalf("query file path for %s: %v", id, err)
-		}
-		if _, err := os.Stat(filePath); err != nil {
-			t.Fatalf("downloaded file missing for %s: %v", id, err)
+	for _, input := range []string{"list subscriptions --sort size", "list subscriptions --sort=SIZE"} {
+		if got := usage(input); got != "pod2=500 pod1=100 pod3=0" {
+			t.Fatalf("%s: unexpected order %s", input, got)
 		}
 	}
+	if got := usage("list subscriptions a --sort size"); got != "pod2=500 pod1=100 pod3=0" {
+		t.Fatalf("expected the filter to apply with the order, got %s", got)
+	}
+	result, err := app.Execute(ctx, "list subscriptions --sort newest")
+	if err != nil || result.Message != "Usage: "+listUsage {
+		t.Fatalf("expected usage for an unknown order, got %q, %v", result.Message, err)
+	}
 }
 
-func newTestApp(t *testing.T) *App {
-	t.Helper()
+func TestSubscribeCommandByIDAndFeedURL(t *testing.T) {
+	ctx := context.Background()
+	server := newMockPodcastServer(t)
 
 	dir := t.TempDir()
 	cfg := config.Defaults()
-	cfg.ParallelDownloads = 0
 	cfg.DownloadRoot = filepath.Join(dir, "downloads")
 	cfg.TmpDir = filepath.Join(dir, "tmp")
 
@@ -701,79 +792,3881 @@ func newTestApp(t *testing.T) *App {
 		db.Close()
 	})
 
+	deps := Dependencies{
+		HTTPClient: server.Client(),
+		ITunes:     itunes.NewClient(server.Client(), server.URL),
+		Headless:   true,
+	}
+	application := NewWithDependencies(ctx, cfg, filepath.Join(dir, "config.yaml"), db, deps)
+	t.Cleanup(func() {
+		application.Close()
+	})
+	if workers := application.DownloadWorkers(); workers != 0 {
+		t.Fatalf("expected no download workers in headless mode, got %d", workers)
+	}
+
+	result, err := application.Execute(ctx, "subscribe 12345")
+	if err != nil {
+		t.Fatalf("subscribe by ID error = %v", err)
+	}
+	if result.Message != "Subscribed to Example Podcast (2 new episodes)." {
+		t.Fatalf("unexpected subscribe message: %q", result.Message)
+	}
+
+	result, err = application.Execute(ctx, "subscribe "+server.URL+"/feed")
+	if err != nil {
+		t.Fatalf("subscribe by URL error = %v", err)
+	}
+	if !strings.HasPrefix(result.Message, "Already subscribed to ") {
+		t.Fatalf("expected already subscribed message, got %q", result.Message)
+	}
+
+	result, err = application.Execute(ctx, "unsubscribe 12345")
+	if err != nil {
+		t.Fatalf("unsubscribe error = %v", err)
+	}
+	if result.Message != "Subscription removed." {
+		t.Fatalf("unexpected unsubscribe message: %q", result.Message)
+	}
+
+	result, err = application.Execute(ctx, "subscribe "+server.URL+"/feed")
+	if err != nil {
+		t.Fatalf("subscribe by URL error = %v", err)
+	}
+	if !strings.HasPrefix(result.Message, "Subscribed to Example Podcast ") {
+		t.Fatalf("unexpected subscribe message: %q", result.Message)
+	}
+
+	result, err = application.Execute(ctx, "subscribe")
+	if err != nil {
+		t.Fatalf("subscribe without args error = %v", err)
+	}
+	if result.Message != "Usage: subscribe <podcast_id | feed_url>" {
+		t.Fatalf("unexpected usage message: %q", result.Message)
+	}
+
+	result, err = application.Execute(ctx, "history example")
+	if err != nil {
+		t.Fatalf("history error = %v", err)
+	}
+	history := result.SubscriptionHistory
+	if len(history) != 3 {
+		t.Fatalf("expected 3 history entries, got %+v", history)
+	}
+	wantHistory := []struct{ id, action, source string }{
+		{"", domain.SubscriptionAdded, domain.SourceFeedURL},
+		{"12345", domain.SubscriptionRemoved, domain.SourceUser},
+		{"12345", domain.SubscriptionAdded, domain.SourceDirectory},
+	}
+	for i, want := range wantHistory {
+		got := history[i]
+		if (want.id != "" && got.PodcastID != want.id) || got.Action != want.action || got.Source != want.source {
+			t.Fatalf("history[%d] = %+v, want %s %s via %s", i, got, want.id, want.action, want.source)
+		}
+		if got.Title != "Example Podcast" || got.At.IsZero() {
+			t.Fatalf("history[%d] misses title or time: %+v", i, got)
+		}
+	}
+
+	result, err = application.Execute(ctx, "history nothing-like-this")
+	if err != nil {
+		t.Fatalf("history error = %v", err)
+	}
+	if result.SubscriptionHistory == nil || len(result.SubscriptionHistory) != 0 || !strings.HasPrefix(result.Message, "No subscription changes match") {
+		t.Fatalf("unexpected empty history result: %+v", result)
+	}
+}
+
+func TestCommandResultJSON(t *testing.T) {
+	published := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	result := CommandResult{
+		Message:       "2 results\n",
+		SearchContext: "subscriptions",
+		SearchResults: []SearchResult{{Podcast: itunes.Podcast{ID: "p1", Title: "Show"}, IsSubscribed: true, NewCount: 2, TotalCount: 5}},
+		EpisodeResults: []domain.EpisodeResult{
+			{Episode: domain.EpisodeRow{ID: "e1", Title: "One", State: stateDownloaded, PublishedAt: published, HasPublish: true, SizeBytes: 42}, PodcastID: "p1", PodcastTitle: "Show"},
+			{Episode: domain.EpisodeRow{ID: "e2", Title: "Two", State: "NEW"}, PodcastID: "p1", PodcastTitle: "Show"},
+		},
+		QueuedEpisodeResults: []domain.QueuedEpisodeResult{
+			{Episode: domain.EpisodeRow{ID: "e3", Title: "Three", State: stateQueued}, PodcastID: "p1", RetryCount: 1, Paused: true},
+		},
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	var decoded map[string]any
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+	if decoded["message"] != "2 results" {
+		t.Fatalf("unexpected message %v", decoded["message"])
+	}
+	if _, ok := decoded["podcasts"]; ok {
+		t.Fatalf("subscription listing should not be reported as podcasts: %s", data)
+	}
+	subs := decoded["subscriptions"].([]any)
+	if sub := subs[0].(map[string]any); sub["id"] != "p1" || sub["subscribed"] != true || sub["new_count"] != float64(2) {
+		t.Fatalf("unexpected subscription %v", sub)
+	}
+	episodes := decoded["episodes"].([]any)
+	first, second := episodes[0].(map[string]any), episodes[1].(map[string]any)
+	if first["published_at"] != "2024-03-01T12:00:00Z" || first["size_bytes"] != float64(42) || first["podcast_title"] != "Show" {
+		t.Fatalf("unexpected episode %v", first)
+	}
+	if _, ok := second["published_at"]; ok {
+		t.Fatalf("episode without a date should omit published_at: %v", second)
+	}
+	queued := decoded["queue"].([]any)[0].(map[string]any)
+	if queued["status"] != "paused" || queued["retry_count"] != float64(1) {
+		t.Fatalf("unexpected queue entry %v", queued)
+	}
+}
+
+func TestExitCommandSetsQuit(t *testing.T) {
+	app := newTestApp(t)
+
+	result, err := app.Execute(context.Background(), "quit")
+	if err != nil {
+		t.Fatalf("Execute(quit) error = %v", err)
+	}
+
+	if !result.Quit {
+		t.Fatal("expected quit result")
+	}
+}
+
+func TestListCommandUsage(t *testing.T) {
+	app := newTestApp(t)
+
+	result, err := app.Execute(context.Background(), "list")
+	if err != nil {
+		t.Fatalf("Execute(list) error = %v", err)
+	}
+
+	if !strings.Contains(result.Message, "Usage: list subscriptions") {
+		t.Fatalf("unexpected list response: %s", result.Message)
+	}
+}
+
+func TestConfigShowRendersYaml(t *testing.T) {
+	app := newTestApp(t)
+
+	result, err := app.Execute(context.Background(), "config show")
+	if err != nil {
+		t.Fatalf("Execute(config show) error = %v", err)
+	}
+
+	if !strings.Contains(result.Message, "download_root:") {
+		t.Fatalf("expected download_root in config output: %s", result.Message)
+	}
+}
+
+func TestPodcastLifecycle(t *testing.T) {
+	ctx := context.Background()
+	server := newMockPodcastServer(t)
+
+	dir := t.TempDir()
+	cfg := config.Defaults()
+	cfg.ParallelDownloads = 0
+	cfg.DownloadRoot = filepath.Join(dir, "downloads")
+	cfg.TmpDir = filepath.Join(dir, "tmp")
+
 	if err := os.MkdirAll(cfg.DownloadRoot, 0o755); err != nil {
 		t.Fatalf("mkdir downloads: %v", err)
 	}
-	if err := os.MkdirAll(cfg.TmpDir, 0o755); err != nil {
-		t.Fatalf("mkdir tmp: %v", err)
+
+	db, err := storage.Open(filepath.Join(dir, "app.db"))
+	if err != nil {
+		t.Fatalf("storage.Open() error = %v", err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+	})
+
+	httpClient := server.Client()
+	deps := Dependencies{
+		HTTPClient: httpClient,
+		ITunes:     itunes.NewClient(httpClient, server.URL),
 	}
 
-	app := New(cfg, filepath.Join(dir, "config.yaml"), db)
+	application := NewWithDependencies(context.Background(), cfg, filepath.Join(dir, "config.yaml"), db, deps)
 	t.Cleanup(func() {
-		app.Close()
+		application.Close()
 	})
-	return app
-}
 
-func newMockPodcastServer(t *testing.T) *httptest.Server {
-	t.Helper()
+	exec := func(command string) CommandResult {
+		result, err := application.Execute(ctx, command)
+		if err != nil {
+			t.Fatalf("Execute(%s) error = %v", command, err)
+		}
+		return result
+	}
 
-	const podcastID = "12345"
-	handler := func(serverURL string) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			switch r.URL.Path {
-			case "/search":
-				w.Header().Set("Content-Type", "application/json")
-				fmt.Fprintf(w, `{"results":[{"collectionId":%s,"collectionName":"Example Podcast","artistName":"Example Author","feedUrl":"%s/feed"}]}`, podcastID, serverURL)
-			case "/lookup":
-				w.Header().Set("Content-Type", "application/json")
-				fmt.Fprintf(w, `{"results":[{"collectionId":%s,"collectionName":"Example Podcast","artistName":"Example Author","feedUrl":"%s/feed"}]}`, podcastID, serverURL)
-			case "/feed":
-				w.Header().Set("Content-Type", "application/rss+xml")
-				fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>
-<rss version="2.0">
-  <channel>
-    <title>Example Podcast</title>
-    <description>Example description</description>
-    <item>
-      <guid>ep1</guid>
-      <title>Episode One</title>
-      <pubDate>Mon, 02 Jan 2006 15:04:05 -0700</pubDate>
-      <enclosure url="%s/audio/ep1.mp3" length="100" type="audio/mpeg" />
-    </item>
-    <item>
-      <guid>ep2</guid>
-      <title>Episode Two</title>
-      <pubDate>Tue, 03 Jan 2006 15:04:05 -0700</pubDate>
-      <enclosure url="%s/audio/ep2.mp3" length="100" type="audio/mpeg" />
-    </item>
-  </channel>
-</rss>`, serverURL, serverURL)
-			case "/audio/ep1.mp3":
-				w.WriteHeader(http.StatusOK)
-				w.Write([]byte("episode-one"))
-			case "/audio/ep2.mp3":
-				w.WriteHeader(http.StatusOK)
-				w.Write([]byte("episode-two"))
-			default:
-				http.NotFound(w, r)
-			}
-		})
+	subscribe := func(podcast itunes.Podcast) CommandResult {
+		result, err := application.SubscribePodcast(ctx, podcast)
+		if err != nil {
+			t.Fatalf("SubscribePodcast(%s) error = %v", podcast.ID, err)
+		}
+		return result
 	}
 
-	var srv *httptest.Server
-	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		handler(srv.URL).ServeHTTP(w, r)
-	}))
-	t.Cleanup(srv.Close)
-	return srv
-}
+	searchResult := exec("search Example")
+	found := false
+	var targetPodcast itunes.Podcast
+	for _, sr := range searchResult.SearchResults {
+		if sr.Podcast.ID == "12345" {
+			found = true
+			targetPodcast = sr.Podcast
+			break
+		}
+	}
+	if !found {
+		t.Fatalf("search results missing podcast id 12345")
+	}
 
-func episodeState(t *testing.T, ctx context.Context, db *sql.DB, episodeID string) string {
-	t.Helper()
-	var state string
-	if err := db.QueryRowContext(ctx, "SELECT state FROM episodes WHERE id = ?", episodeID).Scan(&state); err != nil {
-		t.Fatalf("query episode state: %v", err)
+	if msg := subscribe(targetPodcast).Message; !strings.Contains(msg, "Subscribed to Example Podcast") {
+		t.Fatalf("subscribe output unexpected: %s", msg)
+	}
+
+	var count int
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM episodes").Scan(&count); err != nil {
+		t.Fatalf("query episodes count: %v", err)
+	}
+	if count != 2 {
+		t.Fatalf("expected 2 episodes, got %d", count)
+	}
+
+	listResult := exec("list subscriptions")
+	if len(listResult.SearchResults) == 0 {
+		t.Fatal("list subscriptions should return search results")
+	}
+	listFound := false
+	for _, sr := range listResult.SearchResults {
+		if sr.Podcast.Title == "Example Podcast" {
+			listFound = true
+			break
+		}
+	}
+	if !listFound {
+		t.Fatalf("list output missing subscription: %+v", listResult.SearchResults)
+	}
+
+	episodesResult := exec("episodes")
+	if len(episodesResult.EpisodeResults) != 2 {
+		t.Fatalf("expected 2 episodes, got %d", len(episodesResult.EpisodeResults))
+	}
+	if episodesResult.EpisodeResults[0].Episode.Title != "Episode Two" {
+		t.Fatalf("expected most recent episode first, got %s", episodesResult.EpisodeResults[0].Episode.Title)
+	}
+	if episodesResult.EpisodeResults[0].PodcastTitle != "Example Podcast" {
+		t.Fatalf("expected podcast title to be included, got %s", episodesResult.EpisodeResults[0].PodcastTitle)
+	}
+	foundEp1, foundEp2 := false, false
+	for _, ep := range episodesResult.EpisodeResults {
+		if ep.Episode.Title == "Episode One" {
+			foundEp1 = true
+		}
+		if ep.Episode.Title == "Episode Two" {
+			foundEp2 = true
+		}
+	}
+	if !foundEp1 || !foundEp2 {
+		t.Fatalf("episodes missing Episode One or Episode Two")
+	}
+
+	if state := episodeState(t, ctx, db, "ep1"); state != stateNew {
+		t.Fatalf("expected listing to leave ep1 %s, got %s", stateNew, state)
+	}
+	if detail, err := application.EpisodeDetails(ctx, "ep1"); err != nil || detail.State != stateSeen {
+		t.Fatalf("expected opening the details to mark ep1 seen, got %q, %v", detail.State, err)
+	}
+	if state := episodeState(t, ctx, db, "ep1"); state != stateSeen {
+		t.Fatalf("expected ep1 state %s after viewing its details, got %s", stateSeen, state)
+	}
+
+	podcastEpisodes := exec("episodes 12345")
+	if len(podcastEpisodes.EpisodeResults) != 2 || podcastEpisodes.EpisodePodcast != "12345" || podcastEpisodes.EpisodeQuery != "12345" {
+		t.Fatalf("expected the podcast's episodes, got %+v", podcastEpisodes)
+	}
+	if msg := exec("episodes 99999").Message; msg != "No subscription found for that podcast." {
+		t.Fatalf("expected an unknown podcast to be reported, got %q", msg)
+	}
+	usage := exec("episodes 12345 67890")
+	if !strings.HasPrefix(usage.Message, "Usage: episodes") {
+		t.Fatalf("expected usage message for extra args, got %q", usage.Message)
+	}
+
+	if msg := exec("queue ep1").Message; !strings.Contains(msg, "queued") {
+		t.Fatalf("queue output unexpected: %s", msg)
+	}
+	if state := episodeState(t, ctx, db, "ep1"); state != stateQueued {
+		t.Fatalf("expected ep1 state %s after queue, got %s", stateQueued, state)
+	}
+
+	downloadMsg := exec("download ep1").Message
+	if !strings.Contains(downloadMsg, "Downloaded Episode One") {
+		t.Fatalf("download output unexpected: %s", downloadMsg)
+	}
+	if state := episodeState(t, ctx, db, "ep1"); state != stateDownloaded {
+		t.Fatalf("expected ep1 state %s after download, got %s", stateDownloaded, state)
+	}
+	var filePath string
+	if err := db.QueryRowContext(ctx, "SELECT file_path FROM episodes WHERE id = ?", "ep1").Scan(&filePath); err != nil {
+		t.Fatalf("query file_path: %v", err)
+	}
+	if _, err := os.Stat(filePath); err != nil {
+		t.Fatalf("downloaded file not found: %v", err)
+	}
+
+	if msg := exec("ignore ep2").Message; !strings.Contains(msg, "ignored") {
+		t.Fatalf("ignore output unexpected: %s", msg)
+	}
+	if state := episodeState(t, ctx, db, "ep2"); state != stateIgnored {
+		t.Fatalf("expected ep2 state %s after ignore, got %s", stateIgnored, state)
+	}
+
+	if msg := exec("ignore ep2").Message; !strings.Contains(msg, "unignored") {
+		t.Fatalf("second ignore output unexpected: %s", msg)
+	}
+	if state := episodeState(t, ctx, db, "ep2"); state != stateSeen {
+		t.Fatalf("expected ep2 state %s after unignore, got %s", stateSeen, state)
+	}
+}
+
+func TestDownloadRetriesAndResume(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	cfg := config.Defaults()
+	cfg.ParallelDownloads = 0
+	cfg.DownloadRoot = filepath.Join(dir, "downloads")
+	cfg.TmpDir = filepath.Join(dir, "tmp")
+	cfg.RetryCount = 2
+	cfg.RetryBackoffMaxSec = 2
+
+	if err := os.MkdirAll(cfg.DownloadRoot, 0o755); err != nil {
+		t.Fatalf("mkdir downloads: %v", err)
+	}
+	if err := os.MkdirAll(cfg.TmpDir, 0o755); err != nil {
+		t.Fatalf("mkdir tmp: %v", err)
+	}
+
+	db, err := storage.Open(filepath.Join(dir, "app.db"))
+	if err != nil {
+		t.Fatalf("storage.Open() error = %v", err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+	})
+
+	const (
+		podcastID   = "555"
+		episodeID   = "ep1"
+		content     = "hello world"
+		partialSize = 6
+	)
+
+	var (
+		requests      int
+		receivedRange []string
+	)
+
+	handler := func(baseURL string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/search", "/lookup":
+				w.Header().Set("Content-Type", "application/json")
+				fmt.Fprintf(w, `{"results":[{"collectionId":%s,"collectionName":"Retry Podcast","artistName":"Retry Author","feedUrl":"%s/feed"}]}`, podcastID, baseURL)
+			case "/feed":
+				w.Header().Set("Content-Type", "application/rss+xml")
+				fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0">
+  <channel>
+    <title>Retry Podcast</title>
+    <item>
+      <guid>%s</guid>
+      <title>Episode One</title>
+      <enclosure url="%s/audio/%s.mp3" length="%d" type="audio/mpeg" />
+    </item>
+  </channel>
+</rss>`, episodeID, baseURL, episodeID, len(content))
+			case "/audio/" + episodeID + ".mp3":
+				requests++
+				receivedRange = append(receivedRange, r.Header.Get("Range"))
+				if requests == 1 {
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+				if r.Header.Get("Range") != fmt.Sprintf("bytes=%d-", partialSize) {
+					t.Fatalf("expected range header bytes=%d-, got %q", partialSize, r.Header.Get("Range"))
+				}
+				w.Header().Set("Content-Length", fmt.Sprintf("%d", len(content)-partialSize))
+				w.WriteHeader(http.StatusPartialContent)
+				w.Write([]byte(content[partialSize:]))
+			default:
+				http.NotFound(w, r)
+			}
+		})
+	}
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler(server.URL).ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	sleeper := &recordingSleeper{}
+	deps := Dependencies{
+		HTTPClient: server.Client(),
+		ITunes:     itunes.NewClient(server.Client(), server.URL),
+		Sleep:      sleeper.Sleep,
+	}
+	app := NewWithDependencies(context.Background(), cfg, filepath.Join(dir, "config.yaml"), db, deps)
+	t.Cleanup(func() {
+		app.Close()
+	})
+
+	exec := func(command string) CommandResult {
+		result, err := app.Execute(ctx, command)
+		if err != nil {
+			t.Fatalf("Execute(%s) error = %v", command, err)
+		}
+		return result
+	}
+
+	subscribe := func(podcast itunes.Podcast) CommandResult {
+		result, err := app.SubscribePodcast(ctx, podcast)
+		if err != nil {
+			t.Fatalf("SubscribePodcast(%s) error = %v", podcast.ID, err)
+		}
+		return result
+	}
+
+	searchResult := exec("search Retry")
+	found := false
+	var targetPodcast itunes.Podcast
+	for _, sr := range searchResult.SearchResults {
+		if sr.Podcast.ID == podcastID {
+			found = true
+			targetPodcast = sr.Podcast
+			break
+		}
+	}
+	if !found {
+		t.Fatalf("search results missing podcast id %s", podcastID)
+	}
+	if msg := subscribe(targetPodcast).Message; !strings.Contains(msg, "Subscribed") {
+		t.Fatalf("subscribe output unexpected: %s", msg)
+	}
+
+	partialPath := filepath.Join(cfg.TmpDir, "podsink-"+episodeID+".partial")
+	if err := os.WriteFile(partialPath, []byte(content[:partialSize]), 0o600); err != nil {
+		t.Fatalf("write partial: %v", err)
+	}
+
+	downloadMsg := exec("download " + episodeID).Message
+	if !strings.Contains(downloadMsg, "Downloaded") {
+		t.Fatalf("download message unexpected: %s", downloadMsg)
+	}
+
+	if requests != 2 {
+		t.Fatalf("expected 2 download attempts, got %d", requests)
+	}
+	if len(receivedRange) != 2 {
+		t.Fatalf("expected 2 recorded ranges, got %d", len(receivedRange))
+	}
+	if receivedRange[0] != fmt.Sprintf("bytes=%d-", partialSize) {
+		t.Fatalf("first range header unexpected: %q", receivedRange[0])
+	}
+
+	if len(sleeper.calls) != 1 {
+		t.Fatalf("expected one backoff call, got %d", len(sleeper.calls))
+	}
+	if sleeper.calls[0] != time.Second {
+		t.Fatalf("expected 1s backoff, got %v", sleeper.calls[0])
+	}
+
+	var filePath string
+	if err := db.QueryRowContext(ctx, "SELECT file_path FROM episodes WHERE id = ?", episodeID).Scan(&filePath); err != nil {
+		t.Fatalf("query file_path: %v", err)
+	}
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatalf("read downloaded file: %v", err)
+	}
+	if string(data) != content {
+		t.Fatalf("downloaded file mismatch: got %q", string(data))
+	}
+
+	var retryCount int
+	if err := db.QueryRowContext(ctx, "SELECT retry_count FROM episodes WHERE id = ?", episodeID).Scan(&retryCount); err != nil {
+		t.Fatalf("query retry_count: %v", err)
+	}
+	if retryCount != 0 {
+		t.Fatalf("expected retry_count reset to 0, got %d", retryCount)
+	}
+
+	if _, err := os.Stat(partialPath); !os.IsNotExist(err) {
+		t.Fatalf("partial file should be removed, stat err=%v", err)
+	}
+}
+
+func TestDownloadQueueProcessesInParallel(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	cfg := config.Defaults()
+	cfg.DownloadRoot = filepath.Join(dir, "downloads")
+	cfg.TmpDir = filepath.Join(dir, "tmp")
+	cfg.ParallelDownloads = 2
+
+	if err := os.MkdirAll(cfg.DownloadRoot, 0o755); err != nil {
+		t.Fatalf("mkdir downloads: %v", err)
+	}
+	if err := os.MkdirAll(cfg.TmpDir, 0o755); err != nil {
+		t.Fatalf("mkdir tmp: %v", err)
+	}
+
+	db, err := storage.Open(filepath.Join(dir, "app.db"))
+	if err != nil {
+		t.Fatalf("storage.Open() error = %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	const podcastID = "777"
+
+	startCh := make(chan string, 4)
+	releaseCh := make(chan struct{}, 4)
+	var (
+		mu        sync.Mutex
+		active    int
+		maxActive int
+	)
+
+	handler := func(baseURL string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/search", "/lookup":
+				w.Header().Set("Content-Type", "application/json")
+				fmt.Fprintf(w, `{"results":[{"collectionId":%s,"collectionName":"Parallel Podcast","artistName":"Parallel Author","feedUrl":"%s/feed"}]}`, podcastID, baseURL)
+			case "/feed":
+				w.Header().Set("Content-Type", "application/rss+xml")
+				fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0">
+  <channel>
+    <title>Parallel Podcast</title>
+    <item>
+      <guid>ep1</guid>
+      <title>Episode One</title>
+      <enclosure url="%s/audio/ep1.mp3" length="100" type="audio/mpeg" />
+    </item>
+    <item>
+      <guid>ep2</guid>
+      <title>Episode Two</title>
+      <enclosure url="%s/audio/ep2.mp3" length="100" type="audio/mpeg" />
+    </item>
+  </channel>
+</rss>`, baseURL, baseURL)
+			case "/audio/ep1.mp3", "/audio/ep2.mp3":
+				mu.Lock()
+				active++
+				if active > maxActive {
+					maxActive = active
+				}
+				mu.Unlock()
+				startCh <- r.URL.Path
+				<-releaseCh
+				w.WriteHeader(http.StatusOK)
+				fmt.Fprintf(w, "data-%s", strings.TrimPrefix(r.URL.Path, "/audio/"))
+				mu.Lock()
+				active--
+				mu.Unlock()
+			default:
+				http.NotFound(w, r)
+			}
+		})
+	}
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler(server.URL).ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	deps := Dependencies{
+		HTTPClient: server.Client(),
+		ITunes:     itunes.NewClient(server.Client(), server.URL),
+	}
+	app := NewWithDependencies(context.Background(), cfg, filepath.Join(dir, "config.yaml"), db, deps)
+	t.Cleanup(func() {
+		app.Close()
+	})
+
+	exec := func(command string) CommandResult {
+		result, err := app.Execute(ctx, command)
+		if err != nil {
+			t.Fatalf("Execute(%s) error = %v", command, err)
+		}
+		return result
+	}
+
+	subscribe := func(podcast itunes.Podcast) CommandResult {
+		result, err := app.SubscribePodcast(ctx, podcast)
+		if err != nil {
+			t.Fatalf("SubscribePodcast(%s) error = %v", podcast.ID, err)
+		}
+		return result
+	}
+
+	searchResult := exec("search Parallel")
+	found := false
+	var targetPodcast itunes.Podcast
+	for _, sr := range searchResult.SearchResults {
+		if sr.Podcast.ID == podcastID {
+			found = true
+			targetPodcast = sr.Podcast
+			break
+		}
+	}
+	if !found {
+		t.Fatalf("search results missing podcast id %s", podcastID)
+	}
+	if msg := subscribe(targetPodcast).Message; !strings.Contains(msg, "Subscribed") {
+		t.Fatalf("subscribe output unexpected: %s", msg)
+	}
+
+	if msg := exec("queue ep1").Message; !strings.Contains(msg, "queued") {
+		t.Fatalf("queue ep1 unexpected: %s", msg)
+	}
+	if msg := exec("queue ep2").Message; !strings.Contains(msg, "queued") {
+		t.Fatalf("queue ep2 unexpected: %s", msg)
+	}
+
+	seen := make(map[string]struct{})
+	deadline := time.After(3 * time.Second)
+	for len(seen) < 2 {
+		select {
+		case path := <-startCh:
+			seen[path] = struct{}{}
+		case <-deadline:
+			t.Fatalf("timeout waiting for parallel downloads, saw %d", len(seen))
+		}
+	}
+
+	releaseCh <- struct{}{}
+	releaseCh <- struct{}{}
+
+	waitDeadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(waitDeadline) {
+		if episodeState(t, ctx, db, "ep1") == stateDownloaded && episodeState(t, ctx, db, "ep2") == stateDownloaded {
+			break
+		}
+		time.Sleep(25 * time.Millisecond)
+	}
+
+	if episodeState(t, ctx, db, "ep1") != stateDownloaded || episodeState(t, ctx, db, "ep2") != stateDownloaded {
+		t.Fatalf("episodes not downloaded in time: ep1=%s ep2=%s", episodeState(t, ctx, db, "ep1"), episodeState(t, ctx, db, "ep2"))
+	}
+
+	mu.Lock()
+	observed := maxActive
+	mu.Unlock()
+	if observed < 2 {
+		t.Fatalf("expected at least 2 concurrent downloads, saw %d", observed)
+	}
+
+	for _, id := range []string{"ep1", "ep2"} {
+		var filePath string
+		if err := db.QueryRowContext(ctx, "SELECT file_path FROM episodes WHERE id = ?", id).Scan(&filePath); err != nil {
+			t.Fatalf("query file path for %s: %v", id, err)
+		}
+		if _, err := os.Stat(filePath); err != nil {
+			t.Fatalf("downloaded file missing for %s: %v", id, err)
+		}
+	}
+}
+
+func newTestApp(t *testing.T) *App {
+	t.Helper()
+
+	dir := t.TempDir()
+	cfg := config.Defaults()
+	cfg.ParallelDownloads = 0
+	cfg.DownloadRoot = filepath.Join(dir, "downloads")
+	cfg.TmpDir = filepath.Join(dir, "tmp")
+
+	db, err := storage.Open(filepath.Join(dir, "app.db"))
+	if err != nil {
+		t.Fatalf("storage.Open() error = %v", err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+	})
+
+	if err := os.MkdirAll(cfg.DownloadRoot, 0o755); err != nil {
+		t.Fatalf("mkdir downloads: %v", err)
+	}
+	if err := os.MkdirAll(cfg.TmpDir, 0o755); err != nil {
+		t.Fatalf("mkdir tmp: %v", err)
+	}
+
+	app := New(context.Background(), cfg, filepath.Join(dir, "config.yaml"), db)
+	t.Cleanup(func() {
+		app.Close()
+	})
+	return app
+}
+
+func newMockPodcastServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	const podcastID = "12345"
+	handler := func(serverURL string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/search":
+				w.Header().Set("Content-Type", "application/json")
+				fmt.Fprintf(w, `{"results":[{"collectionId":%s,"collectionName":"Example Podcast","artistName":"Example Author","feedUrl":"%s/feed"}]}`, podcastID, serverURL)
+			case "/lookup":
+				w.Header().Set("Content-Type", "application/json")
+				fmt.Fprintf(w, `{"results":[{"collectionId":%s,"collectionName":"Example Podcast","artistName":"Example Author","feedUrl":"%s/feed","primaryGenreName":"News"}]}`, podcastID, serverURL)
+			case "/feed":
+				w.Header().Set("Content-Type", "application/rss+xml")
+				fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0">
+  <channel>
+    <title>Example Podcast</title>
+    <description>Example description</description>
+    <item>
+      <guid>ep1</guid>
+      <title>Episode One</title>
+      <pubDate>Mon, 02 Jan 2006 15:04:05 -0700</pubDate>
+      <enclosure url="%s/audio/ep1.mp3" length="100" type="audio/mpeg" />
+    </item>
+    <item>
+      <guid>ep2</guid>
+      <title>Episode Two</title>
+      <pubDate>Tue, 03 Jan 2006 15:04:05 -0700</pubDate>
+      <enclosure url="%s/audio/ep2.mp3" length="100" type="audio/mpeg" />
+    </item>
+  </channel>
+</rss>`, serverURL, serverURL)
+			case "/audio/ep1.mp3":
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte("episode-one"))
+			case "/audio/ep2.mp3":
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte("episode-two"))
+			default:
+				http.NotFound(w, r)
+			}
+		})
+	}
+
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler(srv.URL).ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func episodeState(t *testing.T, ctx context.Context, db *sql.DB, episodeID string) string {
+	t.Helper()
+	var state string
+	if err := db.QueryRowContext(ctx, "SELECT state FROM episodes WHERE id = ?", episodeID).Scan(&state); err != nil {
+		t.Fatalf("query episode state: %v", err)
+	}
+	return state
+}
+
+func TestRedownloadCorruptFindsChangedFiles(t *testing.T) {
+	app := newTestApp(t)
+	ctx := context.Background()
+
+	podcastDir := filepath.Join(app.config.DownloadRoot, "Example Podcast")
+	if err := os.MkdirAll(podcastDir, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if _, err := app.db.ExecContext(ctx, `INSERT INTO podcasts (id, title, feed_url, subscribed_at) VALUES (?, ?, ?, ?)`,
+		"pod1", "Example Podcast", "http://example.com/feed", time.Now().UTC()); err != nil {
+		t.Fatalf("insert podcast: %v", err)
+	}
+	// SHA-256 of "audio"; ep2's file was changed after it was recorded.
+	const audioHash = "6ed8919ce20490a5e3ad8630a4fab69475297abd07db73918dd5f36fcfaeb11b"
+	for id, content := range map[string]string{"ep1": "audio", "ep2": "bit rot"} {
+		path := filepath.Join(podcastDir, id+".mp3")
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("write file: %v", err)
+		}
+		if _, err := app.db.ExecContext(ctx, `INSERT INTO episodes (id, podcast_id, title, state, file_path, hash, enclosure_url) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			id, "pod1", "Episode "+id, stateDownloaded, path, audioHash, "http://example.com/"+id+".mp3"); err != nil {
+			t.Fatalf("insert episode: %v", err)
+		}
+	}
+
+	result, err := app.Execute(ctx, "redownload corrupt")
+	if err != nil {
+		t.Fatalf("Execute(redownload corrupt) error = %v", err)
+	}
+	if result.Message != "Queued 1 corrupt episode(s) for re-download." {
+		t.Fatalf("unexpected message: %s", result.Message)
+	}
+	if state := episodeState(t, ctx, app.db, "ep1"); state != stateDownloaded {
+		t.Fatalf("expected intact file to stay DOWNLOADED, got %s", state)
+	}
+	if state := episodeState(t, ctx, app.db, "ep2"); state != stateQueued {
+		t.Fatalf("expected changed file to be queued, got %s", state)
+	}
+}
+
+func TestDedupeCommandLinksIdenticalFiles(t *testing.T) {
+	app := newTestApp(t)
+	ctx := context.Background()
+
+	if _, err := app.db.ExecContext(ctx, `INSERT INTO podcasts (id, title, feed_url, subscribed_at) VALUES (?, ?, ?, ?)`,
+		"pod1", "Example Podcast", "http://example.com/feed", time.Now().UTC()); err != nil {
+		t.Fatalf("insert podcast: %v", err)
+	}
+
+	content := []byte("cross-promo audio")
+	paths := make([]string, 0, 2)
+	for _, id := range []string{"promo-a", "promo-b"} {
+		path := filepath.Join(app.config.DownloadRoot, id+".mp3")
+		if err := os.WriteFile(path, content, 0o600); err != nil {
+			t.Fatalf("write %s: %v", id, err)
+		}
+		if _, err := app.db.ExecContext(ctx, `INSERT INTO episodes (id, podcast_id, title, state, enclosure_url, file_path, hash, downloaded_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?)`, id, "pod1", id, stateDownloaded, "http://example.com/"+id+".mp3", path, "samehash", time.Now().UTC().Format(time.RFC3339Nano)); err != nil {
+			t.Fatalf("insert episode %s: %v", id, err)
+		}
+		paths = append(paths, path)
+	}
+
+	result, err := app.Execute(ctx, "dedupe")
+	if err != nil {
+		t.Fatalf("Execute(dedupe) error = %v", err)
+	}
+	if !strings.Contains(result.Message, "Found 1 duplicate file(s)") {
+		t.Fatalf("unexpected dedupe report: %s", result.Message)
+	}
+
+	result, err = app.Execute(ctx, "dedupe link")
+	if err != nil {
+		t.Fatalf("Execute(dedupe link) error = %v", err)
+	}
+	if !strings.Contains(result.Message, "Linked 1 duplicate file(s)") {
+		t.Fatalf("unexpected dedupe link message: %s", result.Message)
+	}
+
+	first, err := os.Stat(paths[0])
+	if err != nil {
+		t.Fatalf("stat first: %v", err)
+	}
+	second, err := os.Stat(paths[1])
+	if err != nil {
+		t.Fatalf("stat second: %v", err)
+	}
+	if !os.SameFile(first, second) {
+		t.Fatal("expected duplicate files to be hard linked")
+	}
+
+	result, err = app.Execute(ctx, "dedupe")
+	if err != nil {
+		t.Fatalf("Execute(dedupe) after link error = %v", err)
+	}
+	if result.Message != "No duplicate downloads found." {
+		t.Fatalf("expected no duplicates after linking, got %s", result.Message)
+	}
+}
+
+func TestAdoptCommandMatchesExistingFiles(t *testing.T) {
+	app := newTestApp(t)
+	ctx := context.Background()
+
+	if _, err := app.db.ExecContext(ctx, `INSERT INTO podcasts (id, title, feed_url, subscribed_at) VALUES (?, ?, ?, ?)`,
+		"pod1", "Example Podcast", "http://example.com/feed", time.Now().UTC()); err != nil {
+		t.Fatalf("insert podcast: %v", err)
+	}
+	episodes := []struct {
+		id, title, url string
+		size           int64
+	}{
+		{"ep1", "First Episode", "http://example.com/audio/ep-001.mp3", 0},
+		{"ep2", "Second Episode", "http://example.com/audio/ep-002.mp3", 0},
+		{"ep3", "Third Episode", "http://example.com/audio/ep-003.mp3", 11},
+		{"ep4", "Fourth Episode", "http://example.com/audio/ep-004.mp3", 12},
+	}
+	for _, ep := range episodes {
+		state := "SEEN"
+		if ep.id == "ep4" {
+			state = stateQueued // about to be downloaded
+		}
+		if _, err := app.db.ExecContext(ctx, `INSERT INTO episodes (id, podcast_id, title, state, enclosure_url, size_bytes) VALUES (?, ?, ?, ?, ?, ?)`,
+			ep.id, "pod1", ep.title, state, ep.url, ep.size); err != nil {
+			t.Fatalf("insert episode %s: %v", ep.id, err)
+		}
+	}
+
+	library := t.TempDir()
+	files := map[string]string{
+		"First_Episode.mp3": "first audio",  // matched by generated name
+		"ep-002.mp3":        "second audio", // matched by enclosure name
+		"renamed.mp3":       "third audio",  // matched by size (11 bytes)
+		"unknown.mp3":       "no match for this one",
+		"cover.jpg":         "not audio!!",  // same size as ep3, skipped
+		"queued.mp3":        "queued audio", // ep4 is queued, not adopted
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(library, name), []byte(content), 0o600); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+
+	result, err := app.Execute(ctx, "adopt "+library)
+	if err != nil {
+		t.Fatalf("Execute(adopt) error = %v", err)
+	}
+	if result.Message != "Adopted 3 of 5 file(s); 2 unmatched." {
+		t.Fatalf("unexpected adopt message: %s", result.Message)
+	}
+
+	for id, name := range map[string]string{"ep1": "First_Episode.mp3", "ep2": "ep-002.mp3", "ep3": "renamed.mp3"} {
+		var state, filePath string
+		if err := app.db.QueryRowContext(ctx, `SELECT state, file_path FROM episodes WHERE id = ?`, id).Scan(&state, &filePath); err != nil {
+			t.Fatalf("query %s: %v", id, err)
+		}
+		if state != stateDownloaded || filePath != filepath.Join(library, name) {
+			t.Fatalf("episode %s: got state %s path %s", id, state, filePath)
+		}
+	}
+}
+
+func TestQueueCommandPublishesEpisodeStateEvent(t *testing.T) {
+	app := newTestApp(t)
+	ctx := context.Background()
+
+	if _, err := app.db.ExecContext(ctx, `INSERT INTO podcasts (id, title, feed_url, subscribed_at) VALUES (?, ?, ?, ?)`,
+		"pod1", "Example Podcast", "http://example.com/feed", time.Now().UTC()); err != nil {
+		t.Fatalf("insert podcast: %v", err)
+	}
+	if _, err := app.db.ExecContext(ctx, `INSERT INTO episodes (id, podcast_id, title, state, enclosure_url) VALUES (?, ?, ?, ?, ?)`,
+		"ep1", "pod1", "Episode One", "SEEN", "http://example.com/ep1.mp3"); err != nil {
+		t.Fatalf("insert episode: %v", err)
+	}
+
+	events, unsubscribe := app.Events().Subscribe()
+	defer unsubscribe()
+
+	if _, err := app.Execute(ctx, "queue ep1"); err != nil {
+		t.Fatalf("Execute(queue) error = %v", err)
+	}
+
+	select {
+	case event := <-events:
+		if event.Kind != EventEpisodeState || event.EpisodeID != "ep1" || event.State != stateQueued {
+			t.Fatalf("unexpected event: %+v", event)
+		}
+	default:
+		t.Fatal("expected an episode state event after queueing")
+	}
+
+	app.Events().Close()
+	if _, ok := <-events; ok {
+		t.Fatal("expected subscriber channel to be closed with the bus")
+	}
+}
+
+func TestCancelledContextAbortsInFlightDownloads(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config.Defaults()
+	cfg.ParallelDownloads = 1
+	cfg.DownloadRoot = filepath.Join(dir, "downloads")
+	cfg.TmpDir = filepath.Join(dir, "tmp")
+	for _, d := range []string{cfg.DownloadRoot, cfg.TmpDir} {
+		if err := os.MkdirAll(d, 0o755); err != nil {
+			t.Fatalf("mkdir %s: %v", d, err)
+		}
+	}
+
+	db, err := storage.Open(filepath.Join(dir, "app.db"))
+	if err != nil {
+		t.Fatalf("storage.Open() error = %v", err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+	})
+
+	started := make(chan struct{}, 1)
+	aborted := make(chan struct{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		started <- struct{}{}
+		<-r.Context().Done()
+		aborted <- struct{}{}
+	}))
+	t.Cleanup(server.Close)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	app := NewWithDependencies(ctx, cfg, filepath.Join(dir, "config.yaml"), db, Dependencies{HTTPClient: server.Client()})
+	t.Cleanup(func() {
+		app.Close()
+	})
+
+	if _, err := app.db.ExecContext(context.Background(), `INSERT INTO podcasts (id, title, feed_url, subscribed_at) VALUES (?, ?, ?, ?)`,
+		"pod1", "Example Podcast", server.URL+"/feed", time.Now().UTC()); err != nil {
+		t.Fatalf("insert podcast: %v", err)
+	}
+	if _, err := app.db.ExecContext(context.Background(), `INSERT INTO episodes (id, podcast_id, title, state, enclosure_url) VALUES (?, ?, ?, ?, ?)`,
+		"ep1", "pod1", "Episode One", "SEEN", server.URL+"/ep1.mp3"); err != nil {
+		t.Fatalf("insert episode: %v", err)
+	}
+	if _, err := app.Execute(context.Background(), "queue ep1"); err != nil {
+		t.Fatalf("Execute(queue) error = %v", err)
+	}
+
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("download did not start")
+	}
+
+	cancel()
+	select {
+	case <-aborted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("cancelling the app context did not abort the transfer")
+	}
+
+	app.downloadMgr.Stop()
+	var claimed sql.NullString
+	if err := app.db.QueryRowContext(context.Background(), `SELECT claimed_at FROM downloads WHERE episode_id = ?`, "ep1").Scan(&claimed); err != nil {
+		t.Fatalf("query claim: %v", err)
+	}
+	if claimed.Valid {
+		t.Fatal("expected aborted download to be released back to the queue")
+	}
+}
+
+func TestQueueEpisodeReadsOnlyEpisodeIDs(t *testing.T) {
+	app := newTestApp(t)
+	ctx := context.Background()
+
+	for _, id := range []string{"process", "workers"} {
+		result, err := app.QueueEpisode(ctx, id)
+		if err != nil {
+			t.Fatalf("QueueEpisode(%s) error = %v", id, err)
+		}
+		if result.Message != "Episode not found." {
+			t.Fatalf("QueueEpisode(%s) ran a subcommand: %s", id, result.Message)
+		}
+	}
+}
+
+func TestQueueWorkersResizesPool(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "audio")
+	}))
+	t.Cleanup(server.Close)
+
+	app := newTestAppWithClient(t, server.Client())
+	ctx := context.Background()
+
+	if _, err := app.db.ExecContext(ctx, `INSERT INTO podcasts (id, title, feed_url, subscribed_at) VALUES (?, ?, ?, ?)`,
+		"pod1", "Example Podcast", server.URL+"/feed", time.Now().UTC()); err != nil {
+		t.Fatalf("insert podcast: %v", err)
+	}
+	if _, err := app.db.ExecContext(ctx, `INSERT INTO episodes (id, podcast_id, title, state, enclosure_url) VALUES (?, ?, ?, ?, ?)`,
+		"ep1", "pod1", "Episode One", "SEEN", server.URL+"/ep1.mp3"); err != nil {
+		t.Fatalf("insert episode: %v", err)
+	}
+	if _, err := app.Execute(ctx, "queue ep1"); err != nil {
+		t.Fatalf("Execute(queue) error = %v", err)
+	}
+
+	result, err := app.Execute(ctx, "queue workers 1")
+	if err != nil {
+		t.Fatalf("Execute(queue workers 1) error = %v", err)
+	}
+	if result.Message != "Download workers set to 1." {
+		t.Fatalf("unexpected message: %s", result.Message)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		info, err := app.episodes.FetchEpisodeInfo(ctx, "ep1")
+		if err != nil {
+			t.Fatalf("FetchEpisodeInfo: %v", err)
+		}
+		if info.State == stateDownloaded {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("episode not downloaded after growing the pool, state %s", info.State)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	if _, err := app.Execute(ctx, "queue workers 0"); err != nil {
+		t.Fatalf("Execute(queue workers 0) error = %v", err)
+	}
+	result, err = app.Execute(ctx, "queue workers")
+	if err != nil {
+		t.Fatalf("Execute(queue workers) error = %v", err)
+	}
+	if result.Message != "0 download worker(s) running." {
+		t.Fatalf("unexpected message: %s", result.Message)
+	}
+}
+
+func TestRefreshCommandMergesNewEpisodes(t *testing.T) {
+	var published atomic.Int32
+	published.Store(1)
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/feed" {
+			http.NotFound(w, r)
+			return
+		}
+		var items strings.Builder
+		for i := 1; i <= int(published.Load()); i++ {
+			fmt.Fprintf(&items, `<item><guid>ep%d</guid><title>Episode %d</title><enclosure url="%s/audio/ep%d.mp3" length="100" type="audio/mpeg" /></item>`, i, i, srv.URL, i)
+		}
+		w.Header().Set("Content-Type", "application/rss+xml")
+		fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><rss version="2.0"><channel><title>Example Podcast</title>%s</channel></rss>`, items.String())
+	}))
+	t.Cleanup(srv.Close)
+
+	app := newTestApp(t)
+	ctx := context.Background()
+	app.subscriptions = subscriptions.NewService(repository.New(app.db), srv.Client(), nil, app.events)
+
+	if _, err := app.SubscribePodcast(ctx, itunes.Podcast{ID: "pod1", Title: "Example Podcast", FeedURL: srv.URL + "/feed"}); err != nil {
+		t.Fatalf("SubscribePodcast() error = %v", err)
+	}
+
+	published.Store(2)
+	result, err := app.Execute(ctx, "refresh")
+	if err != nil {
+		t.Fatalf("Execute(refresh) error = %v", err)
+	}
+	if result.Message != "Refreshed 1 feed(s), 1 new episode(s): Example Podcast +1." {
+		t.Fatalf("unexpected refresh message: %s", result.Message)
+	}
+	if state := episodeState(t, ctx, app.db, "ep2"); state != stateNew {
+		t.Fatalf("expected new episode state NEW, got %s", state)
+	}
+
+	published.Store(3)
+	result, err = app.Execute(ctx, "refresh pod1")
+	if err != nil {
+		t.Fatalf("Execute(refresh pod1) error = %v", err)
+	}
+	if result.Message != "Refreshed 1 feed(s), 1 new episode(s): Example Podcast +1." {
+		t.Fatalf("unexpected single refresh message: %s", result.Message)
+	}
+	result, err = app.Execute(ctx, "refresh unknown")
+	if err != nil {
+		t.Fatalf("Execute(refresh unknown) error = %v", err)
+	}
+	if result.Message != "No subscription found for that podcast." {
+		t.Fatalf("unexpected message for unknown podcast: %s", result.Message)
+	}
+
+	summaries, err := app.subscriptions.Summaries(ctx)
+	if err != nil {
+		t.Fatalf("Summaries() error = %v", err)
+	}
+	if len(summaries) != 1 || summaries[0].LastRefreshed.IsZero() {
+		t.Fatalf("expected last refreshed timestamp, got %+v", summaries)
+	}
+}
+
+func TestRefreshRunsIngestFilters(t *testing.T) {
+	var published atomic.Int32
+	published.Store(1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var items strings.Builder
+		for i := 1; i <= int(published.Load()); i++ {
+			fmt.Fprintf(&items, `<item><guid>ep%d</guid><title>Episode %d</title><enclosure url="https://example.com/ep%d.mp3" length="100" type="audio/mpeg" /></item>`, i, i, i)
+		}
+		w.Header().Set("Content-Type", "application/rss+xml")
+		fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><rss version="2.0"><channel><title>Example Podcast</title>%s</channel></rss>`, items.String())
+	}))
+	t.Cleanup(srv.Close)
+
+	dir := t.TempDir()
+	app := newTestApp(t)
+	ctx := context.Background()
+	app.subscriptions = subscriptions.NewService(repository.New(app.db), srv.Client(), nil, app.events)
+
+	if _, err := app.SubscribePodcast(ctx, itunes.Podcast{ID: "pod1", Title: "Example Podcast", FeedURL: srv.URL + "/feed"}); err != nil {
+		t.Fatalf("SubscribePodcast() error = %v", err)
+	}
+	app.subscriptions.SetIngestFilters([]config.IngestFilter{
+		{Name: "broken", Command: "echo boom >&2; exit 1"},
+		{Name: "skip", Command: fmt.Sprintf(`cat > %q; echo '{"episodes":[{"id":"ep2","state":"ignored"},{"id":"ep1","state":"queued"}],"add_tags":["Filtered"]}'`, filepath.Join(dir, "input.json"))},
+	})
+	published.Store(3)
+	result, err := app.Execute(ctx, "refresh")
+	if err != nil {
+		t.Fatalf("Execute(refresh) error = %v", err)
+	}
+	if result.Message != "Refreshed 1 feed(s), 2 new episode(s): Example Podcast +2." {
+		t.Fatalf("unexpected refresh message: %s", result.Message)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "input.json"))
+	if err != nil {
+		t.Fatalf("filter did not run: %v", err)
+	}
+	var input subscriptions.FilterInput
+	if err := json.Unmarshal(data, &input); err != nil {
+		t.Fatalf("parse filter input: %v", err)
+	}
+	if input.Podcast.ID != "pod1" || len(input.Episodes) != 2 || input.Episodes[0].State != "new" {
+		t.Fatalf("unexpected filter input: %s", data)
+	}
+
+	for id, want := range map[string]string{"ep1": stateNew, "ep2": stateIgnored, "ep3": stateNew} {
+		if state := episodeState(t, ctx, app.db, id); state != want {
+			t.Fatalf("expected %s to be %s, got %s", id, want, state)
+		}
+	}
+	var tags int
+	if err := app.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM podcast_tags WHERE podcast_id = 'pod1' AND tag = 'Filtered'").Scan(&tags); err != nil {
+		t.Fatalf("count tags: %v", err)
+	}
+	if tags != 1 {
+		t.Fatalf("expected the filter to tag the podcast")
+	}
+}
+
+func TestSubscribeRunsIngestFilters(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		feed := strings.TrimPrefix(r.URL.Path, "/")
+		w.Header().Set("Content-Type", "application/rss+xml")
+		fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><rss version="2.0"><channel><title>Podcast %[1]s</title><item><guid>%[1]s1</guid><title>Trailer</title><enclosure url="https://example.com/%[1]s1.mp3" length="100" type="audio/mpeg" /></item><item><guid>%[1]s2</guid><title>Episode</title><enclosure url="https://example.com/%[1]s2.mp3" length="100" type="audio/mpeg" /></item></channel></rss>`, feed)
+	}))
+	t.Cleanup(srv.Close)
+
+	dir := t.TempDir()
+	app := newTestApp(t)
+	ctx := context.Background()
+	app.subscriptions = subscriptions.NewService(repository.New(app.db), srv.Client(), nil, app.events)
+	app.subscriptions.SetIngestFilters([]config.IngestFilter{
+		{Name: "skip-trailers", Command: fmt.Sprintf(`cat >> %q; echo '{"episodes":[{"id":"a1","state":"ignored"},{"id":"b1","state":"ignored"}],"add_tags":["Filtered"]}'`, filepath.Join(dir, "input.json"))},
+	})
+
+	if _, err := app.SubscribePodcast(ctx, itunes.Podcast{ID: "pod1", Title: "Podcast a", FeedURL: srv.URL + "/a"}); err != nil {
+		t.Fatalf("SubscribePodcast() error = %v", err)
+	}
+	if _, err := app.Execute(ctx, "subscribe "+srv.URL+"/b"); err != nil {
+		t.Fatalf("Execute(subscribe) error = %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "input.json"))
+	if err != nil {
+		t.Fatalf("filter did not run: %v", err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for _, want := range []string{"a", "b"} {
+		var input subscriptions.FilterInput
+		if err := dec.Decode(&input); err != nil {
+			t.Fatalf("parse filter input: %v", err)
+		}
+		if input.Podcast.Title != "Podcast "+want || len(input.Episodes) != 2 {
+			t.Fatalf("unexpected filter input: %s", data)
+		}
+	}
+	for id, want := range map[string]string{"a1": stateIgnored, "a2": stateNew, "b1": stateIgnored, "b2": stateNew} {
+		if state := episodeState(t, ctx, app.db, id); state != want {
+			t.Fatalf("expected %s to be %s, got %s", id, want, state)
+		}
+	}
+	var tagged int
+	if err := app.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM podcast_tags WHERE tag = 'Filtered'").Scan(&tagged); err != nil {
+		t.Fatalf("count tags: %v", err)
+	}
+	if tagged != 2 {
+		t.Fatalf("expected the filter to tag both podcasts, got %d", tagged)
+	}
+}
+
+func TestRefreshRunsIngestFiltersAfterStoringEveryFeedWithinTheFeedTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><rss version="2.0"><channel><title>Podcast %[1]s</title><item><guid>%[1]s-ep1</guid><title>Episode 1</title><enclosure url="https://example.com%[1]s.mp3" length="100" type="audio/mpeg" /></item></channel></rss>`, r.URL.Path)
+	}))
+	t.Cleanup(srv.Close)
+
+	app := newTestApp(t)
+	ctx := context.Background()
+	app.subscriptions = subscriptions.NewService(repository.New(app.db), srv.Client(), nil, app.events)
+	app.subscriptions.SetFeedTimeoutSeconds(1)
+	app.subscriptions.SetIngestFilters([]config.IngestFilter{
+		{Name: "slow", Command: `if [ "$PODSINK_PODCAST_ID" = slow ]; then sleep 30; fi`},
+	})
+	for _, id := range []string{"slow", "fast"} {
+		if _, err := app.db.ExecContext(ctx, `INSERT INTO podcasts (id, title, feed_url, subscribed_at) VALUES (?, ?, ?, ?)`,
+			id, id, srv.URL+"/"+id, time.Now().UTC()); err != nil {
+			t.Fatalf("insert podcast %s: %v", id, err)
+		}
+	}
+	events, unsubscribe := app.Events().Subscribe()
+	defer unsubscribe()
+
+	start := time.Now()
+	result, err := app.Execute(ctx, "refresh")
+	if err != nil {
+		t.Fatalf("Execute(refresh) error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Fatalf("the slow filter held up the refresh for %s", elapsed)
+	}
+	if !strings.HasPrefix(result.Message, "Refreshed 2 feed(s), 2 new episode(s)") {
+		t.Fatalf("unexpected refresh message: %s", result.Message)
+	}
+
+	var order []string
+	for len(order) < 4 {
+		select {
+		case event := <-events:
+			switch {
+			case event.Kind == domain.EventRefreshProgress && event.FeedsDone > 0:
+				order = append(order, "stored")
+			case event.Kind == domain.EventEpisodeAdded:
+				order = append(order, "added "+event.PodcastID)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("missing events, got %v", order)
+		}
+	}
+	if order[0] != "stored" || order[1] != "stored" || !slices.Contains(order, "added slow") || !slices.Contains(order, "added fast") {
+		t.Fatalf("expected both feeds stored before episodes are announced, got %v", order)
+	}
+}
+
+func TestRefreshReportsFailingFeedsAndContinues(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/slow":
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+			return
+		case "/broken":
+			http.Error(w, "boom", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/rss+xml")
+		fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?><rss version="2.0"><channel><title>Working Podcast</title><item><guid>ep1</guid><title>Episode 1</title><enclosure url="https://example.com/ep1.mp3" length="100" type="audio/mpeg" /></item></channel></rss>`)
+	}))
+	t.Cleanup(srv.Close)
+	t.Cleanup(func() { close(release) })
+
+	app := newTestApp(t)
+	ctx := context.Background()
+	app.subscriptions = subscriptions.NewService(repository.New(app.db), srv.Client(), nil, app.events)
+	app.subscriptions.SetFeedTimeoutSeconds(1)
+	for _, feed := range []struct{ id, title, path string }{
+		{"slow", "Slow Podcast", "/slow"},
+		{"broken", "Broken Podcast", "/broken"},
+		{"working", "Working Podcast", "/feed"},
+	} {
+		if _, err := app.db.ExecContext(ctx, `INSERT INTO podcasts (id, title, feed_url, subscribed_at) VALUES (?, ?, ?, ?)`,
+			feed.id, feed.title, srv.URL+feed.path, time.Now().UTC()); err != nil {
+			t.Fatalf("insert podcast %s: %v", feed.id, err)
+		}
+	}
+
+	result, err := app.Execute(ctx, "refresh")
+	if err != nil {
+		t.Fatalf("Execute(refresh) error = %v", err)
+	}
+	if result.Message != "Refreshed 1 feed(s), 1 new episode(s), 2 error(s): Working Podcast +1." {
+		t.Fatalf("unexpected refresh message: %s", result.Message)
+	}
+	failures := result.RefreshFailures
+	if len(failures) != 2 || failures[0].PodcastID != "broken" || failures[1].PodcastID != "slow" {
+		t.Fatalf("expected broken and slow feeds to fail, got %+v", failures)
+	}
+	if !strings.Contains(failures[1].Err, "timed out after 1s") {
+		t.Fatalf("expected a timeout for the slow feed, got %q", failures[1].Err)
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		t.Fatalf("marshal result: %v", err)
+	}
+	if !strings.Contains(string(data), `"refresh_errors":[{"podcast_id":"broken","title":"Broken Podcast"`) {
+		t.Fatalf("expected refresh_errors in JSON, got %s", data)
+	}
+}
+
+// newTestAppWithClient builds an app without download workers that fetches through client.
+func newTestAppWithClient(t *testing.T, client *http.Client) *App {
+	t.Helper()
+	return newTestAppWithConfig(t, client, nil)
+}
+
+// newTestAppWithConfig is newTestAppWithClient with a hook to adjust the
+// configuration before services are created.
+func newTestAppWithConfig(t *testing.T, client *http.Client, configure func(*config.Config)) *App {
+	t.Helper()
+
+	dir := t.TempDir()
+	cfg := config.Defaults()
+	cfg.ParallelDownloads = 0
+	cfg.DownloadRoot = filepath.Join(dir, "downloads")
+	cfg.TmpDir = filepath.Join(dir, "tmp")
+	if configure != nil {
+		configure(&cfg)
+	}
+	for _, d := range []string{cfg.DownloadRoot, cfg.TmpDir} {
+		if err := os.MkdirAll(d, 0o755); err != nil {
+			t.Fatalf("mkdir %s: %v", d, err)
+		}
+	}
+	db, err := storage.Open(filepath.Join(dir, "app.db"))
+	if err != nil {
+		t.Fatalf("storage.Open() error = %v", err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+	})
+
+	noSleep := func(context.Context, time.Duration) error { return nil }
+	app := NewWithDependencies(context.Background(), cfg, filepath.Join(dir, "config.yaml"), db, Dependencies{HTTPClient: client, Sleep: noSleep})
+	t.Cleanup(func() {
+		app.Close()
+	})
+	return app
+}
+
+func TestImportOPMLDedupesByPodcastGUID(t *testing.T) {
+	var fetched sync.Map
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetched.Store(r.URL.Path, true)
+		fmt.Fprint(w, `<rss xmlns:podcast="https://podcastindex.org/namespace/1.0"><channel><title>Moved</title>
+<podcast:guid>917393e3-1b1e-5cef-ace4-edaa54e1f810</podcast:guid>
+<item><guid>ep1</guid><title>One</title><enclosure url="https://example.com/1.mp3" type="audio/mpeg"/></item>
+</channel></rss>`)
+	}))
+	t.Cleanup(server.Close)
+
+	app := newTestAppWithClient(t, server.Client())
+	// One outline at a time, so the third sees the guid the first stored
+	app.subscriptions.SetImportConcurrency(1)
+	ctx := context.Background()
+
+	// The same podcast under its old and new URL, and once more under a
+	// third URL whose outline carries the guid.
+	opmlPath := filepath.Join(t.TempDir(), "import.opml")
+	contents := fmt.Sprintf(`<opml version="2.0" xmlns:podcast="https://podcastindex.org/namespace/1.0"><body>
+<outline type="rss" text="Old" xmlUrl="%[1]s/old"/>
+<outline type="rss" text="New" xmlUrl="%[1]s/new"/>
+<outline type="rss" text="Mirror" xmlUrl="%[1]s/mirror" podcast:guid="917393E3-1B1E-5CEF-ACE4-EDAA54E1F810"/>
+</body></opml>`, server.URL)
+	if err := os.WriteFile(opmlPath, []byte(contents), 0o600); err != nil {
+		t.Fatalf("write OPML file: %v", err)
+	}
+
+	result, err := app.ImportOPML(ctx, opmlPath, "")
+	if err != nil {
+		t.Fatalf("ImportOPML error = %v", err)
+	}
+	if result.Imported != 1 || result.Skipped != 2 || len(result.Errors) != 0 {
+		t.Fatalf("unexpected import result %+v", result)
+	}
+	if _, ok := fetched.Load("/mirror"); ok {
+		t.Fatal("outline with a known podcast:guid should be skipped without fetching")
+	}
+
+	res, err := app.Execute(ctx, "subscribe "+server.URL+"/new")
+	if err != nil {
+		t.Fatalf("subscribe error = %v", err)
+	}
+	if res.Message != "Already subscribed to Moved." {
+		t.Fatalf("unexpected subscribe message %q", res.Message)
+	}
+
+	exportPath := filepath.Join(t.TempDir(), "export.opml")
+	if _, err := app.ExportOPML(ctx, exportPath); err != nil {
+		t.Fatalf("ExportOPML error = %v", err)
+	}
+	data, err := os.ReadFile(exportPath)
+	if err != nil {
+		t.Fatalf("read exported file: %v", err)
+	}
+	if !strings.Contains(string(data), `podcast:guid="917393e3-1b1e-5cef-ace4-edaa54e1f810"`) {
+		t.Fatalf("export lacks podcast:guid: %s", data)
+	}
+}
+
+func TestImportOPMLConflictPolicies(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<rss xmlns:podcast="https://podcastindex.org/namespace/1.0"><channel><title>Example Podcast</title>
+<podcast:guid>917393e3-1b1e-5cef-ace4-edaa54e1f810</podcast:guid>
+<item><guid>ep1</guid><title>One</title><enclosure url="https://example.com/1.mp3" type="audio/mpeg"/></item>
+</channel></rss>`)
+	}))
+	t.Cleanup(server.Close)
+
+	app := newTestAppWithClient(t, server.Client())
+	ctx := context.Background()
+	if _, err := app.Execute(ctx, "subscribe "+server.URL+"/feed"); err != nil {
+		t.Fatalf("subscribe error = %v", err)
+	}
+	podcast, found, err := repository.New(app.db).FindSubscription(ctx, "917393e3-1b1e-5cef-ace4-edaa54e1f810", "")
+	if err != nil || !found {
+		t.Fatalf("FindSubscription() = %v, %v", found, err)
+	}
+
+	// Another app knows the podcast by another name and its new URL
+	opmlPath := filepath.Join(t.TempDir(), "import.opml")
+	contents := fmt.Sprintf(`<opml version="2.0" xmlns:podcast="https://podcastindex.org/namespace/1.0"><body>
+<outline text="News"><outline type="rss" text="Renamed" xmlUrl="%s/moved" podcast:guid="917393e3-1b1e-5cef-ace4-edaa54e1f810"/></outline>
+</body></opml>`, server.URL)
+	if err := os.WriteFile(opmlPath, []byte(contents), 0o600); err != nil {
+		t.Fatalf("write OPML file: %v", err)
+	}
+	current := func() (string, string, []string) {
+		t.Helper()
+		var title, feedURL string
+		if err := app.db.QueryRowContext(ctx, "SELECT title, feed_url FROM podcasts WHERE id = ?", podcast.ID).Scan(&title, &feedURL); err != nil {
+			t.Fatalf("read podcast: %v", err)
+		}
+		tags, err := repository.New(app.db).PodcastTags(ctx, podcast.ID)
+		if err != nil {
+			t.Fatalf("PodcastTags() error = %v", err)
+		}
+		return title, feedURL, tags
+	}
+
+	result, err := app.Execute(ctx, "import "+opmlPath)
+	if err != nil {
+		t.Fatalf("import error = %v", err)
+	}
+	if result.Message != "Imported 0 subscriptions, skipped 1" || len(result.ImportConflicts) != 0 {
+		t.Fatalf("unexpected result for keep: %q %+v", result.Message, result.ImportConflicts)
+	}
+
+	result, err = app.Execute(ctx, "import "+opmlPath+" --on-conflict ask")
+	if err != nil {
+		t.Fatalf("import error = %v", err)
+	}
+	if result.Message != "Imported 0 subscriptions, skipped 1, 1 conflicts" || len(result.ImportConflicts) != 1 {
+		t.Fatalf("unexpected result for ask: %q %+v", result.Message, result.ImportConflicts)
+	}
+	conflict := result.ImportConflicts[0]
+	if conflict.PodcastID != podcast.ID || conflict.Title != "Example Podcast" || conflict.ImportedTitle != "Renamed" ||
+		conflict.ImportedFeedURL != server.URL+"/moved" || len(conflict.Tags) != 1 || conflict.Tags[0] != "News" {
+		t.Fatalf("unexpected conflict %+v", conflict)
+	}
+	if title, feedURL, tags := current(); title != "Example Podcast" || feedURL != server.URL+"/feed" || len(tags) != 0 {
+		t.Fatalf("ask changed the subscription: %q %q %v", title, feedURL, tags)
+	}
+
+	result, err = app.Execute(ctx, "import "+opmlPath+" --on-conflict merge")
+	if err != nil {
+		t.Fatalf("import error = %v", err)
+	}
+	if result.Message != "Imported 0 subscriptions, updated 1" {
+		t.Fatalf("unexpected result for merge: %q", result.Message)
+	}
+	if title, feedURL, tags := current(); title != "Example Podcast" || feedURL != server.URL+"/moved" || len(tags) != 1 || tags[0] != "News" {
+		t.Fatalf("unexpected subscription after merge: %q %q %v", title, feedURL, tags)
+	}
+
+	result, err = app.Execute(ctx, "import "+opmlPath+" --on-conflict=imported")
+	if err != nil {
+		t.Fatalf("import error = %v", err)
+	}
+	if result.Message != "Imported 0 subscriptions, updated 1" {
+		t.Fatalf("unexpected result for imported: %q", result.Message)
+	}
+	if title, _, _ := current(); title != "Renamed" {
+		t.Fatalf("expected the imported title, got %q", title)
+	}
+
+	result, err = app.Execute(ctx, "import "+opmlPath+" --on-conflict overwrite")
+	if err != nil {
+		t.Fatalf("import error = %v", err)
+	}
+	if !strings.Contains(result.Message, "unknown conflict policy: overwrite") {
+		t.Fatalf("unexpected message for an unknown policy: %q", result.Message)
+	}
+}
+
+func TestImportOPMLFetchesFeedsConcurrently(t *testing.T) {
+	var active, peak atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := active.Add(1)
+		defer active.Add(-1)
+		for {
+			old := peak.Load()
+			if n <= old || peak.CompareAndSwap(old, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		if strings.HasPrefix(r.URL.Path, "/broken") {
+			http.Error(w, "gone", http.StatusGone)
+			return
+		}
+		fmt.Fprintf(w, `<rss><channel><title>Feed %s</title>
+<item><guid>%[1]s-1</guid><title>One</title><enclosure url="https://example.com%[1]s.mp3" type="audio/mpeg"/></item>
+</channel></rss>`, r.URL.Path)
+	}))
+	t.Cleanup(server.Close)
+
+	app := newTestAppWithClient(t, server.Client())
+	app.subscriptions.SetImportConcurrency(3)
+	events, unsubscribe := app.Events().Subscribe()
+	defer unsubscribe()
+	ctx := context.Background()
+
+	var outlines strings.Builder
+	for i := range 10 {
+		fmt.Fprintf(&outlines, `<outline type="rss" text="Feed %d" xmlUrl="%s/feed%d"/>`+"\n", i, server.URL, i)
+	}
+	fmt.Fprintf(&outlines, `<outline type="rss" text="Broken B" xmlUrl="%s/broken-b"/>`+"\n", server.URL)
+	fmt.Fprintf(&outlines, `<outline type="rss" text="Again" xmlUrl="%s/feed0"/>`+"\n", server.URL)
+	fmt.Fprintf(&outlines, `<outline type="rss" text="Broken A" xmlUrl="%s/broken-a"/>`+"\n", server.URL)
+	opmlPath := filepath.Join(t.TempDir(), "import.opml")
+	if err := os.WriteFile(opmlPath, []byte("<opml version=\"2.0\"><body>\n"+outlines.String()+"</body></opml>"), 0o600); err != nil {
+		t.Fatalf("write OPML file: %v", err)
+	}
+
+	result, err := app.ImportOPML(ctx, opmlPath, "")
+	if err != nil {
+		t.Fatalf("ImportOPML error = %v", err)
+	}
+	// The second feed0 outline is merged into the first by the parser
+	if result.Imported != 10 || result.Skipped != 0 || len(result.Errors) != 2 {
+		t.Fatalf("unexpected import result %+v", result)
+	}
+	if result.Errors[0].Title != "Broken B" || result.Errors[1].Title != "Broken A" || result.Errors[1].FeedURL != server.URL+"/broken-a" {
+		t.Fatalf("expected failures in file order, got %+v", result.Errors)
+	}
+	if got := peak.Load(); got < 2 || got > 3 {
+		t.Fatalf("expected 2 to 3 feeds fetched at once, got %d", got)
+	}
+
+	var last domain.Event
+	updates := 0
+	for len(events) > 0 {
+		event := <-events
+		if event.Kind == domain.EventImportProgress {
+			last = event
+			updates++
+		}
+	}
+	// One event to start, then one per fetch
+	if updates != 13 || last.FeedsDone != 12 || last.FeedsTotal != 12 || last.Added != 10 || last.Failed != 2 {
+		t.Fatalf("unexpected import progress: %d updates, last %+v", updates, last)
+	}
+}
+
+func TestQueueProcessDownloadsWithoutWorkers(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "audio")
+	}))
+	t.Cleanup(server.Close)
+
+	app := newTestAppWithClient(t, server.Client())
+	ctx := context.Background()
+
+	if _, err := app.db.ExecContext(ctx, `INSERT INTO podcasts (id, title, feed_url, subscribed_at) VALUES (?, ?, ?, ?)`,
+		"pod1", "Example Podcast", server.URL+"/feed", time.Now().UTC()); err != nil {
+		t.Fatalf("insert podcast: %v", err)
+	}
+	if _, err := app.db.ExecContext(ctx, `INSERT INTO episodes (id, podcast_id, title, state, enclosure_url) VALUES (?, ?, ?, ?, ?)`,
+		"ep1", "pod1", "Episode One", "SEEN", server.URL+"/ep1.mp3"); err != nil {
+		t.Fatalf("insert episode: %v", err)
+	}
+
+	result, err := app.Execute(ctx, "queue ep1")
+	if err != nil {
+		t.Fatalf("Execute(queue) error = %v", err)
+	}
+	if !strings.Contains(result.Message, "queue process") {
+		t.Fatalf("expected hint about missing workers, got %s", result.Message)
+	}
+
+	result, err = app.Execute(ctx, "queue process")
+	if err != nil {
+		t.Fatalf("Execute(queue process) error = %v", err)
+	}
+	if result.Message != "Downloaded 1 episode(s)." {
+		t.Fatalf("unexpected message: %s", result.Message)
+	}
+	if state := episodeState(t, ctx, app.db, "ep1"); state != stateDownloaded {
+		t.Fatalf("expected DOWNLOADED, got %s", state)
+	}
+
+	result, err = app.Execute(ctx, "queue process")
+	if err != nil {
+		t.Fatalf("Execute(queue process) error = %v", err)
+	}
+	if result.Message != "Nothing waiting in the queue." {
+		t.Fatalf("unexpected message: %s", result.Message)
+	}
+}
+
+func TestPostProcessStepsRunAfterDownloadAndRetry(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "audio")
+	}))
+	t.Cleanup(server.Close)
+
+	outDir := t.TempDir()
+	marker := filepath.Join(outDir, "ready")
+	app := newTestAppWithConfig(t, server.Client(), func(cfg *config.Config) {
+		cfg.PostProcess = []config.PostProcessStep{
+			{Name: "copy", Kind: config.PostProcessCommand, Command: `cp "$PODSINK_FILE" "` + outDir + `/$PODSINK_EPISODE_ID.mp3"`},
+			{Name: "check", Kind: config.PostProcessCommand, Command: "test -e " + marker},
+		}
+	})
+	ctx := context.Background()
+
+	if _, err := app.db.ExecContext(ctx, `INSERT INTO podcasts (id, title, feed_url, subscribed_at) VALUES (?, ?, ?, ?)`,
+		"pod1", "Example Podcast", server.URL+"/feed", time.Now().UTC()); err != nil {
+		t.Fatalf("insert podcast: %v", err)
+	}
+	if _, err := app.db.ExecContext(ctx, `INSERT INTO episodes (id, podcast_id, title, state, enclosure_url) VALUES (?, ?, ?, ?, ?)`,
+		"ep1", "pod1", "Episode One", stateSeen, server.URL+"/ep1.mp3"); err != nil {
+		t.Fatalf("insert episode: %v", err)
+	}
+	if _, err := app.Execute(ctx, "queue ep1"); err != nil {
+		t.Fatalf("Execute(queue) error = %v", err)
+	}
+
+	run := func(command string) string {
+		t.Helper()
+		result, err := app.Execute(ctx, command)
+		if err != nil {
+			t.Fatalf("Execute(%s) error = %v", command, err)
+		}
+		return result.Message
+	}
+
+	if msg := run("queue process"); msg != "Downloaded 1 episode(s)." {
+		t.Fatalf("a failed step must not fail the download, got %s", msg)
+	}
+	if state := episodeState(t, ctx, app.db, "ep1"); state != stateDownloaded {
+		t.Fatalf("expected DOWNLOADED, got %s", state)
+	}
+	if data, err := os.ReadFile(filepath.Join(outDir, "ep1.mp3")); err != nil || string(data) != "audio" {
+		t.Fatalf("expected the copy step to run: %q, %v", data, err)
+	}
+	if msg := run("postprocess"); !strings.HasPrefix(msg, "1 failed post-processing step(s):") || !strings.Contains(msg, "ep1  Example Podcast - Episode One  check: exit status 1") {
+		t.Fatalf("unexpected failed step listing: %s", msg)
+	}
+	if msg := run("postprocess retry ep1"); !strings.HasPrefix(msg, "Post-processing failed: step check") {
+		t.Fatalf("unexpected retry result: %s", msg)
+	}
+
+	// The retry runs only the failed step, not the copy again
+	if err := os.Remove(filepath.Join(outDir, "ep1.mp3")); err != nil {
+		t.Fatalf("remove copy: %v", err)
+	}
+	if err := os.WriteFile(marker, nil, 0o644); err != nil {
+		t.Fatalf("write marker: %v", err)
+	}
+	if msg := run("postprocess retry all"); msg != "Post-processed 1 of 1 episode(s)." {
+		t.Fatalf("unexpected retry all result: %s", msg)
+	}
+	if _, err := os.Stat(filepath.Join(outDir, "ep1.mp3")); !os.IsNotExist(err) {
+		t.Fatalf("expected completed steps to be skipped, stat error = %v", err)
+	}
+	if msg := run("postprocess"); msg != "No failed post-processing steps." {
+		t.Fatalf("unexpected listing after retry: %s", msg)
+	}
+	if msg := run("postprocess retry ep1"); msg != "Nothing to post-process for that episode." {
+		t.Fatalf("unexpected second retry result: %s", msg)
+	}
+	if msg := run("postprocess retry missing"); msg != "Episode not found." {
+		t.Fatalf("unexpected unknown episode result: %s", msg)
+	}
+}
+
+func TestTranscodeReplacesDownloadAndKeepsOriginal(t *testing.T) {
+	// A stand-in for ffmpeg that appends a marker to the input
+	bin := t.TempDir()
+	script := "#!/bin/sh\nwhile [ \"$1\" != -i ]; do shift; done\nin=$2\nfor out; do :; done\n{ cat \"$in\"; printf ' as opus'; } > \"$out\"\n"
+	if err := os.WriteFile(filepath.Join(bin, "ffmpeg"), []byte(script), 0o755); err != nil {
+		t.Fatalf("write ffmpeg: %v", err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "audio")
+	}))
+	t.Cleanup(server.Close)
+
+	app := newTestAppWithConfig(t, server.Client(), func(cfg *config.Config) {
+		cfg.TranscodeFormat = config.TranscodeOpus
+		cfg.TranscodeBitrate = "48k"
+		cfg.TranscodeKeepOriginal = true
+	})
+	ctx := context.Background()
+
+	if _, err := app.db.ExecContext(ctx, `INSERT INTO podcasts (id, title, feed_url, subscribed_at) VALUES (?, ?, ?, ?)`,
+		"pod1", "Example Podcast", server.URL+"/feed", time.Now().UTC()); err != nil {
+		t.Fatalf("insert podcast: %v", err)
+	}
+	if _, err := app.db.ExecContext(ctx, `INSERT INTO episodes (id, podcast_id, title, state, enclosure_url, size_bytes) VALUES (?, ?, ?, ?, ?, ?)`,
+		"ep1", "pod1", "Episode One", stateSeen, server.URL+"/ep1.mp3", 5); err != nil {
+		t.Fatalf("insert episode: %v", err)
+	}
+	if _, err := app.Execute(ctx, "queue ep1"); err != nil {
+		t.Fatalf("Execute(queue) error = %v", err)
+	}
+	if result, err := app.Execute(ctx, "queue process"); err != nil || result.Message != "Downloaded 1 episode(s)." {
+		t.Fatalf("unexpected queue process result: %q, %v", result.Message, err)
+	}
+
+	var filePath string
+	var size int64
+	if err := app.db.QueryRowContext(ctx, "SELECT file_path, size_bytes FROM episodes WHERE id = ?", "ep1").Scan(&filePath, &size); err != nil {
+		t.Fatalf("query episode: %v", err)
+	}
+	if filepath.Ext(filePath) != ".opus" || size != int64(len("audio as opus")) {
+		t.Fatalf("expected the transcoded file to be recorded, got %s of %d bytes", filePath, size)
+	}
+	if data, err := os.ReadFile(filePath); err != nil || string(data) != "audio as opus" {
+		t.Fatalf("unexpected transcoded file: %q, %v", data, err)
+	}
+	original := strings.TrimSuffix(filePath, ".opus") + ".mp3"
+	if _, err := os.Stat(original); !os.IsNotExist(err) {
+		t.Fatalf("expected the original to leave the podcast folder, stat error = %v", err)
+	}
+	rel, err := filepath.Rel(app.config.DownloadRoot, original)
+	if err != nil {
+		t.Fatalf("rel: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(config.OriginalsDir(app.config.DownloadRoot), rel)); err != nil || string(data) != "audio" {
+		t.Fatalf("expected the original to be kept: %q, %v", data, err)
+	}
+	if result, err := app.Execute(ctx, "dangling"); err != nil || result.Message != "No dangling files." {
+		t.Fatalf("kept originals must not be dangling: %q, %v", result.Message, err)
+	}
+}
+
+func TestQueuePauseResumeCancel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "audio")
+	}))
+	t.Cleanup(server.Close)
+
+	app := newTestAppWithClient(t, server.Client())
+	ctx := context.Background()
+
+	if _, err := app.db.ExecContext(ctx, `INSERT INTO podcasts (id, title, feed_url, subscribed_at) VALUES (?, ?, ?, ?)`,
+		"pod1", "Example Podcast", server.URL+"/feed", time.Now().UTC()); err != nil {
+		t.Fatalf("insert podcast: %v", err)
+	}
+	for _, id := range []string{"ep1", "ep2"} {
+		if _, err := app.db.ExecContext(ctx, `INSERT INTO episodes (id, podcast_id, title, state, enclosure_url) VALUES (?, ?, ?, ?, ?)`,
+			id, "pod1", "Episode "+id, "SEEN", server.URL+"/"+id+".mp3"); err != nil {
+			t.Fatalf("insert episode: %v", err)
+		}
+		if _, err := app.Execute(ctx, "queue "+id); err != nil {
+			t.Fatalf("Execute(queue %s) error = %v", id, err)
+		}
+	}
+
+	run := func(command string) string {
+		t.Helper()
+		result, err := app.Execute(ctx, command)
+		if err != nil {
+			t.Fatalf("Execute(%s) error = %v", command, err)
+		}
+		return result.Message
+	}
+
+	if msg := run("queue pause ep1"); msg != "Download of ep1 paused." {
+		t.Fatalf("unexpected pause message: %s", msg)
+	}
+	if msg := run("queue pause ep1"); msg != "Download is already paused." {
+		t.Fatalf("unexpected second pause message: %s", msg)
+	}
+	result, err := app.Execute(ctx, "queue")
+	if err != nil {
+		t.Fatalf("Execute(queue) error = %v", err)
+	}
+	for _, queued := range result.QueuedEpisodeResults {
+		if paused := queued.Episode.ID == "ep1"; queued.Paused != paused {
+			t.Fatalf("expected Paused=%v for %s", paused, queued.Episode.ID)
+		}
+	}
+
+	if msg := run("queue cancel ep2"); msg != "Download of ep2 cancelled." {
+		t.Fatalf("unexpected cancel message: %s", msg)
+	}
+	if state := episodeState(t, ctx, app.db, "ep2"); state != stateSeen {
+		t.Fatalf("expected cancelled episode to be SEEN, got %s", state)
+	}
+	if msg := run("queue process"); msg != "Nothing waiting in the queue." {
+		t.Fatalf("expected paused episode to be skipped, got %s", msg)
+	}
+	if state := episodeState(t, ctx, app.db, "ep1"); state != stateQueued {
+		t.Fatalf("expected paused episode to stay QUEUED, got %s", state)
+	}
+
+	if msg := run("queue resume ep1"); !strings.HasPrefix(msg, "Download of ep1 resumed.") {
+		t.Fatalf("unexpected resume message: %s", msg)
+	}
+	if msg := run("queue process"); msg != "Downloaded 1 episode(s)." {
+		t.Fatalf("unexpected message: %s", msg)
+	}
+	if msg := run("queue cancel ep1"); msg != "Episode is not in the download queue." {
+		t.Fatalf("unexpected cancel message: %s", msg)
+	}
+}
+
+func TestQueuePriorityOrdersDownloads(t *testing.T) {
+	var mu sync.Mutex
+	var requested []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requested = append(requested, strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/"), ".mp3"))
+		mu.Unlock()
+		fmt.Fprint(w, "audio")
+	}))
+	t.Cleanup(server.Close)
+
+	app := newTestAppWithClient(t, server.Client())
+	ctx := context.Background()
+
+	if _, err := app.db.ExecContext(ctx, `INSERT INTO podcasts (id, title, feed_url, subscribed_at) VALUES (?, ?, ?, ?)`,
+		"pod1", "Example Podcast", server.URL+"/feed", time.Now().UTC()); err != nil {
+		t.Fatalf("insert podcast: %v", err)
+	}
+	for _, id := range []string{"ep1", "ep2", "ep3"} {
+		if _, err := app.db.ExecContext(ctx, `INSERT INTO episodes (id, podcast_id, title, state, enclosure_url) VALUES (?, ?, ?, ?, ?)`,
+			id, "pod1", "Episode "+id, "SEEN", server.URL+"/"+id+".mp3"); err != nil {
+			t.Fatalf("insert episode: %v", err)
+		}
+	}
+
+	run := func(command string) string {
+		t.Helper()
+		result, err := app.Execute(ctx, command)
+		if err != nil {
+			t.Fatalf("Execute(%s) error = %v", command, err)
+		}
+		return result.Message
+	}
+	run("queue ep1")
+	if msg := run("queue ep2 --priority high"); !strings.HasPrefix(msg, "Episode ep2 queued for download with high priority.") {
+		t.Fatalf("unexpected queue message: %s", msg)
+	}
+	run("queue ep3 --priority=low")
+	if msg := run("queue ep1 --priority urgent"); !strings.HasPrefix(msg, "Usage: queue") {
+		t.Fatalf("expected usage for an unknown priority, got %s", msg)
+	}
+	if msg := run("queue ep3 --priority high"); msg != "Episode ep3 now has high priority." {
+		t.Fatalf("unexpected priority message: %s", msg)
+	}
+
+	result, err := app.Execute(ctx, "queue")
+	if err != nil {
+		t.Fatalf("Execute(queue) error = %v", err)
+	}
+	var listed []string
+	for _, queued := range result.QueuedEpisodeResults {
+		listed = append(listed, queued.Episode.ID+"="+domain.PriorityName(queued.Priority))
+	}
+	if got := strings.Join(listed, " "); got != "ep2=high ep3=high ep1=normal" {
+		t.Fatalf("unexpected queue order: %s", got)
+	}
+
+	run("queue process")
+	mu.Lock()
+	defer mu.Unlock()
+	if got := strings.Join(requested, " "); got != "ep2 ep3 ep1" {
+		t.Fatalf("downloads claimed in order %s, want ep2 ep3 ep1", got)
+	}
+}
+
+func TestReadOnlyAppRefusesChanges(t *testing.T) {
+	owner := newTestApp(t)
+	ctx := context.Background()
+
+	if _, err := owner.db.ExecContext(ctx, `INSERT INTO podcasts (id, title, feed_url, subscribed_at) VALUES (?, ?, ?, ?)`,
+		"pod1", "Example Podcast", "http://example.com/feed", time.Now().UTC()); err != nil {
+		t.Fatalf("insert podcast: %v", err)
+	}
+	if _, err := owner.db.ExecContext(ctx, `INSERT INTO episodes (id, podcast_id, title, state, enclosure_url) VALUES (?, ?, ?, ?, ?)`,
+		"ep1", "pod1", "Episode 1", stateNew, "http://example.com/ep1.mp3"); err != nil {
+		t.Fatalf("insert episode: %v", err)
+	}
+
+	db, err := storage.OpenReadOnly(filepath.Join(filepath.Dir(owner.configPath), "app.db"))
+	if err != nil {
+		t.Fatalf("storage.OpenReadOnly() error = %v", err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+	})
+	reader := NewWithDependencies(ctx, owner.config, owner.configPath, db, Dependencies{ReadOnly: true})
+	t.Cleanup(func() {
+		reader.Close()
+	})
+	if err := reader.Initialize(ctx); err != nil {
+		t.Fatalf("Initialize() error = %v", err)
+	}
+
+	for _, command := range []string{"list subscriptions", "episodes", "queue", "downloads", "query \"state = new\"", "history"} {
+		if _, err := reader.Execute(ctx, command); err != nil {
+			t.Fatalf("Execute(%s) error = %v", command, err)
+		}
+	}
+	if state := episodeState(t, ctx, owner.db, "ep1"); state != stateNew {
+		t.Fatalf("expected browsing to leave the episode NEW, got %s", state)
+	}
+	for _, command := range []string{"queue ep1", "ignore ep1", "unsubscribe pod1", "refresh", "dangling clean"} {
+		if _, err := reader.Execute(ctx, command); !errors.Is(err, ErrReadOnly) {
+			t.Fatalf("Execute(%s) error = %v, want ErrReadOnly", command, err)
+		}
+	}
+	if _, err := reader.QueueEpisodes(ctx, []string{"ep1"}); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("QueueEpisodes error = %v, want ErrReadOnly", err)
+	}
+	if _, err := reader.db.ExecContext(ctx, `UPDATE episodes SET state = ?`, stateSeen); err == nil {
+		t.Fatalf("expected the read-only database to refuse writes")
+	}
+}
+
+func TestRedownloadKeepsPreviousFileUntilVerified(t *testing.T) {
+	var failing atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, "audio")
+	}))
+	t.Cleanup(server.Close)
+
+	app := newTestAppWithClient(t, server.Client())
+	ctx := context.Background()
+
+	if _, err := app.db.ExecContext(ctx, `INSERT INTO podcasts (id, title, feed_url, subscribed_at) VALUES (?, ?, ?, ?)`,
+		"pod1", "Example Podcast", server.URL+"/feed", time.Now().UTC()); err != nil {
+		t.Fatalf("insert podcast: %v", err)
+	}
+	if _, err := app.db.ExecContext(ctx, `INSERT INTO episodes (id, podcast_id, title, state, enclosure_url) VALUES (?, ?, ?, ?, ?)`,
+		"ep1", "pod1", "Episode One", "SEEN", server.URL+"/ep1.mp3"); err != nil {
+		t.Fatalf("insert episode: %v", err)
+	}
+	redownload := func() CommandResult {
+		t.Helper()
+		if _, err := app.Execute(ctx, "queue ep1"); err != nil {
+			t.Fatalf("Execute(queue) error = %v", err)
+		}
+		result, err := app.Execute(ctx, "queue process")
+		if err != nil {
+			t.Fatalf("Execute(queue process) error = %v", err)
+		}
+		return result
+	}
+	redownload()
+	var filePath string
+	if err := app.db.QueryRowContext(ctx, "SELECT file_path FROM episodes WHERE id = ?", "ep1").Scan(&filePath); err != nil {
+		t.Fatalf("query file_path: %v", err)
+	}
+
+	// An intact file is kept and the episode returns to DOWNLOADED.
+	failing.Store(true)
+	if result := redownload(); result.Message != "Downloaded 1 episode(s)." {
+		t.Fatalf("unexpected message for intact file: %s", result.Message)
+	}
+	if state := episodeState(t, ctx, app.db, "ep1"); state != stateDownloaded {
+		t.Fatalf("expected DOWNLOADED, got %s", state)
+	}
+
+	// A verified download replaces a damaged file without leaving a backup
+	// behind.
+	if err := os.WriteFile(filePath, []byte("damaged"), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	failing.Store(false)
+	if result := redownload(); result.Message != "Downloaded 1 episode(s)." {
+		t.Fatalf("unexpected message for re-download: %s", result.Message)
+	}
+	if data, err := os.ReadFile(filePath); err != nil || string(data) != "audio" {
+		t.Fatalf("expected replaced file, got %q, %v", data, err)
+	}
+	if state := episodeState(t, ctx, app.db, "ep1"); state != stateDownloaded {
+		t.Fatalf("expected DOWNLOADED, got %s", state)
+	}
+
+	// A damaged file survives a failed re-download.
+	if err := os.WriteFile(filePath, []byte("damaged"), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	failing.Store(true)
+	if result := redownload(); result.Message != "Downloaded 0 episode(s), 1 failed." {
+		t.Fatalf("unexpected message for failed re-download: %s", result.Message)
+	}
+	if data, err := os.ReadFile(filePath); err != nil || string(data) != "damaged" {
+		t.Fatalf("expected previous file to survive, got %q, %v", data, err)
+	}
+	entries, err := os.ReadDir(filepath.Dir(filePath))
+	if err != nil {
+		t.Fatalf("read dir: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected only the episode file to remain, got %d entries", len(entries))
+	}
+}
+
+func TestSyncCommandExchangesWithGpodderServer(t *testing.T) {
+	var (
+		mu            sync.Mutex
+		pushedSubs    map[string][]string
+		pushedActions []map[string]interface{}
+		actionsSince  []string
+	)
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/api/") {
+			if user, pass, ok := r.BasicAuth(); !ok || user != "alice" || pass != "secret" {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+		}
+		first := r.URL.Query().Get("since") == "0"
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.URL.Path == "/feed/local" || r.URL.Path == "/feed/remote":
+			name := strings.TrimPrefix(r.URL.Path, "/feed/")
+			w.Header().Set("Content-Type", "application/rss+xml")
+			fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><rss version="2.0"><channel><title>%s podcast</title><item><guid>%s-1</guid><title>Episode</title><enclosure url="%s/audio/%s-1.mp3" length="100" type="audio/mpeg" /></item></channel></rss>`, name, name, srv.URL, name)
+		case r.URL.Path == "/api/2/subscriptions/alice/podsink.json" && r.Method == http.MethodGet:
+			if first {
+				fmt.Fprintf(w, `{"add":["%s/feed/remote"],"remove":[],"timestamp":10}`, srv.URL)
+				return
+			}
+			fmt.Fprint(w, `{"add":[],"remove":[],"timestamp":12}`)
+		case r.URL.Path == "/api/2/subscriptions/alice/podsink.json" && r.Method == http.MethodPost:
+			json.NewDecoder(r.Body).Decode(&pushedSubs)
+			fmt.Fprint(w, `{"timestamp":11,"update_urls":[]}`)
+		case r.URL.Path == "/api/2/episodes/alice.json" && r.Method == http.MethodGet:
+			actionsSince = append(actionsSince, r.URL.Query().Get("since"))
+			if first {
+				fmt.Fprintf(w, `{"actions":[{"podcast":"%s/feed/remote","episode":"%s/audio/remote-1.mp3","device":"phone","action":"play"}],"timestamp":20}`, srv.URL, srv.URL)
+				return
+			}
+			fmt.Fprint(w, `{"actions":[],"timestamp":22}`)
+		case r.URL.Path == "/api/2/episodes/alice.json" && r.Method == http.MethodPost:
+			json.NewDecoder(r.Body).Decode(&pushedActions)
+			fmt.Fprint(w, `{"timestamp":21,"update_urls":[]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	app := newTestAppWithClient(t, srv.Client())
+	ctx := context.Background()
+
+	result, err := app.Execute(ctx, "sync")
+	if err != nil {
+		t.Fatalf("Execute(sync) error = %v", err)
+	}
+	if !strings.Contains(result.Message, "not configured") {
+		t.Fatalf("expected configuration hint, got %s", result.Message)
+	}
+	app.config.GpodderServer = srv.URL
+	app.config.GpodderUsername = "alice"
+	app.config.GpodderPassword = "secret"
+
+	if _, err := app.db.ExecContext(ctx, `INSERT INTO podcasts (id, title, feed_url, subscribed_at) VALUES (?, ?, ?, ?)`,
+		"pod1", "Local Podcast", srv.URL+"/feed/local", time.Now().UTC()); err != nil {
+		t.Fatalf("insert podcast: %v", err)
+	}
+	if _, err := app.db.ExecContext(ctx, `INSERT INTO episodes (id, podcast_id, title, state, enclosure_url, downloaded_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		"ep1", "pod1", "Episode One", stateDownloaded, srv.URL+"/audio/local-1.mp3", time.Now().UTC().Format(time.RFC3339Nano)); err != nil {
+		t.Fatalf("insert episode: %v", err)
+	}
+	if _, err := app.db.ExecContext(ctx, `INSERT INTO episodes (id, podcast_id, title, state, enclosure_url, position_seconds, duration_seconds) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		"ep2", "pod1", "Episode Two", stateSeen, srv.URL+"/audio/local-2.mp3", 90, 600); err != nil {
+		t.Fatalf("insert episode: %v", err)
+	}
+
+	result, err = app.Execute(ctx, "sync")
+	if err != nil {
+		t.Fatalf("Execute(sync) error = %v", err)
+	}
+	want := "Synced as podsink: 1 subscription(s) added, 1 change(s) uploaded, 1 download(s) and 1 play position(s) reported, 1 episode(s) marked seen."
+	if result.Message != want {
+		t.Fatalf("unexpected sync message: %s", result.Message)
+	}
+
+	mu.Lock()
+	if adds := pushedSubs["add"]; len(adds) != 1 || adds[0] != srv.URL+"/feed/local" {
+		t.Fatalf("expected local feed to be uploaded, got %v", pushedSubs)
+	}
+	if len(pushedActions) != 2 || pushedActions[0]["action"] != "download" || pushedActions[0]["episode"] != srv.URL+"/audio/local-1.mp3" {
+		t.Fatalf("expected download action for local episode, got %v", pushedActions)
+	}
+	if play := pushedActions[1]; play["action"] != "play" || play["episode"] != srv.URL+"/audio/local-2.mp3" || play["position"] != float64(90) || play["total"] != float64(600) {
+		t.Fatalf("expected play action with position for started episode, got %v", play)
+	}
+	mu.Unlock()
+
+	var state string
+	if err := app.db.QueryRowContext(ctx, "SELECT state FROM episodes WHERE enclosure_url = ?", srv.URL+"/audio/remote-1.mp3").Scan(&state); err != nil {
+		t.Fatalf("query remote episode: %v", err)
+	}
+	if state != stateSeen {
+		t.Fatalf("expected remotely played episode to be SEEN, got %s", state)
+	}
+
+	result, err = app.Execute(ctx, "sync")
+	if err != nil {
+		t.Fatalf("Execute(sync) error = %v", err)
+	}
+	if result.Message != "Synced as podsink: 0 subscription(s) added, 0 change(s) uploaded, 0 download(s) and 0 play position(s) reported, 0 episode(s) marked seen." {
+		t.Fatalf("expected nothing to sync the second time, got %s", result.Message)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	// The pull's timestamp is the cursor, not the upload's
+	if len(actionsSince) != 2 || actionsSince[1] != "20" {
+		t.Fatalf("expected second pull since 20, got %v", actionsSince)
+	}
+}
+
+func TestMaxEpisodeSizeSkipsLargeDownloads(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.URL.Path == "/ep2.mp3" {
+			// Chunked, without a length to check up front
+			for i := 0; i < 4; i++ {
+				w.Write(make([]byte, 512<<10))
+				w.(http.Flusher).Flush()
+			}
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(2<<20))
+		w.Write(make([]byte, 2<<20))
+	}))
+	t.Cleanup(server.Close)
+
+	app := newTestAppWithConfig(t, server.Client(), func(cfg *config.Config) {
+		cfg.MaxEpisodeSizeMB = 1
+	})
+	ctx := context.Background()
+
+	if _, err := app.db.ExecContext(ctx, `INSERT INTO podcasts (id, title, feed_url, subscribed_at) VALUES (?, ?, ?, ?)`,
+		"pod1", "Example Podcast", server.URL+"/feed", time.Now().UTC()); err != nil {
+		t.Fatalf("insert podcast: %v", err)
+	}
+	if _, err := app.db.ExecContext(ctx, `INSERT INTO episodes (id, podcast_id, title, state, enclosure_url) VALUES (?, ?, ?, ?, ?)`,
+		"ep1", "pod1", "Huge Episode", "SEEN", server.URL+"/ep1.mp4"); err != nil {
+		t.Fatalf("insert episode: %v", err)
+	}
+	if _, err := app.db.ExecContext(ctx, `INSERT INTO episodes (id, podcast_id, title, state, enclosure_url) VALUES (?, ?, ?, ?, ?)`,
+		"ep2", "pod1", "Streamed Episode", "SEEN", server.URL+"/ep2.mp3"); err != nil {
+		t.Fatalf("insert episode: %v", err)
+	}
+
+	for _, id := range []string{"ep1", "ep2"} {
+		if _, err := app.Execute(ctx, "queue "+id); err != nil {
+			t.Fatalf("Execute(queue) error = %v", err)
+		}
+	}
+	result, err := app.Execute(ctx, "queue process")
+	if err != nil {
+		t.Fatalf("Execute(queue process) error = %v", err)
+	}
+	if result.Message != "Downloaded 0 episode(s), 2 skipped as too large." {
+		t.Fatalf("unexpected message: %s", result.Message)
+	}
+	for _, id := range []string{"ep1", "ep2"} {
+		if state := episodeState(t, ctx, app.db, id); state != domain.EpisodeStateSkipped {
+			t.Fatalf("expected %s SKIPPED, got %s", id, state)
+		}
+	}
+	if got := requests.Load(); got != 2 {
+		t.Fatalf("expected a single request without retries, got %d", got)
+	}
+	if count, err := app.CountQueued(ctx); err != nil || count != 0 {
+		t.Fatalf("expected empty queue, got %d, %v", count, err)
+	}
+
+	result, err = app.Execute(ctx, "download ep1")
+	if err != nil {
+		t.Fatalf("Execute(download) error = %v", err)
+	}
+	if result.Message != "Skipped Huge Episode: episode too large: 2 MB exceeds max_episode_size_mb of 1 MB." {
+		t.Fatalf("unexpected message: %s", result.Message)
+	}
+
+	// An edited limit applies without a restart
+	cfg := app.config
+	cfg.MaxEpisodeSizeMB = 0
+	app.downloads.Configure(cfg)
+	result, err = app.Execute(ctx, "download ep2")
+	if err != nil {
+		t.Fatalf("Execute(download) error = %v", err)
+	}
+	if !strings.HasPrefix(result.Message, "Downloaded Streamed Episode") {
+		t.Fatalf("expected download without a limit, got %s", result.Message)
+	}
+}
+
+func TestMarkCommand(t *testing.T) {
+	app := newTestApp(t)
+	ctx := context.Background()
+
+	if _, err := app.db.ExecContext(ctx, `INSERT INTO podcasts (id, title, feed_url, subscribed_at) VALUES (?, ?, ?, ?)`,
+		"pod1", "Example Podcast", "http://example.com/feed", time.Now().UTC()); err != nil {
+		t.Fatalf("insert podcast: %v", err)
+	}
+	if _, err := app.db.ExecContext(ctx, `INSERT INTO episodes (id, podcast_id, title, state, enclosure_url) VALUES (?, ?, ?, ?, ?)`,
+		"ep1", "pod1", "Episode One", stateDownloaded, "http://example.com/ep1.mp3"); err != nil {
+		t.Fatalf("insert episode: %v", err)
+	}
+	if _, err := app.db.ExecContext(ctx, `INSERT INTO episodes (id, podcast_id, title, state, enclosure_url) VALUES (?, ?, ?, ?, ?)`,
+		"ep0", "pod1", "Episode Zero", stateNew, "http://example.com/ep0.mp3"); err != nil {
+		t.Fatalf("insert episode: %v", err)
+	}
+
+	for _, tc := range []struct {
+		input string
+		want  string
+	}{
+		{"mark ep1", "Usage: mark played|seen <episode_id>"},
+		{"mark played missing", "Episode not found."},
+		{"mark seen ep0", "Episode ep0 marked as seen."},
+		{"mark seen ep0", "Episode is already marked as seen."},
+		{"mark seen ep1", "Only new or ignored episodes can be marked as seen."},
+		{"mark played ep1", "Episode ep1 marked as played."},
+		{"mark played ep1", "Episode is already marked as played."},
+	} {
+		result, err := app.Execute(ctx, tc.input)
+		if err != nil {
+			t.Fatalf("Execute(%s) error = %v", tc.input, err)
+		}
+		if result.Message != tc.want {
+			t.Fatalf("Execute(%s) = %q, want %q", tc.input, result.Message, tc.want)
+		}
+	}
+	if state := episodeState(t, ctx, app.db, "ep1"); state != statePlayed {
+		t.Fatalf("expected PLAYED, got %s", state)
+	}
+}
+
+func TestDownloadRejectsHTMLErrorPage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/declared.mp3":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			fmt.Fprint(w, "Service unavailable")
+		case "/sniffed.mp3":
+			w.Header().Set("Content-Type", "audio/mpeg")
+			fmt.Fprint(w, "<!DOCTYPE html><html><body>Not found</body></html>")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	app := newTestAppWithConfig(t, server.Client(), func(cfg *config.Config) {
+		cfg.RetryCount = 1
+	})
+	ctx := context.Background()
+
+	if _, err := app.db.ExecContext(ctx, `INSERT INTO podcasts (id, title, feed_url, subscribed_at) VALUES (?, ?, ?, ?)`,
+		"pod1", "Example Podcast", server.URL+"/feed", time.Now().UTC()); err != nil {
+		t.Fatalf("insert podcast: %v", err)
+	}
+	for _, id := range []string{"declared", "sniffed"} {
+		if _, err := app.db.ExecContext(ctx, `INSERT INTO episodes (id, podcast_id, title, state, enclosure_url) VALUES (?, ?, ?, ?, ?)`,
+			id, "pod1", id, stateSeen, server.URL+"/"+id+".mp3"); err != nil {
+			t.Fatalf("insert episode: %v", err)
+		}
+		if _, err := app.Execute(ctx, "queue "+id); err != nil {
+			t.Fatalf("Execute(queue) error = %v", err)
+		}
+	}
+
+	result, err := app.Execute(ctx, "queue process")
+	if err != nil {
+		t.Fatalf("Execute(queue process) error = %v", err)
+	}
+	if result.Message != "Downloaded 0 episode(s), 2 failed." {
+		t.Fatalf("unexpected message: %s", result.Message)
+	}
+	for _, id := range []string{"declared", "sniffed"} {
+		if state := episodeState(t, ctx, app.db, id); state == stateDownloaded {
+			t.Fatalf("%s: HTML page was stored as a download", id)
+		}
+	}
+}
+
+func TestDownloadShorterThanContentLengthResumes(t *testing.T) {
+	// Long enough for the cut to come after the sniffed head
+	content := "ID3" + strings.Repeat(" complete episode audio", 100)
+	half := len(content) / 2
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		w.Header().Set("Content-Type", "audio/mpeg")
+		if r.Header.Get("Range") == "" {
+			// Announces the whole episode but is cut off halfway
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			w.Write([]byte(content[:half]))
+			return
+		}
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", half, len(content)-1, len(content)))
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write([]byte(content[half:]))
+	}))
+	t.Cleanup(server.Close)
+
+	app := newTestAppWithConfig(t, server.Client(), func(cfg *config.Config) {
+		cfg.RetryCount = 1
+	})
+	ctx := context.Background()
+
+	if _, err := app.db.ExecContext(ctx, `INSERT INTO podcasts (id, title, feed_url, subscribed_at) VALUES (?, ?, ?, ?)`,
+		"pod1", "Example Podcast", server.URL+"/feed", time.Now().UTC()); err != nil {
+		t.Fatalf("insert podcast: %v", err)
+	}
+	if _, err := app.db.ExecContext(ctx, `INSERT INTO episodes (id, podcast_id, title, state, enclosure_url) VALUES (?, ?, ?, ?, ?)`,
+		"ep1", "pod1", "Episode One", stateSeen, server.URL+"/ep1.mp3"); err != nil {
+		t.Fatalf("insert episode: %v", err)
+	}
+
+	if _, err := app.Execute(ctx, "queue ep1"); err != nil {
+		t.Fatalf("Execute(queue) error = %v", err)
+	}
+	result, err := app.Execute(ctx, "queue process")
+	if err != nil {
+		t.Fatalf("Execute(queue process) error = %v", err)
+	}
+	if result.Message != "Downloaded 1 episode(s)." {
+		t.Fatalf("unexpected message %q", result.Message)
+	}
+	if len(ranges) != 2 || ranges[1] != fmt.Sprintf("bytes=%d-", half) {
+		t.Fatalf("expected the second attempt to resume, got ranges %q", ranges)
+	}
+	var path string
+	if err := app.db.QueryRowContext(ctx, `SELECT file_path FROM episodes WHERE id = ?`, "ep1").Scan(&path); err != nil {
+		t.Fatalf("query file path: %v", err)
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != content {
+		t.Fatalf("unexpected file contents %q, %v", data, err)
+	}
+}
+
+func TestDownloadEmbedsTagsOnceStored(t *testing.T) {
+	audio := append([]byte{0xFF, 0xFB, 0x90, 0x00}, strings.Repeat("audio", 300)...)
+	half := len(audio) / 2
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		w.Header().Set("Content-Type", "audio/mpeg")
+		if r.Header.Get("Range") == "" {
+			w.Header().Set("Content-Length", strconv.Itoa(len(audio)))
+			w.Write(audio[:half])
+			return
+		}
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", half, len(audio)-1, len(audio)))
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write(audio[half:])
+	}))
+	t.Cleanup(server.Close)
+
+	app := newTestAppWithConfig(t, server.Client(), func(cfg *config.Config) {
+		cfg.RetryCount = 1
+		cfg.EmbedTags = true
+	})
+	ctx := context.Background()
+
+	if _, err := app.db.ExecContext(ctx, `INSERT INTO podcasts (id, title, feed_url, subscribed_at) VALUES (?, ?, ?, ?)`,
+		"pod1", "Example Podcast", server.URL+"/feed", time.Now().UTC()); err != nil {
+		t.Fatalf("insert podcast: %v", err)
+	}
+	if _, err := app.db.ExecContext(ctx, `INSERT INTO episodes (id, podcast_id, title, state, enclosure_url) VALUES (?, ?, ?, ?, ?)`,
+		"ep1", "pod1", "Tagged Episode", stateSeen, server.URL+"/ep1.mp3"); err != nil {
+		t.Fatalf("insert episode: %v", err)
+	}
+
+	if _, err := app.Execute(ctx, "queue ep1"); err != nil {
+		t.Fatalf("Execute(queue) error = %v", err)
+	}
+	result, err := app.Execute(ctx, "queue process")
+	if err != nil {
+		t.Fatalf("Execute(queue process) error = %v", err)
+	}
+	if result.Message != "Downloaded 1 episode(s)." {
+		t.Fatalf("unexpected message %q", result.Message)
+	}
+	if len(ranges) != 2 || ranges[1] != fmt.Sprintf("bytes=%d-", half) {
+		t.Fatalf("expected the untagged partial to resume, got ranges %q", ranges)
+	}
+	var path, hash string
+	if err := app.db.QueryRowContext(ctx, `SELECT file_path, hash FROM episodes WHERE id = ?`, "ep1").Scan(&path, &hash); err != nil {
+		t.Fatalf("query download: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read download: %v", err)
+	}
+	if !bytes.HasPrefix(data, []byte("ID3")) || !bytes.Contains(data, []byte("Tagged Episode")) || !bytes.HasSuffix(data, audio) {
+		t.Fatalf("download was not tagged around the full audio: %q", data)
+	}
+	if sum := sha256.Sum256(data); hash != hex.EncodeToString(sum[:]) {
+		t.Fatalf("recorded hash %s is not the tagged file's", hash)
+	}
+}
+
+func TestDownloadChunkedShorterThanEnclosureLengthSucceeds(t *testing.T) {
+	const content = "ID3 episode without the ads"
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Content-Type", "audio/mpeg")
+		// Streamed without a Content-Length
+		w.Write([]byte(content))
+		w.(http.Flusher).Flush()
+	}))
+	t.Cleanup(server.Close)
+
+	app := newTestAppWithConfig(t, server.Client(), func(cfg *config.Config) {
+		cfg.RetryCount = 1
+	})
+	ctx := context.Background()
+
+	if _, err := app.db.ExecContext(ctx, `INSERT INTO podcasts (id, title, feed_url, subscribed_at) VALUES (?, ?, ?, ?)`,
+		"pod1", "Example Podcast", server.URL+"/feed", time.Now().UTC()); err != nil {
+		t.Fatalf("insert podcast: %v", err)
+	}
+	// The feed still carries the length of the episode with its ads
+	if _, err := app.db.ExecContext(ctx, `INSERT INTO episodes (id, podcast_id, title, state, enclosure_url, size_bytes) VALUES (?, ?, ?, ?, ?, ?)`,
+		"ep1", "pod1", "Episode One", stateSeen, server.URL+"/ep1.mp3", 10*len(content)); err != nil {
+		t.Fatalf("insert episode: %v", err)
+	}
+
+	if _, err := app.Execute(ctx, "queue ep1"); err != nil {
+		t.Fatalf("Execute(queue) error = %v", err)
+	}
+	result, err := app.Execute(ctx, "queue process")
+	if err != nil {
+		t.Fatalf("Execute(queue process) error = %v", err)
+	}
+	if result.Message != "Downloaded 1 episode(s)." {
+		t.Fatalf("unexpected message %q", result.Message)
+	}
+	if got := requests.Load(); got != 1 {
+		t.Fatalf("expected a single request, got %d", got)
+	}
+	var path string
+	if err := app.db.QueryRowContext(ctx, `SELECT file_path FROM episodes WHERE id = ?`, "ep1").Scan(&path); err != nil {
+		t.Fatalf("query file path: %v", err)
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != content {
+		t.Fatalf("unexpected file contents %q, %v", data, err)
+	}
+}
+
+func TestURLRewritesRouteDownloadsToMirror(t *testing.T) {
+	var requested atomic.Value
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested.Store(r.URL.Path)
+		fmt.Fprint(w, "audio")
+	}))
+	t.Cleanup(server.Close)
+
+	app := newTestAppWithConfig(t, server.Client(), func(cfg *config.Config) {
+		cfg.URLRewrites = []config.URLRewrite{{Pattern: `^https://origin\.example\.com/(.*)$`, Replacement: server.URL + "/mirror/$1"}}
+	})
+	ctx := context.Background()
+
+	if _, err := app.db.ExecContext(ctx, `INSERT INTO podcasts (id, title, feed_url, subscribed_at) VALUES (?, ?, ?, ?)`,
+		"pod1", "Example Podcast", "https://origin.example.com/feed", time.Now().UTC()); err != nil {
+		t.Fatalf("insert podcast: %v", err)
+	}
+	if _, err := app.db.ExecContext(ctx, `INSERT INTO episodes (id, podcast_id, title, state, enclosure_url) VALUES (?, ?, ?, ?, ?)`,
+		"ep1", "pod1", "Episode One", stateSeen, "https://origin.example.com/audio/ep1.mp3"); err != nil {
+		t.Fatalf("insert episode: %v", err)
+	}
+
+	if _, err := app.Execute(ctx, "queue ep1"); err != nil {
+		t.Fatalf("Execute(queue) error = %v", err)
+	}
+	result, err := app.Execute(ctx, "queue process")
+	if err != nil {
+		t.Fatalf("Execute(queue process) error = %v", err)
+	}
+	if result.Message != "Downloaded 1 episode(s)." {
+		t.Fatalf("unexpected message: %s", result.Message)
+	}
+	if got, _ := requested.Load().(string); got != "/mirror/audio/ep1.mp3" {
+		t.Fatalf("expected rewritten request path, got %q", got)
+	}
+}
+
+func TestFilenameTemplateSeparatesEpisodesWithTheSameTitle(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "audio "+r.URL.Path)
+	}))
+	t.Cleanup(server.Close)
+
+	app := newTestAppWithConfig(t, server.Client(), func(cfg *config.Config) {
+		cfg.FilenameTemplate = "{podcast}/{date}/{number}-{title}-{guid}.{ext}"
+	})
+	ctx := context.Background()
+
+	if _, err := app.db.ExecContext(ctx, `INSERT INTO podcasts (id, title, feed_url, subscribed_at) VALUES (?, ?, ?, ?)`,
+		"pod1", "Example Podcast", server.URL+"/feed", time.Now().UTC()); err != nil {
+		t.Fatalf("insert podcast: %v", err)
+	}
+	if _, err := app.db.ExecContext(ctx, `INSERT INTO episodes (id, podcast_id, title, state, enclosure_url, published_at, episode_number) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		"ep1", "pod1", "Trailer", stateSeen, server.URL+"/ep1.m4a", "2024-03-09T12:00:00Z", 7); err != nil {
+		t.Fatalf("insert episode: %v", err)
+	}
+	if _, err := app.db.ExecContext(ctx, `INSERT INTO episodes (id, podcast_id, title, state, enclosure_url) VALUES (?, ?, ?, ?, ?)`,
+		"ep2", "pod1", "Trailer", stateSeen, server.URL+"/ep2.mp3"); err != nil {
+		t.Fatalf("insert episode: %v", err)
+	}
+
+	for _, command := range []string{"queue ep1", "queue ep2", "queue process"} {
+		if _, err := app.Execute(ctx, command); err != nil {
+			t.Fatalf("Execute(%s) error = %v", command, err)
+		}
+	}
+	guid := func(id string) string {
+		sum := sha256.Sum256([]byte(id))
+		return hex.EncodeToString(sum[:4])
+	}
+	root := app.config.DownloadRoot
+	for id, want := range map[string]string{
+		"ep1": filepath.Join(root, "Example_Podcast", "2024-03-09", "7-Trailer-"+guid("ep1")+".m4a"),
+		"ep2": filepath.Join(root, "Example_Podcast", "Trailer-"+guid("ep2")+".mp3"),
+	} {
+		var path string
+		if err := app.db.QueryRowContext(ctx, `SELECT file_path FROM episodes WHERE id = ?`, id).Scan(&path); err != nil {
+			t.Fatalf("query %s: %v", id, err)
+		}
+		if path != want {
+			t.Fatalf("%s stored at %s, want %s", id, path, want)
+		}
+		if _, err := os.Stat(path); err != nil {
+			t.Fatalf("stat %s: %v", path, err)
+		}
+	}
+}
+
+func TestDownloadKeepsAnotherEpisodesFileAtTheSamePath(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "audio "+r.URL.Path)
+	}))
+	t.Cleanup(server.Close)
+
+	app := newTestAppWithConfig(t, server.Client(), nil)
+	ctx := context.Background()
+
+	if _, err := app.db.ExecContext(ctx, `INSERT INTO podcasts (id, title, feed_url, subscribed_at) VALUES (?, ?, ?, ?)`,
+		"pod1", "Example Podcast", server.URL+"/feed", time.Now().UTC()); err != nil {
+		t.Fatalf("insert podcast: %v", err)
+	}
+	for _, id := range []string{"ep1", "ep2"} {
+		if _, err := app.db.ExecContext(ctx, `INSERT INTO episodes (id, podcast_id, title, state, enclosure_url) VALUES (?, ?, ?, ?, ?)`,
+			id, "pod1", "Trailer", stateSeen, server.URL+"/"+id+".mp3"); err != nil {
+			t.Fatalf("insert episode: %v", err)
+		}
+	}
+
+	for _, command := range []string{"download ep1", "download ep2"} {
+		if _, err := app.Execute(ctx, command); err != nil {
+			t.Fatalf("Execute(%s) error = %v", command, err)
+		}
+	}
+	sum := sha256.Sum256([]byte("ep2"))
+	dir := filepath.Join(app.config.DownloadRoot, "Example_Podcast")
+	for id, want := range map[string]string{
+		"ep1": filepath.Join(dir, "Trailer.mp3"),
+		"ep2": filepath.Join(dir, "Trailer-"+hex.EncodeToString(sum[:4])+".mp3"),
+	} {
+		var path string
+		if err := app.db.QueryRowContext(ctx, `SELECT file_path FROM episodes WHERE id = ?`, id).Scan(&path); err != nil {
+			t.Fatalf("query %s: %v", id, err)
+		}
+		if path != want {
+			t.Fatalf("%s stored at %s, want %s", id, path, want)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil || string(data) != "audio /"+id+".mp3" {
+			t.Fatalf("%s file holds %q, %v", id, data, err)
+		}
+	}
+}
+
+func TestPodcastSetDirOverridesDownloadRoot(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "audio")
+	}))
+	t.Cleanup(server.Close)
+
+	app := newTestAppWithConfig(t, server.Client(), func(cfg *config.Config) {
+		cfg.PruneEmptyDirs = true
+	})
+	ctx := context.Background()
+
+	if _, err := app.db.ExecContext(ctx, `INSERT INTO podcasts (id, title, feed_url, subscribed_at) VALUES (?, ?, ?, ?)`,
+		"pod1", "Music Cast", server.URL+"/feed", time.Now().UTC()); err != nil {
+		t.Fatalf("insert podcast: %v", err)
+	}
+	if _, err := app.db.ExecContext(ctx, `INSERT INTO episodes (id, podcast_id, title, state, enclosure_url) VALUES (?, ?, ?, ?, ?)`,
+		"ep1", "pod1", "Song", stateSeen, server.URL+"/ep1.mp3"); err != nil {
+		t.Fatalf("insert episode: %v", err)
+	}
+
+	if res, err := app.Execute(ctx, "podcast set-dir missing /tmp"); err != nil || res.Message != "No subscription found for that podcast." {
+		t.Fatalf("unexpected result for unknown podcast: %+v, %v", res, err)
+	}
+	dir := filepath.Join(t.TempDir(), "music")
+	if _, err := app.Execute(ctx, fmt.Sprintf("podcast set-dir pod1 %q", dir)); err != nil {
+		t.Fatalf("set-dir error = %v", err)
+	}
+	if _, err := os.Stat(dir); err != nil {
+		t.Fatalf("expected the directory to be created: %v", err)
+	}
+	for _, command := range []string{"queue ep1", "queue process"} {
+		if _, err := app.Execute(ctx, command); err != nil {
+			t.Fatalf("Execute(%s) error = %v", command, err)
+		}
+	}
+	var path string
+	if err := app.db.QueryRowContext(ctx, `SELECT file_path FROM episodes WHERE id = ?`, "ep1").Scan(&path); err != nil {
+		t.Fatalf("query episode: %v", err)
+	}
+	if want := filepath.Join(dir, "Music_Cast", "Song.mp3"); path != want {
+		t.Fatalf("episode stored at %s, want %s", path, want)
+	}
+
+	if _, err := app.Execute(ctx, "delete ep1"); err != nil {
+		t.Fatalf("delete error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "Music_Cast")); !os.IsNotExist(err) {
+		t.Fatalf("expected the empty podcast directory to be pruned, got %v", err)
+	}
+	if _, err := os.Stat(dir); err != nil {
+		t.Fatalf("expected the override directory itself to be kept: %v", err)
+	}
+
+	if _, err := app.Execute(ctx, "podcast set-dir pod1"); err != nil {
+		t.Fatalf("clear set-dir error = %v", err)
+	}
+	res, err := app.Execute(ctx, "list subscriptions")
+	if err != nil {
+		t.Fatalf("list error = %v", err)
+	}
+	if len(res.SearchResults) != 1 || res.SearchResults[0].DownloadDir != "" {
+		t.Fatalf("expected the override to be cleared, got %+v", res.SearchResults)
+	}
+}
+
+func TestRedirectPolicy(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/hop1.mp3":
+			http.Redirect(w, r, "/hop2.mp3", http.StatusFound)
+		case "/hop2.mp3":
+			http.Redirect(w, r, "/final.mp3", http.StatusFound)
+		case "/final.mp3":
+			fmt.Fprint(w, "audio")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	for _, tc := range []struct {
+		name      string
+		configure func(*config.Config)
+		want      string
+	}{
+		{"within limit", func(cfg *config.Config) { cfg.MaxRedirects = 2 }, "Downloaded Episode One"},
+		{"too many redirects", func(cfg *config.Config) { cfg.MaxRedirects = 1 }, "stopped after 1 redirects (max_redirects)"},
+		{"plaintext refused", func(cfg *config.Config) { cfg.AllowHTTP = false }, "plaintext HTTP is disabled (allow_http: false) and the HTTPS upgrade failed"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			app := newTestAppWithConfig(t, server.Client(), func(cfg *config.Config) {
+				cfg.RetryCount = 0
+				tc.configure(cfg)
+			})
+			ctx := context.Background()
+
+			if _, err := app.db.ExecContext(ctx, `INSERT INTO podcasts (id, title, feed_url, subscribed_at) VALUES (?, ?, ?, ?)`,
+				"pod1", "Example Podcast", server.URL+"/feed", time.Now().UTC()); err != nil {
+				t.Fatalf("insert podcast: %v", err)
+			}
+			if _, err := app.db.ExecContext(ctx, `INSERT INTO episodes (id, podcast_id, title, state, enclosure_url) VALUES (?, ?, ?, ?, ?)`,
+				"ep1", "pod1", "Episode One", stateSeen, server.URL+"/hop1.mp3"); err != nil {
+				t.Fatalf("insert episode: %v", err)
+			}
+
+			// Failed downloads surface as errors, successful ones as messages.
+			result, err := app.Execute(ctx, "download ep1")
+			got := result.Message
+			if err != nil {
+				got = err.Error()
+			}
+			if !strings.Contains(got, tc.want) {
+				t.Fatalf("expected %q in result, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestTLSConfigCAFileAndPins(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "feed")
+	}))
+	t.Cleanup(server.Close)
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(caFile, certPEM, 0o600); err != nil {
+		t.Fatalf("write ca file: %v", err)
+	}
+	digest := sha256.Sum256(server.Certificate().RawSubjectPublicKeyInfo)
+	goodPin := "sha256/" + base64.StdEncoding.EncodeToString(digest[:])
+	badPin := "sha256/" + base64.StdEncoding.EncodeToString(make([]byte, sha256.Size))
+
+	// Pins match the TLS server name, so dial the test server as example.com,
+	// one of the names its certificate is valid for.
+	dialer := &net.Dialer{}
+	get := func(cfg config.Config) error {
+		t.Helper()
+		client := &http.Client{Transport: &http.Transport{
+			TLSClientConfig: newTLSConfig(cfg),
+			DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return dialer.DialContext(ctx, network, server.Listener.Addr().String())
+			},
+		}}
+		resp, err := client.Get("https://example.com/feed")
+		if err != nil {
+			return err
+		}
+		return resp.Body.Close()
+	}
+
+	tests := []struct {
+		name    string
+		cfg     config.Config
+		wantErr bool
+	}{
+		{name: "unknown CA", cfg: config.Config{TLSVerify: true}, wantErr: true},
+		{name: "ca_file", cfg: config.Config{TLSVerify: true, CAFile: caFile}},
+		{name: "matching pin", cfg: config.Config{TLSVerify: true, CAFile: caFile, TLSPins: map[string][]string{"example.com": {goodPin}}}},
+		{name: "mismatched pin", cfg: config.Config{TLSVerify: true, CAFile: caFile, TLSPins: map[string][]string{"example.com": {badPin}}}, wantErr: true},
+		{name: "pin without verification", cfg: config.Config{TLSPins: map[string][]string{"example.com": {goodPin}}}},
+		{name: "pin for another host", cfg: config.Config{CAFile: caFile, TLSVerify: true, TLSPins: map[string][]string{"feeds.example.com": {badPin}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := get(tt.cfg)
+			if tt.wantErr && err == nil {
+				t.Fatal("expected TLS error")
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestWatchDownloadsMarksRemovedFilesDeleted(t *testing.T) {
+	app := newTestAppWithConfig(t, nil, func(cfg *config.Config) {
+		cfg.WatchDownloads = true
+	})
+	if app.watcher == nil {
+		t.Fatal("expected watch_downloads to start a watcher")
+	}
+	events, unsubscribe := app.Events().Subscribe()
+	defer unsubscribe()
+	ctx := context.Background()
+
+	file := filepath.Join(app.config.DownloadRoot, "Example Podcast", "ep1.mp3")
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(file, []byte("audio"), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	if _, err := app.db.ExecContext(ctx, `INSERT INTO podcasts (id, title, feed_url, subscribed_at) VALUES (?, ?, ?, ?)`,
+		"pod1", "Example Podcast", "https://example.com/feed", time.Now().UTC()); err != nil {
+		t.Fatalf("insert podcast: %v", err)
+	}
+	if _, err := app.db.ExecContext(ctx, `INSERT INTO episodes (id, podcast_id, title, state, file_path, enclosure_url) VALUES (?, ?, ?, ?, ?, ?)`,
+		"ep1", "pod1", "Episode One", stateDownloaded, file, "https://example.com/ep1.mp3"); err != nil {
+		t.Fatalf("insert episode: %v", err)
+	}
+
+	if err := os.Remove(file); err != nil {
+		t.Fatalf("remove file: %v", err)
+	}
+	var deleted bool
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case event := <-events:
+			if event.Kind == EventEpisodeState && event.EpisodeID == "ep1" && event.State == stateDeleted {
+				deleted = true
+			}
+			if event.Kind != EventFilesChanged {
+				continue
+			}
+		case <-timeout:
+			t.Fatal("no files_changed event after removing a download")
+		}
+		break
+	}
+	if !deleted {
+		t.Fatal("expected an episode state event for the removed download")
+	}
+	if state := episodeState(t, ctx, app.db, "ep1"); state != stateDeleted {
+		t.Fatalf("expected DELETED, got %s", state)
+	}
+}
+
+func TestDeletedFileCheckRereadsOnlyChangedFolders(t *testing.T) {
+	app := newTestAppWithClient(t, nil)
+	events, unsubscribe := app.Events().Subscribe()
+	defer unsubscribe()
+	ctx := context.Background()
+
+	dir := filepath.Join(app.config.DownloadRoot, "Example Podcast")
+	file := filepath.Join(dir, "ep1.mp3")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(file, []byte("audio"), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	if _, err := app.db.ExecContext(ctx, `INSERT INTO podcasts (id, title, feed_url, subscribed_at) VALUES (?, ?, ?, ?)`,
+		"pod1", "Example Podcast", "https://example.com/feed", time.Now().UTC()); err != nil {
+		t.Fatalf("insert podcast: %v", err)
+	}
+	if _, err := app.db.ExecContext(ctx, `INSERT INTO episodes (id, podcast_id, title, state, file_path, enclosure_url) VALUES (?, ?, ?, ?, ?, ?)`,
+		"ep1", "pod1", "Episode One", stateDownloaded, file, "https://example.com/ep1.mp3"); err != nil {
+		t.Fatalf("insert episode: %v", err)
+	}
+	past := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(dir, past, past); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+	if marked, err := app.episodes.CheckDeletedFiles(ctx); err != nil || marked != 0 {
+		t.Fatalf("CheckDeletedFiles() = %d, %v; want 0, nil", marked, err)
+	}
+
+	// An unchanged folder is not read again
+	if err := os.Remove(file); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	if err := os.Chtimes(dir, past, past); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+	if marked, err := app.episodes.CheckDeletedFiles(ctx); err != nil || marked != 0 {
+		t.Fatalf("CheckDeletedFiles() = %d, %v; want the cached folder to be trusted", marked, err)
+	}
+
+	now := time.Now()
+	if err := os.Chtimes(dir, now, now); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+	if _, err := app.Execute(ctx, "downloads"); err != nil {
+		t.Fatalf("Execute(downloads) error = %v", err)
+	}
+	timeout := time.After(5 * time.Second)
+	for changed := false; !changed; {
+		select {
+		case event := <-events:
+			changed = event.Kind == EventFilesChanged
+		case <-timeout:
+			t.Fatal("expected the background check to report the removed file")
+		}
+	}
+	if state := episodeState(t, ctx, app.db, "ep1"); state != stateDeleted {
+		t.Fatalf("expected DELETED, got %s", state)
+	}
+}
+
+func TestDeleteCommandRemovesFileAndPrunesDirectory(t *testing.T) {
+	app := newTestAppWithConfig(t, nil, func(cfg *config.Config) {
+		cfg.PruneEmptyDirs = true
+		cfg.TrashDays = 0
+	})
+	ctx := context.Background()
+
+	podcastDir := filepath.Join(app.config.DownloadRoot, "Example Podcast")
+	if err := os.MkdirAll(podcastDir, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	files := map[string]string{}
+	for _, id := range []string{"ep1", "ep2"} {
+		files[id] = filepath.Join(podcastDir, id+".mp3")
+		if err := os.WriteFile(files[id], []byte("audio"), 0o644); err != nil {
+			t.Fatalf("write file: %v", err)
+		}
+	}
+	if _, err := app.db.ExecContext(ctx, `INSERT INTO podcasts (id, title, feed_url, subscribed_at) VALUES (?, ?, ?, ?)`,
+		"pod1", "Example Podcast", "https://example.com/feed", time.Now().UTC()); err != nil {
+		t.Fatalf("insert podcast: %v", err)
+	}
+	for id, state := range map[string]string{"ep1": stateDownloaded, "ep2": statePlayed} {
+		if _, err := app.db.ExecContext(ctx, `INSERT INTO episodes (id, podcast_id, title, state, file_path, enclosure_url) VALUES (?, ?, ?, ?, ?, ?)`,
+			id, "pod1", "Episode "+id, state, files[id], "https://example.com/"+id+".mp3"); err != nil {
+			t.Fatalf("insert episode: %v", err)
+		}
+	}
+
+	result, err := app.Execute(ctx, "delete ep1")
+	if err != nil {
+		t.Fatalf("Execute(delete) error = %v", err)
+	}
+	if result.Message != fmt.Sprintf("Deleted %s.", files["ep1"]) {
+		t.Fatalf("unexpected message: %s", result.Message)
+	}
+	if _, err := os.Stat(files["ep1"]); !os.IsNotExist(err) {
+		t.Fatalf("expected file to be removed, stat error = %v", err)
+	}
+	if state := episodeState(t, ctx, app.db, "ep1"); state != stateDeleted {
+		t.Fatalf("expected DELETED, got %s", state)
+	}
+	if _, err := os.Stat(podcastDir); err != nil {
+		t.Fatalf("expected non-empty podcast directory to remain: %v", err)
+	}
+
+	if result, err = app.Execute(ctx, "delete ep1"); err != nil || result.Message != "Episode file is already deleted." {
+		t.Fatalf("unexpected second delete result: %q, %v", result.Message, err)
+	}
+
+	if _, err := app.Execute(ctx, "delete ep2"); err != nil {
+		t.Fatalf("Execute(delete) error = %v", err)
+	}
+	if state := episodeState(t, ctx, app.db, "ep2"); state != statePlayed {
+		t.Fatalf("expected played episode to stay PLAYED, got %s", state)
+	}
+	if _, err := os.Stat(podcastDir); !os.IsNotExist(err) {
+		t.Fatalf("expected empty podcast directory to be pruned, stat error = %v", err)
+	}
+	if _, err := os.Stat(app.config.DownloadRoot); err != nil {
+		t.Fatalf("download root must never be pruned: %v", err)
+	}
+}
+
+func TestVerifyCommandMarksCorruptFilesAndSamples(t *testing.T) {
+	app := newTestApp(t)
+	ctx := context.Background()
+
+	podcastDir := filepath.Join(app.config.DownloadRoot, "Example Podcast")
+	if err := os.MkdirAll(podcastDir, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if _, err := app.db.ExecContext(ctx, `INSERT INTO podcasts (id, title, feed_url, subscribed_at) VALUES (?, ?, ?, ?)`,
+		"pod1", "Example Podcast", "https://example.com/feed", time.Now().UTC()); err != nil {
+		t.Fatalf("insert podcast: %v", err)
+	}
+	// ep2 and ep4 were changed on disk, ep3 was restored and ep5 is gone
+	for _, ep := range []struct{ id, state, content string }{
+		{"ep1", stateDownloaded, "audio ep1"},
+		{"ep2", stateDownloaded, "bit rot"},
+		{"ep3", stateCorrupt, "audio ep3"},
+		{"ep4", statePlayed, "bit rot"},
+		{"ep5", stateDownloaded, ""},
+	} {
+		path := filepath.Join(podcastDir, ep.id+".mp3")
+		if ep.content != "" {
+			if err := os.WriteFile(path, []byte(ep.content), 0o644); err != nil {
+				t.Fatalf("write file: %v", err)
+			}
+		}
+		sum := sha256.Sum256([]byte("audio " + ep.id))
+		if _, err := app.db.ExecContext(ctx, `INSERT INTO episodes (id, podcast_id, title, state, file_path, hash, enclosure_url) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			ep.id, "pod1", "Episode "+ep.id, ep.state, path, hex.EncodeToString(sum[:]), "https://example.com/"+ep.id+".mp3"); err != nil {
+			t.Fatalf("insert episode: %v", err)
+		}
+	}
+
+	result, err := app.Execute(ctx, "verify pod1")
+	if err != nil {
+		t.Fatalf("Execute(verify) error = %v", err)
+	}
+	want := strings.Join([]string{
+		"Verified 4 of 5 file(s): 2 corrupt.",
+		"Corrupt: ep2",
+		"Corrupt: ep4",
+		"1 corrupt file(s) match their hash again.",
+		"1 file(s) are missing from disk.",
+		"Use 'redownload corrupt' to fetch corrupt files again.",
+	}, "\n")
+	if result.Message != want {
+		t.Fatalf("unexpected message:\n%s", result.Message)
+	}
+	for id, want := range map[string]string{"ep1": stateDownloaded, "ep2": stateCorrupt, "ep3": stateDownloaded, "ep4": statePlayed, "ep5": stateDownloaded} {
+		if state := episodeState(t, ctx, app.db, id); state != want {
+			t.Fatalf("expected %s to be %s, got %s", id, want, state)
+		}
+	}
+
+	result, err = app.Execute(ctx, "verify --sample 40%")
+	if err != nil {
+		t.Fatalf("Execute(verify --sample) error = %v", err)
+	}
+	lines := strings.Split(result.Message, "\n")
+	if !strings.HasSuffix(lines[0], " of 5 file(s): "+strconv.Itoa(strings.Count(result.Message, "Corrupt: "))+" corrupt.") ||
+		!strings.Contains(result.Message, "Corruption rate ") {
+		t.Fatalf("unexpected sample message:\n%s", result.Message)
+	}
+	if checked, _, _ := strings.Cut(strings.TrimPrefix(lines[0], "Verified "), " "); checked != "2" && checked != "1" {
+		t.Fatalf("expected 2 sampled files, one maybe missing, got %s", checked)
+	}
+
+	for _, command := range []string{"verify --sample 0%", "verify --sample", "verify --sample=lots", "verify pod1 pod2"} {
+		if result, err := app.Execute(ctx, command); err != nil || !strings.HasPrefix(result.Message, "Usage: verify") {
+			t.Fatalf("Execute(%s) = %q, %v, want usage", command, result.Message, err)
+		}
+	}
+	if result, err := app.Execute(ctx, "verify nope"); err != nil || result.Message != "No subscription found for that podcast." {
+		t.Fatalf("unexpected unknown podcast result: %q, %v", result.Message, err)
+	}
+}
+
+func TestTrashKeepsDeletedFilesForRestore(t *testing.T) {
+	app := newTestApp(t)
+	ctx := context.Background()
+
+	podcastDir := filepath.Join(app.config.DownloadRoot, "Example Podcast")
+	if err := os.MkdirAll(podcastDir, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	files := map[string]string{}
+	for _, id := range []string{"ep1", "ep2", "ep3"} {
+		files[id] = filepath.Join(podcastDir, id+".mp3")
+		if err := os.WriteFile(files[id], []byte("audio "+id), 0o644); err != nil {
+			t.Fatalf("write file: %v", err)
+		}
+	}
+	if _, err := app.db.ExecContext(ctx, `INSERT INTO podcasts (id, title, feed_url, subscribed_at) VALUES (?, ?, ?, ?)`,
+		"pod1", "Example Podcast", "https://example.com/feed", time.Now().UTC()); err != nil {
+		t.Fatalf("insert podcast: %v", err)
+	}
+	for id, state := range map[string]string{"ep1": stateDownloaded, "ep2": statePlayed, "ep3": stateDownloaded} {
+		if _, err := app.db.ExecContext(ctx, `INSERT INTO episodes (id, podcast_id, title, state, file_path, enclosure_url) VALUES (?, ?, ?, ?, ?, ?)`,
+			id, "pod1", "Episode "+id, state, files[id], "https://example.com/"+id+".mp3"); err != nil {
+			t.Fatalf("insert episode: %v", err)
+		}
+	}
+
+	if result, err := app.Execute(ctx, "trash"); err != nil || result.Message != "The trash is empty." {
+		t.Fatalf("unexpected empty trash listing: %q, %v", result.Message, err)
+	}
+	result, err := app.Execute(ctx, "delete ep1")
+	if err != nil || !strings.HasPrefix(result.Message, "Moved "+files["ep1"]+" to the trash") {
+		t.Fatalf("unexpected delete result: %q, %v", result.Message, err)
+	}
+	if _, err := app.DeleteEpisodes(ctx, []string{"ep2", "ep3"}); err != nil {
+		t.Fatalf("DeleteEpisodes error = %v", err)
+	}
+	for id := range files {
+		if _, err := os.Stat(files[id]); !os.IsNotExist(err) {
+			t.Fatalf("expected %s to leave its folder, stat error = %v", id, err)
+		}
+	}
+	if state := episodeState(t, ctx, app.db, "ep1"); state != stateDeleted {
+		t.Fatalf("expected DELETED, got %s", state)
+	}
+	trashed, err := os.ReadDir(config.TrashDir(app.config.DownloadRoot))
+	if err != nil || len(trashed) != 3 {
+		t.Fatalf("expected 3 files in the trash: %v, %v", trashed, err)
+	}
+	if result, err = app.Execute(ctx, "dangling"); err != nil || result.Message != "No dangling files." {
+		t.Fatalf("trashed files must not be dangling: %q, %v", result.Message, err)
+	}
+	if result, err = app.Execute(ctx, "trash"); err != nil || !strings.HasPrefix(result.Message, "3 file(s) in the trash, purged after 30 day(s):") || !strings.Contains(result.Message, "Example Podcast - Episode ep1") {
+		t.Fatalf("unexpected trash listing: %q, %v", result.Message, err)
+	}
+
+	if result, err = app.Execute(ctx, "trash restore ep1"); err != nil || result.Message != "Restored "+files["ep1"]+"." {
+		t.Fatalf("unexpected restore result: %q, %v", result.Message, err)
+	}
+	if data, err := os.ReadFile(files["ep1"]); err != nil || string(data) != "audio ep1" {
+		t.Fatalf("expected ep1 back in place: %q, %v", data, err)
+	}
+	if state := episodeState(t, ctx, app.db, "ep1"); state != stateDownloaded {
+		t.Fatalf("expected restored episode to be DOWNLOADED, got %s", state)
+	}
+	if result, err = app.Execute(ctx, "trash restore ep1"); err != nil || result.Message != "No file of that episode is in the trash." {
+		t.Fatalf("unexpected second restore result: %q, %v", result.Message, err)
+	}
+
+	if err := os.WriteFile(files["ep2"], []byte("other"), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	if result, err = app.Execute(ctx, "trash restore ep2"); err != nil || result.Message != "Cannot restore: "+files["ep2"]+" already exists." {
+		t.Fatalf("unexpected conflicting restore result: %q, %v", result.Message, err)
+	}
+	if err := os.Remove(files["ep2"]); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	if result, err = app.Execute(ctx, "trash restore all"); err != nil || result.Message != "Restored 2 of 2 file(s) from the trash." {
+		t.Fatalf("unexpected restore all result: %q, %v", result.Message, err)
+	}
+	if state := episodeState(t, ctx, app.db, "ep2"); state != statePlayed {
+		t.Fatalf("expected played episode to stay PLAYED, got %s", state)
+	}
+
+	// Files older than trash_days are purged on start
+	if _, err := app.Execute(ctx, "delete ep3"); err != nil {
+		t.Fatalf("Execute(delete) error = %v", err)
+	}
+	old := time.Now().Add(-31 * 24 * time.Hour).UTC().Format(time.RFC3339Nano)
+	if _, err := app.db.ExecContext(ctx, "UPDATE trash SET trashed_at = ?", old); err != nil {
+		t.Fatalf("age trash: %v", err)
+	}
+	if err := app.Initialize(ctx); err != nil {
+		t.Fatalf("Initialize error = %v", err)
+	}
+	if result, err = app.Execute(ctx, "trash"); err != nil || result.Message != "The trash is empty." {
+		t.Fatalf("expected old files to be purged: %q, %v", result.Message, err)
+	}
+	if trashed, _ := os.ReadDir(config.TrashDir(app.config.DownloadRoot)); len(trashed) != 0 {
+		t.Fatalf("expected purged files to be removed, got %v", trashed)
+	}
+
+	if _, err := app.Execute(ctx, "delete ep1"); err != nil {
+		t.Fatalf("Execute(delete) error = %v", err)
+	}
+	if result, err = app.Execute(ctx, "trash empty"); err != nil || result.Message != "Deleted 1 file(s) from the trash." {
+		t.Fatalf("unexpected empty result: %q, %v", result.Message, err)
+	}
+}
+
+func TestSupportBundleRedactsSecrets(t *testing.T) {
+	app := newTestAppWithConfig(t, nil, func(cfg *config.Config) {
+		cfg.GpodderUsername, cfg.GpodderPassword = "alice", "hunter2"
+	})
+	ctx := context.Background()
+	if _, err := app.db.ExecContext(ctx, `INSERT INTO podcasts (id, title, feed_url, subscribed_at) VALUES (?, ?, ?, ?)`,
+		"pod1", "Example Podcast", "https://example.com/feed", time.Now().UTC()); err != nil {
+		t.Fatalf("insert podcast: %v", err)
+	}
+	if _, err := app.db.ExecContext(ctx, `INSERT INTO episodes (id, podcast_id, title, state, enclosure_url) VALUES (?, ?, ?, ?, ?)`,
+		"ep1", "pod1", "Episode One", stateNew, "https://example.com/ep1.mp3"); err != nil {
+		t.Fatalf("insert episode: %v", err)
+	}
+	logPath := filepath.Join(filepath.Dir(app.configPath), "podsink.log")
+	if err := os.WriteFile(logPath, []byte("sync: login with hunter2 refused\n"), 0o600); err != nil {
+		t.Fatalf("write log: %v", err)
+	}
+
+	bundle := filepath.Join(t.TempDir(), "bundle.tar.gz")
+	result, err := app.Execute(ctx, "support-bundle "+bundle)
+	if err != nil || !strings.HasPrefix(result.Message, "Wrote "+bundle) {
+		t.Fatalf("unexpected support-bundle result: %q, %v", result.Message, err)
+	}
+
+	file, err := os.Open(bundle)
+	if err != nil {
+		t.Fatalf("open bundle: %v", err)
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatalf("gzip: %v", err)
+	}
+	entries := map[string]string{}
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("read tar: %v", err)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatalf("read %s: %v", header.Name, err)
+		}
+		entries[header.Name] = string(data)
+	}
+
+	if !strings.Contains(entries["config.yaml"], "gpodder_password: '[redacted]'") || strings.Contains(entries["config.yaml"], "hunter2") {
+		t.Fatalf("expected the password to be redacted in config.yaml:\n%s", entries["config.yaml"])
+	}
+	if entries["podsink.log"] != "sync: login with [redacted] refused\n" {
+		t.Fatalf("unexpected log in bundle: %q", entries["podsink.log"])
+	}
+	var info supportInfo
+	if err := json.Unmarshal([]byte(entries["info.json"]), &info); err != nil {
+		t.Fatalf("decode info.json: %v", err)
+	}
+	if info.Database.SchemaVersion != storage.SchemaVersion || info.ExpectedSchemaVersion != storage.SchemaVersion {
+		t.Fatalf("unexpected schema versions %d, %d", info.Database.SchemaVersion, info.ExpectedSchemaVersion)
+	}
+	if info.Database.Tables["podcasts"] != 1 || info.Database.Tables["episodes"] != 1 || info.Database.EpisodeStates[stateNew] != 1 || info.Database.SizeBytes == 0 {
+		t.Fatalf("unexpected database stats %+v", info.Database)
+	}
+	if _, ok := info.Database.Tables["episodes_fts"]; ok {
+		t.Fatal("expected the search index to be left out of the table counts")
+	}
+}
+
+func TestRenameFilesCommandFollowsTemplate(t *testing.T) {
+	app := newTestAppWithConfig(t, nil, func(cfg *config.Config) {
+		cfg.PruneEmptyDirs = true
+	})
+	ctx := context.Background()
+	root := app.config.DownloadRoot
+	podcastDir := filepath.Join(root, "Example_Podcast")
+	oldDir := filepath.Join(root, "Old Name")
+	for _, dir := range []string{podcastDir, oldDir} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+	}
+	files := map[string]string{
+		"ep1": filepath.Join(oldDir, "typo.mp3"),
+		"ep2": filepath.Join(podcastDir, "Episode_ep2.mp3"),
+		"ep3": filepath.Join(podcastDir, "old-ep3.mp3"),
+		"ep4": filepath.Join(podcastDir, "gone.mp3"),
+	}
+	for _, id := range []string{"ep1", "ep2", "ep3"} {
+		if err := os.WriteFile(files[id], []byte("audio "+id), 0o644); err != nil {
+			t.Fatalf("write file: %v", err)
+		}
+	}
+	taken := filepath.Join(podcastDir, "Episode_ep3.mp3")
+	if err := os.WriteFile(taken, []byte("other"), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	if _, err := app.db.ExecContext(ctx, `INSERT INTO podcasts (id, title, feed_url, subscribed_at) VALUES (?, ?, ?, ?)`,
+		"pod1", "Example Podcast", "https://example.com/feed", time.Now().UTC()); err != nil {
+		t.Fatalf("insert podcast: %v", err)
+	}
+	for id, path := range files {
+		if _, err := app.db.ExecContext(ctx, `INSERT INTO episodes (id, podcast_id, title, state, file_path, enclosure_url) VALUES (?, ?, ?, ?, ?, ?)`,
+			id, "pod1", "Episode "+id, stateDownloaded, path, "https://example.com/"+id+".mp3"); err != nil {
+			t.Fatalf("insert episode: %v", err)
+		}
+	}
+
+	if result, err := app.Execute(ctx, "rename-files unknown"); err != nil || result.Message != "No subscription found for that podcast." {
+		t.Fatalf("unexpected result for unknown podcast: %q, %v", result.Message, err)
+	}
+
+	result, err := app.Execute(ctx, "rename-files pod1")
+	if err != nil {
+		t.Fatalf("Execute(rename-files) error = %v", err)
+	}
+	want := "Renamed 1 file(s); 1 already matched the template.\n1 file(s) are missing from disk.\nSkipped, target exists: " + taken
+	if result.Message != want {
+		t.Fatalf("unexpected message:\n%s\nwant:\n%s", result.Message, want)
+	}
+
+	renamed := filepath.Join(podcastDir, "Episode_ep1.mp3")
+	if data, err := os.ReadFile(renamed); err != nil || string(data) != "audio ep1" {
+		t.Fatalf("expected ep1 at %s: %q, %v", renamed, data, err)
+	}
+	var recorded string
+	if err := app.db.QueryRowContext(ctx, "SELECT file_path FROM episodes WHERE id = ?", "ep1").Scan(&recorded); err != nil {
+		t.Fatalf("query file_path: %v", err)
+	}
+	if recorded != renamed {
+		t.Fatalf("expected file_path %s, got %s", renamed, recorded)
+	}
+	if _, err := os.Stat(oldDir); !os.IsNotExist(err) {
+		t.Fatalf("expected emptied directory to be pruned, stat error = %v", err)
+	}
+	if _, err := os.Stat(files["ep3"]); err != nil {
+		t.Fatalf("expected conflicting file to stay in place: %v", err)
+	}
+	if data, err := os.ReadFile(taken); err != nil || string(data) != "other" {
+		t.Fatalf("expected existing file to be kept: %q, %v", data, err)
+	}
+
+	if err := os.Remove(taken); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	if _, err := app.db.ExecContext(ctx, "UPDATE episodes SET state = ? WHERE id = ?", stateDeleted, "ep4"); err != nil {
+		t.Fatalf("update episode: %v", err)
+	}
+	if result, err = app.Execute(ctx, "rename-files"); err != nil || result.Message != "Renamed 1 file(s); 2 already matched the template." {
+		t.Fatalf("unexpected second rename result: %q, %v", result.Message, err)
+	}
+	if result, err = app.Execute(ctx, "rename-files"); err != nil || result.Message != "All downloaded files already match the filename template." {
+		t.Fatalf("unexpected third rename result: %q, %v", result.Message, err)
+	}
+}
+
+func TestDanglingCommandAdoptsAndDeletesFiles(t *testing.T) {
+	app := newTestApp(t)
+	ctx := context.Background()
+
+	if _, err := app.db.ExecContext(ctx, `INSERT INTO podcasts (id, title, feed_url, subscribed_at) VALUES (?, ?, ?, ?)`,
+		"pod1", "Go Time", "https://example.com/feed", time.Now().UTC()); err != nil {
+		t.Fatalf("insert podcast: %v", err)
+	}
+	if _, err := app.db.ExecContext(ctx, `INSERT INTO episodes (id, podcast_id, title, state, enclosure_url) VALUES (?, ?, ?, ?, ?)`,
+		"ep1", "pod1", "Building Better APIs", "SEEN", "https://example.com/ep1.mp3"); err != nil {
+		t.Fatalf("insert episode: %v", err)
+	}
+
+	podcastDir := filepath.Join(app.config.DownloadRoot, "Go Time")
+	if err := os.MkdirAll(podcastDir, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	paths := map[string]string{}
+	for _, name := range []string{"building-better-apis.mp3", "notes.txt", "other.mp3"} {
+		paths[name] = filepath.Join(podcastDir, name)
+		if err := os.WriteFile(paths[name], []byte(name), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+
+	run := func(command string) string {
+		t.Helper()
+		result, err := app.Execute(ctx, command)
+		if err != nil {
+			t.Fatalf("Execute(%s) error = %v", command, err)
+		}
+		return result.Message
+	}
+
+	if msg := run("dangling"); !strings.HasPrefix(msg, "3 dangling file(s):") {
+		t.Fatalf("unexpected listing: %s", msg)
+	}
+	if msg := run("dangling adopt '" + paths["building-better-apis.mp3"] + "'"); msg != "Adopted building-better-apis.mp3 as Building Better APIs." {
+		t.Fatalf("unexpected adopt message: %s", msg)
+	}
+	if state := episodeState(t, ctx, app.db, "ep1"); state != stateDownloaded {
+		t.Fatalf("expected adopted episode to be DOWNLOADED, got %s", state)
+	}
+	if msg := run("dangling adopt '" + paths["other.mp3"] + "'"); msg != "No episode matches other.mp3." {
+		t.Fatalf("unexpected adopt message: %s", msg)
+	}
+
+	outside := filepath.Join(t.TempDir(), "keep.mp3")
+	if err := os.WriteFile(outside, []byte("keep"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if msg := run("dangling delete " + outside); !strings.HasPrefix(msg, "Not a dangling file") {
+		t.Fatalf("expected refusal for a file outside the download root, got %s", msg)
+	}
+	if _, err := os.Stat(outside); err != nil {
+		t.Fatalf("file outside the download root was touched: %v", err)
+	}
+
+	if msg := run("dangling delete '" + paths["notes.txt"] + "'"); msg != fmt.Sprintf("Deleted %s.", paths["notes.txt"]) {
+		t.Fatalf("unexpected delete message: %s", msg)
+	}
+	if msg := run("dangling clean"); msg != "Deleted 1 dangling file(s)." {
+		t.Fatalf("unexpected clean message: %s", msg)
+	}
+	if msg := run("dangling"); msg != "No dangling files." {
+		t.Fatalf("unexpected listing after clean: %s", msg)
+	}
+	if _, err := os.Stat(paths["building-better-apis.mp3"]); err != nil {
+		t.Fatalf("adopted file must survive clean: %v", err)
+	}
+}
+
+type fakeDirectory struct {
+	results []itunes.Podcast
+	err     error
+}
+
+func (f fakeDirectory) Search(context.Context, string, int) ([]itunes.Podcast, error) {
+	return f.results, f.err
+}
+
+func (f fakeDirectory) LookupPodcast(_ context.Context, id string) (itunes.Podcast, error) {
+	for _, p := range f.results {
+		if p.ID == id {
+			return p, nil
+		}
+	}
+	return itunes.Podcast{}, errors.New("podcast not found")
+}
+
+func TestMergedDirectoryDedupesResults(t *testing.T) {
+	ctx := context.Background()
+	dir := &mergedDirectory{
+		itunes: fakeDirectory{results: []itunes.Podcast{
+			{ID: "1", Title: "Go Time", FeedURL: "https://changelog.com/gotime/feed"},
+			{ID: "2", Title: "Gopher Talk", FeedURL: "http://example.com/feed/"},
+		}},
+		index: fakeDirectory{results: []itunes.Podcast{
+			{ID: "1", Title: "Go Time", FeedURL: "https://changelog.com/gotime/feed"},
+			{ID: "pi-42", Title: "Gopher Talk", FeedURL: "https://Example.com/feed"},
+			{ID: "pi-7", Title: "Regional Go", FeedURL: "https://example.org/go"},
+		}},
+	}
+	results, err := dir.Search(ctx, "go", 10)
+	if err != nil {
+		t.Fatalf("Search() error = %v", err)
+	}
+	var ids []string
+	for _, p := range results {
+		ids = append(ids, p.ID)
+	}
+	if strings.Join(ids, ",") != "1,2,pi-7" {
+		t.Fatalf("unexpected merged results %v", ids)
+	}
+
+	if p, err := dir.LookupPodcast(ctx, "pi-7"); err != nil || p.Title != "Regional Go" {
+		t.Fatalf("LookupPodcast(pi-7) = %+v, %v", p, err)
+	}
+
+	dir.itunes = fakeDirectory{err: errors.New("itunes down")}
+	if results, err := dir.Search(ctx, "go", 10); err != nil || len(results) != 3 {
+		t.Fatalf("expected index results when iTunes fails, got %d, %v", len(results), err)
+	}
+	dir.index = fakeDirectory{err: errors.New("index down")}
+	if _, err := dir.Search(ctx, "go", 10); err == nil {
+		t.Fatal("expected an error when both directories fail")
+	}
+}
+
+func TestEpisodeQueryFiltersAndSortsByDuration(t *testing.T) {
+	a := newTestApp(t)
+	ctx := context.Background()
+	if _, err := a.db.ExecContext(ctx, `INSERT INTO podcasts (id, title, feed_url, subscribed_at) VALUES (?, ?, ?, ?)`,
+		"pod1", "Example Podcast", "http://example.com/feed", time.Now().UTC()); err != nil {
+		t.Fatalf("insert podcast: %v", err)
+	}
+	// Newest first: long, unknown, short, medium.
+	for i, ep := range []struct {
+		id      string
+		minutes int
+	}{{"long", 90}, {"unknown", 0}, {"short", 10}, {"medium", 45}} {
+		published := time.Date(2024, 1, 10-i, 0, 0, 0, 0, time.UTC).Format(time.RFC3339)
+		if _, err := a.db.ExecContext(ctx, `INSERT INTO episodes (id, podcast_id, title, state, enclosure_url, published_at, duration_seconds) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			ep.id, "pod1", ep.id, stateNew, "http://example.com/"+ep.id+".mp3", published, ep.minutes*60); err != nil {
+			t.Fatalf("insert episode: %v", err)
+		}
+	}
+	ids := func(rs []EpisodeResult) string {
+		var out []string
+		for _, r := range rs {
+			out = append(out, r.Episode.ID)
+		}
+		return strings.Join(out, ",")
+	}
+
+	cases := []struct {
+		args string
+		want string
+		more bool
+	}{
+		{"", "long,unknown,short,medium", false},
+		{"shortest", "short,medium,long,unknown", false},
+		{"longest", "long,medium,short,unknown", false},
+		{"under 60", "short,medium", false},
+		{"over 30 under 60", "medium", false},
+		{"OVER 20 longest", "long,medium", false},
+		{"--limit 2", "long,unknown", true},
+		{"--limit 2 --offset 2", "short,medium", false},
+		{"shortest --offset 1 --limit 2", "medium,long", true},
+	}
+	for _, tc := range cases {
+		result, err := a.Execute(ctx, strings.TrimSpace("episodes "+tc.args))
+		if err != nil {
+			t.Fatalf("episodes %s error = %v", tc.args, err)
+		}
+		if got := ids(result.EpisodeResults); got != tc.want || result.EpisodesMore != tc.more {
+			t.Fatalf("episodes %s = %s (more %v), want %s (more %v)", tc.args, got, result.EpisodesMore, tc.want, tc.more)
+		}
+	}
+
+	result, err := a.Execute(ctx, "episodes --offset 4")
+	if err != nil || len(result.EpisodeResults) != 0 || result.Message != "No episodes match." {
+		t.Fatalf("expected no episodes past the end, got %+v, %v", result, err)
+	}
+
+	for _, args := range [][]string{{"under"}, {"over", "x"}, {"under", "-5"}, {"pod-1", "newest"}, {"--limit", "0"}, {"--offset"}} {
+		if _, err := parseEpisodeQuery(args); err == nil {
+			t.Fatalf("parseEpisodeQuery(%v) should fail", args)
+		}
+	}
+
+	query, _ := parseEpisodeQuery([]string{"longest", "under", "30"})
+	if got := query.String(); got != "under 30 longest" {
+		t.Fatalf("String() = %q, want %q", got, "under 30 longest")
+	}
+	query, _ = parseEpisodeQuery([]string{"shortest", "pod-1", "--limit", "5"})
+	if got := query.String(); got != "pod-1 shortest" {
+		t.Fatalf("String() = %q, want %q", got, "pod-1 shortest")
+	}
+}
+
+func TestEpisodesFilterByStatePodcastAndDate(t *testing.T) {
+	a := newTestApp(t)
+	ctx := context.Background()
+	for _, podcast := range [][2]string{{"gotime", "Go Time"}, {"other", "Other Show"}} {
+		if _, err := a.db.ExecContext(ctx, `INSERT INTO podcasts (id, title, feed_url, subscribed_at) VALUES (?, ?, ?, ?)`,
+			podcast[0], podcast[1], "http://example.com/"+podcast[0], time.Now().UTC()); err != nil {
+			t.Fatalf("insert podcast: %v", err)
+		}
+	}
+	// Newest first: gt1, other1, gt2, gt3.
+	for i, ep := range []struct{ id, podcast, state string }{
+		{"gt1", "gotime", stateNew},
+		{"other1", "other", stateNew},
+		{"gt2", "gotime", stateDownloaded},
+		{"gt3", "gotime", stateIgnored},
+	} {
+		published := time.Date(2024, 1, 10-i, 12, 0, 0, 0, time.UTC).Format(time.RFC3339)
+		if _, err := a.db.ExecContext(ctx, `INSERT INTO episodes (id, podcast_id, title, state, enclosure_url, published_at) VALUES (?, ?, ?, ?, ?, ?)`,
+			ep.id, ep.podcast, ep.id, ep.state, "http://example.com/"+ep.id+".mp3", published); err != nil {
+			t.Fatalf("insert episode: %v", err)
+		}
+	}
+	ids := func(rs []EpisodeResult) string {
+		var out []string
+		for _, r := range rs {
+			out = append(out, r.Episode.ID)
+		}
+		return strings.Join(out, ",")
+	}
+
+	cases := []struct{ args, want string }{
+		{"--state new", "gt1,other1"},
+		{"--state seen", ""},
+		{`--podcast "go time"`, "gt1,gt2,gt3"},
+		{"--podcast time --since 2024-01-09", "gt1"},
+		{"--since 2024-01-08 --limit 1 --offset 1", "other1"},
+		{`--state DOWNLOADED --podcast "Go Time"`, "gt2"},
+		{"--podcast 100%", ""},
+	}
+	for _, tc := range cases {
+		result, err := a.Execute(ctx, "episodes "+tc.args)
+		if err != nil {
+			t.Fatalf("episodes %s error = %v", tc.args, err)
+		}
+		if got := ids(result.EpisodeResults); got != tc.want {
+			t.Fatalf("episodes %s = %q, want %q", tc.args, got, tc.want)
+		}
+	}
+
+	for _, args := range [][]string{{"--state", "bogus"}, {"--since", "2024-13-01"}, {"--podcast"}, {"--bogus"}} {
+		if _, err := parseEpisodeQuery(args); err == nil {
+			t.Fatalf("parseEpisodeQuery(%v) should fail", args)
+		}
+	}
+	query, _ := parseEpisodeQuery([]string{"--podcast", "Go Time", "--since", "2024-01-01", "--state", "new"})
+	if got, want := query.String(), "--state NEW --podcast 'Go Time' --since 2024-01-01"; got != want {
+		t.Fatalf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestSearchFiltersByLanguage(t *testing.T) {
+	a := newTestApp(t)
+	a.directory = fakeDirectory{results: []itunes.Podcast{
+		{ID: "1", Title: "Go Time", Language: "en"},
+		{ID: "2", Title: "Go Podcast", Language: "de_AT"},
+		{ID: "3", Title: "Go Radio", Language: "DE"},
+		{ID: "4", Title: "Go Unknown"},
+	}}
+
+	result, err := a.Execute(context.Background(), "search go LANG:de")
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	ids := make(map[string]bool)
+	for _, r := range result.SearchResults {
+		ids[r.Podcast.ID] = true
+	}
+	if len(ids) != 2 || !ids["2"] || !ids["3"] {
+		t.Fatalf("expected German podcasts only, got %+v", result.SearchResults)
+	}
+
+	if result, _ := a.Execute(context.Background(), "search go lang:de-de"); len(result.SearchResults) != 0 {
+		t.Fatalf("de-de should not match de or de-at, got %+v", result.SearchResults)
+	}
+	if result, _ := a.Execute(context.Background(), "search lang:de"); !strings.HasPrefix(result.Message, "Usage: search") {
+		t.Fatalf("expected usage without a search term, got %q", result.Message)
+	}
+}
+
+func TestSearchMarksDuplicateTitles(t *testing.T) {
+	a := newTestApp(t)
+	a.directory = fakeDirectory{results: []itunes.Podcast{
+		{ID: "1", Title: "The Daily", Author: "The New York Times", FeedURL: "https://feeds.simplecast.com/daily", EpisodeCount: 2400},
+		{ID: "2", Title: "The  daily", Author: "the new york times", FeedURL: "https://example.com/reupload", EpisodeCount: 12},
+		{ID: "3", Title: "The Daily", Author: "Someone Else", FeedURL: "https://other.example.org/feed"},
+		{ID: "4", Title: "Daily Tech", Author: "Tech"},
+	}}
+
+	result, err := a.Execute(context.Background(), "search the daily")
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	byID := make(map[string]SearchResult)
+	for _, r := range result.SearchResults {
+		byID[r.Podcast.ID] = r
+	}
+	for _, id := range []string{"1", "2", "3"} {
+		if !byID[id].SameTitle {
+			t.Fatalf("expected result %s to be marked as sharing its title: %+v", id, byID[id])
+		}
+	}
+	if r, ok := byID["4"]; ok && r.SameTitle {
+		t.Fatalf("unique title should not be marked: %+v", r)
+	}
+	if byID["1"].LikelyDuplicate || !byID["2"].LikelyDuplicate || byID["3"].LikelyDuplicate {
+		t.Fatalf("expected only the smaller show by the same author to be a likely duplicate: %+v", result.SearchResults)
+	}
+}
+
+func TestBulkEpisodeActions(t *testing.T) {
+	app := newTestApp(t)
+	ctx := context.Background()
+
+	file := filepath.Join(app.config.DownloadRoot, "ep4.mp3")
+	if err := os.WriteFile(file, []byte("audio"), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	if _, err := app.db.ExecContext(ctx, `INSERT INTO podcasts (id, title, feed_url, subscribed_at) VALUES (?, ?, ?, ?)`,
+		"pod1", "Example Podcast", "http://example.com/feed", time.Now().UTC()); err != nil {
+		t.Fatalf("insert podcast: %v", err)
+	}
+	for id, state := range map[string]string{"ep1": stateNew, "ep2": stateNew, "ep3": stateIgnored, "ep4": stateDownloaded} {
+		filePath := ""
+		if id == "ep4" {
+			filePath = file
+		}
+		if _, err := app.db.ExecContext(ctx, `INSERT INTO episodes (id, podcast_id, title, state, file_path, enclosure_url) VALUES (?, ?, ?, ?, ?, ?)`,
+			id, "pod1", "Episode "+id, state, filePath, "http://example.com/"+id+".mp3"); err != nil {
+			t.Fatalf("insert episode: %v", err)
+		}
+	}
+
+	result, err := app.QueueEpisodes(ctx, []string{"ep1", "ep2", "ep3"})
+	if err != nil || result.Message != "Queued 2 of 3 episode(s) for download." {
+		t.Fatalf("QueueEpisodes = %q, %v", result.Message, err)
+	}
+	if result, err = app.IgnoreEpisodes(ctx, []string{"ep2"}); err != nil || result.Message != "Ignored 1 of 1 episode(s)." {
+		t.Fatalf("IgnoreEpisodes = %q, %v", result.Message, err)
+	}
+	if result, err = app.MarkEpisodesSeen(ctx, []string{"ep2", "ep3"}); err != nil || result.Message != "Marked 2 of 2 episode(s) as seen." {
+		t.Fatalf("MarkEpisodesSeen = %q, %v", result.Message, err)
+	}
+	if result, err = app.DeleteEpisodes(ctx, []string{"ep1", "ep4"}); err != nil || result.Message != "Deleted the files of 1 of 2 episode(s)." {
+		t.Fatalf("DeleteEpisodes = %q, %v", result.Message, err)
+	}
+	if _, err := os.Stat(file); !os.IsNotExist(err) {
+		t.Fatalf("expected the file to be removed, stat error = %v", err)
+	}
+
+	for id, want := range map[string]string{"ep1": stateQueued, "ep2": stateSeen, "ep3": stateSeen, "ep4": stateDeleted} {
+		if state := episodeState(t, ctx, app.db, id); state != want {
+			t.Fatalf("state of %s = %s, want %s", id, state, want)
+		}
+	}
+}
+
+func TestBacklogCommands(t *testing.T) {
+	app := newTestApp(t)
+	ctx := context.Background()
+
+	for _, id := range []string{"pod1", "pod2"} {
+		if _, err := app.db.ExecContext(ctx, `INSERT INTO podcasts (id, title, feed_url, subscribed_at) VALUES (?, ?, ?, ?)`,
+			id, "Podcast "+id, "http://example.com/"+id, time.Now().UTC()); err != nil {
+			t.Fatalf("insert podcast: %v", err)
+		}
+	}
+	episodes := []struct{ id, podcast, state, published string }{
+		{"a1", "pod1", stateNew, "2024-01-01T00:00:00Z"},
+		{"a2", "pod1", stateNew, "2024-02-01T00:00:00Z"},
+		{"a3", "pod1", stateDownloaded, "2024-03-01T00:00:00Z"},
+		{"a4", "pod1", stateSeen, "2024-04-01T00:00:00Z"},
+		{"b1", "pod2", stateNew, "2024-01-01T00:00:00Z"},
+		{"b2", "pod2", stateQueued, "2024-02-01T00:00:00Z"},
+	}
+	for _, ep := range episodes {
+		if _, err := app.db.ExecContext(ctx, `INSERT INTO episodes (id, podcast_id, title, state, published_at, enclosure_url) VALUES (?, ?, ?, ?, ?, ?)`,
+			ep.id, ep.podcast, "Episode "+ep.id, ep.state, ep.published, "http://example.com/"+ep.id+".mp3"); err != nil {
+			t.Fatalf("insert episode: %v", err)
+		}
+	}
+
+	for _, tc := range []struct {
+		input string
+		want  string
+	}{
+		{"queue latest", "Usage: " + queueUsage},
+		{"queue latest pod1 0", "Usage: " + queueUsage},
+		{"queue latest missing", "No subscription found for that podcast."},
+		// The downloaded third newest is skipped
+		{"queue latest pod1 3", "Queued 2 of the 3 newest episode(s) for download."},
+		{"mark-seen", "Usage: mark-seen <all | podcast_id>"},
+		{"mark-seen missing", "No subscription found for that podcast."},
+		{"mark-seen pod1", "Marked 1 new episode(s) as seen."},
+		{"ignore podcast missing", "No subscription found for that podcast."},
+		// Only the NEW episode is backlog; the queued one stays
+		{"ignore podcast pod2", "Ignored 1 episode(s) of pod2."},
+		{"mark-seen all", "Marked 0 new episode(s) as seen."},
+	} {
+		result, err := app.Execute(ctx, tc.input)
+		if err != nil {
+			t.Fatalf("Execute(%s) error = %v", tc.input, err)
+		}
+		if result.Message != tc.want {
+			t.Fatalf("Execute(%s) = %q, want %q", tc.input, result.Message, tc.want)
+		}
+	}
+
+	want := map[string]string{
+		"a1": stateSeen, "a2": stateQueued, "a3": stateDownloaded, "a4": stateQueued,
+		"b1": stateIgnored, "b2": stateQueued,
+	}
+	for id, state := range want {
+		if got := episodeState(t, ctx, app.db, id); got != state {
+			t.Fatalf("state of %s = %s, want %s", id, got, state)
+		}
+	}
+}
+
+func TestMarkSeenOnListMarksListedEpisodes(t *testing.T) {
+	app := newTestAppWithConfig(t, nil, func(cfg *config.Config) {
+		cfg.MarkSeenOnList = true
+	})
+	ctx := context.Background()
+
+	if _, err := app.db.ExecContext(ctx, `INSERT INTO podcasts (id, title, feed_url, subscribed_at) VALUES (?, ?, ?, ?)`,
+		"pod1", "Example Podcast", "http://example.com/feed", time.Now().UTC()); err != nil {
+		t.Fatalf("insert podcast: %v", err)
+	}
+	if _, err := app.db.ExecContext(ctx, `INSERT INTO episodes (id, podcast_id, title, state, enclosure_url) VALUES (?, ?, ?, ?, ?)`,
+		"ep1", "pod1", "Episode One", stateNew, "http://example.com/ep1.mp3"); err != nil {
+		t.Fatalf("insert episode: %v", err)
+	}
+
+	if _, err := app.Execute(ctx, "episodes"); err != nil {
+		t.Fatalf("Execute(episodes) error = %v", err)
+	}
+	if state := episodeState(t, ctx, app.db, "ep1"); state != stateSeen {
+		t.Fatalf("expected listing to mark ep1 seen with mark_seen_on_list, got %s", state)
 	}
-	return state
 }
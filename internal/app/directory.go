@@ -0,0 +1,103 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"podsink/internal/config"
+	"podsink/internal/itunes"
+	"podsink/internal/podcastindex"
+)
+
+// newDirectory returns the podcast directory selected by cfg.Directory.
+func newDirectory(cfg config.Config, httpClient *http.Client, deps Dependencies) itunes.Directory {
+	itunesClient := deps.ITunes
+	if itunesClient == nil {
+		itunesClient = itunes.NewClient(httpClient, "")
+	}
+	if cfg.Directory != config.DirectoryPodcastIndex && cfg.Directory != config.DirectoryBoth {
+		return itunesClient
+	}
+	indexClient := deps.PodcastIndex
+	if indexClient == nil {
+		indexClient = podcastindex.NewClient(httpClient, "", cfg.PodcastIndexKey, cfg.PodcastIndexSecret, cfg.UserAgent)
+	}
+	if cfg.Directory == config.DirectoryPodcastIndex {
+		return indexClient
+	}
+	return &mergedDirectory{itunes: itunesClient, index: indexClient}
+}
+
+// mergedDirectory searches iTunes and the Podcast Index together.
+type mergedDirectory struct {
+	itunes itunes.Directory
+	index  itunes.Directory
+}
+
+// Search queries both directories and merges the results, iTunes first.
+// A podcast listed in both is kept once; it is recognised by its ID, since
+// the index reports iTunes IDs where it knows them, or by its feed URL. The
+// search only fails when both directories do.
+func (d *mergedDirectory) Search(ctx context.Context, term string, limit int) ([]itunes.Podcast, error) {
+	type answer struct {
+		results []itunes.Podcast
+		err     error
+	}
+	indexAnswer := make(chan answer, 1)
+	go func() {
+		results, err := d.index.Search(ctx, term, limit)
+		indexAnswer <- answer{results, err}
+	}()
+	itunesResults, itunesErr := d.itunes.Search(ctx, term, limit)
+	index := <-indexAnswer
+	if itunesErr != nil && index.err != nil {
+		return nil, errors.Join(itunesErr, index.err)
+	}
+
+	seenIDs := make(map[string]bool)
+	seenFeeds := make(map[string]bool)
+	merged := make([]itunes.Podcast, 0, len(itunesResults)+len(index.results))
+	for _, results := range [][]itunes.Podcast{itunesResults, index.results} {
+		for _, podcast := range results {
+			feed := feedKey(podcast.FeedURL)
+			if seenIDs[podcast.ID] || (feed != "" && seenFeeds[feed]) {
+				continue
+			}
+			seenIDs[podcast.ID] = true
+			if feed != "" {
+				seenFeeds[feed] = true
+			}
+			merged = append(merged, podcast)
+		}
+	}
+	return merged, nil
+}
+
+// LookupPodcast resolves Podcast Index feed IDs in the index and everything
+// else in iTunes, falling back to the index for podcasts iTunes no longer
+// lists.
+func (d *mergedDirectory) LookupPodcast(ctx context.Context, id string) (itunes.Podcast, error) {
+	if strings.HasPrefix(id, podcastindex.IDPrefix) {
+		return d.index.LookupPodcast(ctx, id)
+	}
+	podcast, err := d.itunes.LookupPodcast(ctx, id)
+	if err == nil {
+		return podcast, nil
+	}
+	if fallback, indexErr := d.index.LookupPodcast(ctx, id); indexErr == nil {
+		return fallback, nil
+	}
+	return itunes.Podcast{}, err
+}
+
+// feedKey normalises a feed URL for comparison, ignoring the scheme, case
+// and a trailing slash.
+func feedKey(feedURL string) string {
+	key := strings.ToLower(strings.TrimSpace(feedURL))
+	if _, rest, ok := strings.Cut(key, "://"); ok {
+		key = rest
+	}
+	return strings.TrimRight(key, "/")
+}
@@ -0,0 +1,174 @@
+package app
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kballard/go-shellquote"
+
+	"podsink/internal/domain"
+	"podsink/internal/repository"
+)
+
+// episodesArgs is the usage of the episodes command.
+const episodesArgs = "episodes [podcast_id] [under <minutes>] [over <minutes>] [shortest|longest] [--state <state>] [--podcast <title>] [--since <YYYY-MM-DD>] [--limit <n>] [--offset <n>]"
+
+const episodesUsage = "Usage: " + episodesArgs
+
+// queryUsage describes the query command, whose expression is parsed by
+// repository.Store.QueryEpisodes.
+const queryUsage = `query "<field> <op> <value> [and|or ...] [order by <field> [desc]] [limit <n>]"`
+
+// episodeQuery limits the episodes listing to a podcast, if any, narrows
+// it by state, podcast title, publish date and duration, orders it and
+// selects a page of it. Episodes of unknown duration are left out by a bound
+// and listed last when sorting.
+type episodeQuery struct {
+	podcastID string // Empty lists every subscription
+	state     string // Upper case; empty for any state
+	podcast   string // Part of the podcast title, ignoring case
+	since     time.Time
+	underMin  int // 0 means no upper bound
+	overMin   int // 0 means no lower bound
+	order     string
+	page      repository.Page
+}
+
+func parseEpisodeQuery(args []string) (episodeQuery, error) {
+	var q episodeQuery
+	rest, page, err := parsePage(args)
+	if err != nil {
+		return episodeQuery{}, err
+	}
+	q.page = page
+	for i := 0; i < len(rest); i++ {
+		switch word := strings.ToLower(rest[i]); word {
+		case "under", "over":
+			if i+1 >= len(rest) {
+				return episodeQuery{}, fmt.Errorf("%s needs a number of minutes", word)
+			}
+			i++
+			minutes, err := strconv.Atoi(rest[i])
+			if err != nil || minutes <= 0 {
+				return episodeQuery{}, fmt.Errorf("invalid number of minutes: %s", rest[i])
+			}
+			if word == "under" {
+				q.underMin = minutes
+			} else {
+				q.overMin = minutes
+			}
+		case repository.OrderShortest, repository.OrderLongest:
+			q.order = word
+		case "--state", "--podcast", "--since":
+			if i+1 >= len(rest) || strings.TrimSpace(rest[i+1]) == "" {
+				return episodeQuery{}, fmt.Errorf("%s needs a value", word)
+			}
+			i++
+			value := strings.TrimSpace(rest[i])
+			switch word {
+			case "--state":
+				q.state = strings.ToUpper(value)
+				if !slices.Contains(domain.EpisodeStates, q.state) {
+					return episodeQuery{}, fmt.Errorf("unknown state: %s", value)
+				}
+			case "--podcast":
+				q.podcast = value
+			case "--since":
+				since, err := time.Parse("2006-01-02", value)
+				if err != nil {
+					return episodeQuery{}, fmt.Errorf("invalid date: %s", value)
+				}
+				q.since = since
+			}
+		default:
+			if q.podcastID != "" || strings.HasPrefix(word, "--") {
+				return episodeQuery{}, fmt.Errorf("unknown option: %s", rest[i])
+			}
+			q.podcastID = rest[i]
+		}
+	}
+	return q, nil
+}
+
+// parsePage takes the --limit and --offset options out of args. Listings
+// without them are complete, as scripts and the HTTP API expect.
+func parsePage(args []string) ([]string, repository.Page, error) {
+	var page repository.Page
+	rest := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		option := strings.ToLower(args[i])
+		if option != "--limit" && option != "--offset" {
+			rest = append(rest, args[i])
+			continue
+		}
+		if i+1 >= len(args) {
+			return nil, page, fmt.Errorf("%s needs a number", option)
+		}
+		i++
+		n, err := strconv.Atoi(args[i])
+		if err != nil || n < 0 || (option == "--limit" && n == 0) {
+			return nil, page, fmt.Errorf("invalid %s: %s", option, args[i])
+		}
+		if option == "--limit" {
+			page.Limit = n
+		} else {
+			page.Offset = n
+		}
+	}
+	return rest, page, nil
+}
+
+// String returns the arguments that repeat the query, without its page.
+func (q episodeQuery) String() string {
+	var parts []string
+	if q.podcastID != "" {
+		parts = append(parts, q.podcastID)
+	}
+	if q.state != "" {
+		parts = append(parts, "--state "+q.state)
+	}
+	if q.podcast != "" {
+		parts = append(parts, "--podcast "+shellquote.Join(q.podcast))
+	}
+	if !q.since.IsZero() {
+		parts = append(parts, "--since "+q.since.Format("2006-01-02"))
+	}
+	if q.underMin > 0 {
+		parts = append(parts, "under "+strconv.Itoa(q.underMin))
+	}
+	if q.overMin > 0 {
+		parts = append(parts, "over "+strconv.Itoa(q.overMin))
+	}
+	if q.order != "" {
+		parts = append(parts, q.order)
+	}
+	return strings.Join(parts, " ")
+}
+
+func (q episodeQuery) listing() repository.EpisodeListing {
+	return repository.EpisodeListing{
+		State:    q.state,
+		Podcast:  q.podcast,
+		Since:    q.since,
+		UnderSec: q.underMin * 60,
+		OverSec:  q.overMin * 60,
+		Order:    q.order,
+	}
+}
+
+// fetchPage lists one page of episodes through list, reporting whether more
+// follow. One row beyond the page is asked for to tell.
+func fetchPage(page repository.Page, list func(repository.Page) ([]EpisodeResult, error)) ([]EpisodeResult, bool, error) {
+	if page.Limit <= 0 {
+		results, err := list(page)
+		return results, false, err
+	}
+	results, err := list(repository.Page{Offset: page.Offset, Limit: page.Limit + 1})
+	if err != nil || len(results) <= page.Limit {
+		return results, false, err
+	}
+	return results[:page.Limit], true, nil
+}
@@ -0,0 +1,88 @@
+package app
+
+import (
+	"sync"
+
+	"podsink/internal/domain"
+)
+
+type Event = domain.Event
+
+type EventKind = domain.EventKind
+
+const (
+	EventEpisodeState     = domain.EventEpisodeState
+	EventDownloadProgress = domain.EventDownloadProgress
+	EventDownloadFinished = domain.EventDownloadFinished
+	EventRefreshFinished  = domain.EventRefreshFinished
+	EventRefreshProgress  = domain.EventRefreshProgress
+	EventImportProgress   = domain.EventImportProgress
+	EventSubscribed       = domain.EventSubscribed
+	EventEpisodeAdded     = domain.EventEpisodeAdded
+	EventDownloadStarted  = domain.EventDownloadStarted
+	EventFilesChanged     = domain.EventFilesChanged
+)
+
+// eventBufferSize leaves room for a refresh that publishes an event per new
+// episode.
+const eventBufferSize = 1024
+
+// EventBus fans out service events to subscribers such as the REPL.
+// Publish never blocks: events are dropped for subscribers that fall behind.
+type EventBus struct {
+	mu     sync.Mutex
+	subs   map[chan Event]struct{}
+	closed bool
+}
+
+func NewEventBus() *EventBus {
+	return &EventBus{subs: make(map[chan Event]struct{})}
+}
+
+// Subscribe returns a channel of future events and a function that cancels the subscription.
+func (b *EventBus) Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, eventBufferSize)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	b.subs[ch] = struct{}{}
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subs[ch]; ok {
+			delete(b.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+func (b *EventBus) Publish(event Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+	for ch := range b.subs {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// Close closes every subscriber channel; later publishes are ignored.
+func (b *EventBus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+	b.closed = true
+	for ch := range b.subs {
+		close(ch)
+		delete(b.subs, ch)
+	}
+}
@@ -0,0 +1,227 @@
+package app
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"podsink/internal/domain"
+)
+
+// jsonResult is the stable JSON shape of a CommandResult, meant for scripts.
+// View hints such as SearchHint only make sense in the REPL and are left out.
+type jsonResult struct {
+	Message       string         `json:"message,omitempty"`
+	Podcasts      []jsonPodcast  `json:"podcasts,omitempty"`
+	Subscriptions []jsonPodcast  `json:"subscriptions,omitempty"`
+	Episodes      []jsonEpisode  `json:"episodes,omitempty"`
+	MoreEpisodes  bool           `json:"more_episodes,omitempty"`
+	Queue         []jsonQueued   `json:"queue,omitempty"`
+	Downloads     []jsonEpisode  `json:"downloads,omitempty"`
+	DanglingFiles []jsonDangling `json:"dangling_files,omitempty"`
+	RefreshErrors []jsonFailure  `json:"refresh_errors,omitempty"`
+	ImportErrors  []jsonImport   `json:"import_errors,omitempty"`
+	Conflicts     []jsonConflict `json:"import_conflicts,omitempty"`
+	History       []jsonHistory  `json:"history,omitempty"`
+}
+
+type jsonPodcast struct {
+	ID            string        `json:"id"`
+	Title         string        `json:"title"`
+	Author        string        `json:"author,omitempty"`
+	FeedURL       string        `json:"feed_url,omitempty"`
+	Language      string        `json:"language,omitempty"`
+	ArtworkURL    string        `json:"artwork_url,omitempty"`
+	DownloadDir   string        `json:"download_dir,omitempty"`
+	Tags          []string      `json:"tags,omitempty"`
+	DiskBytes     int64         `json:"disk_bytes,omitempty"` // Subscriptions only
+	EpisodeCount  int           `json:"episode_count,omitempty"`
+	Duplicate     bool          `json:"likely_duplicate,omitempty"`
+	Subscribed    bool          `json:"subscribed"`
+	NewCount      int           `json:"new_count"`
+	UnplayedCount int           `json:"unplayed_count"`
+	PlayedCount   int           `json:"played_count"`
+	TotalCount    int           `json:"total_count"`
+	LastRefreshed *time.Time    `json:"last_refreshed,omitempty"`
+	NextRefresh   *time.Time    `json:"next_refresh,omitempty"`
+	Funding       []jsonFunding `json:"funding,omitempty"`
+	Value         []jsonValue   `json:"value,omitempty"`
+}
+
+type jsonFunding struct {
+	URL   string `json:"url"`
+	Label string `json:"label,omitempty"`
+}
+
+type jsonValue struct {
+	Type       string               `json:"type"`
+	Method     string               `json:"method,omitempty"`
+	Suggested  string               `json:"suggested,omitempty"`
+	Recipients []jsonValueRecipient `json:"recipients"`
+}
+
+type jsonValueRecipient struct {
+	Name    string `json:"name,omitempty"`
+	Type    string `json:"type,omitempty"`
+	Address string `json:"address"`
+	Split   int    `json:"split"`
+	Fee     bool   `json:"fee,omitempty"`
+}
+
+type jsonEpisode struct {
+	ID           string     `json:"id"`
+	Title        string     `json:"title"`
+	State        string     `json:"state"`
+	PublishedAt  *time.Time `json:"published_at,omitempty"`
+	SizeBytes    int64      `json:"size_bytes"`
+	DurationSec  int        `json:"duration_seconds"`
+	PodcastID    string     `json:"podcast_id"`
+	PodcastTitle string     `json:"podcast_title"`
+	DiskBytes    int64      `json:"disk_bytes,omitempty"`    // Downloads only
+	SizeMismatch bool       `json:"size_mismatch,omitempty"` // Downloads only
+}
+
+type jsonQueued struct {
+	jsonEpisode
+	Status     string    `json:"status"`
+	Priority   string    `json:"priority"`
+	RetryCount int       `json:"retry_count"`
+	LastError  string    `json:"last_error,omitempty"`
+	EnqueuedAt time.Time `json:"enqueued_at"`
+}
+
+type jsonDangling struct {
+	Path      string `json:"path"`
+	SizeBytes int64  `json:"size_bytes"`
+}
+
+type jsonFailure struct {
+	PodcastID string `json:"podcast_id"`
+	Title     string `json:"title"`
+	Error     string `json:"error"`
+}
+
+type jsonImport struct {
+	Title   string `json:"title"`
+	FeedURL string `json:"feed_url"`
+	Error   string `json:"error"`
+}
+
+type jsonConflict struct {
+	PodcastID       string   `json:"podcast_id"`
+	Title           string   `json:"title"`
+	FeedURL         string   `json:"feed_url"`
+	ImportedTitle   string   `json:"imported_title"`
+	ImportedFeedURL string   `json:"imported_feed_url"`
+	Tags            []string `json:"tags,omitempty"`
+}
+
+type jsonHistory struct {
+	PodcastID string    `json:"podcast_id"`
+	Title     string    `json:"title"`
+	FeedURL   string    `json:"feed_url,omitempty"`
+	Action    string    `json:"action"`
+	Source    string    `json:"source,omitempty"`
+	At        time.Time `json:"at"`
+}
+
+// MarshalJSON encodes the result with snake_case keys. Subscription listings
+// are reported as "subscriptions", search results as "podcasts".
+func (r CommandResult) MarshalJSON() ([]byte, error) {
+	out := jsonResult{Message: strings.TrimRight(r.Message, "\n"), MoreEpisodes: r.EpisodesMore}
+	for _, sr := range r.SearchResults {
+		p := jsonPodcast{
+			ID:            sr.Podcast.ID,
+			Title:         sr.Podcast.Title,
+			Author:        sr.Podcast.Author,
+			FeedURL:       sr.Podcast.FeedURL,
+			Language:      sr.Podcast.Language,
+			ArtworkURL:    sr.Podcast.Artwork,
+			DownloadDir:   sr.DownloadDir,
+			Tags:          sr.Tags,
+			DiskBytes:     sr.DiskBytes,
+			EpisodeCount:  sr.Podcast.EpisodeCount,
+			Duplicate:     sr.LikelyDuplicate,
+			Subscribed:    sr.IsSubscribed,
+			NewCount:      sr.NewCount,
+			UnplayedCount: sr.UnplayedCount,
+			PlayedCount:   sr.PlayedCount,
+			TotalCount:    sr.TotalCount,
+		}
+		if !sr.LastRefreshed.IsZero() {
+			refreshed := sr.LastRefreshed
+			p.LastRefreshed = &refreshed
+		}
+		if !sr.NextRefresh.IsZero() {
+			next := sr.NextRefresh
+			p.NextRefresh = &next
+		}
+		for _, f := range sr.Funding {
+			p.Funding = append(p.Funding, jsonFunding{URL: f.URL, Label: f.Label})
+		}
+		for _, v := range sr.Value {
+			value := jsonValue{Type: v.Type, Method: v.Method, Suggested: v.Suggested}
+			for _, r := range v.Recipients {
+				value.Recipients = append(value.Recipients, jsonValueRecipient{Name: r.Name, Type: r.Type, Address: r.Address, Split: r.Split, Fee: r.Fee})
+			}
+			p.Value = append(p.Value, value)
+		}
+		if r.SearchContext == "subscriptions" {
+			out.Subscriptions = append(out.Subscriptions, p)
+		} else {
+			out.Podcasts = append(out.Podcasts, p)
+		}
+	}
+	for _, er := range r.EpisodeResults {
+		out.Episodes = append(out.Episodes, newJSONEpisode(er.Episode, er.PodcastID, er.PodcastTitle))
+	}
+	for _, qr := range r.QueuedEpisodeResults {
+		out.Queue = append(out.Queue, jsonQueued{
+			jsonEpisode: newJSONEpisode(qr.Episode, qr.PodcastID, qr.PodcastTitle),
+			Status:      qr.Status(),
+			Priority:    domain.PriorityName(qr.Priority),
+			RetryCount:  qr.RetryCount,
+			LastError:   qr.LastError,
+			EnqueuedAt:  qr.EnqueuedAt,
+		})
+	}
+	for _, dr := range r.DownloadedEpisodeResults {
+		download := newJSONEpisode(dr.Episode, dr.PodcastID, dr.PodcastTitle)
+		download.DiskBytes = dr.DiskBytes
+		download.SizeMismatch = dr.SizeMismatch()
+		out.Downloads = append(out.Downloads, download)
+	}
+	for _, f := range r.DanglingFiles {
+		out.DanglingFiles = append(out.DanglingFiles, jsonDangling{Path: f.Path, SizeBytes: f.SizeBytes})
+	}
+	for _, f := range r.RefreshFailures {
+		out.RefreshErrors = append(out.RefreshErrors, jsonFailure{PodcastID: f.PodcastID, Title: f.Title, Error: f.Err})
+	}
+	for _, f := range r.ImportFailures {
+		out.ImportErrors = append(out.ImportErrors, jsonImport{Title: f.Title, FeedURL: f.FeedURL, Error: f.Err})
+	}
+	for _, c := range r.ImportConflicts {
+		out.Conflicts = append(out.Conflicts, jsonConflict{PodcastID: c.PodcastID, Title: c.Title, FeedURL: c.FeedURL, ImportedTitle: c.ImportedTitle, ImportedFeedURL: c.ImportedFeedURL, Tags: c.Tags})
+	}
+	for _, e := range r.SubscriptionHistory {
+		out.History = append(out.History, jsonHistory{PodcastID: e.PodcastID, Title: e.Title, FeedURL: e.FeedURL, Action: e.Action, Source: e.Source, At: e.At})
+	}
+	return json.Marshal(out)
+}
+
+func newJSONEpisode(ep domain.EpisodeRow, podcastID, podcastTitle string) jsonEpisode {
+	out := jsonEpisode{
+		ID:           ep.ID,
+		Title:        ep.Title,
+		State:        ep.State,
+		SizeBytes:    ep.SizeBytes,
+		DurationSec:  ep.DurationSec,
+		PodcastID:    podcastID,
+		PodcastTitle: podcastTitle,
+	}
+	if ep.HasPublish {
+		published := ep.PublishedAt
+		out.PublishedAt = &published
+	}
+	return out
+}
@@ -0,0 +1,33 @@
+package app
+
+import (
+	"strings"
+
+	"podsink/internal/feeds"
+)
+
+// languagePrefix marks a language filter argument, as in "lang:de".
+const languagePrefix = "lang:"
+
+// splitLanguageFilter removes lang:<tag> arguments from args. It returns the
+// last tag given, normalized, and the remaining arguments.
+func splitLanguageFilter(args []string) (string, []string) {
+	var language string
+	rest := make([]string, 0, len(args))
+	for _, arg := range args {
+		if len(arg) > len(languagePrefix) && strings.EqualFold(arg[:len(languagePrefix)], languagePrefix) {
+			language = feeds.NormalizeLanguage(arg[len(languagePrefix):])
+			continue
+		}
+		rest = append(rest, arg)
+	}
+	return language, rest
+}
+
+// matchesLanguage reports whether a feed's language tag is want or one of its
+// regional variants: "de" matches "de" and "de-at", "de-at" only "de-at".
+// An unknown language matches nothing.
+func matchesLanguage(tag, want string) bool {
+	tag = feeds.NormalizeLanguage(tag)
+	return tag != "" && (tag == want || strings.HasPrefix(tag, want+"-"))
+}
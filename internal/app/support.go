@@ -0,0 +1,156 @@
+package app
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"podsink/internal/config"
+	"podsink/internal/domain"
+	"podsink/internal/storage"
+)
+
+// supportLogBytes bounds how much of the end of the log goes into a support
+// bundle.
+const supportLogBytes = 2 << 20
+
+// supportInfo is written to info.json in a support bundle.
+type supportInfo struct {
+	GeneratedAt           time.Time            `json:"generated_at"`
+	OS                    string               `json:"os"`
+	Arch                  string               `json:"arch"`
+	GoVersion             string               `json:"go_version"`
+	ReadOnly              bool                 `json:"read_only"`
+	ExpectedSchemaVersion int                  `json:"expected_schema_version"`
+	Database              domain.DatabaseStats `json:"database"`
+}
+
+func (a *App) supportBundleCommand(ctx context.Context, args []string) (CommandResult, error) {
+	if len(args) > 1 {
+		return CommandResult{Message: "Usage: support-bundle [file]"}, nil
+	}
+	path := filepath.Join(filepath.Dir(a.configPath), "podsink-support-"+time.Now().Format("20060102-150405")+".tar.gz")
+	if len(args) == 1 {
+		expanded, err := config.ExpandPath(args[0])
+		if err != nil {
+			return CommandResult{}, err
+		}
+		path = expanded
+	}
+	if err := a.WriteSupportBundle(ctx, path); err != nil {
+		return CommandResult{}, err
+	}
+	return CommandResult{Message: fmt.Sprintf("Wrote %s with recent logs, the config (secrets redacted) and database statistics. Attach it to your bug report.", path)}, nil
+}
+
+// WriteSupportBundle writes a gzipped tarball for bug reports to path: the
+// end of the log, the config with its secrets redacted and info.json with
+// the platform, schema version and database statistics.
+func (a *App) WriteSupportBundle(ctx context.Context, path string) (err error) {
+	stats, err := a.episodes.DatabaseStats(ctx)
+	if err != nil {
+		return fmt.Errorf("collect database statistics: %w", err)
+	}
+	info, err := json.MarshalIndent(supportInfo{
+		GeneratedAt:           time.Now().UTC(),
+		OS:                    runtime.GOOS,
+		Arch:                  runtime.GOARCH,
+		GoVersion:             runtime.Version(),
+		ReadOnly:              a.readOnly,
+		ExpectedSchemaVersion: storage.SchemaVersion,
+		Database:              stats,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	cfg, err := yaml.Marshal(a.config.Redacted())
+	if err != nil {
+		return err
+	}
+	logData, err := readLogTail(filepath.Join(filepath.Dir(a.configPath), "podsink.log"), supportLogBytes)
+	if err != nil {
+		return fmt.Errorf("read log: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(path)
+		}
+	}()
+	gz := gzip.NewWriter(file)
+	tw := tar.NewWriter(gz)
+	now := time.Now()
+	for _, entry := range []struct {
+		name string
+		data []byte
+	}{
+		{"info.json", info},
+		{"config.yaml", cfg},
+		{"podsink.log", []byte(a.config.RedactSecrets(string(logData)))},
+	} {
+		header := &tar.Header{Name: entry.name, Mode: 0o600, Size: int64(len(entry.data)), ModTime: now}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := tw.Write(entry.data); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	log.Printf("support bundle written to %s", path)
+	return nil
+}
+
+// readLogTail returns up to limit bytes from the end of the log, starting at
+// a line boundary. A missing log reads as empty.
+func readLogTail(path string, limit int64) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	offset := info.Size() - limit
+	if offset < 0 {
+		offset = 0
+	}
+	data, err := io.ReadAll(io.NewSectionReader(file, offset, info.Size()-offset))
+	if err != nil {
+		return nil, err
+	}
+	if offset > 0 {
+		if i := bytes.IndexByte(data, '\n'); i >= 0 {
+			data = data[i+1:]
+		}
+	}
+	return data, nil
+}
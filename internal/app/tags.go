@@ -0,0 +1,96 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"podsink/internal/opml"
+	"podsink/internal/subscriptions"
+)
+
+const (
+	tagUsage   = "Usage: tag <podcast_id> <tag>"
+	untagUsage = "Usage: untag <podcast_id> <tag>"
+)
+
+func (a *App) tagCommand(ctx context.Context, args []string) (CommandResult, error) {
+	if len(args) < 2 {
+		return CommandResult{Message: tagUsage}, nil
+	}
+	tag := strings.Join(args[1:], " ")
+	ok, err := a.subscriptions.Tag(ctx, args[0], tag)
+	if message, handled := tagError(err); handled {
+		return CommandResult{Message: message}, nil
+	}
+	if err != nil {
+		return CommandResult{}, err
+	}
+	if !ok {
+		return CommandResult{Message: "No subscription found for that podcast."}, nil
+	}
+	return CommandResult{Message: fmt.Sprintf("Tagged %s with %q.", args[0], strings.TrimSpace(tag))}, nil
+}
+
+func (a *App) untagCommand(ctx context.Context, args []string) (CommandResult, error) {
+	if len(args) < 2 {
+		return CommandResult{Message: untagUsage}, nil
+	}
+	tag := strings.Join(args[1:], " ")
+	ok, err := a.subscriptions.Untag(ctx, args[0], tag)
+	if message, handled := tagError(err); handled {
+		return CommandResult{Message: message}, nil
+	}
+	if err != nil {
+		return CommandResult{}, err
+	}
+	if !ok {
+		return CommandResult{Message: fmt.Sprintf("%s is not tagged %q.", args[0], strings.TrimSpace(tag))}, nil
+	}
+	return CommandResult{Message: fmt.Sprintf("Removed tag %q from %s.", strings.TrimSpace(tag), args[0])}, nil
+}
+
+// tagError turns the input errors of tagging into a message.
+func tagError(err error) (string, bool) {
+	switch {
+	case errors.Is(err, subscriptions.ErrMissingPodcastID):
+		return "Podcast ID cannot be empty.", true
+	case errors.Is(err, subscriptions.ErrMissingTag):
+		return "Tag cannot be empty.", true
+	}
+	return "", false
+}
+
+// splitTagFilter pulls a --tag <tag> or --tag=<tag> option out of args.
+func splitTagFilter(args []string) (string, []string, error) {
+	var tag string
+	rest := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--tag":
+			if i+1 >= len(args) {
+				return "", nil, errors.New("--tag needs a tag")
+			}
+			i++
+			tag = args[i]
+		case strings.HasPrefix(arg, "--tag="):
+			tag = strings.TrimPrefix(arg, "--tag=")
+		default:
+			rest = append(rest, arg)
+		}
+	}
+	return strings.Trim(strings.TrimSpace(tag), opml.TagSeparator), rest, nil
+}
+
+// matchesTag reports whether one of tags is want or nested below it,
+// ignoring case: "news" matches "News" and "News/Tech".
+func matchesTag(tags []string, want string) bool {
+	for _, tag := range tags {
+		if strings.EqualFold(tag, want) || (len(tag) > len(want) && strings.EqualFold(tag[:len(want)+1], want+opml.TagSeparator)) {
+			return true
+		}
+	}
+	return false
+}
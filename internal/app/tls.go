@@ -0,0 +1,74 @@
+package app
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"strings"
+
+	"podsink/internal/config"
+)
+
+// newTLSConfig applies tls_verify, ca_file and tls_pins. An unreadable
+// ca_file fails closed: without its roots no certificate verifies.
+func newTLSConfig(cfg config.Config) *tls.Config {
+	tlsConfig := &tls.Config{InsecureSkipVerify: !cfg.TLSVerify}
+	if caFile := strings.TrimSpace(cfg.CAFile); caFile != "" {
+		pool, err := config.LoadCAFile(caFile)
+		if err != nil {
+			log.Printf("load ca_file failed: %v", err)
+			pool = x509.NewCertPool()
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	pins := make(map[string][][]byte, len(cfg.TLSPins))
+	for host, encoded := range cfg.TLSPins {
+		host = strings.ToLower(strings.TrimSpace(host))
+		for _, pin := range encoded {
+			if digest, err := config.ParsePin(pin); err == nil {
+				pins[host] = append(pins[host], digest)
+			}
+		}
+		if len(pins[host]) == 0 {
+			// Every pin was invalid; keep the host pinned so nothing matches.
+			pins[host] = [][]byte{}
+		}
+	}
+	if len(pins) > 0 {
+		tlsConfig.VerifyConnection = func(state tls.ConnectionState) error {
+			return verifyPins(pins, state)
+		}
+	}
+	return tlsConfig
+}
+
+// verifyPins accepts a connection to a pinned host only when some certificate
+// in its chain has a public key matching one of the host's pins. Hosts
+// without pins are left to ordinary verification.
+func verifyPins(pins map[string][][]byte, state tls.ConnectionState) error {
+	host := strings.ToLower(state.ServerName)
+	expected, ok := pins[host]
+	if !ok {
+		return nil
+	}
+	chains := state.VerifiedChains
+	if len(chains) == 0 {
+		// tls_verify is off; only the presented certificates are available
+		chains = [][]*x509.Certificate{state.PeerCertificates}
+	}
+	for _, chain := range chains {
+		for _, cert := range chain {
+			digest := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+			for _, pin := range expected {
+				if bytes.Equal(digest[:], pin) {
+					return nil
+				}
+			}
+		}
+	}
+	return fmt.Errorf("certificate for %s does not match any pin in tls_pins", host)
+}
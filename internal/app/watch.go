@@ -0,0 +1,61 @@
+package app
+
+import (
+	"context"
+	"log"
+
+	"podsink/internal/config"
+	"podsink/internal/watch"
+)
+
+// startWatcher follows the download root for files added or removed outside
+// podsink when watch_downloads is set. Like the download workers, it only
+// runs in the instance that owns the library.
+func (a *App) startWatcher(cfg config.Config) {
+	if !cfg.WatchDownloads || !a.background {
+		return
+	}
+	skip := []string{cfg.TmpDir, config.TrashDir(cfg.DownloadRoot), config.OriginalsDir(cfg.DownloadRoot)}
+	watcher, err := watch.Start(a.baseCtx, cfg.DownloadRoot, skip, a.downloadFilesChanged)
+	if err != nil {
+		log.Printf("watch %s: %v", cfg.DownloadRoot, err)
+		return
+	}
+	a.watcher = watcher
+}
+
+// downloadFilesChanged marks episodes whose file was removed as DELETED and
+// tells the views to list the downloads and dangling files again.
+func (a *App) downloadFilesChanged(ctx context.Context) {
+	if _, err := a.episodes.CheckDeletedFiles(ctx); err != nil {
+		if ctx.Err() == nil {
+			log.Printf("check deleted files: %v", err)
+		}
+		return
+	}
+	a.events.Publish(Event{Kind: EventFilesChanged})
+}
+
+// checkDeletedFilesInBackground runs a deleted-file check unless one is
+// running, and tells the views to list the downloads again when it marks
+// any episode DELETED.
+func (a *App) checkDeletedFilesInBackground() {
+	if !a.checkingFiles.CompareAndSwap(false, true) {
+		return
+	}
+	a.checks.Add(1)
+	go func() {
+		defer a.checks.Done()
+		defer a.checkingFiles.Store(false)
+		marked, err := a.episodes.CheckDeletedFiles(a.baseCtx)
+		if err != nil {
+			if a.baseCtx.Err() == nil {
+				log.Printf("check deleted files: %v", err)
+			}
+			return
+		}
+		if marked > 0 {
+			a.events.Publish(Event{Kind: EventFilesChanged})
+		}
+	}()
+}
@@ -0,0 +1,145 @@
+// Package artwork downloads podcast cover art into a local cache, so the
+// details view can show it and players that expect cover art can be pointed
+// at a file.
+package artwork
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// maxImageBytes bounds the size of a downloaded image.
+const maxImageBytes = 10 << 20
+
+// ErrNotImage is returned when the artwork URL serves something other than
+// a JPEG, PNG, GIF or WebP image.
+var ErrNotImage = errors.New("artwork is not an image")
+
+var invalidPathChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// extensions maps sniffed content types to file extensions.
+var extensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// Cache keeps one image per podcast below its directory, named after a hash
+// of the image URL so a feed that changes its artwork is fetched again.
+type Cache struct {
+	dir       string
+	client    *http.Client
+	userAgent string
+
+	mu sync.Mutex // Serializes downloads
+}
+
+// NewCache creates a cache in dir, which is created on the first download.
+func NewCache(dir string, client *http.Client, userAgent string) *Cache {
+	return &Cache{dir: dir, client: client, userAgent: userAgent}
+}
+
+// Fetch returns the path of the podcast's artwork, downloading imageURL
+// first unless the cache already holds it. Artwork the podcast used before
+// is removed.
+func (c *Cache) Fetch(ctx context.Context, podcastID, imageURL string) (string, error) {
+	imageURL = strings.TrimSpace(imageURL)
+	parsed, err := url.Parse(imageURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return "", fmt.Errorf("invalid artwork URL %q", imageURL)
+	}
+	name := strings.Trim(invalidPathChars.ReplaceAllString(podcastID, "_"), "._")
+	if name == "" {
+		return "", errors.New("podcast ID cannot be empty")
+	}
+	sum := sha1.Sum([]byte(imageURL))
+	base := hex.EncodeToString(sum[:8])
+	dir := filepath.Join(c.dir, name)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if matches, _ := filepath.Glob(filepath.Join(dir, base+".*")); len(matches) > 0 {
+		return matches[0], nil
+	}
+
+	data, err := c.download(ctx, imageURL)
+	if err != nil {
+		return "", err
+	}
+	ext, ok := extensions[http.DetectContentType(data)]
+	if !ok {
+		return "", ErrNotImage
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	tmp, err := os.CreateTemp(dir, ".download-*")
+	if err != nil {
+		return "", err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	target := filepath.Join(dir, base+ext)
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return target, nil
+	}
+	for _, entry := range entries {
+		if entry.Name() != base+ext {
+			os.Remove(filepath.Join(dir, entry.Name()))
+		}
+	}
+	return target, nil
+}
+
+func (c *Cache) download(ctx context.Context, imageURL string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, imageURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if ua := strings.TrimSpace(c.userAgent); ua != "" {
+		req.Header.Set("User-Agent", ua)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("fetch artwork: unexpected status %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxImageBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxImageBytes {
+		return nil, fmt.Errorf("artwork exceeds %d MB", maxImageBytes>>20)
+	}
+	return data, nil
+}
@@ -0,0 +1,62 @@
+package artwork
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+func TestFetchCachesAndReplacesArtwork(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 2, 2))); err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.URL.Path == "/page.html" {
+			w.Write([]byte("<html><body>Not found</body></html>"))
+			return
+		}
+		w.Write(buf.Bytes())
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	dir := t.TempDir()
+	cache := NewCache(dir, server.Client(), "podsink/test")
+
+	first, err := cache.Fetch(ctx, "pi-42", server.URL+"/cover.png")
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if filepath.Dir(first) != filepath.Join(dir, "pi-42") || filepath.Ext(first) != ".png" {
+		t.Fatalf("unexpected artwork path %s", first)
+	}
+	if again, err := cache.Fetch(ctx, "pi-42", server.URL+"/cover.png"); err != nil || again != first || requests.Load() != 1 {
+		t.Fatalf("expected cached artwork, got %s, %v after %d requests", again, err, requests.Load())
+	}
+
+	second, err := cache.Fetch(ctx, "pi-42", server.URL+"/new-cover.png")
+	if err != nil || second == first {
+		t.Fatalf("expected new artwork for a new URL, got %s, %v", second, err)
+	}
+	if _, err := os.Stat(first); !os.IsNotExist(err) {
+		t.Fatalf("expected old artwork to be removed, stat error = %v", err)
+	}
+
+	if _, err := cache.Fetch(ctx, "pi-42", server.URL+"/page.html"); !errors.Is(err, ErrNotImage) {
+		t.Fatalf("expected ErrNotImage for an HTML page, got %v", err)
+	}
+	if _, err := cache.Fetch(ctx, "pi-42", "file:///etc/passwd"); err == nil {
+		t.Fatal("expected an error for a non-HTTP URL")
+	}
+}
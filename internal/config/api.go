@@ -0,0 +1,62 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// minAPITokenLength keeps api_token from being trivially guessable.
+const minAPITokenLength = 16
+
+func validateAPIListen(ans interface{}) error {
+	addr := strings.TrimSpace(ans.(string))
+	if addr == "" {
+		return nil
+	}
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		return fmt.Errorf("expected host:port: %w", err)
+	}
+	return nil
+}
+
+// validateHTTPURL accepts an empty answer or an absolute http(s) URL, for
+// websub_callback_url and hook URLs.
+func validateHTTPURL(ans interface{}) error {
+	raw := strings.TrimSpace(ans.(string))
+	if raw == "" {
+		return nil
+	}
+	parsed, err := url.Parse(raw)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return errors.New("expected an http:// or https:// URL")
+	}
+	return nil
+}
+
+func validateAPI(cfg Config) error {
+	addr := strings.TrimSpace(cfg.APIListen)
+	if callback := strings.TrimSpace(cfg.WebSubCallbackURL); callback != "" {
+		if addr == "" {
+			return errors.New("websub_callback_url requires api_listen")
+		}
+		if err := validateHTTPURL(callback); err != nil {
+			return fmt.Errorf("websub_callback_url: %w", err)
+		}
+	}
+	if addr == "" {
+		return nil
+	}
+	if err := validateAPIListen(addr); err != nil {
+		return fmt.Errorf("api_listen: %w", err)
+	}
+	switch token := strings.TrimSpace(cfg.APIToken); {
+	case token == "":
+		return errors.New("api_token is required when api_listen is set")
+	case len(token) < minAPITokenLength:
+		return fmt.Errorf("api_token must be at least %d characters", minAPITokenLength)
+	}
+	return nil
+}
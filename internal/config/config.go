@@ -7,10 +7,14 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"strings"
 
 	"github.com/AlecAivazis/survey/v2"
+	"github.com/AlecAivazis/survey/v2/core"
 	"github.com/AlecAivazis/survey/v2/terminal"
+	"github.com/kballard/go-shellquote"
 	"gopkg.in/yaml.v3"
 
 	"podsink/internal/theme"
@@ -18,19 +22,121 @@ import (
 
 // Config represents the persisted application configuration.
 type Config struct {
-	DownloadRoot               string `yaml:"download_root"`
-	ParallelDownloads          int    `yaml:"parallel_downloads"`
-	TmpDir                     string `yaml:"tmp_dir"`
-	RetryCount                 int    `yaml:"retry_count"`
-	RetryBackoffMaxSec         int    `yaml:"retry_backoff_max_seconds"`
-	UserAgent                  string `yaml:"user_agent"`
-	Proxy                      string `yaml:"proxy,omitempty"`
-	TLSVerify                  bool   `yaml:"tls_verify"`
-	ColorTheme                 string `yaml:"color_theme"`
-	MaxEpisodes                int    `yaml:"max_episodes"`
-	MaxEpisodeDescriptionLines int    `yaml:"max_episode_description_lines"`
-	PodcastNameMaxLength       int    `yaml:"podcast_name_max_length"`
-	EpisodeNameMaxLength       int    `yaml:"episode_name_max_length"`
+	DownloadRoot               string              `yaml:"download_root"`
+	ParallelDownloads          int                 `yaml:"parallel_downloads"`
+	TmpDir                     string              `yaml:"tmp_dir"`
+	FilenameTemplate           string              `yaml:"filename_template"`
+	RetryCount                 int                 `yaml:"retry_count"`
+	RetryBackoffMaxSec         int                 `yaml:"retry_backoff_max_seconds"`
+	UserAgent                  string              `yaml:"user_agent"`
+	Proxy                      string              `yaml:"proxy,omitempty"`
+	TLSVerify                  bool                `yaml:"tls_verify"`
+	CAFile                     string              `yaml:"ca_file,omitempty"`
+	TLSPins                    map[string][]string `yaml:"tls_pins,omitempty"`
+	ColorTheme                 string              `yaml:"color_theme"`
+	OutputFormat               string              `yaml:"output_format"`
+	Directory                  string              `yaml:"directory"`
+	PodcastIndexKey            string              `yaml:"podcastindex_key,omitempty"`
+	PodcastIndexSecret         string              `yaml:"podcastindex_secret,omitempty"`
+	Player                     string              `yaml:"player"`
+	ArtworkProtocol            string              `yaml:"artwork_protocol"`
+	StartupView                string              `yaml:"startup_view"`
+	MaxEpisodes                int                 `yaml:"max_episodes"`
+	MaxEpisodeDescriptionLines int                 `yaml:"max_episode_description_lines"`
+	PodcastNameMaxLength       int                 `yaml:"podcast_name_max_length"`
+	EpisodeNameMaxLength       int                 `yaml:"episode_name_max_length"`
+	MarkSeenOnList             bool                `yaml:"mark_seen_on_list"`
+	DedupeHardlinks            bool                `yaml:"dedupe_hardlinks"`
+	PruneEmptyDirs             bool                `yaml:"prune_empty_dirs"`
+	WatchDownloads             bool                `yaml:"watch_downloads"`
+	TrashDays                  int                 `yaml:"trash_days"`
+	MaxStoredEpisodes          int                 `yaml:"max_stored_episodes"`
+	TranscodeFormat            string              `yaml:"transcode_format,omitempty"`
+	TranscodeBitrate           string              `yaml:"transcode_bitrate"`
+	TranscodeKeepOriginal      bool                `yaml:"transcode_keep_original"`
+	EmbedTags                  bool                `yaml:"embed_tags"`
+	RefreshIntervalMinutes     int                 `yaml:"refresh_interval_minutes"`
+	NotifyOnNew                bool                `yaml:"notify_on_new"`
+	NotifyCommand              string              `yaml:"notify_command,omitempty"`
+	MaxEpisodeSizeMB           int                 `yaml:"max_episode_size_mb"`
+	MaxFeedSizeMB              int                 `yaml:"max_feed_size_mb"`
+	FeedTimeoutSeconds         int                 `yaml:"feed_timeout_seconds"`
+	ImportConcurrency          int                 `yaml:"import_concurrency"`
+	StripTrackingPrefixes      bool                `yaml:"strip_tracking_prefixes"`
+	MaxRedirects               int                 `yaml:"max_redirects"`
+	AllowHTTP                  bool                `yaml:"allow_http"`
+	GpodderServer              string              `yaml:"gpodder_server,omitempty"`
+	GpodderUsername            string              `yaml:"gpodder_username,omitempty"`
+	GpodderPassword            string              `yaml:"gpodder_password,omitempty"`
+	GpodderDevice              string              `yaml:"gpodder_device,omitempty"`
+	URLRewrites                []URLRewrite        `yaml:"url_rewrites,omitempty"`
+	Hooks                      []Hook              `yaml:"hooks,omitempty"`
+	DBBusyTimeoutMS            int                 `yaml:"db_busy_timeout_ms"`
+	DBCacheSizeKB              int                 `yaml:"db_cache_size_kb"`
+	DBSynchronous              string              `yaml:"db_synchronous,omitempty"`
+	DBMmapSizeMB               int                 `yaml:"db_mmap_size_mb"`
+	DBMaxOpenConns             int                 `yaml:"db_max_open_conns"`
+	PostProcess                []PostProcessStep   `yaml:"post_process,omitempty"`
+	IngestFilters              []IngestFilter      `yaml:"ingest_filters,omitempty"`
+	Playlists                  []Playlist          `yaml:"playlists,omitempty"`
+	APIListen                  string              `yaml:"api_listen,omitempty"`
+	APIToken                   string              `yaml:"api_token,omitempty"`
+	WebSubCallbackURL          string              `yaml:"websub_callback_url,omitempty"`
+}
+
+// Output formats for commands run from the shell instead of the REPL.
+const (
+	OutputText = "text"
+	OutputJSON = "json"
+)
+
+// DefaultPlayer is the external player command. {file} is replaced by the
+// downloaded file or the enclosure URL, {start} by the start offset in
+// seconds.
+const DefaultPlayer = "mpv --start={start} {file}"
+
+// DefaultFilenameTemplate lays downloads out as one folder per podcast
+// holding files named after the episode title.
+const DefaultFilenameTemplate = "{podcast}/{title}.{ext}"
+
+// FilenameFields are the placeholders filename_template may use.
+var FilenameFields = []string{"podcast", "title", "date", "number", "guid", "ext"}
+
+var filenamePlaceholder = regexp.MustCompile(`\{([^{}]*)\}`)
+
+// Terminal graphics protocols for drawing podcast artwork. ArtworkAuto
+// picks one from the environment; ArtworkNone only shows the cached file.
+const (
+	ArtworkAuto  = "auto"
+	ArtworkKitty = "kitty"
+	ArtworkSixel = "sixel"
+	ArtworkNone  = "none"
+)
+
+// Views the menu can open on start instead of the command menu.
+const (
+	ViewMenu          = "menu"
+	ViewEpisodes      = "episodes"
+	ViewQueue         = "queue"
+	ViewDownloads     = "downloads"
+	ViewSubscriptions = "subscriptions"
+)
+
+// StartupViews lists the values startup_view accepts.
+var StartupViews = []string{ViewMenu, ViewEpisodes, ViewQueue, ViewDownloads, ViewSubscriptions}
+
+// Podcast directories searched by the search command.
+const (
+	DirectoryITunes       = "itunes"
+	DirectoryPodcastIndex = "podcastindex"
+	DirectoryBoth         = "both"
+)
+
+// URLRewrite replaces matches of the regular expression Pattern in enclosure
+// URLs before downloading. Replacement may refer to groups as $1 or ${name}.
+type URLRewrite struct {
+	Pattern     string `yaml:"pattern"`
+	Replacement string `yaml:"replacement"`
 }
 
 // Defaults returns the baseline configuration used on first run.
@@ -40,19 +146,46 @@ func Defaults() Config {
 	return Config{
 		DownloadRoot:               downloadRoot,
 		ParallelDownloads:          4,
-		TmpDir:                     os.TempDir(),
+		TmpDir:                     DefaultTmpDir(downloadRoot),
+		FilenameTemplate:           DefaultFilenameTemplate,
 		RetryCount:                 3,
 		RetryBackoffMaxSec:         60,
 		UserAgent:                  "podsink/dev",
 		TLSVerify:                  true,
 		ColorTheme:                 theme.Default,
+		OutputFormat:               OutputText,
+		Directory:                  DirectoryITunes,
+		Player:                     DefaultPlayer,
+		ArtworkProtocol:            ArtworkAuto,
+		StartupView:                ViewMenu,
 		MaxEpisodes:                12,
 		MaxEpisodeDescriptionLines: 12,
 		PodcastNameMaxLength:       16,
 		EpisodeNameMaxLength:       40,
+		TrashDays:                  30,
+		TranscodeBitrate:           DefaultTranscodeBitrate,
+		RefreshIntervalMinutes:     60,
+		MaxFeedSizeMB:              50,
+		FeedTimeoutSeconds:         30,
+		ImportConcurrency:          8,
+		MaxRedirects:               10,
+		AllowHTTP:                  true,
+		DBBusyTimeoutMS:            5000,
 	}
 }
 
+// TrashDir returns the folder deleted downloads are kept in for trash_days.
+func TrashDir(downloadRoot string) string {
+	return filepath.Join(downloadRoot, ".trash")
+}
+
+// DefaultTmpDir returns the partial-download directory used when none is
+// configured. Keeping it under the download root lets finished episodes be
+// renamed into place instead of copied across filesystems.
+func DefaultTmpDir(downloadRoot string) string {
+	return filepath.Join(downloadRoot, ".tmp")
+}
+
 // Ensure loads configuration from the provided path, prompting the user to
 // create one if it does not yet exist.
 func Ensure(ctx context.Context, path string) (Config, error) {
@@ -83,18 +216,110 @@ func Load(path string) (Config, error) {
 	if err != nil {
 		return Config{}, err
 	}
-	var cfg Config
+	// Seed fields whose zero value is meaningful so older files keep the default.
+	defaults := Defaults()
+	cfg := Config{
+		TrashDays:              defaults.TrashDays,
+		RefreshIntervalMinutes: defaults.RefreshIntervalMinutes,
+		MaxFeedSizeMB:          defaults.MaxFeedSizeMB,
+		FeedTimeoutSeconds:     defaults.FeedTimeoutSeconds,
+		MaxRedirects:           defaults.MaxRedirects,
+		AllowHTTP:              defaults.AllowHTTP,
+		DBBusyTimeoutMS:        defaults.DBBusyTimeoutMS,
+	}
 	if err := yaml.Unmarshal(data, &cfg); err != nil {
 		return Config{}, fmt.Errorf("parse config: %w", err)
 	}
 	if strings.TrimSpace(cfg.ColorTheme) == "" {
 		cfg.ColorTheme = theme.Default
 	}
+	if strings.TrimSpace(cfg.TmpDir) == "" {
+		cfg.TmpDir = DefaultTmpDir(cfg.DownloadRoot)
+	}
+	if strings.TrimSpace(cfg.FilenameTemplate) == "" {
+		cfg.FilenameTemplate = defaults.FilenameTemplate
+	}
+	if err := validateFilenameTemplate(cfg.FilenameTemplate); err != nil {
+		return Config{}, fmt.Errorf("parse config: filename_template: %w", err)
+	}
+	switch cfg.OutputFormat {
+	case "":
+		cfg.OutputFormat = defaults.OutputFormat
+	case OutputText, OutputJSON:
+	default:
+		return Config{}, fmt.Errorf("parse config: output_format must be %q or %q, got %q", OutputText, OutputJSON, cfg.OutputFormat)
+	}
+	if err := validateDirectory(&cfg); err != nil {
+		return Config{}, fmt.Errorf("parse config: %w", err)
+	}
+	if strings.TrimSpace(cfg.Player) == "" {
+		cfg.Player = defaults.Player
+	}
+	if err := validatePlayer(cfg.Player); err != nil {
+		return Config{}, fmt.Errorf("parse config: player: %w", err)
+	}
+	switch cfg.ArtworkProtocol {
+	case "":
+		cfg.ArtworkProtocol = defaults.ArtworkProtocol
+	case ArtworkAuto, ArtworkKitty, ArtworkSixel, ArtworkNone:
+	default:
+		return Config{}, fmt.Errorf("parse config: artwork_protocol must be one of %q, %q, %q or %q, got %q",
+			ArtworkAuto, ArtworkKitty, ArtworkSixel, ArtworkNone, cfg.ArtworkProtocol)
+	}
+	if cfg.StartupView == "" {
+		cfg.StartupView = defaults.StartupView
+	} else if !slices.Contains(StartupViews, cfg.StartupView) {
+		return Config{}, fmt.Errorf("parse config: startup_view must be one of %s, got %q", strings.Join(StartupViews, ", "), cfg.StartupView)
+	}
 	if cfg.MaxEpisodes <= 0 {
-		cfg.MaxEpisodes = Defaults().MaxEpisodes
+		cfg.MaxEpisodes = defaults.MaxEpisodes
 	}
 	if cfg.MaxEpisodeDescriptionLines <= 0 {
-		cfg.MaxEpisodeDescriptionLines = Defaults().MaxEpisodeDescriptionLines
+		cfg.MaxEpisodeDescriptionLines = defaults.MaxEpisodeDescriptionLines
+	}
+	if cfg.ImportConcurrency <= 0 {
+		cfg.ImportConcurrency = defaults.ImportConcurrency
+	}
+	if cfg.TrashDays < 0 {
+		return Config{}, fmt.Errorf("parse config: trash_days must not be negative, got %d", cfg.TrashDays)
+	}
+	if cfg.MaxStoredEpisodes < 0 {
+		return Config{}, fmt.Errorf("parse config: max_stored_episodes must not be negative, got %d", cfg.MaxStoredEpisodes)
+	}
+	if err := validateDatabaseTuning(&cfg); err != nil {
+		return Config{}, fmt.Errorf("parse config: %w", err)
+	}
+	cfg.TranscodeBitrate = strings.TrimSpace(cfg.TranscodeBitrate)
+	if cfg.TranscodeBitrate == "" {
+		cfg.TranscodeBitrate = defaults.TranscodeBitrate
+	}
+	if err := validateTranscode(cfg); err != nil {
+		return Config{}, fmt.Errorf("parse config: %w", err)
+	}
+	for i, rule := range cfg.URLRewrites {
+		if _, err := regexp.Compile(rule.Pattern); err != nil {
+			return Config{}, fmt.Errorf("parse config: url_rewrites[%d]: %w", i, err)
+		}
+	}
+	for i, hook := range cfg.Hooks {
+		if err := validateHook(hook); err != nil {
+			return Config{}, fmt.Errorf("parse config: hooks[%d]: %w", i, err)
+		}
+	}
+	if err := validatePostProcess(cfg.PostProcess); err != nil {
+		return Config{}, fmt.Errorf("parse config: %w", err)
+	}
+	if err := validateIngestFilters(cfg.IngestFilters); err != nil {
+		return Config{}, fmt.Errorf("parse config: %w", err)
+	}
+	if err := validatePlaylists(cfg.Playlists); err != nil {
+		return Config{}, fmt.Errorf("parse config: %w", err)
+	}
+	if err := validateTLS(cfg); err != nil {
+		return Config{}, fmt.Errorf("parse config: %w", err)
+	}
+	if err := validateAPI(cfg); err != nil {
+		return Config{}, fmt.Errorf("parse config: %w", err)
 	}
 	return cfg, nil
 }
@@ -119,7 +344,7 @@ func Save(path string, cfg Config) error {
 
 func bootstrap(ctx context.Context, cfg *Config) error {
 	if fromEnv := strings.TrimSpace(os.Getenv("PODSINK_DOWNLOAD_ROOT")); fromEnv != "" {
-		resolved, err := expandPath(fromEnv)
+		resolved, err := ExpandPath(fromEnv)
 		if err != nil {
 			return err
 		}
@@ -127,6 +352,7 @@ func bootstrap(ctx context.Context, cfg *Config) error {
 			return fmt.Errorf("create download directory: %w", err)
 		}
 		cfg.DownloadRoot = resolved
+		cfg.TmpDir = DefaultTmpDir(resolved)
 		return nil
 	}
 
@@ -148,7 +374,7 @@ func bootstrap(ctx context.Context, cfg *Config) error {
 		return fmt.Errorf("download directory cannot be empty")
 	}
 
-	resolved, err := expandPath(answer)
+	resolved, err := ExpandPath(answer)
 	if err != nil {
 		return err
 	}
@@ -158,6 +384,7 @@ func bootstrap(ctx context.Context, cfg *Config) error {
 	}
 
 	cfg.DownloadRoot = resolved
+	cfg.TmpDir = DefaultTmpDir(resolved)
 	return nil
 }
 
@@ -168,14 +395,55 @@ func EditableKeys() []string {
 		"download_root",
 		"parallel_downloads",
 		"tmp_dir",
+		"filename_template",
 		"retry_count",
 		"retry_backoff_max_seconds",
 		"user_agent",
 		"proxy",
 		"tls_verify",
+		"ca_file",
 		"color_theme",
+		"output_format",
+		"directory",
+		"podcastindex_key",
+		"podcastindex_secret",
+		"player",
+		"artwork_protocol",
+		"startup_view",
 		"max_episodes",
 		"max_episode_description_lines",
+		"mark_seen_on_list",
+		"dedupe_hardlinks",
+		"prune_empty_dirs",
+		"watch_downloads",
+		"trash_days",
+		"max_stored_episodes",
+		"transcode_format",
+		"transcode_bitrate",
+		"transcode_keep_original",
+		"embed_tags",
+		"refresh_interval_minutes",
+		"notify_on_new",
+		"notify_command",
+		"max_episode_size_mb",
+		"max_feed_size_mb",
+		"feed_timeout_seconds",
+		"import_concurrency",
+		"strip_tracking_prefixes",
+		"max_redirects",
+		"allow_http",
+		"db_busy_timeout_ms",
+		"db_cache_size_kb",
+		"db_synchronous",
+		"db_mmap_size_mb",
+		"db_max_open_conns",
+		"gpodder_server",
+		"gpodder_username",
+		"gpodder_password",
+		"gpodder_device",
+		"api_listen",
+		"api_token",
+		"websub_callback_url",
 	}
 }
 
@@ -207,6 +475,16 @@ func EditInteractive(ctx context.Context, cfg Config) (Config, error) {
 			},
 			Validate: survey.Required,
 		},
+		{
+			Name: "filename_template",
+			Prompt: &survey.Input{
+				Message: "Download file name template ({podcast}, {title}, {date}, {number}, {guid}, {ext})",
+				Default: cfg.FilenameTemplate,
+			},
+			Validate: func(ans interface{}) error {
+				return validateFilenameTemplate(strings.TrimSpace(ans.(string)))
+			},
+		},
 		{
 			Name: "retry_count",
 			Prompt: &survey.Input{
@@ -244,6 +522,14 @@ func EditInteractive(ctx context.Context, cfg Config) (Config, error) {
 				Default: cfg.TLSVerify,
 			},
 		},
+		{
+			Name: "ca_file",
+			Prompt: &survey.Input{
+				Message: "Extra CA certificates, PEM file (optional)",
+				Default: cfg.CAFile,
+			},
+			Validate: validateCAFile,
+		},
 		{
 			Name: "color_theme",
 			Prompt: &survey.Select{
@@ -252,6 +538,59 @@ func EditInteractive(ctx context.Context, cfg Config) (Config, error) {
 				Default: cfg.ColorTheme,
 			},
 		},
+		{
+			Name: "output_format",
+			Prompt: &survey.Select{
+				Message: "Output format of commands run from the shell",
+				Options: []string{OutputText, OutputJSON},
+				Default: cfg.OutputFormat,
+			},
+		},
+		{
+			Name: "directory",
+			Prompt: &survey.Select{
+				Message: "Podcast directory to search",
+				Options: []string{DirectoryITunes, DirectoryPodcastIndex, DirectoryBoth},
+				Default: cfg.Directory,
+			},
+		},
+		{
+			Name: "podcastindex_key",
+			Prompt: &survey.Input{
+				Message: "Podcast Index API key (for podcastindex or both)",
+				Default: cfg.PodcastIndexKey,
+			},
+		},
+		{
+			Name: "podcastindex_secret",
+			Prompt: &survey.Password{
+				Message: "Podcast Index API secret (leave empty to keep)",
+			},
+		},
+		{
+			Name: "player",
+			Prompt: &survey.Input{
+				Message: "External player command ({file} and {start} are filled in)",
+				Default: cfg.Player,
+			},
+			Validate: validatePlayer,
+		},
+		{
+			Name: "artwork_protocol",
+			Prompt: &survey.Select{
+				Message: "Terminal graphics for podcast artwork",
+				Options: []string{ArtworkAuto, ArtworkKitty, ArtworkSixel, ArtworkNone},
+				Default: cfg.ArtworkProtocol,
+			},
+		},
+		{
+			Name: "startup_view",
+			Prompt: &survey.Select{
+				Message: "View the menu opens on start",
+				Options: StartupViews,
+				Default: cfg.StartupView,
+			},
+		},
 		{
 			Name: "max_episodes",
 			Prompt: &survey.Input{
@@ -268,6 +607,245 @@ func EditInteractive(ctx context.Context, cfg Config) (Config, error) {
 			},
 			Validate: validatePositiveInt,
 		},
+		{
+			Name: "mark_seen_on_list",
+			Prompt: &survey.Confirm{
+				Message: "Mark new episodes seen when the episode list shows them",
+				Default: cfg.MarkSeenOnList,
+			},
+		},
+		{
+			Name: "dedupe_hardlinks",
+			Prompt: &survey.Confirm{
+				Message: "Hardlink identical downloads instead of storing copies",
+				Default: cfg.DedupeHardlinks,
+			},
+		},
+		{
+			Name: "prune_empty_dirs",
+			Prompt: &survey.Confirm{
+				Message: "Remove podcast folders left empty by deleting episodes",
+				Default: cfg.PruneEmptyDirs,
+			},
+		},
+		{
+			Name: "watch_downloads",
+			Prompt: &survey.Confirm{
+				Message: "Watch the download folder for files changed outside podsink",
+				Default: cfg.WatchDownloads,
+			},
+		},
+		{
+			Name: "trash_days",
+			Prompt: &survey.Input{
+				Message: "Keep deleted files in the trash for this many days (0 deletes at once)",
+				Default: fmt.Sprintf("%d", cfg.TrashDays),
+			},
+			Validate: validateNonNegativeInt,
+		},
+		{
+			Name: "max_stored_episodes",
+			Prompt: &survey.Input{
+				Message: "Keep at most this many episodes per podcast in the database (0 keeps all)",
+				Default: fmt.Sprintf("%d", cfg.MaxStoredEpisodes),
+			},
+			Validate: validateNonNegativeInt,
+		},
+		{
+			Name: "transcode_format",
+			Prompt: &survey.Select{
+				Message: "Transcode downloads with ffmpeg to",
+				Options: append([]string{transcodeNone}, TranscodeFormats...),
+				Default: transcodeOption(cfg.TranscodeFormat),
+			},
+		},
+		{
+			Name: "transcode_bitrate",
+			Prompt: &survey.Input{
+				Message: "Bitrate of transcoded downloads",
+				Default: cfg.TranscodeBitrate,
+			},
+			Validate: validateTranscodeBitrate,
+		},
+		{
+			Name: "transcode_keep_original",
+			Prompt: &survey.Confirm{
+				Message: "Keep the original of transcoded downloads",
+				Default: cfg.TranscodeKeepOriginal,
+			},
+		},
+		{
+			Name: "embed_tags",
+			Prompt: &survey.Confirm{
+				Message: "Write ID3 tags and cover art into downloaded files",
+				Default: cfg.EmbedTags,
+			},
+		},
+		{
+			Name: "refresh_interval_minutes",
+			Prompt: &survey.Input{
+				Message: "Feed refresh interval in minutes (0 disables)",
+				Default: fmt.Sprintf("%d", cfg.RefreshIntervalMinutes),
+			},
+			Validate: validateNonNegativeInt,
+		},
+		{
+			Name: "notify_on_new",
+			Prompt: &survey.Confirm{
+				Message: "Show desktop notifications for new episodes and finished downloads",
+				Default: cfg.NotifyOnNew,
+			},
+		},
+		{
+			Name: "notify_command",
+			Prompt: &survey.Input{
+				Message: "Shell command for new episodes and finished downloads (PODSINK_* variables like hooks; optional)",
+				Default: cfg.NotifyCommand,
+			},
+		},
+		{
+			Name: "max_episode_size_mb",
+			Prompt: &survey.Input{
+				Message: "Skip episodes larger than this many MB (0 disables)",
+				Default: fmt.Sprintf("%d", cfg.MaxEpisodeSizeMB),
+			},
+			Validate: validateNonNegativeInt,
+		},
+		{
+			Name: "max_feed_size_mb",
+			Prompt: &survey.Input{
+				Message: "Reject feeds larger than this many MB (0 disables)",
+				Default: fmt.Sprintf("%d", cfg.MaxFeedSizeMB),
+			},
+			Validate: validateNonNegativeInt,
+		},
+		{
+			Name: "feed_timeout_seconds",
+			Prompt: &survey.Input{
+				Message: "Give up on a feed during refresh after this many seconds (0 disables)",
+				Default: fmt.Sprintf("%d", cfg.FeedTimeoutSeconds),
+			},
+			Validate: validateNonNegativeInt,
+		},
+		{
+			Name: "import_concurrency",
+			Prompt: &survey.Input{
+				Message: "Feeds fetched at once during an OPML import",
+				Default: fmt.Sprintf("%d", cfg.ImportConcurrency),
+			},
+			Validate: validatePositiveInt,
+		},
+		{
+			Name: "strip_tracking_prefixes",
+			Prompt: &survey.Confirm{
+				Message: "Strip tracking redirects (podtrac, chartable, pdst.fm) from episode URLs",
+				Default: cfg.StripTrackingPrefixes,
+			},
+		},
+		{
+			Name: "max_redirects",
+			Prompt: &survey.Input{
+				Message: "Maximum redirects per download (0 refuses redirects)",
+				Default: fmt.Sprintf("%d", cfg.MaxRedirects),
+			},
+			Validate: validateNonNegativeInt,
+		},
+		{
+			Name: "allow_http",
+			Prompt: &survey.Confirm{
+				Message: "Allow plaintext HTTP downloads (otherwise upgrade to HTTPS)",
+				Default: cfg.AllowHTTP,
+			},
+		},
+		{
+			Name: "db_busy_timeout_ms",
+			Prompt: &survey.Input{
+				Message: "Milliseconds to wait for the database's write lock (0 = 5000; applies on restart)",
+				Default: fmt.Sprintf("%d", cfg.DBBusyTimeoutMS),
+			},
+			Validate: validateNonNegativeInt,
+		},
+		{
+			Name: "db_cache_size_kb",
+			Prompt: &survey.Input{
+				Message: "Database page cache per connection in KiB (0 = SQLite default)",
+				Default: fmt.Sprintf("%d", cfg.DBCacheSizeKB),
+			},
+			Validate: validateNonNegativeInt,
+		},
+		{
+			Name: "db_synchronous",
+			Prompt: &survey.Select{
+				Message: "How often the database syncs to disk",
+				Options: append([]string{synchronousDefault}, SynchronousModes...),
+				Default: synchronousOption(cfg.DBSynchronous),
+			},
+		},
+		{
+			Name: "db_mmap_size_mb",
+			Prompt: &survey.Input{
+				Message: "Memory-map up to this many MiB of the database (0 = off)",
+				Default: fmt.Sprintf("%d", cfg.DBMmapSizeMB),
+			},
+			Validate: validateNonNegativeInt,
+		},
+		{
+			Name: "db_max_open_conns",
+			Prompt: &survey.Input{
+				Message: "Maximum open database connections (0 = unlimited)",
+				Default: fmt.Sprintf("%d", cfg.DBMaxOpenConns),
+			},
+			Validate: validateNonNegativeInt,
+		},
+		{
+			Name: "gpodder_server",
+			Prompt: &survey.Input{
+				Message: "gpodder.net sync server (optional)",
+				Default: cfg.GpodderServer,
+			},
+		},
+		{
+			Name: "gpodder_username",
+			Prompt: &survey.Input{
+				Message: "gpodder username",
+				Default: cfg.GpodderUsername,
+			},
+		},
+		{
+			Name: "gpodder_password",
+			Prompt: &survey.Password{
+				Message: "gpodder password (leave empty to keep)",
+			},
+		},
+		{
+			Name: "gpodder_device",
+			Prompt: &survey.Input{
+				Message: "gpodder device id",
+				Default: cfg.GpodderDevice,
+			},
+		},
+		{
+			Name: "api_listen",
+			Prompt: &survey.Input{
+				Message: "HTTP API listen address, e.g. 127.0.0.1:8737 (optional)",
+				Default: cfg.APIListen,
+			},
+			Validate: validateAPIListen,
+		},
+		{
+			Name: "api_token",
+			Prompt: &survey.Password{
+				Message: "HTTP API token (leave empty to keep)",
+			},
+		},
+		{
+			Name: "websub_callback_url",
+			Prompt: &survey.Input{
+				Message: "Public URL of the HTTP API for WebSub hubs (optional)",
+				Default: cfg.WebSubCallbackURL,
+			},
+			Validate: validateHTTPURL,
+		},
 	}
 
 	answers := map[string]interface{}{}
@@ -284,20 +862,163 @@ func EditInteractive(ctx context.Context, cfg Config) (Config, error) {
 	cfg.DownloadRoot = strings.TrimSpace(answers["download_root"].(string))
 	cfg.ParallelDownloads = toInt(answers["parallel_downloads"])
 	cfg.TmpDir = strings.TrimSpace(answers["tmp_dir"].(string))
+	cfg.FilenameTemplate = strings.TrimSpace(answers["filename_template"].(string))
 	cfg.RetryCount = toInt(answers["retry_count"])
 	cfg.RetryBackoffMaxSec = toInt(answers["retry_backoff_max_seconds"])
 	cfg.UserAgent = strings.TrimSpace(answers["user_agent"].(string))
 	cfg.Proxy = strings.TrimSpace(answers["proxy"].(string))
 	cfg.TLSVerify = answers["tls_verify"].(bool)
-	if themeName, ok := answers["color_theme"].(string); ok {
+	cfg.CAFile = strings.TrimSpace(answers["ca_file"].(string))
+	if themeName, ok := selected(answers["color_theme"]); ok {
 		cfg.ColorTheme = themeName
 	}
+	if format, ok := selected(answers["output_format"]); ok {
+		cfg.OutputFormat = format
+	}
+	if directory, ok := selected(answers["directory"]); ok {
+		cfg.Directory = directory
+	}
+	cfg.PodcastIndexKey = strings.TrimSpace(answers["podcastindex_key"].(string))
+	if secret := strings.TrimSpace(answers["podcastindex_secret"].(string)); secret != "" {
+		cfg.PodcastIndexSecret = secret
+	}
+	cfg.Player = strings.TrimSpace(answers["player"].(string))
+	if protocol, ok := selected(answers["artwork_protocol"]); ok {
+		cfg.ArtworkProtocol = protocol
+	}
+	if view, ok := selected(answers["startup_view"]); ok {
+		cfg.StartupView = view
+	}
 	cfg.MaxEpisodes = toInt(answers["max_episodes"])
 	cfg.MaxEpisodeDescriptionLines = toInt(answers["max_episode_description_lines"])
+	cfg.MarkSeenOnList = answers["mark_seen_on_list"].(bool)
+	cfg.DedupeHardlinks = answers["dedupe_hardlinks"].(bool)
+	cfg.PruneEmptyDirs = answers["prune_empty_dirs"].(bool)
+	cfg.WatchDownloads = answers["watch_downloads"].(bool)
+	cfg.TrashDays = toInt(answers["trash_days"])
+	cfg.MaxStoredEpisodes = toInt(answers["max_stored_episodes"])
+	if format, ok := selected(answers["transcode_format"]); ok {
+		if format == transcodeNone {
+			format = ""
+		}
+		cfg.TranscodeFormat = format
+	}
+	cfg.TranscodeBitrate = strings.TrimSpace(answers["transcode_bitrate"].(string))
+	cfg.TranscodeKeepOriginal = answers["transcode_keep_original"].(bool)
+	cfg.EmbedTags = answers["embed_tags"].(bool)
+	cfg.RefreshIntervalMinutes = toInt(answers["refresh_interval_minutes"])
+	cfg.NotifyOnNew = answers["notify_on_new"].(bool)
+	cfg.NotifyCommand = strings.TrimSpace(answers["notify_command"].(string))
+	cfg.MaxEpisodeSizeMB = toInt(answers["max_episode_size_mb"])
+	cfg.MaxFeedSizeMB = toInt(answers["max_feed_size_mb"])
+	cfg.FeedTimeoutSeconds = toInt(answers["feed_timeout_seconds"])
+	cfg.ImportConcurrency = toInt(answers["import_concurrency"])
+	cfg.StripTrackingPrefixes = answers["strip_tracking_prefixes"].(bool)
+	cfg.MaxRedirects = toInt(answers["max_redirects"])
+	cfg.AllowHTTP = answers["allow_http"].(bool)
+	cfg.DBBusyTimeoutMS = toInt(answers["db_busy_timeout_ms"])
+	cfg.DBCacheSizeKB = toInt(answers["db_cache_size_kb"])
+	if mode, ok := selected(answers["db_synchronous"]); ok {
+		if mode == synchronousDefault {
+			mode = ""
+		}
+		cfg.DBSynchronous = mode
+	}
+	cfg.DBMmapSizeMB = toInt(answers["db_mmap_size_mb"])
+	cfg.DBMaxOpenConns = toInt(answers["db_max_open_conns"])
+	cfg.GpodderServer = strings.TrimSpace(answers["gpodder_server"].(string))
+	cfg.GpodderUsername = strings.TrimSpace(answers["gpodder_username"].(string))
+	if password := answers["gpodder_password"].(string); password != "" {
+		cfg.GpodderPassword = password
+	}
+	cfg.GpodderDevice = strings.TrimSpace(answers["gpodder_device"].(string))
+	cfg.APIListen = strings.TrimSpace(answers["api_listen"].(string))
+	if token := strings.TrimSpace(answers["api_token"].(string)); token != "" {
+		cfg.APIToken = token
+	}
+	cfg.WebSubCallbackURL = strings.TrimSpace(answers["websub_callback_url"].(string))
+	if err := validateDirectory(&cfg); err != nil {
+		return Config{}, err
+	}
+	if err := validateAPI(cfg); err != nil {
+		return Config{}, err
+	}
 
 	return cfg, nil
 }
 
+// selected returns the option picked in a survey.Select, which answers into
+// a map with a core.OptionAnswer rather than a string.
+func selected(ans interface{}) (string, bool) {
+	switch v := ans.(type) {
+	case string:
+		return v, true
+	case core.OptionAnswer:
+		return v.Value, true
+	}
+	return "", false
+}
+
+func validatePlayer(ans interface{}) error {
+	args, err := shellquote.Split(strings.TrimSpace(ans.(string)))
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return errors.New("value required")
+	}
+	return nil
+}
+
+// validateFilenameTemplate checks that a filename template stays below the
+// download root, uses known placeholders only and names each episode's file
+// after something that tells episodes apart.
+func validateFilenameTemplate(template string) error {
+	if template == "" {
+		return errors.New("value required")
+	}
+	if strings.HasPrefix(template, "/") || filepath.IsAbs(template) {
+		return errors.New("must be relative to download_root")
+	}
+	for _, segment := range strings.Split(template, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return fmt.Errorf("invalid path segment %q", segment)
+		}
+	}
+	for _, match := range filenamePlaceholder.FindAllStringSubmatch(template, -1) {
+		if !slices.Contains(FilenameFields, match[1]) {
+			return fmt.Errorf("unknown placeholder %s, use one of {%s}", match[0], strings.Join(FilenameFields, "}, {"))
+		}
+	}
+	if strings.ContainsAny(filenamePlaceholder.ReplaceAllString(template, ""), "{}") {
+		return errors.New("unbalanced braces")
+	}
+	name := template[strings.LastIndex(template, "/")+1:]
+	for _, field := range []string{"title", "date", "number", "guid"} {
+		if strings.Contains(name, "{"+field+"}") {
+			return nil
+		}
+	}
+	return errors.New("file name must contain {title}, {date}, {number} or {guid}")
+}
+
+// validateDirectory defaults an unset directory to iTunes and checks that
+// the Podcast Index credentials are present when it is used.
+func validateDirectory(cfg *Config) error {
+	switch cfg.Directory {
+	case "":
+		cfg.Directory = DirectoryITunes
+	case DirectoryITunes:
+	case DirectoryPodcastIndex, DirectoryBoth:
+		if strings.TrimSpace(cfg.PodcastIndexKey) == "" || strings.TrimSpace(cfg.PodcastIndexSecret) == "" {
+			return fmt.Errorf("directory %q requires podcastindex_key and podcastindex_secret", cfg.Directory)
+		}
+	default:
+		return fmt.Errorf("directory must be %q, %q or %q, got %q", DirectoryITunes, DirectoryPodcastIndex, DirectoryBoth, cfg.Directory)
+	}
+	return nil
+}
+
 func validatePositiveInt(ans interface{}) error {
 	v := strings.TrimSpace(ans.(string))
 	if v == "" {
@@ -351,7 +1072,8 @@ func toInt(value interface{}) int {
 	}
 }
 
-func expandPath(path string) (string, error) {
+// ExpandPath replaces a leading "~" with the user's home directory.
+func ExpandPath(path string) (string, error) {
 	if strings.HasPrefix(path, "~") {
 		home, err := os.UserHomeDir()
 		if err != nil {
@@ -0,0 +1,41 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// SynchronousModes are the values of db_synchronous besides "", which keeps
+// SQLite's default.
+var SynchronousModes = []string{"OFF", "NORMAL", "FULL", "EXTRA"}
+
+// synchronousDefault is the editor's option for an empty db_synchronous.
+const synchronousDefault = "default"
+
+// validateDatabaseTuning checks the db_* settings and normalizes
+// db_synchronous to upper case.
+func validateDatabaseTuning(cfg *Config) error {
+	for key, value := range map[string]int{
+		"db_busy_timeout_ms": cfg.DBBusyTimeoutMS,
+		"db_cache_size_kb":   cfg.DBCacheSizeKB,
+		"db_mmap_size_mb":    cfg.DBMmapSizeMB,
+		"db_max_open_conns":  cfg.DBMaxOpenConns,
+	} {
+		if value < 0 {
+			return fmt.Errorf("%s must not be negative, got %d", key, value)
+		}
+	}
+	cfg.DBSynchronous = strings.ToUpper(strings.TrimSpace(cfg.DBSynchronous))
+	if cfg.DBSynchronous != "" && !slices.Contains(SynchronousModes, cfg.DBSynchronous) {
+		return fmt.Errorf("db_synchronous must be empty or one of %s, got %q", strings.Join(SynchronousModes, ", "), cfg.DBSynchronous)
+	}
+	return nil
+}
+
+func synchronousOption(mode string) string {
+	if mode == "" {
+		return synchronousDefault
+	}
+	return mode
+}
@@ -0,0 +1,50 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Lifecycle events hooks can run on.
+const (
+	HookSubscribed        = "subscribed"
+	HookEpisodeAdded      = "episode_added"
+	HookDownloadStarted   = "download_started"
+	HookDownloadCompleted = "download_completed"
+	HookDownloadFailed    = "download_failed"
+)
+
+// HookEvents lists the events a hook may name.
+var HookEvents = []string{HookSubscribed, HookEpisodeAdded, HookDownloadStarted, HookDownloadCompleted, HookDownloadFailed}
+
+// Hook runs Command through the shell or posts to the webhook URL when one
+// of Events happens, every event when Events is empty.
+type Hook struct {
+	Events  []string `yaml:"events,omitempty"`
+	Command string   `yaml:"command,omitempty"`
+	URL     string   `yaml:"url,omitempty"`
+}
+
+// validateHook checks that a hook names known events and either a command
+// or an http(s) webhook URL, not both.
+func validateHook(hook Hook) error {
+	for _, event := range hook.Events {
+		if !slices.Contains(HookEvents, event) {
+			return fmt.Errorf("unknown event %q, must be one of %s", event, strings.Join(HookEvents, ", "))
+		}
+	}
+	command, target := strings.TrimSpace(hook.Command), strings.TrimSpace(hook.URL)
+	switch {
+	case command == "" && target == "":
+		return errors.New("command or url required")
+	case command != "" && target != "":
+		return errors.New("command and url are exclusive")
+	case target != "":
+		if err := validateHTTPURL(target); err != nil {
+			return fmt.Errorf("url: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// IngestFilterEvent is the PODSINK_EVENT of an ingest filter.
+const IngestFilterEvent = "ingest_filter"
+
+// IngestFilter is a command run like a hook command after a subscription or
+// refresh stores new episodes of a podcast. It reads the podcast and its new
+// episodes as JSON on standard input and may print JSON that changes their
+// states and the podcast's tags; see subscriptions.FilterInput and
+// subscriptions.FilterOutput.
+type IngestFilter struct {
+	Name    string `yaml:"name"`
+	Command string `yaml:"command"`
+}
+
+// validateIngestFilters checks that filters have a command and unique
+// names.
+func validateIngestFilters(filters []IngestFilter) error {
+	seen := map[string]bool{}
+	for i, filter := range filters {
+		name := strings.TrimSpace(filter.Name)
+		switch {
+		case name == "":
+			return fmt.Errorf("ingest_filters[%d]: name required", i)
+		case seen[name]:
+			return fmt.Errorf("ingest_filters[%d]: duplicate name %q", i, name)
+		case strings.TrimSpace(filter.Command) == "":
+			return fmt.Errorf("ingest_filters[%d]: command required", i)
+		}
+		seen[name] = true
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Playlist is a saved episode query. It is listed in the menu and run with
+// playlist <name>; Query uses the syntax of the query command.
+type Playlist struct {
+	Name  string `yaml:"name"`
+	Query string `yaml:"query"`
+}
+
+// validatePlaylists checks that playlists have a query and names that are
+// unique, ignoring case.
+func validatePlaylists(playlists []Playlist) error {
+	seen := map[string]bool{}
+	for i, playlist := range playlists {
+		name := strings.ToLower(strings.TrimSpace(playlist.Name))
+		switch {
+		case name == "":
+			return fmt.Errorf("playlists[%d]: name required", i)
+		case seen[name]:
+			return fmt.Errorf("playlists[%d]: duplicate name %q", i, playlist.Name)
+		case strings.TrimSpace(playlist.Query) == "":
+			return fmt.Errorf("playlists[%d]: query required", i)
+		}
+		seen[name] = true
+	}
+	return nil
+}
+
+// FindPlaylist returns the playlist with the given name, ignoring case.
+func (c Config) FindPlaylist(name string) (Playlist, bool) {
+	name = strings.TrimSpace(name)
+	for _, playlist := range c.Playlists {
+		if strings.EqualFold(strings.TrimSpace(playlist.Name), name) {
+			return playlist, true
+		}
+	}
+	return Playlist{}, false
+}
@@ -0,0 +1,70 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Kinds of post-processing steps.
+const (
+	PostProcessLoudnorm = "loudnorm"
+	PostProcessOpus     = "opus"
+	PostProcessCommand  = "command"
+)
+
+// PostProcessEvent is the PODSINK_EVENT of a post_process command.
+const PostProcessEvent = "post_process"
+
+// PostProcessKinds lists the kinds a post-processing step may have.
+var PostProcessKinds = []string{PostProcessLoudnorm, PostProcessOpus, PostProcessCommand}
+
+// PostProcessStep is run on a finished download. loudnorm normalizes the
+// loudness with ffmpeg, opus transcodes the file to Opus with ffmpeg and
+// command runs Command like a hook command, with the file in PODSINK_FILE.
+// Steps are tracked by Name, so renaming a step runs it again.
+type PostProcessStep struct {
+	Name    string `yaml:"name"`
+	Kind    string `yaml:"kind"`
+	Command string `yaml:"command,omitempty"`
+}
+
+// validatePostProcess checks that steps have unique names, a known kind and
+// a command exactly when they are of kind command.
+func validatePostProcess(steps []PostProcessStep) error {
+	seen := map[string]bool{}
+	for i, step := range steps {
+		name := strings.TrimSpace(step.Name)
+		switch {
+		case name == "":
+			return fmt.Errorf("post_process[%d]: name required", i)
+		case strings.ContainsAny(name, " \t"):
+			return fmt.Errorf("post_process[%d]: name %q must not contain spaces", i, name)
+		case seen[name]:
+			return fmt.Errorf("post_process[%d]: duplicate name %q", i, name)
+		}
+		seen[name] = true
+		if err := validatePostProcessStep(step); err != nil {
+			return fmt.Errorf("post_process[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func validatePostProcessStep(step PostProcessStep) error {
+	if !slices.Contains(PostProcessKinds, step.Kind) {
+		return fmt.Errorf("unknown kind %q, must be one of %s", step.Kind, strings.Join(PostProcessKinds, ", "))
+	}
+	command := strings.TrimSpace(step.Command)
+	if step.Kind != PostProcessCommand {
+		if command != "" {
+			return fmt.Errorf("command is only used by kind %s", PostProcessCommand)
+		}
+		return nil
+	}
+	if command == "" {
+		return errors.New("command required")
+	}
+	return nil
+}
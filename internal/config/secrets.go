@@ -0,0 +1,147 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// redacted replaces secrets in configs and logs shared for support.
+const redacted = "[redacted]"
+
+// SecretKeys are the settings holding credentials. The REPL asks for them
+// with masked input so they need not be pasted into config.yaml.
+var SecretKeys = []string{"podcastindex_key", "podcastindex_secret", "gpodder_password", "api_token"}
+
+// Secret returns the value of a secret setting.
+func (c Config) Secret(key string) (string, error) {
+	switch key {
+	case "podcastindex_key":
+		return c.PodcastIndexKey, nil
+	case "podcastindex_secret":
+		return c.PodcastIndexSecret, nil
+	case "gpodder_password":
+		return c.GpodderPassword, nil
+	case "api_token":
+		return c.APIToken, nil
+	}
+	return "", fmt.Errorf("unknown secret %q", key)
+}
+
+// SetSecret stores value under a secret setting, or clears it when value is
+// empty. The change is refused when Load would reject the result, such as
+// clearing the credentials the configured directory needs.
+func (c *Config) SetSecret(key, value string) error {
+	updated := *c
+	if err := updated.assignSecret(key, strings.TrimSpace(value)); err != nil {
+		return err
+	}
+	if err := validateDirectory(&updated); err != nil {
+		return err
+	}
+	if err := validateAPI(updated); err != nil {
+		return err
+	}
+	*c = updated
+	return nil
+}
+
+func (c *Config) assignSecret(key, value string) error {
+	switch key {
+	case "podcastindex_key":
+		c.PodcastIndexKey = value
+	case "podcastindex_secret":
+		c.PodcastIndexSecret = value
+	case "gpodder_password":
+		c.GpodderPassword = value
+	case "api_token":
+		c.APIToken = value
+	default:
+		return fmt.Errorf("unknown secret %q", key)
+	}
+	return nil
+}
+
+// Redacted returns the config with its secrets replaced, for sharing in
+// bug reports. Passwords and query strings of the proxy, webhook and
+// rewritten URLs are dropped too, as services often put tokens there, and
+// so are user commands, which may carry them as arguments.
+func (c Config) Redacted() Config {
+	for _, key := range SecretKeys {
+		if value, _ := c.Secret(key); value != "" {
+			c.assignSecret(key, redacted)
+		}
+	}
+	c.Proxy = redactURL(c.Proxy)
+	c.NotifyCommand = redactCommand(c.NotifyCommand)
+	c.URLRewrites = append([]URLRewrite(nil), c.URLRewrites...)
+	for i, rule := range c.URLRewrites {
+		c.URLRewrites[i].Replacement = redactURL(rule.Replacement)
+	}
+	c.Hooks = append([]Hook(nil), c.Hooks...)
+	for i, hook := range c.Hooks {
+		c.Hooks[i].URL = redactURL(hook.URL)
+		c.Hooks[i].Command = redactCommand(hook.Command)
+	}
+	c.PostProcess = append([]PostProcessStep(nil), c.PostProcess...)
+	for i, step := range c.PostProcess {
+		c.PostProcess[i].Command = redactCommand(step.Command)
+	}
+	c.IngestFilters = append([]IngestFilter(nil), c.IngestFilters...)
+	for i, filter := range c.IngestFilters {
+		c.IngestFilters[i].Command = redactCommand(filter.Command)
+	}
+	return c
+}
+
+// RedactSecrets replaces every secret of the config found in text,
+// including the proxy's password.
+func (c Config) RedactSecrets(text string) string {
+	secrets := []string{urlPassword(c.Proxy)}
+	for _, key := range SecretKeys {
+		value, _ := c.Secret(key)
+		secrets = append(secrets, value)
+	}
+	for _, secret := range secrets {
+		if secret != "" {
+			text = strings.ReplaceAll(text, secret, redacted)
+		}
+	}
+	return text
+}
+
+// redactURL drops the password and query string of a URL, leaving other
+// values as they are.
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || raw == "" {
+		return raw
+	}
+	_, hasPassword := u.User.Password()
+	if !hasPassword && u.RawQuery == "" {
+		return raw
+	}
+	if hasPassword {
+		u.User = url.User(u.User.Username())
+	}
+	u.RawQuery = ""
+	return u.String()
+}
+
+// urlPassword returns the password of a URL, "" when it has none.
+func urlPassword(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	password, _ := u.User.Password()
+	return password
+}
+
+// redactCommand hides a configured command line.
+func redactCommand(command string) string {
+	if strings.TrimSpace(command) == "" {
+		return command
+	}
+	return redacted
+}
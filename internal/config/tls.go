@@ -0,0 +1,80 @@
+package config
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+// pinPrefix marks a pin as the SHA-256 digest of a certificate's
+// SubjectPublicKeyInfo, the format used by curl's --pinnedpubkey.
+const pinPrefix = "sha256/"
+
+// LoadCAFile returns the system roots extended with the PEM certificates in
+// path, so feeds stay reachable when a proxy re-signs traffic with its own CA.
+func LoadCAFile(path string) (*x509.CertPool, error) {
+	path, err := ExpandPath(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("%s contains no PEM certificates", path)
+	}
+	return pool, nil
+}
+
+// ParsePin decodes a "sha256/<base64>" public key pin. The prefix is optional.
+func ParsePin(pin string) ([]byte, error) {
+	encoded := strings.TrimPrefix(strings.TrimSpace(pin), pinPrefix)
+	digest, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("pin %q is not base64", pin)
+	}
+	if len(digest) != sha256.Size {
+		return nil, fmt.Errorf("pin %q is not a SHA-256 digest", pin)
+	}
+	return digest, nil
+}
+
+func validateCAFile(ans interface{}) error {
+	if path := strings.TrimSpace(ans.(string)); path != "" {
+		_, err := LoadCAFile(path)
+		return err
+	}
+	return nil
+}
+
+func validateTLS(cfg Config) error {
+	if strings.TrimSpace(cfg.CAFile) != "" {
+		if _, err := LoadCAFile(cfg.CAFile); err != nil {
+			return fmt.Errorf("ca_file: %w", err)
+		}
+	}
+	for host, pins := range cfg.TLSPins {
+		if net.ParseIP(strings.Trim(host, "[]")) != nil {
+			// TLS sends no server name for IP addresses, so there is nothing to match.
+			return fmt.Errorf("tls_pins[%s]: pins apply to host names, not IP addresses", host)
+		}
+		if len(pins) == 0 {
+			return fmt.Errorf("tls_pins[%s]: no pins listed", host)
+		}
+		for _, pin := range pins {
+			if _, err := ParsePin(pin); err != nil {
+				return fmt.Errorf("tls_pins[%s]: %w", host, err)
+			}
+		}
+	}
+	return nil
+}
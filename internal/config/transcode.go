@@ -0,0 +1,62 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Formats downloads can be transcoded to.
+const (
+	TranscodeOpus = "opus"
+	TranscodeMP3  = "mp3"
+	TranscodeAAC  = "aac"
+)
+
+// TranscodeFormats lists the values of transcode_format besides "", which
+// keeps downloads as published.
+var TranscodeFormats = []string{TranscodeOpus, TranscodeMP3, TranscodeAAC}
+
+// DefaultTranscodeBitrate is the bitrate used when transcode_bitrate is not
+// set.
+const DefaultTranscodeBitrate = "64k"
+
+// transcodeBitratePattern matches bitrates in ffmpeg's kbit/s notation.
+var transcodeBitratePattern = regexp.MustCompile(`^[1-9][0-9]*k$`)
+
+// transcodeNone is the editor's option for an empty transcode_format.
+const transcodeNone = "none"
+
+func transcodeOption(format string) string {
+	if format == "" {
+		return transcodeNone
+	}
+	return format
+}
+
+// OriginalsDir returns the folder transcode_keep_original keeps the
+// downloaded files in.
+func OriginalsDir(downloadRoot string) string {
+	return filepath.Join(downloadRoot, ".originals")
+}
+
+// validateTranscode checks transcode_format and transcode_bitrate.
+func validateTranscode(cfg Config) error {
+	if cfg.TranscodeFormat != "" && !slices.Contains(TranscodeFormats, cfg.TranscodeFormat) {
+		return fmt.Errorf("transcode_format must be empty or one of %s, got %q", strings.Join(TranscodeFormats, ", "), cfg.TranscodeFormat)
+	}
+	if !transcodeBitratePattern.MatchString(cfg.TranscodeBitrate) {
+		return fmt.Errorf("transcode_bitrate must be kbit/s such as %q, got %q", DefaultTranscodeBitrate, cfg.TranscodeBitrate)
+	}
+	return nil
+}
+
+func validateTranscodeBitrate(ans interface{}) error {
+	value := strings.TrimSpace(fmt.Sprint(ans))
+	if !transcodeBitratePattern.MatchString(value) {
+		return fmt.Errorf("enter kbit/s such as %q", DefaultTranscodeBitrate)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+// ErrNotRunning is returned by Client when nothing listens on the socket.
+var ErrNotRunning = errors.New("daemon is not running")
+
+// Client talks to a running daemon over its unix socket.
+type Client struct {
+	http *http.Client
+}
+
+func NewClient(socketPath string) *Client {
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", socketPath)
+		},
+	}
+	// Refreshing every feed can take a while; the request context bounds it.
+	return &Client{http: &http.Client{Transport: transport, Timeout: 10 * time.Minute}}
+}
+
+// Status fetches the daemon status.
+func (c *Client) Status(ctx context.Context) (Status, error) {
+	var status Status
+	err := c.do(ctx, http.MethodGet, "/status", nil, &status)
+	return status, err
+}
+
+// Enqueue queues an episode for download. The reply is the command result
+// in its JSON form.
+func (c *Client) Enqueue(ctx context.Context, episodeID string) (json.RawMessage, error) {
+	var result json.RawMessage
+	err := c.do(ctx, http.MethodPost, "/enqueue", Request{EpisodeID: episodeID}, &result)
+	return result, err
+}
+
+// Refresh refreshes one podcast, or every subscription when podcastID is empty.
+func (c *Client) Refresh(ctx context.Context, podcastID string) (json.RawMessage, error) {
+	var result json.RawMessage
+	err := c.do(ctx, http.MethodPost, "/refresh", Request{PodcastID: podcastID}, &result)
+	return result, err
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body any, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	// The host is ignored; requests always go to the socket.
+	req, err := http.NewRequestWithContext(ctx, method, "http://podsink"+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		var opErr *net.OpError
+		if errors.As(err, &opErr) && opErr.Op == "dial" {
+			return ErrNotRunning
+		}
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr errorResponse
+		if err := json.NewDecoder(resp.Body).Decode(&apiErr); err != nil || apiErr.Error == "" {
+			return fmt.Errorf("daemon: %s", resp.Status)
+		}
+		return fmt.Errorf("daemon: %s", apiErr.Error)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
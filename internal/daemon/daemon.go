@@ -0,0 +1,211 @@
+// Package daemon runs podsink without the TUI: background refresh and
+// download workers keep going, and a small HTTP API on a unix socket reports
+// status and accepts work.
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/kballard/go-shellquote"
+
+	"podsink/internal/app"
+)
+
+// Controller is the part of the application the daemon API exposes.
+type Controller interface {
+	Execute(ctx context.Context, input string) (app.CommandResult, error)
+	QueueEpisode(ctx context.Context, episodeID string) (app.CommandResult, error)
+	DownloadWorkers() int
+	CountQueued(ctx context.Context) (int, error)
+	CountDownloaded(ctx context.Context) (int, error)
+}
+
+// Status is the response of GET /status.
+type Status struct {
+	PID        int       `json:"pid"`
+	StartedAt  time.Time `json:"started_at"`
+	Workers    int       `json:"workers"`
+	Queued     int       `json:"queued"`
+	Downloaded int       `json:"downloaded"`
+}
+
+// Request is the body accepted by POST /enqueue and POST /refresh.
+type Request struct {
+	EpisodeID string `json:"episode_id,omitempty"`
+	PodcastID string `json:"podcast_id,omitempty"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// Handler serves the daemon API:
+//
+//	GET  /status   daemon and queue counters
+//	POST /enqueue  {"episode_id": "..."} queues an episode for download
+//	POST /refresh  {"podcast_id": "..."} refreshes one feed, or all without an ID
+//
+// enqueue and refresh answer with the command result as the CLI prints it
+// with --json.
+func Handler(ctrl Controller, startedAt time.Time) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeJSON(w, http.StatusMethodNotAllowed, errorResponse{Error: "use GET"})
+			return
+		}
+		status := Status{PID: os.Getpid(), StartedAt: startedAt, Workers: ctrl.DownloadWorkers()}
+		var err error
+		if status.Queued, err = ctrl.CountQueued(r.Context()); err == nil {
+			status.Downloaded, err = ctrl.CountDownloaded(r.Context())
+		}
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, errorResponse{Error: err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, status)
+	})
+	mux.HandleFunc("/enqueue", func(w http.ResponseWriter, r *http.Request) {
+		req, ok := readRequest(w, r)
+		if !ok {
+			return
+		}
+		if strings.TrimSpace(req.EpisodeID) == "" {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: "episode_id is required"})
+			return
+		}
+		// Not run as "queue <id>", where "process" or "workers" would be
+		// read as subcommands
+		result, err := ctrl.QueueEpisode(r.Context(), req.EpisodeID)
+		reply(w, result, err)
+	})
+	mux.HandleFunc("/refresh", func(w http.ResponseWriter, r *http.Request) {
+		req, ok := readRequest(w, r)
+		if !ok {
+			return
+		}
+		if id := strings.TrimSpace(req.PodcastID); id != "" {
+			execute(w, r, ctrl, "refresh", id)
+			return
+		}
+		execute(w, r, ctrl, "refresh")
+	})
+	return mux
+}
+
+func readRequest(w http.ResponseWriter, r *http.Request) (Request, bool) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, errorResponse{Error: "use POST"})
+		return Request{}, false
+	}
+	var req Request
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: fmt.Sprintf("invalid request body: %v", err)})
+			return Request{}, false
+		}
+	}
+	return req, true
+}
+
+func execute(w http.ResponseWriter, r *http.Request, ctrl Controller, args ...string) {
+	result, err := ctrl.Execute(r.Context(), shellquote.Join(args...))
+	reply(w, result, err)
+}
+
+func reply(w http.ResponseWriter, result app.CommandResult, err error) {
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("daemon: write response: %v", err)
+	}
+}
+
+// Run writes pidPath, serves the API on socketPath until ctx is cancelled and
+// then removes both files. The socket is only accessible to the current user,
+// which is the API's only access control.
+func Run(ctx context.Context, ctrl Controller, socketPath, pidPath string) error {
+	if err := writePIDFile(pidPath); err != nil {
+		return err
+	}
+	defer os.Remove(pidPath)
+
+	// The pidfile shows no other daemon is running, so a socket file left
+	// over from a crash can go.
+	if err := os.Remove(socketPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	listener, err := listenPrivate(socketPath)
+	if err != nil {
+		return fmt.Errorf("listen on %s: %w", socketPath, err)
+	}
+	defer os.Remove(socketPath)
+
+	server := &http.Server{
+		Handler:           Handler(ctrl, time.Now().UTC()),
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.Serve(listener)
+	}()
+	log.Printf("daemon started (pid %d), listening on %s", os.Getpid(), socketPath)
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("daemon: shutdown: %v", err)
+	}
+	log.Printf("daemon stopped")
+	return nil
+}
+
+// listenPrivate listens on a unix socket at path that only the current user
+// can connect to. The socket is bound in a new 0700 directory and made 0600
+// there before it is moved into place, so others never get to connect.
+func listenPrivate(path string) (net.Listener, error) {
+	dir, err := os.MkdirTemp(filepath.Dir(path), ".podsink-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	bound := filepath.Join(dir, "sock")
+	listener, err := net.Listen("unix", bound)
+	if err != nil {
+		return nil, err
+	}
+	// The bound path no longer exists once moved; Run removes path itself.
+	listener.(*net.UnixListener).SetUnlinkOnClose(false)
+	if err = os.Chmod(bound, 0o600); err == nil {
+		err = os.Rename(bound, path)
+	}
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
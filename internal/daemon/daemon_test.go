@@ -0,0 +1,151 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"podsink/internal/app"
+)
+
+type fakeController struct {
+	mu       sync.Mutex
+	commands []string
+}
+
+func (f *fakeController) Execute(_ context.Context, input string) (app.CommandResult, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.commands = append(f.commands, input)
+	return app.CommandResult{Message: "ran " + input}, nil
+}
+
+func (f *fakeController) QueueEpisode(_ context.Context, episodeID string) (app.CommandResult, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.commands = append(f.commands, "enqueue "+episodeID)
+	if episodeID == "broken" {
+		return app.CommandResult{}, errors.New("database is locked")
+	}
+	return app.CommandResult{Message: "queued " + episodeID}, nil
+}
+
+func (f *fakeController) DownloadWorkers() int { return 3 }
+
+func (f *fakeController) CountQueued(context.Context) (int, error) { return 2, nil }
+
+func (f *fakeController) CountDownloaded(context.Context) (int, error) { return 7, nil }
+
+func TestRunServesAPIOnSocket(t *testing.T) {
+	// Unix socket paths are limited to ~100 bytes, which t.TempDir can exceed.
+	dir, err := os.MkdirTemp("", "podsink")
+	if err != nil {
+		t.Fatalf("MkdirTemp: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	socketPath := filepath.Join(dir, "d.sock")
+	pidPath := filepath.Join(dir, "d.pid")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ctrl := &fakeController{}
+	done := make(chan error, 1)
+	go func() {
+		done <- Run(ctx, ctrl, socketPath, pidPath)
+	}()
+
+	client := NewClient(socketPath)
+	var status Status
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		status, err = client.Status(ctx)
+		if err == nil || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+	if status.PID != os.Getpid() || status.Workers != 3 || status.Queued != 2 || status.Downloaded != 7 {
+		t.Fatalf("unexpected status %+v", status)
+	}
+	if pid, err := ReadPID(pidPath); err != nil || pid != os.Getpid() {
+		t.Fatalf("pidfile: pid %d, err %v", pid, err)
+	}
+	if info, err := os.Stat(socketPath); err != nil || info.Mode().Perm() != 0o600 {
+		t.Fatalf("socket should be private: %v %v", info, err)
+	}
+
+	if err := Run(ctx, ctrl, filepath.Join(dir, "other.sock"), pidPath); !errors.Is(err, ErrAlreadyRunning) {
+		t.Fatalf("expected ErrAlreadyRunning for a second daemon, got %v", err)
+	}
+
+	reply, err := client.Enqueue(ctx, "ep 1")
+	if err != nil {
+		t.Fatalf("Enqueue() error = %v", err)
+	}
+	if !strings.Contains(string(reply), `"message":"queued ep 1"`) {
+		t.Fatalf("unexpected enqueue reply %s", reply)
+	}
+	if _, err := client.Enqueue(ctx, ""); err == nil || !strings.Contains(err.Error(), "episode_id is required") {
+		t.Fatalf("expected missing episode_id error, got %v", err)
+	}
+	// Queued by ID, not run as "queue process"
+	if reply, err := client.Enqueue(ctx, "process"); err != nil || !strings.Contains(string(reply), "queued process") {
+		t.Fatalf("unexpected enqueue reply %s, %v", reply, err)
+	}
+	if _, err := client.Enqueue(ctx, "broken"); err == nil || !strings.Contains(err.Error(), "database is locked") {
+		t.Fatalf("expected command error, got %v", err)
+	}
+	if _, err := client.Refresh(ctx, ""); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	if _, err := client.Refresh(ctx, "pod-1"); err != nil {
+		t.Fatalf("Refresh(pod-1) error = %v", err)
+	}
+	want := []string{"enqueue ep 1", "enqueue process", "enqueue broken", "refresh", "refresh pod-1"}
+	if fmt.Sprint(ctrl.commands) != fmt.Sprint(want) {
+		t.Fatalf("unexpected commands %q, want %q", ctrl.commands, want)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("daemon did not stop")
+	}
+	for _, path := range []string{socketPath, pidPath} {
+		if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+			t.Fatalf("expected %s to be removed, got %v", path, err)
+		}
+	}
+	if _, err := client.Status(context.Background()); !errors.Is(err, ErrNotRunning) {
+		t.Fatalf("expected ErrNotRunning after stop, got %v", err)
+	}
+}
+
+func TestWritePIDFileReplacesStaleFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "podsink.pid")
+	// PIDs are capped well below this on every supported system.
+	if err := os.WriteFile(path, []byte("999999999\n"), 0o600); err != nil {
+		t.Fatalf("write pidfile: %v", err)
+	}
+	if err := writePIDFile(path); err != nil {
+		t.Fatalf("writePIDFile() error = %v", err)
+	}
+	if pid, err := ReadPID(path); err != nil || pid != os.Getpid() {
+		t.Fatalf("pidfile: pid %d, err %v", pid, err)
+	}
+	if err := writePIDFile(path); !errors.Is(err, ErrAlreadyRunning) {
+		t.Fatalf("expected ErrAlreadyRunning, got %v", err)
+	}
+}
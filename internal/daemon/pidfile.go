@@ -0,0 +1,54 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ErrAlreadyRunning is returned when the pidfile names a live process.
+var ErrAlreadyRunning = errors.New("daemon already running")
+
+// writePIDFile records the current process in path. A pidfile left behind by
+// a process that no longer exists is replaced.
+func writePIDFile(path string) error {
+	for attempt := 0; attempt < 2; attempt++ {
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if err == nil {
+			_, err = fmt.Fprintf(file, "%d\n", os.Getpid())
+			if closeErr := file.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				os.Remove(path)
+			}
+			return err
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return err
+		}
+		pid, err := ReadPID(path)
+		if err == nil && processAlive(pid) {
+			return fmt.Errorf("%w (pid %d)", ErrAlreadyRunning, pid)
+		}
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return fmt.Errorf("%w: pidfile %s keeps reappearing", ErrAlreadyRunning, path)
+}
+
+// ReadPID returns the process ID recorded in a pidfile.
+func ReadPID(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 {
+		return 0, fmt.Errorf("invalid pidfile %s", path)
+	}
+	return pid, nil
+}
@@ -0,0 +1,18 @@
+//go:build !unix
+
+package daemon
+
+import "os"
+
+// processAlive cannot send signal 0 here, so os.FindProcess succeeding
+// stands in. On Windows that opens the process and fails for one that has
+// exited; a pid already reused by another process still counts as alive and
+// the stale pidfile has to be removed by hand.
+func processAlive(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	process.Release()
+	return true
+}
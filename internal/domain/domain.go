@@ -1,6 +1,9 @@
 package domain
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
 const (
 	EpisodeStateNew        = "NEW"
@@ -9,14 +12,62 @@ const (
 	EpisodeStateQueued     = "QUEUED"
 	EpisodeStateDownloaded = "DOWNLOADED"
 	EpisodeStateDeleted    = "DELETED"
+	// EpisodeStateCorrupt marks a download whose file no longer matches its recorded hash.
+	EpisodeStateCorrupt = "CORRUPT"
+	// EpisodeStateSkipped marks an episode whose download exceeded max_episode_size_mb.
+	EpisodeStateSkipped = "SKIPPED"
+	// EpisodeStatePlayed marks an episode the user has listened to.
+	EpisodeStatePlayed = "PLAYED"
 )
 
+// EpisodeStates lists every episode state.
+var EpisodeStates = []string{
+	EpisodeStateNew, EpisodeStateSeen, EpisodeStateIgnored, EpisodeStateQueued, EpisodeStateDownloaded,
+	EpisodeStateDeleted, EpisodeStateCorrupt, EpisodeStateSkipped, EpisodeStatePlayed,
+}
+
 type SubscriptionSummary struct {
 	ID            string
 	Title         string
 	NewCount      int
-	UnplayedCount int
+	UnplayedCount int // Episodes neither played nor ignored
+	PlayedCount   int
 	TotalCount    int
+	LastRefreshed time.Time // Zero when the feed has not been fetched since tracking began
+	NextRefresh   time.Time // Zero when the feed is due
+	Language      string    // Language tag of the feed, lower case; empty when unknown
+	ArtworkURL    string    // Cover art of the feed or directory; empty when unknown
+	DownloadDir   string    // Overrides download_root for this podcast; empty for the default
+	Tags          []string  // OPML groups the podcast was imported from, sorted
+	DiskBytes     int64     // Size of the podcast's downloaded files on disk
+	Funding       []Funding
+	Value         []ValueBlock
+}
+
+// Funding is a link where listeners can support a podcast, from the feed's
+// podcast:funding tags.
+type Funding struct {
+	URL   string
+	Label string
+}
+
+// ValueBlock describes how listeners can stream payments to a podcast, from
+// the feed's podcast:value tags.
+type ValueBlock struct {
+	Type       string // Payment layer, e.g. "lightning"
+	Method     string // e.g. "keysend"
+	Suggested  string // Suggested amount per minute; empty when unknown
+	Recipients []ValueRecipient
+}
+
+// ValueRecipient receives a share of value payments. Split is a share of the
+// payment, or a percentage taken off the top when Fee is set.
+type ValueRecipient struct {
+	Name    string
+	Type    string
+	Address string
+	Split   int
+	Fee     bool
 }
 
 type EpisodeRow struct {
@@ -26,12 +77,33 @@ type EpisodeRow struct {
 	PublishedAt time.Time
 	HasPublish  bool
 	SizeBytes   int64
+	DurationSec int // From itunes:duration, 0 when unknown
 }
 
 type EpisodeResult struct {
 	Episode      EpisodeRow
 	PodcastTitle string
 	PodcastID    string
+	DiskBytes    int64 // Size of the downloaded file on disk, set by the downloads listing; 0 when missing
+}
+
+// sizeMismatchRatio is how far the size of a downloaded file may stray from
+// the size the feed reported before SizeMismatch flags it.
+const sizeMismatchRatio = 0.1
+
+// SizeMismatch reports whether the downloaded file is more than 10 percent
+// smaller or larger than the feed said, which usually means the download was
+// truncated. Episodes without a file or a reported size are not flagged.
+func (r EpisodeResult) SizeMismatch() bool {
+	reported := r.Episode.SizeBytes
+	if reported <= 0 || r.DiskBytes <= 0 {
+		return false
+	}
+	diff := r.DiskBytes - reported
+	if diff < 0 {
+		diff = -diff
+	}
+	return float64(diff) > float64(reported)*sizeMismatchRatio
 }
 
 type EpisodeInfo struct {
@@ -43,10 +115,31 @@ type EpisodeInfo struct {
 	HasPublish   bool
 	FilePath     string
 	EnclosureURL string
+	MimeType     string
 	Hash         string
 	PodcastID    string
 	PodcastTitle string
-	SizeBytes    int64
+	// PodcastArtworkURL is the podcast cover, embedded into downloads with
+	// embed_tags.
+	PodcastArtworkURL string
+	DownloadDir       string // The podcast's download_root override, if any
+	SizeBytes         int64
+	RetryCount        int
+	LastError         string // Why the last download attempt failed
+	PositionSec       int
+	DurationSec       int
+	Season            int
+	Number            int
+	ImageURL          string
+	ChaptersURL       string
+	Soundbites        []Soundbite
+}
+
+// Soundbite is a highlight of an episode its feed points out.
+type Soundbite struct {
+	StartSec    float64
+	DurationSec float64
+	Title       string
 }
 
 type EpisodeDetail struct {
@@ -61,6 +154,15 @@ type EpisodeDetail struct {
 	PodcastID    string
 	PodcastTitle string
 	SizeBytes    int64
+	RetryCount   int
+	LastError    string // Why the last download attempt failed, until one succeeds
+	PositionSec  int
+	DurationSec  int
+	Season       int    // itunes:season, 0 when unknown
+	Number       int    // itunes:episode, 0 when unknown
+	ImageURL     string // Episode artwork from itunes:image
+	ChaptersURL  string // JSON chapters file from podcast:chapters
+	Soundbites   []Soundbite
 }
 
 type QueuedEpisodeResult struct {
@@ -68,14 +170,83 @@ type QueuedEpisodeResult struct {
 	PodcastTitle string
 	PodcastID    string
 	RetryCount   int
+	LastError    string // Why the last download attempt failed
 	EnqueuedAt   time.Time
+	ClaimedAt    time.Time
+	Active       bool // Claimed by a download worker
+	Paused       bool // Held back from workers until resumed
+	Priority     int  // One of the Priority constants
+}
+
+// Download priorities. Workers claim higher priorities first and, within a
+// priority, the download that has waited longest.
+const (
+	PriorityLow    = -1
+	PriorityNormal = 0
+	PriorityHigh   = 1
+)
+
+// ParsePriority returns the priority named "low", "normal" or "high".
+func ParsePriority(name string) (int, bool) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "low":
+		return PriorityLow, true
+	case "normal":
+		return PriorityNormal, true
+	case "high":
+		return PriorityHigh, true
+	}
+	return 0, false
+}
+
+// PriorityName names a priority; values beyond the levels count as the
+// nearest one.
+func PriorityName(priority int) string {
+	switch {
+	case priority < PriorityNormal:
+		return "low"
+	case priority > PriorityNormal:
+		return "high"
+	default:
+		return "normal"
+	}
+}
+
+// Status describes the queue entry as "downloading", "paused" or "waiting".
+func (r QueuedEpisodeResult) Status() string {
+	switch {
+	case r.Active:
+		return "downloading"
+	case r.Paused:
+		return "paused"
+	default:
+		return "waiting"
+	}
 }
 
 type Podcast struct {
-	ID        string
+	ID          string
+	Title       string
+	FeedURL     string
+	CreatedAt   time.Time
+	NextRefresh time.Time // When the scheduler fetches the feed next; zero means as soon as possible
+	WebSubHub   string    // WebSub hub the feed advertises, if any
+	WebSubTopic string    // URL the hub knows the feed by, its atom:link rel="self"
+	GUID        string    // podcast:guid of the feed, lower case; empty when unknown
+	Language    string    // Language tag of the feed, e.g. "de-de"; empty when unknown
+	ArtworkURL  string    // Cover art from the feed, else the directory
+	Tags        []string  // Added to the podcast's tags; existing tags are kept
+	DownloadDir string    // Overrides download_root; empty keeps the podcast's current one
+	Funding     []Funding
+	Value       []ValueBlock
+}
+
+// WebSubTopic is a subscribed feed that can be followed through a WebSub hub.
+type WebSubTopic struct {
+	PodcastID string
 	Title     string
-	FeedURL   string
-	CreatedAt time.Time
+	Hub       string
+	Topic     string
 }
 
 type EpisodeInput struct {
@@ -84,20 +255,243 @@ type EpisodeInput struct {
 	Description string
 	PublishedAt *time.Time
 	Enclosure   string
+	MimeType    string // Enclosure type attribute, e.g. audio/mpeg
 	SizeBytes   int64
+	DurationSec int
+	Season      int
+	Number      int
+	ImageURL    string
+	ChaptersURL string
+	Soundbites  []Soundbite
+	LinkID      string // Permalink ID the episode may be stored under; see feeds.Episode.LinkID
 }
 
 type SubscriptionData struct {
 	Podcast  Podcast
 	Episodes []EpisodeInput
+	// KeepEpisodes caps the episodes stored for the podcast; older ones
+	// that were never downloaded or started are pruned. 0 keeps all.
+	KeepEpisodes int
 }
 
 type PodcastExport struct {
-	Title   string
-	FeedURL string
+	Title       string
+	FeedURL     string
+	GUID        string
+	Tags        []string
+	DownloadDir string
+}
+
+// PlaylistEntry is a downloaded episode's file in an exported playlist.
+type PlaylistEntry struct {
+	EpisodeID    string
+	Title        string
+	PodcastTitle string
+	FilePath     string
+	DurationSec  int // 0 when unknown
 }
 
 type DanglingFile struct {
 	Path      string
 	SizeBytes int64
 }
+
+// TrashEntry is a deleted download kept in the trash until it is purged.
+// Titles are empty once the episode is gone, e.g. after unsubscribing.
+type TrashEntry struct {
+	EpisodeID    string
+	Title        string
+	PodcastTitle string
+	TrashPath    string
+	OriginalPath string
+	State        string // The episode's state before the file was deleted
+	TrashedAt    time.Time
+}
+
+// Outcomes of a post-processing step.
+const (
+	PostProcessDone   = "done"
+	PostProcessFailed = "failed"
+)
+
+// PostProcessRecord is the outcome of a post_process step for an episode.
+type PostProcessRecord struct {
+	EpisodeID    string
+	Title        string
+	PodcastTitle string
+	Step         string
+	Status       string
+	Error        string
+	UpdatedAt    time.Time
+}
+
+// DatabaseStats is a snapshot of the database for support bundles.
+type DatabaseStats struct {
+	SchemaVersion int            `json:"schema_version"` // PRAGMA user_version; 0 before versions were recorded
+	SizeBytes     int64          `json:"size_bytes"`
+	Tables        map[string]int `json:"tables"` // Row count per table
+	EpisodeStates map[string]int `json:"episode_states"`
+}
+
+// Actions recorded in the subscription history.
+const (
+	SubscriptionAdded   = "subscribed"
+	SubscriptionRemoved = "unsubscribed"
+)
+
+// Sources of subscription changes, kept with each history entry.
+const (
+	SourceDirectory = "directory" // Subscribed from an iTunes search or ID
+	SourceFeedURL   = "feed url"
+	SourceOPML      = "opml import"
+	SourceSync      = "gpodder sync"
+	SourceUser      = "user"
+)
+
+// SubscriptionEvent is one entry of the subscription history. It keeps the
+// title and feed URL, which are gone from podcasts after an unsubscribe.
+type SubscriptionEvent struct {
+	PodcastID string
+	Title     string
+	FeedURL   string
+	Action    string
+	Source    string
+	At        time.Time
+}
+
+// RefreshFailure names a feed a refresh could not fetch or store, and why.
+type RefreshFailure struct {
+	PodcastID string
+	Title     string
+	Err       string
+}
+
+// ImportFailure names an OPML outline an import could not subscribe to,
+// and why.
+type ImportFailure struct {
+	Title   string
+	FeedURL string
+	Err     string
+}
+
+// ImportConflict is an OPML outline for a podcast that is already
+// subscribed under another title or feed URL. Tags are the outline's groups.
+type ImportConflict struct {
+	PodcastID       string
+	Title           string
+	FeedURL         string
+	ImportedTitle   string
+	ImportedFeedURL string
+	Tags            []string
+}
+
+// AddedEpisode names an episode stored for the first time.
+type AddedEpisode struct {
+	ID    string
+	Title string
+}
+
+type DuplicateGroup struct {
+	Hash       string
+	EpisodeIDs []string
+	FilePaths  []string
+}
+
+type AdoptionCandidate struct {
+	ID           string
+	Title        string
+	PodcastTitle string
+	EnclosureURL string
+	SizeBytes    int64
+	Hash         string
+}
+
+// EpisodeActivity is what happened to an episode locally, for exporting
+// it as gpodder episode actions. Times are zero when unknown.
+type EpisodeActivity struct {
+	FeedURL      string
+	EnclosureURL string
+	DownloadedAt time.Time
+	Played       bool
+	PlayedAt     time.Time // Unknown for episodes played before it was recorded
+	PositionSec  int
+	DurationSec  int
+}
+
+// EpisodeStateRecord is an episode's listening progress as read from
+// another podsink database.
+type EpisodeStateRecord struct {
+	FeedURL      string
+	PodcastGUID  string
+	EpisodeID    string
+	EnclosureURL string
+	State        string
+	PositionSec  int
+	PlayedAt     string // As stored, empty when unknown
+}
+
+// StateMergeResult counts what merging episode states from another
+// database changed.
+type StateMergeResult struct {
+	Matched   int // Episodes found in this library
+	Unmatched int // Episodes of podcasts or with IDs not found here
+	Played    int
+	Ignored   int
+	Seen      int
+	Positions int // Play positions moved forward
+}
+
+// EventKind identifies what an Event reports.
+type EventKind string
+
+const (
+	EventEpisodeState     EventKind = "episode_state"
+	EventDownloadProgress EventKind = "download_progress"
+	EventDownloadFinished EventKind = "download_finished"
+	EventRefreshFinished  EventKind = "refresh_finished"
+	EventRefreshProgress  EventKind = "refresh_progress"
+	EventImportProgress   EventKind = "import_progress"
+	EventSubscribed       EventKind = "subscribed"
+	EventEpisodeAdded     EventKind = "episode_added"
+	EventDownloadStarted  EventKind = "download_started"
+	// EventFilesChanged reports files added to or removed from the download
+	// root by something other than podsink.
+	EventFilesChanged EventKind = "files_changed"
+)
+
+// Event is published by services when state changes in the background.
+type Event struct {
+	Kind      EventKind
+	EpisodeID string
+	PodcastID string
+	Title     string
+	// PodcastTitle names the podcast of an episode event when Title is the
+	// episode's.
+	PodcastTitle string
+	State        string
+	FilePath     string // Where a finished download was stored
+	Bytes        int64  // Bytes transferred so far, or the final file size
+	Total        int64  // Expected size in bytes, 0 when unknown
+	Rate         int64  // Average transfer rate in bytes per second during a download
+	Err          error
+	// During a refresh: feeds done out of FeedsTotal, and the new episodes
+	// and failed feeds so far. A finished refresh reports all new episodes.
+	// An OPML import reports outlines done, and in Added the subscriptions
+	// imported so far.
+	FeedsDone  int
+	FeedsTotal int
+	Added      int
+	Failed     int
+}
+
+// EventPublisher receives events from services.
+type EventPublisher interface {
+	Publish(Event)
+}
+
+type discardEvents struct{}
+
+func (discardEvents) Publish(Event) {}
+
+// DiscardEvents is an EventPublisher that drops every event.
+var DiscardEvents EventPublisher = discardEvents{}
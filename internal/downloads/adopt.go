@@ -0,0 +1,237 @@
+package downloads
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"podsink/internal/domain"
+)
+
+// AdoptResult summarises an adopt run over an existing audio folder.
+type AdoptResult struct {
+	Scanned   int
+	Adopted   int
+	Unmatched []string
+}
+
+// mediaExtensions are the file extensions of podcast enclosures. Adoption
+// skips other files, which could otherwise match an episode by size alone.
+var mediaExtensions = map[string]bool{
+	".mp3": true, ".m4a": true, ".m4b": true, ".mp4": true, ".m4v": true, ".aac": true,
+	".ogg": true, ".oga": true, ".opus": true, ".flac": true, ".wav": true, ".wma": true,
+}
+
+// isMediaFile reports whether path has the extension of a podcast enclosure.
+func isMediaFile(path string) bool {
+	return mediaExtensions[strings.ToLower(filepath.Ext(path))]
+}
+
+type adoptFile struct {
+	path string
+	size int64
+}
+
+// Adopt scans dir for previously downloaded audio files, by extension, and
+// records each one that can be matched to a known episode as DOWNLOADED in
+// place. Files are matched by content hash first, then by file name (the name
+// podsink would have used or the enclosure's name), and finally by a unique
+// enclosure size.
+func (s *Service) Adopt(ctx context.Context, dir string) (AdoptResult, error) {
+	dir = strings.TrimSpace(dir)
+	if dir == "" {
+		return AdoptResult{}, fmt.Errorf("directory cannot be empty")
+	}
+	stat, err := os.Stat(dir)
+	if err != nil {
+		return AdoptResult{}, err
+	}
+	if !stat.IsDir() {
+		return AdoptResult{}, fmt.Errorf("%s is not a directory", dir)
+	}
+
+	candidates, err := s.store.ListAdoptionCandidates(ctx)
+	if err != nil {
+		return AdoptResult{}, err
+	}
+
+	var files []adoptFile
+	err = filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil // Skip files we can't access
+		}
+		if info.IsDir() {
+			if p != dir && strings.HasPrefix(info.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.Mode().IsRegular() || strings.HasPrefix(info.Name(), ".") || !isMediaFile(info.Name()) {
+			return nil
+		}
+		files = append(files, adoptFile{path: p, size: info.Size()})
+		return nil
+	})
+	if err != nil {
+		return AdoptResult{}, err
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].path < files[j].path })
+
+	index := newAdoptIndex(candidates)
+	result := AdoptResult{Scanned: len(files)}
+	for i := range files {
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+		file := files[i]
+		hash, err := computeFileHash(file.path)
+		if err != nil {
+			result.Unmatched = append(result.Unmatched, file.path)
+			continue
+		}
+
+		match := index.match(file, hash)
+		if match < 0 {
+			result.Unmatched = append(result.Unmatched, file.path)
+			continue
+		}
+
+		absPath, err := filepath.Abs(file.path)
+		if err != nil {
+			absPath = file.path
+		}
+		if err := s.store.PersistDownloadResult(ctx, candidates[match].ID, absPath, hash); err != nil {
+			return result, err
+		}
+		index.adopted[match] = true
+		result.Adopted++
+	}
+
+	return result, nil
+}
+
+// adoptIndex looks up adoption candidates by hash, likely file name and size.
+type adoptIndex struct {
+	candidates []domain.AdoptionCandidate
+	byHash     map[string][]int
+	byName     map[string][]int
+	bySize     map[int64][]int
+	adopted    map[int]bool
+}
+
+func newAdoptIndex(candidates []domain.AdoptionCandidate) *adoptIndex {
+	index := &adoptIndex{
+		candidates: candidates,
+		byHash:     make(map[string][]int),
+		byName:     make(map[string][]int),
+		bySize:     make(map[int64][]int),
+		adopted:    make(map[int]bool),
+	}
+	for i, candidate := range candidates {
+		if candidate.Hash != "" {
+			index.byHash[candidate.Hash] = append(index.byHash[candidate.Hash], i)
+		}
+		for _, name := range candidateFileNames(candidate) {
+			index.byName[name] = append(index.byName[name], i)
+		}
+		if candidate.SizeBytes > 0 {
+			index.bySize[candidate.SizeBytes] = append(index.bySize[candidate.SizeBytes], i)
+		}
+	}
+	return index
+}
+
+// match returns the candidate a file belongs to, or -1 when there is none or
+// the choice is ambiguous.
+func (ai *adoptIndex) match(file adoptFile, hash string) int {
+	match := ai.pick(ai.byHash[hash])
+	if match < 0 {
+		match = matchByName(ai.candidates, ai.byName[strings.ToLower(filepath.Base(file.path))], ai.adopted, file)
+	}
+	if match < 0 {
+		match = ai.pick(ai.bySize[file.size])
+	}
+	return match
+}
+
+func (ai *adoptIndex) pick(indexes []int) int {
+	match := -1
+	for _, idx := range indexes {
+		if ai.adopted[idx] {
+			continue
+		}
+		if match >= 0 {
+			return -1 // Ambiguous
+		}
+		match = idx
+	}
+	return match
+}
+
+// matchByName resolves a file name shared by several episodes using the
+// podcast folder name, then the enclosure size.
+func matchByName(candidates []domain.AdoptionCandidate, indexes []int, adopted map[int]bool, file adoptFile) int {
+	var open []int
+	for _, idx := range indexes {
+		if !adopted[idx] {
+			open = append(open, idx)
+		}
+	}
+	switch len(open) {
+	case 0:
+		return -1
+	case 1:
+		return open[0]
+	}
+
+	folder := strings.ToLower(filepath.Base(filepath.Dir(file.path)))
+	var inFolder []int
+	for _, idx := range open {
+		if strings.ToLower(safeFilename(candidates[idx].PodcastTitle)) == folder {
+			inFolder = append(inFolder, idx)
+		}
+	}
+	if len(inFolder) == 1 {
+		return inFolder[0]
+	}
+	if len(inFolder) > 1 {
+		open = inFolder
+	}
+
+	match := -1
+	for _, idx := range open {
+		if candidates[idx].SizeBytes != file.size {
+			continue
+		}
+		if match >= 0 {
+			return -1
+		}
+		match = idx
+	}
+	return match
+}
+
+// candidateFileNames lists the lower-cased file names an episode is likely to
+// have been saved under.
+func candidateFileNames(candidate domain.AdoptionCandidate) []string {
+	var names []string
+	ext := fileExtension(candidate.EnclosureURL)
+	if title := safeFilename(candidate.Title); title != "" {
+		names = append(names, strings.ToLower(title+ext))
+	}
+	if u, err := url.Parse(candidate.EnclosureURL); err == nil {
+		base := path.Base(u.Path)
+		if unescaped, err := url.PathUnescape(base); err == nil {
+			base = unescaped
+		}
+		if base != "" && base != "." && base != "/" {
+			names = append(names, strings.ToLower(base))
+		}
+	}
+	return names
+}
@@ -0,0 +1,100 @@
+package downloads
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"podsink/internal/domain"
+	"podsink/internal/fuzzy"
+)
+
+// ErrNoEpisodeMatch is returned when a file cannot be matched to an episode.
+var ErrNoEpisodeMatch = errors.New("no matching episode")
+
+// minTitleSimilarity is how close a file name must be to an episode title
+// for a fuzzy match.
+const minTitleSimilarity = 0.75
+
+// AdoptFile records one untracked file as the download of the episode it
+// belongs to and returns that episode. Matching tries the content hash, the
+// file name and the size like Adopt, then falls back to the episode title
+// most similar to the file name.
+func (s *Service) AdoptFile(ctx context.Context, path string) (domain.AdoptionCandidate, error) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return domain.AdoptionCandidate{}, err
+	}
+	if !isMediaFile(path) {
+		return domain.AdoptionCandidate{}, fmt.Errorf("%w: %s is not an audio file", ErrNoEpisodeMatch, filepath.Base(path))
+	}
+	candidates, err := s.store.ListAdoptionCandidates(ctx)
+	if err != nil {
+		return domain.AdoptionCandidate{}, err
+	}
+	hash, err := computeFileHash(path)
+	if err != nil {
+		return domain.AdoptionCandidate{}, err
+	}
+
+	file := adoptFile{path: path, size: stat.Size()}
+	match := newAdoptIndex(candidates).match(file, hash)
+	if match < 0 {
+		match = matchByTitle(candidates, file)
+	}
+	if match < 0 {
+		return domain.AdoptionCandidate{}, ErrNoEpisodeMatch
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		absPath = path
+	}
+	if err := s.store.PersistDownloadResult(ctx, candidates[match].ID, absPath, hash); err != nil {
+		return domain.AdoptionCandidate{}, err
+	}
+	s.events.Publish(domain.Event{Kind: domain.EventEpisodeState, EpisodeID: candidates[match].ID, State: domain.EpisodeStateDownloaded})
+	return candidates[match], nil
+}
+
+// matchByTitle picks the episode whose title is most similar to the file
+// name, preferring episodes of the podcast named by the file's folder. It
+// returns -1 when no title is close enough or two are equally close.
+func matchByTitle(candidates []domain.AdoptionCandidate, file adoptFile) int {
+	base := filepath.Base(file.path)
+	name := strings.TrimSuffix(base, filepath.Ext(base))
+	folder := strings.ToLower(filepath.Base(filepath.Dir(file.path)))
+
+	best, bestScore, tied := -1, 0.0, false
+	for i, candidate := range candidates {
+		score := fuzzy.Similarity(name, safeFilename(candidate.Title))
+		if strings.ToLower(safeFilename(candidate.PodcastTitle)) == folder {
+			score += 0.1
+		}
+		switch {
+		case score > bestScore:
+			best, bestScore, tied = i, score, false
+		case score == bestScore:
+			tied = true
+		}
+	}
+	if tied || bestScore < minTitleSimilarity {
+		return -1
+	}
+	return best
+}
+
+// RemoveDanglingFile deletes an untracked file from the download directory.
+// With prune, its folder is removed too once empty.
+func (s *Service) RemoveDanglingFile(path string, prune bool) error {
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if prune {
+		s.pruneEmptyDir(filepath.Dir(path), "")
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package downloads
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// DedupeResult summarises a scan for downloaded files with identical content.
+type DedupeResult struct {
+	Groups     int   // hashes shared by more than one distinct file
+	Duplicates int   // files that are redundant copies
+	Linked     int   // duplicates replaced with hard links
+	BytesSaved int64 // bytes reclaimed by linking, or reclaimable when not linking
+}
+
+// Dedupe finds downloaded episodes whose files have the same hash. When link is
+// true each redundant copy is replaced by a hard link to the earliest download.
+func (s *Service) Dedupe(ctx context.Context, link bool) (DedupeResult, error) {
+	groups, err := s.store.ListDuplicateDownloads(ctx)
+	if err != nil {
+		return DedupeResult{}, err
+	}
+
+	var result DedupeResult
+	for _, group := range groups {
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+		keep := group.FilePaths[0]
+		keepInfo, err := os.Stat(keep)
+		if err != nil {
+			continue
+		}
+
+		duplicates := 0
+		for _, candidate := range group.FilePaths[1:] {
+			info, err := os.Stat(candidate)
+			if err != nil || os.SameFile(keepInfo, info) {
+				continue
+			}
+			duplicates++
+			if link {
+				if err := replaceWithLink(keep, candidate); err != nil {
+					return result, fmt.Errorf("link %s: %w", candidate, err)
+				}
+				result.Linked++
+			}
+			result.BytesSaved += info.Size()
+		}
+		if duplicates > 0 {
+			result.Groups++
+			result.Duplicates += duplicates
+		}
+	}
+	return result, nil
+}
+
+// linkDuplicate places a hard link to an already downloaded file with the same
+// hash at finalPath instead of storing a second copy. It reports false when no
+// usable duplicate exists, leaving the caller to move the partial file.
+func (s *Service) linkDuplicate(ctx context.Context, episodeID, hash, partialPath, finalPath string) (bool, error) {
+	existing, err := s.store.FindDownloadedFileByHash(ctx, hash, episodeID)
+	if err != nil || existing == "" || existing == finalPath {
+		return false, err
+	}
+
+	existingInfo, err := os.Stat(existing)
+	if err != nil {
+		return false, nil
+	}
+	partialInfo, err := os.Stat(partialPath)
+	if err != nil {
+		return false, err
+	}
+	if existingInfo.Size() != partialInfo.Size() {
+		return false, nil
+	}
+
+	if err := replaceWithLink(existing, finalPath); err != nil {
+		return false, err
+	}
+	os.Remove(partialPath)
+	log.Printf("linked %s to identical file %s, saved %d bytes", finalPath, existing, partialInfo.Size())
+	return true, nil
+}
+
+// replaceWithLink makes dst a hard link to src, swapping it in via a temporary
+// name so dst is never missing.
+func replaceWithLink(src, dst string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".*.link")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	tmp.Close()
+	if err := os.Remove(tmpPath); err != nil {
+		return err
+	}
+
+	if err := os.Link(src, tmpPath); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, dst); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return syncDir(filepath.Dir(dst))
+}
@@ -0,0 +1,71 @@
+package downloads
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"podsink/internal/domain"
+)
+
+// DeleteEpisode removes the file of a downloaded episode and returns the
+// episode's new state: DELETED, or PLAYED for episodes already listened to.
+// The file path is kept so `redownload deleted` can restore it. With
+// trash_days set the file is moved to the trash instead of being removed.
+// With prune, the podcast directory is removed too once it is empty.
+func (s *Service) DeleteEpisode(ctx context.Context, info domain.EpisodeInfo, prune bool) (string, error) {
+	state := domain.EpisodeStateDeleted
+	if info.State == domain.EpisodeStatePlayed {
+		state = domain.EpisodeStatePlayed
+	}
+	trashed, err := s.trashEpisodeFile(ctx, info, state)
+	if err != nil {
+		return "", err
+	}
+	if !trashed {
+		if info.FilePath != "" {
+			if err := os.Remove(info.FilePath); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return "", err
+			}
+		}
+		if err := s.store.UpdateEpisodeState(ctx, info.ID, state); err != nil {
+			return "", err
+		}
+	}
+	if prune && info.FilePath != "" {
+		s.pruneEmptyDir(filepath.Dir(info.FilePath), info.DownloadDir)
+	}
+	s.events.Publish(domain.Event{Kind: domain.EventEpisodeState, EpisodeID: info.ID, PodcastID: info.PodcastID, State: state})
+	return state, nil
+}
+
+// pruneEmptyDir removes dir when it is empty and lies below root, the
+// podcast's download directory, or below the download root when root is
+// empty; the root itself is never removed.
+func (s *Service) pruneEmptyDir(dir, root string) {
+	if strings.TrimSpace(root) == "" {
+		root = s.config().DownloadRoot
+	}
+	root, err := filepath.Abs(strings.TrimSpace(root))
+	if err != nil {
+		return
+	}
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		return
+	}
+	rel, err := filepath.Rel(root, dir)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) > 0 {
+		return
+	}
+	if err := os.Remove(dir); err != nil {
+		log.Printf("prune %s failed: %v", dir, err)
+	}
+}
@@ -0,0 +1,98 @@
+package downloads
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"os"
+
+	"podsink/internal/domain"
+)
+
+// VerifyResult summarises re-hashing downloaded files against the hashes
+// recorded for them.
+type VerifyResult struct {
+	Total     int      // kept files on record, sampled or not
+	Checked   int      // files re-hashed
+	Corrupt   []string // IDs of episodes whose file no longer matches
+	Missing   int      // recorded files no longer on disk
+	Unhashed  int      // files recorded without a hash
+	Recovered int      // CORRUPT episodes whose file matches again
+}
+
+// EstimatedCorrupt extrapolates the corruption rate of the checked files to
+// all Total files. It is the exact count when every file was checked.
+func (r VerifyResult) EstimatedCorrupt() int {
+	if r.Checked == 0 {
+		return 0
+	}
+	return int(math.Round(float64(len(r.Corrupt)) * float64(r.Total) / float64(r.Checked)))
+}
+
+// Verify re-hashes the kept files of downloaded episodes, optionally of a
+// single podcast, and compares them with their recorded hashes. With sample
+// between 0 and 1 only that share of the files, at least one, is picked at
+// random, so repeated runs cover the library over time. A DOWNLOADED episode
+// whose file differs becomes CORRUPT and a CORRUPT one whose file matches
+// again DOWNLOADED; PLAYED episodes keep their state. Missing files are
+// counted and left to the deleted file check.
+func (s *Service) Verify(ctx context.Context, podcastID string, sample float64) (VerifyResult, error) {
+	ids, err := s.store.ListEpisodeFiles(ctx, podcastID)
+	if err != nil {
+		return VerifyResult{}, err
+	}
+
+	result := VerifyResult{Total: len(ids)}
+	if sample > 0 && sample < 1 && len(ids) > 0 {
+		n := max(1, int(math.Ceil(float64(len(ids))*sample)))
+		rand.Shuffle(len(ids), func(i, j int) { ids[i], ids[j] = ids[j], ids[i] })
+		ids = ids[:n]
+	}
+
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+		info, err := s.store.GetEpisodeInfo(ctx, id)
+		if err != nil {
+			return result, err
+		}
+		if info.Hash == "" {
+			result.Unhashed++
+			continue
+		}
+		hash, err := computeFileHash(info.FilePath)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				result.Missing++
+				continue
+			}
+			return result, fmt.Errorf("hash %s: %w", info.FilePath, err)
+		}
+		result.Checked++
+
+		corrupt := hash != info.Hash
+		if corrupt {
+			result.Corrupt = append(result.Corrupt, id)
+		}
+		if corrupt == (info.State == domain.EpisodeStateCorrupt) || info.State == domain.EpisodeStatePlayed {
+			continue
+		}
+		changed, err := s.store.SetEpisodeCorrupt(ctx, id, corrupt)
+		if err != nil {
+			return result, err
+		}
+		if !changed {
+			continue
+		}
+		state := domain.EpisodeStateCorrupt
+		if !corrupt {
+			state = domain.EpisodeStateDownloaded
+			result.Recovered++
+		}
+		s.events.Publish(domain.Event{Kind: domain.EventEpisodeState, EpisodeID: id, PodcastID: info.PodcastID, State: state})
+	}
+	return result, nil
+}
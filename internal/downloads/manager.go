@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"errors"
 	"log"
+	"os"
 	"strings"
 	"sync"
 	"time"
@@ -13,33 +14,104 @@ import (
 	"podsink/internal/repository"
 )
 
+var (
+	errWorkerRetired    = errors.New("download worker retired")
+	errMissingEnclosure = errors.New("episode has no enclosure URL")
+
+	// ErrDownloadPaused and ErrDownloadCancelled are the causes passed to
+	// Abort; the claim is released for a pause and dropped for a cancel.
+	ErrDownloadPaused    = errors.New("download paused")
+	ErrDownloadCancelled = errors.New("download cancelled")
+)
+
 type EpisodeInfoProvider interface {
 	FetchEpisodeInfo(ctx context.Context, episodeID string) (domain.EpisodeInfo, error)
 }
 
+// maxPendingWakeups bounds queued Notify calls; extra wakeups are redundant.
+const maxPendingWakeups = 16
+
 type Manager struct {
 	downloads *Service
 	episodes  EpisodeInfoProvider
+	ctx       context.Context
 	wakeCh    chan struct{}
 	cancel    context.CancelFunc
 	wg        sync.WaitGroup
+
+	mu       sync.Mutex
+	retire   []chan struct{}                    // One per running worker; closed to retire it
+	inFlight map[string]context.CancelCauseFunc // Aborts the transfer of an episode
 }
 
-func NewManager(downloads *Service, episodes EpisodeInfoProvider, workers int) *Manager {
-	ctx, cancel := context.WithCancel(context.Background())
+// NewManager starts workers that run until ctx is cancelled or Stop is called.
+func NewManager(ctx context.Context, downloads *Service, episodes EpisodeInfoProvider, workers int) *Manager {
+	ctx, cancel := context.WithCancel(ctx)
 	manager := &Manager{
 		downloads: downloads,
 		episodes:  episodes,
-		wakeCh:    make(chan struct{}, workers*2),
+		ctx:       ctx,
+		wakeCh:    make(chan struct{}, maxPendingWakeups),
 		cancel:    cancel,
+		inFlight:  make(map[string]context.CancelCauseFunc),
 	}
-	for i := 0; i < workers; i++ {
-		manager.wg.Add(1)
-		go manager.worker(ctx)
-	}
+	manager.Resize(workers)
 	return manager
 }
 
+// Workers returns the number of running workers.
+func (m *Manager) Workers() int {
+	if m == nil {
+		return 0
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.retire)
+}
+
+// Resize grows or shrinks the worker pool. Retired workers finish the
+// download they have claimed before exiting.
+func (m *Manager) Resize(workers int) {
+	if m == nil {
+		return
+	}
+	if workers < 0 {
+		workers = 0
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ctx.Err() != nil {
+		return
+	}
+	for len(m.retire) < workers {
+		stop := make(chan struct{})
+		m.retire = append(m.retire, stop)
+		m.wg.Add(1)
+		go m.worker(m.ctx, stop)
+	}
+	for len(m.retire) > workers {
+		last := len(m.retire) - 1
+		close(m.retire[last])
+		m.retire = m.retire[:last]
+	}
+}
+
+// Abort stops the in-flight transfer of an episode with cause, which must be
+// ErrDownloadPaused or ErrDownloadCancelled. It reports whether a transfer
+// was running.
+func (m *Manager) Abort(episodeID string, cause error) bool {
+	if m == nil {
+		return false
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cancel, ok := m.inFlight[episodeID]
+	if ok {
+		cancel(cause)
+	}
+	return ok
+}
+
 func (m *Manager) Notify() {
 	if m == nil {
 		return
@@ -54,22 +126,30 @@ func (m *Manager) Stop() {
 	if m == nil {
 		return
 	}
+	m.mu.Lock()
 	m.cancel()
+	m.retire = nil
+	m.mu.Unlock()
 	m.Notify()
 	m.wg.Wait()
 }
 
-func (m *Manager) worker(ctx context.Context) {
+func (m *Manager) worker(ctx context.Context, stop <-chan struct{}) {
 	defer m.wg.Done()
 	for {
 		if ctx.Err() != nil {
 			return
 		}
+		select {
+		case <-stop:
+			return
+		default:
+		}
 
 		episodeID, err := m.downloads.ClaimNextDownload(ctx)
 		if err != nil {
 			if errors.Is(err, repository.ErrNoDownloadTask) {
-				if err := m.waitForWork(ctx); err != nil {
+				if err := m.waitForWork(ctx, stop); err != nil {
 					return
 				}
 				continue
@@ -81,32 +161,141 @@ func (m *Manager) worker(ctx context.Context) {
 			continue
 		}
 
-		info, err := m.episodes.FetchEpisodeInfo(ctx, episodeID)
-		if err != nil {
-			if !errors.Is(err, sql.ErrNoRows) {
-				log.Printf("download queue fetch info %s: %v", episodeID, err)
+		if err := m.process(ctx, episodeID); err != nil && ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// process downloads a claimed episode, requeueing it on failure and dropping
+// it from the queue when it is too large. When ctx is cancelled mid-transfer
+// the claim is released so the partial file is resumed next run.
+func (m *Manager) process(ctx context.Context, episodeID string) error {
+	info, err := m.episodes.FetchEpisodeInfo(ctx, episodeID)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("download queue fetch info %s: %v", episodeID, err)
+		}
+		return err
+	}
+	if strings.TrimSpace(info.EnclosureURL) == "" {
+		log.Printf("episode %s missing enclosure URL", episodeID)
+		return errMissingEnclosure
+	}
+
+	transferCtx, cancel := context.WithCancelCause(ctx)
+	m.mu.Lock()
+	m.inFlight[episodeID] = cancel
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		delete(m.inFlight, episodeID)
+		m.mu.Unlock()
+		cancel(nil)
+	}()
+
+	m.downloads.events.Publish(domain.Event{Kind: domain.EventDownloadStarted, EpisodeID: episodeID, PodcastID: info.PodcastID, PodcastTitle: info.PodcastTitle, Title: info.Title})
+	finalPath, err := m.downloads.DownloadEpisode(transferCtx, info)
+	if err != nil {
+		if ctx.Err() != nil {
+			if err := m.downloads.ReleaseClaim(context.WithoutCancel(ctx), episodeID); err != nil {
+				log.Printf("release %s failed: %v", episodeID, err)
 			}
-			continue
+			return err
 		}
-		if strings.TrimSpace(info.EnclosureURL) == "" {
-			log.Printf("episode %s missing enclosure URL", episodeID)
-			continue
+		if cause := context.Cause(transferCtx); errors.Is(cause, ErrDownloadPaused) || errors.Is(cause, ErrDownloadCancelled) {
+			// A paused download keeps its partial file and resumes with a
+			// Range request; a cancelled one was already dropped from the queue.
+			if errors.Is(cause, ErrDownloadPaused) {
+				if err := m.downloads.ReleaseClaim(ctx, episodeID); err != nil {
+					log.Printf("release %s failed: %v", episodeID, err)
+				}
+			} else {
+				m.downloads.removePartial(info)
+			}
+			return cause
+		}
+		event := domain.Event{Kind: domain.EventDownloadFinished, EpisodeID: episodeID, PodcastID: info.PodcastID, PodcastTitle: info.PodcastTitle, Title: info.Title}
+		if errors.Is(err, ErrEpisodeTooLarge) {
+			// Skipped by policy, not failed: no download_failed hook
+			log.Printf("download %s skipped: %v", episodeID, err)
+			if err := m.downloads.SkipEpisode(ctx, info); err != nil {
+				log.Printf("skip %s failed: %v", episodeID, err)
+			}
+			event.State = domain.EpisodeStateSkipped
+			m.downloads.events.Publish(event)
+			return err
+		}
+		log.Printf("download %s failed: %v", episodeID, err)
+		if err := m.downloads.RequeueEpisode(ctx, episodeID); err != nil {
+			log.Printf("requeue %s failed: %v", episodeID, err)
 		}
-		if _, err := m.downloads.DownloadEpisode(ctx, info); err != nil {
-			log.Printf("download %s failed: %v", episodeID, err)
-			if err := m.downloads.RequeueEpisode(ctx, episodeID); err != nil {
-				log.Printf("requeue %s failed: %v", episodeID, err)
+		event.Err = err
+		m.downloads.events.Publish(event)
+		return err
+	}
+	// A failed transcode leaves the download as published
+	finalPath, err = m.downloads.Transcode(ctx, info, finalPath)
+	if err != nil && ctx.Err() == nil {
+		log.Printf("transcode %s failed: %v", episodeID, err)
+	}
+	// A failed post-processing step leaves a good download; it is recorded
+	// so it can be retried on its own.
+	finalPath, err = m.downloads.PostProcess(ctx, info, finalPath)
+	if err != nil && ctx.Err() == nil {
+		log.Printf("post-process %s failed: %v", episodeID, err)
+	}
+	event := domain.Event{Kind: domain.EventDownloadFinished, EpisodeID: episodeID, PodcastID: info.PodcastID, PodcastTitle: info.PodcastTitle, Title: info.Title, State: domain.EpisodeStateDownloaded, FilePath: finalPath}
+	if stat, err := os.Stat(finalPath); err == nil {
+		event.Bytes = stat.Size()
+	}
+	m.downloads.events.Publish(event)
+	return nil
+}
+
+// ProcessResult summarises a foreground run over the download queue.
+type ProcessResult struct {
+	Downloaded int
+	Skipped    int // over max_episode_size_mb
+	Failed     int
+}
+
+// ProcessQueue downloads waiting episodes in the caller's goroutine until the
+// queue is empty. It lets a pool with no workers make progress on demand.
+func (m *Manager) ProcessQueue(ctx context.Context) (ProcessResult, error) {
+	var result ProcessResult
+	for {
+		episodeID, err := m.downloads.ClaimNextDownload(ctx)
+		if err != nil {
+			if errors.Is(err, repository.ErrNoDownloadTask) {
+				return result, nil
 			}
+			return result, err
+		}
+		if err := m.process(ctx, episodeID); err != nil {
+			if ctx.Err() != nil {
+				return result, ctx.Err()
+			}
+			switch {
+			case errors.Is(err, ErrEpisodeTooLarge):
+				result.Skipped++
+			case !errors.Is(err, ErrDownloadPaused) && !errors.Is(err, ErrDownloadCancelled):
+				result.Failed++
+			}
+			continue
 		}
+		result.Downloaded++
 	}
 }
 
-func (m *Manager) waitForWork(ctx context.Context) error {
+func (m *Manager) waitForWork(ctx context.Context, stop <-chan struct{}) error {
 	timer := time.NewTimer(2 * time.Second)
 	defer timer.Stop()
 	select {
 	case <-ctx.Done():
 		return ctx.Err()
+	case <-stop:
+		return errWorkerRetired
 	case <-m.wakeCh:
 		return nil
 	case <-timer.C:
@@ -0,0 +1,58 @@
+package downloads
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// ErrNotMedia is returned when a server answers with something other than the
+// episode audio, typically an HTML error page sent with status 200.
+var ErrNotMedia = errors.New("response is not a media file")
+
+// sniffLength is how many leading bytes http.DetectContentType considers.
+const sniffLength = 512
+
+// documentTypes are content types that never carry episode audio.
+var documentTypes = map[string]bool{
+	"text/html":                true,
+	"application/xhtml+xml":    true,
+	"text/xml":                 true,
+	"application/xml":          true,
+	"application/rss+xml":      true,
+	"application/atom+xml":     true,
+	"application/json":         true,
+	"application/problem+json": true,
+}
+
+// checkMediaResponse rejects responses whose declared Content-Type or first
+// bytes show a web page rather than media. A declared document type is still
+// accepted when the feed's enclosure type says the same. head is nil when the
+// body continues an earlier partial download and cannot be sniffed.
+func checkMediaResponse(declared, enclosureType string, head []byte) error {
+	declaredType := baseMediaType(declared)
+	if declaredType != "" && declaredType != baseMediaType(enclosureType) && documentTypes[declaredType] {
+		return fmt.Errorf("%w: server sent %s", ErrNotMedia, declaredType)
+	}
+	if len(head) == 0 {
+		return nil
+	}
+	sniffed := baseMediaType(http.DetectContentType(head))
+	if sniffed == "text/html" || sniffed == "text/xml" {
+		return fmt.Errorf("%w: body looks like %s", ErrNotMedia, sniffed)
+	}
+	return nil
+}
+
+func baseMediaType(value string) string {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return ""
+	}
+	if parsed, _, err := mime.ParseMediaType(value); err == nil {
+		return parsed
+	}
+	return strings.ToLower(strings.TrimSpace(strings.Split(value, ";")[0]))
+}
@@ -0,0 +1,36 @@
+package downloads
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ErrPlaintextHTTP is returned when allow_http is false and a download would
+// use plain HTTP.
+var ErrPlaintextHTTP = errors.New("plaintext HTTP is disabled (allow_http: false)")
+
+// checkRedirect enforces max_redirects and refuses redirects to plain HTTP
+// when allow_http is false.
+func (s *Service) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) > s.config().MaxRedirects {
+		return fmt.Errorf("stopped after %d redirects (max_redirects)", s.config().MaxRedirects)
+	}
+	if !s.config().AllowHTTP && req.URL.Scheme == "http" {
+		return fmt.Errorf("%w: redirected to %s", ErrPlaintextHTTP, req.URL.Redacted())
+	}
+	return nil
+}
+
+// upgradeToHTTPS switches a plain HTTP URL to HTTPS. It reports whether the
+// URL was changed.
+func upgradeToHTTPS(rawURL string) (string, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil || !strings.EqualFold(u.Scheme, "http") {
+		return rawURL, false
+	}
+	u.Scheme = "https"
+	return u.String(), true
+}
@@ -0,0 +1,190 @@
+package downloads
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"podsink/internal/config"
+	"podsink/internal/domain"
+	"podsink/internal/hooks"
+)
+
+// ErrNothingToProcess is returned by RetryPostProcess when the episode has
+// no file or every post_process step already ran.
+var ErrNothingToProcess = errors.New("no post-processing steps left")
+
+// PostProcess runs the post_process steps an episode's file has not been
+// through yet, in order, recording each outcome. It stops at the first
+// failing step, so a retry picks up from there. The file's path after the
+// steps that ran is returned; transcoding changes its extension.
+func (s *Service) PostProcess(ctx context.Context, info domain.EpisodeInfo, path string) (string, error) {
+	steps, err := s.pendingSteps(ctx, info.ID)
+	if err != nil {
+		return path, err
+	}
+	for _, step := range steps {
+		record := domain.PostProcessRecord{EpisodeID: info.ID, Step: step.Name, Status: domain.PostProcessDone}
+		output, stepErr := runStep(ctx, step, info, path)
+		var hash string
+		var stat os.FileInfo
+		if stepErr == nil {
+			hash, stepErr = computeFileHash(output)
+		}
+		if stepErr == nil {
+			stat, stepErr = os.Stat(output)
+		}
+		if stepErr != nil {
+			if ctx.Err() != nil {
+				// Stopped with the app; the step runs again next time
+				return path, ctx.Err()
+			}
+			record.Status, record.Error = domain.PostProcessFailed, stepErr.Error()
+			if err := s.store.RecordPostProcessStep(context.WithoutCancel(ctx), record, "", "", 0); err != nil {
+				return path, err
+			}
+			return path, fmt.Errorf("step %s: %w", step.Name, stepErr)
+		}
+		if err := s.store.RecordPostProcessStep(ctx, record, output, hash, stat.Size()); err != nil {
+			return path, err
+		}
+		path = output
+	}
+	return path, nil
+}
+
+// RetryPostProcess runs the steps a downloaded episode has not completed,
+// starting with the one that failed.
+func (s *Service) RetryPostProcess(ctx context.Context, episodeID string) (string, error) {
+	info, err := s.store.GetEpisodeInfo(ctx, episodeID)
+	if err != nil {
+		return "", err
+	}
+	if info.FilePath == "" || (info.State != domain.EpisodeStateDownloaded && info.State != domain.EpisodeStatePlayed) {
+		return "", ErrNothingToProcess
+	}
+	steps, err := s.pendingSteps(ctx, episodeID)
+	if err != nil {
+		return "", err
+	}
+	if len(steps) == 0 {
+		return "", ErrNothingToProcess
+	}
+	if _, err := os.Stat(info.FilePath); err != nil {
+		return "", err
+	}
+	return s.PostProcess(ctx, info, info.FilePath)
+}
+
+// ListFailedPostProcess returns the failed steps still in post_process.
+func (s *Service) ListFailedPostProcess(ctx context.Context) ([]domain.PostProcessRecord, error) {
+	records, err := s.store.ListFailedPostProcess(ctx)
+	if err != nil {
+		return nil, err
+	}
+	configured := map[string]bool{}
+	for _, step := range s.config().PostProcess {
+		configured[step.Name] = true
+	}
+	failed := records[:0]
+	for _, record := range records {
+		if configured[record.Step] {
+			failed = append(failed, record)
+		}
+	}
+	return failed, nil
+}
+
+// pendingSteps returns the configured steps not yet done for an episode.
+func (s *Service) pendingSteps(ctx context.Context, episodeID string) ([]config.PostProcessStep, error) {
+	if len(s.config().PostProcess) == 0 {
+		return nil, nil
+	}
+	records, err := s.store.ListPostProcessSteps(ctx, episodeID)
+	if err != nil {
+		return nil, err
+	}
+	done := map[string]bool{}
+	for _, record := range records {
+		done[record.Step] = record.Status == domain.PostProcessDone
+	}
+	var pending []config.PostProcessStep
+	for _, step := range s.config().PostProcess {
+		if !done[step.Name] {
+			pending = append(pending, step)
+		}
+	}
+	return pending, nil
+}
+
+// runStep applies a step to the file at path and returns where the file is
+// afterwards.
+func runStep(ctx context.Context, step config.PostProcessStep, info domain.EpisodeInfo, path string) (string, error) {
+	switch step.Kind {
+	case config.PostProcessLoudnorm:
+		return path, ffmpeg(ctx, path, path, "-map", "0", "-c:v", "copy", "-af", "loudnorm", "-ar", "48000")
+	case config.PostProcessOpus:
+		target := strings.TrimSuffix(path, filepath.Ext(path)) + ".opus"
+		if err := ffmpeg(ctx, path, target, "-vn", "-c:a", "libopus", "-b:a", "64k"); err != nil {
+			return path, err
+		}
+		if target != path {
+			if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				log.Printf("remove %s after transcoding failed: %v", path, err)
+			}
+		}
+		return target, nil
+	case config.PostProcessCommand:
+		payload := hooks.Payload{
+			Event:        config.PostProcessEvent,
+			Time:         time.Now().UTC(),
+			PodcastID:    info.PodcastID,
+			PodcastTitle: info.PodcastTitle,
+			EpisodeID:    info.ID,
+			Title:        info.Title,
+			FilePath:     path,
+		}
+		body, err := json.Marshal(payload)
+		if err != nil {
+			return path, err
+		}
+		ctx, cancel := context.WithTimeout(ctx, hooks.Timeout)
+		defer cancel()
+		_, err = hooks.Command(ctx, strings.TrimSpace(step.Command), body, hooks.Environment(payload))
+		return path, err
+	}
+	return path, fmt.Errorf("unknown kind %q", step.Kind)
+}
+
+// ffmpeg converts input to output with the given output options, keeping
+// the input's metadata. It writes to a hidden file next to output first, so
+// output is only replaced by a complete file.
+func ffmpeg(ctx context.Context, input, output string, options ...string) error {
+	ext := filepath.Ext(output)
+	partial := filepath.Join(filepath.Dir(output), "."+strings.TrimSuffix(filepath.Base(output), ext)+".postprocess"+ext)
+	args := append([]string{"-nostdin", "-hide_banner", "-loglevel", "error", "-y", "-i", input, "-map_metadata", "0"}, options...)
+	if err := runProcess(exec.CommandContext(ctx, "ffmpeg", append(args, partial)...)); err != nil {
+		os.Remove(partial)
+		return err
+	}
+	if err := os.Rename(partial, output); err != nil {
+		os.Remove(partial)
+		return err
+	}
+	return nil
+}
+
+func runProcess(cmd *exec.Cmd) error {
+	out, err := cmd.CombinedOutput()
+	if err != nil && len(out) > 0 {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return err
+}
@@ -0,0 +1,55 @@
+package downloads
+
+import (
+	"time"
+
+	"podsink/internal/domain"
+)
+
+// progressInterval limits how often progress events are published per download.
+const progressInterval = 500 * time.Millisecond
+
+// progressWriter counts bytes copied to a partial file and publishes
+// throttled download progress events.
+type progressWriter struct {
+	events    domain.EventPublisher
+	episodeID string
+	title     string
+	written   int64
+	total     int64
+	last      time.Time
+
+	started time.Time // First write of this transfer
+	resumed int64     // Bytes already on disk when the transfer started
+}
+
+func (p *progressWriter) Write(b []byte) (int, error) {
+	now := time.Now()
+	if p.started.IsZero() {
+		p.started = now
+		p.resumed = p.written
+	}
+	p.written += int64(len(b))
+	if now.Sub(p.last) >= progressInterval {
+		p.last = now
+		p.events.Publish(domain.Event{
+			Kind:      domain.EventDownloadProgress,
+			EpisodeID: p.episodeID,
+			Title:     p.title,
+			Bytes:     p.written,
+			Total:     p.total,
+			Rate:      p.rate(now),
+		})
+	}
+	return len(b), nil
+}
+
+// rate returns the bytes per second received by this transfer so far; bytes
+// resumed from an earlier attempt do not count.
+func (p *progressWriter) rate(now time.Time) int64 {
+	elapsed := now.Sub(p.started)
+	if elapsed < time.Second {
+		return 0
+	}
+	return int64(float64(p.written-p.resumed) / elapsed.Seconds())
+}
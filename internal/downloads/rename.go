@@ -0,0 +1,87 @@
+package downloads
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// RenameResult summarises moving downloaded files to the names
+// filename_template gives them now.
+type RenameResult struct {
+	Renamed   int
+	Unchanged int      // files already named by the template
+	Missing   int      // recorded files no longer on disk
+	Conflicts []string // target paths taken by another file
+}
+
+// RenameFiles moves the kept files of downloaded episodes, optionally of a
+// single podcast, to the path the filename template and the current episode
+// metadata give them, recording each new path. A file is only moved when its
+// target is free, and moved back when the new path cannot be recorded. With
+// prune, folders left empty are removed.
+func (s *Service) RenameFiles(ctx context.Context, podcastID string, prune bool) (RenameResult, error) {
+	ids, err := s.store.ListEpisodeFiles(ctx, podcastID)
+	if err != nil {
+		return RenameResult{}, err
+	}
+
+	var result RenameResult
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+		info, err := s.store.GetEpisodeInfo(ctx, id)
+		if err != nil {
+			return result, err
+		}
+		target, err := s.episodeFilePath(info)
+		if err != nil {
+			return result, err
+		}
+		// Transcoding post_process steps change the extension
+		if ext := filepath.Ext(info.FilePath); ext != filepath.Ext(target) {
+			target = strings.TrimSuffix(target, filepath.Ext(target)) + ext
+		}
+		if target == info.FilePath {
+			result.Unchanged++
+			continue
+		}
+		if _, err := os.Stat(info.FilePath); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				result.Missing++
+				continue
+			}
+			return result, err
+		}
+		if _, err := os.Lstat(target); !errors.Is(err, os.ErrNotExist) {
+			result.Conflicts = append(result.Conflicts, target)
+			continue
+		}
+
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return result, err
+		}
+		if err := moveFile(info.FilePath, target); err != nil {
+			return result, fmt.Errorf("rename %s: %w", info.FilePath, err)
+		}
+		recorded, err := s.store.UpdateEpisodeFilePath(ctx, id, info.FilePath, target)
+		if err != nil || !recorded {
+			if restoreErr := moveFile(target, info.FilePath); restoreErr != nil {
+				return result, fmt.Errorf("restore %s: %w", info.FilePath, restoreErr)
+			}
+			if err == nil {
+				err = fmt.Errorf("file path of episode %s changed during rename", id)
+			}
+			return result, err
+		}
+		result.Renamed++
+		if prune {
+			s.pruneEmptyDir(filepath.Dir(info.FilePath), info.DownloadDir)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,36 @@
+package downloads
+
+import (
+	"log"
+	"regexp"
+
+	"podsink/internal/config"
+)
+
+type rewriteRule struct {
+	pattern     *regexp.Regexp
+	replacement string
+}
+
+// compileRewrites compiles the configured url_rewrites. config.Load already
+// rejects invalid patterns, so any left here are logged and skipped.
+func compileRewrites(rules []config.URLRewrite) []rewriteRule {
+	compiled := make([]rewriteRule, 0, len(rules))
+	for _, rule := range rules {
+		pattern, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			log.Printf("ignoring url_rewrites pattern %q: %v", rule.Pattern, err)
+			continue
+		}
+		compiled = append(compiled, rewriteRule{pattern: pattern, replacement: rule.Replacement})
+	}
+	return compiled
+}
+
+// rewriteURL applies every rule in order to an enclosure URL.
+func rewriteURL(rules []rewriteRule, rawURL string) string {
+	for _, rule := range rules {
+		rawURL = rule.pattern.ReplaceAllString(rawURL, rule.replacement)
+	}
+	return rawURL
+}
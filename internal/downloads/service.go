@@ -1,47 +1,134 @@
 package downloads
 
 import (
+	"bufio"
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"net/url"
 	"os"
 	"path"
 	"path/filepath"
 	"regexp"
+	"runtime"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"syscall"
 	"time"
 
+	"podsink/internal/artwork"
 	"podsink/internal/config"
 	"podsink/internal/domain"
+	"podsink/internal/feeds"
 	"podsink/internal/repository"
 )
 
 var invalidPathChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
 
+// filenamePlaceholder matches the {field} placeholders of filename_template.
+var filenamePlaceholder = regexp.MustCompile(`\{[a-z]+\}`)
+
+// ErrEpisodeTooLarge is returned when an enclosure exceeds max_episode_size_mb.
+var ErrEpisodeTooLarge = errors.New("episode too large")
+
 type SleepFunc func(context.Context, time.Duration) error
 
 type Service struct {
-	cfg        config.Config
+	current    atomic.Pointer[settings]
 	store      *repository.Store
 	httpClient *http.Client
 	sleep      SleepFunc
+	events     domain.EventPublisher
+	artwork    *artwork.Cache
+}
+
+// settings is the configuration downloads read, replaced as a whole when
+// the configuration is edited.
+type settings struct {
+	cfg      config.Config
+	rewrites []rewriteRule
 }
 
-func NewService(cfg config.Config, store *repository.Store, client *http.Client, sleep SleepFunc) *Service {
+func NewService(cfg config.Config, store *repository.Store, client *http.Client, sleep SleepFunc, events domain.EventPublisher) *Service {
 	if sleep == nil {
 		sleep = defaultSleep
 	}
-	return &Service{cfg: cfg, store: store, httpClient: client, sleep: sleep}
+	if events == nil {
+		events = domain.DiscardEvents
+	}
+	service := &Service{store: store, sleep: sleep, events: events}
+	service.Configure(cfg)
+	if client != nil {
+		// Redirect policy applies to downloads only, not to feed fetches
+		// sharing the client.
+		policyClient := *client
+		policyClient.CheckRedirect = service.checkRedirect
+		service.httpClient = &policyClient
+	}
+	return service
+}
+
+// Configure applies an edited configuration to the downloads that follow,
+// including trash_days: 0 disables the trash and deletes files at once.
+func (s *Service) Configure(cfg config.Config) {
+	s.current.Store(&settings{cfg: cfg, rewrites: compileRewrites(cfg.URLRewrites)})
+}
+
+func (s *Service) config() *config.Config {
+	return &s.current.Load().cfg
 }
 
 func (s *Service) EnqueueEpisode(ctx context.Context, episodeID string) error {
-	return s.store.EnqueueEpisode(ctx, episodeID)
+	if err := s.store.EnqueueEpisode(ctx, episodeID); err != nil {
+		return err
+	}
+	s.events.Publish(domain.Event{Kind: domain.EventEpisodeState, EpisodeID: episodeID, State: domain.EpisodeStateQueued})
+	return nil
+}
+
+// SetPriority changes the priority of a queued episode; see
+// domain.PriorityHigh. It reports false when the episode is not queued.
+func (s *Service) SetPriority(ctx context.Context, episodeID string, priority int) (bool, error) {
+	ok, err := s.store.SetDownloadPriority(ctx, episodeID, priority)
+	if err == nil && ok {
+		s.events.Publish(domain.Event{Kind: domain.EventEpisodeState, EpisodeID: episodeID, State: domain.EpisodeStateQueued})
+	}
+	return ok, err
+}
+
+// EnqueueByState queues all episodes in state, optionally for a single podcast.
+func (s *Service) EnqueueByState(ctx context.Context, state, podcastID string) (int, error) {
+	count, err := s.store.EnqueueEpisodesByState(ctx, state, podcastID)
+	if err == nil && count > 0 {
+		s.events.Publish(domain.Event{Kind: domain.EventEpisodeState, PodcastID: podcastID, State: domain.EpisodeStateQueued})
+	}
+	return count, err
+}
+
+// EnqueueEpisodes queues several episodes at once; see
+// repository.Store.EnqueueEpisodes.
+func (s *Service) EnqueueEpisodes(ctx context.Context, episodeIDs []string) (int, error) {
+	count, err := s.store.EnqueueEpisodes(ctx, episodeIDs)
+	if err == nil && count > 0 {
+		s.events.Publish(domain.Event{Kind: domain.EventEpisodeState, State: domain.EpisodeStateQueued})
+	}
+	return count, err
+}
+
+// EnqueueLatest queues the newest episodes of a podcast; see
+// repository.Store.EnqueueLatestEpisodes.
+func (s *Service) EnqueueLatest(ctx context.Context, podcastID string, n int) (int, error) {
+	count, err := s.store.EnqueueLatestEpisodes(ctx, podcastID, n)
+	if err == nil && count > 0 {
+		s.events.Publish(domain.Event{Kind: domain.EventEpisodeState, PodcastID: podcastID, State: domain.EpisodeStateQueued})
+	}
+	return count, err
 }
 
 func (s *Service) RemoveFromQueue(ctx context.Context, episodeID string) error {
@@ -52,8 +139,83 @@ func (s *Service) RequeueEpisode(ctx context.Context, episodeID string) error {
 	return s.store.RequeueEpisode(ctx, episodeID)
 }
 
+// SkipEpisode takes an episode that is too large off the queue. Episodes
+// without a previous download become SKIPPED; a re-download keeps the state
+// its existing file warrants.
+func (s *Service) SkipEpisode(ctx context.Context, info domain.EpisodeInfo) error {
+	state := stateOffQueue(info, domain.EpisodeStateSkipped)
+	if err := s.store.DropFromQueue(ctx, info.ID, state); err != nil {
+		return err
+	}
+	s.events.Publish(domain.Event{Kind: domain.EventEpisodeState, EpisodeID: info.ID, State: state})
+	return nil
+}
+
+// PauseDownload holds a queued episode back from workers. It reports false
+// when the episode is not queued or already paused.
+func (s *Service) PauseDownload(ctx context.Context, episodeID string) (bool, error) {
+	paused, err := s.store.PauseDownload(ctx, episodeID)
+	if err == nil && paused {
+		s.events.Publish(domain.Event{Kind: domain.EventEpisodeState, EpisodeID: episodeID, State: domain.EpisodeStateQueued})
+	}
+	return paused, err
+}
+
+// ResumeDownload releases a paused episode to the workers again.
+func (s *Service) ResumeDownload(ctx context.Context, episodeID string) (bool, error) {
+	resumed, err := s.store.ResumeDownload(ctx, episodeID)
+	if err == nil && resumed {
+		s.events.Publish(domain.Event{Kind: domain.EventEpisodeState, EpisodeID: episodeID, State: domain.EpisodeStateQueued})
+	}
+	return resumed, err
+}
+
+// CancelDownload takes an episode off the queue and discards its partial
+// file. The episode returns to SEEN, or to the state its existing download
+// warrants when it was queued for a re-download.
+func (s *Service) CancelDownload(ctx context.Context, info domain.EpisodeInfo) error {
+	state := stateOffQueue(info, domain.EpisodeStateSeen)
+	if err := s.store.DropFromQueue(ctx, info.ID, state); err != nil {
+		return err
+	}
+	s.removePartial(info)
+	s.events.Publish(domain.Event{Kind: domain.EventEpisodeState, EpisodeID: info.ID, State: state})
+	return nil
+}
+
+func (s *Service) removePartial(info domain.EpisodeInfo) {
+	if err := os.Remove(s.episodePartialPath(info)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("remove partial download of %s failed: %v", info.ID, err)
+	}
+}
+
+// stateOffQueue is the state of an episode taken off the queue: fallback
+// when it was never downloaded, otherwise whatever its existing file warrants.
+func stateOffQueue(info domain.EpisodeInfo, fallback string) string {
+	if info.FilePath == "" {
+		return fallback
+	}
+	if _, err := os.Stat(info.FilePath); err == nil {
+		return domain.EpisodeStateDownloaded
+	}
+	return domain.EpisodeStateDeleted
+}
+
+// IsDownloadActive reports whether a worker is currently downloading the episode.
+func (s *Service) IsDownloadActive(ctx context.Context, episodeID string) (bool, error) {
+	return s.store.IsDownloadActive(ctx, episodeID)
+}
+
+func (s *Service) ReleaseClaim(ctx context.Context, episodeID string) error {
+	return s.store.ReleaseClaim(ctx, episodeID)
+}
+
 func (s *Service) PersistDownloadResult(ctx context.Context, episodeID, finalPath, hash string) error {
-	return s.store.PersistDownloadResult(ctx, episodeID, finalPath, hash)
+	if err := s.store.PersistDownloadResult(ctx, episodeID, finalPath, hash); err != nil {
+		return err
+	}
+	s.events.Publish(domain.Event{Kind: domain.EventEpisodeState, EpisodeID: episodeID, State: domain.EpisodeStateDownloaded})
+	return nil
 }
 
 func (s *Service) IncrementRetryCount(ctx context.Context, episodeID string) error {
@@ -64,19 +226,52 @@ func (s *Service) ClaimNextDownload(ctx context.Context) (string, error) {
 	return s.store.ClaimNextDownload(ctx)
 }
 
+// CrossDevice reports whether TmpDir and DownloadRoot live on different
+// filesystems, in which case every finished download falls back to a slow copy.
+func (s *Service) CrossDevice() (bool, error) {
+	root := strings.TrimSpace(s.config().DownloadRoot)
+	if root == "" {
+		return false, fmt.Errorf("download root is not configured")
+	}
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return false, err
+	}
+	if err := os.MkdirAll(s.config().TmpDir, 0o755); err != nil {
+		return false, err
+	}
+
+	probe, err := os.CreateTemp(s.config().TmpDir, "podsink-probe-*")
+	if err != nil {
+		return false, err
+	}
+	probePath := probe.Name()
+	probe.Close()
+
+	target := filepath.Join(root, filepath.Base(probePath))
+	if err := os.Rename(probePath, target); err != nil {
+		os.Remove(probePath)
+		var linkErr *os.LinkError
+		if errors.As(err, &linkErr) && linkErr.Err == syscall.EXDEV {
+			return true, nil
+		}
+		return false, err
+	}
+	return false, os.Remove(target)
+}
+
 func (s *Service) DownloadEpisode(ctx context.Context, info domain.EpisodeInfo) (string, error) {
-	finalPath, err := s.episodeFilePath(info)
+	finalPath, err := s.resolveFilePath(ctx, info)
 	if err != nil {
 		return "", err
 	}
 	if err := os.MkdirAll(filepath.Dir(finalPath), 0o755); err != nil {
 		return "", err
 	}
-	if err := os.MkdirAll(s.cfg.TmpDir, 0o755); err != nil {
+	if err := os.MkdirAll(s.config().TmpDir, 0o755); err != nil {
 		return "", err
 	}
 
-	attempts := s.cfg.RetryCount + 1
+	attempts := s.config().RetryCount + 1
 	if attempts <= 0 {
 		attempts = 1
 	}
@@ -92,9 +287,16 @@ func (s *Service) DownloadEpisode(ctx context.Context, info domain.EpisodeInfo)
 		if err == nil {
 			return resultPath, nil
 		}
+		if errors.Is(err, ErrEpisodeTooLarge) {
+			return "", err
+		}
+		if ctx.Err() != nil {
+			// Stopped, paused or cancelled mid-transfer; not a failed attempt
+			return "", err
+		}
 
 		attemptErr = err
-		if err := s.store.IncrementRetryCount(ctx, info.ID); err != nil {
+		if err := s.store.RecordDownloadFailure(ctx, info.ID, err.Error()); err != nil {
 			return "", err
 		}
 
@@ -103,7 +305,7 @@ func (s *Service) DownloadEpisode(ctx context.Context, info domain.EpisodeInfo)
 		}
 
 		backoff := time.Second << i
-		maxBackoff := time.Duration(s.cfg.RetryBackoffMaxSec) * time.Second
+		maxBackoff := time.Duration(s.config().RetryBackoffMaxSec) * time.Second
 		if maxBackoff > 0 && backoff > maxBackoff {
 			backoff = maxBackoff
 		}
@@ -120,6 +322,9 @@ func (s *Service) downloadOnce(ctx context.Context, info domain.EpisodeInfo, fin
 	if _, err := os.Stat(finalPath); err == nil {
 		existingHash, err := computeFileHash(finalPath)
 		if err == nil && existingHash == info.Hash && info.Hash != "" {
+			if err := s.PersistDownloadResult(ctx, info.ID, finalPath, info.Hash); err != nil {
+				return "", err
+			}
 			return finalPath, nil
 		}
 	}
@@ -139,11 +344,16 @@ func (s *Service) downloadOnce(ctx context.Context, info domain.EpisodeInfo, fin
 		return "", err
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, info.EnclosureURL, nil)
+	requestURL := s.requestURL(info.EnclosureURL)
+	upgraded := false
+	if !s.config().AllowHTTP {
+		requestURL, upgraded = upgradeToHTTPS(requestURL)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL, nil)
 	if err != nil {
 		return "", err
 	}
-	if ua := strings.TrimSpace(s.cfg.UserAgent); ua != "" {
+	if ua := strings.TrimSpace(s.config().UserAgent); ua != "" {
 		req.Header.Set("User-Agent", ua)
 	}
 	if existingSize > 0 {
@@ -152,26 +362,88 @@ func (s *Service) downloadOnce(ctx context.Context, info domain.EpisodeInfo, fin
 
 	resp, err := s.httpClient.Do(req)
 	if err != nil {
+		if upgraded && !errors.Is(err, ErrPlaintextHTTP) && ctx.Err() == nil {
+			return "", fmt.Errorf("download episode: %w and the HTTPS upgrade failed: %w", ErrPlaintextHTTP, err)
+		}
 		return "", fmt.Errorf("download episode: %w", err)
 	}
 	defer resp.Body.Close()
 
+	body := bufio.NewReaderSize(resp.Body, sniffLength)
+	var head []byte
+	complete := false // The partial file already holds the whole episode
 	switch resp.StatusCode {
 	case http.StatusOK:
-		if existingSize > 0 {
-			if err := file.Truncate(0); err != nil {
-				return "", err
-			}
-			if _, err := file.Seek(0, io.SeekStart); err != nil {
-				return "", err
-			}
+		if head, err = body.Peek(sniffLength); err != nil && !errors.Is(err, io.EOF) {
+			return "", err
 		}
 	case http.StatusPartialContent:
+		if start, _, ok := parseContentRange(resp.Header.Get("Content-Range")); ok && start != existingSize {
+			return "", fmt.Errorf("download failed: server resumed at byte %d instead of %d", start, existingSize)
+		}
+	case http.StatusRequestedRangeNotSatisfiable:
+		// An earlier attempt received everything but was cut off before
+		// the length could be confirmed.
+		if _, total, ok := parseContentRange(resp.Header.Get("Content-Range")); ok && existingSize > 0 && total == existingSize {
+			complete = true
+			break
+		}
+		return "", fmt.Errorf("download failed: %s", resp.Status)
 	default:
 		return "", fmt.Errorf("download failed: %s", resp.Status)
 	}
+	if !complete {
+		if err := checkMediaResponse(resp.Header.Get("Content-Type"), info.MimeType, head); err != nil {
+			return "", err
+		}
+	}
+
+	if resp.StatusCode == http.StatusOK && existingSize > 0 {
+		if err := file.Truncate(0); err != nil {
+			return "", err
+		}
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return "", err
+		}
+	}
 
-	if _, err := io.Copy(file, resp.Body); err != nil {
+	progress := &progressWriter{events: s.events, episodeID: info.ID, title: info.Title}
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		progress.written = existingSize
+		if _, total, ok := parseContentRange(resp.Header.Get("Content-Range")); ok && total > 0 {
+			progress.total = total
+		}
+	case http.StatusRequestedRangeNotSatisfiable:
+		progress.written, progress.total = existingSize, existingSize
+	}
+	if resp.ContentLength > 0 && progress.total == 0 {
+		progress.total = progress.written + resp.ContentLength
+	}
+	maxMB := s.config().MaxEpisodeSizeMB
+	limit := int64(maxMB) << 20
+	tooLarge := func(size int64) error {
+		file.Close()
+		os.Remove(partialPath)
+		return fmt.Errorf("%w: %d MB exceeds max_episode_size_mb of %d MB", ErrEpisodeTooLarge, size>>20, maxMB)
+	}
+	if limit > 0 && progress.total > limit {
+		return "", tooLarge(progress.total)
+	}
+	if !complete {
+		var src io.Reader = body
+		if limit > 0 {
+			// Chunked responses announce no length; stop one byte past the limit
+			src = io.LimitReader(body, max(limit-progress.written+1, 0))
+		}
+		if _, err := io.Copy(io.MultiWriter(file, progress), src); err != nil {
+			return "", err
+		}
+		if limit > 0 && progress.written > limit {
+			return "", tooLarge(progress.written)
+		}
+	}
+	if err := verifyLength(info.ID, progress.written, progress.total, info.SizeBytes); err != nil {
 		return "", err
 	}
 	if err := file.Sync(); err != nil {
@@ -190,39 +462,209 @@ func (s *Service) downloadOnce(ctx context.Context, info domain.EpisodeInfo, fin
 		existingHash, err := computeFileHash(finalPath)
 		if err == nil && existingHash == hash {
 			os.Remove(partialPath)
+			if err := s.PersistDownloadResult(ctx, info.ID, finalPath, hash); err != nil {
+				return "", err
+			}
 			return finalPath, nil
 		}
 	}
 
-	if err := moveFile(partialPath, finalPath); err != nil {
-		return "", err
+	// Keep the previous download until the new one is recorded, so a failure
+	// below leaves the old file in place.
+	previous, err := archiveFile(finalPath)
+	if err != nil {
+		return "", fmt.Errorf("archive previous download: %w", err)
+	}
+
+	linked := false
+	if s.config().DedupeHardlinks {
+		if linked, err = s.linkDuplicate(ctx, info.ID, hash, partialPath, finalPath); err != nil {
+			log.Printf("hardlink duplicate of %s failed, storing a copy: %v", info.ID, err)
+			linked = false
+		}
+	}
+	if !linked {
+		if err := moveFile(partialPath, finalPath); err != nil {
+			previous.restore()
+			return "", err
+		}
+		// Tagged only once in place, so a failed attempt never leaves a
+		// tagged partial to resume; the recorded hash is the tagged file's.
+		if s.config().EmbedTags {
+			s.embedTags(ctx, info, finalPath)
+			if hash, err = computeFileHash(finalPath); err != nil {
+				previous.restore()
+				return "", fmt.Errorf("compute hash: %w", err)
+			}
+		}
 	}
 
-	if err := s.store.PersistDownloadResult(ctx, info.ID, finalPath, hash); err != nil {
+	if err := s.PersistDownloadResult(ctx, info.ID, finalPath, hash); err != nil {
+		previous.restore()
 		return "", err
 	}
+	previous.discard()
 
 	return finalPath, nil
 }
 
+// requestURL is the URL actually fetched for an enclosure: tracking prefixes
+// stored before strip_tracking_prefixes was enabled are dropped, then
+// url_rewrites apply.
+func (s *Service) requestURL(enclosureURL string) string {
+	if s.config().StripTrackingPrefixes {
+		enclosureURL = feeds.StripTrackingPrefixes(enclosureURL)
+	}
+	return rewriteURL(s.current.Load().rewrites, enclosureURL)
+}
+
+// archivedFile is a hard-linked backup of a previous download.
+type archivedFile struct {
+	path   string
+	target string
+}
+
+// archiveFile keeps the file at target under a hidden name in the same
+// directory. It returns nil when there is no file to keep.
+func archiveFile(target string) (*archivedFile, error) {
+	if _, err := os.Stat(target); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(target), "."+filepath.Base(target)+".*.previous")
+	if err != nil {
+		return nil, err
+	}
+	backup := tmp.Name()
+	tmp.Close()
+	if err := os.Remove(backup); err != nil {
+		return nil, err
+	}
+	if err := os.Link(target, backup); err != nil {
+		// Filesystems without hard links get a full copy instead.
+		if err := copyIntoPlace(target, backup); err != nil {
+			return nil, err
+		}
+	}
+	return &archivedFile{path: backup, target: target}, nil
+}
+
+// restore moves the backup back over its original location.
+func (a *archivedFile) restore() {
+	if a == nil {
+		return
+	}
+	if err := os.Rename(a.path, a.target); err != nil {
+		log.Printf("restore previous download %s failed: %v", a.target, err)
+		return
+	}
+	syncDir(filepath.Dir(a.target))
+}
+
+// discard removes the backup once its replacement is recorded.
+func (a *archivedFile) discard() {
+	if a == nil {
+		return
+	}
+	if err := os.Remove(a.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("remove previous download %s failed: %v", a.path, err)
+	}
+}
+
+// episodeFilePath expands filename_template below the podcast's download
+// directory, or the download root when it has none. Each placeholder is
+// made safe for file names; path segments left empty by missing values are
+// dropped.
 func (s *Service) episodeFilePath(info domain.EpisodeInfo) (string, error) {
-	root := strings.TrimSpace(s.cfg.DownloadRoot)
+	root := strings.TrimSpace(info.DownloadDir)
+	if root == "" {
+		root = strings.TrimSpace(s.config().DownloadRoot)
+	}
 	if root == "" {
 		return "", fmt.Errorf("download root is not configured")
 	}
-	podcastName := safeFilename(info.PodcastTitle)
-	if podcastName == "" {
-		podcastName = "podcast"
+	template := strings.TrimSpace(s.config().FilenameTemplate)
+	if template == "" {
+		template = config.DefaultFilenameTemplate
+	}
+	ext := strings.TrimPrefix(fileExtension(info.EnclosureURL), ".")
+	segments := strings.Split(template, "/")
+	parts := []string{root}
+	for i, segment := range segments {
+		name := filenamePlaceholder.ReplaceAllStringFunc(segment, func(placeholder string) string {
+			return filenameField(info, strings.Trim(placeholder, "{}"), ext)
+		})
+		name = strings.Trim(name, " ._-")
+		if name == "" {
+			if i < len(segments)-1 {
+				continue
+			}
+			name = "episode." + ext
+		}
+		parts = append(parts, name)
+	}
+	return filepath.Join(parts...), nil
+}
+
+// resolveFilePath is episodeFilePath, with the episode's {guid} appended to
+// the name when another episode's file already has that path, as happens for
+// episodes with the same title under the default template. The other file
+// is never replaced.
+func (s *Service) resolveFilePath(ctx context.Context, info domain.EpisodeInfo) (string, error) {
+	path, err := s.episodeFilePath(info)
+	if err != nil {
+		return "", err
+	}
+	owner, err := s.store.EpisodeWithFilePath(ctx, path, info.ID)
+	if err != nil || owner == "" {
+		return path, err
+	}
+	ext := filepath.Ext(path)
+	alternative := strings.TrimSuffix(path, ext) + "-" + filenameField(info, "guid", "") + ext
+	taken, err := s.store.EpisodeWithFilePath(ctx, alternative, info.ID)
+	if err != nil {
+		return "", err
 	}
-	episodeName := safeFilename(info.Title)
-	if episodeName == "" {
-		episodeName = safeFilename(info.ID)
+	if taken != "" {
+		return "", fmt.Errorf("%s and %s are taken by episodes %s and %s", path, alternative, owner, taken)
 	}
-	if episodeName == "" {
-		episodeName = "episode"
+	return alternative, nil
+}
+
+// filenameField is the value of a filename_template placeholder.
+func filenameField(info domain.EpisodeInfo, field, ext string) string {
+	switch field {
+	case "podcast":
+		if name := safeFilename(info.PodcastTitle); name != "" {
+			return name
+		}
+		return "podcast"
+	case "title":
+		if name := safeFilename(info.Title); name != "" {
+			return name
+		}
+		if name := safeFilename(info.ID); name != "" {
+			return name
+		}
+		return "episode"
+	case "date":
+		if info.HasPublish {
+			return info.PublishedAt.Format("2006-01-02")
+		}
+	case "number":
+		if info.Number > 0 {
+			return strconv.Itoa(info.Number)
+		}
+	case "guid":
+		sum := sha256.Sum256([]byte(info.ID))
+		return hex.EncodeToString(sum[:4])
+	case "ext":
+		return ext
 	}
-	ext := fileExtension(info.EnclosureURL)
-	return filepath.Join(root, podcastName, episodeName+ext), nil
+	return ""
 }
 
 func (s *Service) episodePartialPath(info domain.EpisodeInfo) string {
@@ -233,7 +675,7 @@ func (s *Service) episodePartialPath(info domain.EpisodeInfo) string {
 	if name == "" {
 		name = "episode"
 	}
-	return filepath.Join(s.cfg.TmpDir, fmt.Sprintf("podsink-%s.partial", name))
+	return filepath.Join(s.config().TmpDir, fmt.Sprintf("podsink-%s.partial", name))
 }
 
 func safeFilename(value string) string {
@@ -281,40 +723,77 @@ func computeFileHash(filePath string) (string, error) {
 	return hex.EncodeToString(hasher.Sum(nil)), nil
 }
 
+// moveFile places src at dst and fsyncs the destination directory so the
+// rename survives a crash before the database records the download. The
+// existing dst is only replaced once the new content is complete.
 func moveFile(src, dst string) error {
-	if err := os.Remove(dst); err != nil && !errors.Is(err, os.ErrNotExist) {
-		return err
-	}
 	if err := os.Rename(src, dst); err != nil {
 		var linkErr *os.LinkError
-		if errors.As(err, &linkErr) && linkErr.Err == syscall.EXDEV {
-			in, err := os.Open(src)
-			if err != nil {
-				return err
-			}
-			defer in.Close()
+		if !errors.As(err, &linkErr) || linkErr.Err != syscall.EXDEV {
+			return err
+		}
+		if err := copyIntoPlace(src, dst); err != nil {
+			return err
+		}
+		if err := os.Remove(src); err != nil {
+			return err
+		}
+	}
+	return syncDir(filepath.Dir(dst))
+}
 
-			out, err := os.Create(dst)
-			if err != nil {
-				return err
-			}
-			if _, err := io.Copy(out, in); err != nil {
-				out.Close()
-				return err
-			}
-			if err := out.Close(); err != nil {
-				return err
-			}
-			if err := os.Remove(src); err != nil {
-				return err
-			}
-			return nil
+// copyIntoPlace copies src to a temporary name in dst's directory and renames
+// it over dst, so a partially copied file never appears under the final name.
+func copyIntoPlace(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpPath := out.Name()
+	placed := false
+	defer func() {
+		if !placed {
+			out.Close()
+			os.Remove(tmpPath)
 		}
+	}()
+
+	if _, err := io.Copy(out, in); err != nil {
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		return err
+	}
+	if err := out.Close(); err != nil {
 		return err
 	}
+	if err := os.Rename(tmpPath, dst); err != nil {
+		return err
+	}
+	placed = true
 	return nil
 }
 
+// syncDir flushes directory metadata (new or renamed entries) to disk.
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		// Directories cannot be fsynced on Windows; NTFS journals renames.
+		return nil
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
 func defaultSleep(ctx context.Context, d time.Duration) error {
 	timer := time.NewTimer(d)
 	defer timer.Stop()
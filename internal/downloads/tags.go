@@ -0,0 +1,55 @@
+package downloads
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/jaytaylor/html2text"
+
+	"podsink/internal/artwork"
+	"podsink/internal/domain"
+	"podsink/internal/tags"
+)
+
+// SetArtwork gives embed_tags access to the podcast cover art cache.
+func (s *Service) SetArtwork(cache *artwork.Cache) {
+	s.artwork = cache
+}
+
+// embedTags writes the episode's metadata into a finished download. A file
+// that cannot be tagged is still a good download, so failures are only
+// logged.
+func (s *Service) embedTags(ctx context.Context, info domain.EpisodeInfo, path string) {
+	meta := tags.Metadata{
+		Title:       info.Title,
+		Podcast:     info.PodcastTitle,
+		Description: plainDescription(info.Description),
+	}
+	if info.HasPublish {
+		meta.Published = info.PublishedAt
+	}
+	if s.artwork != nil && info.PodcastArtworkURL != "" {
+		cover, err := s.artwork.Fetch(ctx, info.PodcastID, info.PodcastArtworkURL)
+		if err == nil {
+			meta.Cover, err = os.ReadFile(cover)
+		}
+		if err != nil {
+			log.Printf("cover art for %s unavailable: %v", info.ID, err)
+		}
+	}
+	if err := tags.Write(path, meta); err != nil && !errors.Is(err, tags.ErrUnsupported) {
+		log.Printf("tagging %s failed: %v", info.ID, err)
+	}
+}
+
+// plainDescription turns an HTML show note into text for a tag.
+func plainDescription(desc string) string {
+	desc = strings.TrimSpace(desc)
+	if text, err := html2text.FromString(desc, html2text.Options{OmitLinks: true}); err == nil {
+		return strings.TrimSpace(text)
+	}
+	return desc
+}
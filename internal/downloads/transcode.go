@@ -0,0 +1,101 @@
+package downloads
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"podsink/internal/config"
+	"podsink/internal/domain"
+)
+
+// transcodeTargets maps transcode_format to ffmpeg's audio encoder and the
+// extension of the files it produces.
+var transcodeTargets = map[string]struct{ codec, ext string }{
+	config.TranscodeOpus: {"libopus", ".opus"},
+	config.TranscodeMP3:  {"libmp3lame", ".mp3"},
+	config.TranscodeAAC:  {"aac", ".m4a"},
+}
+
+// Transcode converts a finished download to transcode_format at
+// transcode_bitrate and records the new file's path, hash and size. The
+// original is removed, or with transcode_keep_original moved below
+// config.OriginalsDir. It returns the path of the episode's file, which is
+// unchanged when transcoding is off or fails.
+func (s *Service) Transcode(ctx context.Context, info domain.EpisodeInfo, path string) (string, error) {
+	target, ok := transcodeTargets[s.config().TranscodeFormat]
+	if !ok {
+		return path, nil
+	}
+	output := strings.TrimSuffix(path, filepath.Ext(path)) + target.ext
+	input := path
+	if s.config().TranscodeKeepOriginal {
+		kept, err := s.originalPath(path)
+		if err != nil {
+			return path, err
+		}
+		if err := os.MkdirAll(filepath.Dir(kept), 0o755); err != nil {
+			return path, err
+		}
+		if err := moveFile(path, kept); err != nil {
+			return path, err
+		}
+		input = kept
+	}
+	restore := func() {
+		if input != path {
+			if err := moveFile(input, path); err != nil {
+				log.Printf("restore %s failed: %v", path, err)
+			}
+		}
+	}
+
+	if err := ffmpeg(ctx, input, output, "-vn", "-c:a", target.codec, "-b:a", s.config().TranscodeBitrate); err != nil {
+		restore()
+		return path, fmt.Errorf("transcode: %w", err)
+	}
+	hash, err := computeFileHash(output)
+	var stat os.FileInfo
+	if err == nil {
+		stat, err = os.Stat(output)
+	}
+	if err == nil {
+		err = s.store.RecordTranscode(ctx, info.ID, output, hash, stat.Size())
+	}
+	if err != nil {
+		if output != path {
+			os.Remove(output)
+		}
+		restore()
+		return path, err
+	}
+	if input == path && output != path {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Printf("remove %s after transcoding failed: %v", path, err)
+		}
+	}
+	return output, nil
+}
+
+// originalPath is where transcode_keep_original keeps a download: below
+// config.OriginalsDir at the same path relative to the download root, or
+// under the file's own name for downloads outside it.
+func (s *Service) originalPath(path string) (string, error) {
+	root, err := filepath.Abs(s.config().DownloadRoot)
+	if err != nil {
+		return "", err
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(root, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		rel = filepath.Base(abs)
+	}
+	return filepath.Join(config.OriginalsDir(s.config().DownloadRoot), rel), nil
+}
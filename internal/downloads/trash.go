@@ -0,0 +1,119 @@
+package downloads
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"podsink/internal/config"
+	"podsink/internal/domain"
+)
+
+var (
+	// ErrRestoreConflict is returned when a file already exists where a
+	// trashed file would be restored to.
+	ErrRestoreConflict = errors.New("a file already exists at the original path")
+	// ErrNotRestorable is returned when the episode of a trashed file was
+	// downloaded again or no longer exists.
+	ErrNotRestorable = errors.New("episode was downloaded again or removed")
+)
+
+// trashEpisodeFile moves an episode's file to the trash and records it
+// together with the episode's new state. It reports false when the trash is
+// disabled or there is no file to move.
+func (s *Service) trashEpisodeFile(ctx context.Context, info domain.EpisodeInfo, state string) (bool, error) {
+	if s.config().TrashDays <= 0 || info.FilePath == "" {
+		return false, nil
+	}
+	if _, err := os.Stat(info.FilePath); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	dir := config.TrashDir(s.config().DownloadRoot)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return false, err
+	}
+	now := time.Now().UTC()
+	trashPath := filepath.Join(dir, fmt.Sprintf("%d-%s", now.UnixNano(), filepath.Base(info.FilePath)))
+	if err := moveFile(info.FilePath, trashPath); err != nil {
+		return false, err
+	}
+
+	entry := domain.TrashEntry{EpisodeID: info.ID, TrashPath: trashPath, OriginalPath: info.FilePath, State: info.State, TrashedAt: now}
+	replaced, err := s.store.TrashEpisodeFile(ctx, entry, state)
+	if err != nil {
+		if restoreErr := moveFile(trashPath, info.FilePath); restoreErr != nil {
+			log.Printf("restore %s from trash failed: %v", info.FilePath, restoreErr)
+		}
+		return false, err
+	}
+	if replaced != "" && replaced != trashPath {
+		// An earlier deletion of the same episode is superseded
+		if err := os.Remove(replaced); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Printf("remove %s from trash failed: %v", replaced, err)
+		}
+	}
+	return true, nil
+}
+
+// ListTrash returns the files in the trash, latest first.
+func (s *Service) ListTrash(ctx context.Context) ([]domain.TrashEntry, error) {
+	return s.store.ListTrash(ctx)
+}
+
+// RestoreTrash moves a trashed file back to where it was deleted from and
+// gives the episode its state from before the deletion. The file is moved
+// back to the trash if the episode cannot take it anymore.
+func (s *Service) RestoreTrash(ctx context.Context, entry domain.TrashEntry) error {
+	if _, err := os.Lstat(entry.OriginalPath); !errors.Is(err, os.ErrNotExist) {
+		return ErrRestoreConflict
+	}
+	if err := os.MkdirAll(filepath.Dir(entry.OriginalPath), 0o755); err != nil {
+		return err
+	}
+	if err := moveFile(entry.TrashPath, entry.OriginalPath); err != nil {
+		return err
+	}
+	restored, err := s.store.RestoreTrashedFile(ctx, entry)
+	if err != nil || !restored {
+		if restoreErr := moveFile(entry.OriginalPath, entry.TrashPath); restoreErr != nil {
+			log.Printf("move %s back to trash failed: %v", entry.OriginalPath, restoreErr)
+		}
+		if err == nil {
+			err = ErrNotRestorable
+		}
+		return err
+	}
+	s.events.Publish(domain.Event{Kind: domain.EventEpisodeState, EpisodeID: entry.EpisodeID, State: entry.State})
+	return nil
+}
+
+// PurgeTrash deletes the trashed files older than trash_days, or every
+// trashed file with all, and returns how many were deleted.
+func (s *Service) PurgeTrash(ctx context.Context, all bool) (int, error) {
+	entries, err := s.store.ListTrash(ctx)
+	if err != nil {
+		return 0, err
+	}
+	cutoff := time.Now().Add(-time.Duration(s.config().TrashDays) * 24 * time.Hour)
+	purged := 0
+	for _, entry := range entries {
+		if !all && entry.TrashedAt.After(cutoff) {
+			continue
+		}
+		if err := os.Remove(entry.TrashPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return purged, err
+		}
+		if err := s.store.RemoveTrashEntry(ctx, entry.EpisodeID); err != nil {
+			return purged, err
+		}
+		purged++
+	}
+	return purged, nil
+}
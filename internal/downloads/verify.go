@@ -0,0 +1,58 @@
+package downloads
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// ErrTruncated is returned when a transfer ends before the episode's full
+// length arrived. The partial file is kept so the next attempt resumes it.
+var ErrTruncated = errors.New("download incomplete")
+
+// parseContentRange reads a Content-Range header of the form
+// "bytes <start>-<end>/<total>" or "bytes */<total>". start is -1 for the
+// second form and total is -1 when the server does not know it.
+func parseContentRange(header string) (start, total int64, ok bool) {
+	spec, found := strings.CutPrefix(strings.TrimSpace(header), "bytes ")
+	if !found {
+		return 0, 0, false
+	}
+	span, size, found := strings.Cut(spec, "/")
+	if !found {
+		return 0, 0, false
+	}
+	start, total = -1, -1
+	if size != "*" {
+		n, err := strconv.ParseInt(size, 10, 64)
+		if err != nil || n < 0 {
+			return 0, 0, false
+		}
+		total = n
+	}
+	if span != "*" {
+		first, _, found := strings.Cut(span, "-")
+		n, err := strconv.ParseInt(first, 10, 64)
+		if !found || err != nil || n < 0 {
+			return 0, 0, false
+		}
+		start = n
+	}
+	return start, total, true
+}
+
+// verifyLength checks the bytes on disk against the length the server
+// announced through Content-Length or Content-Range. The feed's enclosure
+// length only gets a mismatch logged, since feeds often carry stale lengths,
+// for instance after dynamic ad insertion changed the file.
+func verifyLength(episodeID string, written, announced, enclosureLength int64) error {
+	switch {
+	case announced > 0 && written != announced:
+		return fmt.Errorf("%w: received %d of %d bytes", ErrTruncated, written, announced)
+	case announced <= 0 && enclosureLength > 0 && written < enclosureLength:
+		log.Printf("download of %s received %d bytes, fewer than the enclosure length of %d", episodeID, written, enclosureLength)
+	}
+	return nil
+}
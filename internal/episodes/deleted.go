@@ -0,0 +1,114 @@
+package episodes
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"podsink/internal/domain"
+)
+
+// mtimeGranularity covers filesystems that store modification times in
+// whole seconds or coarser. A folder changed more recently than this may
+// change again without a new time, so it is not cached.
+const mtimeGranularity = 2 * time.Second
+
+// checkedDir is what CheckDeletedFiles found in a folder with downloads.
+type checkedDir struct {
+	modTime time.Time
+	files   map[string]bool // Downloaded files found in the folder
+}
+
+// CheckDeletedFiles marks downloaded episodes whose file is gone as DELETED
+// and returns how many it marked. Removing or renaming a file changes the
+// modification time of its folder, so only folders that changed since the
+// last check, or hold downloads not seen then, are read again.
+func (s *Service) CheckDeletedFiles(ctx context.Context) (int, error) {
+	s.checking.Lock()
+	defer s.checking.Unlock()
+
+	files, err := s.store.ListDownloadedFiles(ctx)
+	if err != nil {
+		return 0, err
+	}
+	byDir := map[string][]string{}
+	for _, path := range files {
+		dir := filepath.Dir(path)
+		byDir[dir] = append(byDir[dir], path)
+	}
+
+	checked := make(map[string]checkedDir, len(byDir))
+	present := map[string]bool{}
+	for dir, paths := range byDir {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		found, err := s.checkDir(dir, paths)
+		if err != nil {
+			return 0, err
+		}
+		for path := range found.files {
+			present[path] = true
+		}
+		if time.Since(found.modTime) >= mtimeGranularity {
+			checked[dir] = found
+		}
+	}
+	// Folders without downloads anymore are dropped
+	s.checked = checked
+
+	missing := map[string]string{}
+	for id, path := range files {
+		if !present[path] {
+			missing[id] = path
+		}
+	}
+	if len(missing) == 0 {
+		return 0, nil
+	}
+	marked, err := s.store.MarkFilesDeleted(ctx, missing)
+	for _, id := range marked {
+		s.events.Publish(domain.Event{Kind: domain.EventEpisodeState, EpisodeID: id, State: domain.EpisodeStateDeleted})
+	}
+	return len(marked), err
+}
+
+// checkDir returns which of the downloaded files in dir exist, reading the
+// folder only when the last check does not answer that.
+func (s *Service) checkDir(dir string, paths []string) (checkedDir, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return checkedDir{}, nil
+		}
+		return checkedDir{}, err
+	}
+	if cached, ok := s.checked[dir]; ok && cached.modTime.Equal(info.ModTime()) {
+		known := true
+		for _, path := range paths {
+			known = known && cached.files[path]
+		}
+		if known {
+			return cached, nil
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return checkedDir{}, err
+	}
+	names := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		names[entry.Name()] = true
+	}
+	found := checkedDir{modTime: info.ModTime(), files: map[string]bool{}}
+	for _, path := range paths {
+		if names[filepath.Base(path)] {
+			found.files[path] = true
+		}
+	}
+	return found, nil
+}
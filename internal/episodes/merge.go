@@ -0,0 +1,39 @@
+package episodes
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"podsink/internal/domain"
+	"podsink/internal/repository"
+	"podsink/internal/storage"
+)
+
+// ImportStates merges the listening progress of another podsink database,
+// opened read-only at path, into this library; see
+// repository.Store.MergeEpisodeStates for how.
+func (s *Service) ImportStates(ctx context.Context, path string) (domain.StateMergeResult, error) {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return domain.StateMergeResult{}, errors.New("file path cannot be empty")
+	}
+	db, err := storage.OpenReadOnly(path)
+	if err != nil {
+		return domain.StateMergeResult{}, err
+	}
+	defer db.Close()
+
+	records, err := repository.ReadEpisodeStates(ctx, db)
+	if err != nil {
+		return domain.StateMergeResult{}, err
+	}
+	result, err := s.store.MergeEpisodeStates(ctx, records)
+	if err != nil {
+		return result, err
+	}
+	if result.Played+result.Ignored+result.Seen+result.Positions > 0 {
+		s.events.Publish(domain.Event{Kind: domain.EventEpisodeState})
+	}
+	return result, nil
+}
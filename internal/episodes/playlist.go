@@ -0,0 +1,69 @@
+package episodes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"podsink/internal/m3u"
+	"podsink/internal/repository"
+)
+
+// ErrNoEpisodesToExport is returned by ExportPlaylist when no downloaded
+// file matches.
+var ErrNoEpisodesToExport = errors.New("no downloaded episodes to export")
+
+// ExportPlaylist writes the downloaded files matching filter to an M3U
+// playlist at filePath and returns how many it wrote. Files missing on disk
+// are left out. Files below the playlist's folder are written relative to
+// it, so the folder can be copied as a whole, e.g. to a USB stick; others
+// keep their absolute path.
+func (s *Service) ExportPlaylist(ctx context.Context, filePath string, filter repository.PlaylistFilter) (int, error) {
+	filePath = strings.TrimSpace(filePath)
+	if filePath == "" {
+		return 0, errors.New("file path cannot be empty")
+	}
+	dir, err := filepath.Abs(filepath.Dir(filePath))
+	if err != nil {
+		return 0, err
+	}
+
+	found, err := s.store.ListPlaylistEntries(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	entries := make([]m3u.Entry, 0, len(found))
+	for _, episode := range found {
+		if _, err := os.Stat(episode.FilePath); err != nil {
+			continue
+		}
+		path := episode.FilePath
+		if rel, err := filepath.Rel(dir, path); err == nil && filepath.IsLocal(rel) {
+			path = rel
+		}
+		entries = append(entries, m3u.Entry{
+			Path:        path,
+			Title:       episode.PodcastTitle + " - " + episode.Title,
+			DurationSec: episode.DurationSec,
+		})
+	}
+	if len(entries) == 0 {
+		return 0, ErrNoEpisodesToExport
+	}
+
+	file, err := os.Create(filePath)
+	if err != nil {
+		return 0, fmt.Errorf("create file: %w", err)
+	}
+	if err := m3u.Write(file, entries); err != nil {
+		file.Close()
+		return 0, err
+	}
+	if err := file.Close(); err != nil {
+		return 0, err
+	}
+	return len(entries), nil
+}
@@ -2,21 +2,64 @@ package episodes
 
 import (
 	"context"
+	"sync"
 
 	"podsink/internal/domain"
 	"podsink/internal/repository"
 )
 
+// Metadata keys holding how the episode list was last shown.
+const (
+	viewFilterKey = "view.episodes.filter"
+	viewOrderKey  = "view.episodes.order"
+)
+
 type Service struct {
-	store *repository.Store
+	store  *repository.Store
+	events domain.EventPublisher
+
+	// checking serializes CheckDeletedFiles, which keeps what it found
+	// per folder in checked.
+	checking sync.Mutex
+	checked  map[string]checkedDir
+}
+
+func NewService(store *repository.Store, events domain.EventPublisher) *Service {
+	if events == nil {
+		events = domain.DiscardEvents
+	}
+	return &Service{store: store, events: events}
+}
+
+// List lists a page of the episodes of all subscriptions; see
+// repository.Store.ListEpisodes.
+func (s *Service) List(ctx context.Context, listing repository.EpisodeListing, page repository.Page) ([]domain.EpisodeResult, error) {
+	return s.store.ListEpisodes(ctx, listing, page)
+}
+
+// ListByPodcast lists a page of the episodes of one podcast. It returns
+// sql.ErrNoRows for a podcast that is not subscribed.
+func (s *Service) ListByPodcast(ctx context.Context, podcastID string, listing repository.EpisodeListing, page repository.Page) ([]domain.EpisodeResult, error) {
+	results, err := s.store.ListEpisodesByPodcast(ctx, podcastID, listing, page)
+	if err != nil || len(results) > 0 {
+		return results, err
+	}
+	if _, err := s.store.GetPodcast(ctx, podcastID); err != nil {
+		return nil, err
+	}
+	return results, nil
 }
 
-func NewService(store *repository.Store) *Service {
-	return &Service{store: store}
+// Query lists the episodes matching a library query; see
+// repository.Store.QueryEpisodes for the syntax.
+func (s *Service) Query(ctx context.Context, expr string) ([]domain.EpisodeResult, error) {
+	return s.store.QueryEpisodes(ctx, expr)
 }
 
-func (s *Service) List(ctx context.Context) ([]domain.EpisodeResult, error) {
-	return s.store.ListEpisodes(ctx)
+// Search lists the episodes whose title or description contains every
+// term; see repository.Store.SearchEpisodes.
+func (s *Service) Search(ctx context.Context, terms []string, page repository.Page) ([]domain.EpisodeResult, error) {
+	return s.store.SearchEpisodes(ctx, terms, page)
 }
 
 func (s *Service) ListQueued(ctx context.Context) ([]domain.QueuedEpisodeResult, error) {
@@ -27,8 +70,72 @@ func (s *Service) ListDownloaded(ctx context.Context) ([]domain.EpisodeResult, e
 	return s.store.ListDownloadedEpisodes(ctx)
 }
 
-func (s *Service) MarkAllSeen(ctx context.Context) error {
-	return s.store.MarkAllEpisodesSeen(ctx)
+// MarkAllSeen marks every NEW episode as SEEN and returns how many changed.
+func (s *Service) MarkAllSeen(ctx context.Context) (int, error) {
+	count, err := s.store.MarkAllEpisodesSeen(ctx)
+	if err != nil {
+		return 0, err
+	}
+	s.events.Publish(domain.Event{Kind: domain.EventEpisodeState, State: domain.EpisodeStateSeen})
+	return count, nil
+}
+
+// Ignore marks several episodes as IGNORED at once; see
+// repository.Store.IgnoreEpisodes.
+func (s *Service) Ignore(ctx context.Context, episodeIDs []string) (int, error) {
+	count, err := s.store.IgnoreEpisodes(ctx, episodeIDs)
+	if err == nil && count > 0 {
+		s.events.Publish(domain.Event{Kind: domain.EventEpisodeState, State: domain.EpisodeStateIgnored})
+	}
+	return count, err
+}
+
+// MarkSeen marks several NEW or IGNORED episodes as SEEN at once.
+func (s *Service) MarkSeen(ctx context.Context, episodeIDs []string) (int, error) {
+	count, err := s.store.MarkEpisodesSeen(ctx, episodeIDs)
+	if err == nil && count > 0 {
+		s.events.Publish(domain.Event{Kind: domain.EventEpisodeState, State: domain.EpisodeStateSeen})
+	}
+	return count, err
+}
+
+// MarkPodcastSeen marks the NEW episodes of one podcast as SEEN and returns
+// how many changed.
+func (s *Service) MarkPodcastSeen(ctx context.Context, podcastID string) (int, error) {
+	count, err := s.store.MarkPodcastEpisodesSeen(ctx, podcastID)
+	if err != nil {
+		return 0, err
+	}
+	s.events.Publish(domain.Event{Kind: domain.EventEpisodeState, PodcastID: podcastID, State: domain.EpisodeStateSeen})
+	return count, nil
+}
+
+// IgnorePodcast ignores the NEW and SEEN episodes of one podcast; see
+// repository.Store.IgnorePodcastEpisodes.
+func (s *Service) IgnorePodcast(ctx context.Context, podcastID string) (int, error) {
+	count, err := s.store.IgnorePodcastEpisodes(ctx, podcastID)
+	if err == nil && count > 0 {
+		s.events.Publish(domain.Event{Kind: domain.EventEpisodeState, PodcastID: podcastID, State: domain.EpisodeStateIgnored})
+	}
+	return count, err
+}
+
+// ViewSettings returns the filter mode and order the episode list was last
+// shown with, empty when unset.
+func (s *Service) ViewSettings(ctx context.Context) (filter, order string, err error) {
+	if filter, err = s.store.GetMetadata(ctx, viewFilterKey); err != nil {
+		return "", "", err
+	}
+	order, err = s.store.GetMetadata(ctx, viewOrderKey)
+	return filter, order, err
+}
+
+// SaveViewSettings remembers the filter mode and order of the episode list.
+func (s *Service) SaveViewSettings(ctx context.Context, filter, order string) error {
+	if err := s.store.SetMetadata(ctx, viewFilterKey, filter); err != nil {
+		return err
+	}
+	return s.store.SetMetadata(ctx, viewOrderKey, order)
 }
 
 func (s *Service) FetchEpisodeInfo(ctx context.Context, episodeID string) (domain.EpisodeInfo, error) {
@@ -52,15 +159,33 @@ func (s *Service) EpisodeDetails(ctx context.Context, episodeID string) (domain.
 		PodcastID:    info.PodcastID,
 		PodcastTitle: info.PodcastTitle,
 		SizeBytes:    info.SizeBytes,
+		RetryCount:   info.RetryCount,
+		LastError:    info.LastError,
+		PositionSec:  info.PositionSec,
+		DurationSec:  info.DurationSec,
+		Season:       info.Season,
+		Number:       info.Number,
+		ImageURL:     info.ImageURL,
+		ChaptersURL:  info.ChaptersURL,
+		Soundbites:   info.Soundbites,
 	}, nil
 }
 
-func (s *Service) UpdateEpisodeState(ctx context.Context, episodeID, state string) error {
-	return s.store.UpdateEpisodeState(ctx, episodeID, state)
+// MarkPlayed records that the user has finished listening to an episode.
+func (s *Service) MarkPlayed(ctx context.Context, episodeID string) error {
+	if err := s.store.MarkPlayed(ctx, episodeID); err != nil {
+		return err
+	}
+	s.events.Publish(domain.Event{Kind: domain.EventEpisodeState, EpisodeID: episodeID, State: domain.EpisodeStatePlayed})
+	return nil
 }
 
-func (s *Service) CheckDeletedFiles(ctx context.Context) error {
-	return s.store.CheckAndUpdateDeletedFiles(ctx)
+func (s *Service) UpdateEpisodeState(ctx context.Context, episodeID, state string) error {
+	if err := s.store.UpdateEpisodeState(ctx, episodeID, state); err != nil {
+		return err
+	}
+	s.events.Publish(domain.Event{Kind: domain.EventEpisodeState, EpisodeID: episodeID, State: state})
+	return nil
 }
 
 func (s *Service) CorrectQueuedStates(ctx context.Context) error {
@@ -71,10 +196,22 @@ func (s *Service) CountQueued(ctx context.Context) (int, error) {
 	return s.store.CountQueuedEpisodes(ctx)
 }
 
+func (s *Service) CountNew(ctx context.Context) (int, error) {
+	return s.store.CountNewEpisodes(ctx)
+}
+
+func (s *Service) CountFailed(ctx context.Context) (int, error) {
+	return s.store.CountFailedDownloads(ctx)
+}
+
 func (s *Service) CountDownloaded(ctx context.Context) (int, error) {
 	return s.store.CountDownloadedEpisodes(ctx)
 }
 
-func (s *Service) FindDanglingFiles(ctx context.Context, downloadRoot string) ([]domain.DanglingFile, error) {
-	return s.store.FindDanglingFiles(ctx, downloadRoot)
+func (s *Service) DatabaseStats(ctx context.Context) (domain.DatabaseStats, error) {
+	return s.store.DatabaseStats(ctx)
+}
+
+func (s *Service) FindDanglingFiles(ctx context.Context, downloadRoot string, skipDirs ...string) ([]domain.DanglingFile, error) {
+	return s.store.FindDanglingFiles(ctx, downloadRoot, skipDirs...)
 }
@@ -0,0 +1,255 @@
+package feeds
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"io"
+	"regexp"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// newDecoder returns an XML decoder that tolerates what real feeds get
+// wrong: HTML entities such as &nbsp;, bare ampersands, unquoted attributes
+// and mismatched end tags. Text reaches it as UTF-8 from newFeedReader, so
+// any declared encoding is accepted as is.
+func newDecoder(r io.Reader) *xml.Decoder {
+	decoder := xml.NewDecoder(r)
+	decoder.Strict = false
+	decoder.Entity = xml.HTMLEntity
+	decoder.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+	return decoder
+}
+
+var encodingDecl = regexp.MustCompile(`^\s*<\?xml[^>]*encoding\s*=\s*["']([A-Za-z0-9._:-]+)["']`)
+
+// legacyCharsets are declared encodings decoded as Windows-1252, which is a
+// superset of the printable ISO-8859-1 range.
+var legacyCharsets = map[string]bool{
+	"iso-8859-1":   true,
+	"iso8859-1":    true,
+	"latin1":       true,
+	"windows-1252": true,
+	"cp1252":       true,
+	"us-ascii":     true,
+	"ascii":        true,
+}
+
+// newFeedReader converts a feed to clean UTF-8. A UTF-16 byte order mark or
+// a Latin-1/Windows-1252 declaration selects the source encoding; otherwise
+// the feed is read as UTF-8 and stray bytes that are not valid UTF-8 are
+// taken as Windows-1252, the usual culprit. Characters XML forbids, such as
+// most control characters, are dropped.
+func newFeedReader(r io.Reader) io.Reader {
+	br := bufio.NewReader(r)
+	text := &textReader{r: br, decode: decodeUTF8}
+	head, _ := br.Peek(512)
+	switch {
+	case bytes.HasPrefix(head, []byte{0xEF, 0xBB, 0xBF}):
+		br.Discard(3)
+	case bytes.HasPrefix(head, []byte{0xFE, 0xFF}):
+		br.Discard(2)
+		text.decode = decodeUTF16(binary.BigEndian)
+	case bytes.HasPrefix(head, []byte{0xFF, 0xFE}):
+		br.Discard(2)
+		text.decode = decodeUTF16(binary.LittleEndian)
+	default:
+		if match := encodingDecl.FindSubmatch(head); match != nil && legacyCharsets[strings.ToLower(string(match[1]))] {
+			text.decode = decodeWindows1252
+		}
+	}
+	return text
+}
+
+type textReader struct {
+	r       *bufio.Reader
+	decode  func(*bufio.Reader) (rune, error)
+	pending []byte // Encoded bytes of a rune that did not fit the last Read
+	err     error
+}
+
+func (t *textReader) Read(p []byte) (int, error) {
+	n := copy(p, t.pending)
+	t.pending = t.pending[n:]
+	var buf [utf8.UTFMax]byte
+	for n < len(p) && t.err == nil {
+		r, err := t.decode(t.r)
+		if err != nil {
+			t.err = err
+			break
+		}
+		if !isXMLChar(r) {
+			continue
+		}
+		size := utf8.EncodeRune(buf[:], r)
+		copied := copy(p[n:], buf[:size])
+		n += copied
+		t.pending = append(t.pending, buf[copied:size]...)
+	}
+	if n > 0 {
+		return n, nil
+	}
+	return 0, t.err
+}
+
+func decodeUTF8(r *bufio.Reader) (rune, error) {
+	value, size, err := r.ReadRune()
+	if err != nil {
+		return 0, err
+	}
+	if value == utf8.RuneError && size == 1 {
+		r.UnreadRune()
+		b, _ := r.ReadByte()
+		return windows1252(b), nil
+	}
+	return value, nil
+}
+
+func decodeWindows1252(r *bufio.Reader) (rune, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	return windows1252(b), nil
+}
+
+func decodeUTF16(order binary.ByteOrder) func(*bufio.Reader) (rune, error) {
+	var buf [2]byte
+	next := func(r *bufio.Reader) (rune, error) {
+		if _, err := io.ReadFull(r, buf[:]); err != nil {
+			if errors.Is(err, io.ErrUnexpectedEOF) {
+				err = io.EOF
+			}
+			return 0, err
+		}
+		return rune(order.Uint16(buf[:])), nil
+	}
+	return func(r *bufio.Reader) (rune, error) {
+		first, err := next(r)
+		if err != nil || !utf16.IsSurrogate(first) {
+			return first, err
+		}
+		second, err := next(r)
+		if err != nil {
+			return 0, err
+		}
+		return utf16.DecodeRune(first, second), nil
+	}
+}
+
+// cp1252 maps the bytes 0x80-0x9F, where Windows-1252 differs from
+// ISO-8859-1. Unassigned bytes keep their Latin-1 value.
+var cp1252 = [32]rune{
+	'€', 0x81, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0x8D, 'Ž', 0x8F,
+	0x90, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0x9D, 'ž', 'Ÿ',
+}
+
+func windows1252(b byte) rune {
+	if b >= 0x80 && b <= 0x9F {
+		return cp1252[b-0x80]
+	}
+	return rune(b)
+}
+
+func isXMLChar(r rune) bool {
+	return r == '\t' || r == '\n' || r == '\r' ||
+		(r >= 0x20 && r <= 0xD7FF) ||
+		(r >= 0xE000 && r <= 0xFFFD) ||
+		(r >= 0x10000 && r <= 0x10FFFF)
+}
+
+// segmenter cuts <item> elements, or <entry> elements of an Atom feed, out
+// of a feed so each can be decoded on its own; a syntax error then costs one
+// item instead of the whole feed. Everything outside items, the channel
+// metadata, collects in outside.
+type segmenter struct {
+	r       *bufio.Reader
+	outside bytes.Buffer
+	name    string // Item element name, set from the document element
+	pending bool   // An item start was read while looking for the previous end
+}
+
+func newSegmenter(r io.Reader) *segmenter {
+	return &segmenter{r: bufio.NewReader(r)}
+}
+
+// next returns the next item element, or io.EOF when the feed has no more.
+// An item missing its end tag is closed where the next one starts.
+func (s *segmenter) next() ([]byte, error) {
+	item := []byte{'<'}
+	if !s.pending {
+		for {
+			chunk, err := s.r.ReadBytes('<')
+			if err != nil {
+				s.outside.Write(chunk)
+				return nil, err
+			}
+			if s.name == "" {
+				s.detectFormat()
+			}
+			if s.name != "" && hasTagName(s.r, s.name) {
+				s.outside.Write(chunk[:len(chunk)-1])
+				break
+			}
+			s.outside.Write(chunk)
+		}
+	}
+	s.pending = false
+	end := "</" + s.name + ">"
+
+	for {
+		chunk, err := s.r.ReadBytes('<')
+		item = append(item, chunk...)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return append(item, end...), nil
+			}
+			return nil, err
+		}
+		switch {
+		case hasTagName(s.r, "/"+s.name):
+			rest, err := s.r.ReadBytes('>')
+			if err != nil && !errors.Is(err, io.EOF) {
+				return nil, err
+			}
+			return append(item, rest...), nil
+		case hasTagName(s.r, s.name):
+			s.pending = true
+			return append(item[:len(item)-1], end...), nil
+		}
+	}
+}
+
+// detectFormat looks at a tag following an already consumed '<' and, if it
+// is the document element, picks the item element name: <entry> for an Atom
+// <feed>, <item> otherwise.
+func (s *segmenter) detectFormat() {
+	peek, _ := s.r.Peek(1)
+	if len(peek) == 0 || peek[0] == '?' || peek[0] == '!' {
+		return
+	}
+	s.name = "item"
+	if hasTagName(s.r, "feed") {
+		s.name = "entry"
+	}
+}
+
+// hasTagName reports whether the tag following an already consumed '<' has
+// the given name.
+func hasTagName(r *bufio.Reader, name string) bool {
+	peek, _ := r.Peek(len(name) + 1)
+	if len(peek) <= len(name) || string(peek[:len(name)]) != name {
+		return false
+	}
+	switch peek[len(name)] {
+	case ' ', '\t', '\r', '\n', '>', '/':
+		return true
+	}
+	return false
+}
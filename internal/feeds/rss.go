@@ -1,11 +1,17 @@
 package feeds
 
 import (
+	"bytes"
 	"context"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"io"
+	"log"
+	"math"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -14,6 +20,55 @@ import (
 type Podcast struct {
 	Title       string
 	Description string
+	// SkippedItems counts items dropped because they could not be parsed.
+	SkippedItems int
+	// BadDates lists publication dates that could not be parsed. Their
+	// episodes have a zero PublishedAt.
+	BadDates []string
+	// Schedule holds the feed's refresh hints.
+	Schedule Schedule
+	// Hub is the WebSub hub the feed advertises with <atom:link rel="hub">,
+	// and Self its canonical URL from <atom:link rel="self">. Either may be
+	// empty.
+	Hub  string
+	Self string
+	// GUID is the podcast:guid of the channel in lower case, a stable
+	// identity that survives feed URL changes. Empty when absent.
+	GUID string
+	// Language is the channel's <language>, or the xml:lang of an Atom
+	// feed, as a lower-case tag such as "en-us". Empty when absent.
+	Language string
+	// ImageURL is the cover art: itunes:image, else the RSS <image>, or an
+	// Atom feed's <logo> or <icon>. Empty when absent.
+	ImageURL string
+	// Funding lists the podcast:funding links in feed order.
+	Funding []Funding
+	// Value lists the channel's podcast:value blocks in feed order.
+	Value []ValueBlock
+}
+
+// Funding is a podcast:funding link where listeners can support the show.
+type Funding struct {
+	URL   string
+	Label string // Link text, e.g. "Support us on Patreon"; may be empty
+}
+
+// ValueBlock is a podcast:value block: how listeners can stream payments to
+// the show while listening, and who receives them.
+type ValueBlock struct {
+	Type       string // Payment layer, e.g. "lightning"
+	Method     string // Transport, e.g. "keysend"
+	Suggested  string // Suggested amount per minute in the layer's unit; may be empty
+	Recipients []ValueRecipient
+}
+
+// ValueRecipient is a podcast:valueRecipient.
+type ValueRecipient struct {
+	Name    string
+	Type    string // Address kind, e.g. "node"
+	Address string
+	Split   int  // Share of the payment, or a percentage when Fee is set
+	Fee     bool // Taken off the top before the shares are divided
 }
 
 // Episode captures parsed feed episode information.
@@ -23,11 +78,39 @@ type Episode struct {
 	Description string
 	PublishedAt time.Time
 	Enclosure   string
+	MimeType    string
 	SizeBytes   int64
+	DurationSec int
+	// Season and Number are itunes:season and itunes:episode, 0 when absent.
+	Season int
+	Number int
+	// ImageURL is the episode's itunes:image, ChaptersURL the JSON chapters
+	// file of podcast:chapters. Either may be empty.
+	ImageURL    string
+	ChaptersURL string
+	// Soundbites are the podcast:soundbite highlights in feed order.
+	Soundbites []Soundbite
+	// LinkID is the permalink GUID or link the episode would have been keyed
+	// by when ID is its enclosure URL instead. Episodes stored under it by
+	// earlier versions are matched through it.
+	LinkID string
 }
 
-// Fetch retrieves and parses an RSS/Atom feed.
-func Fetch(ctx context.Context, client *http.Client, url string) (Podcast, []Episode, error) {
+// Soundbite is a podcast:soundbite, a highlight of an episode.
+type Soundbite struct {
+	StartSec    float64
+	DurationSec float64
+	Title       string // May be empty
+}
+
+// ErrFeedTooLarge is returned when a feed exceeds the size limit given to Fetch.
+var ErrFeedTooLarge = errors.New("feed too large")
+
+// Fetch retrieves and parses an RSS/Atom feed. The body is parsed as it
+// streams in; a feed larger than maxBytes is abandoned as soon as the limit
+// is crossed, or before reading when Content-Length already exceeds it.
+// maxBytes <= 0 disables the limit.
+func Fetch(ctx context.Context, client *http.Client, url string, maxBytes int64) (Podcast, []Episode, error) {
 	if client == nil {
 		client = http.DefaultClient
 	}
@@ -45,76 +128,508 @@ func Fetch(ctx context.Context, client *http.Client, url string) (Podcast, []Epi
 		return Podcast{}, nil, fmt.Errorf("fetch feed failed: %s", resp.Status)
 	}
 
-	data, err := io.ReadAll(resp.Body)
+	var body io.Reader = resp.Body
+	if maxBytes > 0 {
+		if resp.ContentLength > maxBytes {
+			return Podcast{}, nil, tooLarge(maxBytes)
+		}
+		body = &limitedReader{r: resp.Body, remaining: maxBytes, limit: maxBytes}
+	}
+	podcast, episodes, err := parseFeed(body)
 	if err != nil {
-		return Podcast{}, nil, fmt.Errorf("read feed: %w", err)
+		if errors.Is(err, ErrFeedTooLarge) {
+			return Podcast{}, nil, err
+		}
+		return Podcast{}, nil, fmt.Errorf("parse feed: %w", err)
+	}
+	if podcast.SkippedItems > 0 {
+		log.Printf("feed %s: skipped %d malformed item(s)", url, podcast.SkippedItems)
+	}
+	if len(podcast.BadDates) > 0 {
+		log.Printf("feed %q (%s): could not parse %d publication date(s), e.g. %q", podcast.Title, url, len(podcast.BadDates), podcast.BadDates[0])
 	}
+	return podcast, episodes, nil
+}
 
-	var rss rssDocument
-	if err := xml.Unmarshal(data, &rss); err != nil {
-		return Podcast{}, nil, fmt.Errorf("parse feed: %w", err)
+func tooLarge(maxBytes int64) error {
+	return fmt.Errorf("%w: exceeds %d MB (max_feed_size_mb)", ErrFeedTooLarge, maxBytes>>20)
+}
+
+// limitedReader fails with ErrFeedTooLarge instead of reporting EOF when the
+// limit is reached, so a truncated feed is never mistaken for a complete one.
+type limitedReader struct {
+	r         io.Reader
+	remaining int64
+	limit     int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.remaining <= 0 {
+		// Probe for one more byte to tell an exact fit from an oversized feed.
+		var probe [1]byte
+		n, err := l.r.Read(probe[:])
+		if n > 0 {
+			return 0, tooLarge(l.limit)
+		}
+		return 0, err
+	}
+	if int64(len(p)) > l.remaining {
+		p = p[:l.remaining]
 	}
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	return n, err
+}
 
-	episodes := make([]Episode, 0, len(rss.Channel.Items))
-	for _, item := range rss.Channel.Items {
-		guid := strings.TrimSpace(item.GUID.Value)
-		if guid == "" {
-			guid = strings.TrimSpace(item.Enclosure.URL)
+// parseFeed reads an RSS or Atom feed item by item. Each <item> or <entry>
+// is cut from the stream and decoded separately, so the raw document is never
+// held in memory as a whole and a malformed item is skipped rather than
+// failing the feed.
+func parseFeed(r io.Reader) (Podcast, []Episode, error) {
+	segments := newSegmenter(newFeedReader(r))
+	var items []rssItem
+	var namespaces []xml.Attr
+	skipped := 0
+	for {
+		raw, err := segments.next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return Podcast{}, nil, err
 		}
-		if guid == "" {
-			guid = strings.TrimSpace(item.Link)
+		if namespaces == nil {
+			// The document head is complete once the first item starts.
+			if namespaces, err = headNamespaces(segments.outside.Bytes()); err != nil {
+				return Podcast{}, nil, err
+			}
 		}
-		if guid == "" {
-			guid = fmt.Sprintf("%s:%s", rss.Channel.Title, item.Title)
+		item, err := decodeItem(raw, namespaces, segments.name)
+		if err != nil {
+			skipped++
+			continue
 		}
+		items = append(items, item)
+	}
 
-		published, _ := parseTime(item.PubDate)
+	channel, err := parseChannel(segments.outside.Bytes())
+	if err != nil {
+		return Podcast{}, nil, err
+	}
+	var badDates []string
+	episodes := make([]Episode, 0, len(items))
+	for _, item := range items {
+		episode := toEpisode(channel.Title, item)
+		if dates := item.pubDates(); episode.PublishedAt.IsZero() && len(dates) > 0 {
+			badDates = append(badDates, dates[0])
+		}
+		episodes = append(episodes, episode)
+	}
+	return Podcast{
+		Title:        strings.TrimSpace(channel.Title),
+		Description:  strings.TrimSpace(channel.Description),
+		SkippedItems: skipped,
+		BadDates:     badDates,
+		Schedule:     channel.Schedule,
+		Hub:          channel.Hub,
+		Self:         channel.Self,
+		GUID:         channel.GUID,
+		Language:     channel.Language,
+		ImageURL:     channel.ImageURL,
+		Funding:      channel.Funding,
+		Value:        channel.Value,
+	}, episodes, nil
+}
 
-		// Parse size from enclosure length attribute
-		var sizeBytes int64
-		if item.Enclosure.Length != "" {
-			// strconv.ParseInt handles the conversion
-			if size, err := parseSize(item.Enclosure.Length); err == nil {
-				sizeBytes = size
+// rootStart returns the document element, which must be <rss> or an Atom
+// <feed>.
+func rootStart(decoder *xml.Decoder) (xml.StartElement, error) {
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return xml.StartElement{}, io.ErrUnexpectedEOF
+			}
+			return xml.StartElement{}, err
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			if start.Name.Local != "rss" && start.Name.Local != "feed" {
+				return xml.StartElement{}, fmt.Errorf("expected element type <rss> or <feed> but have <%s>", start.Name.Local)
 			}
+			return start, nil
 		}
+	}
+}
 
-		episodes = append(episodes, Episode{
-			ID:          guid,
-			Title:       strings.TrimSpace(item.Title),
-			Description: strings.TrimSpace(item.Description),
-			PublishedAt: published,
-			Enclosure:   strings.TrimSpace(item.Enclosure.URL),
-			SizeBytes:   sizeBytes,
-		})
+// headNamespaces collects the namespace declarations made before the first
+// item, which the separately decoded items need to resolve prefixes such as
+// itunes:.
+func headNamespaces(head []byte) ([]xml.Attr, error) {
+	decoder := newDecoder(bytes.NewReader(head))
+	root, err := rootStart(decoder)
+	if err != nil {
+		return nil, err
+	}
+	namespaces := []xml.Attr{}
+	for token := xml.Token(root); ; {
+		if start, ok := token.(xml.StartElement); ok {
+			for _, attr := range start.Attr {
+				if attr.Name.Space == "xmlns" {
+					namespaces = append(namespaces, attr)
+				}
+			}
+		}
+		// The head ends inside <channel>, so running out of tokens is expected.
+		if token, err = decoder.Token(); err != nil {
+			return namespaces, nil
+		}
 	}
+}
 
-	return Podcast{
-		Title:       strings.TrimSpace(rss.Channel.Title),
-		Description: strings.TrimSpace(rss.Channel.Description),
-	}, episodes, nil
+// decodeItem decodes an <item>, or an Atom <entry> when name is "entry".
+func decodeItem(raw []byte, namespaces []xml.Attr, name string) (rssItem, error) {
+	var doc bytes.Buffer
+	doc.WriteString("<items")
+	for _, attr := range namespaces {
+		doc.WriteString(" xmlns:" + attr.Name.Local + `="`)
+		xml.EscapeText(&doc, []byte(attr.Value))
+		doc.WriteString(`"`)
+	}
+	doc.WriteString(">")
+	doc.Write(raw)
+	doc.WriteString("</items>")
+
+	decoder := newDecoder(&doc)
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return rssItem{}, err
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != name {
+			continue
+		}
+		if name == "entry" {
+			var entry atomEntry
+			err := decoder.DecodeElement(&entry, &start)
+			return entry.item(), err
+		}
+		var item rssItem
+		err = decoder.DecodeElement(&item, &start)
+		return item, err
+	}
 }
 
+// parseChannel reads the channel title and description from the feed with
+// its items removed. The document must be complete; a feed cut off before
+// </rss> fails instead of yielding a partial episode list. An Atom <feed> is
+// its own channel.
+func parseChannel(doc []byte) (rssChannel, error) {
+	decoder := newDecoder(bytes.NewReader(doc))
+	root, err := rootStart(decoder)
+	if err != nil {
+		return rssChannel{}, err
+	}
+	var channel rssChannel
+	depth := 1 // Inside <rss>
+	level := 2 // Depth of the channel's children
+	if root.Name.Local == "feed" {
+		level = 1
+	}
+	for _, attr := range root.Attr {
+		if attr.Name.Local == "lang" && (attr.Name.Space == xmlNamespace || attr.Name.Space == "xml") {
+			channel.Language = NormalizeLanguage(attr.Value)
+		}
+	}
+	for depth > 0 {
+		token, err := decoder.Token()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return rssChannel{}, io.ErrUnexpectedEOF
+			}
+			return rssChannel{}, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch {
+			case level == 2 && depth == 1 && t.Name.Local == "channel":
+				depth++
+				continue
+			case depth == level && t.Name.Local == "title":
+				err = decoder.DecodeElement(&channel.Title, &t)
+			case depth == level && (t.Name.Local == "description" || t.Name.Local == "subtitle" && level == 1):
+				err = decoder.DecodeElement(&channel.Description, &t)
+			case depth == level && t.Name.Local == "ttl":
+				var ttl string
+				if err = decoder.DecodeElement(&ttl, &t); err == nil {
+					channel.Schedule.TTL = parseTTL(ttl)
+				}
+			case depth == level && t.Name.Local == "skipHours":
+				var hours rssList
+				if err = decoder.DecodeElement(&hours, &t); err == nil {
+					for _, value := range hours.Values {
+						if hour, ok := parseSkipHour(value); ok {
+							channel.Schedule.SkipHours = append(channel.Schedule.SkipHours, hour)
+						}
+					}
+				}
+			case depth == level && t.Name.Local == "skipDays":
+				var days rssList
+				if err = decoder.DecodeElement(&days, &t); err == nil {
+					for _, value := range days.Values {
+						if day, ok := parseSkipDay(value); ok {
+							channel.Schedule.SkipDays = append(channel.Schedule.SkipDays, day)
+						}
+					}
+				}
+			case depth == level && t.Name.Local == "updateFrequency":
+				var freq rssUpdateFrequency
+				if err = decoder.DecodeElement(&freq, &t); err == nil {
+					channel.Schedule.UpdateEvery = parseRRule(freq.RRule)
+					channel.Schedule.Complete = strings.EqualFold(strings.TrimSpace(freq.Complete), "true")
+				}
+			case depth == level && t.Name.Local == "guid":
+				// Channels have no RSS guid, so this is podcast:guid.
+				var guid string
+				if err = decoder.DecodeElement(&guid, &t); err == nil {
+					channel.GUID = strings.ToLower(strings.TrimSpace(guid))
+				}
+			case depth == level && t.Name.Local == "language":
+				var language string
+				if err = decoder.DecodeElement(&language, &t); err == nil {
+					if tag := NormalizeLanguage(language); tag != "" {
+						channel.Language = tag
+					}
+				}
+			case depth == level && t.Name.Local == "image":
+				var image rssChannelImage
+				if err = decoder.DecodeElement(&image, &t); err == nil {
+					if href := strings.TrimSpace(image.Href); href != "" {
+						channel.ImageURL = href // itunes:image wins over the RSS image
+					} else if link := strings.TrimSpace(image.URL); link != "" && channel.ImageURL == "" {
+						channel.ImageURL = link
+					}
+				}
+			case level == 1 && depth == level && (t.Name.Local == "logo" || t.Name.Local == "icon"):
+				var link string
+				if err = decoder.DecodeElement(&link, &t); err == nil {
+					if link = strings.TrimSpace(link); link != "" && (t.Name.Local == "logo" || channel.ImageURL == "") {
+						channel.ImageURL = link
+					}
+				}
+			case depth == level && t.Name.Local == "funding":
+				var funding rssFunding
+				if err = decoder.DecodeElement(&funding, &t); err == nil {
+					if link, ok := funding.link(); ok {
+						channel.Funding = append(channel.Funding, link)
+					}
+				}
+			case depth == level && t.Name.Local == "value":
+				var value rssValue
+				if err = decoder.DecodeElement(&value, &t); err == nil {
+					if block, ok := value.block(); ok {
+						channel.Value = append(channel.Value, block)
+					}
+				}
+			case depth == level && t.Name.Local == "link" && t.Name.Space == atomNamespace:
+				var link rssAtomLink
+				if err = decoder.DecodeElement(&link, &t); err == nil {
+					href := strings.TrimSpace(link.Href)
+					switch rel := strings.ToLower(strings.TrimSpace(link.Rel)); {
+					case rel == "hub" && channel.Hub == "":
+						channel.Hub = href
+					case rel == "self" && channel.Self == "":
+						channel.Self = href
+					}
+				}
+			default:
+				err = decoder.Skip()
+			}
+			if err != nil {
+				return rssChannel{}, err
+			}
+		case xml.EndElement:
+			depth--
+		}
+	}
+	return channel, nil
+}
+
+func toEpisode(channelTitle string, item rssItem) Episode {
+	// An opaque GUID is the most stable ID. A GUID that is a permalink
+	// changes whenever the publisher restructures their site, so the
+	// enclosure URL is preferred over it, as over a bare link.
+	guid := strings.TrimSpace(item.GUID.Value)
+	link := strings.TrimSpace(item.Link)
+	enclosure := strings.TrimSpace(item.Enclosure.URL)
+	var linkID string
+	if guid != "" && (item.GUID.permaLink() || guid == link) {
+		linkID, guid = guid, ""
+	}
+	if guid == "" {
+		guid = enclosure
+	}
+	if guid == "" {
+		// Without an enclosure the permalink is all there is.
+		guid, linkID = linkID, ""
+	}
+	if guid == "" {
+		guid = link
+	}
+	if guid == "" {
+		guid = fmt.Sprintf("%s:%s", channelTitle, item.Title)
+	}
+
+	var published time.Time
+	for _, value := range item.pubDates() {
+		if t, err := parseTime(value); err == nil {
+			published = t
+			break
+		}
+	}
+
+	// Parse size from enclosure length attribute
+	var sizeBytes int64
+	if item.Enclosure.Length != "" {
+		// strconv.ParseInt handles the conversion
+		if size, err := parseSize(item.Enclosure.Length); err == nil {
+			sizeBytes = size
+		}
+	}
+
+	return Episode{
+		ID:          guid,
+		Title:       strings.TrimSpace(item.Title),
+		Description: strings.TrimSpace(item.Description),
+		PublishedAt: published,
+		Enclosure:   enclosure,
+		MimeType:    strings.TrimSpace(item.Enclosure.Type),
+		SizeBytes:   sizeBytes,
+		DurationSec: parseDuration(item.Duration),
+		Season:      parseNumber(item.Season),
+		Number:      parseNumber(item.Episode),
+		ImageURL:    strings.TrimSpace(item.Image.Href),
+		ChaptersURL: strings.TrimSpace(item.Chapters.URL),
+		Soundbites:  item.soundbites(),
+		LinkID:      linkID,
+	}
+}
+
+// parseTime reads a publication date. Besides RFC 1123 and RFC 3339 it
+// accepts the malformed variants common in feeds: a missing or misspelt
+// weekday, single-digit days, missing seconds, zones written as "GMT+0000"
+// or "EST", and ISO dates with a space instead of "T" or no zone at all.
+// Dates without a zone are taken as UTC.
 func parseTime(value string) (time.Time, error) {
 	value = strings.TrimSpace(value)
 	if value == "" {
 		return time.Time{}, fmt.Errorf("empty time")
 	}
-	layouts := []string{
-		time.RFC1123Z,
-		time.RFC1123,
-		time.RFC822Z,
-		time.RFC822,
-		time.RFC3339,
-	}
-	for _, layout := range layouts {
-		if t, err := time.Parse(layout, value); err == nil {
+	normalized := normalizeDate(value)
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, normalized); err == nil {
 			return t, nil
 		}
 	}
 	return time.Time{}, fmt.Errorf("unable to parse time: %s", value)
 }
 
+// dateLayouts are tried in order against a date rewritten by normalizeDate.
+var dateLayouts = []string{
+	"2 Jan 2006 15:04:05 -0700",
+	"2 Jan 2006 15:04 -0700",
+	"2 Jan 06 15:04:05 -0700",
+	"2 Jan 06 15:04 -0700",
+	"2 Jan 2006 15:04:05",
+	"2 Jan 2006",
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// zoneOffsets maps the zone names RFC 822 allows onto numeric offsets.
+var zoneOffsets = map[string]string{
+	"Z": "+0000", "UT": "+0000", "UTC": "+0000", "GMT": "+0000",
+	"EST": "-0500", "EDT": "-0400",
+	"CST": "-0600", "CDT": "-0500",
+	"MST": "-0700", "MDT": "-0600",
+	"PST": "-0800", "PDT": "-0700",
+}
+
+// normalizeDate drops a leading weekday, which feeds abbreviate and misspell
+// freely and which carries no information, and rewrites a trailing zone as a
+// numeric offset.
+func normalizeDate(value string) string {
+	fields := strings.Fields(value)
+	if len(fields) > 0 && isWeekday(fields[0]) {
+		fields = fields[1:]
+	} else if i := strings.IndexByte(value, ','); i > 0 && isWeekday(value[:i]) {
+		// "Mon,02 Jan 2006" has no space after the comma.
+		fields = strings.Fields(value[i+1:])
+	}
+	if n := len(fields); n > 1 {
+		fields[n-1] = normalizeZone(fields[n-1])
+	}
+	return strings.Join(fields, " ")
+}
+
+func isWeekday(field string) bool {
+	field = strings.ToLower(strings.TrimSuffix(field, ","))
+	if len(field) < 3 {
+		return false
+	}
+	for _, r := range field {
+		if r < 'a' || r > 'z' {
+			return false
+		}
+	}
+	switch field[:3] {
+	case "mon", "tue", "wed", "thu", "fri", "sat", "sun":
+		return true
+	}
+	return false
+}
+
+// normalizeZone turns "GMT", "GMT+0000", "UTC+01:00", "+01:00" and "+1"
+// into the "+0100" form.
+func normalizeZone(zone string) string {
+	upper := strings.ToUpper(zone)
+	if offset, ok := zoneOffsets[upper]; ok {
+		return offset
+	}
+	for _, prefix := range []string{"GMT", "UTC", "UT"} {
+		if strings.HasPrefix(upper, prefix) {
+			upper = upper[len(prefix):]
+			break
+		}
+	}
+	if upper == "" || (upper[0] != '+' && upper[0] != '-') {
+		return zone
+	}
+	sign, digits := upper[:1], strings.ReplaceAll(upper[1:], ":", "")
+	for _, r := range digits {
+		if r < '0' || r > '9' {
+			return zone
+		}
+	}
+	switch len(digits) {
+	case 1:
+		digits = "0" + digits + "00"
+	case 2:
+		digits += "00"
+	case 3:
+		digits = "0" + digits
+	case 4:
+	default:
+		return zone
+	}
+	return sign + digits
+}
+
 func parseSize(value string) (int64, error) {
 	value = strings.TrimSpace(value)
 	if value == "" {
@@ -129,15 +644,143 @@ func parseSize(value string) (int64, error) {
 	return size, nil
 }
 
-type rssDocument struct {
-	XMLName xml.Name   `xml:"rss"`
-	Channel rssChannel `xml:"channel"`
+// parseDuration reads an itunes:duration value given as seconds, MM:SS or
+// HH:MM:SS. Unparseable values yield 0.
+func parseDuration(value string) int {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	parts := strings.Split(value, ":")
+	if len(parts) > 3 {
+		return 0
+	}
+	total := 0
+	for _, part := range parts {
+		n, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || n < 0 {
+			return 0
+		}
+		total = total*60 + n
+	}
+	return total
+}
+
+// parseNumber reads a positive itunes:season or itunes:episode number.
+// Anything else yields 0.
+func parseNumber(value string) int {
+	n, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || n < 0 {
+		return 0
+	}
+	return n
 }
 
 type rssChannel struct {
-	Title       string    `xml:"title"`
-	Description string    `xml:"description"`
-	Items       []rssItem `xml:"item"`
+	Title       string
+	Description string
+	Schedule    Schedule
+	Hub         string
+	Self        string
+	GUID        string
+	Language    string
+	ImageURL    string
+	Funding     []Funding
+	Value       []ValueBlock
+}
+
+const xmlNamespace = "http://www.w3.org/XML/1998/namespace"
+
+// NormalizeLanguage lower-cases a language tag and uses hyphens between its
+// subtags, so "en_US" and "en-us" compare equal.
+func NormalizeLanguage(tag string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(tag)), "_", "-")
+}
+
+// rssFunding is podcast:funding; the text is the link label.
+type rssFunding struct {
+	URL   string `xml:"url,attr"`
+	Label string `xml:",chardata"`
+}
+
+// link returns the funding link if its URL is an absolute http(s) URL, the
+// only kind that can safely be handed to a browser.
+func (f rssFunding) link() (Funding, bool) {
+	raw := strings.TrimSpace(f.URL)
+	parsed, err := url.Parse(raw)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return Funding{}, false
+	}
+	return Funding{URL: raw, Label: strings.Join(strings.Fields(f.Label), " ")}, true
+}
+
+// rssValue is podcast:value with its podcast:valueRecipient children.
+type rssValue struct {
+	Type       string              `xml:"type,attr"`
+	Method     string              `xml:"method,attr"`
+	Suggested  string              `xml:"suggested,attr"`
+	Recipients []rssValueRecipient `xml:"valueRecipient"`
+}
+
+type rssValueRecipient struct {
+	Name    string `xml:"name,attr"`
+	Type    string `xml:"type,attr"`
+	Address string `xml:"address,attr"`
+	Split   string `xml:"split,attr"`
+	Fee     string `xml:"fee,attr"`
+}
+
+// block returns the value block without recipients that lack an address or
+// a valid split. A block left without recipients is dropped, as is a
+// suggested amount that is not a non-negative number.
+func (v rssValue) block() (ValueBlock, bool) {
+	block := ValueBlock{
+		Type:   strings.ToLower(strings.TrimSpace(v.Type)),
+		Method: strings.ToLower(strings.TrimSpace(v.Method)),
+	}
+	if suggested := strings.TrimSpace(v.Suggested); suggested != "" {
+		if amount, err := strconv.ParseFloat(suggested, 64); err == nil && amount >= 0 && !math.IsInf(amount, 0) {
+			block.Suggested = suggested
+		}
+	}
+	for _, r := range v.Recipients {
+		address := strings.TrimSpace(r.Address)
+		split, err := strconv.Atoi(strings.TrimSpace(r.Split))
+		if address == "" || err != nil || split < 0 {
+			continue
+		}
+		block.Recipients = append(block.Recipients, ValueRecipient{
+			Name:    strings.Join(strings.Fields(r.Name), " "),
+			Type:    strings.ToLower(strings.TrimSpace(r.Type)),
+			Address: address,
+			Split:   split,
+			Fee:     strings.EqualFold(strings.TrimSpace(r.Fee), "true"),
+		})
+	}
+	return block, len(block.Recipients) > 0
+}
+
+const atomNamespace = "http://www.w3.org/2005/Atom"
+
+// rssAtomLink is an <atom:link> in the channel, used for WebSub discovery,
+// or a <link> of an Atom feed or entry.
+type rssAtomLink struct {
+	Rel    string `xml:"rel,attr"`
+	Href   string `xml:"href,attr"`
+	Type   string `xml:"type,attr"`
+	Length string `xml:"length,attr"`
+}
+
+// rssUpdateFrequency is podcast:updateFrequency; the text is a human
+// readable description, the rrule attribute the machine readable period.
+type rssUpdateFrequency struct {
+	RRule    string `xml:"rrule,attr"`
+	Complete string `xml:"complete,attr"`
+}
+
+// rssList collects the <hour> or <day> children of <skipHours>/<skipDays>.
+type rssList struct {
+	Values []string `xml:",any"`
 }
 
 type rssItem struct {
@@ -145,12 +788,143 @@ type rssItem struct {
 	Title       string       `xml:"title"`
 	Description string       `xml:"description"`
 	Link        string       `xml:"link"`
-	PubDate     string       `xml:"pubDate"`
+	PubDates    []rssDate    `xml:"pubDate"` // Also matches itunes:pubDate and other namespaces
 	Enclosure   rssEnclosure `xml:"enclosure"`
+	itemExtensions
+}
+
+// itemExtensions are the iTunes and podcast namespace elements of an RSS
+// item or Atom entry.
+type itemExtensions struct {
+	Duration   string         `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd duration"`
+	Season     string         `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd season"`
+	Episode    string         `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd episode"`
+	Image      rssImage       `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd image"`
+	Chapters   rssChapters    `xml:"chapters"`  // podcast:chapters
+	Soundbites []rssSoundbite `xml:"soundbite"` // podcast:soundbite
+}
+
+// rssSoundbite is podcast:soundbite; times are seconds, the text its title.
+type rssSoundbite struct {
+	StartTime string `xml:"startTime,attr"`
+	Duration  string `xml:"duration,attr"`
+	Title     string `xml:",chardata"`
+}
+
+// soundbites returns the soundbites with a valid start time and a positive
+// duration.
+func (e itemExtensions) soundbites() []Soundbite {
+	var out []Soundbite
+	for _, bite := range e.Soundbites {
+		start, ok := parseSeconds(bite.StartTime)
+		if !ok {
+			continue
+		}
+		duration, ok := parseSeconds(bite.Duration)
+		if !ok || duration == 0 {
+			continue
+		}
+		out = append(out, Soundbite{StartSec: start, DurationSec: duration, Title: strings.Join(strings.Fields(bite.Title), " ")})
+	}
+	return out
+}
+
+// parseSeconds reads a non-negative, finite number of seconds such as "73.5".
+func parseSeconds(value string) (float64, bool) {
+	seconds, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || !(seconds >= 0) || math.IsInf(seconds, 1) {
+		return 0, false
+	}
+	return seconds, true
+}
+
+// rssImage is itunes:image, which carries its URL in href.
+type rssImage struct {
+	Href string `xml:"href,attr"`
+}
+
+// rssChannelImage is the channel's itunes:image, with its URL in href, or
+// the RSS <image> with a <url> child.
+type rssChannelImage struct {
+	Href string `xml:"href,attr"`
+	URL  string `xml:"url"`
+}
+
+// rssChapters is podcast:chapters, a link to a JSON chapters file.
+type rssChapters struct {
+	URL  string `xml:"url,attr"`
+	Type string `xml:"type,attr"`
+}
+
+// atomEntry is an <entry> of an Atom feed.
+type atomEntry struct {
+	ID        string        `xml:"id"`
+	Title     string        `xml:"title"`
+	Summary   string        `xml:"summary"`
+	Content   string        `xml:"content"`
+	Links     []rssAtomLink `xml:"link"`
+	Published string        `xml:"published"`
+	Updated   string        `xml:"updated"`
+	itemExtensions
+}
+
+// item maps the entry onto the RSS item fields: the id is an opaque GUID,
+// the first enclosure link the enclosure and the first alternate link the
+// link. The publication date falls back to the last update.
+func (e atomEntry) item() rssItem {
+	item := rssItem{
+		GUID:           rssGUID{Value: e.ID},
+		Title:          e.Title,
+		Description:    e.Summary,
+		PubDates:       []rssDate{{Value: e.Published}, {Value: e.Updated}},
+		itemExtensions: e.itemExtensions,
+	}
+	if strings.TrimSpace(item.Description) == "" {
+		item.Description = e.Content
+	}
+	for _, link := range e.Links {
+		switch rel := strings.ToLower(strings.TrimSpace(link.Rel)); {
+		case rel == "enclosure" && item.Enclosure.URL == "":
+			item.Enclosure = rssEnclosure{URL: link.Href, Length: link.Length, Type: link.Type}
+		case (rel == "" || rel == "alternate") && item.Link == "":
+			item.Link = link.Href
+		}
+	}
+	return item
+}
+
+type rssDate struct {
+	XMLName xml.Name
+	Value   string `xml:",chardata"`
+}
+
+// pubDates returns the item's non-empty publication dates with the plain
+// RSS pubDate first, so a namespaced variant only serves as a fallback.
+func (item rssItem) pubDates() []string {
+	var plain, other []string
+	for _, date := range item.PubDates {
+		value := strings.TrimSpace(date.Value)
+		switch {
+		case value == "":
+		case date.XMLName.Space == "":
+			plain = append(plain, value)
+		default:
+			other = append(other, value)
+		}
+	}
+	return append(plain, other...)
 }
 
 type rssGUID struct {
-	Value string `xml:",chardata"`
+	Value       string `xml:",chardata"`
+	IsPermaLink string `xml:"isPermaLink,attr"`
+}
+
+// permaLink reports whether the feed declares the GUID to be a URL. RSS
+// defaults the attribute to true, but most feeds that omit it use opaque IDs,
+// so only an explicit "true" counts.
+func (g rssGUID) permaLink() bool {
+	return strings.EqualFold(strings.TrimSpace(g.IsPermaLink), "true")
 }
 
 type rssEnclosure struct {
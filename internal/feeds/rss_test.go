@@ -0,0 +1,516 @@
+package feeds
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf16"
+)
+
+const sampleFeed = `<?xml version="1.0"?>
+<rss version="2.0" xmlns:itunes="http://www.itunes.com/dtds/podcast-1.0.dtd">
+  <channel>
+    <image><title>ignored</title></image>
+    <item>
+      <guid>ep-1</guid>
+      <title> Episode One </title>
+      <pubDate>Mon, 02 Jan 2006 15:04:05 -0700</pubDate>
+      <enclosure url="https://example.com/1.mp3" length="1234" type="audio/mpeg"/>
+      <itunes:duration>1:02:03</itunes:duration>
+    </item>
+    <item>
+      <title>Episode Two</title>
+    </item>
+    <title>Example Show</title>
+    <description>About things</description>
+  </channel>
+</rss>`
+
+func TestParseFeed(t *testing.T) {
+	podcast, episodes, err := parseFeed(strings.NewReader(sampleFeed))
+	if err != nil {
+		t.Fatalf("parseFeed() error = %v", err)
+	}
+	if podcast.Title != "Example Show" || podcast.Description != "About things" {
+		t.Fatalf("unexpected podcast: %+v", podcast)
+	}
+	if len(episodes) != 2 {
+		t.Fatalf("expected 2 episodes, got %d", len(episodes))
+	}
+	first := episodes[0]
+	if first.ID != "ep-1" || first.Title != "Episode One" || first.Enclosure != "https://example.com/1.mp3" ||
+		first.MimeType != "audio/mpeg" || first.SizeBytes != 1234 || first.DurationSec != 3723 || first.PublishedAt.IsZero() {
+		t.Fatalf("unexpected first episode: %+v", first)
+	}
+	// Without guid, enclosure or link the ID falls back to the channel title,
+	// even when the title follows the items.
+	if episodes[1].ID != "Example Show:Episode Two" {
+		t.Fatalf("unexpected fallback ID %q", episodes[1].ID)
+	}
+
+	if _, _, err := parseFeed(strings.NewReader("<html><body>Not found</body></html>")); err == nil {
+		t.Fatal("expected error for a non-RSS document")
+	}
+	if _, _, err := parseFeed(strings.NewReader(sampleFeed[:len(sampleFeed)/2])); err == nil {
+		t.Fatal("expected error for a truncated feed")
+	}
+}
+
+func TestFetchEnforcesMaxSize(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/streamed" {
+			// Flushing first forces chunked encoding, hiding the size.
+			w.(http.Flusher).Flush()
+		} else {
+			w.Header().Set("Content-Length", fmt.Sprint(len(sampleFeed)))
+		}
+		fmt.Fprint(w, sampleFeed)
+	}))
+	t.Cleanup(server.Close)
+	ctx := context.Background()
+	size := int64(len(sampleFeed))
+
+	for _, path := range []string{"/sized", "/streamed"} {
+		if _, _, err := Fetch(ctx, server.Client(), server.URL+path, size-1); !errors.Is(err, ErrFeedTooLarge) {
+			t.Fatalf("%s: expected ErrFeedTooLarge, got %v", path, err)
+		}
+		if _, episodes, err := Fetch(ctx, server.Client(), server.URL+path, size); err != nil || len(episodes) != 2 {
+			t.Fatalf("%s: expected feed of exactly the limit to parse, got %d episodes, err %v", path, len(episodes), err)
+		}
+		if _, _, err := Fetch(ctx, server.Client(), server.URL+path, 0); err != nil {
+			t.Fatalf("%s: expected no limit, got %v", path, err)
+		}
+	}
+}
+
+func TestParseFeedRecoversFromMalformedXML(t *testing.T) {
+	feed := "<?xml version=\"1.0\" encoding=\"utf-8\"?>\n" +
+		`<rss xmlns:itunes="http://www.itunes.com/dtds/podcast-1.0.dtd"><channel>` +
+		"<title>News &amp; Caf\xe9\x01</title>" +
+		`<item><guid>ok-1</guid><title>Tom&nbsp;&amp; Jerry & friends</title><itunes:duration>90</itunes:duration></item>` +
+		`<item><guid>broken</guid><enclosure url="a.mp3" <title>Broken</title></item>` +
+		`<item><guid>unclosed</guid><title>No end tag</title>` +
+		`<item><guid>ok-2</guid><title>Last</title></item>` +
+		`</channel></rss>`
+
+	podcast, episodes, err := parseFeed(strings.NewReader(feed))
+	if err != nil {
+		t.Fatalf("parseFeed() error = %v", err)
+	}
+	if podcast.Title != "News & Café" {
+		t.Fatalf("unexpected title %q", podcast.Title)
+	}
+	if podcast.SkippedItems != 1 {
+		t.Fatalf("expected 1 skipped item, got %d", podcast.SkippedItems)
+	}
+	var ids []string
+	for _, episode := range episodes {
+		ids = append(ids, episode.ID)
+	}
+	if strings.Join(ids, ",") != "ok-1,unclosed,ok-2" {
+		t.Fatalf("unexpected episodes %v", ids)
+	}
+	if episodes[0].Title != "Tom\u00a0& Jerry & friends" || episodes[0].DurationSec != 90 {
+		t.Fatalf("unexpected first episode: %+v", episodes[0])
+	}
+}
+
+func TestParseFeedConvertsCharsets(t *testing.T) {
+	latin1 := "<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?><rss><channel><title>Fran\xe7ais \x93quoted\x94</title></channel></rss>"
+	podcast, _, err := parseFeed(strings.NewReader(latin1))
+	if err != nil {
+		t.Fatalf("parseFeed(latin1) error = %v", err)
+	}
+	if podcast.Title != "Français “quoted”" {
+		t.Fatalf("unexpected latin1 title %q", podcast.Title)
+	}
+
+	text := `<?xml version="1.0" encoding="UTF-16"?><rss><channel><title>Grüße 🎙</title></channel></rss>`
+	encoded := []byte{0xFF, 0xFE}
+	for _, unit := range utf16.Encode([]rune(text)) {
+		encoded = append(encoded, byte(unit), byte(unit>>8))
+	}
+	podcast, _, err = parseFeed(bytes.NewReader(encoded))
+	if err != nil {
+		t.Fatalf("parseFeed(utf16) error = %v", err)
+	}
+	if podcast.Title != "Grüße 🎙" {
+		t.Fatalf("unexpected utf16 title %q", podcast.Title)
+	}
+}
+
+func TestParseTimeFormats(t *testing.T) {
+	want := time.Date(2006, time.January, 2, 15, 4, 5, 0, time.UTC)
+	for _, value := range []string{
+		"Mon, 02 Jan 2006 15:04:05 +0000",
+		"Mon, 02 Jan 2006 15:04:05 GMT",
+		"Mon, 02 Jan 2006 16:04:05 GMT+0100",
+		"Mon, 02 Jan 2006 16:04:05 UTC+01:00",
+		"Mon, 2 Jan 2006 15:04:05 +0000",
+		"Monday, 02 Jan 2006 07:04:05 PST",
+		"Tues, 02 Jan 2006 15:04:05 Z",
+		"Mon,02 Jan 2006 15:04:05 +0000",
+		"02 Jan 2006 15:04:05 +0000",
+		"2 Jan 06 10:04:05 -0500",
+		"Mon,  02 Jan 2006  15:04:05 +00:00",
+		"2006-01-02T15:04:05Z",
+		"2006-01-02T15:04:05.000+00:00",
+		"2006-01-02T15:04:05",
+		"2006-01-02 15:04:05",
+		"2006-01-02 17:04:05+02:00",
+		"2006-01-02 15:04:05 UTC",
+	} {
+		got, err := parseTime(value)
+		if err != nil {
+			t.Errorf("parseTime(%q) error = %v", value, err)
+			continue
+		}
+		if !got.Equal(want) {
+			t.Errorf("parseTime(%q) = %v, want %v", value, got, want)
+		}
+	}
+
+	if got, err := parseTime("Mon, 02 Jan 2006 15:04 +0000"); err != nil || !got.Equal(want.Add(-5*time.Second)) {
+		t.Errorf("date without seconds: got %v, %v", got, err)
+	}
+	if got, err := parseTime("2006-01-02"); err != nil || !got.Equal(want.Truncate(24*time.Hour)) {
+		t.Errorf("date only: got %v, %v", got, err)
+	}
+	for _, value := range []string{"", "yesterday", "Mon, 32 Jan 2006 15:04:05 +0000"} {
+		if _, err := parseTime(value); err == nil {
+			t.Errorf("parseTime(%q) expected error", value)
+		}
+	}
+}
+
+func TestParseFeedPrefersPlainPubDate(t *testing.T) {
+	feed := `<rss xmlns:itunes="http://www.itunes.com/dtds/podcast-1.0.dtd"><channel><title>Dates</title>
+<item><guid>a</guid><itunes:pubDate>Tue, 03 Jan 2006 00:00:00 GMT</itunes:pubDate><pubDate>Mon, 02 Jan 2006 00:00:00 GMT</pubDate></item>
+<item><guid>b</guid><pubDate>sometime</pubDate><itunes:pubDate>2006-01-04</itunes:pubDate></item>
+<item><guid>c</guid><pubDate>last week</pubDate></item>
+<item><guid>d</guid></item>
+</channel></rss>`
+	podcast, episodes, err := parseFeed(strings.NewReader(feed))
+	if err != nil {
+		t.Fatalf("parseFeed() error = %v", err)
+	}
+	dates := make([]string, len(episodes))
+	for i, ep := range episodes {
+		if !ep.PublishedAt.IsZero() {
+			dates[i] = ep.PublishedAt.Format("2006-01-02")
+		}
+	}
+	if want := []string{"2006-01-02", "2006-01-04", "", ""}; fmt.Sprint(dates) != fmt.Sprint(want) {
+		t.Fatalf("unexpected dates %q, want %q", dates, want)
+	}
+	if fmt.Sprint(podcast.BadDates) != "[last week]" {
+		t.Fatalf("unexpected bad dates %q", podcast.BadDates)
+	}
+}
+
+func TestParseFeedPermalinkGUIDs(t *testing.T) {
+	feed := `<rss><channel><title>GUIDs</title>
+<item><guid isPermaLink="false">opaque-1</guid><link>https://example.com/1</link><enclosure url="https://cdn.example.com/1.mp3"/></item>
+<item><guid isPermaLink="true">https://example.com/2</guid><enclosure url="https://cdn.example.com/2.mp3"/></item>
+<item><guid>https://example.com/3</guid><link>https://example.com/3</link><enclosure url="https://cdn.example.com/3.mp3"/></item>
+<item><guid isPermaLink="TRUE">https://example.com/4</guid></item>
+<item><link>https://example.com/5</link><enclosure url="https://cdn.example.com/5.mp3"/></item>
+</channel></rss>`
+	_, episodes, err := parseFeed(strings.NewReader(feed))
+	if err != nil {
+		t.Fatalf("parseFeed() error = %v", err)
+	}
+	want := []struct{ id, linkID string }{
+		{"opaque-1", ""},
+		{"https://cdn.example.com/2.mp3", "https://example.com/2"},
+		{"https://cdn.example.com/3.mp3", "https://example.com/3"},
+		{"https://example.com/4", ""},
+		{"https://cdn.example.com/5.mp3", ""},
+	}
+	if len(episodes) != len(want) {
+		t.Fatalf("expected %d episodes, got %d", len(want), len(episodes))
+	}
+	for i, w := range want {
+		if episodes[i].ID != w.id || episodes[i].LinkID != w.linkID {
+			t.Errorf("episode %d: got ID %q LinkID %q, want %q %q", i, episodes[i].ID, episodes[i].LinkID, w.id, w.linkID)
+		}
+	}
+}
+
+func TestParseFeedScheduleHints(t *testing.T) {
+	feed := `<rss xmlns:podcast="https://podcastindex.org/namespace/1.0"><channel><title>Hints</title>
+<ttl> 120 </ttl>
+<skipHours><hour>0</hour><hour>24</hour><hour>3</hour><hour>x</hour></skipHours>
+<skipDays><day>Saturday</day><day>sun</day><day>Caturday</day></skipDays>
+<podcast:updateFrequency rrule="FREQ=WEEKLY;BYDAY=MO,TH">Twice a week</podcast:updateFrequency>
+<item><guid>a</guid></item>
+</channel></rss>`
+	podcast, _, err := parseFeed(strings.NewReader(feed))
+	if err != nil {
+		t.Fatalf("parseFeed() error = %v", err)
+	}
+	got := podcast.Schedule
+	if got.TTL != 2*time.Hour || fmt.Sprint(got.SkipHours) != "[0 0 3]" || fmt.Sprint(got.SkipDays) != "[Saturday Sunday]" ||
+		got.UpdateEvery != 84*time.Hour || got.Complete {
+		t.Fatalf("unexpected schedule %+v", got)
+	}
+}
+
+func TestParseFeedWebSubLinks(t *testing.T) {
+	feed := `<rss xmlns:atom="http://www.w3.org/2005/Atom"><channel><title>Hub</title>
+<link>https://example.com/</link>
+<atom:link rel="self" type="application/rss+xml" href=" https://example.com/feed.xml "/>
+<atom:link rel="hub" href="https://pubsubhubbub.appspot.com/"/>
+<atom:link rel="hub" href="https://second.example.com/hub"/>
+<item><guid>a</guid></item>
+</channel></rss>`
+	podcast, _, err := parseFeed(strings.NewReader(feed))
+	if err != nil {
+		t.Fatalf("parseFeed() error = %v", err)
+	}
+	if podcast.Hub != "https://pubsubhubbub.appspot.com/" || podcast.Self != "https://example.com/feed.xml" {
+		t.Fatalf("unexpected hub %q / self %q", podcast.Hub, podcast.Self)
+	}
+}
+
+func TestParseFeedPodcastGUID(t *testing.T) {
+	feed := `<rss xmlns:podcast="https://podcastindex.org/namespace/1.0"><channel><title>GUID</title>
+<podcast:guid> 917393E3-1B1E-5CEF-ACE4-EDAA54E1F810 </podcast:guid>
+<item><guid>item-guid</guid><enclosure url="https://example.com/a.mp3"/></item>
+</channel></rss>`
+	podcast, episodes, err := parseFeed(strings.NewReader(feed))
+	if err != nil {
+		t.Fatalf("parseFeed() error = %v", err)
+	}
+	if podcast.GUID != "917393e3-1b1e-5cef-ace4-edaa54e1f810" {
+		t.Fatalf("unexpected podcast guid %q", podcast.GUID)
+	}
+	if len(episodes) != 1 || episodes[0].ID != "item-guid" {
+		t.Fatalf("item guid should be unaffected: %+v", episodes)
+	}
+}
+
+func TestParseFeedLanguage(t *testing.T) {
+	cases := []struct {
+		name, feed, want string
+	}{
+		{"rss", `<rss><channel><title>T</title><language> en_US </language><item><guid>a</guid></item></channel></rss>`, "en-us"},
+		{"atom", `<feed xmlns="http://www.w3.org/2005/Atom" xml:lang="de"><title>T</title><entry><id>a</id></entry></feed>`, "de"},
+		{"rss over xml:lang", `<rss xml:lang="fr"><channel><title>T</title><language>de-AT</language></channel></rss>`, "de-at"},
+		{"absent", `<rss><channel><title>T</title></channel></rss>`, ""},
+	}
+	for _, tc := range cases {
+		podcast, _, err := parseFeed(strings.NewReader(tc.feed))
+		if err != nil {
+			t.Fatalf("%s: parseFeed() error = %v", tc.name, err)
+		}
+		if podcast.Language != tc.want {
+			t.Fatalf("%s: Language = %q, want %q", tc.name, podcast.Language, tc.want)
+		}
+	}
+}
+
+func TestParseFeedImage(t *testing.T) {
+	cases := []struct {
+		name, feed, want string
+	}{
+		{"itunes", `<rss xmlns:itunes="http://www.itunes.com/dtds/podcast-1.0.dtd"><channel><title>T</title>
+<itunes:image href=" https://example.com/itunes.jpg "/><image><url>https://example.com/rss.png</url></image></channel></rss>`, "https://example.com/itunes.jpg"},
+		{"rss", `<rss><channel><title>T</title><image><url>https://example.com/rss.png</url><title>T</title></image>
+<item><title>E</title><itunes:image xmlns:itunes="http://www.itunes.com/dtds/podcast-1.0.dtd" href="https://example.com/ep.jpg"/></item></channel></rss>`, "https://example.com/rss.png"},
+		{"atom", `<feed xmlns="http://www.w3.org/2005/Atom"><title>T</title><icon>https://example.com/icon.png</icon><logo>https://example.com/logo.png</logo></feed>`, "https://example.com/logo.png"},
+		{"absent", `<rss><channel><title>T</title></channel></rss>`, ""},
+	}
+	for _, tc := range cases {
+		podcast, _, err := parseFeed(strings.NewReader(tc.feed))
+		if err != nil {
+			t.Fatalf("%s: parseFeed() error = %v", tc.name, err)
+		}
+		if podcast.ImageURL != tc.want {
+			t.Fatalf("%s: ImageURL = %q, want %q", tc.name, podcast.ImageURL, tc.want)
+		}
+	}
+}
+
+func TestParseFeedFunding(t *testing.T) {
+	feed := `<rss xmlns:podcast="https://podcastindex.org/namespace/1.0"><channel><title>Funding</title>
+<podcast:funding url="https://www.example.com/donations">Support
+  the show!</podcast:funding>
+<podcast:funding url="https://www.patreon.com/example"/>
+<podcast:funding url="javascript:alert(1)">Bad</podcast:funding>
+<podcast:funding url="/relative">Relative</podcast:funding>
+<item><guid>a</guid></item>
+</channel></rss>`
+	podcast, _, err := parseFeed(strings.NewReader(feed))
+	if err != nil {
+		t.Fatalf("parseFeed() error = %v", err)
+	}
+	want := []Funding{
+		{URL: "https://www.example.com/donations", Label: "Support the show!"},
+		{URL: "https://www.patreon.com/example"},
+	}
+	if fmt.Sprint(podcast.Funding) != fmt.Sprint(want) {
+		t.Fatalf("unexpected funding %+v", podcast.Funding)
+	}
+}
+
+func TestParseFeedValue(t *testing.T) {
+	feed := `<rss xmlns:podcast="https://podcastindex.org/namespace/1.0"><channel><title>Value</title>
+<podcast:value type="Lightning" method="keysend" suggested="0.00000005000">
+  <podcast:valueRecipient name="Host  Name" type="node" address="02d5c1bf8b940dc9" split="90"/>
+  <podcast:valueRecipient name="Producer" type="node" address="032f4ffbbafffbe5" split="10"/>
+  <podcast:valueRecipient name="App" type="node" address="03ae9f91a0cb8ff4" split="1" fee="true"/>
+  <podcast:valueRecipient name="No address" type="node" split="5"/>
+  <podcast:valueRecipient name="Bad split" type="node" address="abc" split="lots"/>
+</podcast:value>
+<podcast:value type="lightning" suggested="NaN">
+  <podcast:valueRecipient type="node" address="02d5c1bf8b940dc9" split="100"/>
+</podcast:value>
+<podcast:value type="lightning"><podcast:valueRecipient name="Nobody" split="1"/></podcast:value>
+<item><guid>a</guid></item>
+</channel></rss>`
+	podcast, _, err := parseFeed(strings.NewReader(feed))
+	if err != nil {
+		t.Fatalf("parseFeed() error = %v", err)
+	}
+	want := []ValueBlock{
+		{Type: "lightning", Method: "keysend", Suggested: "0.00000005000", Recipients: []ValueRecipient{
+			{Name: "Host Name", Type: "node", Address: "02d5c1bf8b940dc9", Split: 90},
+			{Name: "Producer", Type: "node", Address: "032f4ffbbafffbe5", Split: 10},
+			{Name: "App", Type: "node", Address: "03ae9f91a0cb8ff4", Split: 1, Fee: true},
+		}},
+		{Type: "lightning", Recipients: []ValueRecipient{
+			{Type: "node", Address: "02d5c1bf8b940dc9", Split: 100},
+		}},
+	}
+	if fmt.Sprint(podcast.Value) != fmt.Sprint(want) {
+		t.Fatalf("unexpected value blocks %+v", podcast.Value)
+	}
+}
+
+func TestParseFeedItemExtensions(t *testing.T) {
+	feed := `<rss xmlns:itunes="http://www.itunes.com/dtds/podcast-1.0.dtd" xmlns:podcast="https://podcastindex.org/namespace/1.0"><channel><title>Ext</title>
+<item><guid>a</guid>
+  <itunes:season>2</itunes:season>
+  <itunes:episode> 14 </itunes:episode>
+  <itunes:image href="https://example.com/ep14.jpg"/>
+  <podcast:chapters url="https://example.com/ep14.json" type="application/json+chapters"/>
+  <podcast:soundbite startTime="73.5" duration="60.0">The   best bit</podcast:soundbite>
+  <podcast:soundbite startTime="1234" duration="42"/>
+  <podcast:soundbite startTime="-5" duration="10">Negative</podcast:soundbite>
+  <podcast:soundbite startTime="NaN" duration="10">Not a number</podcast:soundbite>
+  <podcast:soundbite startTime="10" duration="0">Empty</podcast:soundbite>
+</item>
+<item><guid>b</guid><itunes:episode>bonus</itunes:episode></item>
+</channel></rss>`
+	_, episodes, err := parseFeed(strings.NewReader(feed))
+	if err != nil {
+		t.Fatalf("parseFeed() error = %v", err)
+	}
+	if len(episodes) != 2 {
+		t.Fatalf("expected 2 episodes, got %d", len(episodes))
+	}
+	first := episodes[0]
+	if first.Season != 2 || first.Number != 14 || first.ImageURL != "https://example.com/ep14.jpg" || first.ChaptersURL != "https://example.com/ep14.json" {
+		t.Fatalf("unexpected extensions %+v", first)
+	}
+	wantBites := []Soundbite{{StartSec: 73.5, DurationSec: 60, Title: "The best bit"}, {StartSec: 1234, DurationSec: 42}}
+	if fmt.Sprint(first.Soundbites) != fmt.Sprint(wantBites) {
+		t.Fatalf("unexpected soundbites %+v", first.Soundbites)
+	}
+	if episodes[1].Number != 0 {
+		t.Fatalf("a non-numeric episode number should be ignored, got %d", episodes[1].Number)
+	}
+}
+
+func TestParseAtomFeed(t *testing.T) {
+	feed := `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom" xmlns:itunes="http://www.itunes.com/dtds/podcast-1.0.dtd">
+  <title>Atom Show</title>
+  <subtitle>Episodes in Atom</subtitle>
+  <link rel="self" href="https://example.com/atom.xml"/>
+  <link rel="hub" href="https://hub.example.com/"/>
+  <id>urn:uuid:60a76c80-d399-11d9-b93C-0003939e0af6</id>
+  <entry>
+    <id>tag:example.com,2024:ep-1</id>
+    <title>First</title>
+    <link href="https://example.com/ep-1"/>
+    <link rel="enclosure" type="audio/mpeg" length="2048" href="https://example.com/ep-1.mp3"/>
+    <published>2024-03-01T10:00:00Z</published>
+    <updated>2024-03-02T10:00:00Z</updated>
+    <summary>The first one</summary>
+    <itunes:duration>30:00</itunes:duration>
+    <itunes:episode>1</itunes:episode>
+  </entry>
+  <entry>
+    <id>tag:example.com,2024:ep-2</id>
+    <title>Second</title>
+    <updated>2024-03-08T10:00:00Z</updated>
+    <content type="html">&lt;p&gt;Only content&lt;/p&gt;</content>
+  </entry>
+</feed>`
+	podcast, episodes, err := parseFeed(strings.NewReader(feed))
+	if err != nil {
+		t.Fatalf("parseFeed() error = %v", err)
+	}
+	if podcast.Title != "Atom Show" || podcast.Description != "Episodes in Atom" ||
+		podcast.Self != "https://example.com/atom.xml" || podcast.Hub != "https://hub.example.com/" {
+		t.Fatalf("unexpected podcast %+v", podcast)
+	}
+	if len(episodes) != 2 {
+		t.Fatalf("expected 2 episodes, got %d", len(episodes))
+	}
+	first := episodes[0]
+	if first.ID != "tag:example.com,2024:ep-1" || first.Title != "First" || first.Enclosure != "https://example.com/ep-1.mp3" ||
+		first.MimeType != "audio/mpeg" || first.SizeBytes != 2048 || first.DurationSec != 1800 || first.Number != 1 ||
+		first.Description != "The first one" || !first.PublishedAt.Equal(time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected first entry %+v", first)
+	}
+	second := episodes[1]
+	if second.Description != "<p>Only content</p>" || !second.PublishedAt.Equal(time.Date(2024, 3, 8, 10, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected second entry %+v", second)
+	}
+}
+
+func TestScheduleNext(t *testing.T) {
+	from := time.Date(2024, 3, 1, 10, 30, 0, 0, time.UTC) // A Friday
+	hour := time.Hour
+	cases := []struct {
+		name     string
+		schedule Schedule
+		interval time.Duration
+		want     time.Time
+	}{
+		{"no hints", Schedule{}, hour, from.Add(hour)},
+		{"ttl shorter than interval", Schedule{TTL: 30 * time.Minute}, hour, from.Add(hour)},
+		{"ttl longer than interval", Schedule{TTL: 3 * hour}, hour, from.Add(3 * hour)},
+		{"ttl capped at a day", Schedule{TTL: 72 * hour}, hour, from.Add(24 * hour)},
+		{"weekly feed checked four times a week", Schedule{UpdateEvery: 7 * 24 * hour}, hour, from.Add(24 * hour)},
+		{"daily feed", Schedule{UpdateEvery: 24 * hour}, hour, from.Add(6 * hour)},
+		{"complete feed", Schedule{Complete: true}, hour, from.Add(7 * 24 * hour)},
+		{"skip hours", Schedule{SkipHours: []int{11, 12}}, hour, time.Date(2024, 3, 1, 13, 0, 0, 0, time.UTC)},
+		{"skip days", Schedule{SkipDays: []time.Weekday{time.Saturday, time.Sunday}}, 24 * hour, time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)},
+		{"everything skipped", Schedule{SkipDays: []time.Weekday{0, 1, 2, 3, 4, 5, 6}}, hour, from.Add(hour)},
+	}
+	for _, tc := range cases {
+		if got := tc.schedule.Next(from, tc.interval); !got.Equal(tc.want) {
+			t.Errorf("%s: Next() = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+
+	if got := parseRRule("FREQ=DAILY;INTERVAL=2"); got != 48*time.Hour {
+		t.Errorf("parseRRule(daily/2) = %v", got)
+	}
+	if got := parseRRule("FREQ=MONTHLY;BYDAY=1MO"); got != 30*24*time.Hour {
+		t.Errorf("parseRRule(monthly) = %v", got)
+	}
+	if got := parseRRule("whenever"); got != 0 {
+		t.Errorf("parseRRule(invalid) = %v", got)
+	}
+}
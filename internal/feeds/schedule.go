@@ -0,0 +1,152 @@
+package feeds
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// maxHintInterval bounds how far ttl and podcast:updateFrequency may push
+	// the next refresh, so a feed is still checked at least daily.
+	maxHintInterval = 24 * time.Hour
+	// completeInterval is used for feeds that declare they are finished.
+	completeInterval = 7 * 24 * time.Hour
+)
+
+// Schedule holds the refresh hints a feed publishes: RSS <ttl>, <skipHours>
+// and <skipDays>, and podcast:updateFrequency.
+type Schedule struct {
+	TTL         time.Duration
+	SkipHours   []int // Hours of the day, in UTC, in which the feed should not be fetched
+	SkipDays    []time.Weekday
+	UpdateEvery time.Duration // Publishing period from podcast:updateFrequency, 0 when unknown
+	Complete    bool          // No new episodes are expected
+}
+
+// Next returns when a feed fetched at from should be fetched again, given
+// the configured interval. Hints only ever lengthen the interval: ttl is
+// honoured as a minimum, a feed published every period is checked four times
+// per period, and neither pushes the next check more than a day out. A
+// complete feed is checked weekly. The result is moved past skipped hours
+// and days.
+func (s Schedule) Next(from time.Time, interval time.Duration) time.Time {
+	hint := s.TTL
+	if quarter := s.UpdateEvery / 4; quarter > hint {
+		hint = quarter
+	}
+	if hint > maxHintInterval {
+		hint = maxHintInterval
+	}
+	if s.Complete {
+		hint = completeInterval
+	}
+	if hint > interval {
+		interval = hint
+	}
+	return s.skip(from.Add(interval))
+}
+
+// skip moves t to the start of the first hour that is neither a skipped hour
+// nor on a skipped day. When every hour is skipped the hints are ignored.
+func (s Schedule) skip(t time.Time) time.Time {
+	if len(s.SkipHours) == 0 && len(s.SkipDays) == 0 {
+		return t
+	}
+	next := t.UTC()
+	for i := 0; i < 7*24; i++ {
+		if !s.skipped(next) {
+			if i == 0 {
+				return t
+			}
+			return next
+		}
+		next = next.Truncate(time.Hour).Add(time.Hour)
+	}
+	return t
+}
+
+func (s Schedule) skipped(t time.Time) bool {
+	for _, hour := range s.SkipHours {
+		if t.Hour() == hour {
+			return true
+		}
+	}
+	for _, day := range s.SkipDays {
+		if t.Weekday() == day {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTTL reads <ttl>, given in minutes.
+func parseTTL(value string) time.Duration {
+	minutes, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || minutes <= 0 {
+		return 0
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// parseSkipHour reads an <hour> of <skipHours>. RSS numbers them 0-23, but
+// some feeds write 24 for midnight.
+func parseSkipHour(value string) (int, bool) {
+	hour, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || hour < 0 || hour > 24 {
+		return 0, false
+	}
+	return hour % 24, true
+}
+
+// parseSkipDay reads a <day> of <skipDays>, such as "Monday".
+func parseSkipDay(value string) (time.Weekday, bool) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if len(value) < 3 {
+		return 0, false
+	}
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if strings.HasPrefix(strings.ToLower(day.String()), value[:3]) {
+			return day, true
+		}
+	}
+	return 0, false
+}
+
+// parseRRule estimates the publishing period of a podcast:updateFrequency
+// rrule such as "FREQ=WEEKLY;INTERVAL=2" or "FREQ=WEEKLY;BYDAY=MO,TH". It
+// returns 0 for rules it does not understand.
+func parseRRule(rule string) time.Duration {
+	var period time.Duration
+	weekly := false
+	interval, perPeriod := 1, 1
+	for _, part := range strings.Split(rule, ";") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			weekly = strings.EqualFold(value, "WEEKLY")
+			switch strings.ToUpper(value) {
+			case "HOURLY":
+				period = time.Hour
+			case "DAILY":
+				period = 24 * time.Hour
+			case "WEEKLY":
+				period = 7 * 24 * time.Hour
+			case "MONTHLY":
+				period = 30 * 24 * time.Hour
+			case "YEARLY":
+				period = 365 * 24 * time.Hour
+			}
+		case "INTERVAL":
+			if n, err := strconv.Atoi(value); err == nil && n > 0 {
+				interval = n
+			}
+		case "BYDAY":
+			perPeriod = len(strings.Split(value, ","))
+		}
+	}
+	if !weekly {
+		perPeriod = 1
+	}
+	return period * time.Duration(interval) / time.Duration(perPeriod)
+}
@@ -0,0 +1,64 @@
+package feeds
+
+import (
+	"net/url"
+	"strings"
+)
+
+// trackingPrefixes are analytics redirectors that prepend their own host and
+// path to the real enclosure URL, e.g.
+// https://dts.podtrac.com/redirect.mp3/cdn.example.com/ep.mp3.
+var trackingPrefixes = []struct {
+	host   string
+	prefix string // Leading path of the tracker
+	skip   int    // Path segments after prefix to drop, such as a tracking ID
+}{
+	{host: "dts.podtrac.com", prefix: "/redirect.", skip: 1}, // redirect.mp3/, redirect.m4a/, ...
+	{host: "www.podtrac.com", prefix: "/pts/redirect.", skip: 1},
+	{host: "podtrac.com", prefix: "/pts/redirect.", skip: 1},
+	{host: "chrt.fm", prefix: "/track/", skip: 1},
+	{host: "chtbl.com", prefix: "/track/", skip: 1},
+	{host: "pdst.fm", prefix: "/e/"},
+}
+
+// StripTrackingPrefixes removes known tracking redirect prefixes from an
+// enclosure URL, including chains of several trackers. The scheme of the
+// outermost URL is kept. URLs without a known prefix are returned unchanged.
+func StripTrackingPrefixes(rawURL string) string {
+	for {
+		stripped, ok := stripTrackingPrefix(rawURL)
+		if !ok {
+			return rawURL
+		}
+		rawURL = stripped
+	}
+}
+
+func stripTrackingPrefix(rawURL string) (string, bool) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || u.Host == "" {
+		return rawURL, false
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, tracker := range trackingPrefixes {
+		path := u.EscapedPath()
+		if host != tracker.host || !strings.HasPrefix(path, tracker.prefix) {
+			continue
+		}
+		rest := strings.TrimPrefix(path, tracker.prefix)
+		for i := 0; i < tracker.skip; i++ {
+			_, rest, _ = strings.Cut(rest, "/")
+		}
+		// Some publishers repeat the scheme of the wrapped URL.
+		rest = strings.TrimPrefix(strings.TrimPrefix(rest, "https://"), "http://")
+		if rest == "" || !strings.Contains(strings.SplitN(rest, "/", 2)[0], ".") {
+			return rawURL, false
+		}
+		stripped := u.Scheme + "://" + rest
+		if u.RawQuery != "" {
+			stripped += "?" + u.RawQuery
+		}
+		return stripped, true
+	}
+	return rawURL, false
+}
@@ -0,0 +1,22 @@
+package feeds
+
+import "testing"
+
+func TestStripTrackingPrefixes(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"https://dts.podtrac.com/redirect.mp3/cdn.example.com/ep.mp3", "https://cdn.example.com/ep.mp3"},
+		{"https://www.podtrac.com/pts/redirect.m4a/cdn.example.com/a/ep.m4a?x=1", "https://cdn.example.com/a/ep.m4a?x=1"},
+		{"https://chrt.fm/track/ABC12/traffic.megaphone.fm/XYZ.mp3", "https://traffic.megaphone.fm/XYZ.mp3"},
+		{"https://pdst.fm/e/chtbl.com/track/9/dts.podtrac.com/redirect.mp3/https://cdn.example.com/ep.mp3", "https://cdn.example.com/ep.mp3"},
+		{"https://pdst.fm/e/", "https://pdst.fm/e/"},
+		{"https://example.com/ep.mp3", "https://example.com/ep.mp3"},
+	}
+	for _, tt := range tests {
+		if got := StripTrackingPrefixes(tt.input); got != tt.want {
+			t.Errorf("StripTrackingPrefixes(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package gpodder
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TimestampLayout is the format gpodder.net uses for episode action times.
+const TimestampLayout = "2006-01-02T15:04:05"
+
+// Episode action names podsink exchanges with gpodder.net.
+const (
+	ActionDownload = "download"
+	ActionPlay     = "play"
+)
+
+// DefaultDevice is the device id used when none is configured.
+const DefaultDevice = "podsink"
+
+// Client talks to a gpodder.net-compatible server using the v2 API.
+type Client struct {
+	httpClient *http.Client
+	baseURL    string
+	username   string
+	password   string
+	device     string
+}
+
+// NewClient creates a client for the server at baseURL that authenticates as
+// username and records changes against device, or DefaultDevice when empty.
+func NewClient(httpClient *http.Client, baseURL, username, password, device string) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	if strings.TrimSpace(device) == "" {
+		device = DefaultDevice
+	}
+	return &Client{
+		httpClient: httpClient,
+		baseURL:    strings.TrimRight(strings.TrimSpace(baseURL), "/"),
+		username:   username,
+		password:   password,
+		device:     device,
+	}
+}
+
+// Device returns the device id changes are recorded against.
+func (c *Client) Device() string {
+	return c.device
+}
+
+// SubscriptionChanges lists feeds added to or removed from a device since a
+// previous sync.
+type SubscriptionChanges struct {
+	Add       []string `json:"add"`
+	Remove    []string `json:"remove"`
+	Timestamp int64    `json:"timestamp"`
+}
+
+// EpisodeAction records something that happened to an episode on a device.
+type EpisodeAction struct {
+	Podcast   string `json:"podcast"`
+	Episode   string `json:"episode"`
+	Device    string `json:"device,omitempty"`
+	Action    string `json:"action"`
+	Timestamp string `json:"timestamp,omitempty"`
+	Started   int    `json:"started,omitempty"`
+	Position  int    `json:"position,omitempty"`
+	Total     int    `json:"total,omitempty"`
+}
+
+type uploadResponse struct {
+	Timestamp int64 `json:"timestamp"`
+}
+
+type actionsResponse struct {
+	Actions   []EpisodeAction `json:"actions"`
+	Timestamp int64           `json:"timestamp"`
+}
+
+// PullSubscriptions returns the device's subscription changes since the given
+// server timestamp. A zero since returns every subscription as added.
+func (c *Client) PullSubscriptions(ctx context.Context, since int64) (SubscriptionChanges, error) {
+	var changes SubscriptionChanges
+	query := url.Values{"since": {strconv.FormatInt(since, 10)}}
+	err := c.do(ctx, http.MethodGet, c.subscriptionsPath(), query, nil, &changes)
+	return changes, err
+}
+
+// PushSubscriptions uploads local subscription changes and returns the server
+// timestamp to use for the next pull.
+func (c *Client) PushSubscriptions(ctx context.Context, add, remove []string) (int64, error) {
+	body := SubscriptionChanges{Add: nonNil(add), Remove: nonNil(remove)}
+	var resp uploadResponse
+	if err := c.do(ctx, http.MethodPost, c.subscriptionsPath(), nil, body, &resp); err != nil {
+		return 0, err
+	}
+	return resp.Timestamp, nil
+}
+
+// PullEpisodeActions returns episode actions from all devices since the given
+// server timestamp, along with the timestamp to use next time.
+func (c *Client) PullEpisodeActions(ctx context.Context, since int64) ([]EpisodeAction, int64, error) {
+	var resp actionsResponse
+	query := url.Values{"since": {strconv.FormatInt(since, 10)}}
+	if err := c.do(ctx, http.MethodGet, c.episodesPath(), query, nil, &resp); err != nil {
+		return nil, 0, err
+	}
+	return resp.Actions, resp.Timestamp, nil
+}
+
+// PushEpisodeActions uploads episode actions, filling in this client's device
+// where an action has none.
+func (c *Client) PushEpisodeActions(ctx context.Context, actions []EpisodeAction) (int64, error) {
+	body := make([]EpisodeAction, len(actions))
+	for i, action := range actions {
+		if action.Device == "" {
+			action.Device = c.device
+		}
+		body[i] = action
+	}
+	var resp uploadResponse
+	if err := c.do(ctx, http.MethodPost, c.episodesPath(), nil, body, &resp); err != nil {
+		return 0, err
+	}
+	return resp.Timestamp, nil
+}
+
+// FormatTimestamp renders t in the layout gpodder.net expects for actions.
+func FormatTimestamp(t time.Time) string {
+	return t.UTC().Format(TimestampLayout)
+}
+
+func (c *Client) subscriptionsPath() string {
+	return fmt.Sprintf("/api/2/subscriptions/%s/%s.json", url.PathEscape(c.username), url.PathEscape(c.device))
+}
+
+func (c *Client) episodesPath() string {
+	return fmt.Sprintf("/api/2/episodes/%s.json", url.PathEscape(c.username))
+}
+
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out interface{}) error {
+	if c.baseURL == "" {
+		return fmt.Errorf("gpodder server is not configured")
+	}
+	endpoint := c.baseURL + path
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reader)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(c.username, c.password)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("gpodder %s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized:
+		return fmt.Errorf("gpodder login failed for %s", c.username)
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("gpodder %s %s failed: %s", method, path, resp.Status)
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode gpodder response: %w", err)
+	}
+	return nil
+}
+
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
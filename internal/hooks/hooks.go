@@ -0,0 +1,252 @@
+// Package hooks runs the shell commands and webhooks configured under hooks
+// when a podcast is subscribed, a refresh adds an episode, or a download
+// starts, completes or fails. Its Command runs every other user-configured
+// command too, so notify_command, post_process and ingest_filters commands
+// get the same shell, PODSINK_* variables and timeout.
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"podsink/internal/config"
+	"podsink/internal/domain"
+)
+
+// Timeout bounds how long a command run with Command may take.
+const Timeout = 60 * time.Second
+
+// Payload describes an event to a hook: as the JSON body of a webhook, on a
+// command's standard input and, in part, in PODSINK_* environment variables.
+// Other commands get the fields that apply to them.
+type Payload struct {
+	Event        string    `json:"event"`
+	Time         time.Time `json:"time"`
+	PodcastID    string    `json:"podcast_id,omitempty"`
+	PodcastTitle string    `json:"podcast_title,omitempty"`
+	EpisodeID    string    `json:"episode_id,omitempty"`
+	Title        string    `json:"title,omitempty"`
+	FilePath     string    `json:"file_path,omitempty"`
+	Error        string    `json:"error,omitempty"`
+	Count        int       `json:"count,omitempty"` // Episodes a notification is about
+}
+
+// Runner runs hooks for events in the order they happen. Events are queued
+// so slow hooks do not make the event bus drop any.
+type Runner struct {
+	hooks     []config.Hook
+	client    *http.Client
+	userAgent string
+	// runCommand executes a hook command; tests replace it.
+	runCommand func(ctx context.Context, command string, payload []byte, env []string) ([]byte, error)
+
+	mu      sync.Mutex
+	wake    *sync.Cond
+	pending []Payload
+	closed  bool
+	wg      sync.WaitGroup
+}
+
+// New returns a runner for hooks posting webhooks with client.
+func New(hooks []config.Hook, client *http.Client, userAgent string) *Runner {
+	r := &Runner{hooks: hooks, client: client, userAgent: userAgent, runCommand: Command}
+	r.wake = sync.NewCond(&r.mu)
+	return r
+}
+
+// Start runs hooks for events in the background until the channel is
+// closed and the events queued before are handled.
+func (r *Runner) Start(events <-chan domain.Event) {
+	r.wg.Add(2)
+	go func() {
+		defer r.wg.Done()
+		for event := range events {
+			payload, ok := NewPayload(event)
+			if !ok || !r.wanted(payload.Event) {
+				continue
+			}
+			r.mu.Lock()
+			r.pending = append(r.pending, payload)
+			r.mu.Unlock()
+			r.wake.Signal()
+		}
+		r.mu.Lock()
+		r.closed = true
+		r.mu.Unlock()
+		r.wake.Signal()
+	}()
+	go func() {
+		defer r.wg.Done()
+		for {
+			r.mu.Lock()
+			for len(r.pending) == 0 && !r.closed {
+				r.wake.Wait()
+			}
+			if len(r.pending) == 0 {
+				r.mu.Unlock()
+				return
+			}
+			payload := r.pending[0]
+			r.pending = r.pending[1:]
+			r.mu.Unlock()
+			r.Run(payload)
+		}
+	}()
+}
+
+// Wait blocks until the events channel passed to Start is closed and every
+// hook queued before has run.
+func (r *Runner) Wait() {
+	r.wg.Wait()
+}
+
+// wanted reports whether any hook runs on event.
+func (r *Runner) wanted(event string) bool {
+	for _, hook := range r.hooks {
+		if len(hook.Events) == 0 || slices.Contains(hook.Events, event) {
+			return true
+		}
+	}
+	return false
+}
+
+// NewPayload describes a service event to hooks. It reports false for
+// events hooks do not run on.
+func NewPayload(event domain.Event) (Payload, bool) {
+	payload := Payload{
+		Time:         time.Now().UTC(),
+		PodcastID:    event.PodcastID,
+		PodcastTitle: event.PodcastTitle,
+		EpisodeID:    event.EpisodeID,
+		Title:        event.Title,
+	}
+	switch event.Kind {
+	case domain.EventSubscribed:
+		payload.Event = config.HookSubscribed
+		payload.PodcastTitle, payload.Title = event.Title, ""
+	case domain.EventEpisodeAdded:
+		payload.Event = config.HookEpisodeAdded
+	case domain.EventDownloadStarted:
+		payload.Event = config.HookDownloadStarted
+	case domain.EventDownloadFinished:
+		if event.State == domain.EpisodeStateSkipped {
+			return Payload{}, false
+		}
+		if event.Err != nil {
+			payload.Event, payload.Error = config.HookDownloadFailed, event.Err.Error()
+		} else {
+			payload.Event, payload.FilePath = config.HookDownloadCompleted, event.FilePath
+		}
+	default:
+		return Payload{}, false
+	}
+	return payload, true
+}
+
+// Run runs every hook configured for the payload's event, one after the
+// other. Failures are logged.
+func (r *Runner) Run(payload Payload) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("hook %s: %v", payload.Event, err)
+		return
+	}
+	for _, hook := range r.hooks {
+		if len(hook.Events) > 0 && !slices.Contains(hook.Events, payload.Event) {
+			continue
+		}
+		if command := strings.TrimSpace(hook.Command); command != "" {
+			ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+			_, err = r.runCommand(ctx, command, body, Environment(payload))
+			cancel()
+			if err != nil {
+				log.Printf("hook %s: command %q failed: %v", payload.Event, command, err)
+			}
+			continue
+		}
+		if err := r.post(strings.TrimSpace(hook.URL), body); err != nil {
+			log.Printf("hook %s: webhook %s failed: %v", payload.Event, hook.URL, err)
+		}
+	}
+}
+
+func (r *Runner) post(target string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if r.userAgent != "" {
+		req.Header.Set("User-Agent", r.userAgent)
+	}
+	client := r.client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// Environment passes the payload to a command as PODSINK_* variables.
+func Environment(payload Payload) []string {
+	return []string{
+		"PODSINK_EVENT=" + payload.Event,
+		"PODSINK_PODCAST_ID=" + payload.PodcastID,
+		"PODSINK_PODCAST_TITLE=" + payload.PodcastTitle,
+		"PODSINK_EPISODE_ID=" + payload.EpisodeID,
+		"PODSINK_TITLE=" + payload.Title,
+		"PODSINK_FILE=" + payload.FilePath,
+		"PODSINK_ERROR=" + payload.Error,
+		"PODSINK_COUNT=" + strconv.Itoa(payload.Count),
+	}
+}
+
+// Command runs command through the system shell (sh -c, cmd /C on Windows)
+// with stdin on standard input and env added to the environment, and
+// returns what it printed on standard output. A failing command's error
+// includes what it printed on standard error.
+func Command(ctx context.Context, command string, stdin []byte, env []string) ([]byte, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Env = append(os.Environ(), env...)
+	// Children the shell started may hold its output open after it is
+	// killed; stop waiting for them shortly after.
+	cmd.WaitDelay = time.Second
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = strings.TrimSpace(stdout.String())
+		}
+		if msg != "" {
+			return nil, fmt.Errorf("%w: %s", err, msg)
+		}
+		return nil, err
+	}
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,124 @@
+package hooks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"podsink/internal/config"
+	"podsink/internal/domain"
+)
+
+func TestRunnerPostsWebhooksAndRunsCommands(t *testing.T) {
+	var mu sync.Mutex
+	var posted []Payload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" || r.Header.Get("User-Agent") != "podsink/test" {
+			t.Errorf("unexpected request %s %v", r.Method, r.Header)
+		}
+		var payload Payload
+		body, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &payload); err != nil {
+			t.Errorf("decode payload: %v", err)
+		}
+		mu.Lock()
+		posted = append(posted, payload)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	runner := New([]config.Hook{
+		{URL: server.URL},
+		{Events: []string{config.HookDownloadCompleted}, Command: "post-process"},
+	}, server.Client(), "podsink/test")
+	type call struct {
+		command string
+		payload Payload
+		env     []string
+	}
+	var calls []call
+	runner.runCommand = func(_ context.Context, command string, body []byte, env []string) ([]byte, error) {
+		var payload Payload
+		if err := json.Unmarshal(body, &payload); err != nil {
+			t.Errorf("decode payload: %v", err)
+		}
+		calls = append(calls, call{command: command, payload: payload, env: env})
+		return nil, nil
+	}
+
+	events := make(chan domain.Event, 8)
+	runner.Start(events)
+	events <- domain.Event{Kind: domain.EventSubscribed, PodcastID: "pod1", Title: "Example Podcast"}
+	events <- domain.Event{Kind: domain.EventEpisodeAdded, PodcastID: "pod1", PodcastTitle: "Example Podcast", EpisodeID: "ep1", Title: "Episode One"}
+	events <- domain.Event{Kind: domain.EventDownloadProgress, EpisodeID: "ep1", Bytes: 10}
+	events <- domain.Event{Kind: domain.EventDownloadStarted, PodcastID: "pod1", EpisodeID: "ep1", Title: "Episode One"}
+	events <- domain.Event{Kind: domain.EventDownloadFinished, PodcastID: "pod1", EpisodeID: "ep1", Title: "Episode One", Err: errors.New("timeout")}
+	events <- domain.Event{Kind: domain.EventDownloadFinished, PodcastID: "pod1", EpisodeID: "ep1", Title: "Episode One", State: domain.EpisodeStateDownloaded, FilePath: "/podcasts/ep1.mp3"}
+	close(events)
+	runner.Wait()
+
+	var kinds []string
+	for _, payload := range posted {
+		kinds = append(kinds, payload.Event)
+	}
+	want := []string{config.HookSubscribed, config.HookEpisodeAdded, config.HookDownloadStarted, config.HookDownloadFailed, config.HookDownloadCompleted}
+	if !slices.Equal(kinds, want) {
+		t.Fatalf("webhook events = %v, want %v", kinds, want)
+	}
+	if posted[0].PodcastTitle != "Example Podcast" || posted[0].Title != "" {
+		t.Fatalf("unexpected subscribed payload %+v", posted[0])
+	}
+	if posted[3].Error != "timeout" || posted[4].FilePath != "/podcasts/ep1.mp3" {
+		t.Fatalf("unexpected download payloads %+v, %+v", posted[3], posted[4])
+	}
+
+	if len(calls) != 1 || calls[0].command != "post-process" || calls[0].payload.Event != config.HookDownloadCompleted {
+		t.Fatalf("unexpected command calls %+v", calls)
+	}
+	if !slices.Contains(calls[0].env, "PODSINK_FILE=/podcasts/ep1.mp3") || !slices.Contains(calls[0].env, "PODSINK_EVENT=download_completed") {
+		t.Fatalf("unexpected command environment %v", calls[0].env)
+	}
+}
+
+func TestRunnerSkipsEventsNoHookWants(t *testing.T) {
+	runner := New([]config.Hook{{Events: []string{config.HookSubscribed}, Command: "notify"}}, nil, "")
+	var commands int
+	runner.runCommand = func(context.Context, string, []byte, []string) ([]byte, error) {
+		commands++
+		return nil, nil
+	}
+	events := make(chan domain.Event, 2)
+	runner.Start(events)
+	events <- domain.Event{Kind: domain.EventEpisodeAdded, EpisodeID: "ep1"}
+	close(events)
+	runner.Wait()
+	if commands != 0 {
+		t.Fatalf("expected no command for an unwanted event, got %d", commands)
+	}
+}
+
+func TestCommandPassesStdinAndEnvironmentThroughTheShell(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	out, err := Command(context.Background(), `printf '%s ' "$PODSINK_EVENT"; cat`, []byte("payload"), Environment(Payload{Event: "downloaded"}))
+	if err != nil {
+		t.Fatalf("Command error = %v", err)
+	}
+	if string(out) != "downloaded payload" {
+		t.Fatalf("unexpected output %q", out)
+	}
+
+	_, err = Command(context.Background(), "echo broken >&2; exit 3", nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Fatalf("expected the error to carry stderr, got %v", err)
+	}
+}
@@ -10,6 +10,13 @@ import (
 	"strings"
 )
 
+// Directory is a podcast directory that can be searched and that resolves
+// the IDs of its search results. Client implements it for iTunes.
+type Directory interface {
+	Search(ctx context.Context, term string, limit int) ([]Podcast, error)
+	LookupPodcast(ctx context.Context, id string) (Podcast, error)
+}
+
 // Client interacts with the iTunes Search API.
 type Client struct {
 	httpClient *http.Client
@@ -40,6 +47,7 @@ type Podcast struct {
 	Language        string
 	Description     string
 	LongDescription string
+	EpisodeCount    int // Episodes the directory knows of; 0 when unknown
 }
 
 // Search queries the API for podcasts matching the supplied term.
@@ -95,6 +103,7 @@ func (c *Client) Search(ctx context.Context, term string, limit int) ([]Podcast,
 			Language:        item.Language,
 			Description:     item.Description,
 			LongDescription: item.LongDescription,
+			EpisodeCount:    item.TrackCount,
 		})
 	}
 	return results, nil
@@ -146,6 +155,7 @@ func (c *Client) LookupPodcast(ctx context.Context, id string) (Podcast, error)
 		Language:        item.Language,
 		Description:     item.Description,
 		LongDescription: item.LongDescription,
+		EpisodeCount:    item.TrackCount,
 	}, nil
 }
 
@@ -168,4 +178,5 @@ type podcastResult struct {
 	Language         string `json:"language"`
 	Description      string `json:"description"`
 	LongDescription  string `json:"longDescription"`
+	TrackCount       int    `json:"trackCount"`
 }
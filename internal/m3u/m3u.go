@@ -0,0 +1,37 @@
+package m3u
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Entry is a file in a playlist.
+type Entry struct {
+	Path        string
+	Title       string
+	DurationSec int // 0 when unknown, written as -1
+}
+
+// Write writes entries as an extended M3U playlist in UTF-8, which suits
+// both .m3u and .m3u8 files.
+func Write(w io.Writer, entries []Entry) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("#EXTM3U\n")
+	for _, entry := range entries {
+		duration := entry.DurationSec
+		if duration <= 0 {
+			duration = -1
+		}
+		fmt.Fprintf(bw, "#EXTINF:%d,%s\n%s\n", duration, oneLine(entry.Title), oneLine(entry.Path))
+	}
+	return bw.Flush()
+}
+
+// lineBreaks would end an entry early in the line-based format.
+var lineBreaks = strings.NewReplacer("\r\n", " ", "\r", " ", "\n", " ")
+
+func oneLine(s string) string {
+	return lineBreaks.Replace(s)
+}
@@ -0,0 +1,37 @@
+package m3u
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	entries := []Entry{
+		{Path: "Tech Talk/2024-01-02 Episode 1.mp3", Title: "Tech Talk - Episode 1", DurationSec: 1800},
+		{Path: "/music/News/Daily.mp3", Title: "News -\nDaily"},
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, entries); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	want := "#EXTM3U\n" +
+		"#EXTINF:1800,Tech Talk - Episode 1\n" +
+		"Tech Talk/2024-01-02 Episode 1.mp3\n" +
+		"#EXTINF:-1,News - Daily\n" +
+		"/music/News/Daily.mp3\n"
+	if got := buf.String(); got != want {
+		t.Errorf("Write() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestWriteEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, nil); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if got := buf.String(); got != "#EXTM3U\n" {
+		t.Errorf("Write() = %q, want only the header", got)
+	}
+}
@@ -0,0 +1,177 @@
+// Package notify tells the user about new episodes and finished downloads
+// with a desktop notification, a hook command, or both.
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"podsink/internal/domain"
+	"podsink/internal/hooks"
+)
+
+// Events passed to notify_command as PODSINK_EVENT.
+const (
+	EventNewEpisodes = "new_episodes"
+	EventDownloaded  = "downloaded"
+)
+
+// Notifier turns service events into notifications. The zero value
+// notifies nobody.
+type Notifier struct {
+	mu      sync.Mutex
+	desktop bool
+	command string
+	// run executes a desktop notification's command line and shell runs
+	// notify_command; tests replace them.
+	run   func(ctx context.Context, args []string) error
+	shell func(ctx context.Context, command string, stdin []byte, env []string) ([]byte, error)
+	wg    sync.WaitGroup
+}
+
+// New returns a notifier that shows desktop notifications when desktop is
+// set and runs command, a notify_command line, like a hook command when it
+// is not empty.
+func New(desktop bool, command string) *Notifier {
+	return &Notifier{desktop: desktop, command: command, run: runCommand, shell: hooks.Command}
+}
+
+// Configure changes the settings of a running notifier.
+func (n *Notifier) Configure(desktop bool, command string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.desktop, n.command = desktop, command
+}
+
+// Start handles events in the background until the channel is closed.
+func (n *Notifier) Start(events <-chan domain.Event) {
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+		for event := range events {
+			n.Handle(event)
+		}
+	}()
+}
+
+// Wait blocks until the events channel passed to Start is closed and the
+// notifications of the events before are done.
+func (n *Notifier) Wait() {
+	n.wg.Wait()
+}
+
+// notification is what an event tells the user, on the desktop and as
+// notify_command's payload.
+type notification struct {
+	summary string
+	body    string
+	payload hooks.Payload
+}
+
+// Handle notifies about a refresh that found new episodes or a completed
+// download; other events are ignored.
+func (n *Notifier) Handle(event domain.Event) {
+	note, ok := describe(event)
+	if !ok {
+		return
+	}
+	n.mu.Lock()
+	desktop, command, run, shell := n.desktop, n.command, n.run, n.shell
+	n.mu.Unlock()
+	if run == nil || shell == nil {
+		return
+	}
+
+	if desktop {
+		if args := desktopArgs(runtime.GOOS, note.summary, note.body); args != nil {
+			if err := runWithTimeout(run, args); err != nil {
+				log.Printf("desktop notification failed: %v", err)
+			}
+		}
+	}
+	if command = strings.TrimSpace(command); command != "" {
+		body, err := json.Marshal(note.payload)
+		if err == nil {
+			ctx, cancel := context.WithTimeout(context.Background(), hooks.Timeout)
+			_, err = shell(ctx, command, body, hooks.Environment(note.payload))
+			cancel()
+		}
+		if err != nil {
+			log.Printf("notify_command failed: %v", err)
+		}
+	}
+}
+
+func describe(event domain.Event) (notification, bool) {
+	switch event.Kind {
+	case domain.EventRefreshFinished:
+		if event.Added <= 0 {
+			return notification{}, false
+		}
+		body := fmt.Sprintf("%d new episode(s)", event.Added)
+		if event.Title != "" {
+			body += " of " + event.Title
+		}
+		payload := hooks.Payload{Event: EventNewEpisodes, Time: time.Now().UTC(), PodcastID: event.PodcastID, PodcastTitle: event.Title, Count: event.Added}
+		return notification{summary: "New episodes", body: body, payload: payload}, true
+	case domain.EventDownloadFinished:
+		if event.Err != nil || event.State != domain.EpisodeStateDownloaded {
+			return notification{}, false
+		}
+		title := event.Title
+		if title == "" {
+			title = event.EpisodeID
+		}
+		payload := hooks.Payload{
+			Event:        EventDownloaded,
+			Time:         time.Now().UTC(),
+			PodcastID:    event.PodcastID,
+			PodcastTitle: event.PodcastTitle,
+			EpisodeID:    event.EpisodeID,
+			Title:        title,
+			FilePath:     event.FilePath,
+			Count:        1,
+		}
+		return notification{summary: "Download complete", body: title, payload: payload}, true
+	}
+	return notification{}, false
+}
+
+// desktopArgs returns the command showing a desktop notification on goos,
+// or nil where podsink has none.
+func desktopArgs(goos, summary, body string) []string {
+	switch goos {
+	case "darwin":
+		script := fmt.Sprintf("display notification %s with title %s", appleScriptString(body), appleScriptString("podsink: "+summary))
+		return []string{"osascript", "-e", script}
+	case "windows":
+		return nil
+	default:
+		return []string{"notify-send", "--app-name=podsink", summary, body}
+	}
+}
+
+func appleScriptString(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
+
+func runWithTimeout(run func(context.Context, []string) error, args []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), hooks.Timeout)
+	defer cancel()
+	return run(ctx, args)
+}
+
+func runCommand(ctx context.Context, args []string) error {
+	out, err := exec.CommandContext(ctx, args[0], args[1:]...).CombinedOutput()
+	if err != nil && len(out) > 0 {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return err
+}
@@ -0,0 +1,125 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"runtime"
+	"slices"
+	"sync"
+	"testing"
+
+	"podsink/internal/domain"
+	"podsink/internal/hooks"
+)
+
+type recorder struct {
+	mu       sync.Mutex
+	calls    [][]string
+	commands []shellCall
+}
+
+type shellCall struct {
+	command string
+	payload hooks.Payload
+	env     []string
+}
+
+func (r *recorder) run(_ context.Context, args []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, args)
+	return nil
+}
+
+func (r *recorder) shell(_ context.Context, command string, stdin []byte, env []string) ([]byte, error) {
+	var payload hooks.Payload
+	if err := json.Unmarshal(stdin, &payload); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.commands = append(r.commands, shellCall{command: command, payload: payload, env: env})
+	return nil, nil
+}
+
+func (r *recorder) attach(n *Notifier) {
+	n.run, n.shell = r.run, r.shell
+}
+
+func TestHookRunsForNewEpisodesAndDownloads(t *testing.T) {
+	rec := &recorder{}
+	n := New(false, `notify-hook "$PODSINK_EVENT"`)
+	rec.attach(n)
+
+	events := make(chan domain.Event, 8)
+	n.Start(events)
+	events <- domain.Event{Kind: domain.EventRefreshFinished}
+	events <- domain.Event{Kind: domain.EventRefreshFinished, Added: 3, PodcastID: "pod1", Title: "Example Podcast"}
+	events <- domain.Event{Kind: domain.EventDownloadFinished, EpisodeID: "ep1", Title: "Failed", Err: errors.New("boom")}
+	events <- domain.Event{Kind: domain.EventDownloadFinished, EpisodeID: "ep2", PodcastID: "pod1", Title: "Episode Two", State: domain.EpisodeStateDownloaded}
+	events <- domain.Event{Kind: domain.EventEpisodeState, EpisodeID: "ep3", State: domain.EpisodeStateQueued}
+	close(events)
+	n.Wait()
+
+	if len(rec.calls) != 0 {
+		t.Fatalf("expected no desktop notification, got %q", rec.calls)
+	}
+	if len(rec.commands) != 2 {
+		t.Fatalf("expected 2 notify_command runs, got %+v", rec.commands)
+	}
+	newEpisodes, downloaded := rec.commands[0], rec.commands[1]
+	if newEpisodes.command != `notify-hook "$PODSINK_EVENT"` || newEpisodes.payload.Event != EventNewEpisodes || newEpisodes.payload.PodcastTitle != "Example Podcast" || newEpisodes.payload.Count != 3 {
+		t.Fatalf("unexpected new episodes call %+v", newEpisodes)
+	}
+	for _, variable := range []string{"PODSINK_EVENT=new_episodes", "PODSINK_PODCAST_ID=pod1", "PODSINK_COUNT=3"} {
+		if !slices.Contains(newEpisodes.env, variable) {
+			t.Fatalf("expected %s in %v", variable, newEpisodes.env)
+		}
+	}
+	if downloaded.payload.Event != EventDownloaded || downloaded.payload.EpisodeID != "ep2" || downloaded.payload.Title != "Episode Two" {
+		t.Fatalf("unexpected download call %+v", downloaded)
+	}
+	for _, variable := range []string{"PODSINK_EVENT=downloaded", "PODSINK_EPISODE_ID=ep2", "PODSINK_TITLE=Episode Two", "PODSINK_COUNT=1"} {
+		if !slices.Contains(downloaded.env, variable) {
+			t.Fatalf("expected %s in %v", variable, downloaded.env)
+		}
+	}
+}
+
+func TestDesktopNotificationsFollowConfiguration(t *testing.T) {
+	rec := &recorder{}
+	n := New(false, "")
+	rec.attach(n)
+
+	event := domain.Event{Kind: domain.EventRefreshFinished, Added: 2}
+	n.Handle(event)
+	if len(rec.calls) != 0 {
+		t.Fatalf("expected no notification while disabled, got %q", rec.calls)
+	}
+
+	n.Configure(true, "")
+	n.Handle(event)
+	want := [][]string{desktopArgs(runtime.GOOS, "New episodes", "2 new episode(s)")}
+	if want[0] == nil {
+		want = nil
+	}
+	if !reflect.DeepEqual(rec.calls, want) {
+		t.Fatalf("unexpected notifications %q, want %q", rec.calls, want)
+	}
+}
+
+func TestDesktopArgs(t *testing.T) {
+	if got, want := desktopArgs("linux", "Download complete", "Episode"), []string{"notify-send", "--app-name=podsink", "Download complete", "Episode"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("linux: got %q, want %q", got, want)
+	}
+	got := desktopArgs("darwin", "Download complete", `Say "hi" \ bye`)
+	want := []string{"osascript", "-e", `display notification "Say \"hi\" \\ bye" with title "podsink: Download complete"`}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("darwin: got %q, want %q", got, want)
+	}
+	if got := desktopArgs("windows", "Download complete", "Episode"); got != nil {
+		t.Fatalf("windows: expected no command, got %q", got)
+	}
+}
@@ -4,15 +4,22 @@ import (
 	"encoding/xml"
 	"fmt"
 	"io"
+	"slices"
+	"strings"
 	"time"
 )
 
+// PodcastNamespace is the Podcasting 2.0 namespace of the podcast:guid
+// outline attribute.
+const PodcastNamespace = "https://podcastindex.org/namespace/1.0"
+
 // OPML represents the root OPML document structure.
 type OPML struct {
-	XMLName xml.Name `xml:"opml"`
-	Version string   `xml:"version,attr"`
-	Head    Head     `xml:"head"`
-	Body    Body     `xml:"body"`
+	XMLName      xml.Name `xml:"opml"`
+	Version      string   `xml:"version,attr"`
+	XMLNSPodcast string   `xml:"xmlns:podcast,attr,omitempty"`
+	Head         Head     `xml:"head"`
+	Body         Body     `xml:"body"`
 }
 
 // Head contains metadata about the OPML document.
@@ -26,19 +33,54 @@ type Body struct {
 	Outlines []Outline `xml:"outline"`
 }
 
-// Outline represents a single podcast subscription.
+// Outline represents a single podcast subscription, or a group of them
+// such as a category folder.
 type Outline struct {
-	Type    string `xml:"type,attr"`
+	Type    string `xml:"type,attr,omitempty"`
 	Text    string `xml:"text,attr"`
 	Title   string `xml:"title,attr,omitempty"`
-	XMLURL  string `xml:"xmlUrl,attr"`
+	XMLURL  string `xml:"xmlUrl,attr,omitempty"`
 	HTMLURL string `xml:"htmlUrl,attr,omitempty"`
+	// GUID is written as podcast:guid. When reading, namespaced attributes
+	// end up in Extra instead.
+	GUID     string     `xml:"podcast:guid,attr,omitempty"`
+	Extra    []xml.Attr `xml:",any,attr"`
+	Outlines []Outline  `xml:"outline"`
+}
+
+// name returns the outline's title, falling back to its text.
+func (o Outline) name() string {
+	if o.Title != "" {
+		return o.Title
+	}
+	return o.Text
 }
 
+// guid returns the outline's podcast:guid, accepting the attribute with or
+// without a declared namespace.
+func (o Outline) guid() string {
+	for _, attr := range o.Extra {
+		if attr.Name.Local == "guid" && (attr.Name.Space == PodcastNamespace || attr.Name.Space == "podcast") {
+			return NormalizeGUID(attr.Value)
+		}
+	}
+	return NormalizeGUID(o.GUID)
+}
+
+// NormalizeGUID returns guid in the form podcast:guid values are compared in.
+func NormalizeGUID(guid string) string {
+	return strings.ToLower(strings.TrimSpace(guid))
+}
+
+// TagSeparator joins the names of nested groups into a tag.
+const TagSeparator = "/"
+
 // Subscription represents a parsed podcast subscription from OPML.
 type Subscription struct {
 	Title   string
 	FeedURL string
+	GUID    string   // podcast:guid, if known
+	Tags    []string // Groups the outline is nested in, e.g. "News/Tech"
 }
 
 // Export writes subscriptions to an OPML file.
@@ -55,12 +97,23 @@ func Export(w io.Writer, subscriptions []Subscription) error {
 	}
 
 	for _, sub := range subscriptions {
-		doc.Body.Outlines = append(doc.Body.Outlines, Outline{
+		if sub.GUID != "" {
+			doc.XMLNSPodcast = PodcastNamespace
+		}
+		feed := Outline{
 			Type:   "rss",
 			Text:   sub.Title,
 			Title:  sub.Title,
 			XMLURL: sub.FeedURL,
-		})
+			GUID:   sub.GUID,
+		}
+		if len(sub.Tags) == 0 {
+			doc.Body.Outlines = append(doc.Body.Outlines, feed)
+		}
+		// A feed with several tags is listed in each of their groups
+		for _, tag := range sub.Tags {
+			addToGroup(&doc.Body.Outlines, strings.Split(tag, TagSeparator), feed)
+		}
 	}
 
 	encoder := xml.NewEncoder(w)
@@ -80,20 +133,58 @@ func Import(r io.Reader) ([]Subscription, error) {
 		return nil, fmt.Errorf("decode OPML: %w", err)
 	}
 
-	subscriptions := make([]Subscription, 0, len(doc.Body.Outlines))
-	for _, outline := range doc.Body.Outlines {
-		if outline.XMLURL == "" {
-			continue
-		}
-		title := outline.Title
-		if title == "" {
-			title = outline.Text
+	var subscriptions []Subscription
+	listed := map[string]int{}
+	var walk func(outlines []Outline, groups []string)
+	walk = func(outlines []Outline, groups []string) {
+		tag := strings.Join(groups, TagSeparator)
+		for _, outline := range outlines {
+			if outline.XMLURL != "" {
+				// A feed listed in several groups is imported once with
+				// all of their tags
+				i, ok := listed[outline.XMLURL]
+				if !ok {
+					i = len(subscriptions)
+					listed[outline.XMLURL] = i
+					subscriptions = append(subscriptions, Subscription{
+						Title:   outline.name(),
+						FeedURL: outline.XMLURL,
+						GUID:    outline.guid(),
+					})
+				}
+				if tag != "" && !slices.Contains(subscriptions[i].Tags, tag) {
+					subscriptions[i].Tags = append(subscriptions[i].Tags, tag)
+				}
+			}
+			if len(outline.Outlines) == 0 {
+				continue
+			}
+			if name := strings.TrimSpace(outline.name()); name != "" {
+				walk(outline.Outlines, append(groups[:len(groups):len(groups)], name))
+			} else {
+				walk(outline.Outlines, groups)
+			}
 		}
-		subscriptions = append(subscriptions, Subscription{
-			Title:   title,
-			FeedURL: outline.XMLURL,
-		})
 	}
+	walk(doc.Body.Outlines, nil)
 
 	return subscriptions, nil
 }
+
+// addToGroup adds feed to the group outline at path below outlines,
+// creating the groups that do not exist yet.
+func addToGroup(outlines *[]Outline, path []string, feed Outline) {
+	if len(path) == 0 {
+		*outlines = append(*outlines, feed)
+		return
+	}
+	for i := range *outlines {
+		if group := &(*outlines)[i]; group.XMLURL == "" && group.Text == path[0] {
+			addToGroup(&group.Outlines, path[1:], feed)
+			return
+		}
+	}
+	group := Outline{Text: path[0], Title: path[0]}
+	addToGroup(&group.Outlines, path[1:], feed)
+	*outlines = append(*outlines, group)
+}
@@ -109,3 +109,110 @@ func TestRoundTrip(t *testing.T) {
 		}
 	}
 }
+
+func TestPodcastGUIDRoundTrip(t *testing.T) {
+	original := []Subscription{
+		{Title: "With GUID", FeedURL: "https://example.com/a.xml", GUID: "917393e3-1b1e-5cef-ace4-edaa54e1f810"},
+		{Title: "Without GUID", FeedURL: "https://example.com/b.xml"},
+	}
+
+	var buf bytes.Buffer
+	if err := Export(&buf, original); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+	output := buf.String()
+	if !strings.Contains(output, `xmlns:podcast="`+PodcastNamespace+`"`) || strings.Count(output, "podcast:guid=") != 1 {
+		t.Fatalf("Export() output lacks podcast:guid:\n%s", output)
+	}
+
+	imported, err := Import(&buf)
+	if err != nil {
+		t.Fatalf("Import() error = %v", err)
+	}
+	if len(imported) != 2 || imported[0].GUID != original[0].GUID || imported[1].GUID != "" {
+		t.Fatalf("unexpected imported GUIDs %+v", imported)
+	}
+}
+
+func TestImportPodcastGUIDWithoutNamespace(t *testing.T) {
+	opmlData := `<opml version="2.0"><body>
+<outline type="rss" text="A" xmlUrl="https://example.com/a.xml" podcast:guid=" 917393E3-1B1E-5CEF-ACE4-EDAA54E1F810 "/>
+</body></opml>`
+
+	subs, err := Import(strings.NewReader(opmlData))
+	if err != nil {
+		t.Fatalf("Import() error = %v", err)
+	}
+	if len(subs) != 1 || subs[0].GUID != "917393e3-1b1e-5cef-ace4-edaa54e1f810" {
+		t.Fatalf("unexpected subscriptions %+v", subs)
+	}
+}
+
+func TestImportNestedGroupsAsTags(t *testing.T) {
+	opmlData := `<?xml version="1.0" encoding="UTF-8"?>
+<opml version="2.0">
+  <body>
+    <outline type="rss" text="Loose" xmlUrl="https://example.com/loose.xml" />
+    <outline text="News">
+      <outline type="rss" text="Daily" xmlUrl="https://example.com/daily.xml" />
+      <outline title="Tech" text="Technology">
+        <outline type="rss" text="Gadgets" xmlUrl="https://example.com/gadgets.xml" />
+      </outline>
+    </outline>
+    <outline text="Favourites">
+      <outline type="rss" text="Daily" xmlUrl="https://example.com/daily.xml" />
+    </outline>
+  </body>
+</opml>`
+
+	subs, err := Import(strings.NewReader(opmlData))
+	if err != nil {
+		t.Fatalf("Import() error = %v", err)
+	}
+	if len(subs) != 3 {
+		t.Fatalf("Import() returned %d subscriptions, want 3: %+v", len(subs), subs)
+	}
+	want := map[string]string{
+		"https://example.com/loose.xml":   "",
+		"https://example.com/daily.xml":   "News,Favourites",
+		"https://example.com/gadgets.xml": "News/Tech",
+	}
+	for _, sub := range subs {
+		if got := strings.Join(sub.Tags, ","); got != want[sub.FeedURL] {
+			t.Errorf("%s tags = %q, want %q", sub.FeedURL, got, want[sub.FeedURL])
+		}
+	}
+}
+
+func TestGroupsRoundTrip(t *testing.T) {
+	original := []Subscription{
+		{Title: "Daily", FeedURL: "https://example.com/daily.xml", Tags: []string{"News", "Favourites"}},
+		{Title: "Gadgets", FeedURL: "https://example.com/gadgets.xml", Tags: []string{"News/Tech"}},
+		{Title: "Loose", FeedURL: "https://example.com/loose.xml"},
+	}
+
+	var buf bytes.Buffer
+	if err := Export(&buf, original); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+	if strings.Count(buf.String(), `text="News"`) != 1 {
+		t.Errorf("Export() should write the News group once:\n%s", buf.String())
+	}
+
+	imported, err := Import(&buf)
+	if err != nil {
+		t.Fatalf("Import() error = %v", err)
+	}
+	if len(imported) != len(original) {
+		t.Fatalf("Round trip: got %d subscriptions, want %d", len(imported), len(original))
+	}
+	tags := map[string]string{}
+	for _, sub := range imported {
+		tags[sub.FeedURL] = strings.Join(sub.Tags, ",")
+	}
+	for _, sub := range original {
+		if want := strings.Join(sub.Tags, ","); tags[sub.FeedURL] != want {
+			t.Errorf("%s tags = %q, want %q", sub.FeedURL, tags[sub.FeedURL], want)
+		}
+	}
+}
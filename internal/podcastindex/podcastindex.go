@@ -0,0 +1,205 @@
+// Package podcastindex searches the Podcast Index (podcastindex.org), an
+// open directory with better coverage than iTunes in some regions.
+package podcastindex
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"podsink/internal/itunes"
+)
+
+// IDPrefix marks podcast IDs that are Podcast Index feed IDs. Podcasts that
+// are also listed in iTunes keep their iTunes ID, so subscriptions do not
+// depend on which directory they were found in.
+const IDPrefix = "pi-"
+
+// Client interacts with the Podcast Index API. It implements
+// itunes.Directory.
+type Client struct {
+	httpClient *http.Client
+	baseURL    string
+	key        string
+	secret     string
+	userAgent  string
+	now        func() time.Time
+}
+
+// NewClient creates a client authenticating with the API key and secret.
+// The baseURL can be overridden for testing; if empty the public API
+// endpoint is used.
+func NewClient(httpClient *http.Client, baseURL, key, secret, userAgent string) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	if strings.TrimSpace(baseURL) == "" {
+		baseURL = "https://api.podcastindex.org/api/1.0"
+	}
+	if strings.TrimSpace(userAgent) == "" {
+		userAgent = "podsink"
+	}
+	return &Client{
+		httpClient: httpClient,
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		key:        key,
+		secret:     secret,
+		userAgent:  userAgent,
+		now:        time.Now,
+	}
+}
+
+// Search queries the index for podcasts matching the supplied term.
+func (c *Client) Search(ctx context.Context, term string, limit int) ([]itunes.Podcast, error) {
+	if strings.TrimSpace(term) == "" {
+		return nil, fmt.Errorf("search term cannot be empty")
+	}
+	if limit <= 0 {
+		limit = 10
+	}
+
+	var payload searchResponse
+	query := url.Values{"q": {term}, "max": {strconv.Itoa(limit)}}
+	if err := c.get(ctx, "/search/byterm", query, &payload); err != nil {
+		return nil, fmt.Errorf("podcast index search: %w", err)
+	}
+
+	results := make([]itunes.Podcast, 0, len(payload.Feeds))
+	for _, item := range payload.Feeds {
+		results = append(results, item.podcast())
+	}
+	return results, nil
+}
+
+// LookupPodcast retrieves metadata for a single podcast. IDs with IDPrefix
+// are looked up by Podcast Index feed ID, all others by iTunes ID.
+func (c *Client) LookupPodcast(ctx context.Context, id string) (itunes.Podcast, error) {
+	path := "/podcasts/byitunesid"
+	if feedID, ok := strings.CutPrefix(id, IDPrefix); ok {
+		path, id = "/podcasts/byfeedid", feedID
+	}
+	if _, err := strconv.ParseInt(id, 10, 64); err != nil {
+		return itunes.Podcast{}, fmt.Errorf("podcast not found")
+	}
+
+	var payload lookupResponse
+	if err := c.get(ctx, path, url.Values{"id": {id}}, &payload); err != nil {
+		return itunes.Podcast{}, fmt.Errorf("podcast index lookup: %w", err)
+	}
+	// A podcast that is not indexed comes back as an empty array.
+	var item feed
+	if len(bytes.TrimSpace(payload.Feed)) == 0 || payload.Feed[0] != '{' {
+		return itunes.Podcast{}, fmt.Errorf("podcast not found")
+	}
+	if err := json.Unmarshal(payload.Feed, &item); err != nil {
+		return itunes.Podcast{}, fmt.Errorf("decode lookup response: %w", err)
+	}
+	if item.ID == 0 {
+		return itunes.Podcast{}, fmt.Errorf("podcast not found")
+	}
+	return item.podcast(), nil
+}
+
+// get sends an authenticated request: the API expects the key, the current
+// Unix time and a SHA-1 of key, secret and time in the headers.
+func (c *Client) get(ctx context.Context, path string, query url.Values, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path+"?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	date := strconv.FormatInt(c.now().Unix(), 10)
+	sum := sha1.Sum([]byte(c.key + c.secret + date))
+	req.Header.Set("User-Agent", c.userAgent)
+	req.Header.Set("X-Auth-Key", c.key)
+	req.Header.Set("X-Auth-Date", date)
+	req.Header.Set("Authorization", hex.EncodeToString(sum[:]))
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("request failed: %s", resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
+
+type searchResponse struct {
+	Feeds []feed `json:"feeds"`
+}
+
+type lookupResponse struct {
+	Feed json.RawMessage `json:"feed"`
+}
+
+type feed struct {
+	ID          int64             `json:"id"`
+	Title       string            `json:"title"`
+	URL         string            `json:"url"`
+	Author      string            `json:"author"`
+	OwnerName   string            `json:"ownerName"`
+	Description string            `json:"description"`
+	Image       string            `json:"image"`
+	Artwork     string            `json:"artwork"`
+	ITunesID    int64             `json:"itunesId"`
+	Language    string            `json:"language"`
+	Categories  map[string]string `json:"categories"`
+	Episodes    int               `json:"episodeCount"`
+}
+
+func (f feed) podcast() itunes.Podcast {
+	id := IDPrefix + strconv.FormatInt(f.ID, 10)
+	if f.ITunesID > 0 {
+		id = strconv.FormatInt(f.ITunesID, 10)
+	}
+	author := f.Author
+	if strings.TrimSpace(author) == "" {
+		author = f.OwnerName
+	}
+	artwork := f.Artwork
+	if artwork == "" {
+		artwork = f.Image
+	}
+	return itunes.Podcast{
+		ID:              id,
+		Title:           f.Title,
+		Author:          author,
+		FeedURL:         f.URL,
+		Artwork:         artwork,
+		Genre:           f.genre(),
+		Language:        f.Language,
+		Description:     f.Description,
+		LongDescription: f.Description,
+		EpisodeCount:    f.Episodes,
+	}
+}
+
+// genre returns the category with the lowest ID, which the index lists
+// first.
+func (f feed) genre() string {
+	ids := make([]int, 0, len(f.Categories))
+	for key := range f.Categories {
+		if id, err := strconv.Atoi(key); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return ""
+	}
+	sort.Ints(ids)
+	return f.Categories[strconv.Itoa(ids[0])]
+}
@@ -0,0 +1,75 @@
+package podcastindex
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSearchAndLookup(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sum := sha1.Sum([]byte("key" + "secret" + "1700000000"))
+		if r.Header.Get("X-Auth-Key") != "key" || r.Header.Get("X-Auth-Date") != "1700000000" ||
+			r.Header.Get("Authorization") != hex.EncodeToString(sum[:]) || r.Header.Get("User-Agent") != "podsink/test" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/search/byterm" && r.URL.Query().Get("q") == "go" && r.URL.Query().Get("max") == "5":
+			w.Write([]byte(`{"status":"true","feeds":[
+				{"id":920666,"title":"Go Time","url":"https://changelog.com/gotime/feed","author":"Changelog","itunesId":1120964487,"artwork":"https://a/art.png","language":"en","episodeCount":250,"categories":{"104":"Tech","102":"Technology"}},
+				{"id":42,"title":"Gopher Talk","url":"https://example.com/feed","ownerName":"Gophers","image":"https://a/img.png"}
+			]}`))
+		case r.URL.Path == "/podcasts/byfeedid" && r.URL.Query().Get("id") == "42":
+			w.Write([]byte(`{"status":"true","feed":{"id":42,"title":"Gopher Talk","url":"https://example.com/feed"}}`))
+		case r.URL.Path == "/podcasts/byitunesid" && r.URL.Query().Get("id") == "1120964487":
+			w.Write([]byte(`{"status":"true","feed":{"id":920666,"title":"Go Time","url":"https://changelog.com/gotime/feed","itunesId":1120964487}}`))
+		case r.URL.Path == "/podcasts/byitunesid":
+			w.Write([]byte(`{"status":"true","feed":[]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient(server.Client(), server.URL, "key", "secret", "podsink/test")
+	client.now = func() time.Time { return time.Unix(1700000000, 0) }
+	ctx := context.Background()
+
+	results, err := client.Search(ctx, "go", 5)
+	if err != nil {
+		t.Fatalf("Search() error = %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+	if got := results[0]; got.ID != "1120964487" || got.Title != "Go Time" || got.FeedURL != "https://changelog.com/gotime/feed" ||
+		got.Author != "Changelog" || got.Artwork != "https://a/art.png" || got.Genre != "Technology" || got.EpisodeCount != 250 {
+		t.Fatalf("unexpected first result %+v", got)
+	}
+	if got := results[1]; got.ID != "pi-42" || got.Author != "Gophers" || got.Artwork != "https://a/img.png" {
+		t.Fatalf("podcasts without an iTunes ID should use the feed ID, got %+v", got)
+	}
+
+	podcast, err := client.LookupPodcast(ctx, "pi-42")
+	if err != nil || podcast.ID != "pi-42" || podcast.FeedURL != "https://example.com/feed" {
+		t.Fatalf("LookupPodcast(pi-42) = %+v, %v", podcast, err)
+	}
+	podcast, err = client.LookupPodcast(ctx, "1120964487")
+	if err != nil || podcast.ID != "1120964487" || podcast.Title != "Go Time" {
+		t.Fatalf("LookupPodcast(itunes id) = %+v, %v", podcast, err)
+	}
+	if _, err := client.LookupPodcast(ctx, "7"); err == nil {
+		t.Fatal("expected an error for a podcast that is not indexed")
+	}
+
+	client.secret = "wrong"
+	if _, err := client.Search(ctx, "go", 5); err == nil {
+		t.Fatal("expected an error for a bad signature")
+	}
+}
@@ -0,0 +1,79 @@
+// Package refresh periodically re-fetches subscribed feeds in the background.
+// Each feed is scheduled individually, so the scheduler only checks which
+// feeds are due.
+package refresh
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"podsink/internal/subscriptions"
+)
+
+// maxCheckInterval bounds how long a due feed waits for the next check.
+const maxCheckInterval = 5 * time.Minute
+
+// Refresher fetches the subscribed feeds that are due.
+type Refresher interface {
+	RefreshDue(ctx context.Context) (subscriptions.RefreshResult, error)
+}
+
+// Scheduler checks for due feeds until stopped.
+type Scheduler struct {
+	refresher Refresher
+	interval  time.Duration
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+}
+
+// Start begins checking for due feeds every interval, or every five minutes
+// when the interval is longer. A non-positive interval disables the
+// scheduler; Stop is still safe to call.
+func Start(ctx context.Context, refresher Refresher, interval time.Duration) *Scheduler {
+	ctx, cancel := context.WithCancel(ctx)
+	if interval > maxCheckInterval {
+		interval = maxCheckInterval
+	}
+	s := &Scheduler{refresher: refresher, interval: interval, cancel: cancel}
+	if interval > 0 {
+		s.wg.Add(1)
+		go s.run(ctx)
+	}
+	return s
+}
+
+func (s *Scheduler) Stop() {
+	if s == nil {
+		return
+	}
+	s.cancel()
+	s.wg.Wait()
+}
+
+func (s *Scheduler) run(ctx context.Context) {
+	defer s.wg.Done()
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			result, err := s.refresher.RefreshDue(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Printf("feed refresh failed: %v", err)
+				}
+				continue
+			}
+			for _, failure := range result.Failures {
+				log.Printf("feed refresh: %s: %s", failure.Title, failure.Err)
+			}
+			if result.Refreshed > 0 {
+				log.Printf("refreshed %d feed(s), %d new episode(s)", result.Refreshed, result.Added)
+			}
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package repl
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	_ "image/gif" // Register decoders for the artwork formats feeds use
+	_ "image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"podsink/internal/config"
+	"podsink/internal/itunes"
+)
+
+const (
+	// kittyMaxPixels and sixelMaxPixels bound the longer side of the drawn
+	// image. Kitty scales it to kittyColumns cells; sixel images are drawn
+	// at their pixel size.
+	kittyMaxPixels = 512
+	kittyColumns   = 40
+	sixelMaxPixels = 320
+	// kittyChunk is the largest payload of one kitty graphics command.
+	kittyChunk = 4096
+)
+
+// artworkLoadedMsg reports the cached artwork of the podcast in details.
+type artworkLoadedMsg struct {
+	podcastID string
+	path      string
+	err       error
+}
+
+// artworkClosedMsg follows the artwork viewer handing back the terminal.
+type artworkClosedMsg struct {
+	err error
+}
+
+// loadArtwork fetches the podcast's artwork into the cache in the
+// background.
+func (m model) loadArtwork(podcast itunes.Podcast) tea.Cmd {
+	if strings.TrimSpace(podcast.Artwork) == "" {
+		return nil
+	}
+	ctx, application := m.ctx, m.app
+	return func() tea.Msg {
+		path, err := application.Artwork(ctx, podcast.ID, podcast.Artwork)
+		return artworkLoadedMsg{podcastID: podcast.ID, path: path, err: err}
+	}
+}
+
+// artworkProtocol resolves the artwork_protocol setting. Auto picks kitty
+// graphics or sixel from the environment of terminals known to support
+// them, and none otherwise.
+func artworkProtocol(setting string) string {
+	if setting != config.ArtworkAuto && setting != "" {
+		return setting
+	}
+	term, program := os.Getenv("TERM"), os.Getenv("TERM_PROGRAM")
+	switch {
+	case os.Getenv("KITTY_WINDOW_ID") != "" || strings.Contains(term, "kitty") || program == "ghostty" || program == "WezTerm":
+		return config.ArtworkKitty
+	case strings.Contains(term, "sixel") || strings.HasPrefix(term, "foot") || term == "mlterm" || program == "iTerm.app":
+		return config.ArtworkSixel
+	}
+	return config.ArtworkNone
+}
+
+// artworkViewer draws an image while the REPL has released the terminal and
+// waits for Enter. It runs through tea.Exec because the renderer cannot
+// place graphics between its lines.
+type artworkViewer struct {
+	path     string
+	protocol string
+	stdin    io.Reader
+	stdout   io.Writer
+}
+
+func (v *artworkViewer) SetStdin(r io.Reader)  { v.stdin = r }
+func (v *artworkViewer) SetStdout(w io.Writer) { v.stdout = w }
+func (v *artworkViewer) SetStderr(io.Writer)   {}
+
+func (v *artworkViewer) Run() error {
+	graphics, err := encodeArtwork(v.path, v.protocol)
+	if err != nil {
+		return err
+	}
+	fmt.Fprint(v.stdout, "\x1b[2J\x1b[H"+graphics)
+	fmt.Fprintf(v.stdout, "\r\n%s\r\nPress Enter to return.", v.path)
+	_, err = bufio.NewReader(v.stdin).ReadString('\n')
+	if v.protocol == config.ArtworkKitty {
+		fmt.Fprint(v.stdout, "\x1b_Ga=d\x1b\\")
+	}
+	if err == io.EOF {
+		err = nil
+	}
+	return err
+}
+
+// encodeArtwork returns the escape sequence that draws the image file with
+// the given graphics protocol.
+func encodeArtwork(path, protocol string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	img, format, err := image.Decode(f)
+	if err != nil {
+		return "", fmt.Errorf("cannot draw artwork: %w", err)
+	}
+	switch protocol {
+	case config.ArtworkKitty:
+		return kittyImage(scaleDown(img, kittyMaxPixels), kittyColumns)
+	case config.ArtworkSixel:
+		return sixelImage(scaleDown(img, sixelMaxPixels)), nil
+	}
+	return "", fmt.Errorf("cannot draw %s artwork with protocol %q", format, protocol)
+}
+
+// scaleDown shrinks img so its longer side is at most maxPixels, averaging
+// the source pixels that fall into each target pixel.
+func scaleDown(img image.Image, maxPixels int) image.Image {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w <= maxPixels && h <= maxPixels {
+		return img
+	}
+	tw, th := maxPixels, h*maxPixels/w
+	if h > w {
+		tw, th = w*maxPixels/h, maxPixels
+	}
+	tw, th = max(tw, 1), max(th, 1)
+	out := image.NewRGBA(image.Rect(0, 0, tw, th))
+	for y := 0; y < th; y++ {
+		y0, y1 := bounds.Min.Y+y*h/th, bounds.Min.Y+max((y+1)*h/th, y*h/th+1)
+		for x := 0; x < tw; x++ {
+			x0, x1 := bounds.Min.X+x*w/tw, bounds.Min.X+max((x+1)*w/tw, x*w/tw+1)
+			var r, g, b, a, n uint32
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := img.At(sx, sy).RGBA()
+					r, g, b, a, n = r+pr, g+pg, b+pb, a+pa, n+1
+				}
+			}
+			out.Set(x, y, color.RGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(b / n), A: uint16(a / n)})
+		}
+	}
+	return out
+}
+
+// kittyImage transmits img as PNG with the kitty graphics protocol, scaled
+// to the given number of cells across, in chunks the protocol allows.
+func kittyImage(img image.Image, columns int) (string, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return "", err
+	}
+	data := base64.StdEncoding.EncodeToString(buf.Bytes())
+	var b strings.Builder
+	for start := 0; start < len(data); start += kittyChunk {
+		end := min(start+kittyChunk, len(data))
+		more := 0
+		if end < len(data) {
+			more = 1
+		}
+		if start == 0 {
+			fmt.Fprintf(&b, "\x1b_Ga=T,f=100,q=2,c=%d,m=%d;%s\x1b\\", columns, more, data[start:end])
+		} else {
+			fmt.Fprintf(&b, "\x1b_Gm=%d;%s\x1b\\", more, data[start:end])
+		}
+	}
+	return b.String(), nil
+}
+
+// sixelImage encodes img as sixel graphics, dithered to a 256 color
+// palette.
+func sixelImage(img image.Image) string {
+	bounds := img.Bounds()
+	paletted := image.NewPaletted(image.Rect(0, 0, bounds.Dx(), bounds.Dy()), palette.Plan9)
+	draw.FloydSteinberg.Draw(paletted, paletted.Bounds(), img, bounds.Min)
+	w, h := paletted.Rect.Dx(), paletted.Rect.Dy()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "\x1bPq\"1;1;%d;%d", w, h)
+	used := make([]bool, len(paletted.Palette))
+	for _, index := range paletted.Pix {
+		used[index] = true
+	}
+	for i, c := range paletted.Palette {
+		if !used[i] {
+			continue
+		}
+		r, g, bl, _ := c.RGBA()
+		fmt.Fprintf(&b, "#%d;2;%d;%d;%d", i, r*100/0xffff, g*100/0xffff, bl*100/0xffff)
+	}
+
+	row := make([]byte, w)
+	for top := 0; top < h; top += 6 {
+		inBand := make([]bool, len(paletted.Palette))
+		for y := top; y < min(top+6, h); y++ {
+			for x := 0; x < w; x++ {
+				inBand[paletted.ColorIndexAt(x, y)] = true
+			}
+		}
+		for index, present := range inBand {
+			if !present {
+				continue
+			}
+			for x := 0; x < w; x++ {
+				bits := byte(0)
+				for dy := 0; dy < 6 && top+dy < h; dy++ {
+					if int(paletted.ColorIndexAt(x, top+dy)) == index {
+						bits |= 1 << dy
+					}
+				}
+				row[x] = 63 + bits
+			}
+			fmt.Fprintf(&b, "#%d", index)
+			writeSixelRun(&b, row)
+			b.WriteByte('$')
+		}
+		b.WriteByte('-')
+	}
+	b.WriteString("\x1b\\")
+	return b.String()
+}
+
+// writeSixelRun writes sixel characters, compressing repeats as !<n><char>.
+func writeSixelRun(b *strings.Builder, row []byte) {
+	for i := 0; i < len(row); {
+		j := i
+		for j < len(row) && row[j] == row[i] {
+			j++
+		}
+		if n := j - i; n > 3 {
+			fmt.Fprintf(b, "!%d%c", n, row[i])
+		} else {
+			b.Write(row[i:j])
+		}
+		i = j
+	}
+}
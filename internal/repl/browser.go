@@ -0,0 +1,25 @@
+package repl
+
+import (
+	"os/exec"
+	"runtime"
+)
+
+// openURL opens target in the default browser without waiting for it.
+// Tests replace it.
+var openURL = func(target string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", target)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", target)
+	default:
+		cmd = exec.Command("xdg-open", target)
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go cmd.Wait()
+	return nil
+}
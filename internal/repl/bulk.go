@@ -0,0 +1,97 @@
+package repl
+
+import (
+	"context"
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"podsink/internal/app"
+)
+
+// episodeAction changes several episodes at once through a batched App
+// method.
+type episodeAction func(context.Context, []string) (app.CommandResult, error)
+
+// toggleEpisodeSelection selects or deselects the episode under the cursor.
+func (m *model) toggleEpisodeSelection() {
+	selected, ok := m.cursorEpisode()
+	if !ok {
+		return
+	}
+	if m.episodes.selected[selected.Episode.ID] {
+		delete(m.episodes.selected, selected.Episode.ID)
+		return
+	}
+	if m.episodes.selected == nil {
+		m.episodes.selected = make(map[string]bool)
+	}
+	m.episodes.selected[selected.Episode.ID] = true
+}
+
+// toggleAllVisibleEpisodes selects every episode the filter mode shows, or
+// clears the selection when all of them are selected already.
+func (m *model) toggleAllVisibleEpisodes() {
+	visible := m.visibleEpisodes()
+	all := len(visible) > 0
+	for _, result := range visible {
+		if !m.episodes.selected[result.Episode.ID] {
+			all = false
+			break
+		}
+	}
+	if all {
+		m.episodes.selected = nil
+		return
+	}
+	m.episodes.selected = make(map[string]bool, len(visible))
+	for _, result := range visible {
+		m.episodes.selected[result.Episode.ID] = true
+	}
+}
+
+// actionEpisodeIDs returns the IDs of the selected episodes in list order,
+// or that of the episode under the cursor when none are selected.
+func (m model) actionEpisodeIDs() []string {
+	if len(m.episodes.selected) == 0 {
+		if selected, ok := m.cursorEpisode(); ok {
+			return []string{selected.Episode.ID}
+		}
+		return nil
+	}
+	ids := make([]string, 0, len(m.episodes.selected))
+	for _, result := range m.episodes.results {
+		if m.episodes.selected[result.Episode.ID] {
+			ids = append(ids, result.Episode.ID)
+		}
+	}
+	return ids
+}
+
+// runEpisodeAction applies action to the selected episodes, clears the
+// selection and reloads the list, reporting the outcome as a toast.
+func (m model) runEpisodeAction(action episodeAction) (tea.Model, tea.Cmd) {
+	ids := m.actionEpisodeIDs()
+	if len(ids) == 0 {
+		return m, nil
+	}
+	result, err := action(m.ctx, ids)
+	if err != nil {
+		return m, m.showToast(fmt.Sprintf("Error: %v", err))
+	}
+	m.episodes.selected = nil
+	cursor, scroll := m.episodes.cursor, m.episodes.scroll
+	reloaded, err := m.refreshEpisodes()
+	if err != nil || len(reloaded.EpisodeResults) == 0 {
+		// Keep the list as it was
+		return m, m.showToast(result.Message)
+	}
+	updated, _ := m.handleCommandResult(reloaded)
+	m = updated.(model)
+	if visible := len(m.visibleEpisodes()); visible > 0 {
+		m.episodes.cursor = min(cursor, visible-1)
+		m.episodes.scroll = min(scroll, m.episodes.cursor)
+	}
+	m.refreshCounts()
+	return m, m.showToast(result.Message)
+}
@@ -0,0 +1,134 @@
+package repl
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/kballard/go-shellquote"
+
+	"podsink/internal/app"
+	"podsink/internal/repository"
+)
+
+// episodeOrders are the orders [o] steps through in the episode list.
+var episodeOrders = []string{"", repository.OrderShortest, repository.OrderLongest}
+
+// episodesCommand lists the episodes of args, a podcast ID or nothing, in
+// the order the episode list was last shown with.
+func (m model) episodesCommand(args string) string {
+	return pagedCommand(strings.Join(strings.Fields("episodes "+args+" "+m.episodes.order), " "), 0)
+}
+
+// loadEpisodeView restores the filter mode and order the episode list was
+// shown with in the last session.
+func (m *model) loadEpisodeView() {
+	view, err := m.app.EpisodeView(m.ctx)
+	if err != nil {
+		return
+	}
+	m.episodes.filterMode = view.Filter
+	m.episodes.order = view.Order
+}
+
+// saveEpisodeView keeps the filter mode and order of the episode list for
+// the next session.
+func (m *model) saveEpisodeView() tea.Cmd {
+	view := app.EpisodeView{Filter: m.episodes.filterMode, Order: m.episodes.order}
+	if err := m.app.SaveEpisodeView(m.ctx, view); err != nil {
+		return m.showToast(fmt.Sprintf("Could not save the episode view: %v", err))
+	}
+	return nil
+}
+
+// setEpisodeFilter lists the episodes of a filter mode and remembers it.
+// Choosing the active mode again goes back to hiding ignored episodes.
+func (m model) setEpisodeFilter(mode string) (tea.Model, tea.Cmd) {
+	if m.episodes.filterMode == mode {
+		mode = ""
+	}
+	m.episodes.filterMode = mode
+	save := m.saveEpisodeView()
+	result, err := m.refreshEpisodes()
+	if err != nil {
+		// Error: stay in episode list
+		return m, save
+	}
+	updated, cmd := m.handleCommandResult(result)
+	return updated, tea.Batch(save, cmd)
+}
+
+// cycleEpisodeOrder lists the episodes in the next order and remembers it.
+// Queries and searches keep the order they were run with.
+func (m model) cycleEpisodeOrder() (tea.Model, tea.Cmd) {
+	if m.episodes.filter != "" || m.episodes.search != "" {
+		return m, nil
+	}
+	next := episodeOrders[0]
+	for i, order := range episodeOrders {
+		if order == episodeQueryOrder(m.episodes.query) {
+			next = episodeOrders[(i+1)%len(episodeOrders)]
+		}
+	}
+	m.episodes.query = withEpisodeOrder(m.episodes.query, next)
+	result, err := m.refreshEpisodes()
+	if err != nil {
+		return m, nil
+	}
+	return m.handleCommandResult(result)
+}
+
+// episodeQueryOrder returns the order word of an episodes query, or "" for
+// newest first.
+func episodeQueryOrder(query string) string {
+	words, _ := splitEpisodeQuery(query)
+	order := ""
+	for _, word := range words {
+		if word == repository.OrderShortest || word == repository.OrderLongest {
+			order = word
+		}
+	}
+	return order
+}
+
+// withEpisodeOrder replaces the order of an episodes query.
+func withEpisodeOrder(query, order string) string {
+	words, values := splitEpisodeQuery(query)
+	kept := make([]string, 0, len(words)+len(values)+1)
+	for i, word := range words {
+		if word == repository.OrderShortest || word == repository.OrderLongest {
+			continue
+		}
+		kept = append(kept, word)
+		if value, ok := values[i]; ok {
+			kept = append(kept, value)
+		}
+	}
+	if order != "" {
+		kept = append(kept, order)
+	}
+	return shellquote.Join(kept...)
+}
+
+// splitEpisodeQuery splits an episodes query into its words, keeping the
+// values of options apart by the index of the word they follow, so that a
+// podcast title such as "longest" is not taken for an order.
+func splitEpisodeQuery(query string) ([]string, map[int]string) {
+	all, err := shellquote.Split(query)
+	if err != nil {
+		all = strings.Fields(query)
+	}
+	var words []string
+	values := make(map[int]string)
+	for i := 0; i < len(all); i++ {
+		words = append(words, all[i])
+		switch all[i] {
+		case "under", "over", "--state", "--podcast", "--since", "--limit", "--offset":
+			if i+1 < len(all) {
+				values[len(words)-1] = all[i+1]
+				i++
+			}
+		}
+	}
+	return words, values
+}
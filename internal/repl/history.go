@@ -0,0 +1,81 @@
+package repl
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"podsink/internal/app"
+	"podsink/internal/domain"
+)
+
+// historyView lists subscription changes, latest first, opened from the
+// main menu or by the history command.
+type historyView struct {
+	active bool
+	events []app.SubscriptionEvent
+	scroll int
+}
+
+func (m model) handleHistoryKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		m.quitting = true
+		return m, tea.Quit
+	case "esc", "x":
+		m.history = historyView{}
+		m.commandMenu.active = true
+		m.input.Blur()
+	case "up", "k":
+		if m.history.scroll > 0 {
+			m.history.scroll--
+		}
+	case "down", "j":
+		if m.history.scroll < len(m.history.events)-1 {
+			m.history.scroll++
+		}
+	}
+	return m, nil
+}
+
+func (m model) renderHistory() string {
+	var b strings.Builder
+	events := m.history.events
+	b.WriteString(m.theme.Header.Render(fmt.Sprintf("Subscription history (%d change(s))", len(events))))
+	b.WriteString("\n")
+	b.WriteString(m.theme.Dim.Render("Use ↑↓/jk to scroll, [x]/Esc to return to main menu"))
+	b.WriteString("\n\n")
+
+	if len(events) == 0 {
+		b.WriteString(m.theme.Dim.Render("No subscription changes recorded."))
+		b.WriteString("\n")
+		return b.String()
+	}
+
+	limit := m.app.Config().MaxEpisodes
+	end := min(m.history.scroll+limit, len(events))
+	for _, event := range events[m.history.scroll:end] {
+		line := fmt.Sprintf("%s  %-12s  %s", event.At.Local().Format("2006-01-02 15:04"), event.Action, event.Title)
+		if event.Action == domain.SubscriptionRemoved {
+			b.WriteString(m.theme.Error.Render(line))
+		} else {
+			b.WriteString(m.theme.Normal.Render(line))
+		}
+		b.WriteString("\n")
+		details := "  " + event.PodcastID
+		if event.Source != "" {
+			details += " via " + event.Source
+		}
+		if event.FeedURL != "" {
+			details += " · " + event.FeedURL
+		}
+		b.WriteString(m.theme.Dim.Render(details))
+		b.WriteString("\n")
+	}
+	if end < len(events) {
+		b.WriteString(m.theme.Dim.Render(fmt.Sprintf("… %d more", len(events)-end)))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,229 @@
+package repl
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"podsink/internal/app"
+)
+
+// importProgressView follows an OPML import started from the palette and
+// lists the outlines that failed once it is done, and the podcasts already
+// subscribed under another title or feed URL for the user to resolve. It can
+// be left while the import keeps running.
+type importProgressView struct {
+	active   bool // The view is shown
+	running  bool
+	done     int
+	total    int
+	imported int
+	failed   int
+	last     string // Title of the outline handled last
+	result   string // Summary of the finished import
+	failures []app.ImportFailure
+	scroll   int
+	// conflicts are resolved one at a time, the one at cursor first.
+	conflicts []app.ImportConflict
+	cursor    int
+}
+
+// importedMsg reports the outcome of an OPML import started from the palette.
+type importedMsg struct {
+	message   string
+	failures  []app.ImportFailure
+	conflicts []app.ImportConflict
+	err       error
+}
+
+// startImport runs the import command line in the background and shows its
+// progress. A running import is shown instead of starting another. Unless
+// the line says otherwise, conflicts are left for the user to resolve.
+func (m model) startImport(line string) (tea.Model, tea.Cmd) {
+	if !strings.Contains(line, "--on-conflict") {
+		line += " --on-conflict " + app.ConflictAsk
+	}
+	m.commandMenu.active = false
+	m.input.Blur()
+	if m.importProgress.running {
+		m.importProgress.active = true
+		return m, m.showToast("An import is already running.")
+	}
+	m.importProgress = importProgressView{active: true, running: true}
+	ctx, application := m.ctx, m.app
+	return m, func() tea.Msg {
+		result, err := application.Execute(ctx, line)
+		return importedMsg{message: result.Message, failures: result.ImportFailures, conflicts: result.ImportConflicts, err: err}
+	}
+}
+
+// trackImport updates the progress from an import event. Events older than
+// the progress shown are ignored.
+func (m *model) trackImport(event app.Event) {
+	p := &m.importProgress
+	if !p.running || event.FeedsDone < p.done {
+		return
+	}
+	p.done, p.total = event.FeedsDone, event.FeedsTotal
+	p.imported, p.failed = event.Added, event.Failed
+	if event.Title != "" {
+		p.last = event.Title
+	}
+}
+
+// finishImport shows the outcome of an import.
+func (m *model) finishImport(msg importedMsg) {
+	p := &m.importProgress
+	p.running = false
+	p.result = msg.message
+	if msg.err != nil {
+		p.result = fmt.Sprintf("Import failed: %v", msg.err)
+	}
+	p.failures = msg.failures
+	p.conflicts, p.cursor = msg.conflicts, 0
+	m.refreshCounts()
+}
+
+// resolveConflict applies choice to the selected conflict and drops it from
+// the list.
+func (m model) resolveConflict(choice string) (tea.Model, tea.Cmd) {
+	p := &m.importProgress
+	conflict := p.conflicts[p.cursor]
+	result, err := m.app.ResolveImportConflict(m.ctx, conflict, choice)
+	if err != nil {
+		return m, m.showToast(fmt.Sprintf("Resolve conflict failed: %v", err))
+	}
+	p.conflicts = slices.Delete(slices.Clone(p.conflicts), p.cursor, p.cursor+1)
+	p.cursor = min(p.cursor, max(len(p.conflicts)-1, 0))
+	return m, m.showToast(result.Message)
+}
+
+func (m model) handleImportProgressKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		m.quitting = true
+		return m, tea.Quit
+	case "esc", "x":
+		m.importProgress.active = false
+		m.commandMenu.active = true
+	case "l", "i", "m":
+		if len(m.importProgress.conflicts) == 0 {
+			break
+		}
+		choice := map[string]string{"l": app.ConflictKeep, "i": app.ConflictImported, "m": app.ConflictMerge}[msg.String()]
+		return m.resolveConflict(choice)
+	case "up", "k":
+		if len(m.importProgress.conflicts) > 0 {
+			m.importProgress.cursor = max(m.importProgress.cursor-1, 0)
+			break
+		}
+		if m.importProgress.scroll > 0 {
+			m.importProgress.scroll--
+		}
+	case "down", "j":
+		if len(m.importProgress.conflicts) > 0 {
+			m.importProgress.cursor = min(m.importProgress.cursor+1, len(m.importProgress.conflicts)-1)
+			break
+		}
+		if m.importProgress.scroll < len(m.importProgress.failures)-1 {
+			m.importProgress.scroll++
+		}
+	}
+	return m, nil
+}
+
+func (m model) renderImportProgress() string {
+	var b strings.Builder
+	p := m.importProgress
+	title := "Importing subscriptions"
+	if !p.running {
+		title = "Import finished"
+	}
+	b.WriteString(m.theme.Header.Render(title))
+	b.WriteString("\n")
+	hint := "[x]/Esc to return to main menu"
+	switch {
+	case p.running:
+		hint = "[x]/Esc to return to main menu; the import continues"
+	case len(p.conflicts) > 0:
+		hint = "Use ↑↓/jk to select, [l] keep local, [i] take imported, [m] merge, [x]/Esc to return to main menu"
+	case len(p.failures) > 0:
+		hint = "Use ↑↓/jk to scroll, [x]/Esc to return to main menu"
+	}
+	b.WriteString(m.theme.Dim.Render(hint))
+	b.WriteString("\n\n")
+
+	if p.total > 0 {
+		b.WriteString(m.theme.Normal.Render(fmt.Sprintf("Feeds: %d / %d", p.done, p.total)))
+		b.WriteString("\n")
+		b.WriteString(m.theme.State.Render(fmt.Sprintf("Imported: %d", p.imported)))
+		b.WriteString("\n")
+		b.WriteString(m.theme.Dim.Render(fmt.Sprintf("Skipped: %d", p.done-p.imported-p.failed)))
+		b.WriteString("\n")
+		failures := fmt.Sprintf("Failures: %d", p.failed)
+		if p.failed > 0 {
+			b.WriteString(m.theme.Error.Render(failures))
+		} else {
+			b.WriteString(m.theme.Dim.Render(failures))
+		}
+		b.WriteString("\n")
+		if p.running && p.last != "" {
+			b.WriteString(m.theme.Dim.Render("Last: " + p.last))
+			b.WriteString("\n")
+		}
+	} else if p.running {
+		b.WriteString(m.theme.Dim.Render("Starting..."))
+		b.WriteString("\n")
+	}
+	if p.result != "" {
+		b.WriteString("\n")
+		b.WriteString(m.theme.Normal.Render(p.result))
+		b.WriteString("\n")
+	}
+	if len(p.conflicts) > 0 {
+		b.WriteString("\n")
+		b.WriteString(m.theme.Header.Render(fmt.Sprintf("Subscribed under another title or feed URL: %d", len(p.conflicts))))
+		b.WriteString("\n")
+		limit := m.app.Config().MaxEpisodes
+		start := max(0, min(p.cursor-limit+1, len(p.conflicts)-limit))
+		end := min(start+limit, len(p.conflicts))
+		for i, conflict := range p.conflicts[start:end] {
+			style, cursor := m.theme.Normal, "  "
+			if start+i == p.cursor {
+				style, cursor = m.theme.Cursor, "→ "
+			}
+			b.WriteString(style.Render(cursor + conflict.Title))
+			b.WriteString("\n")
+			b.WriteString(m.theme.Dim.Render(fmt.Sprintf("    here:     %s (%s)", conflict.Title, conflict.FeedURL)))
+			b.WriteString("\n")
+			imported := conflict.ImportedTitle
+			if imported == "" {
+				imported = conflict.Title
+			}
+			b.WriteString(m.theme.Dim.Render(fmt.Sprintf("    imported: %s (%s)", imported, conflict.ImportedFeedURL)))
+			b.WriteString("\n")
+		}
+		if end < len(p.conflicts) {
+			b.WriteString(m.theme.Dim.Render(fmt.Sprintf("… %d more", len(p.conflicts)-end)))
+			b.WriteString("\n")
+		}
+	}
+	if len(p.failures) > 0 {
+		b.WriteString("\n")
+		limit := m.app.Config().MaxEpisodes
+		end := min(p.scroll+limit, len(p.failures))
+		for _, failure := range p.failures[p.scroll:end] {
+			b.WriteString(m.theme.Normal.Render(failure.Title))
+			b.WriteString("\n")
+			b.WriteString(m.theme.Dim.Render("  " + failure.Err))
+			b.WriteString("\n")
+		}
+		if end < len(p.failures) {
+			b.WriteString(m.theme.Dim.Render(fmt.Sprintf("… %d more", len(p.failures)-end)))
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
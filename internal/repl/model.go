@@ -1,16 +1,26 @@
 package repl
 
 import (
+	"cmp"
 	"context"
 	"fmt"
+	"math"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/jaytaylor/html2text"
+	"github.com/kballard/go-shellquote"
 
 	"podsink/internal/app"
+	"podsink/internal/config"
+	"podsink/internal/domain"
 	"podsink/internal/itunes"
 	"podsink/internal/theme"
 )
@@ -26,8 +36,10 @@ type searchView struct {
 }
 
 type detailView struct {
-	active  bool
-	podcast app.SearchResult
+	active      bool
+	podcast     app.SearchResult
+	artworkPath string // Cached artwork file, once fetched
+	artworkErr  error
 }
 
 type episodeView struct {
@@ -36,7 +48,15 @@ type episodeView struct {
 	cursor     int
 	scroll     int
 	details    episodeDetailView
-	filterMode string // "all", "ignored", "downloaded", or "" (default: not ignored)
+	filterMode string          // "all", "ignored", "downloaded", or "" (default: not ignored)
+	query      string          // duration filter and order, e.g. "under 30 shortest"
+	order      string          // Order of the last episodes listing, kept across sessions
+	filter     string          // expression of a query command, listed instead of all episodes
+	search     string          // terms of a search episodes command, listed instead of all episodes
+	playlist   string          // name of the playlist whose query is the filter
+	more       bool            // Further pages of episodes are not loaded yet
+	podcast    string          // ID of the podcast listed, opened from the subscriptions
+	selected   map[string]bool // IDs of the episodes bulk actions apply to
 }
 
 type episodeDetailView struct {
@@ -58,6 +78,7 @@ type downloadsView struct {
 	danglingFiles []app.DanglingFile
 	cursor        int
 	scroll        int
+	confirmDelete string // Episode awaiting a second delete key press
 }
 
 type commandMenuItem struct {
@@ -87,18 +108,53 @@ type model struct {
 	episodes        episodeView
 	queue           queueView
 	downloads       downloadsView
+	secrets         secretsView
+	refreshErrors   refreshErrorsView
+	refreshProgress refreshProgressView
+	importProgress  importProgressView
+	history         historyView
+	palette         paletteView
+
+	recentCommands      []string // Command lines run from the palette, oldest first
+	subscriptionsBySize bool     // List subscriptions by disk usage rather than title
 
 	queueCount     int
 	downloadsCount int
+	newCount       int // Episodes in NEW state, badged next to episodes
+	failedCount    int // Queued episodes whose download failed, badged next to queue
+
+	events   <-chan app.Event
+	progress map[string]app.Event // Latest progress per downloading episode
+	toast    string               // Transient notification shown below the current view
+	toastSeq int
 
 	longDescCache map[string]string
 }
 
+const toastDuration = 4 * time.Second
+
+type appEventMsg app.Event
+
+type toastExpiredMsg struct{ seq int }
+
+// refreshedMsg reports the outcome of a feed refresh started from a view.
+type refreshedMsg struct {
+	message  string
+	failures []app.RefreshFailure
+	err      error
+}
+
+// queueProcessedMsg reports the outcome of a foreground "queue process" run.
+type queueProcessedMsg struct {
+	message string
+	err     error
+}
+
 func newModel(ctx context.Context, application *app.App) model {
 	cfg := application.Config()
 	th := theme.ForName(cfg.ColorTheme)
 	ti := textinput.New()
-	ti.Placeholder = "Enter podcast search query..."
+	ti.Placeholder = "Enter podcast search query, or episodes <words>..."
 	ti.Blur() // Start with menu, not input
 	ti.Prompt = "search> "
 	ti.CharLimit = 512
@@ -106,14 +162,23 @@ func newModel(ctx context.Context, application *app.App) model {
 
 	// Build command menu items
 	commandItems := []commandMenuItem{
-		{name: "search", usage: "search", description: "Search for podcasts via the iTunes API", shorthand: "[s]"},
+		{name: "search", usage: "search", description: "Search for podcasts, or episodes by title and show notes", shorthand: "[s]"},
 		{name: "list", usage: "podcasts", description: "List all podcast subscriptions", shorthand: "[p]"},
-		{name: "episodes", usage: "episodes", description: "View recent episodes across subscriptions", shorthand: "[e]"},
+		{name: "episodes", usage: "episodes [under <min>] [over <min>] [shortest|longest]", description: "View recent episodes across subscriptions", shorthand: "[e]"},
 		{name: "queue", usage: "queue", description: "View download queue status", shorthand: "[q]"},
 		{name: "downloads", usage: "downloads", description: "View all downloaded episodes", shorthand: "[d]"},
+		{name: "refresh", usage: "refresh", description: "Refresh all feeds and follow the progress", shorthand: "[r]"},
+		{name: "history", usage: "history", description: "Show when subscriptions were added and removed", shorthand: "[h]"},
+	}
+	// Saved queries follow the views
+	for _, playlist := range cfg.Playlists {
+		commandItems = append(commandItems, commandMenuItem{name: "playlist " + shellquote.Join(playlist.Name), usage: "playlist " + playlist.Name, description: playlist.Query})
+	}
+	commandItems = append(commandItems, []commandMenuItem{
 		{name: "config", usage: "config [show]", description: "View or edit application configuration", shorthand: "[c]"},
+		{name: "secrets", usage: "secrets", description: "Enter API keys and passwords with masked input", shorthand: "[a]"},
 		{name: "exit", usage: "exit", description: "Exit the application", shorthand: "[x]"},
-	}
+	}...)
 
 	m := model{
 		ctx:   ctx,
@@ -125,17 +190,167 @@ func newModel(ctx context.Context, application *app.App) model {
 			items:  commandItems,
 			cursor: 0,
 		},
+		progress:      make(map[string]app.Event),
 		longDescCache: make(map[string]string),
 	}
+	m.events, _ = application.Events().Subscribe()
+	m.loadEpisodeView()
 
 	// Fetch initial counts
 	m.refreshCounts()
 
-	return m
+	return m.openStartupView(cfg.StartupView)
+}
+
+// openStartupView opens the view startup_view names in place of the command
+// menu. The menu stays when the view cannot be loaded or has nothing to list.
+func (m model) openStartupView(view string) model {
+	var command string
+	switch view {
+	case config.ViewEpisodes:
+		command = m.episodesCommand("")
+	case config.ViewQueue:
+		command = "queue"
+	case config.ViewDownloads:
+		command = "downloads"
+	case config.ViewSubscriptions:
+		command = "list subscriptions"
+	default:
+		return m
+	}
+	result, err := m.app.Execute(m.ctx, command)
+	if err != nil {
+		return m
+	}
+	m.commandMenu.active = false
+	updated, _ := m.handleCommandResult(result)
+	return updated.(model)
 }
 
 func (m model) Init() tea.Cmd {
-	return textinput.Blink
+	return tea.Batch(textinput.Blink, m.waitForEvent())
+}
+
+// waitForEvent blocks until a service publishes an event on the app's bus.
+func (m model) waitForEvent() tea.Cmd {
+	if m.events == nil {
+		return nil
+	}
+	events := m.events
+	return func() tea.Msg {
+		event, ok := <-events
+		if !ok {
+			return nil
+		}
+		return appEventMsg(event)
+	}
+}
+
+func (m model) handleAppEvent(event appEventMsg) (tea.Model, tea.Cmd) {
+	cmds := []tea.Cmd{m.waitForEvent()}
+
+	switch event.Kind {
+	case app.EventDownloadProgress:
+		m.progress[event.EpisodeID] = app.Event(event)
+		return m, tea.Batch(cmds...)
+	case app.EventRefreshProgress:
+		m.trackRefresh(app.Event(event))
+		return m, tea.Batch(cmds...)
+	case app.EventImportProgress:
+		m.trackImport(app.Event(event))
+		return m, tea.Batch(cmds...)
+	case app.EventSubscribed, app.EventEpisodeAdded, app.EventDownloadStarted:
+		// Followed by a refresh, state or progress event that updates the views
+		return m, tea.Batch(cmds...)
+	case app.EventEpisodeState:
+		// Paused and cancelled transfers stop without a finished event
+		delete(m.progress, event.EpisodeID)
+	case app.EventFilesChanged:
+		// Files were added or removed outside podsink
+		if m.downloads.active {
+			m.reloadDownloads()
+		}
+	case app.EventDownloadFinished:
+		delete(m.progress, event.EpisodeID)
+		title := event.Title
+		if title == "" {
+			title = event.EpisodeID
+		}
+		switch {
+		case event.State == "SKIPPED":
+			cmds = append(cmds, m.showToast(fmt.Sprintf("Download skipped: %s (over max_episode_size_mb)", title)))
+		case event.Err != nil:
+			cmds = append(cmds, m.showToast(fmt.Sprintf("Download failed: %s", title)))
+		case event.Bytes > 0:
+			cmds = append(cmds, m.showToast(fmt.Sprintf("Downloaded: %s (%.0f MB)", title, float64(event.Bytes)/(1024*1024))))
+		default:
+			cmds = append(cmds, m.showToast(fmt.Sprintf("Downloaded: %s", title)))
+		}
+	}
+
+	m.refreshCounts()
+	m.reloadQueue()
+	return m, tea.Batch(cmds...)
+}
+
+// startRefresh fetches one feed, or all when podcastID is empty, in the background.
+func (m *model) startRefresh(podcastID string) tea.Cmd {
+	command := "refresh"
+	if podcastID != "" {
+		command += " " + podcastID
+	} else {
+		m.refreshProgress = refreshProgressView{active: m.refreshProgress.active, running: true}
+	}
+	ctx, application := m.ctx, m.app
+	return tea.Batch(m.showToast("Refreshing feeds..."), func() tea.Msg {
+		result, err := application.Execute(ctx, command)
+		return refreshedMsg{message: result.Message, failures: result.RefreshFailures, err: err}
+	})
+}
+
+// showToast displays text below the current view and schedules its removal.
+func (m *model) showToast(text string) tea.Cmd {
+	m.toast = text
+	m.toastSeq++
+	seq := m.toastSeq
+	return tea.Tick(toastDuration, func(time.Time) tea.Msg { return toastExpiredMsg{seq: seq} })
+}
+
+// reloadDownloads refreshes the downloads view in place, keeping the cursor in range.
+func (m *model) reloadDownloads() {
+	if result, err := m.app.Execute(m.ctx, "downloads"); err == nil {
+		m.downloads.results = result.DownloadedEpisodeResults
+		m.downloads.danglingFiles = result.DanglingFiles
+		if total := len(m.downloads.results) + len(m.downloads.danglingFiles); m.downloads.cursor >= total {
+			m.downloads.cursor = max(total-1, 0)
+		}
+		if m.downloads.scroll > m.downloads.cursor {
+			m.downloads.scroll = m.downloads.cursor
+		}
+	}
+}
+
+// selectedDanglingFile returns the dangling file under the cursor, which
+// moves on to the dangling files after the last downloaded episode.
+func (m *model) selectedDanglingFile() (app.DanglingFile, bool) {
+	i := m.downloads.cursor - len(m.downloads.results)
+	if i < 0 || i >= len(m.downloads.danglingFiles) {
+		return app.DanglingFile{}, false
+	}
+	return m.downloads.danglingFiles[i], true
+}
+
+// reloadQueue refreshes the queue view in place, keeping the cursor in range.
+func (m *model) reloadQueue() {
+	if !m.queue.active {
+		return
+	}
+	if result, err := m.app.Execute(m.ctx, "queue"); err == nil {
+		m.queue.results = result.QueuedEpisodeResults
+		if m.queue.cursor >= len(m.queue.results) {
+			m.queue.cursor = max(len(m.queue.results)-1, 0)
+		}
+	}
 }
 
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -148,7 +363,97 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.episodes.details.scroll = 0
 		}
 		return m, nil
+	case appEventMsg:
+		return m.handleAppEvent(msg)
+	case refreshedMsg:
+		text := msg.message
+		if msg.err != nil {
+			text = fmt.Sprintf("Refresh failed: %v", msg.err)
+		}
+		toast := m.showToast(text)
+		m.refreshCounts()
+		m.refreshProgress.running = false
+		m.refreshProgress.result = text
+		if len(msg.failures) > 0 {
+			m.refreshErrors = refreshErrorsView{active: true, failures: msg.failures}
+		}
+		if m.search.active && m.search.context == "subscriptions" && !m.search.details.active {
+			if result, err := m.app.Execute(m.ctx, "list subscriptions"); err == nil && len(result.SearchResults) > 0 {
+				cursor := min(m.search.cursor, len(result.SearchResults)-1)
+				updated, cmd := m.handleCommandResult(result)
+				next := updated.(model)
+				next.search.cursor = cursor
+				return next, tea.Batch(toast, cmd)
+			}
+		}
+		if m.episodes.active && !m.episodes.details.active {
+			if result, err := m.refreshEpisodes(); err == nil {
+				updated, cmd := m.handleCommandResult(result)
+				return updated, tea.Batch(toast, cmd)
+			}
+		}
+		return m, toast
+	case importedMsg:
+		m.finishImport(msg)
+		if !m.importProgress.active {
+			return m, m.showToast(m.importProgress.result)
+		}
+		return m, nil
+	case queueProcessedMsg:
+		text := msg.message
+		if msg.err != nil {
+			text = fmt.Sprintf("Processing queue failed: %v", msg.err)
+		}
+		m.refreshCounts()
+		m.reloadQueue()
+		return m, m.showToast(text)
+	case artworkLoadedMsg:
+		if m.search.details.active && m.search.details.podcast.Podcast.ID == msg.podcastID {
+			m.search.details.artworkPath, m.search.details.artworkErr = msg.path, msg.err
+		}
+		return m, nil
+	case artworkClosedMsg:
+		if msg.err != nil {
+			return m, m.showToast(fmt.Sprintf("Could not show artwork: %v", msg.err))
+		}
+		return m, nil
+	case toastExpiredMsg:
+		if msg.seq == m.toastSeq {
+			m.toast = ""
+		}
+		return m, nil
 	case tea.KeyMsg:
+		if m.palette.active {
+			return m.handlePaletteKey(msg)
+		}
+		if msg.String() == "ctrl+p" && !m.secrets.editing {
+			return m.openPalette()
+		}
+
+		if m.readOnlyKey(msg.String()) {
+			return m, m.showToast("Read-only mode: changes are disabled.")
+		}
+
+		if m.refreshErrors.active {
+			return m.handleRefreshErrorsKey(msg)
+		}
+
+		if m.refreshProgress.active {
+			return m.handleRefreshProgressKey(msg)
+		}
+
+		if m.importProgress.active {
+			return m.handleImportProgressKey(msg)
+		}
+
+		if command, ok := m.viewShortcut(msg.String()); ok {
+			return m.openView(command)
+		}
+
+		if m.history.active {
+			return m.handleHistoryKey(msg)
+		}
+
 		// Handle command menu mode navigation
 		if m.commandMenu.active {
 			switch msg.String() {
@@ -174,37 +479,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			case "enter":
 				// Execute selected command
 				if m.commandMenu.cursor < len(m.commandMenu.items) {
-					selectedItem := m.commandMenu.items[m.commandMenu.cursor]
-					m.commandMenu.active = false
-					m.input.Focus()
-
-					// For commands that need arguments, prompt for input
-					switch selectedItem.name {
-					case "search":
-						// Enter search input mode
-						m.searchInputMode = true
-						m.input.Prompt = "search> "
-						m.input.Placeholder = "Enter podcast search query..."
-						m.input.SetValue("")
-						m.input.SetCursor(0)
-						return m, nil
-					case "list":
-						// Execute "list subscriptions" directly
-						result, err := m.app.Execute(m.ctx, "list subscriptions")
-						if err != nil {
-							// Error: return to menu
-							return m, nil
-						}
-						return m.handleCommandResult(result)
-					default:
-						// Execute the command directly
-						result, err := m.app.Execute(m.ctx, selectedItem.name)
-						if err != nil {
-							// Error: return to menu
-							return m, nil
-						}
-						return m.handleCommandResult(result)
-					}
+					return m.runMenuItem(m.commandMenu.items[m.commandMenu.cursor].name)
 				}
 				return m, nil
 			case "s":
@@ -213,70 +488,19 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.searchInputMode = true
 				m.input.Focus()
 				m.input.Prompt = "search> "
-				m.input.Placeholder = "Enter podcast search query..."
+				m.input.Placeholder = "Enter podcast search query, or episodes <words>..."
 				m.input.SetValue("")
 				m.input.SetCursor(0)
 				return m, nil
-			case "p":
-				// Shortcut for list podcasts
-				m.commandMenu.active = false
-				m.input.Focus()
-				result, err := m.app.Execute(m.ctx, "list subscriptions")
-				if err != nil {
-					// Error: return to menu
-					m.commandMenu.active = true
-					m.input.Blur()
-					return m, nil
-				}
-				return m.handleCommandResult(result)
-			case "e":
-				// Shortcut for episodes
-				m.commandMenu.active = false
-				m.input.Focus()
-				result, err := m.app.Execute(m.ctx, "episodes")
-				if err != nil {
-					// Error: return to menu
-					m.commandMenu.active = true
-					m.input.Blur()
-					return m, nil
-				}
-				return m.handleCommandResult(result)
-			case "c":
-				// Shortcut for config
-				m.commandMenu.active = false
-				m.input.Focus()
-				result, err := m.app.Execute(m.ctx, "config")
-				if err != nil {
-					// Error: return to menu
-					m.commandMenu.active = true
-					m.input.Blur()
-					return m, nil
-				}
-				return m.handleCommandResult(result)
-			case "q":
-				// Shortcut for queue
-				m.commandMenu.active = false
-				m.input.Focus()
-				result, err := m.app.Execute(m.ctx, "queue")
-				if err != nil {
-					// Error: return to menu
-					m.commandMenu.active = true
-					m.input.Blur()
-					return m, nil
-				}
-				return m.handleCommandResult(result)
-			case "d":
-				// Shortcut for downloads
-				m.commandMenu.active = false
-				m.input.Focus()
-				result, err := m.app.Execute(m.ctx, "downloads")
-				if err != nil {
-					// Error: return to menu
-					m.commandMenu.active = true
-					m.input.Blur()
-					return m, nil
-				}
-				return m.handleCommandResult(result)
+			case "p", "e", "c", "q", "d", "h":
+				// Shortcuts for the list views and config
+				return m.openView(viewShortcuts[msg.String()])
+			case "a":
+				// Shortcut for secrets
+				return m.openSecrets()
+			case "r":
+				// Shortcut for refresh; shows a running refresh instead of starting another
+				return m.openRefreshProgress()
 			}
 			return m, nil
 		}
@@ -297,6 +521,24 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			case "u":
 				// Unsubscribe from podcast
 				return m.handleSearchUnsubscribe()
+			case "o":
+				// Open the first support link in the browser
+				funding := m.search.details.podcast.Funding
+				if len(funding) == 0 {
+					return m, nil
+				}
+				if err := openURL(funding[0].URL); err != nil {
+					return m, m.showToast(fmt.Sprintf("Could not open browser: %v", err))
+				}
+				return m, m.showToast("Opened " + funding[0].URL)
+			case "a":
+				// Draw the artwork while the terminal is released
+				protocol := artworkProtocol(m.app.Config().ArtworkProtocol)
+				if m.search.details.artworkPath == "" || protocol == config.ArtworkNone {
+					return m, nil
+				}
+				viewer := &artworkViewer{path: m.search.details.artworkPath, protocol: protocol}
+				return m, tea.Exec(viewer, func(err error) tea.Msg { return artworkClosedMsg{err: err} })
 			}
 			return m, nil
 		}
@@ -339,6 +581,8 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			case "home":
 				m.episodes.details.scroll = 0
 				return m, nil
+			case "1", "2", "3", "4", "5", "6", "7", "8", "9":
+				return m, m.playSoundbite(int(msg.String()[0] - '1'))
 			}
 			return m, nil
 		}
@@ -372,10 +616,15 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				}
 				return m, nil
 			case "enter":
+				// A subscription opens its episodes
+				if m.search.context == "subscriptions" && m.search.cursor < len(m.search.results) {
+					return m.openPodcastEpisodes(m.search.results[m.search.cursor].Podcast.ID)
+				}
+				fallthrough
+			case "i":
 				// Enter details mode for selected podcast
 				if m.search.cursor < len(m.search.results) {
-					m.search.details.active = true
-					m.search.details.podcast = m.search.results[m.search.cursor]
+					m.search.details = detailView{active: true, podcast: m.search.results[m.search.cursor]}
 
 					// Fetch long description if not already cached
 					podcastID := m.search.details.podcast.Podcast.ID
@@ -390,6 +639,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 						// Use cached long description
 						m.search.details.podcast.Podcast.LongDescription = m.longDescCache[podcastID]
 					}
+					return m, m.loadArtwork(m.search.details.podcast.Podcast)
 				}
 				return m, nil
 			case "s":
@@ -398,6 +648,19 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			case "u":
 				// Unsubscribe directly from list view
 				return m.handleSearchUnsubscribe()
+			case "r":
+				// Refresh the selected subscription's feed
+				if m.search.context == "subscriptions" && m.search.cursor < len(m.search.results) {
+					return m, m.startRefresh(m.search.results[m.search.cursor].Podcast.ID)
+				}
+				return m, nil
+			case "o":
+				// Toggle between title and disk usage order
+				if m.search.context == "subscriptions" {
+					m.subscriptionsBySize = !m.subscriptionsBySize
+					m.sortSubscriptions()
+				}
+				return m, nil
 			}
 			return m, nil
 		}
@@ -409,6 +672,14 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.quitting = true
 				return m, tea.Quit
 			case "esc", "q", "x":
+				if msg.String() == "esc" && len(m.episodes.selected) > 0 {
+					m.episodes.selected = nil
+					return m, nil
+				}
+				if podcastID := m.episodes.podcast; podcastID != "" {
+					// Return to the subscriptions the podcast was opened from
+					return m.returnToSubscription(podcastID)
+				}
 				// Exit episode mode - return to main menu
 				m.episodes.active = false
 				m.episodes.results = nil
@@ -420,8 +691,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.input.Blur()
 				return m, nil
 			case "enter":
-				if m.episodes.cursor < len(m.episodes.results) {
-					selected := m.episodes.results[m.episodes.cursor]
+				if selected, ok := m.cursorEpisode(); ok {
 					detail, err := m.app.EpisodeDetails(m.ctx, selected.Episode.ID)
 					if err != nil {
 						// Error: stay in episode list
@@ -445,7 +715,10 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				}
 				return m, nil
 			case "down", "j":
-				if m.episodes.cursor < len(m.episodes.results)-1 {
+				if m.episodes.more && m.episodes.cursor >= len(m.visibleEpisodes())-1 {
+					m.loadMoreEpisodes()
+				}
+				if m.episodes.cursor < len(m.visibleEpisodes())-1 {
 					m.episodes.cursor++
 					// Scroll down when cursor moves below visible window
 					cfg := m.app.Config()
@@ -458,17 +731,33 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					}
 				}
 				return m, nil
+			case " ":
+				// Select or deselect the episode and move on
+				m.toggleEpisodeSelection()
+				if m.episodes.cursor < len(m.visibleEpisodes())-1 {
+					return m.Update(tea.KeyMsg{Type: tea.KeyDown})
+				}
+				return m, nil
+			case "*":
+				m.toggleAllVisibleEpisodes()
+				return m, nil
+			case "s":
+				return m.runEpisodeAction(m.app.MarkEpisodesSeen)
+			case "delete":
+				return m.runEpisodeAction(m.app.DeleteEpisodes)
 			case "i":
+				if len(m.episodes.selected) > 0 {
+					return m.runEpisodeAction(m.app.IgnoreEpisodes)
+				}
 				// Ignore/unignore the selected episode
-				if m.episodes.cursor < len(m.episodes.results) {
-					selected := m.episodes.results[m.episodes.cursor]
+				if selected, ok := m.cursorEpisode(); ok {
 					_, err := m.app.Execute(m.ctx, "ignore "+selected.Episode.ID)
 					if err != nil {
 						// Error: stay in episode list
 						return m, nil
 					}
 					// Refresh the episode list
-					result, err := m.app.Execute(m.ctx, "episodes")
+					result, err := m.refreshEpisodes()
 					if err != nil {
 						// Error: stay in episode list
 						return m, nil
@@ -476,47 +765,34 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					return m.handleCommandResult(result)
 				}
 				return m, nil
-			case "a":
+			case "a", "A":
 				// Show all episodes
-				m.episodes.filterMode = "all"
-				// Refresh the episode list
-				result, err := m.app.Execute(m.ctx, "episodes")
-				if err != nil {
-					// Error: stay in episode list
-					return m, nil
-				}
-				return m.handleCommandResult(result)
-			case "shift+i":
+				return m.setEpisodeFilter(app.EpisodeFilterAll)
+			case "I", "shift+i":
 				// Show only ignored episodes
-				m.episodes.filterMode = "ignored"
-				// Refresh the episode list
-				result, err := m.app.Execute(m.ctx, "episodes")
-				if err != nil {
-					// Error: stay in episode list
-					return m, nil
-				}
-				return m.handleCommandResult(result)
-			case "shift+d":
+				return m.setEpisodeFilter(app.EpisodeFilterIgnored)
+			case "D", "shift+d":
 				// Show only downloaded episodes
-				m.episodes.filterMode = "downloaded"
-				// Refresh the episode list
-				result, err := m.app.Execute(m.ctx, "episodes")
-				if err != nil {
-					// Error: stay in episode list
-					return m, nil
-				}
-				return m.handleCommandResult(result)
+				return m.setEpisodeFilter(app.EpisodeFilterDownloaded)
+			case "o":
+				// Show the next order
+				return m.cycleEpisodeOrder()
+			case "r":
+				// Refresh all feeds
+				return m, m.startRefresh("")
 			case "d":
+				if len(m.episodes.selected) > 0 {
+					return m.runEpisodeAction(m.app.QueueEpisodes)
+				}
 				// Download/queue the selected episode for download
-				if m.episodes.cursor < len(m.episodes.results) {
-					selected := m.episodes.results[m.episodes.cursor]
+				if selected, ok := m.cursorEpisode(); ok {
 					_, err := m.app.Execute(m.ctx, "queue "+selected.Episode.ID)
 					if err != nil {
 						// Error: stay in episode list
 						return m, nil
 					}
 					// Refresh the episode list
-					result, err := m.app.Execute(m.ctx, "episodes")
+					result, err := m.refreshEpisodes()
 					if err != nil {
 						// Error: stay in episode list
 						return m, nil
@@ -552,13 +828,72 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					m.queue.cursor++
 				}
 				return m, nil
+			case "p":
+				// Download the queue in the foreground when no workers are running
+				if m.app.DownloadWorkers() > 0 || len(m.queue.results) == 0 {
+					return m, nil
+				}
+				ctx, application := m.ctx, m.app
+				return m, tea.Batch(m.showToast("Processing download queue..."), func() tea.Msg {
+					result, err := application.Execute(ctx, "queue process")
+					return queueProcessedMsg{message: result.Message, err: err}
+				})
+			case " ", "c":
+				// Pause/resume or cancel the selected download
+				if m.queue.cursor >= len(m.queue.results) {
+					return m, nil
+				}
+				selected := m.queue.results[m.queue.cursor]
+				action := "cancel"
+				if msg.String() == " " {
+					action = "pause"
+					if selected.Paused {
+						action = "resume"
+					}
+				}
+				result, err := m.app.Execute(m.ctx, "queue "+action+" "+selected.Episode.ID)
+				m.reloadQueue()
+				m.refreshCounts()
+				if err != nil {
+					return m, m.showToast(fmt.Sprintf("Error: %v", err))
+				}
+				return m, m.showToast(result.Message)
+			case "+", "-":
+				// Bump or demote the selected download one priority level
+				if m.queue.cursor >= len(m.queue.results) {
+					return m, nil
+				}
+				selected := m.queue.results[m.queue.cursor]
+				priority := selected.Priority + 1
+				if msg.String() == "-" {
+					priority = selected.Priority - 1
+				}
+				if priority < domain.PriorityLow || priority > domain.PriorityHigh {
+					return m, nil
+				}
+				result, err := m.app.Execute(m.ctx, "queue "+selected.Episode.ID+" --priority "+domain.PriorityName(priority))
+				m.reloadQueue()
+				// Keep the cursor on the episode as it moves in the queue.
+				for i, r := range m.queue.results {
+					if r.Episode.ID == selected.Episode.ID {
+						m.queue.cursor = i
+					}
+				}
+				if err != nil {
+					return m, m.showToast(fmt.Sprintf("Error: %v", err))
+				}
+				return m, m.showToast(result.Message)
 			}
 			return m, nil
 		}
 
 		// Handle downloads mode navigation
 		if m.downloads.active {
-			switch msg.String() {
+			key := msg.String()
+			if key != "D" && key != "delete" && key != "d" {
+				m.downloads.confirmDelete = ""
+			}
+			switch key {
 			case "ctrl+c":
 				m.quitting = true
 				return m, tea.Quit
@@ -587,7 +922,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				}
 				return m, nil
 			case "down", "j":
-				if m.downloads.cursor < len(m.downloads.results)-1 {
+				if m.downloads.cursor < len(m.downloads.results)+len(m.downloads.danglingFiles)-1 {
 					m.downloads.cursor++
 					// Scroll down when cursor moves below visible window
 					cfg := m.app.Config()
@@ -595,15 +930,58 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					if maxVisible <= 0 {
 						maxVisible = 12
 					}
-					if m.downloads.cursor >= m.downloads.scroll+maxVisible {
+					// Dangling files are listed below the window and do not scroll it
+					if m.downloads.cursor < len(m.downloads.results) && m.downloads.cursor >= m.downloads.scroll+maxVisible {
 						m.downloads.scroll = m.downloads.cursor - maxVisible + 1
 					}
 				}
 				return m, nil
+			case "D", "delete", "d":
+				// Delete the selected episode's file or dangling file; the
+				// first press asks for confirmation
+				var target, label, command string
+				if dangling, ok := m.selectedDanglingFile(); ok {
+					target, label = dangling.Path, filepath.Base(dangling.Path)
+					command = "dangling delete " + shellquote.Join(dangling.Path)
+				} else if m.downloads.cursor < len(m.downloads.results) {
+					selected := m.downloads.results[m.downloads.cursor].Episode
+					target, label = selected.ID, selected.Title
+					command = "delete " + selected.ID
+				} else {
+					return m, nil
+				}
+				if m.downloads.confirmDelete != target {
+					m.downloads.confirmDelete = target
+					return m, m.showToast(fmt.Sprintf("Press %s again to delete %s", key, label))
+				}
+				m.downloads.confirmDelete = ""
+				result, err := m.app.Execute(m.ctx, command)
+				m.reloadDownloads()
+				if err != nil {
+					return m, m.showToast(fmt.Sprintf("Error: %v", err))
+				}
+				return m, m.showToast(result.Message)
+			case "a":
+				// Adopt the selected dangling file as the download of the matching episode
+				dangling, ok := m.selectedDanglingFile()
+				if !ok {
+					return m, nil
+				}
+				result, err := m.app.Execute(m.ctx, "dangling adopt "+shellquote.Join(dangling.Path))
+				m.reloadDownloads()
+				m.refreshCounts()
+				if err != nil {
+					return m, m.showToast(fmt.Sprintf("Error: %v", err))
+				}
+				return m, m.showToast(result.Message)
 			}
 			return m, nil
 		}
 
+		if m.secrets.active {
+			return m.handleSecretsKey(msg)
+		}
+
 		// Handle search input mode
 		if m.searchInputMode {
 			switch msg.Type {
@@ -631,7 +1009,11 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					return m, nil
 				}
 
-				result, err := m.app.Execute(m.ctx, "search "+query)
+				command := "search " + query
+				if words := strings.Fields(query); strings.EqualFold(words[0], "episodes") {
+					command = pagedCommand(command, 0)
+				}
+				result, err := m.app.Execute(m.ctx, command)
 				if err != nil {
 					// On error, return to command menu
 					m.commandMenu.active = true
@@ -652,6 +1034,34 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 }
 
 func (m model) View() string {
+	view := m.viewContent()
+	if m.toast == "" {
+		return view
+	}
+	return view + "\n" + m.theme.Message.Render(m.toast) + "\n"
+}
+
+func (m model) viewContent() string {
+	if m.palette.active {
+		return m.renderPalette()
+	}
+
+	if m.refreshErrors.active {
+		return m.renderRefreshErrors()
+	}
+
+	if m.refreshProgress.active {
+		return m.renderRefreshProgress()
+	}
+
+	if m.importProgress.active {
+		return m.renderImportProgress()
+	}
+
+	if m.history.active {
+		return m.renderHistory()
+	}
+
 	// If in command menu mode, render the menu
 	if m.commandMenu.active {
 		return m.renderCommandMenu()
@@ -662,13 +1072,17 @@ func (m model) View() string {
 		var b strings.Builder
 		b.WriteString(m.theme.Header.Render("Search for Podcasts"))
 		b.WriteString("\n")
-		b.WriteString(m.theme.Dim.Render("Enter search query (Esc to cancel):"))
+		b.WriteString(m.theme.Dim.Render("Enter search query, or episodes <words> to find episodes by title and show notes (Esc to cancel):"))
 		b.WriteString("\n\n")
 		b.WriteString(m.input.View())
 		b.WriteString("\n")
 		return b.String()
 	}
 
+	if m.secrets.active {
+		return m.renderSecrets()
+	}
+
 	// If in details mode, render the podcast details
 	if m.search.details.active {
 		return m.renderSearchDetails()
@@ -702,6 +1116,65 @@ func (m model) View() string {
 	return m.renderCommandMenu()
 }
 
+// runMenuItem runs the command menu entry with the given name.
+func (m model) runMenuItem(name string) (tea.Model, tea.Cmd) {
+	m.commandMenu.active = false
+	m.input.Focus()
+
+	if strings.HasPrefix(name, "playlist ") {
+		return m.openPlaylist(name)
+	}
+
+	// For commands that need arguments, prompt for input
+	switch name {
+	case "search":
+		// Enter search input mode
+		m.searchInputMode = true
+		m.input.Prompt = "search> "
+		m.input.Placeholder = "Enter podcast search query, or episodes <words>..."
+		m.input.SetValue("")
+		m.input.SetCursor(0)
+		return m, nil
+	case "list":
+		// Execute "list subscriptions" directly
+		result, err := m.app.Execute(m.ctx, "list subscriptions")
+		if err != nil {
+			// Error: return to menu
+			return m, nil
+		}
+		return m.handleCommandResult(result)
+	case "secrets":
+		return m.openSecrets()
+	case "refresh":
+		return m.openRefreshProgress()
+	case "episodes":
+		return m.openView(viewShortcuts["e"])
+	default:
+		// Execute the command directly
+		result, err := m.app.Execute(m.ctx, name)
+		if err != nil {
+			// Error: return to menu
+			return m, nil
+		}
+		return m.handleCommandResult(result)
+	}
+}
+
+// openPlaylist lists the episodes of a playlist menu entry. An empty or
+// broken playlist keeps the menu open with a toast saying why.
+func (m model) openPlaylist(command string) (tea.Model, tea.Cmd) {
+	result, err := m.app.Execute(m.ctx, command)
+	if err != nil || len(result.EpisodeResults) == 0 {
+		m.commandMenu.active = true
+		m.input.Blur()
+		if err != nil {
+			return m, m.showToast(fmt.Sprintf("Error: %v", err))
+		}
+		return m, m.showToast(result.Message)
+	}
+	return m.handleCommandResult(result)
+}
+
 func (m model) handleCommandResult(result app.CommandResult) (tea.Model, tea.Cmd) {
 	// Check if we got interactive search results
 	if len(result.SearchResults) > 0 {
@@ -712,6 +1185,9 @@ func (m model) handleCommandResult(result app.CommandResult) (tea.Model, tea.Cmd
 		m.search.hint = result.SearchHint
 		m.search.context = result.SearchContext
 		m.search.details = detailView{}
+		if m.subscriptionsBySize {
+			m.sortSubscriptions()
+		}
 		m.input.Blur()
 		return m, nil
 	}
@@ -720,12 +1196,29 @@ func (m model) handleCommandResult(result app.CommandResult) (tea.Model, tea.Cmd
 	if len(result.EpisodeResults) > 0 {
 		m.episodes.active = true
 		m.episodes.results = result.EpisodeResults
+		m.episodes.query = result.EpisodeQuery
+		if (result.EpisodeFilter != "" && result.EpisodeFilter != m.episodes.filter) ||
+			(result.EpisodeSearch != "" && result.EpisodeSearch != m.episodes.search) {
+			// The query or search decides which episodes to list.
+			m.episodes.filterMode = "all"
+		}
+		m.episodes.filter = result.EpisodeFilter
+		m.episodes.search = result.EpisodeSearch
+		m.episodes.playlist = result.EpisodePlaylist
+		var save tea.Cmd
+		if order := episodeQueryOrder(m.episodes.query); m.episodes.filter == "" && m.episodes.search == "" && order != m.episodes.order {
+			m.episodes.order = order
+			save = m.saveEpisodeView()
+		}
+		m.episodes.podcast = result.EpisodePodcast
+		m.episodes.selected = nil
+		m.episodes.more = result.EpisodesMore
 		m.episodes.cursor = 0
 		m.episodes.scroll = 0
 		m.episodes.details.active = false
 		m.episodes.details = episodeDetailView{}
 		m.input.Blur()
-		return m, nil
+		return m, save
 	}
 
 	// Check if we got queued episode results (even if empty)
@@ -748,6 +1241,13 @@ func (m model) handleCommandResult(result app.CommandResult) (tea.Model, tea.Cmd
 		return m, nil
 	}
 
+	// Check if we got subscription history (even if empty)
+	if result.SubscriptionHistory != nil {
+		m.history = historyView{active: true, events: result.SubscriptionHistory}
+		m.input.Blur()
+		return m, nil
+	}
+
 	if result.Quit {
 		m.quitting = true
 		return m, tea.Quit
@@ -872,6 +1372,27 @@ func (m model) handleSearchUnsubscribe() (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+// sortSubscriptions orders a subscriptions listing by disk usage, largest
+// first, or by title, keeping the cursor on the same podcast.
+func (m *model) sortSubscriptions() {
+	if m.search.context != "subscriptions" || len(m.search.results) == 0 {
+		return
+	}
+	selected := m.search.results[min(m.search.cursor, len(m.search.results)-1)].Podcast.ID
+	slices.SortStableFunc(m.search.results, func(a, b app.SearchResult) int {
+		if m.subscriptionsBySize {
+			return cmp.Compare(b.DiskBytes, a.DiskBytes)
+		}
+		return strings.Compare(strings.ToLower(a.Podcast.Title), strings.ToLower(b.Podcast.Title))
+	})
+	for i, result := range m.search.results {
+		if result.Podcast.ID == selected {
+			m.search.cursor = i
+			break
+		}
+	}
+}
+
 func (m model) renderSearchList() string {
 	var b strings.Builder
 
@@ -886,6 +1407,9 @@ func (m model) renderSearchList() string {
 	if title == "" {
 		title = "Search Results"
 	}
+	if m.search.context == "subscriptions" && m.subscriptionsBySize {
+		title += " by disk usage"
+	}
 	hint := m.search.hint
 	if hint == "" {
 		hint = "Use ↑↓/jk to navigate, Enter for details, [s] subscribe, [u] unsubscribe, [x]/Esc to exit"
@@ -917,7 +1441,7 @@ func (m model) renderSearchList() string {
 		// Truncate author if too long
 		author := podcast.Author
 		if m.search.context == "subscriptions" {
-			author = fmt.Sprintf("new: %d | unplayed: %d | total: %d", result.NewCount, result.UnplayedCount, result.TotalCount)
+			author = fmt.Sprintf("new: %d | unplayed: %d | played: %d | total: %d", result.NewCount, result.UnplayedCount, result.PlayedCount, result.TotalCount)
 		}
 		if author == "" {
 			author = "Unknown"
@@ -932,8 +1456,22 @@ func (m model) renderSearchList() string {
 			statusSuffix = " [subscribed]"
 		}
 
-		// Format: → Title (by Author) [subscribed]
-		line := cursor + style.Render(podcast.Title) + normalStyle.Render(" (by "+author+")") + subscribedStyle.Render(statusSuffix)
+		// Tell shows with the same title apart by feed host and size
+		sameTitle := ""
+		if result.SameTitle {
+			sameTitle = " " + podcastOrigin(podcast)
+			if result.LikelyDuplicate {
+				sameTitle += " [likely duplicate]"
+			}
+		}
+
+		diskUsage := ""
+		if result.DiskBytes > 0 {
+			diskUsage = fmt.Sprintf(" %.1f MB on disk", float64(result.DiskBytes)/(1024*1024))
+		}
+
+		// Format: → Title (by Author) [subscribed] host, N episodes N MB on disk #tag
+		line := cursor + style.Render(podcast.Title) + normalStyle.Render(" (by "+author+")") + subscribedStyle.Render(statusSuffix) + dimStyle.Render(sameTitle+diskUsage+hashTags(result.Tags))
 		b.WriteString(line)
 		b.WriteString("\n")
 	}
@@ -941,6 +1479,15 @@ func (m model) renderSearchList() string {
 	return b.String()
 }
 
+// hashTags renders tags as " #tag #other", or nothing without tags.
+func hashTags(tags []string) string {
+	var b strings.Builder
+	for _, tag := range tags {
+		b.WriteString(" #" + tag)
+	}
+	return b.String()
+}
+
 func (m model) renderSearchDetails() string {
 	var b strings.Builder
 
@@ -954,11 +1501,17 @@ func (m model) renderSearchDetails() string {
 
 	b.WriteString(headerStyle.Render("Podcast Details"))
 	b.WriteString("\n")
+	hint := "Press [s] to subscribe"
 	if m.search.details.podcast.IsSubscribed {
-		b.WriteString(dimStyle.Render("Press [u] to unsubscribe, [x]/Esc to return"))
-	} else {
-		b.WriteString(dimStyle.Render("Press [s] to subscribe, [x]/Esc to return"))
+		hint = "Press [u] to unsubscribe"
+		if len(m.search.details.podcast.Funding) > 0 {
+			hint += ", [o] to open the support link"
+		}
+	}
+	if m.search.details.artworkPath != "" && artworkProtocol(m.app.Config().ArtworkProtocol) != config.ArtworkNone {
+		hint += ", [a] to view the artwork"
 	}
+	b.WriteString(dimStyle.Render(hint + ", [x]/Esc to return"))
 	b.WriteString("\n\n")
 
 	// Podcast title with subscription status
@@ -986,7 +1539,56 @@ func (m model) renderSearchDetails() string {
 	}
 
 	if m.search.context == "subscriptions" {
-		b.WriteString(normalStyle.Render(fmt.Sprintf("New: %d | Unplayed: %d | Total: %d", m.search.details.podcast.NewCount, m.search.details.podcast.UnplayedCount, m.search.details.podcast.TotalCount)))
+		details := m.search.details.podcast
+		b.WriteString(normalStyle.Render(fmt.Sprintf("New: %d | Unplayed: %d | Played: %d | Total: %d", details.NewCount, details.UnplayedCount, details.PlayedCount, details.TotalCount)))
+		b.WriteString("\n")
+		lastRefreshed := "never"
+		if refreshed := m.search.details.podcast.LastRefreshed; !refreshed.IsZero() {
+			lastRefreshed = refreshed.Local().Format("2006-01-02 15:04")
+		}
+		b.WriteString(normalStyle.Render("Last refreshed: " + lastRefreshed))
+		b.WriteString("\n")
+		if next := details.NextRefresh; !next.IsZero() && m.app.Config().RefreshIntervalMinutes > 0 {
+			b.WriteString(normalStyle.Render("Next refresh: " + next.Local().Format("2006-01-02 15:04")))
+			b.WriteString("\n")
+		}
+		if details.DownloadDir != "" {
+			b.WriteString(normalStyle.Render("Download directory: " + details.DownloadDir))
+			b.WriteString("\n")
+		}
+		if len(details.Tags) > 0 {
+			b.WriteString(normalStyle.Render("Tags: " + strings.Join(details.Tags, ", ")))
+			b.WriteString("\n")
+		}
+		for _, funding := range details.Funding {
+			link := funding.URL
+			if funding.Label != "" {
+				link = funding.Label + " - " + funding.URL
+			}
+			b.WriteString(normalStyle.Render("Support this show: " + link))
+			b.WriteString("\n")
+		}
+		for _, block := range details.Value {
+			lines := valueBlockLines(block)
+			b.WriteString(normalStyle.Render(lines[0]))
+			b.WriteString("\n")
+			for _, line := range lines[1:] {
+				b.WriteString(dimStyle.Render(line))
+				b.WriteString("\n")
+			}
+		}
+	}
+
+	// Artwork
+	if podcast.Artwork != "" {
+		artwork := "loading..."
+		switch {
+		case m.search.details.artworkErr != nil:
+			artwork = "unavailable (" + m.search.details.artworkErr.Error() + ")"
+		case m.search.details.artworkPath != "":
+			artwork = m.search.details.artworkPath
+		}
+		b.WriteString(normalStyle.Render("Artwork: " + artwork))
 		b.WriteString("\n")
 	}
 
@@ -1023,6 +1625,39 @@ func (m model) renderSearchDetails() string {
 	return b.String()
 }
 
+// cursorEpisode returns the episode under the cursor.
+func (m model) cursorEpisode() (app.EpisodeResult, bool) {
+	visible := m.visibleEpisodes()
+	if m.episodes.cursor < 0 || m.episodes.cursor >= len(visible) {
+		return app.EpisodeResult{}, false
+	}
+	return visible[m.episodes.cursor], true
+}
+
+// visibleEpisodes returns the loaded episodes the filter mode shows, which
+// the cursor indexes.
+func (m model) visibleEpisodes() []app.EpisodeResult {
+	var keep func(state string) bool
+	switch m.episodes.filterMode {
+	case "all":
+		return m.episodes.results
+	case "ignored":
+		keep = func(state string) bool { return state == domain.EpisodeStateIgnored }
+	case "downloaded":
+		keep = func(state string) bool { return state == domain.EpisodeStateDownloaded }
+	default:
+		// Hide ignored episodes
+		keep = func(state string) bool { return state != domain.EpisodeStateIgnored }
+	}
+	visible := make([]app.EpisodeResult, 0, len(m.episodes.results))
+	for _, result := range m.episodes.results {
+		if keep(result.Episode.State) {
+			visible = append(visible, result)
+		}
+	}
+	return visible
+}
+
 func (m model) renderEpisodeList() string {
 	var b strings.Builder
 
@@ -1039,38 +1674,7 @@ func (m model) renderEpisodeList() string {
 		maxVisible = 12
 	}
 
-	// Filter episodes based on filter mode
-	visibleResults := m.episodes.results
-	if m.episodes.filterMode != "" {
-		filtered := make([]app.EpisodeResult, 0, len(m.episodes.results))
-		switch m.episodes.filterMode {
-		case "all":
-			visibleResults = m.episodes.results
-		case "ignored":
-			for _, result := range m.episodes.results {
-				if result.Episode.State == "IGNORED" {
-					filtered = append(filtered, result)
-				}
-			}
-			visibleResults = filtered
-		case "downloaded":
-			for _, result := range m.episodes.results {
-				if result.Episode.State == "DOWNLOADED" {
-					filtered = append(filtered, result)
-				}
-			}
-			visibleResults = filtered
-		}
-	} else {
-		// Default: hide ignored episodes
-		filtered := make([]app.EpisodeResult, 0, len(m.episodes.results))
-		for _, result := range m.episodes.results {
-			if result.Episode.State != "IGNORED" {
-				filtered = append(filtered, result)
-			}
-		}
-		visibleResults = filtered
-	}
+	visibleResults := m.visibleEpisodes()
 
 	totalEpisodes := len(visibleResults)
 	start := m.episodes.scroll
@@ -1100,18 +1704,45 @@ func (m model) renderEpisodeList() string {
 	default:
 		viewMode = "Episodes (hiding ignored)"
 	}
+	order, bounds := episodeQueryLabels(m.episodes.query)
+	if bounds != "" {
+		viewMode += ", " + bounds
+	}
+	viewMode += " (" + order + ")"
+	if m.episodes.podcast != "" && len(m.episodes.results) > 0 {
+		viewMode = m.episodes.results[0].PodcastTitle + ": " + viewMode
+	}
+	if m.episodes.filter != "" {
+		viewMode = fmt.Sprintf("Query %q", m.episodes.filter)
+	}
+	if m.episodes.playlist != "" {
+		viewMode = "Playlist " + m.episodes.playlist
+	}
+	if m.episodes.search != "" {
+		viewMode = "Titles and show notes matching " + m.episodes.search
+	}
+	total := strconv.Itoa(totalEpisodes)
+	if m.episodes.more {
+		// Only the pages loaded so far are counted
+		total += "+"
+	}
 	if totalEpisodes > 0 {
-		if totalEpisodes > maxVisible {
-			b.WriteString(headerStyle.Render(fmt.Sprintf("%s (Newest First) - showing %d-%d of %d", viewMode, start+1, end, totalEpisodes)))
+		if totalEpisodes > maxVisible || m.episodes.more {
+			b.WriteString(headerStyle.Render(fmt.Sprintf("%s - showing %d-%d of %s", viewMode, start+1, end, total)))
 		} else {
-			b.WriteString(headerStyle.Render(fmt.Sprintf("%s (Newest First) - %d total", viewMode, totalEpisodes)))
+			b.WriteString(headerStyle.Render(fmt.Sprintf("%s - %d total", viewMode, totalEpisodes)))
+		}
+		if selected := len(m.episodes.selected); selected > 0 {
+			b.WriteString(" " + m.theme.Badge.Render(fmt.Sprintf(" %d selected ", selected)))
 		}
 		b.WriteString("\n")
 	} else {
 		b.WriteString(headerStyle.Render("No episodes to display"))
 		b.WriteString("\n")
 	}
-	b.WriteString(dimStyle.Render("Use ↑↓/jk to navigate, Enter for details, [i] ignore, [A] all, [I] ignored, [D] downloaded, [d] download, [x]/Esc to exit"))
+	b.WriteString(dimStyle.Render("Use ↑↓/jk to navigate, Enter for details, [i] ignore, [A] all, [I] ignored, [D] downloaded, [o] order, [d] download, [r] refresh, [x]/Esc to exit"))
+	b.WriteString("\n")
+	b.WriteString(dimStyle.Render("[space] select, [*] select all, then [d] download, [i] ignore, [s] mark seen, [Del] delete files"))
 	b.WriteString("\n\n")
 
 	// Column abbreviation settings
@@ -1135,6 +1766,9 @@ func (m model) renderEpisodeList() string {
 			cursor = "→ "
 			style = cursorStyle
 		}
+		if m.episodes.selected[ep.ID] {
+			cursor = cursor[:len(cursor)-1] + m.theme.State.Render("●")
+		}
 
 		// Format published date
 		published := "Unknown   "
@@ -1168,10 +1802,17 @@ func (m model) renderEpisodeList() string {
 			sizeStr = "       --"
 		}
 
-		// Format: → DATE PODCAST_NAME EPISODE_TITLE SIZE
+		// Format duration
+		durationStr := "--"
+		if ep.DurationSec > 0 {
+			durationStr = formatDuration(ep.DurationSec)
+		}
+		durationStr = fmt.Sprintf("%8s", durationStr)
+
+		// Format: → DATE PODCAST_NAME EPISODE_TITLE SIZE DURATION
 		line := cursor + dateStyle.Render(published) + " " +
 			dimStyle.Render(podcastName) + " " + style.Render(episodeTitle) + " " +
-			dimStyle.Render(sizeStr)
+			dimStyle.Render(sizeStr) + " " + dimStyle.Render(durationStr)
 
 		b.WriteString(line)
 		b.WriteString("\n")
@@ -1180,6 +1821,111 @@ func (m model) renderEpisodeList() string {
 	return b.String()
 }
 
+// refreshEpisodes reloads the episode list, keeping its duration filter and
+// order, or repeats the query or search that produced it.
+func (m model) refreshEpisodes() (app.CommandResult, error) {
+	return m.app.Execute(m.ctx, m.episodeListCommand(0))
+}
+
+// loadMoreEpisodes appends the next page of the episode list once the cursor
+// reaches the last episode loaded.
+func (m *model) loadMoreEpisodes() {
+	result, err := m.app.Execute(m.ctx, m.episodeListCommand(len(m.episodes.results)))
+	if err != nil {
+		// Error: keep the episodes loaded so far
+		return
+	}
+	m.episodes.results = append(m.episodes.results, result.EpisodeResults...)
+	m.episodes.more = result.EpisodesMore
+}
+
+// openPodcastEpisodes lists the episodes of a subscription in the episode
+// view. Without episodes to list, the subscriptions stay open with a toast.
+func (m model) openPodcastEpisodes(podcastID string) (tea.Model, tea.Cmd) {
+	result, err := m.app.Execute(m.ctx, m.episodesCommand(podcastID))
+	if err != nil {
+		return m, m.showToast(fmt.Sprintf("Could not list episodes: %v", err))
+	}
+	if len(result.EpisodeResults) == 0 {
+		return m, m.showToast(result.Message)
+	}
+	m.closeListViews()
+	return m.handleCommandResult(result)
+}
+
+// returnToSubscription leaves a podcast's episodes for the subscriptions
+// list, with the podcast selected.
+func (m model) returnToSubscription(podcastID string) (tea.Model, tea.Cmd) {
+	updated, cmd := m.openView("list subscriptions")
+	m = updated.(model)
+	for i, result := range m.search.results {
+		if result.Podcast.ID == podcastID {
+			m.search.cursor = i
+			break
+		}
+	}
+	return m, cmd
+}
+
+// episodeListCommand returns the command listing the episodes shown from
+// offset on. Listings and searches are loaded a page at a time; a query
+// bounds itself with its limit clause.
+func (m model) episodeListCommand(offset int) string {
+	if m.episodes.playlist != "" {
+		return "playlist " + shellquote.Join(m.episodes.playlist)
+	}
+	if m.episodes.filter != "" {
+		return "query " + shellquote.Join(m.episodes.filter)
+	}
+	if m.episodes.search != "" {
+		return pagedCommand("search episodes "+m.episodes.search, offset)
+	}
+	return pagedCommand(strings.TrimSpace("episodes "+m.episodes.query), offset)
+}
+
+// episodePageSize is how many episodes the episode list loads at a time.
+const episodePageSize = 200
+
+// pagedCommand adds the page of episodes starting at offset to an episodes
+// or search episodes command.
+func pagedCommand(command string, offset int) string {
+	command += " --limit " + strconv.Itoa(episodePageSize)
+	if offset > 0 {
+		command += " --offset " + strconv.Itoa(offset)
+	}
+	return command
+}
+
+// episodeQueryLabels describes an episodes query for the list header: the
+// order and the filters, if any.
+func episodeQueryLabels(query string) (order, bounds string) {
+	order = "Newest First"
+	var parts []string
+	words, err := shellquote.Split(query)
+	if err != nil {
+		words = strings.Fields(query)
+	}
+	for i := 0; i < len(words); i++ {
+		switch words[i] {
+		case "shortest":
+			order = "Shortest First"
+		case "longest":
+			order = "Longest First"
+		case "under", "over":
+			if i+1 < len(words) {
+				parts = append(parts, words[i]+" "+words[i+1]+" min")
+				i++
+			}
+		case "--state", "--podcast", "--since":
+			if i+1 < len(words) {
+				parts = append(parts, strings.TrimPrefix(words[i], "--")+" "+words[i+1])
+				i++
+			}
+		}
+	}
+	return order, strings.Join(parts, ", ")
+}
+
 func (m model) renderQueueList() string {
 	var b strings.Builder
 
@@ -1188,19 +1934,31 @@ func (m model) renderQueueList() string {
 	normalStyle := m.theme.Normal
 	dimStyle := m.theme.Dim
 	dateStyle := m.theme.Date
+	stateStyle := m.theme.State
 
 	totalQueued := len(m.queue.results)
+	activeCount := 0
+	for _, result := range m.queue.results {
+		if result.Active {
+			activeCount++
+		}
+	}
 
 	// Header
 	if totalQueued > 0 {
-		b.WriteString(headerStyle.Render(fmt.Sprintf("Download Queue - %d episode(s)", totalQueued)))
+		b.WriteString(headerStyle.Render(fmt.Sprintf("Download Queue - %d active / %d waiting", activeCount, totalQueued-activeCount)))
 		b.WriteString("\n")
 	} else {
 		b.WriteString(headerStyle.Render("Download Queue - Empty"))
 		b.WriteString("\n")
 	}
-	b.WriteString(dimStyle.Render("Use ↑↓/jk to navigate, [x]/Esc to return to main menu"))
-	b.WriteString("\n\n")
+	b.WriteString(dimStyle.Render("Use ↑↓/jk to navigate, [+]/[-] priority, [space] pause/resume, [c] cancel, [x]/Esc to return to main menu"))
+	b.WriteString("\n")
+	if totalQueued > 0 && m.app.DownloadWorkers() == 0 {
+		b.WriteString(m.theme.Error.Render("No download workers configured (parallel_downloads is 0); press [p] to download them now or run 'queue workers <n>'."))
+		b.WriteString("\n")
+	}
+	b.WriteString("\n")
 
 	// Column abbreviation settings
 	cfg := m.app.Config()
@@ -1246,22 +2004,44 @@ func (m model) renderQueueList() string {
 			episodeTitle = episodeTitle[:episodeMaxLen-3] + "..."
 		}
 
-		// Format status
+		// Format status; active transfers stand out from waiting rows
 		var statusStr string
-		if result.RetryCount > 0 {
+		statusStyle := dimStyle
+		switch {
+		case result.Paused:
+			statusStr = "Paused"
+		case result.Active:
+			statusStr = "Downloading"
+			if progress, ok := m.progress[ep.ID]; ok {
+				statusStr = formatProgress(progress)
+			}
+			statusStyle = stateStyle
+		case result.RetryCount > 0:
 			statusStr = fmt.Sprintf("Error (retries: %d)", result.RetryCount)
-		} else {
+		default:
 			statusStr = "Queued"
 		}
+		if result.Priority != domain.PriorityNormal && !result.Active {
+			statusStr += ", " + domain.PriorityName(result.Priority)
+		}
 		statusStr = fmt.Sprintf("%-20s", statusStr)
 
+		// Separate active transfers from the waiting queue
+		if i == activeCount && activeCount > 0 {
+			b.WriteString("\n")
+		}
+
 		// Format: → DATE PODCAST_NAME EPISODE_TITLE STATUS
 		line := cursor + dateStyle.Render(enqueued) + " " +
 			dimStyle.Render(podcastName) + " " + style.Render(episodeTitle) + " " +
-			dimStyle.Render(statusStr)
+			statusStyle.Render(statusStr)
 
 		b.WriteString(line)
 		b.WriteString("\n")
+		if i == m.queue.cursor && result.RetryCount > 0 && result.LastError != "" {
+			b.WriteString(m.theme.Error.Render("    Last error: " + result.LastError))
+			b.WriteString("\n")
+		}
 	}
 
 	return b.String()
@@ -1311,7 +2091,9 @@ func (m model) renderDownloadsList() string {
 		b.WriteString(headerStyle.Render("Downloaded Episodes - Empty"))
 		b.WriteString("\n")
 	}
-	b.WriteString(dimStyle.Render("Use ↑↓/jk to navigate, [x]/Esc to return to main menu"))
+	b.WriteString(dimStyle.Render("Use ↑↓/jk to navigate, [D]/Del to delete the file, [x]/Esc to return to main menu"))
+	b.WriteString("\n")
+	b.WriteString(dimStyle.Render("Sizes: reported by the feed, then on disk; [SIZE MISMATCH] marks files more than 10% off, often truncated downloads"))
 	b.WriteString("\n\n")
 
 	// Column abbreviation settings
@@ -1359,25 +2141,32 @@ func (m model) renderDownloadsList() string {
 			episodeTitle = episodeTitle[:episodeMaxLen-3] + "..."
 		}
 
-		// Format size in MB
-		var sizeStr string
-		if ep.SizeBytes > 0 {
-			sizeMB := float64(ep.SizeBytes) / (1024 * 1024)
-			sizeStr = fmt.Sprintf("%6.1f MB", sizeMB)
-		} else {
-			sizeStr = "       --"
+		// Sizes reported by the feed and found on disk
+		sizeStr := formatSizeMB(ep.SizeBytes)
+		diskStr := dimStyle.Render(formatSizeMB(result.DiskBytes))
+		if result.SizeMismatch() {
+			diskStr = m.theme.Error.Render(formatSizeMB(result.DiskBytes))
 		}
 
-		// Add state indicator (DOWNLOADED vs DELETED)
+		// Add state indicator (DOWNLOADED vs DELETED/CORRUPT/PLAYED)
 		stateIndicator := ""
-		if ep.State == "DELETED" {
+		switch ep.State {
+		case "DELETED":
 			stateIndicator = " [DELETED]"
+		case "CORRUPT":
+			stateIndicator = " [CORRUPT]"
+		case "PLAYED":
+			stateIndicator = " [PLAYED]"
+		}
+		mismatch := ""
+		if result.SizeMismatch() {
+			mismatch = m.theme.Error.Render(" [SIZE MISMATCH]")
 		}
 
-		// Format: → DATE PODCAST_NAME EPISODE_TITLE SIZE [DELETED]
+		// Format: → DATE PODCAST_NAME EPISODE_TITLE REPORTED_SIZE DISK_SIZE [DELETED]
 		line := cursor + dateStyle.Render(published) + " " +
 			dimStyle.Render(podcastName) + " " + style.Render(episodeTitle) + " " +
-			dimStyle.Render(sizeStr) + dimStyle.Render(stateIndicator)
+			dimStyle.Render(sizeStr) + " " + diskStr + dimStyle.Render(stateIndicator) + mismatch
 
 		b.WriteString(line)
 		b.WriteString("\n")
@@ -1388,12 +2177,16 @@ func (m model) renderDownloadsList() string {
 		b.WriteString("\n")
 		b.WriteString(headerStyle.Render(fmt.Sprintf("Dangling Files - %d untracked file(s)", len(m.downloads.danglingFiles))))
 		b.WriteString("\n")
-		b.WriteString(dimStyle.Render("Files in download directory not tracked in database:"))
+		b.WriteString(dimStyle.Render("Files in download directory not tracked in database ([a] adopt, [d] delete, 'dangling clean' deletes all):"))
 		b.WriteString("\n\n")
 
-		for _, file := range m.downloads.danglingFiles {
+		for i, file := range m.downloads.danglingFiles {
 			sizeMB := float64(file.SizeBytes) / (1024 * 1024)
-			line := dimStyle.Render("  ") + normalStyle.Render(file.Path) + " " + dimStyle.Render(fmt.Sprintf("(%6.1f MB)", sizeMB))
+			cursor, style := "  ", normalStyle
+			if len(m.downloads.results)+i == m.downloads.cursor {
+				cursor, style = "→ ", cursorStyle
+			}
+			line := cursor + style.Render(file.Path) + " " + dimStyle.Render(fmt.Sprintf("(%6.1f MB)", sizeMB))
 			b.WriteString(line)
 			b.WriteString("\n")
 		}
@@ -1428,12 +2221,22 @@ func (m model) renderEpisodeDetails() string {
 		b.WriteString("\n")
 	}
 
+	if label := episodeNumberLabel(detail.Season, detail.Number); label != "" {
+		b.WriteString(normalStyle.Render(label))
+		b.WriteString("\n")
+	}
+
 	if detail.SizeBytes > 0 {
 		sizeMB := float64(detail.SizeBytes) / (1024 * 1024)
 		b.WriteString(normalStyle.Render(fmt.Sprintf("Size: %.1f MB", sizeMB)))
 		b.WriteString("\n")
 	}
 
+	if detail.DurationSec > 0 {
+		b.WriteString(normalStyle.Render(fmt.Sprintf("Position: %s / %s", formatDuration(detail.PositionSec), formatDuration(detail.DurationSec))))
+		b.WriteString("\n")
+	}
+
 	if detail.FilePath != "" {
 		b.WriteString(normalStyle.Render("Downloaded to: " + detail.FilePath))
 		b.WriteString("\n")
@@ -1447,6 +2250,40 @@ func (m model) renderEpisodeDetails() string {
 		b.WriteString("\n")
 	}
 
+	if detail.LastError != "" {
+		b.WriteString(m.theme.Error.Render(fmt.Sprintf("Last download error (%d retries): %s", detail.RetryCount, detail.LastError)))
+		b.WriteString("\n")
+	}
+
+	if detail.ImageURL != "" {
+		b.WriteString(dimStyle.Render("Artwork: " + detail.ImageURL))
+		b.WriteString("\n")
+	}
+
+	if detail.ChaptersURL != "" {
+		b.WriteString(dimStyle.Render("Chapters: " + detail.ChaptersURL))
+		b.WriteString("\n")
+	}
+
+	if len(detail.Soundbites) > 0 {
+		b.WriteString("\n")
+		b.WriteString(headerStyle.Render("Soundbites:"))
+		b.WriteString("\n")
+		for i, bite := range detail.Soundbites {
+			key := "   " // Only the first nine have a key
+			if i < 9 {
+				key = fmt.Sprintf("[%d]", i+1)
+			}
+			line := key + " " + formatDuration(int(bite.StartSec))
+			if bite.Title != "" {
+				line += " " + bite.Title
+			}
+			line += fmt.Sprintf(" (%ds)", int(bite.DurationSec+0.5))
+			b.WriteString(normalStyle.Render(line))
+			b.WriteString("\n")
+		}
+	}
+
 	if len(m.episodes.details.lines) > 0 {
 		b.WriteString("\n")
 		b.WriteString(headerStyle.Render("Description:"))
@@ -1485,12 +2322,48 @@ func (m model) renderEpisodeDetails() string {
 	}
 
 	b.WriteString("\n")
+	if n := len(detail.Soundbites); n > 0 {
+		if n > 9 {
+			n = 9
+		}
+		b.WriteString(dimStyle.Render(fmt.Sprintf("Press [1-%d] to play a soundbite.", n)))
+		b.WriteString("\n")
+	}
 	b.WriteString(dimStyle.Render("Use ↑↓/jk to scroll. Press [x]/Esc to return to the episode list."))
 	b.WriteString("\n")
 
 	return b.String()
 }
 
+// playSoundbite plays the episode in the external player from the start of
+// its index'th soundbite: the downloaded file if there is one, otherwise the
+// enclosure URL.
+func (m *model) playSoundbite(index int) tea.Cmd {
+	detail := m.episodes.details.detail
+	if index < 0 || index >= len(detail.Soundbites) {
+		return nil
+	}
+	file := detail.EnclosureURL
+	if detail.FilePath != "" {
+		// A relative download_root leaves relative paths
+		if abs, err := filepath.Abs(detail.FilePath); err == nil {
+			file = abs
+		}
+	}
+	if file == "" {
+		return m.showToast("Nothing to play: the episode has no file or URL")
+	}
+	bite := detail.Soundbites[index]
+	args, err := playerArgs(m.app.Config().Player, file, bite.StartSec)
+	if err == nil {
+		err = startPlayer(args)
+	}
+	if err != nil {
+		return m.showToast(fmt.Sprintf("Could not start player: %v", err))
+	}
+	return m.showToast("Playing from " + formatDuration(int(bite.StartSec)))
+}
+
 func (m *model) enterEpisodeDetails(detail app.EpisodeDetail) {
 	m.episodes.details.active = true
 	m.episodes.details.detail = detail
@@ -1533,6 +2406,126 @@ func (m *model) adjustEpisodeDetailScroll(delta int) {
 	m.episodes.details.scroll = newScroll
 }
 
+// progressBarWidth is the number of cells in a queue row's progress bar.
+const progressBarWidth = 20
+
+// formatProgress renders a download progress event as a bar with percentage,
+// transfer rate and estimated time remaining. Without a known total only the
+// received size and rate are shown.
+func formatProgress(progress app.Event) string {
+	mb := func(bytes int64) float64 { return float64(bytes) / (1024 * 1024) }
+	var parts []string
+	if progress.Total > 0 {
+		fraction := float64(progress.Bytes) / float64(progress.Total)
+		fraction = math.Min(math.Max(fraction, 0), 1)
+		filled := int(fraction * progressBarWidth)
+		bar := strings.Repeat("█", filled) + strings.Repeat("░", progressBarWidth-filled)
+		parts = append(parts, bar, fmt.Sprintf("%3.0f%%", fraction*100))
+	} else {
+		parts = append(parts, fmt.Sprintf("Downloading %.1f MB", mb(progress.Bytes)))
+	}
+	if progress.Rate > 0 {
+		parts = append(parts, fmt.Sprintf("%.1f MB/s", mb(progress.Rate)))
+		if remaining := progress.Total - progress.Bytes; progress.Total > 0 && remaining > 0 {
+			parts = append(parts, "ETA "+formatDuration(int(remaining/progress.Rate)))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// podcastOrigin describes where a directory result comes from, as its feed
+// host and episode count, e.g. "feeds.example.com, 120 episodes".
+func podcastOrigin(podcast itunes.Podcast) string {
+	host := "unknown host"
+	if parsed, err := url.Parse(podcast.FeedURL); err == nil && parsed.Hostname() != "" {
+		host = strings.TrimPrefix(strings.ToLower(parsed.Hostname()), "www.")
+	}
+	switch podcast.EpisodeCount {
+	case 0:
+		return host
+	case 1:
+		return host + ", 1 episode"
+	}
+	return fmt.Sprintf("%s, %d episodes", host, podcast.EpisodeCount)
+}
+
+// valueBlockLines describes a podcast:value block: a heading with the payment
+// type and suggested amount, then one line per recipient with its share of
+// each payment. Fee recipients take their split as a percentage off the top;
+// the others divide the rest by their splits.
+func valueBlockLines(block domain.ValueBlock) []string {
+	heading := "Value for value"
+	if block.Type != "" {
+		heading += ": " + block.Type
+		if block.Method != "" {
+			heading += " (" + block.Method + ")"
+		}
+	}
+	if block.Suggested != "" {
+		heading += ", suggested " + block.Suggested + " per minute"
+	}
+
+	shares := 0
+	for _, r := range block.Recipients {
+		if !r.Fee {
+			shares += r.Split
+		}
+	}
+	lines := []string{heading}
+	for _, r := range block.Recipients {
+		name := r.Name
+		if name == "" {
+			name = "Unnamed"
+		}
+		var share string
+		switch {
+		case r.Fee:
+			share = fmt.Sprintf("%d%% fee", r.Split)
+		case shares > 0:
+			share = fmt.Sprintf("%g%%", math.Round(float64(r.Split)*1000/float64(shares))/10)
+		default:
+			share = "0%"
+		}
+		address := r.Address
+		if r.Type != "" {
+			address = r.Type + " " + address
+		}
+		lines = append(lines, fmt.Sprintf("  %s: %s - %s", name, share, address))
+	}
+	return lines
+}
+
+// episodeNumberLabel renders itunes:season and itunes:episode as
+// "Season 2, Episode 14", leaving out whichever is unknown.
+func episodeNumberLabel(season, number int) string {
+	var parts []string
+	if season > 0 {
+		parts = append(parts, fmt.Sprintf("Season %d", season))
+	}
+	if number > 0 {
+		parts = append(parts, fmt.Sprintf("Episode %d", number))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// formatSizeMB renders a size in bytes as megabytes, or "--" when unknown,
+// right-aligned to nine columns.
+func formatSizeMB(bytes int64) string {
+	if bytes <= 0 {
+		return "       --"
+	}
+	return fmt.Sprintf("%6.1f MB", float64(bytes)/(1024*1024))
+}
+
+// formatDuration renders seconds as H:MM:SS, or M:SS under an hour.
+func formatDuration(seconds int) string {
+	h, m, sec := seconds/3600, (seconds/60)%60, seconds%60
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", h, m, sec)
+	}
+	return fmt.Sprintf("%d:%02d", m, sec)
+}
+
 func formatEpisodeDescription(desc string, width int) []string {
 	cleaned := strings.TrimSpace(desc)
 	if cleaned == "" {
@@ -1644,6 +2637,12 @@ func (m *model) refreshCounts() {
 	if count, err := m.app.CountDownloaded(m.ctx); err == nil {
 		m.downloadsCount = count
 	}
+	if count, err := m.app.CountNew(m.ctx); err == nil {
+		m.newCount = count
+	}
+	if count, err := m.app.CountFailed(m.ctx); err == nil {
+		m.failedCount = count
+	}
 }
 
 func (m model) renderCommandMenu() string {
@@ -1655,8 +2654,11 @@ func (m model) renderCommandMenu() string {
 	dimStyle := m.theme.Dim
 
 	b.WriteString(headerStyle.Render("Podsink - Podcast Manager"))
+	if m.app.ReadOnly() {
+		b.WriteString(" " + m.theme.Error.Render("(read-only)"))
+	}
 	b.WriteString("\n")
-	b.WriteString(dimStyle.Render("Use ↑↓/jk to navigate, Enter to select, [s]earch [p]odcasts [e]pisodes [q]ueue [d]ownloads [c]onfig, ESC/[x] to exit"))
+	b.WriteString(dimStyle.Render("Use ↑↓/jk to navigate, Enter to select, [s]earch [p]odcasts [e]pisodes [q]ueue [d]ownloads [r]efresh [h]istory [c]onfig [a]secrets, Ctrl+P commands, ESC/[x] to exit"))
 	b.WriteString("\n\n")
 
 	for i, item := range m.commandMenu.items {
@@ -1682,9 +2684,16 @@ func (m model) renderCommandMenu() string {
 			usage = fmt.Sprintf("%s (%d)", item.usage, m.queueCount)
 		} else if item.name == "downloads" && m.downloadsCount > 0 {
 			usage = fmt.Sprintf("%s (%d)", item.usage, m.downloadsCount)
+		} else if item.name == "refresh" && m.refreshProgress.running {
+			usage = fmt.Sprintf("%s (%d/%d)", item.usage, m.refreshProgress.done, m.refreshProgress.total)
 		}
 
 		line := cursor + dimStyle.Render(shorthand) + style.Render(usage)
+		if item.name == "episodes" && m.newCount > 0 {
+			line += " " + m.theme.Badge.Render(fmt.Sprintf(" %d new ", m.newCount))
+		} else if item.name == "queue" && m.failedCount > 0 {
+			line += " " + m.theme.AlertBadge.Render(fmt.Sprintf(" %d failed ", m.failedCount))
+		}
 		b.WriteString(line)
 		b.WriteString("\n")
 	}
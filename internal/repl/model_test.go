@@ -2,17 +2,23 @@ package repl
 
 import (
 	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
 	"io"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"podsink/internal/app"
 	"podsink/internal/config"
+	"podsink/internal/domain"
 	"podsink/internal/itunes"
 	"podsink/internal/storage"
 	"podsink/internal/theme"
@@ -55,7 +61,7 @@ func newTestAppWithConfig(t *testing.T, mutate func(*config.Config)) *app.App {
 	deps := app.Dependencies{
 		HTTPClient: httpClient,
 	}
-	application := app.NewWithDependencies(cfg, filepath.Join(dir, "config.yaml"), db, deps)
+	application := app.NewWithDependencies(context.Background(), cfg, filepath.Join(dir, "config.yaml"), db, deps)
 	t.Cleanup(func() {
 		application.Close()
 	})
@@ -71,7 +77,7 @@ func (stubTransport) RoundTrip(req *http.Request) (*http.Response, error) {
     <title>Stub Podcast</title>
     <description>Example description</description>
     <item>
-      <guid>stub-episode</guid>
+      <guid>stub-episode` + req.URL.Path + `</guid>
       <title>Stub Episode</title>
       <description>Example episode</description>
       <enclosure url="http://example.com/audio.mp3" type="audio/mpeg" />
@@ -434,3 +440,923 @@ func TestConfigNavigationFromMainMenu(t *testing.T) {
 		t.Errorf("Expected to see main menu, got: %s", view)
 	}
 }
+
+func TestSecretsPromptMasksInput(t *testing.T) {
+	a := newTestApp(t)
+	m := newModel(context.Background(), a)
+
+	press := func(keys ...tea.KeyMsg) {
+		for _, key := range keys {
+			updated, _ := m.Update(key)
+			m = updated.(model)
+		}
+	}
+	runes := func(text string) tea.KeyMsg { return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(text)} }
+
+	press(runes("a"))
+	if !m.secrets.active || m.commandMenu.active {
+		t.Fatal("expected [a] to open the secrets view")
+	}
+	if view := m.View(); !strings.Contains(view, "gpodder_password") || !strings.Contains(view, "not set") {
+		t.Fatalf("expected secret keys with their status, got: %s", view)
+	}
+
+	press(runes("j"), runes("j"), tea.KeyMsg{Type: tea.KeyEnter}, runes("hunter2"))
+	view := m.View()
+	if strings.Contains(view, "hunter2") || !strings.Contains(view, "•••••••") {
+		t.Fatalf("expected the typed secret to be masked, got: %s", view)
+	}
+
+	press(tea.KeyMsg{Type: tea.KeyEnter})
+	if got := a.Config().GpodderPassword; got != "hunter2" {
+		t.Fatalf("expected gpodder_password to be saved, got %q", got)
+	}
+	if m.secrets.editing || !strings.Contains(m.toast, "Saved gpodder_password.") {
+		t.Fatalf("expected the prompt to close with a toast, got %q", m.toast)
+	}
+
+	press(runes("d"))
+	if got := a.Config().GpodderPassword; got != "" {
+		t.Fatalf("expected [d] to clear gpodder_password, got %q", got)
+	}
+}
+
+func TestDownloadEventShowsToastUntilExpired(t *testing.T) {
+	a := newTestApp(t)
+	m := newModel(context.Background(), a)
+
+	updated, cmd := m.Update(appEventMsg{Kind: app.EventDownloadFinished, EpisodeID: "ep2", Title: "Episode Two", Bytes: 54 * 1024 * 1024})
+	m = updated.(model)
+	if cmd == nil {
+		t.Fatal("expected follow-up command to expire the toast")
+	}
+	if view := m.View(); !strings.Contains(view, "Downloaded: Episode Two (54 MB)") {
+		t.Fatalf("expected toast in view, got: %s", view)
+	}
+
+	updated, _ = m.Update(appEventMsg{Kind: app.EventDownloadFinished, EpisodeID: "ep3", Title: "Episode Three", Err: errors.New("boom")})
+	m = updated.(model)
+	if !strings.Contains(m.View(), "Download failed: Episode Three") {
+		t.Fatalf("expected failure toast, got: %s", m.View())
+	}
+
+	// An expiry for an older toast must not clear the newer one.
+	updated, _ = m.Update(toastExpiredMsg{seq: m.toastSeq - 1})
+	m = updated.(model)
+	if m.toast == "" {
+		t.Fatal("stale expiry cleared the current toast")
+	}
+	updated, _ = m.Update(toastExpiredMsg{seq: m.toastSeq})
+	m = updated.(model)
+	if strings.Contains(m.View(), "Download failed") {
+		t.Fatal("expected toast to be cleared after expiry")
+	}
+}
+
+func TestRefreshShortcutInEpisodesView(t *testing.T) {
+	a := newTestApp(t)
+	m := newModel(context.Background(), a)
+	m.commandMenu.active = false
+	m.episodes.active = true
+
+	updated, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'r'}})
+	m = updated.(model)
+	if cmd == nil {
+		t.Fatal("expected refresh command from 'r' in episodes view")
+	}
+	if !strings.Contains(m.View(), "Refreshing feeds...") {
+		t.Fatalf("expected refreshing toast, got: %s", m.View())
+	}
+
+	updated, _ = m.Update(refreshedMsg{message: "Refreshed 0 feed(s), 0 new episode(s)."})
+	m = updated.(model)
+	if !strings.Contains(m.View(), "Refreshed 0 feed(s)") {
+		t.Fatalf("expected refresh result toast, got: %s", m.View())
+	}
+}
+
+func TestRefreshProgressViewCanBeLeftAndReopened(t *testing.T) {
+	a := newTestApp(t)
+	m := newModel(context.Background(), a)
+
+	updated, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'r'}})
+	m = updated.(model)
+	if cmd == nil || !m.refreshProgress.active || !m.refreshProgress.running {
+		t.Fatal("expected 'r' in the menu to start a refresh and show its progress")
+	}
+
+	updated, _ = m.Update(appEventMsg{Kind: app.EventRefreshProgress, FeedsTotal: 2})
+	m = updated.(model)
+	updated, _ = m.Update(appEventMsg{Kind: app.EventRefreshProgress, FeedsDone: 1, FeedsTotal: 2, Added: 3, Title: "First Podcast"})
+	m = updated.(model)
+	view := m.View()
+	if !strings.Contains(view, "Feeds: 1 / 2") || !strings.Contains(view, "New episodes: 3") || !strings.Contains(view, "First Podcast") {
+		t.Fatalf("expected refresh progress, got: %s", view)
+	}
+
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	m = updated.(model)
+	if !m.commandMenu.active || !strings.Contains(m.View(), "refresh (1/2)") {
+		t.Fatalf("expected the menu to show the running refresh, got: %s", m.View())
+	}
+
+	updated, cmd = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'r'}})
+	m = updated.(model)
+	if cmd != nil || !m.refreshProgress.active {
+		t.Fatal("expected 'r' to return to the running refresh without starting another")
+	}
+
+	updated, _ = m.Update(refreshedMsg{message: "Refreshed 2 feed(s), 3 new episode(s)."})
+	m = updated.(model)
+	view = m.View()
+	if !strings.Contains(view, "Refresh finished") || !strings.Contains(view, "Refreshed 2 feed(s), 3 new episode(s).") {
+		t.Fatalf("expected the finished refresh, got: %s", view)
+	}
+}
+
+func TestPaletteImportShowsProgressAndFailures(t *testing.T) {
+	a := newTestApp(t)
+	m := newModel(context.Background(), a)
+
+	updated, cmd := m.runPaletteCommand("import subscriptions.opml")
+	m = updated.(model)
+	if cmd == nil || !m.importProgress.active || !m.importProgress.running {
+		t.Fatal("expected import to run in the background and show its progress")
+	}
+
+	updated, _ = m.Update(appEventMsg{Kind: app.EventImportProgress, FeedsDone: 1, FeedsTotal: 3})
+	m = updated.(model)
+	updated, _ = m.Update(appEventMsg{Kind: app.EventImportProgress, FeedsDone: 2, FeedsTotal: 3, Added: 1, Title: "First Podcast"})
+	m = updated.(model)
+	view := m.View()
+	if !strings.Contains(view, "Feeds: 2 / 3") || !strings.Contains(view, "Imported: 1") || !strings.Contains(view, "Skipped: 1") || !strings.Contains(view, "First Podcast") {
+		t.Fatalf("expected import progress, got: %s", view)
+	}
+
+	updated, _ = m.Update(importedMsg{
+		message:  "Imported 1 subscriptions, skipped 1, 1 errors",
+		failures: []app.ImportFailure{{Title: "Broken Feed", FeedURL: "https://example.com/broken", Err: "unexpected status 404"}},
+	})
+	m = updated.(model)
+	view = m.View()
+	if !strings.Contains(view, "Import finished") || !strings.Contains(view, "Broken Feed") || !strings.Contains(view, "unexpected status 404") {
+		t.Fatalf("expected the finished import with its failures, got: %s", view)
+	}
+
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	m = updated.(model)
+	if m.importProgress.active || !m.commandMenu.active {
+		t.Fatal("expected Esc to return to the main menu")
+	}
+}
+
+func TestImportConflictsAreResolvedFromTheProgressView(t *testing.T) {
+	a := newTestApp(t)
+	ctx := context.Background()
+	if _, err := a.Execute(ctx, "subscribe http://example.com/feed.xml"); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	listing, err := a.Execute(ctx, "list subscriptions")
+	if err != nil || len(listing.SearchResults) != 1 {
+		t.Fatalf("list subscriptions: %v", err)
+	}
+	podcast := listing.SearchResults[0].Podcast
+	m := newModel(ctx, a)
+
+	updated, _ := m.runPaletteCommand("import subscriptions.opml")
+	m = updated.(model)
+	conflicts := []app.ImportConflict{
+		{PodcastID: podcast.ID, Title: podcast.Title, FeedURL: podcast.FeedURL, ImportedTitle: "Other Name", ImportedFeedURL: podcast.FeedURL},
+		{PodcastID: podcast.ID, Title: podcast.Title, FeedURL: podcast.FeedURL, ImportedTitle: "Other Name", ImportedFeedURL: podcast.FeedURL, Tags: []string{"News"}},
+	}
+	updated, _ = m.Update(importedMsg{message: "Imported 0 subscriptions, skipped 2, 2 conflicts", conflicts: conflicts})
+	m = updated.(model)
+	view := m.View()
+	if !strings.Contains(view, "Subscribed under another title or feed URL: 2") || !strings.Contains(view, "imported: Other Name") || !strings.Contains(view, "[i] take imported") {
+		t.Fatalf("expected the conflicts to resolve, got: %s", view)
+	}
+
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyDown})
+	m = updated.(model)
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'m'}})
+	m = updated.(model)
+	if len(m.importProgress.conflicts) != 1 || m.importProgress.cursor != 0 || m.toast != "Updated "+podcast.Title+" from the import." {
+		t.Fatalf("expected merge to resolve the second conflict, got %d left, toast %q", len(m.importProgress.conflicts), m.toast)
+	}
+	tags, err := a.Execute(ctx, "list subscriptions")
+	if err != nil || len(tags.SearchResults[0].Tags) != 1 {
+		t.Fatalf("expected merge to add the tag, got %+v, %v", tags.SearchResults, err)
+	}
+
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'l'}})
+	m = updated.(model)
+	if len(m.importProgress.conflicts) != 0 || m.toast != "Kept "+podcast.Title+" as it is." {
+		t.Fatalf("expected keep to resolve the last conflict, toast %q", m.toast)
+	}
+}
+
+func TestPlaylistMenuEntriesOpenEpisodeView(t *testing.T) {
+	a := newTestAppWithConfig(t, func(cfg *config.Config) {
+		cfg.Playlists = []config.Playlist{
+			{Name: "Stub shows", Query: "podcast ~ stub"},
+			{Name: "Played", Query: "state = played"},
+		}
+	})
+	if _, err := a.Execute(context.Background(), "subscribe http://example.com/feed.xml"); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	m := newModel(context.Background(), a)
+
+	entry := func(usage string) int {
+		t.Helper()
+		for i, item := range m.commandMenu.items {
+			if item.usage == usage {
+				return i
+			}
+		}
+		t.Fatalf("expected a menu entry %q", usage)
+		return -1
+	}
+
+	m.commandMenu.cursor = entry("playlist Played")
+	updated, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = updated.(model)
+	if !m.commandMenu.active || cmd == nil || !strings.Contains(m.toast, `No episodes in playlist "Played"`) {
+		t.Fatalf("expected an empty playlist to keep the menu with a toast, got %q", m.toast)
+	}
+
+	m.commandMenu.cursor = entry("playlist Stub shows")
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = updated.(model)
+	if !m.episodes.active || len(m.episodes.results) != 1 {
+		t.Fatal("expected the playlist to open in the episode view")
+	}
+	if view := m.View(); !strings.Contains(view, "Playlist Stub shows") {
+		t.Fatalf("expected the playlist name in the header, got: %s", view)
+	}
+	if got := m.episodeListCommand(0); got != "playlist 'Stub shows'" {
+		t.Fatalf("expected the view to reload the playlist, got %q", got)
+	}
+}
+
+func TestRefreshFailuresOpenErrorSummary(t *testing.T) {
+	a := newTestApp(t)
+	m := newModel(context.Background(), a)
+
+	updated, _ := m.Update(refreshedMsg{
+		message:  "Refreshed 0 feed(s), 0 new episode(s), 1 error(s).",
+		failures: []app.RefreshFailure{{PodcastID: "pod1", Title: "Slow Podcast", Err: "feed timed out after 30s"}},
+	})
+	m = updated.(model)
+	view := m.View()
+	if !strings.Contains(view, "Refresh errors") || !strings.Contains(view, "Slow Podcast") || !strings.Contains(view, "feed timed out after 30s") {
+		t.Fatalf("expected the refresh error summary, got: %s", view)
+	}
+
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	m = updated.(model)
+	if m.refreshErrors.active || !m.commandMenu.active {
+		t.Fatal("expected Esc to close the summary and return to the menu")
+	}
+}
+
+func TestFormatProgressShowsBarRateAndETA(t *testing.T) {
+	got := formatProgress(app.Event{Bytes: 5 << 20, Total: 10 << 20, Rate: 1 << 20})
+	want := strings.Repeat("█", 10) + strings.Repeat("░", 10) + "  50% 1.0 MB/s ETA 0:05"
+	if got != want {
+		t.Fatalf("formatProgress() = %q, want %q", got, want)
+	}
+
+	if got := formatProgress(app.Event{Bytes: 3 << 20}); got != "Downloading 3.0 MB" {
+		t.Fatalf("formatProgress() without total = %q", got)
+	}
+}
+
+func TestSearchDetailsOpensSupportLink(t *testing.T) {
+	var opened []string
+	previous := openURL
+	openURL = func(target string) error {
+		opened = append(opened, target)
+		return nil
+	}
+	t.Cleanup(func() { openURL = previous })
+
+	a := newTestApp(t)
+	m := newModel(context.Background(), a)
+	m.commandMenu.active = false
+	m.search.active = true
+	m.search.context = "subscriptions"
+	m.search.details = detailView{active: true, podcast: app.SearchResult{
+		Podcast:      itunes.Podcast{ID: "pod", Title: "Pod"},
+		IsSubscribed: true,
+		Funding: []domain.Funding{
+			{URL: "https://example.com/donate", Label: "Support us"},
+			{URL: "https://example.com/patreon"},
+		},
+	}}
+
+	view := m.View()
+	for _, want := range []string{"[o] to open the support link", "Support this show: Support us - https://example.com/donate", "Support this show: https://example.com/patreon"} {
+		if !strings.Contains(view, want) {
+			t.Fatalf("expected %q in view, got: %s", want, view)
+		}
+	}
+
+	updated, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'o'}})
+	m = updated.(model)
+	if cmd == nil || len(opened) != 1 || opened[0] != "https://example.com/donate" {
+		t.Fatalf("expected the first support link to open, got %q", opened)
+	}
+	if !strings.Contains(m.View(), "Opened https://example.com/donate") {
+		t.Fatalf("expected confirmation toast, got: %s", m.View())
+	}
+}
+
+func TestValueBlockLines(t *testing.T) {
+	lines := valueBlockLines(domain.ValueBlock{Type: "lightning", Method: "keysend", Suggested: "0.00000005000", Recipients: []domain.ValueRecipient{
+		{Name: "Host", Type: "node", Address: "02d5", Split: 50},
+		{Name: "Cohost", Type: "node", Address: "032f", Split: 100},
+		{Type: "node", Address: "03ae", Split: 1, Fee: true},
+	}})
+	want := []string{
+		"Value for value: lightning (keysend), suggested 0.00000005000 per minute",
+		"  Host: 33.3% - node 02d5",
+		"  Cohost: 66.7% - node 032f",
+		"  Unnamed: 1% fee - node 03ae",
+	}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("valueBlockLines() = %q, want %q", lines, want)
+	}
+}
+
+func TestEpisodeDetailsPlaysSoundbite(t *testing.T) {
+	var started [][]string
+	previous := startPlayer
+	startPlayer = func(args []string) error {
+		started = append(started, args)
+		return nil
+	}
+	t.Cleanup(func() { startPlayer = previous })
+
+	a := newTestAppWithConfig(t, func(cfg *config.Config) {
+		cfg.Player = "mpv --no-video --start={start}"
+	})
+	m := model{
+		ctx:   context.Background(),
+		app:   a,
+		theme: theme.ForName(a.Config().ColorTheme),
+		episodes: episodeView{
+			details: episodeDetailView{
+				active: true,
+				detail: app.EpisodeDetail{
+					ID:           "ep-1",
+					Title:        "Episode",
+					EnclosureURL: "https://example.com/ep.mp3",
+					Soundbites: []domain.Soundbite{
+						{StartSec: 73.5, DurationSec: 60, Title: "The best bit"},
+						{StartSec: 1234, DurationSec: 42},
+					},
+				},
+			},
+		},
+		longDescCache: make(map[string]string),
+	}
+
+	view := m.renderEpisodeDetails()
+	for _, want := range []string{"[1] 1:13 The best bit (60s)", "[2] 20:34 (42s)", "Press [1-2] to play a soundbite."} {
+		if !strings.Contains(view, want) {
+			t.Fatalf("expected %q in view, got: %s", want, view)
+		}
+	}
+
+	updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'1'}})
+	m = updated.(model)
+	want := []string{"mpv", "--no-video", "--start=73.5", "https://example.com/ep.mp3"}
+	if len(started) != 1 || strings.Join(started[0], " ") != strings.Join(want, " ") {
+		t.Fatalf("unexpected player command %q, want %q", started, want)
+	}
+	if !strings.Contains(m.View(), "Playing from 1:13") {
+		t.Fatalf("expected playing toast, got: %s", m.View())
+	}
+
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'3'}})
+	m = updated.(model)
+	if len(started) != 1 {
+		t.Fatalf("a key without a soundbite should do nothing, got %q", started)
+	}
+
+	// A feed-supplied enclosure must not reach the player as an option
+	m.episodes.details.detail.EnclosureURL = "--script=/tmp/evil.lua"
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'1'}})
+	m = updated.(model)
+	if len(started) != 1 {
+		t.Fatalf("expected the player not to start, got %q", started)
+	}
+	if !strings.Contains(m.View(), "Could not start player") {
+		t.Fatalf("expected an error toast, got: %s", m.View())
+	}
+}
+
+func TestEpisodeQueryLabels(t *testing.T) {
+	cases := []struct {
+		query, order, bounds string
+	}{
+		{"", "Newest First", ""},
+		{"shortest", "Shortest First", ""},
+		{"under 30 over 10 longest", "Longest First", "under 30 min, over 10 min"},
+		{"--state NEW --podcast 'Go Time' --since 2024-01-01", "Newest First", "state NEW, podcast Go Time, since 2024-01-01"},
+	}
+	for _, tc := range cases {
+		order, bounds := episodeQueryLabels(tc.query)
+		if order != tc.order || bounds != tc.bounds {
+			t.Fatalf("episodeQueryLabels(%q) = %q, %q; want %q, %q", tc.query, order, bounds, tc.order, tc.bounds)
+		}
+	}
+}
+
+func TestArtworkProtocolFromEnvironment(t *testing.T) {
+	t.Setenv("KITTY_WINDOW_ID", "")
+	t.Setenv("TERM_PROGRAM", "")
+	t.Setenv("TERM", "xterm-256color")
+	if got := artworkProtocol(config.ArtworkAuto); got != config.ArtworkNone {
+		t.Fatalf("artworkProtocol() in xterm = %q", got)
+	}
+	t.Setenv("TERM", "foot")
+	if got := artworkProtocol(config.ArtworkAuto); got != config.ArtworkSixel {
+		t.Fatalf("artworkProtocol() in foot = %q", got)
+	}
+	t.Setenv("KITTY_WINDOW_ID", "1")
+	if got := artworkProtocol(config.ArtworkAuto); got != config.ArtworkKitty {
+		t.Fatalf("artworkProtocol() in kitty = %q", got)
+	}
+	if got := artworkProtocol(config.ArtworkNone); got != config.ArtworkNone {
+		t.Fatalf("artworkProtocol() should honour the setting, got %q", got)
+	}
+}
+
+func TestEncodeArtwork(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 600, 300))
+	for x := 0; x < 300; x++ {
+		for y := 0; y < 300; y++ {
+			img.Set(x, y, color.RGBA{R: 255, A: 255})
+		}
+	}
+	path := filepath.Join(t.TempDir(), "cover.png")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if err := png.Encode(f, img); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	f.Close()
+
+	sixel, err := encodeArtwork(path, config.ArtworkSixel)
+	if err != nil {
+		t.Fatalf("encodeArtwork(sixel) error = %v", err)
+	}
+	if !strings.HasPrefix(sixel, "\x1bPq\"1;1;320;160") || !strings.HasSuffix(sixel, "-\x1b\\") {
+		t.Fatalf("unexpected sixel framing: %q...", sixel[:min(len(sixel), 40)])
+	}
+
+	kitty, err := encodeArtwork(path, config.ArtworkKitty)
+	if err != nil {
+		t.Fatalf("encodeArtwork(kitty) error = %v", err)
+	}
+	if !strings.HasPrefix(kitty, "\x1b_Ga=T,f=100,") || !strings.HasSuffix(kitty, "\x1b\\") {
+		t.Fatalf("unexpected kitty framing: %q...", kitty[:min(len(kitty), 40)])
+	}
+
+	if _, err := encodeArtwork(path, config.ArtworkNone); err == nil {
+		t.Fatal("expected an error without a graphics protocol")
+	}
+}
+
+func TestWriteSixelRunCompressesRepeats(t *testing.T) {
+	var b strings.Builder
+	writeSixelRun(&b, []byte("????~~~AAAAA"))
+	if got := b.String(); got != "!4?~~~!5A" {
+		t.Fatalf("writeSixelRun() = %q", got)
+	}
+}
+
+func TestPodcastOrigin(t *testing.T) {
+	cases := []struct {
+		podcast itunes.Podcast
+		want    string
+	}{
+		{itunes.Podcast{FeedURL: "https://www.Example.com/feed.xml", EpisodeCount: 120}, "example.com, 120 episodes"},
+		{itunes.Podcast{FeedURL: "https://feeds.example.org/rss", EpisodeCount: 1}, "feeds.example.org, 1 episode"},
+		{itunes.Podcast{}, "unknown host"},
+	}
+	for _, tc := range cases {
+		if got := podcastOrigin(tc.podcast); got != tc.want {
+			t.Fatalf("podcastOrigin(%+v) = %q, want %q", tc.podcast, got, tc.want)
+		}
+	}
+}
+
+func TestHistoryViewListsSubscriptionChanges(t *testing.T) {
+	a := newTestApp(t)
+	m := newModel(context.Background(), a)
+
+	updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'h'}})
+	m = updated.(model)
+	if !m.history.active || !strings.Contains(m.View(), "No subscription changes recorded.") {
+		t.Fatalf("expected 'h' to open the empty history, got: %s", m.View())
+	}
+
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	m = updated.(model)
+	if m.history.active || !m.commandMenu.active {
+		t.Fatal("expected Esc to return from the history to the menu")
+	}
+
+	updated, _ = m.handleCommandResult(app.CommandResult{SubscriptionHistory: []app.SubscriptionEvent{{
+		PodcastID: "pod1",
+		Title:     "Gone Podcast",
+		FeedURL:   "http://example.com/feed",
+		Action:    domain.SubscriptionRemoved,
+		Source:    domain.SourceUser,
+		At:        time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC),
+	}}})
+	m = updated.(model)
+	view := m.View()
+	if !strings.Contains(view, "unsubscribed") || !strings.Contains(view, "Gone Podcast") || !strings.Contains(view, "pod1 via user") {
+		t.Fatalf("expected the unsubscribe in the history, got: %s", view)
+	}
+}
+
+func TestStartupViewOpensInsteadOfMenu(t *testing.T) {
+	a := newTestAppWithConfig(t, func(cfg *config.Config) {
+		cfg.StartupView = config.ViewQueue
+	})
+	m := newModel(context.Background(), a)
+	if m.commandMenu.active || !m.queue.active {
+		t.Fatalf("expected the queue on start, got: %s", m.View())
+	}
+
+	updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	m = updated.(model)
+	if !m.commandMenu.active || m.queue.active {
+		t.Fatal("expected Esc to leave the startup view for the menu")
+	}
+
+	// Without subscriptions there is nothing to list, so the menu stays.
+	a = newTestAppWithConfig(t, func(cfg *config.Config) {
+		cfg.StartupView = config.ViewSubscriptions
+	})
+	m = newModel(context.Background(), a)
+	if !m.commandMenu.active || m.search.active {
+		t.Fatalf("expected the menu when there are no subscriptions, got: %s", m.View())
+	}
+}
+
+func TestViewShortcutsSwitchBetweenListViews(t *testing.T) {
+	a := newTestApp(t)
+	m := newModel(context.Background(), a)
+
+	press := func(key rune) {
+		t.Helper()
+		updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{key}})
+		m = updated.(model)
+	}
+
+	press('q')
+	if !m.queue.active {
+		t.Fatalf("expected 'q' in the menu to open the queue, got: %s", m.View())
+	}
+	press('d')
+	if m.queue.active || !m.downloads.active || m.commandMenu.active {
+		t.Fatalf("expected 'd' in the queue to switch to downloads, got: %s", m.View())
+	}
+	press('h')
+	if m.downloads.active || !m.history.active {
+		t.Fatalf("expected 'h' in downloads to switch to the history, got: %s", m.View())
+	}
+	press('q')
+	if m.history.active || !m.queue.active {
+		t.Fatalf("expected 'q' in the history to switch to the queue, got: %s", m.View())
+	}
+
+	// The queue binds p and c itself, so they do not leave it.
+	press('c')
+	if !m.queue.active || m.commandMenu.active {
+		t.Fatal("expected 'c' to stay a queue key")
+	}
+}
+
+func TestEpisodeListLoadsMorePagesWhenScrolling(t *testing.T) {
+	a := newTestApp(t)
+	ctx := context.Background()
+	for _, feed := range []string{"http://example.com/a.xml", "http://example.com/b.xml", "http://example.com/c.xml"} {
+		if _, err := a.Execute(ctx, "subscribe "+feed); err != nil {
+			t.Fatalf("subscribe %s: %v", feed, err)
+		}
+	}
+	first, err := a.Execute(ctx, "episodes --limit 1")
+	if err != nil || len(first.EpisodeResults) != 1 || !first.EpisodesMore {
+		t.Fatalf("expected one of several episodes, got %+v, %v", first, err)
+	}
+
+	m := newModel(ctx, a)
+	m.commandMenu.active = false
+	updated, _ := m.handleCommandResult(first)
+	m = updated.(model)
+	if !strings.Contains(m.View(), "of 1+") {
+		t.Fatalf("expected the header to show that more episodes follow, got: %s", m.View())
+	}
+
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyDown})
+	m = updated.(model)
+	if len(m.episodes.results) != 3 || m.episodes.more || m.episodes.cursor != 1 {
+		t.Fatalf("expected moving down to load the remaining episodes, got %d (more %v, cursor %d)",
+			len(m.episodes.results), m.episodes.more, m.episodes.cursor)
+	}
+}
+
+func TestCommandMenuBadgesNewEpisodes(t *testing.T) {
+	a := newTestApp(t)
+	ctx := context.Background()
+	if _, err := a.Execute(ctx, "subscribe http://example.com/feed.xml"); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+
+	m := newModel(ctx, a)
+	view := m.View()
+	if !strings.Contains(view, " 1 new ") {
+		t.Fatalf("expected the episodes entry to badge the new episode, got: %s", view)
+	}
+	if strings.Contains(view, "failed") {
+		t.Fatalf("expected no failed badge without failed downloads, got: %s", view)
+	}
+
+	// Listing the episodes leaves them new; opening one marks it seen.
+	updated, _ := m.openView(viewShortcuts["e"])
+	m = updated.(model)
+	if m.newCount != 1 {
+		t.Fatalf("expected listing to keep the episode new, got %d new", m.newCount)
+	}
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = updated.(model)
+	if !m.episodes.details.active || m.episodes.details.detail.State != "SEEN" {
+		t.Fatalf("expected the details to show the episode seen, got %+v", m.episodes.details.detail)
+	}
+	m.refreshCounts()
+	if m.newCount != 0 {
+		t.Fatalf("expected the badge to go once the episode is seen, got %d new", m.newCount)
+	}
+}
+
+func TestSubscriptionEnterOpensItsEpisodes(t *testing.T) {
+	a := newTestApp(t)
+	ctx := context.Background()
+	for _, feed := range []string{"http://example.com/a.xml", "http://example.com/b.xml"} {
+		if _, err := a.Execute(ctx, "subscribe "+feed); err != nil {
+			t.Fatalf("subscribe %s: %v", feed, err)
+		}
+	}
+
+	m := newModel(ctx, a)
+	updated, _ := m.openView("list subscriptions")
+	m = updated.(model)
+	if !m.search.active || len(m.search.results) != 2 {
+		t.Fatalf("expected two subscriptions, got: %s", m.View())
+	}
+	press := func(msg tea.KeyMsg) {
+		updated, _ := m.Update(msg)
+		m = updated.(model)
+	}
+	press(tea.KeyMsg{Type: tea.KeyDown})
+	podcastID := m.search.results[1].Podcast.ID
+
+	press(tea.KeyMsg{Type: tea.KeyEnter})
+	if m.search.active || !m.episodes.active || m.episodes.podcast != podcastID {
+		t.Fatalf("expected Enter to list the podcast's episodes, got: %s", m.View())
+	}
+	if len(m.episodes.results) != 1 || m.episodes.results[0].PodcastID != podcastID {
+		t.Fatalf("expected only the podcast's episode, got %+v", m.episodes.results)
+	}
+	if !strings.Contains(m.View(), "Stub Podcast: ") {
+		t.Fatalf("expected the header to name the podcast, got: %s", m.View())
+	}
+
+	press(tea.KeyMsg{Type: tea.KeyEsc})
+	if m.episodes.active || !m.search.active || m.search.context != "subscriptions" || m.search.cursor != 1 {
+		t.Fatalf("expected Esc to return to the subscription, got: %s", m.View())
+	}
+
+	press(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("i")})
+	if !m.search.details.active || m.search.details.podcast.Podcast.ID != podcastID {
+		t.Fatal("expected [i] to show the subscription's details")
+	}
+}
+
+func TestSubscriptionsSortByDiskUsage(t *testing.T) {
+	m := newModel(context.Background(), newTestApp(t))
+	m.commandMenu.active = false
+	// Listed by title, as list subscriptions does
+	listing := func() app.CommandResult {
+		return app.CommandResult{
+			SearchContext: "subscriptions",
+			SearchTitle:   "Subscriptions",
+			SearchResults: []app.SearchResult{
+				{Podcast: itunes.Podcast{ID: "a", Title: "Alpha"}, IsSubscribed: true, DiskBytes: 1 << 20},
+				{Podcast: itunes.Podcast{ID: "b", Title: "Beta"}, IsSubscribed: true},
+				{Podcast: itunes.Podcast{ID: "c", Title: "Gamma"}, IsSubscribed: true, DiskBytes: 300 << 20},
+			},
+		}
+	}
+	updated, _ := m.handleCommandResult(listing())
+	m = updated.(model)
+	if !strings.Contains(m.View(), "300.0 MB on disk") || strings.Contains(m.View(), "by disk usage") {
+		t.Fatalf("expected sizes in title order, got: %s", m.View())
+	}
+	order := func() string {
+		var ids []string
+		for _, result := range m.search.results {
+			ids = append(ids, result.Podcast.ID)
+		}
+		return strings.Join(ids, "")
+	}
+
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'o'}})
+	m = updated.(model)
+	if order() != "cab" || m.search.cursor != 1 || !strings.Contains(m.View(), "Subscriptions by disk usage") {
+		t.Fatalf("expected [o] to sort by size keeping the cursor on Alpha, got %s at %d", order(), m.search.cursor)
+	}
+
+	// Reloading the listing keeps the order
+	updated, _ = m.handleCommandResult(listing())
+	m = updated.(model)
+	if order() != "cab" {
+		t.Fatalf("expected the size order to stick, got %s", order())
+	}
+
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'o'}})
+	m = updated.(model)
+	if order() != "abc" {
+		t.Fatalf("expected [o] to return to title order, got %s", order())
+	}
+}
+
+func TestEpisodeSelectionRunsBulkActions(t *testing.T) {
+	a := newTestApp(t)
+	ctx := context.Background()
+	for _, feed := range []string{"http://example.com/a.xml", "http://example.com/b.xml", "http://example.com/c.xml"} {
+		if _, err := a.Execute(ctx, "subscribe "+feed); err != nil {
+			t.Fatalf("subscribe %s: %v", feed, err)
+		}
+	}
+
+	m := newModel(ctx, a)
+	updated, _ := m.openView("episodes")
+	m = updated.(model)
+	if !m.episodes.active || len(m.episodes.results) != 3 {
+		t.Fatalf("expected three episodes, got: %s", m.View())
+	}
+	press := func(msg tea.KeyMsg) {
+		updated, _ := m.Update(msg)
+		m = updated.(model)
+	}
+
+	press(tea.KeyMsg{Type: tea.KeySpace})
+	press(tea.KeyMsg{Type: tea.KeySpace})
+	if len(m.episodes.selected) != 2 || m.episodes.cursor != 2 {
+		t.Fatalf("expected space to select two episodes and move on, got %v at %d", m.episodes.selected, m.episodes.cursor)
+	}
+	if !strings.Contains(m.View(), "2 selected") {
+		t.Fatalf("expected the header to count the selection, got: %s", m.View())
+	}
+	press(tea.KeyMsg{Type: tea.KeyEsc})
+	if !m.episodes.active || len(m.episodes.selected) != 0 {
+		t.Fatal("expected Esc to clear the selection first")
+	}
+
+	press(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("*")})
+	if len(m.episodes.selected) != 3 {
+		t.Fatalf("expected * to select every episode, got %v", m.episodes.selected)
+	}
+	press(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("d")})
+	if len(m.episodes.selected) != 0 || !m.episodes.active {
+		t.Fatal("expected the selection to clear after the bulk action")
+	}
+	if m.toast != "Queued 3 of 3 episode(s) for download." {
+		t.Fatalf("unexpected toast %q", m.toast)
+	}
+	for _, result := range m.episodes.results {
+		if result.Episode.State != "QUEUED" {
+			t.Fatalf("expected the reloaded list to show every episode queued, got %+v", m.episodes.results)
+		}
+	}
+}
+
+func TestEpisodeViewFilterAndOrderPersist(t *testing.T) {
+	a := newTestApp(t)
+	ctx := context.Background()
+	if _, err := a.Execute(ctx, "subscribe http://example.com/feed.xml"); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+
+	m := newModel(ctx, a)
+	updated, _ := m.openView(viewShortcuts["e"])
+	m = updated.(model)
+	press := func(msg tea.KeyMsg) {
+		updated, _ := m.Update(msg)
+		m = updated.(model)
+	}
+	press(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("o")})
+	if m.episodes.query != "shortest" || !strings.Contains(m.View(), "Shortest First") {
+		t.Fatalf("expected [o] to sort shortest first, got query %q", m.episodes.query)
+	}
+	press(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("D")})
+	if m.episodes.filterMode != "downloaded" {
+		t.Fatalf("expected [D] to show downloaded episodes, got %q", m.episodes.filterMode)
+	}
+
+	// A new session starts with the same filter and order
+	m = newModel(ctx, a)
+	if m.episodes.filterMode != "downloaded" || m.episodes.order != "shortest" {
+		t.Fatalf("expected the episode view to be restored, got %q, %q", m.episodes.filterMode, m.episodes.order)
+	}
+	updated, _ = m.openView(viewShortcuts["e"])
+	m = updated.(model)
+	if m.episodes.query != "shortest" {
+		t.Fatalf("expected the episode list to open shortest first, got %q", m.episodes.query)
+	}
+
+	press(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("D")})
+	if m.episodes.filterMode != "" {
+		t.Fatalf("expected [D] again to hide only ignored episodes, got %q", m.episodes.filterMode)
+	}
+	press(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("o")})
+	press(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("o")})
+	if m.episodes.query != "" {
+		t.Fatalf("expected [o] to return to newest first, got %q", m.episodes.query)
+	}
+	if view, err := a.EpisodeView(ctx); err != nil || view.Order != "" {
+		t.Fatalf("expected newest first to be saved, got %+v, %v", view, err)
+	}
+}
+
+func TestWithEpisodeOrderKeepsFilters(t *testing.T) {
+	for _, tc := range []struct{ query, order, want string }{
+		{"", "longest", "longest"},
+		{"pod-1 under 30 shortest", "longest", "pod-1 under 30 longest"},
+		{"--podcast longest shortest", "", "--podcast longest"},
+		{"--podcast 'Go Time' --since 2024-01-01", "shortest", "--podcast 'Go Time' --since 2024-01-01 shortest"},
+	} {
+		if got := withEpisodeOrder(tc.query, tc.order); got != tc.want {
+			t.Fatalf("withEpisodeOrder(%q, %q) = %q, want %q", tc.query, tc.order, got, tc.want)
+		}
+	}
+}
+
+func TestCommandPaletteFiltersAndRunsCommands(t *testing.T) {
+	a := newTestApp(t)
+	ctx := context.Background()
+	if _, err := a.Execute(ctx, "subscribe http://example.com/feed.xml"); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	m := newModel(ctx, a)
+	press := func(msg tea.KeyMsg) {
+		updated, _ := m.Update(msg)
+		m = updated.(model)
+	}
+	typeText := func(text string) {
+		for _, r := range text {
+			press(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+		}
+	}
+
+	press(tea.KeyMsg{Type: tea.KeyCtrlP})
+	if !m.palette.active || !strings.Contains(m.View(), "Command Palette") {
+		t.Fatalf("expected Ctrl+P to open the palette, got: %s", m.View())
+	}
+	typeText("histry")
+	if len(m.palette.entries) == 0 || m.palette.entries[0].command != "history" {
+		t.Fatalf("expected a fuzzy match for history first, got %+v", m.palette.entries)
+	}
+	press(tea.KeyMsg{Type: tea.KeyEnter})
+	if m.palette.active || !m.history.active {
+		t.Fatalf("expected Enter to run history, got: %s", m.View())
+	}
+
+	// A typed command line with arguments runs as typed.
+	press(tea.KeyMsg{Type: tea.KeyCtrlP})
+	typeText("list subscriptions")
+	press(tea.KeyMsg{Type: tea.KeyEnter})
+	if m.history.active || !m.search.active || m.search.context != "subscriptions" {
+		t.Fatalf("expected the typed line to list the subscriptions, got: %s", m.View())
+	}
+
+	// Both lines are offered again, latest first, and Esc leaves the view as it was.
+	press(tea.KeyMsg{Type: tea.KeyCtrlP})
+	if len(m.palette.entries) < 2 || m.palette.entries[0].command != "list subscriptions" || m.palette.entries[1].command != "history" || !m.palette.entries[0].recent {
+		t.Fatalf("expected the recent command lines first, got %+v", m.palette.entries[:2])
+	}
+	press(tea.KeyMsg{Type: tea.KeyEsc})
+	if m.palette.active || !m.search.active {
+		t.Fatalf("expected Esc to close the palette over the subscriptions, got: %s", m.View())
+	}
+}
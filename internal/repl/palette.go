@@ -0,0 +1,244 @@
+package repl
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"podsink/internal/fuzzy"
+)
+
+// maxRecentCommands is how many command lines the palette remembers.
+const maxRecentCommands = 10
+
+// paletteView finds a command, or a command line run before, by typing
+// part of it. It opens over any view with Ctrl+P.
+type paletteView struct {
+	active  bool
+	input   textinput.Model
+	entries []paletteEntry // Entries matching the input, best first
+	cursor  int
+}
+
+// paletteEntry is a command line the palette can run.
+type paletteEntry struct {
+	command string
+	usage   string // Arguments the command takes
+	summary string
+	recent  bool // A command line run from the palette before
+}
+
+// openPalette shows the palette over the current view.
+func (m model) openPalette() (tea.Model, tea.Cmd) {
+	input := textinput.New()
+	input.Prompt = "> "
+	input.Placeholder = "Type a command..."
+	input.CharLimit = 512
+	input.Width = 60
+	m.palette = paletteView{active: true, input: input}
+	m.filterPalette()
+	cmd := m.palette.input.Focus()
+	return m, cmd
+}
+
+// paletteEntries lists the recent command lines, latest first, then every
+// command and the views of the menu that are not commands.
+func (m model) paletteEntries() []paletteEntry {
+	entries := make([]paletteEntry, 0, len(m.recentCommands)+32)
+	for i := len(m.recentCommands) - 1; i >= 0; i-- {
+		entries = append(entries, paletteEntry{command: m.recentCommands[i], summary: "recent", recent: true})
+	}
+	for _, info := range m.app.Commands() {
+		usage := strings.TrimSpace(strings.TrimPrefix(info.Usage, info.Name))
+		entries = append(entries, paletteEntry{command: info.Name, usage: usage, summary: info.Summary})
+	}
+	entries = append(entries, paletteEntry{command: "secrets", summary: "Enter API keys and passwords with masked input"})
+	return entries
+}
+
+// filterPalette keeps the entries that match the input fuzzily, best match
+// first. Commands are matched by the first word only, so arguments typed
+// after it keep the command listed.
+func (m *model) filterPalette() {
+	query := strings.TrimSpace(m.palette.input.Value())
+	entries := m.paletteEntries()
+	m.palette.cursor = 0
+	if query == "" {
+		m.palette.entries = entries
+		return
+	}
+	commandQuery := strings.Fields(query)[0]
+
+	type scored struct {
+		entry paletteEntry
+		score float64
+	}
+	var matches []scored
+	for _, entry := range entries {
+		q := commandQuery
+		if entry.recent {
+			q = query
+		}
+		text := entry.command + " " + entry.summary
+		if !fuzzy.ContainsFuzzy(text, q) {
+			continue
+		}
+		matches = append(matches, scored{entry: entry, score: fuzzy.MatchScore(entry.command, q)})
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].score > matches[j].score })
+	m.palette.entries = make([]paletteEntry, 0, len(matches))
+	for _, match := range matches {
+		m.palette.entries = append(m.palette.entries, match.entry)
+	}
+}
+
+func (m model) handlePaletteKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		m.quitting = true
+		return m, tea.Quit
+	case "esc", "ctrl+p":
+		m.palette = paletteView{}
+		return m, nil
+	case "up":
+		if m.palette.cursor > 0 {
+			m.palette.cursor--
+		}
+		return m, nil
+	case "down":
+		if m.palette.cursor < len(m.palette.entries)-1 {
+			m.palette.cursor++
+		}
+		return m, nil
+	case "tab":
+		// Complete the selected command to type its arguments
+		if m.palette.cursor < len(m.palette.entries) {
+			m.palette.input.SetValue(m.palette.entries[m.palette.cursor].command + " ")
+			m.palette.input.CursorEnd()
+			m.filterPalette()
+		}
+		return m, nil
+	case "enter":
+		line := m.paletteCommandLine()
+		if line == "" {
+			return m, nil
+		}
+		return m.runPaletteCommand(line)
+	}
+	var cmd tea.Cmd
+	m.palette.input, cmd = m.palette.input.Update(msg)
+	m.filterPalette()
+	return m, cmd
+}
+
+// paletteCommandLine returns what Enter runs: the typed line when it names
+// a command and gives it arguments, else the selected entry.
+func (m model) paletteCommandLine() string {
+	typed := strings.Fields(m.palette.input.Value())
+	if len(typed) > 1 && slices.Contains(m.app.CommandNames(), strings.ToLower(typed[0])) {
+		return strings.Join(typed, " ")
+	}
+	if m.palette.cursor < len(m.palette.entries) {
+		return m.palette.entries[m.palette.cursor].command
+	}
+	return ""
+}
+
+// runPaletteCommand closes the palette and the view below it and runs line.
+// A command without arguments that the menu offers runs like its menu entry.
+// Results without a view of their own are shown as a toast over the menu.
+func (m model) runPaletteCommand(line string) (tea.Model, tea.Cmd) {
+	m.palette = paletteView{}
+	m.rememberCommand(line)
+	m.closeListViews()
+	m.secrets = secretsView{}
+	m.refreshErrors = refreshErrorsView{}
+	m.refreshProgress.active = false
+	m.importProgress.active = false
+	m.searchInputMode = false
+
+	for _, item := range m.commandMenu.items {
+		if item.name == line {
+			return m.runMenuItem(line)
+		}
+	}
+
+	// An OPML import runs in the background while its progress is shown
+	if fields := strings.Fields(line); len(fields) > 1 && strings.EqualFold(fields[0], "import") && !strings.EqualFold(fields[1], "state-from") {
+		return m.startImport(line)
+	}
+
+	m.commandMenu.active = false
+	result, err := m.app.Execute(m.ctx, line)
+	if err != nil {
+		m.commandMenu.active = true
+		m.input.Blur()
+		return m, m.showToast(fmt.Sprintf("%s: %v", line, err))
+	}
+	updated, cmd := m.handleCommandResult(result)
+	m = updated.(model)
+	if m.commandMenu.active && result.Message != "" {
+		return m, tea.Batch(cmd, m.showToast(result.Message))
+	}
+	return m, cmd
+}
+
+// rememberCommand keeps line as the latest recent command line.
+func (m *model) rememberCommand(line string) {
+	m.recentCommands = slices.DeleteFunc(m.recentCommands, func(recent string) bool { return recent == line })
+	m.recentCommands = append(m.recentCommands, line)
+	if len(m.recentCommands) > maxRecentCommands {
+		m.recentCommands = m.recentCommands[len(m.recentCommands)-maxRecentCommands:]
+	}
+}
+
+func (m model) renderPalette() string {
+	var b strings.Builder
+	b.WriteString(m.theme.Header.Render("Command Palette"))
+	b.WriteString("\n")
+	b.WriteString(m.theme.Dim.Render("Type to filter, ↑↓ to select, Tab to add arguments, Enter to run, Esc to close"))
+	b.WriteString("\n\n")
+	b.WriteString(m.palette.input.View())
+	b.WriteString("\n\n")
+
+	entries := m.palette.entries
+	if len(entries) == 0 {
+		b.WriteString(m.theme.Dim.Render("No matching commands."))
+		b.WriteString("\n")
+		return b.String()
+	}
+
+	limit := m.app.Config().MaxEpisodes
+	if limit <= 0 {
+		limit = 12
+	}
+	start := 0
+	if m.palette.cursor >= limit {
+		start = m.palette.cursor - limit + 1
+	}
+	end := min(start+limit, len(entries))
+	for i, entry := range entries[start:end] {
+		cursor := "  "
+		style := m.theme.Normal
+		if start+i == m.palette.cursor {
+			cursor = "→ "
+			style = m.theme.Cursor
+		}
+		line := cursor + style.Render(entry.command)
+		if entry.usage != "" {
+			line += " " + m.theme.Dim.Render(entry.usage)
+		}
+		line += m.theme.Description.Render(" - " + entry.summary)
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+	if end < len(entries) {
+		b.WriteString(m.theme.Dim.Render(fmt.Sprintf("… %d more", len(entries)-end)))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
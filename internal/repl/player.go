@@ -0,0 +1,58 @@
+package repl
+
+import (
+	"fmt"
+	"net/url"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/kballard/go-shellquote"
+)
+
+// startPlayer runs the external player without waiting for it. Tests
+// replace it.
+var startPlayer = func(args []string) error {
+	cmd := exec.Command(args[0], args[1:]...)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go cmd.Wait()
+	return nil
+}
+
+// playerArgs turns the player setting into a command line, replacing {file}
+// and {start} in each argument. The file is appended when the command has no
+// {file}. It must be an absolute path or an http(s) URL: an enclosure URL
+// comes from the feed and could otherwise pass an option such as
+// --script=... to the player.
+func playerArgs(command, file string, startSec float64) ([]string, error) {
+	if !playable(file) {
+		return nil, fmt.Errorf("%q is not an absolute path or http(s) URL", file)
+	}
+	args, err := shellquote.Split(command)
+	if err != nil {
+		return nil, err
+	}
+	start := strconv.FormatFloat(startSec, 'f', -1, 64)
+	hasFile := false
+	for i, arg := range args {
+		if strings.Contains(arg, "{file}") {
+			hasFile = true
+		}
+		args[i] = strings.NewReplacer("{file}", file, "{start}", start).Replace(arg)
+	}
+	if !hasFile {
+		args = append(args, file)
+	}
+	return args, nil
+}
+
+func playable(file string) bool {
+	if filepath.IsAbs(file) {
+		return true
+	}
+	u, err := url.Parse(file)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
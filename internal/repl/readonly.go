@@ -0,0 +1,30 @@
+package repl
+
+import "slices"
+
+// readOnlyKey reports whether key would change the library in the active
+// view while the app is read-only. The views are checked in the order the
+// key handlers are.
+func (m model) readOnlyKey(key string) bool {
+	if !m.app.ReadOnly() {
+		return false
+	}
+	var keys []string
+	switch {
+	case m.refreshErrors.active, m.refreshProgress.active, m.importProgress.active, m.history.active, m.episodes.details.active, m.secrets.active, m.searchInputMode:
+		return false
+	case m.commandMenu.active:
+		keys = []string{"r"}
+	case m.search.details.active:
+		keys = []string{"s", "u"}
+	case m.search.active:
+		keys = []string{"s", "u", "r"}
+	case m.episodes.active:
+		keys = []string{"i", "d", "s", "delete", "r"}
+	case m.queue.active:
+		keys = []string{"p", " ", "c", "+", "-"}
+	case m.downloads.active:
+		keys = []string{"D", "delete", "d", "a"}
+	}
+	return slices.Contains(keys, key)
+}
@@ -0,0 +1,60 @@
+package repl
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"podsink/internal/app"
+)
+
+// refreshErrorsView lists the feeds a refresh could not update. It opens
+// over the current view once a refresh with failures finishes.
+type refreshErrorsView struct {
+	active   bool
+	failures []app.RefreshFailure
+	scroll   int
+}
+
+func (m model) handleRefreshErrorsKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		m.quitting = true
+		return m, tea.Quit
+	case "esc", "x", "enter":
+		m.refreshErrors = refreshErrorsView{}
+	case "up", "k":
+		if m.refreshErrors.scroll > 0 {
+			m.refreshErrors.scroll--
+		}
+	case "down", "j":
+		if m.refreshErrors.scroll < len(m.refreshErrors.failures)-1 {
+			m.refreshErrors.scroll++
+		}
+	}
+	return m, nil
+}
+
+func (m model) renderRefreshErrors() string {
+	var b strings.Builder
+	failures := m.refreshErrors.failures
+	b.WriteString(m.theme.Header.Render(fmt.Sprintf("Refresh errors (%d feed(s) failed)", len(failures))))
+	b.WriteString("\n")
+	b.WriteString(m.theme.Dim.Render("Use ↑↓/jk to scroll, Enter/[x]/Esc to close"))
+	b.WriteString("\n\n")
+
+	limit := m.app.Config().MaxEpisodes
+	end := min(m.refreshErrors.scroll+limit, len(failures))
+	for _, failure := range failures[m.refreshErrors.scroll:end] {
+		b.WriteString(m.theme.Normal.Render(failure.Title))
+		b.WriteString("\n")
+		b.WriteString(m.theme.Dim.Render("  " + failure.Err))
+		b.WriteString("\n")
+	}
+	if end < len(failures) {
+		b.WriteString(m.theme.Dim.Render(fmt.Sprintf("… %d more", len(failures)-end)))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
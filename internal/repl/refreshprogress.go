@@ -0,0 +1,111 @@
+package repl
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"podsink/internal/app"
+)
+
+// refreshProgressView follows a refresh of all feeds. It can be left while
+// the refresh keeps running and opened again from the main menu.
+type refreshProgressView struct {
+	active  bool // The view is shown
+	running bool
+	done    int
+	total   int
+	added   int
+	failed  int
+	last    string // Title of the feed handled last
+	result  string // Summary of the finished refresh
+}
+
+// openRefreshProgress shows the refresh progress from the main menu,
+// starting a refresh of all feeds unless one is running.
+func (m model) openRefreshProgress() (tea.Model, tea.Cmd) {
+	m.commandMenu.active = false
+	m.input.Blur()
+	m.refreshProgress.active = true
+	if m.refreshProgress.running {
+		return m, nil
+	}
+	return m, m.startRefresh("")
+}
+
+// trackRefresh updates the progress from a refresh event. A refresh starts
+// with an event for no feeds done; events older than the progress shown are
+// ignored.
+func (m *model) trackRefresh(event app.Event) {
+	p := &m.refreshProgress
+	switch {
+	case event.FeedsDone == 0:
+		*p = refreshProgressView{active: p.active}
+	case event.FeedsDone < p.done:
+		return
+	}
+	p.running = event.FeedsDone < event.FeedsTotal
+	p.done, p.total = event.FeedsDone, event.FeedsTotal
+	p.added, p.failed = event.Added, event.Failed
+	if event.Title != "" {
+		p.last = event.Title
+	}
+}
+
+func (m model) handleRefreshProgressKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		m.quitting = true
+		return m, tea.Quit
+	case "esc", "x":
+		m.refreshProgress.active = false
+		m.refreshCounts()
+		m.commandMenu.active = true
+	}
+	return m, nil
+}
+
+func (m model) renderRefreshProgress() string {
+	var b strings.Builder
+	p := m.refreshProgress
+	title := "Refreshing feeds"
+	if !p.running {
+		title = "Refresh finished"
+	}
+	b.WriteString(m.theme.Header.Render(title))
+	b.WriteString("\n")
+	hint := "[x]/Esc to return to main menu"
+	if p.running {
+		hint = "[x]/Esc to return to main menu; the refresh continues and [r] there brings you back"
+	}
+	b.WriteString(m.theme.Dim.Render(hint))
+	b.WriteString("\n\n")
+
+	if p.total > 0 {
+		b.WriteString(m.theme.Normal.Render(fmt.Sprintf("Feeds: %d / %d", p.done, p.total)))
+		b.WriteString("\n")
+		b.WriteString(m.theme.State.Render(fmt.Sprintf("New episodes: %d", p.added)))
+		b.WriteString("\n")
+		failures := fmt.Sprintf("Failures: %d", p.failed)
+		if p.failed > 0 {
+			b.WriteString(m.theme.Error.Render(failures))
+		} else {
+			b.WriteString(m.theme.Dim.Render(failures))
+		}
+		b.WriteString("\n")
+		if p.running && p.last != "" {
+			b.WriteString(m.theme.Dim.Render("Last: " + p.last))
+			b.WriteString("\n")
+		}
+	} else if p.running {
+		b.WriteString(m.theme.Dim.Render("Starting..."))
+		b.WriteString("\n")
+	}
+	if p.result != "" {
+		b.WriteString("\n")
+		b.WriteString(m.theme.Normal.Render(p.result))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
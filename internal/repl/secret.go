@@ -0,0 +1,143 @@
+package repl
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"podsink/internal/config"
+)
+
+// secretsView lists the credential settings and reads a new value for one
+// of them with masked input.
+type secretsView struct {
+	active  bool
+	cursor  int
+	editing bool // The prompt is open for the key under the cursor
+	prompt  textinput.Model
+}
+
+// newSecretPrompt returns a text input that shows a mask character for
+// every typed character instead of the character itself.
+func newSecretPrompt() textinput.Model {
+	prompt := textinput.New()
+	prompt.Prompt = "> "
+	prompt.EchoMode = textinput.EchoPassword
+	prompt.EchoCharacter = '•'
+	prompt.CharLimit = 512
+	prompt.Width = 60
+	return prompt
+}
+
+// openSecrets shows the credential list from the main menu.
+func (m model) openSecrets() (tea.Model, tea.Cmd) {
+	m.commandMenu.active = false
+	m.input.Blur()
+	m.secrets = secretsView{active: true, prompt: newSecretPrompt()}
+	return m, nil
+}
+
+func (m model) handleSecretsKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	key := config.SecretKeys[m.secrets.cursor]
+	if m.secrets.editing {
+		switch msg.Type {
+		case tea.KeyCtrlC:
+			m.quitting = true
+			return m, tea.Quit
+		case tea.KeyEsc:
+			m.secrets.editing = false
+			m.secrets.prompt.Reset()
+			m.secrets.prompt.Blur()
+			return m, nil
+		case tea.KeyEnter:
+			value := m.secrets.prompt.Value()
+			m.secrets.editing = false
+			m.secrets.prompt.Reset()
+			m.secrets.prompt.Blur()
+			if strings.TrimSpace(value) == "" {
+				return m, m.showToast(fmt.Sprintf("%s unchanged; press [d] to clear it.", key))
+			}
+			return m, m.saveSecret(key, value)
+		}
+		var cmd tea.Cmd
+		m.secrets.prompt, cmd = m.secrets.prompt.Update(msg)
+		return m, cmd
+	}
+
+	switch msg.String() {
+	case "ctrl+c":
+		m.quitting = true
+		return m, tea.Quit
+	case "esc", "x":
+		m.secrets = secretsView{}
+		m.refreshCounts()
+		m.commandMenu.active = true
+		return m, nil
+	case "up", "k":
+		if m.secrets.cursor > 0 {
+			m.secrets.cursor--
+		}
+	case "down", "j":
+		if m.secrets.cursor < len(config.SecretKeys)-1 {
+			m.secrets.cursor++
+		}
+	case "enter":
+		m.secrets.editing = true
+		return m, m.secrets.prompt.Focus()
+	case "d":
+		if value, _ := m.app.Config().Secret(key); value == "" {
+			return m, nil
+		}
+		return m, m.saveSecret(key, "")
+	}
+	return m, nil
+}
+
+// saveSecret stores the value and reports the outcome as a toast.
+func (m *model) saveSecret(key, value string) tea.Cmd {
+	if err := m.app.SetSecret(key, value); err != nil {
+		return m.showToast(fmt.Sprintf("Could not save %s: %v", key, err))
+	}
+	text := fmt.Sprintf("Saved %s.", key)
+	if value == "" {
+		text = fmt.Sprintf("Cleared %s.", key)
+	}
+	if key != "gpodder_password" {
+		text += " Restart podsink to use it."
+	}
+	return m.showToast(text)
+}
+
+func (m model) renderSecrets() string {
+	var b strings.Builder
+	b.WriteString(m.theme.Header.Render("Secrets"))
+	b.WriteString("\n")
+	if m.secrets.editing {
+		key := config.SecretKeys[m.secrets.cursor]
+		b.WriteString(m.theme.Dim.Render(fmt.Sprintf("Enter the new %s, Enter to save (Esc to cancel):", key)))
+		b.WriteString("\n\n")
+		b.WriteString(m.secrets.prompt.View())
+		b.WriteString("\n")
+		return b.String()
+	}
+	b.WriteString(m.theme.Dim.Render("Use ↑↓/jk to navigate, Enter to set, [d] to clear, [x]/Esc to return to main menu"))
+	b.WriteString("\n\n")
+
+	cfg := m.app.Config()
+	for i, key := range config.SecretKeys {
+		cursor, style := "  ", m.theme.Normal
+		if i == m.secrets.cursor {
+			cursor, style = "→ ", m.theme.Cursor
+		}
+		status := "not set"
+		if value, _ := cfg.Secret(key); value != "" {
+			status = "set"
+		}
+		b.WriteString(style.Render(fmt.Sprintf("%s%-22s", cursor, key)))
+		b.WriteString(m.theme.Dim.Render(status))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
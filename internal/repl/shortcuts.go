@@ -0,0 +1,95 @@
+package repl
+
+import (
+	"slices"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// viewShortcuts maps the main menu keys that open a view to the command
+// behind them.
+var viewShortcuts = map[string]string{
+	"p": "list subscriptions",
+	"e": pagedCommand("episodes", 0),
+	"q": "queue",
+	"d": "downloads",
+	"h": "history",
+	"c": "config",
+}
+
+// viewShortcut returns the command of a main menu shortcut pressed in a list
+// view. Keys the active view binds itself keep their meaning there, and
+// details views, prompts and the menu have no shortcuts of this kind.
+func (m model) viewShortcut(key string) (string, bool) {
+	command, ok := viewShortcuts[key]
+	if !ok {
+		return "", false
+	}
+	var taken []string
+	switch {
+	case m.search.details.active, m.episodes.details.active, m.secrets.active, m.searchInputMode, m.commandMenu.active:
+		return "", false
+	case m.history.active:
+	case m.search.active:
+		taken = []string{"q"}
+	case m.episodes.active:
+		taken = []string{"q", "d"}
+	case m.queue.active:
+		taken = []string{"p", "c"}
+	case m.downloads.active:
+		taken = []string{"d"}
+	default:
+		return "", false
+	}
+	if slices.Contains(taken, key) {
+		return "", false
+	}
+	return command, true
+}
+
+// openView runs the command of a main menu shortcut and shows its result,
+// leaving the list view open before. Without a view to show, or when the
+// command fails, the menu is shown.
+func (m model) openView(command string) (tea.Model, tea.Cmd) {
+	if command == viewShortcuts["e"] {
+		command = m.episodesCommand("")
+	}
+	m.closeListViews()
+	m.commandMenu.active = false
+	m.input.Focus()
+	result, err := m.app.Execute(m.ctx, command)
+	if err != nil {
+		// Error: return to menu
+		m.commandMenu.active = true
+		m.input.Blur()
+		return m, nil
+	}
+	return m.handleCommandResult(result)
+}
+
+// closeListViews leaves the search results, episodes, queue, downloads and
+// history lists the way Esc does.
+func (m *model) closeListViews() {
+	m.search.active = false
+	m.search.results = nil
+	m.search.title = ""
+	m.search.hint = ""
+	m.search.context = ""
+	m.search.details = detailView{}
+	m.episodes.active = false
+	m.episodes.results = nil
+	m.episodes.details = episodeDetailView{}
+	m.episodes.cursor = 0
+	m.episodes.scroll = 0
+	m.episodes.selected = nil
+	m.queue.active = false
+	m.queue.results = nil
+	m.queue.cursor = 0
+	m.downloads.active = false
+	m.downloads.results = nil
+	m.downloads.cursor = 0
+	m.downloads.scroll = 0
+	m.downloads.confirmDelete = ""
+	m.history = historyView{}
+	m.refreshCounts()
+}
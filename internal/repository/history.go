@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"podsink/internal/domain"
+)
+
+// RecordSubscriptionEvent appends an entry to the subscription history,
+// stamped with the current time when event.At is zero.
+func (s *Store) RecordSubscriptionEvent(ctx context.Context, event domain.SubscriptionEvent) error {
+	at := event.At
+	if at.IsZero() {
+		at = time.Now().UTC()
+	}
+	_, err := s.db.ExecContext(ctx, `INSERT INTO subscription_history (podcast_id, title, feed_url, action, source, at)
+VALUES (?, ?, ?, ?, ?, ?)`, event.PodcastID, event.Title, event.FeedURL, event.Action, event.Source, at.UTC().Format(time.RFC3339Nano))
+	return err
+}
+
+// ListSubscriptionEvents returns the subscription history, latest first.
+// A non-empty filter keeps the entries of the podcast with that ID and
+// those whose title or feed URL contain it, ignoring case.
+func (s *Store) ListSubscriptionEvents(ctx context.Context, filter string) ([]domain.SubscriptionEvent, error) {
+	stmt := `SELECT podcast_id, title, feed_url, action, source, at FROM subscription_history`
+	var args []any
+	if filter = strings.TrimSpace(filter); filter != "" {
+		pattern := "%" + escapeLike(filter) + "%"
+		stmt += ` WHERE podcast_id = ? OR title LIKE ? ESCAPE '\' OR feed_url LIKE ? ESCAPE '\'`
+		args = append(args, filter, pattern, pattern)
+	}
+	// Entries are appended as changes happen, so the ID orders them in time.
+	stmt += ` ORDER BY id DESC`
+
+	rows, err := s.db.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []domain.SubscriptionEvent{}
+	for rows.Next() {
+		var event domain.SubscriptionEvent
+		var at string
+		if err := rows.Scan(&event.PodcastID, &event.Title, &event.FeedURL, &event.Action, &event.Source, &at); err != nil {
+			return nil, err
+		}
+		if parsed, err := time.Parse(time.RFC3339Nano, at); err == nil {
+			event.At = parsed
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
@@ -0,0 +1,182 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"podsink/internal/domain"
+)
+
+// ReadEpisodeStates reads the episodes of another podsink database that
+// carry listening progress: every episode no longer NEW or with a saved
+// position. Columns missing from older schemas read as empty.
+func ReadEpisodeStates(ctx context.Context, db *sql.DB) ([]domain.EpisodeStateRecord, error) {
+	columns := map[string]map[string]bool{}
+	for _, table := range []string{"podcasts", "episodes"} {
+		columns[table] = map[string]bool{}
+		rows, err := db.QueryContext(ctx, "SELECT name FROM pragma_table_info(?)", table)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var name string
+			if err := rows.Scan(&name); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			columns[table][name] = true
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		if len(columns[table]) == 0 {
+			return nil, fmt.Errorf("not a podsink database: no %s table", table)
+		}
+	}
+	optional := func(table, alias, column, fallback string) string {
+		if columns[table][column] {
+			return fmt.Sprintf("COALESCE(%s.%s, %s)", alias, column, fallback)
+		}
+		return fallback
+	}
+	position := optional("episodes", "e", "position_seconds", "0")
+
+	rows, err := db.QueryContext(ctx, fmt.Sprintf(`SELECT p.feed_url, %s, e.id, e.enclosure_url, e.state, %s, %s
+FROM episodes e
+JOIN podcasts p ON p.id = e.podcast_id
+WHERE e.state != ? OR %s > 0`,
+		optional("podcasts", "p", "podcast_guid", "''"), position, optional("episodes", "e", "played_at", "''"), position),
+		domain.EpisodeStateNew)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []domain.EpisodeStateRecord
+	for rows.Next() {
+		var record domain.EpisodeStateRecord
+		if err := rows.Scan(&record.FeedURL, &record.PodcastGUID, &record.EpisodeID, &record.EnclosureURL, &record.State, &record.PositionSec, &record.PlayedAt); err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
+
+// MergeEpisodeStates applies the progress of episodes read from another
+// database to the same episodes here, in one transaction. Podcasts match by
+// podcast:guid or feed URL and episodes within them by ID or enclosure URL.
+// Progress is only ever added:
+//   - a PLAYED episode is played here too, unless it is queued for download
+//   - an IGNORED one is ignored here if it is NEW or SEEN
+//   - any other state but NEW makes a NEW episode SEEN
+//   - a later position replaces an earlier one of an episode not played here
+func (s *Store) MergeEpisodeStates(ctx context.Context, records []domain.EpisodeStateRecord) (domain.StateMergeResult, error) {
+	var result domain.StateMergeResult
+	err := s.withRetry(ctx, func() error {
+		result = domain.StateMergeResult{}
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		committed := false
+		defer func() {
+			if !committed {
+				tx.Rollback()
+			}
+		}()
+
+		podcasts, err := podcastKeys(ctx, tx)
+		if err != nil {
+			return err
+		}
+		for _, record := range records {
+			podcastID, ok := podcasts[strings.ToLower(record.PodcastGUID)]
+			if !ok {
+				podcastID, ok = podcasts[record.FeedURL]
+			}
+			if !ok {
+				result.Unmatched++
+				continue
+			}
+			var id, state string
+			var position int
+			err := tx.QueryRowContext(ctx, `SELECT id, state, COALESCE(position_seconds, 0) FROM episodes
+WHERE podcast_id = ? AND (id = ? OR (enclosure_url = ? AND enclosure_url != ''))
+ORDER BY id = ? DESC LIMIT 1`, podcastID, record.EpisodeID, record.EnclosureURL, record.EpisodeID).Scan(&id, &state, &position)
+			if errors.Is(err, sql.ErrNoRows) {
+				result.Unmatched++
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			result.Matched++
+
+			switch {
+			case record.State == domain.EpisodeStatePlayed:
+				if state == domain.EpisodeStatePlayed || state == domain.EpisodeStateQueued {
+					continue
+				}
+				var playedAt any
+				if record.PlayedAt != "" {
+					playedAt = record.PlayedAt
+				}
+				if _, err := tx.ExecContext(ctx, "UPDATE episodes SET state = ?, position_seconds = COALESCE(duration_seconds, 0), played_at = ? WHERE id = ?",
+					domain.EpisodeStatePlayed, playedAt, id); err != nil {
+					return err
+				}
+				result.Played++
+				continue
+			case record.State == domain.EpisodeStateIgnored && (state == domain.EpisodeStateNew || state == domain.EpisodeStateSeen):
+				if _, err := tx.ExecContext(ctx, "UPDATE episodes SET state = ? WHERE id = ?", domain.EpisodeStateIgnored, id); err != nil {
+					return err
+				}
+				result.Ignored++
+			case record.State != domain.EpisodeStateNew && state == domain.EpisodeStateNew:
+				if _, err := tx.ExecContext(ctx, "UPDATE episodes SET state = ? WHERE id = ?", domain.EpisodeStateSeen, id); err != nil {
+					return err
+				}
+				result.Seen++
+			}
+			if record.PositionSec > position && state != domain.EpisodeStatePlayed {
+				if _, err := tx.ExecContext(ctx, "UPDATE episodes SET position_seconds = ? WHERE id = ?", record.PositionSec, id); err != nil {
+					return err
+				}
+				result.Positions++
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		committed = true
+		return nil
+	})
+	return result, err
+}
+
+// podcastKeys maps the podcast:guid, in lower case, and the feed URL of
+// every podcast to its ID.
+func podcastKeys(ctx context.Context, tx *sql.Tx) (map[string]string, error) {
+	rows, err := tx.QueryContext(ctx, "SELECT id, feed_url, COALESCE(podcast_guid, '') FROM podcasts")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	keys := map[string]string{}
+	for rows.Next() {
+		var id, feedURL, guid string
+		if err := rows.Scan(&id, &feedURL, &guid); err != nil {
+			return nil, err
+		}
+		keys[feedURL] = id
+		if guid != "" {
+			keys[strings.ToLower(guid)] = id
+		}
+	}
+	return keys, rows.Err()
+}
@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"context"
+	"strings"
+
+	"podsink/internal/domain"
+)
+
+// PlaylistFilter narrows the downloads exported to a playlist.
+type PlaylistFilter struct {
+	PodcastID string // Only this podcast's episodes, "" for every subscription
+	Tag       string // Only podcasts with this tag or one nested below it, ignoring case
+	State     string // DOWNLOADED or PLAYED; "" for both
+}
+
+// ListPlaylistEntries returns the files of downloaded and played episodes
+// matching filter, by podcast and oldest first within each, which is the
+// order they are usually listened to.
+func (s *Store) ListPlaylistEntries(ctx context.Context, filter PlaylistFilter) ([]domain.PlaylistEntry, error) {
+	var stmt strings.Builder
+	stmt.WriteString(`SELECT e.id, e.title, p.title, e.file_path, COALESCE(e.duration_seconds, 0)
+FROM episodes e
+JOIN podcasts p ON p.id = e.podcast_id
+WHERE e.file_path IS NOT NULL AND e.file_path != ''`)
+	var args []any
+	if filter.State != "" {
+		stmt.WriteString("\n  AND e.state = ?")
+		args = append(args, filter.State)
+	} else {
+		stmt.WriteString("\n  AND e.state IN (?, ?)")
+		args = append(args, domain.EpisodeStateDownloaded, domain.EpisodeStatePlayed)
+	}
+	if filter.PodcastID != "" {
+		stmt.WriteString("\n  AND e.podcast_id = ?")
+		args = append(args, filter.PodcastID)
+	}
+	if filter.Tag != "" {
+		stmt.WriteString(`
+  AND EXISTS (SELECT 1 FROM podcast_tags t WHERE t.podcast_id = p.id AND (LOWER(t.tag) = LOWER(?) OR t.tag LIKE ? ESCAPE '\'))`)
+		args = append(args, filter.Tag, escapeLike(filter.Tag)+"/%")
+	}
+	stmt.WriteString(`
+ORDER BY LOWER(p.title), p.id,
+    CASE WHEN e.published_at IS NULL OR e.published_at = '' THEN 1 ELSE 0 END,
+    e.published_at,
+    LOWER(e.title)`)
+
+	rows, err := s.db.QueryContext(ctx, stmt.String(), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []domain.PlaylistEntry{}
+	for rows.Next() {
+		var entry domain.PlaylistEntry
+		if err := rows.Scan(&entry.EpisodeID, &entry.Title, &entry.PodcastTitle, &entry.FilePath, &entry.DurationSec); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
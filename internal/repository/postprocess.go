@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"podsink/internal/domain"
+)
+
+// ListPostProcessSteps returns the recorded post_process outcomes of an
+// episode.
+func (s *Store) ListPostProcessSteps(ctx context.Context, episodeID string) ([]domain.PostProcessRecord, error) {
+	return s.queryPostProcess(ctx, "WHERE pp.episode_id = ?", episodeID)
+}
+
+// ListFailedPostProcess returns the failed post_process steps of all
+// episodes, latest first.
+func (s *Store) ListFailedPostProcess(ctx context.Context) ([]domain.PostProcessRecord, error) {
+	return s.queryPostProcess(ctx, "WHERE pp.status = ?", domain.PostProcessFailed)
+}
+
+func (s *Store) queryPostProcess(ctx context.Context, where string, args ...any) ([]domain.PostProcessRecord, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT pp.episode_id, e.title, p.title, pp.step, pp.status, pp.error, pp.updated_at
+FROM post_process pp
+JOIN episodes e ON e.id = pp.episode_id
+JOIN podcasts p ON p.id = e.podcast_id
+`+where+`
+ORDER BY pp.updated_at DESC, pp.episode_id, pp.step`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records := []domain.PostProcessRecord{}
+	for rows.Next() {
+		var record domain.PostProcessRecord
+		var updatedAt string
+		if err := rows.Scan(&record.EpisodeID, &record.Title, &record.PodcastTitle, &record.Step, &record.Status, &record.Error, &updatedAt); err != nil {
+			return nil, err
+		}
+		if parsed, err := time.Parse(time.RFC3339Nano, updatedAt); err == nil {
+			record.UpdatedAt = parsed
+		}
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
+
+// RecordPostProcessStep stores the outcome of a post_process step. A step
+// that succeeded also records the episode's file path, hash and size, which
+// the step may have changed, like RecordTranscode.
+func (s *Store) RecordPostProcessStep(ctx context.Context, record domain.PostProcessRecord, filePath, hash string, size int64) error {
+	return s.withRetry(ctx, func() error {
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		committed := false
+		defer func() {
+			if !committed {
+				tx.Rollback()
+			}
+		}()
+
+		if _, err := tx.ExecContext(ctx, `INSERT OR REPLACE INTO post_process (episode_id, step, status, error, updated_at) VALUES (?, ?, ?, ?, ?)`,
+			record.EpisodeID, record.Step, record.Status, record.Error, time.Now().UTC().Format(time.RFC3339Nano)); err != nil {
+			return err
+		}
+		if record.Status == domain.PostProcessDone {
+			if err := recordProcessedFile(ctx, tx, record.EpisodeID, filePath, hash, size); err != nil {
+				return err
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		committed = true
+		return nil
+	})
+}
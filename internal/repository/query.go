@@ -0,0 +1,430 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"podsink/internal/domain"
+)
+
+// ErrInvalidQuery is returned for library queries that do not parse.
+var ErrInvalidQuery = errors.New("invalid query")
+
+type fieldKind int
+
+const (
+	fieldText fieldKind = iota
+	fieldNumber
+	fieldSize
+	fieldDate
+	fieldTag // Matches the podcast's tags rather than a column
+)
+
+// queryField maps a field of a library query onto a fixed SQL expression.
+// Only these expressions reach the statement; values are always bound.
+type queryField struct {
+	column string
+	kind   fieldKind
+}
+
+var queryFields = map[string]queryField{
+	"id":          {"e.id", fieldText},
+	"title":       {"e.title", fieldText},
+	"description": {"COALESCE(e.description, '')", fieldText},
+	"state":       {"e.state", fieldText},
+	"podcast":     {"p.title", fieldText},
+	"podcast_id":  {"p.id", fieldText},
+	"published":   {"substr(e.published_at, 1, 10)", fieldDate},
+	"downloaded":  {"substr(e.downloaded_at, 1, 10)", fieldDate},
+	"size":        {"COALESCE(e.size_bytes, 0)", fieldSize},
+	"duration":    {"NULLIF(e.duration_seconds, 0) / 60", fieldNumber},
+	"season":      {"COALESCE(e.season, 0)", fieldNumber},
+	"number":      {"COALESCE(e.episode_number, 0)", fieldNumber},
+	"retries":     {"COALESCE(e.retry_count, 0)", fieldNumber},
+	"tag":         {"", fieldTag},
+}
+
+// QueryFields returns the field names library queries accept, sorted.
+func QueryFields() []string {
+	names := make([]string, 0, len(queryFields))
+	for name := range queryFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// QueryEpisodes lists the episodes matching a library query such as
+//
+//	podcast ~ news and (state = downloaded or size > 100MB) order by published desc limit 20
+//
+// Conditions compare a field with =, !=, <, <=, >, >= or, for text, ~ and
+// !~ (contains, case-insensitive); they combine with and, or, not and
+// parentheses. Dates are written YYYY-MM-DD, duration is in minutes and
+// size takes an optional KB, MB or GB suffix. tag matches the podcast's
+// tags. Without an order clause the episodes are listed newest first.
+func (s *Store) QueryEpisodes(ctx context.Context, expr string) ([]domain.EpisodeResult, error) {
+	q, err := parseEpisodeQuery(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	var stmt strings.Builder
+	stmt.WriteString(`SELECT e.id, e.title, e.state, e.published_at, e.size_bytes, COALESCE(e.duration_seconds, 0), p.id, p.title
+FROM episodes e
+JOIN podcasts p ON p.id = e.podcast_id`)
+	if q.where != "" {
+		stmt.WriteString("\nWHERE " + q.where)
+	}
+	stmt.WriteString("\nORDER BY ")
+	for _, order := range q.order {
+		stmt.WriteString(order + ", ")
+	}
+	stmt.WriteString(`CASE WHEN e.published_at IS NULL OR e.published_at = '' THEN 1 ELSE 0 END,
+    e.published_at DESC,
+    LOWER(p.title),
+    LOWER(e.title)`)
+	args := q.args
+	if q.limit > 0 {
+		stmt.WriteString("\nLIMIT ?")
+		args = append(args, q.limit)
+	}
+
+	rows, err := s.db.QueryContext(ctx, stmt.String(), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanEpisodeResults(rows)
+}
+
+// episodeQuery is a parsed library query: a WHERE condition with its
+// arguments, ORDER BY terms and a limit (0 for none).
+type episodeQuery struct {
+	where string
+	args  []any
+	order []string
+	limit int
+}
+
+func parseEpisodeQuery(expr string) (episodeQuery, error) {
+	tokens, err := tokenizeQuery(expr)
+	if err != nil {
+		return episodeQuery{}, err
+	}
+	p := &queryParser{tokens: tokens}
+	var q episodeQuery
+	if !p.done() && !p.peekKeyword("order") && !p.peekKeyword("limit") {
+		if q.where, err = p.parseOr(); err != nil {
+			return episodeQuery{}, err
+		}
+	}
+	if p.keyword("order") {
+		if !p.keyword("by") {
+			return episodeQuery{}, queryErrorf("expected by after order")
+		}
+		for {
+			name, ok := p.next()
+			if !ok {
+				return episodeQuery{}, queryErrorf("expected a field to order by")
+			}
+			field, ok := queryFields[strings.ToLower(name.text)]
+			if !ok || name.quoted {
+				return episodeQuery{}, queryErrorf("unknown field %q", name.text)
+			}
+			if field.kind == fieldTag {
+				return episodeQuery{}, queryErrorf("cannot order by %s", name.text)
+			}
+			direction := "ASC"
+			if p.keyword("desc") {
+				direction = "DESC"
+			} else {
+				p.keyword("asc")
+			}
+			q.order = append(q.order, field.column+" "+direction)
+			if !p.punct(",") {
+				break
+			}
+		}
+	}
+	if p.keyword("limit") {
+		value, ok := p.next()
+		limit, err := strconv.Atoi(value.text)
+		if !ok || err != nil || limit <= 0 {
+			return episodeQuery{}, queryErrorf("limit needs a positive number")
+		}
+		q.limit = limit
+	}
+	if token, ok := p.next(); ok {
+		return episodeQuery{}, queryErrorf("unexpected %q", token.text)
+	}
+	q.args = p.args
+	return q, nil
+}
+
+type queryToken struct {
+	text   string
+	quoted bool // A quoted value, never a keyword, field or operator
+	punct  bool // An operator, parenthesis or comma
+}
+
+func tokenizeQuery(expr string) ([]queryToken, error) {
+	var tokens []queryToken
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c == '(' || c == ')' || c == ',' || c == '=' || c == '~':
+			tokens = append(tokens, queryToken{text: string(c), punct: true})
+			i++
+		case c == '!' || c == '<' || c == '>':
+			op := string(c)
+			if i+1 < len(expr) && (expr[i+1] == '=' || (c == '!' && expr[i+1] == '~')) {
+				op += string(expr[i+1])
+			}
+			if op == "!" {
+				return nil, queryErrorf("unexpected \"!\"")
+			}
+			tokens = append(tokens, queryToken{text: op, punct: true})
+			i += len(op)
+		case c == '"' || c == '\'':
+			end := strings.IndexByte(expr[i+1:], c)
+			if end < 0 {
+				return nil, queryErrorf("unterminated string")
+			}
+			tokens = append(tokens, queryToken{text: expr[i+1 : i+1+end], quoted: true})
+			i += end + 2
+		default:
+			start := i
+			for i < len(expr) && !strings.ContainsRune(" \t\n(),=~!<>\"'", rune(expr[i])) {
+				i++
+			}
+			tokens = append(tokens, queryToken{text: expr[start:i]})
+		}
+	}
+	return tokens, nil
+}
+
+type queryParser struct {
+	tokens []queryToken
+	pos    int
+	args   []any
+}
+
+func (p *queryParser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *queryParser) next() (queryToken, bool) {
+	if p.done() {
+		return queryToken{}, false
+	}
+	p.pos++
+	return p.tokens[p.pos-1], true
+}
+
+func (p *queryParser) peekKeyword(word string) bool {
+	if p.done() {
+		return false
+	}
+	token := p.tokens[p.pos]
+	return !token.quoted && !token.punct && strings.EqualFold(token.text, word)
+}
+
+// keyword consumes the next token when it is the given keyword.
+func (p *queryParser) keyword(word string) bool {
+	if !p.peekKeyword(word) {
+		return false
+	}
+	p.pos++
+	return true
+}
+
+// punct consumes the next token when it is the given operator or
+// punctuation.
+func (p *queryParser) punct(text string) bool {
+	if p.done() || !p.tokens[p.pos].punct || p.tokens[p.pos].text != text {
+		return false
+	}
+	p.pos++
+	return true
+}
+
+func queryErrorf(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", ErrInvalidQuery, fmt.Sprintf(format, args...))
+}
+
+func (p *queryParser) parseOr() (string, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return "", err
+	}
+	for p.keyword("or") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return "", err
+		}
+		left = "(" + left + " OR " + right + ")"
+	}
+	return left, nil
+}
+
+func (p *queryParser) parseAnd() (string, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return "", err
+	}
+	for p.keyword("and") {
+		right, err := p.parseNot()
+		if err != nil {
+			return "", err
+		}
+		left = "(" + left + " AND " + right + ")"
+	}
+	return left, nil
+}
+
+func (p *queryParser) parseNot() (string, error) {
+	if p.keyword("not") {
+		cond, err := p.parseNot()
+		if err != nil {
+			return "", err
+		}
+		return "NOT " + cond, nil
+	}
+	if p.punct("(") {
+		cond, err := p.parseOr()
+		if err != nil {
+			return "", err
+		}
+		if !p.punct(")") {
+			return "", queryErrorf("missing )")
+		}
+		return "(" + cond + ")", nil
+	}
+	return p.parseComparison()
+}
+
+func (p *queryParser) parseComparison() (string, error) {
+	name, ok := p.next()
+	if !ok {
+		return "", queryErrorf("expected a condition")
+	}
+	field, known := queryFields[strings.ToLower(name.text)]
+	if name.quoted || name.punct || !known {
+		return "", queryErrorf("unknown field %q", name.text)
+	}
+	op, ok := p.next()
+	if !ok || !op.punct || op.text == "(" || op.text == ")" || op.text == "," {
+		return "", queryErrorf("expected an operator after %s", name.text)
+	}
+	value, ok := p.next()
+	if !ok || value.punct {
+		return "", queryErrorf("expected a value after %s %s", name.text, op.text)
+	}
+
+	if field.kind == fieldTag {
+		return p.tagCondition(op.text, value.text)
+	}
+	sqlOp := op.text
+	if sqlOp == "!=" {
+		sqlOp = "<>"
+	}
+	if field.kind != fieldText && (op.text == "~" || op.text == "!~") {
+		return "", queryErrorf("%s does not support %s", name.text, op.text)
+	}
+	switch field.kind {
+	case fieldText:
+		switch op.text {
+		case "~", "!~":
+			pattern := "%" + escapeLike(value.text) + "%"
+			p.args = append(p.args, pattern)
+			if op.text == "!~" {
+				return field.column + ` NOT LIKE ? ESCAPE '\'`, nil
+			}
+			return field.column + ` LIKE ? ESCAPE '\'`, nil
+		case "=", "!=":
+			p.args = append(p.args, value.text)
+			return "LOWER(" + field.column + ") " + sqlOp + " LOWER(?)", nil
+		}
+		p.args = append(p.args, value.text)
+		return field.column + " " + sqlOp + " ?", nil
+	case fieldDate:
+		if _, err := time.Parse("2006-01-02", value.text); err != nil {
+			return "", queryErrorf("%s needs a date as YYYY-MM-DD, not %q", name.text, value.text)
+		}
+		p.args = append(p.args, value.text)
+	case fieldSize:
+		size, err := parseQuerySize(value.text)
+		if err != nil {
+			return "", queryErrorf("invalid size %q", value.text)
+		}
+		p.args = append(p.args, size)
+	default:
+		number, err := strconv.ParseInt(value.text, 10, 64)
+		if err != nil {
+			return "", queryErrorf("%s needs a number, not %q", name.text, value.text)
+		}
+		p.args = append(p.args, number)
+	}
+	return field.column + " " + sqlOp + " ?", nil
+}
+
+// tagCondition matches episodes of podcasts with a tag: = and != compare
+// whole tags, ignoring case, and also match tags nested below the value, so
+// "tag = news" matches "News/Tech". ~ and !~ look for the value within tags.
+func (p *queryParser) tagCondition(op, value string) (string, error) {
+	var match string
+	switch op {
+	case "=", "!=":
+		match = `(LOWER(t.tag) = LOWER(?) OR t.tag LIKE ? ESCAPE '\')`
+		p.args = append(p.args, value, escapeLike(value)+"/%")
+	case "~", "!~":
+		match = `t.tag LIKE ? ESCAPE '\'`
+		p.args = append(p.args, "%"+escapeLike(value)+"%")
+	default:
+		return "", queryErrorf("tag does not support %s", op)
+	}
+	exists := "EXISTS (SELECT 1 FROM podcast_tags t WHERE t.podcast_id = p.id AND " + match + ")"
+	if op == "!=" || op == "!~" {
+		return "NOT " + exists, nil
+	}
+	return exists, nil
+}
+
+// parseQuerySize reads a byte count with an optional K, M or G suffix,
+// which may be followed by B.
+func parseQuerySize(text string) (int64, error) {
+	upper := strings.ToUpper(text)
+	if len(upper) > 2 && strings.HasSuffix(upper, "B") {
+		upper = upper[:len(upper)-1]
+	}
+	shift := 0
+	switch {
+	case strings.HasSuffix(upper, "K"):
+		shift = 10
+	case strings.HasSuffix(upper, "M"):
+		shift = 20
+	case strings.HasSuffix(upper, "G"):
+		shift = 30
+	}
+	if shift > 0 {
+		upper = upper[:len(upper)-1]
+	}
+	n, err := strconv.ParseInt(upper, 10, 64)
+	if err != nil || n < 0 || n > math.MaxInt64>>shift {
+		return 0, errors.New("invalid size")
+	}
+	return n << shift, nil
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"context"
+	"strings"
+
+	"podsink/internal/domain"
+)
+
+// SearchEpisodes lists the episodes whose title or description contains
+// every term, best matches first with title matches weighing most. A term
+// with spaces matches as a phrase, and a trailing * matches any word
+// starting with the term. Without terms it returns nothing.
+func (s *Store) SearchEpisodes(ctx context.Context, terms []string, page Page) ([]domain.EpisodeResult, error) {
+	match := ftsMatch(terms)
+	if match == "" {
+		return nil, nil
+	}
+	clause, pageArgs := page.clause()
+	rows, err := s.db.QueryContext(ctx, `SELECT e.id, e.title, e.state, e.published_at, e.size_bytes, COALESCE(e.duration_seconds, 0), p.id, p.title
+FROM episodes_fts
+JOIN episodes e ON e.rowid = episodes_fts.rowid
+JOIN podcasts p ON p.id = e.podcast_id
+WHERE episodes_fts MATCH ?
+ORDER BY bm25(episodes_fts, 10.0, 1.0), e.published_at DESC, e.id`+clause, append([]any{match}, pageArgs...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanEpisodeResults(rows)
+}
+
+// ftsMatch turns search terms into an FTS5 query of quoted strings, so
+// operators and punctuation in a term are searched for rather than parsed.
+func ftsMatch(terms []string) string {
+	var parts []string
+	for _, term := range terms {
+		term = strings.TrimSpace(term)
+		prefix := strings.HasSuffix(term, "*")
+		term = strings.TrimSpace(strings.TrimRight(term, "*"))
+		if term == "" {
+			continue
+		}
+		part := `"` + strings.ReplaceAll(term, `"`, `""`) + `"`
+		if prefix {
+			part += "*"
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, " ")
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
@@ -15,10 +16,12 @@ import (
 
 type Store struct {
 	db *sql.DB
+	// writeSlot admits one write at a time; see write.
+	writeSlot chan struct{}
 }
 
 func New(db *sql.DB) *Store {
-	return &Store{db: db}
+	return &Store{db: db, writeSlot: make(chan struct{}, 1)}
 }
 
 func (s *Store) SubscriptionExists(ctx context.Context, podcastID string) (bool, string, error) {
@@ -33,10 +36,18 @@ func (s *Store) SubscriptionExists(ctx context.Context, podcastID string) (bool,
 	return true, title, nil
 }
 
+// SaveSubscription stores a podcast and its episodes, keeping the state of
+// episodes already stored, and returns how many episodes are new.
 func (s *Store) SaveSubscription(ctx context.Context, data domain.SubscriptionData) (int, error) {
+	added, err := s.SaveSubscriptionEpisodes(ctx, data)
+	return len(added), err
+}
+
+// SaveSubscriptionEpisodes is SaveSubscription returning the new episodes.
+func (s *Store) SaveSubscriptionEpisodes(ctx context.Context, data domain.SubscriptionData) ([]domain.AddedEpisode, error) {
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 	committed := false
 	defer func() {
@@ -55,14 +66,37 @@ func (s *Store) SaveSubscription(ctx context.Context, data domain.SubscriptionDa
 		subscribedAt = time.Now().UTC()
 	}
 
-	if _, err := tx.ExecContext(ctx, `INSERT INTO podcasts (id, title, feed_url, subscribed_at)
-VALUES (?, ?, ?, ?)
-ON CONFLICT(id) DO UPDATE SET title=excluded.title, feed_url=excluded.feed_url, subscribed_at=excluded.subscribed_at`,
-		data.Podcast.ID, title, data.Podcast.FeedURL, subscribedAt); err != nil {
-		return 0, err
+	refreshedAt := time.Now().UTC().Format(time.RFC3339Nano)
+	if _, err := tx.ExecContext(ctx, `INSERT INTO podcasts (id, title, feed_url, subscribed_at, last_refreshed_at, next_refresh_at, websub_hub, websub_topic, podcast_guid, language, artwork_url, download_dir)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT(id) DO UPDATE SET title=excluded.title, feed_url=excluded.feed_url, subscribed_at=excluded.subscribed_at, last_refreshed_at=excluded.last_refreshed_at, next_refresh_at=excluded.next_refresh_at, websub_hub=excluded.websub_hub, websub_topic=excluded.websub_topic,
+podcast_guid=COALESCE(NULLIF(excluded.podcast_guid, ''), podcasts.podcast_guid),
+language=COALESCE(NULLIF(excluded.language, ''), podcasts.language),
+artwork_url=COALESCE(NULLIF(excluded.artwork_url, ''), podcasts.artwork_url),
+download_dir=COALESCE(NULLIF(excluded.download_dir, ''), podcasts.download_dir)`,
+		data.Podcast.ID, title, data.Podcast.FeedURL, subscribedAt, refreshedAt, refreshTime(data.Podcast.NextRefresh),
+		data.Podcast.WebSubHub, data.Podcast.WebSubTopic, data.Podcast.GUID, data.Podcast.Language, data.Podcast.ArtworkURL, data.Podcast.DownloadDir); err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM podcast_funding WHERE podcast_id = ?", data.Podcast.ID); err != nil {
+		return nil, err
+	}
+	for i, funding := range data.Podcast.Funding {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO podcast_funding (podcast_id, position, url, label) VALUES (?, ?, ?, ?)",
+			data.Podcast.ID, i, funding.URL, funding.Label); err != nil {
+			return nil, err
+		}
+	}
+	if err := saveValue(ctx, tx, data.Podcast.ID, data.Podcast.Value); err != nil {
+		return nil, err
+	}
+	for _, tag := range data.Podcast.Tags {
+		if _, err := tx.ExecContext(ctx, "INSERT OR IGNORE INTO podcast_tags (podcast_id, tag) VALUES (?, ?)", data.Podcast.ID, tag); err != nil {
+			return nil, err
+		}
 	}
 
-	added := 0
+	var added []domain.AddedEpisode
 	for _, ep := range data.Episodes {
 		if strings.TrimSpace(ep.Enclosure) == "" {
 			continue
@@ -74,6 +108,18 @@ ON CONFLICT(id) DO UPDATE SET title=excluded.title, feed_url=excluded.feed_url,
 		if episodeID == "" {
 			continue
 		}
+		if linkID := strings.TrimSpace(ep.LinkID); linkID != "" {
+			if episodeID, err = storedEpisodeID(ctx, tx, data.Podcast.ID, episodeID, linkID, ep.Enclosure); err != nil {
+				return nil, err
+			}
+		}
+		var archived int
+		if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM archived_episodes WHERE id = ?", episodeID).Scan(&archived); err != nil {
+			return nil, err
+		}
+		if archived > 0 {
+			continue
+		}
 
 		epTitle := strings.TrimSpace(ep.Title)
 		if epTitle == "" {
@@ -86,14 +132,15 @@ ON CONFLICT(id) DO UPDATE SET title=excluded.title, feed_url=excluded.feed_url,
 		}
 
 		res, err := tx.ExecContext(ctx, `INSERT OR IGNORE INTO episodes
-(id, podcast_id, title, description, state, published_at, enclosure_url, size_bytes)
-VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
-			episodeID, data.Podcast.ID, epTitle, description, domain.EpisodeStateNew, published, ep.Enclosure, ep.SizeBytes)
+(id, podcast_id, title, description, state, published_at, enclosure_url, enclosure_type, size_bytes, duration_seconds, season, episode_number, image_url, chapters_url)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			episodeID, data.Podcast.ID, epTitle, description, domain.EpisodeStateNew, published, ep.Enclosure, ep.MimeType, ep.SizeBytes, ep.DurationSec,
+			ep.Season, ep.Number, ep.ImageURL, ep.ChaptersURL)
 		if err != nil {
-			return 0, err
+			return nil, err
 		}
 		if rows, _ := res.RowsAffected(); rows > 0 {
-			added++
+			added = append(added, domain.AddedEpisode{ID: episodeID, Title: epTitle})
 		}
 
 		if _, err := tx.ExecContext(ctx, `UPDATE episodes SET
@@ -101,389 +148,1777 @@ podcast_id = ?,
 title = ?,
 description = ?,
 enclosure_url = ?,
+enclosure_type = ?,
 published_at = COALESCE(?, published_at),
-size_bytes = ?
+size_bytes = CASE WHEN transcoded_at IS NULL THEN ? ELSE size_bytes END,
+duration_seconds = CASE WHEN ? > 0 THEN ? ELSE duration_seconds END,
+season = ?,
+episode_number = ?,
+image_url = ?,
+chapters_url = ?
 WHERE id = ?`,
-			data.Podcast.ID, epTitle, description, ep.Enclosure, published, ep.SizeBytes, episodeID); err != nil {
-			return 0, err
+			data.Podcast.ID, epTitle, description, ep.Enclosure, ep.MimeType, published, ep.SizeBytes, ep.DurationSec, ep.DurationSec,
+			ep.Season, ep.Number, ep.ImageURL, ep.ChaptersURL, episodeID); err != nil {
+			return nil, err
+		}
+
+		if _, err := tx.ExecContext(ctx, "DELETE FROM episode_soundbites WHERE episode_id = ?", episodeID); err != nil {
+			return nil, err
+		}
+		for i, bite := range ep.Soundbites {
+			if _, err := tx.ExecContext(ctx, "INSERT INTO episode_soundbites (episode_id, position, start_seconds, duration_seconds, title) VALUES (?, ?, ?, ?, ?)",
+				episodeID, i, bite.StartSec, bite.DurationSec, bite.Title); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if data.KeepEpisodes > 0 {
+		pruned, err := pruneEpisodes(ctx, tx, data.Podcast.ID, data.KeepEpisodes)
+		if err != nil {
+			return nil, err
+		}
+		if len(pruned) > 0 {
+			kept := added[:0]
+			for _, episode := range added {
+				if !pruned[episode.ID] {
+					kept = append(kept, episode)
+				}
+			}
+			added = kept
 		}
 	}
 
 	if err := tx.Commit(); err != nil {
-		return 0, err
+		return nil, err
 	}
 	committed = true
 	return added, nil
 }
 
-func (s *Store) DeleteSubscription(ctx context.Context, podcastID string) (bool, error) {
-	res, err := s.db.ExecContext(ctx, "DELETE FROM podcasts WHERE id = ?", podcastID)
+// pruneEpisodes archives the episodes of a podcast beyond the keep latest
+// that were never downloaded, queued or started, and returns their IDs.
+// Downloaded, played and deleted episodes stay, even beyond keep.
+func pruneEpisodes(ctx context.Context, tx *sql.Tx, podcastID string, keep int) (map[string]bool, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT id FROM (
+    SELECT id, state, file_path, position_seconds FROM episodes WHERE podcast_id = ?
+    ORDER BY published_at IS NULL, published_at DESC, rowid DESC
+    LIMIT -1 OFFSET ?
+)
+WHERE state IN (?, ?, ?, ?) AND COALESCE(file_path, '') = '' AND COALESCE(position_seconds, 0) = 0
+AND id NOT IN (SELECT episode_id FROM downloads)
+AND id NOT IN (SELECT episode_id FROM trash)`, podcastID, keep,
+		domain.EpisodeStateNew, domain.EpisodeStateSeen, domain.EpisodeStateIgnored, domain.EpisodeStateSkipped)
 	if err != nil {
-		return false, err
+		return nil, err
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	archivedAt := time.Now().UTC().Format(time.RFC3339Nano)
+	pruned := make(map[string]bool, len(ids))
+	for start := 0; start < len(ids); start += updateBatchSize {
+		batch := ids[start:min(start+updateBatchSize, len(ids))]
+		args := make([]any, 0, len(batch))
+		for _, id := range batch {
+			args = append(args, id)
+			pruned[id] = true
+		}
+		if _, err := tx.ExecContext(ctx, "INSERT OR IGNORE INTO archived_episodes (id, podcast_id, archived_at) SELECT id, podcast_id, ? FROM episodes WHERE id IN ("+placeholders(len(batch))+")",
+			append([]any{archivedAt}, args...)...); err != nil {
+			return nil, err
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM episodes WHERE id IN ("+placeholders(len(batch))+")", args...); err != nil {
+			return nil, err
+		}
+	}
+	return pruned, nil
+}
+
+// storedEpisodeID returns the ID an episode keyed by its enclosure URL is
+// already stored under: the ID itself, the permalink GUID it was keyed by
+// before, or an older permalink with the same enclosure after the publisher
+// changed their URL structure. It returns id when the episode is new.
+func storedEpisodeID(ctx context.Context, tx *sql.Tx, podcastID, id, linkID, enclosure string) (string, error) {
+	var stored string
+	err := tx.QueryRowContext(ctx, `SELECT id FROM episodes
+WHERE podcast_id = ? AND (id = ? OR id = ? OR enclosure_url = ?)
+ORDER BY id = ? DESC, id = ? DESC
+LIMIT 1`, podcastID, id, linkID, enclosure, id, linkID).Scan(&stored)
+	if errors.Is(err, sql.ErrNoRows) {
+		return id, nil
 	}
-	affected, err := res.RowsAffected()
 	if err != nil {
-		return false, err
+		return "", err
 	}
-	return affected > 0, nil
+	return stored, nil
 }
 
-func (s *Store) ListSubscriptionSummaries(ctx context.Context) ([]domain.SubscriptionSummary, error) {
-	rows, err := s.db.QueryContext(ctx, `SELECT
-p.id,
-p.title,
-COALESCE(SUM(CASE WHEN e.state = ? THEN 1 ELSE 0 END), 0) AS new_count,
-COALESCE(SUM(CASE WHEN e.state != ? AND e.id IS NOT NULL THEN 1 ELSE 0 END), 0) AS unplayed_count,
-COUNT(e.id) AS total_count
-FROM podcasts p
-LEFT JOIN episodes e ON e.podcast_id = p.id
-GROUP BY p.id, p.title
-ORDER BY LOWER(p.title)`, domain.EpisodeStateNew, domain.EpisodeStateDownloaded)
+// refreshTime formats next_refresh_at values with a fixed width so they
+// compare correctly as text. The zero time is stored as NULL, meaning due.
+func refreshTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// ListDuePodcasts returns the podcasts whose next scheduled refresh is not
+// after now.
+func (s *Store) ListDuePodcasts(ctx context.Context, now time.Time) ([]domain.Podcast, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, title, feed_url, subscribed_at FROM podcasts
+WHERE next_refresh_at IS NULL OR next_refresh_at <= ?
+ORDER BY next_refresh_at, LOWER(title)`, refreshTime(now))
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	summaries := make([]domain.SubscriptionSummary, 0, 8)
+	podcasts := make([]domain.Podcast, 0, 16)
 	for rows.Next() {
-		var summary domain.SubscriptionSummary
-		if err := rows.Scan(&summary.ID, &summary.Title, &summary.NewCount, &summary.UnplayedCount, &summary.TotalCount); err != nil {
+		var podcast domain.Podcast
+		if err := rows.Scan(&podcast.ID, &podcast.Title, &podcast.FeedURL, &podcast.CreatedAt); err != nil {
 			return nil, err
 		}
-		summaries = append(summaries, summary)
+		podcasts = append(podcasts, podcast)
 	}
 	if err := rows.Err(); err != nil {
 		return nil, err
 	}
-	return summaries, nil
+	return podcasts, nil
 }
 
-func (s *Store) ListEpisodes(ctx context.Context) ([]domain.EpisodeResult, error) {
-	rows, err := s.db.QueryContext(ctx, `SELECT e.id, e.title, e.state, e.published_at, e.size_bytes, p.id, p.title
-FROM episodes e
-JOIN podcasts p ON p.id = e.podcast_id
-ORDER BY
-    CASE WHEN e.published_at IS NULL OR e.published_at = '' THEN 1 ELSE 0 END,
-    e.published_at DESC,
-    LOWER(p.title),
-    LOWER(e.title)`)
+// SetNextRefresh reschedules a podcast without saving a fetched feed, e.g.
+// to back off after a failed refresh.
+func (s *Store) SetNextRefresh(ctx context.Context, podcastID string, next time.Time) error {
+	_, err := s.db.ExecContext(ctx, "UPDATE podcasts SET next_refresh_at = ? WHERE id = ?", refreshTime(next), podcastID)
+	return err
+}
+
+// ListWebSubTopics returns the subscribed podcasts whose feed advertises a
+// WebSub hub. Feeds without a self link are known to the hub by their feed URL.
+func (s *Store) ListWebSubTopics(ctx context.Context) ([]domain.WebSubTopic, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, title, websub_hub, COALESCE(NULLIF(websub_topic, ''), feed_url) FROM podcasts
+WHERE COALESCE(websub_hub, '') != ''
+ORDER BY LOWER(title)`)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	results := make([]domain.EpisodeResult, 0, 128)
+	var topics []domain.WebSubTopic
 	for rows.Next() {
-		var episode domain.EpisodeRow
-		var published sql.NullString
-		var podcastID, podcastTitle string
-		if err := rows.Scan(&episode.ID, &episode.Title, &episode.State, &published, &episode.SizeBytes, &podcastID, &podcastTitle); err != nil {
+		var topic domain.WebSubTopic
+		if err := rows.Scan(&topic.PodcastID, &topic.Title, &topic.Hub, &topic.Topic); err != nil {
 			return nil, err
 		}
-		if published.Valid {
-			if parsed, err := time.Parse(time.RFC3339Nano, published.String); err == nil {
-				episode.PublishedAt = parsed
-				episode.HasPublish = true
-			} else if parsed, err := time.Parse(time.RFC3339, published.String); err == nil {
-				episode.PublishedAt = parsed
-				episode.HasPublish = true
-			}
-		}
-		results = append(results, domain.EpisodeResult{
-			Episode:      episode,
-			PodcastID:    podcastID,
-			PodcastTitle: podcastTitle,
-		})
+		topics = append(topics, topic)
 	}
 	if err := rows.Err(); err != nil {
 		return nil, err
 	}
-	return results, nil
+	return topics, nil
 }
 
-func (s *Store) ListQueuedEpisodes(ctx context.Context) ([]domain.QueuedEpisodeResult, error) {
-	rows, err := s.db.QueryContext(ctx, `SELECT e.id, e.title, e.state, e.published_at, e.size_bytes, e.retry_count, p.id, p.title, d.enqueued_at
-FROM episodes e
-JOIN podcasts p ON p.id = e.podcast_id
-JOIN downloads d ON d.episode_id = e.id
-WHERE e.state = ?
-ORDER BY d.priority DESC, d.enqueued_at`, domain.EpisodeStateQueued)
+// ListPodcasts returns every subscribed podcast with its feed URL.
+func (s *Store) ListPodcasts(ctx context.Context) ([]domain.Podcast, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT id, title, feed_url, subscribed_at FROM podcasts ORDER BY LOWER(title)")
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	results := make([]domain.QueuedEpisodeResult, 0, 32)
+	podcasts := make([]domain.Podcast, 0, 16)
 	for rows.Next() {
-		var episode domain.EpisodeRow
-		var published sql.NullString
-		var podcastID, podcastTitle string
-		var retryCount int
-		var enqueuedAt string
-		if err := rows.Scan(&episode.ID, &episode.Title, &episode.State, &published, &episode.SizeBytes, &retryCount, &podcastID, &podcastTitle, &enqueuedAt); err != nil {
+		var podcast domain.Podcast
+		if err := rows.Scan(&podcast.ID, &podcast.Title, &podcast.FeedURL, &podcast.CreatedAt); err != nil {
 			return nil, err
 		}
-		if published.Valid {
-			if parsed, err := time.Parse(time.RFC3339Nano, published.String); err == nil {
-				episode.PublishedAt = parsed
-				episode.HasPublish = true
-			} else if parsed, err := time.Parse(time.RFC3339, published.String); err == nil {
-				episode.PublishedAt = parsed
-				episode.HasPublish = true
-			}
-		}
-		var parsedEnqueuedAt time.Time
-		if parsed, err := time.Parse(time.RFC3339Nano, enqueuedAt); err == nil {
-			parsedEnqueuedAt = parsed
-		} else if parsed, err := time.Parse(time.RFC3339, enqueuedAt); err == nil {
-			parsedEnqueuedAt = parsed
-		}
-		results = append(results, domain.QueuedEpisodeResult{
-			Episode:      episode,
-			PodcastID:    podcastID,
-			PodcastTitle: podcastTitle,
-			RetryCount:   retryCount,
-			EnqueuedAt:   parsedEnqueuedAt,
-		})
+		podcasts = append(podcasts, podcast)
 	}
 	if err := rows.Err(); err != nil {
 		return nil, err
 	}
-	return results, nil
+	return podcasts, nil
 }
 
-// ListDownloadedEpisodes returns all episodes that have been downloaded (DOWNLOADED or DELETED state).
-func (s *Store) ListDownloadedEpisodes(ctx context.Context) ([]domain.EpisodeResult, error) {
-	rows, err := s.db.QueryContext(ctx, `SELECT e.id, e.title, e.state, e.published_at, e.size_bytes, p.id, p.title
+// GetMetadata returns the value stored under key, or "" when it is unset.
+func (s *Store) GetMetadata(ctx context.Context, key string) (string, error) {
+	var value string
+	err := s.db.QueryRowContext(ctx, "SELECT value FROM metadata WHERE key = ?", key).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return value, err
+}
+
+// SetMetadata stores value under key, replacing any previous value.
+func (s *Store) SetMetadata(ctx context.Context, key, value string) error {
+	_, err := s.db.ExecContext(ctx, `INSERT INTO metadata (key, value) VALUES (?, ?)
+ON CONFLICT(key) DO UPDATE SET value = excluded.value`, key, value)
+	return err
+}
+
+// ListEpisodeActivity returns every episode that was downloaded, played or
+// started, with its feed URL, by enclosure URL.
+func (s *Store) ListEpisodeActivity(ctx context.Context) ([]domain.EpisodeActivity, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT p.feed_url, e.enclosure_url, e.downloaded_at, e.played_at, e.state = ?,
+    COALESCE(e.position_seconds, 0), COALESCE(e.duration_seconds, 0)
 FROM episodes e
 JOIN podcasts p ON p.id = e.podcast_id
-WHERE e.state IN (?, ?)
-ORDER BY
-    CASE WHEN e.published_at IS NULL OR e.published_at = '' THEN 1 ELSE 0 END,
-    e.published_at DESC,
-    LOWER(p.title),
-    LOWER(e.title)`, domain.EpisodeStateDownloaded, domain.EpisodeStateDeleted)
+WHERE (e.downloaded_at IS NOT NULL AND e.downloaded_at != '') OR e.state = ? OR COALESCE(e.position_seconds, 0) > 0
+ORDER BY e.enclosure_url`, domain.EpisodeStatePlayed, domain.EpisodeStatePlayed)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	results := make([]domain.EpisodeResult, 0, 128)
+	var activity []domain.EpisodeActivity
 	for rows.Next() {
-		var episode domain.EpisodeRow
-		var published sql.NullString
-		var podcastID, podcastTitle string
-		if err := rows.Scan(&episode.ID, &episode.Title, &episode.State, &published, &episode.SizeBytes, &podcastID, &podcastTitle); err != nil {
+		var record domain.EpisodeActivity
+		var downloaded, played sql.NullString
+		if err := rows.Scan(&record.FeedURL, &record.EnclosureURL, &downloaded, &played, &record.Played, &record.PositionSec, &record.DurationSec); err != nil {
 			return nil, err
 		}
-		if published.Valid {
-			if parsed, err := time.Parse(time.RFC3339Nano, published.String); err == nil {
-				episode.PublishedAt = parsed
-				episode.HasPublish = true
-			} else if parsed, err := time.Parse(time.RFC3339, published.String); err == nil {
-				episode.PublishedAt = parsed
-				episode.HasPublish = true
+		if downloaded.Valid {
+			if parsed, err := time.Parse(time.RFC3339Nano, downloaded.String); err == nil {
+				record.DownloadedAt = parsed
 			}
 		}
-		results = append(results, domain.EpisodeResult{
-			Episode:      episode,
-			PodcastID:    podcastID,
-			PodcastTitle: podcastTitle,
-		})
+		if played.Valid {
+			if parsed, err := time.Parse(time.RFC3339Nano, played.String); err == nil {
+				record.PlayedAt = parsed
+			}
+		}
+		activity = append(activity, record)
 	}
-	if err := rows.Err(); err != nil {
-		return nil, err
+	return activity, rows.Err()
+}
+
+// MarkSeenByEnclosure moves NEW episodes with one of the given enclosure URLs
+// to SEEN and returns how many changed.
+func (s *Store) MarkSeenByEnclosure(ctx context.Context, enclosureURLs []string) (int, error) {
+	var marked int
+	err := s.withRetry(ctx, func() error {
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		committed := false
+		defer func() {
+			if !committed {
+				tx.Rollback()
+			}
+		}()
+
+		marked = 0
+		for _, enclosure := range enclosureURLs {
+			res, err := tx.ExecContext(ctx, "UPDATE episodes SET state = ? WHERE enclosure_url = ? AND state = ?", domain.EpisodeStateSeen, enclosure, domain.EpisodeStateNew)
+			if err != nil {
+				return err
+			}
+			affected, err := res.RowsAffected()
+			if err != nil {
+				return err
+			}
+			marked += int(affected)
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		committed = true
+		return nil
+	})
+	if err != nil {
+		return 0, err
 	}
-	return results, nil
+	return marked, nil
 }
 
-// CountQueuedEpisodes returns the count of episodes in QUEUED state.
-func (s *Store) CountQueuedEpisodes(ctx context.Context) (int, error) {
-	var count int
-	err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM episodes WHERE state = ?`, domain.EpisodeStateQueued).Scan(&count)
-	return count, err
+// GetPodcast returns a subscribed podcast, or sql.ErrNoRows when unknown.
+func (s *Store) GetPodcast(ctx context.Context, podcastID string) (domain.Podcast, error) {
+	var podcast domain.Podcast
+	err := s.db.QueryRowContext(ctx, "SELECT id, title, feed_url, subscribed_at FROM podcasts WHERE id = ?", podcastID).
+		Scan(&podcast.ID, &podcast.Title, &podcast.FeedURL, &podcast.CreatedAt)
+	return podcast, err
 }
 
-// CountDownloadedEpisodes returns the count of episodes in DOWNLOADED or DELETED state.
-func (s *Store) CountDownloadedEpisodes(ctx context.Context) (int, error) {
-	var count int
-	err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM episodes WHERE state IN (?, ?)`, domain.EpisodeStateDownloaded, domain.EpisodeStateDeleted).Scan(&count)
-	return count, err
+// SetDownloadDir stores the directory a podcast's episodes download to, or
+// clears it when dir is empty. It reports false for an unknown podcast.
+func (s *Store) SetDownloadDir(ctx context.Context, podcastID, dir string) (bool, error) {
+	res, err := s.db.ExecContext(ctx, "UPDATE podcasts SET download_dir = ? WHERE id = ?", dir, podcastID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
 }
 
-// FindDanglingFiles scans the download directory and returns files that are not tracked in the database.
-func (s *Store) FindDanglingFiles(ctx context.Context, downloadRoot string) ([]domain.DanglingFile, error) {
-	if downloadRoot == "" {
-		return nil, nil
+// AddTag tags a podcast. A tag it already has, in any case, is kept as it
+// is. It reports false for an unknown podcast.
+func (s *Store) AddTag(ctx context.Context, podcastID, tag string) (bool, error) {
+	res, err := s.db.ExecContext(ctx, `INSERT INTO podcast_tags (podcast_id, tag)
+SELECT id, ? FROM podcasts WHERE id = ?
+AND NOT EXISTS (SELECT 1 FROM podcast_tags WHERE podcast_id = ? AND tag = ? COLLATE NOCASE)`, tag, podcastID, podcastID, tag)
+	if err != nil {
+		return false, err
+	}
+	if affected, err := res.RowsAffected(); err != nil || affected > 0 {
+		return err == nil, err
+	}
+	exists, _, err := s.SubscriptionExists(ctx, podcastID)
+	return exists, err
+}
+
+// RemoveTag removes a tag from a podcast, ignoring case. It reports false
+// when the podcast did not have the tag.
+func (s *Store) RemoveTag(ctx context.Context, podcastID, tag string) (bool, error) {
+	res, err := s.db.ExecContext(ctx, "DELETE FROM podcast_tags WHERE podcast_id = ? AND tag = ? COLLATE NOCASE", podcastID, tag)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
 	}
+	return affected > 0, nil
+}
 
-	// Get all file paths from the database
-	rows, err := s.db.QueryContext(ctx, `SELECT file_path FROM episodes WHERE file_path IS NOT NULL AND file_path != ''`)
+func (s *Store) DeleteSubscription(ctx context.Context, podcastID string) (bool, error) {
+	res, err := s.db.ExecContext(ctx, "DELETE FROM podcasts WHERE id = ?", podcastID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func (s *Store) ListSubscriptionSummaries(ctx context.Context) ([]domain.SubscriptionSummary, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT
+p.id,
+p.title,
+COALESCE(SUM(CASE WHEN e.state = ? THEN 1 ELSE 0 END), 0) AS new_count,
+COALESCE(SUM(CASE WHEN e.state NOT IN (?, ?) THEN 1 ELSE 0 END), 0) AS unplayed_count,
+COALESCE(SUM(CASE WHEN e.state = ? THEN 1 ELSE 0 END), 0) AS played_count,
+COUNT(e.id) AS total_count,
+p.last_refreshed_at,
+p.next_refresh_at,
+COALESCE(p.language, ''),
+COALESCE(p.artwork_url, ''),
+COALESCE(p.download_dir, '')
+FROM podcasts p
+LEFT JOIN episodes e ON e.podcast_id = p.id
+GROUP BY p.id, p.title
+ORDER BY LOWER(p.title)`, domain.EpisodeStateNew, domain.EpisodeStatePlayed, domain.EpisodeStateIgnored, domain.EpisodeStatePlayed)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	knownFiles := make(map[string]bool)
-	for rows.Next() {
-		var filePath string
-		if err := rows.Scan(&filePath); err != nil {
-			return nil, err
+	summaries := make([]domain.SubscriptionSummary, 0, 8)
+	for rows.Next() {
+		var summary domain.SubscriptionSummary
+		var refreshed, next sql.NullString
+		if err := rows.Scan(&summary.ID, &summary.Title, &summary.NewCount, &summary.UnplayedCount, &summary.PlayedCount, &summary.TotalCount, &refreshed, &next, &summary.Language, &summary.ArtworkURL, &summary.DownloadDir); err != nil {
+			return nil, err
+		}
+		if refreshed.Valid {
+			if parsed, err := time.Parse(time.RFC3339Nano, refreshed.String); err == nil {
+				summary.LastRefreshed = parsed
+			}
+		}
+		if next.Valid {
+			if parsed, err := time.Parse(time.RFC3339, next.String); err == nil {
+				summary.NextRefresh = parsed
+			}
+		}
+		summaries = append(summaries, summary)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	funding, err := s.listFunding(ctx)
+	if err != nil {
+		return nil, err
+	}
+	value, err := s.listValue(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tags, err := s.listTags(ctx)
+	if err != nil {
+		return nil, err
+	}
+	usage, err := s.diskUsage(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range summaries {
+		summaries[i].Funding = funding[summaries[i].ID]
+		summaries[i].Value = value[summaries[i].ID]
+		summaries[i].Tags = tags[summaries[i].ID]
+		summaries[i].DiskBytes = usage[summaries[i].ID]
+	}
+	return summaries, nil
+}
+
+// diskUsage returns how many bytes the downloaded files of each podcast
+// take up on disk, by podcast ID. Files that are gone count as nothing.
+func (s *Store) diskUsage(ctx context.Context) (map[string]int64, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT podcast_id, file_path FROM episodes
+WHERE state IN (?, ?, ?) AND file_path IS NOT NULL AND file_path != ''`,
+		domain.EpisodeStateDownloaded, domain.EpisodeStateCorrupt, domain.EpisodeStatePlayed)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	usage := map[string]int64{}
+	for rows.Next() {
+		var podcastID, filePath string
+		if err := rows.Scan(&podcastID, &filePath); err != nil {
+			return nil, err
+		}
+		if stat, err := os.Stat(filePath); err == nil {
+			usage[podcastID] += stat.Size()
+		}
+	}
+	return usage, rows.Err()
+}
+
+// listTags returns the tags of all podcasts by podcast ID.
+func (s *Store) listTags(ctx context.Context) (map[string][]string, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT podcast_id, tag FROM podcast_tags ORDER BY podcast_id, tag")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := make(map[string][]string)
+	for rows.Next() {
+		var podcastID, tag string
+		if err := rows.Scan(&podcastID, &tag); err != nil {
+			return nil, err
+		}
+		tags[podcastID] = append(tags[podcastID], tag)
+	}
+	return tags, rows.Err()
+}
+
+// PodcastTags returns the tags of a podcast.
+func (s *Store) PodcastTags(ctx context.Context, podcastID string) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT tag FROM podcast_tags WHERE podcast_id = ? ORDER BY tag", podcastID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []string{}
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+// listFunding returns the podcast:funding links of all podcasts by podcast ID.
+func (s *Store) listFunding(ctx context.Context) (map[string][]domain.Funding, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT podcast_id, url, label FROM podcast_funding ORDER BY podcast_id, position")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	funding := make(map[string][]domain.Funding)
+	for rows.Next() {
+		var podcastID string
+		var link domain.Funding
+		if err := rows.Scan(&podcastID, &link.URL, &link.Label); err != nil {
+			return nil, err
+		}
+		funding[podcastID] = append(funding[podcastID], link)
+	}
+	return funding, rows.Err()
+}
+
+// saveValue replaces the podcast:value blocks stored for a podcast.
+func saveValue(ctx context.Context, tx *sql.Tx, podcastID string, blocks []domain.ValueBlock) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM podcast_value WHERE podcast_id = ?", podcastID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM podcast_value_recipients WHERE podcast_id = ?", podcastID); err != nil {
+		return err
+	}
+	for i, block := range blocks {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO podcast_value (podcast_id, position, value_type, method, suggested) VALUES (?, ?, ?, ?, ?)",
+			podcastID, i, block.Type, block.Method, block.Suggested); err != nil {
+			return err
+		}
+		for j, r := range block.Recipients {
+			if _, err := tx.ExecContext(ctx, `INSERT INTO podcast_value_recipients (podcast_id, block, position, name, recipient_type, address, split, fee)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?)`, podcastID, i, j, r.Name, r.Type, r.Address, r.Split, r.Fee); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// listValue returns the podcast:value blocks of all podcasts by podcast ID.
+func (s *Store) listValue(ctx context.Context) (map[string][]domain.ValueBlock, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT podcast_id, value_type, method, suggested FROM podcast_value ORDER BY podcast_id, position")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	value := make(map[string][]domain.ValueBlock)
+	for rows.Next() {
+		var podcastID string
+		var block domain.ValueBlock
+		if err := rows.Scan(&podcastID, &block.Type, &block.Method, &block.Suggested); err != nil {
+			return nil, err
+		}
+		value[podcastID] = append(value[podcastID], block)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	rows, err = s.db.QueryContext(ctx, "SELECT podcast_id, block, name, recipient_type, address, split, fee FROM podcast_value_recipients ORDER BY podcast_id, block, position")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var podcastID string
+		var block int
+		var r domain.ValueRecipient
+		if err := rows.Scan(&podcastID, &block, &r.Name, &r.Type, &r.Address, &r.Split, &r.Fee); err != nil {
+			return nil, err
+		}
+		if blocks := value[podcastID]; block >= 0 && block < len(blocks) {
+			blocks[block].Recipients = append(blocks[block].Recipients, r)
+		}
+	}
+	return value, rows.Err()
+}
+
+// Page selects a window of a listing: at most Limit rows after skipping
+// Offset rows. A zero Limit lists all rows from Offset on.
+type Page struct {
+	Offset int
+	Limit  int
+}
+
+// clause returns the LIMIT and OFFSET clause of the page and its arguments,
+// or nothing for the whole listing.
+func (p Page) clause() (string, []any) {
+	if p.Limit <= 0 && p.Offset <= 0 {
+		return "", nil
+	}
+	limit := p.Limit
+	if limit <= 0 {
+		limit = -1 // SQLite needs a LIMIT before OFFSET
+	}
+	return "\nLIMIT ? OFFSET ?", []any{limit, max(p.Offset, 0)}
+}
+
+// Orders of ListEpisodes besides newest first.
+const (
+	OrderShortest = "shortest"
+	OrderLongest  = "longest"
+)
+
+// EpisodeListing narrows ListEpisodes by state, podcast, publish date and
+// duration, and orders it. Episodes of unknown duration are left out by a
+// duration bound and listed last when sorting; those of unknown publish
+// date are left out by Since.
+type EpisodeListing struct {
+	State    string    // Only episodes in this state, "" for any
+	Podcast  string    // Only podcasts whose title contains this, ignoring case
+	Since    time.Time // Only episodes published on or after this UTC day
+	UnderSec int       // Only episodes shorter than this, 0 for no bound
+	OverSec  int       // Only episodes longer than this, 0 for no bound
+	Order    string    // OrderShortest, OrderLongest or "" for newest first
+}
+
+// ListEpisodes lists the episodes of all subscriptions, newest first unless
+// the listing orders them by duration, one page at a time.
+func (s *Store) ListEpisodes(ctx context.Context, listing EpisodeListing, page Page) ([]domain.EpisodeResult, error) {
+	return s.listEpisodes(ctx, "", listing, page)
+}
+
+// ListEpisodesByPodcast lists the episodes of one podcast like ListEpisodes.
+func (s *Store) ListEpisodesByPodcast(ctx context.Context, podcastID string, listing EpisodeListing, page Page) ([]domain.EpisodeResult, error) {
+	return s.listEpisodes(ctx, podcastID, listing, page)
+}
+
+// listEpisodes lists the episodes of the podcast with podcastID, or of all
+// podcasts when it is empty.
+func (s *Store) listEpisodes(ctx context.Context, podcastID string, listing EpisodeListing, page Page) ([]domain.EpisodeResult, error) {
+	var stmt strings.Builder
+	var args []any
+	stmt.WriteString(`SELECT e.id, e.title, e.state, e.published_at, e.size_bytes, COALESCE(e.duration_seconds, 0), p.id, p.title
+FROM episodes e
+JOIN podcasts p ON p.id = e.podcast_id`)
+	var where []string
+	if podcastID != "" {
+		where = append(where, "e.podcast_id = ?")
+		args = append(args, podcastID)
+	}
+	if listing.State != "" {
+		where = append(where, "e.state = ?")
+		args = append(args, listing.State)
+	}
+	if listing.Podcast != "" {
+		where = append(where, `p.title LIKE ? ESCAPE '\'`)
+		args = append(args, "%"+escapeLike(listing.Podcast)+"%")
+	}
+	if !listing.Since.IsZero() {
+		where = append(where, "substr(e.published_at, 1, 10) >= ?")
+		args = append(args, listing.Since.UTC().Format("2006-01-02"))
+	}
+	if listing.UnderSec > 0 {
+		where = append(where, "e.duration_seconds > 0 AND e.duration_seconds < ?")
+		args = append(args, listing.UnderSec)
+	}
+	if listing.OverSec > 0 {
+		where = append(where, "e.duration_seconds > ?")
+		args = append(args, listing.OverSec)
+	}
+	if len(where) > 0 {
+		stmt.WriteString("\nWHERE " + strings.Join(where, " AND "))
+	}
+	stmt.WriteString("\nORDER BY ")
+	switch listing.Order {
+	case OrderShortest:
+		stmt.WriteString("COALESCE(e.duration_seconds, 0) <= 0, e.duration_seconds ASC, ")
+	case OrderLongest:
+		stmt.WriteString("COALESCE(e.duration_seconds, 0) <= 0, e.duration_seconds DESC, ")
+	}
+	stmt.WriteString(`CASE WHEN e.published_at IS NULL OR e.published_at = '' THEN 1 ELSE 0 END,
+    e.published_at DESC,
+    LOWER(p.title),
+    LOWER(e.title),
+    e.id`)
+	clause, pageArgs := page.clause()
+	stmt.WriteString(clause)
+	args = append(args, pageArgs...)
+
+	rows, err := s.db.QueryContext(ctx, stmt.String(), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanEpisodeResults(rows)
+}
+
+// scanEpisodeResults reads rows of episode ID, title, state, publish date,
+// size, duration, podcast ID and podcast title.
+func scanEpisodeResults(rows *sql.Rows) ([]domain.EpisodeResult, error) {
+	results := make([]domain.EpisodeResult, 0, 128)
+	for rows.Next() {
+		var episode domain.EpisodeRow
+		var published sql.NullString
+		var podcastID, podcastTitle string
+		if err := rows.Scan(&episode.ID, &episode.Title, &episode.State, &published, &episode.SizeBytes, &episode.DurationSec, &podcastID, &podcastTitle); err != nil {
+			return nil, err
+		}
+		if published.Valid {
+			if parsed, err := time.Parse(time.RFC3339Nano, published.String); err == nil {
+				episode.PublishedAt = parsed
+				episode.HasPublish = true
+			} else if parsed, err := time.Parse(time.RFC3339, published.String); err == nil {
+				episode.PublishedAt = parsed
+				episode.HasPublish = true
+			}
+		}
+		results = append(results, domain.EpisodeResult{
+			Episode:      episode,
+			PodcastID:    podcastID,
+			PodcastTitle: podcastTitle,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (s *Store) ListQueuedEpisodes(ctx context.Context) ([]domain.QueuedEpisodeResult, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT e.id, e.title, e.state, e.published_at, e.size_bytes, COALESCE(e.duration_seconds, 0), e.retry_count, COALESCE(e.last_error, ''), p.id, p.title, d.enqueued_at, d.claimed_at, d.paused_at IS NOT NULL, d.priority
+FROM episodes e
+JOIN podcasts p ON p.id = e.podcast_id
+JOIN downloads d ON d.episode_id = e.id
+WHERE e.state = ?
+ORDER BY d.claimed_at IS NULL, d.priority DESC, d.enqueued_at`, domain.EpisodeStateQueued)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := make([]domain.QueuedEpisodeResult, 0, 32)
+	for rows.Next() {
+		var episode domain.EpisodeRow
+		var published sql.NullString
+		var podcastID, podcastTitle string
+		var retryCount int
+		var lastError string
+		var enqueuedAt string
+		var claimedAt sql.NullString
+		var paused bool
+		var priority int
+		if err := rows.Scan(&episode.ID, &episode.Title, &episode.State, &published, &episode.SizeBytes, &episode.DurationSec, &retryCount, &lastError, &podcastID, &podcastTitle, &enqueuedAt, &claimedAt, &paused, &priority); err != nil {
+			return nil, err
+		}
+		if published.Valid {
+			if parsed, err := time.Parse(time.RFC3339Nano, published.String); err == nil {
+				episode.PublishedAt = parsed
+				episode.HasPublish = true
+			} else if parsed, err := time.Parse(time.RFC3339, published.String); err == nil {
+				episode.PublishedAt = parsed
+				episode.HasPublish = true
+			}
+		}
+		var parsedEnqueuedAt time.Time
+		if parsed, err := time.Parse(time.RFC3339Nano, enqueuedAt); err == nil {
+			parsedEnqueuedAt = parsed
+		} else if parsed, err := time.Parse(time.RFC3339, enqueuedAt); err == nil {
+			parsedEnqueuedAt = parsed
+		}
+		var parsedClaimedAt time.Time
+		if claimedAt.Valid {
+			if parsed, err := time.Parse(time.RFC3339Nano, claimedAt.String); err == nil {
+				parsedClaimedAt = parsed
+			} else if parsed, err := time.Parse(time.RFC3339, claimedAt.String); err == nil {
+				parsedClaimedAt = parsed
+			}
+		}
+		results = append(results, domain.QueuedEpisodeResult{
+			Episode:      episode,
+			PodcastID:    podcastID,
+			PodcastTitle: podcastTitle,
+			RetryCount:   retryCount,
+			LastError:    lastError,
+			EnqueuedAt:   parsedEnqueuedAt,
+			ClaimedAt:    parsedClaimedAt,
+			Active:       claimedAt.Valid,
+			Paused:       paused,
+			Priority:     priority,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// ListDownloadedEpisodes returns all episodes that have been downloaded (DOWNLOADED, DELETED or CORRUPT
+// state, or PLAYED with a file on record). Each result carries the size of
+// its file on disk.
+func (s *Store) ListDownloadedEpisodes(ctx context.Context) ([]domain.EpisodeResult, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT e.id, e.title, e.state, e.published_at, e.size_bytes, COALESCE(e.duration_seconds, 0), COALESCE(e.file_path, ''), p.id, p.title
+FROM episodes e
+JOIN podcasts p ON p.id = e.podcast_id
+WHERE e.state IN (?, ?, ?) OR (e.state = ? AND e.file_path IS NOT NULL AND e.file_path != '')
+ORDER BY
+    CASE WHEN e.published_at IS NULL OR e.published_at = '' THEN 1 ELSE 0 END,
+    e.published_at DESC,
+    LOWER(p.title),
+    LOWER(e.title)`, domain.EpisodeStateDownloaded, domain.EpisodeStateDeleted, domain.EpisodeStateCorrupt, domain.EpisodeStatePlayed)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := make([]domain.EpisodeResult, 0, 128)
+	for rows.Next() {
+		var episode domain.EpisodeRow
+		var published sql.NullString
+		var filePath, podcastID, podcastTitle string
+		if err := rows.Scan(&episode.ID, &episode.Title, &episode.State, &published, &episode.SizeBytes, &episode.DurationSec, &filePath, &podcastID, &podcastTitle); err != nil {
+			return nil, err
+		}
+		if published.Valid {
+			if parsed, err := time.Parse(time.RFC3339Nano, published.String); err == nil {
+				episode.PublishedAt = parsed
+				episode.HasPublish = true
+			} else if parsed, err := time.Parse(time.RFC3339, published.String); err == nil {
+				episode.PublishedAt = parsed
+				episode.HasPublish = true
+			}
+		}
+		result := domain.EpisodeResult{
+			Episode:      episode,
+			PodcastID:    podcastID,
+			PodcastTitle: podcastTitle,
+		}
+		if filePath != "" && episode.State != domain.EpisodeStateDeleted {
+			if stat, err := os.Stat(filePath); err == nil {
+				result.DiskBytes = stat.Size()
+			}
+		}
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// CountQueuedEpisodes returns the count of episodes in QUEUED state.
+func (s *Store) CountQueuedEpisodes(ctx context.Context) (int, error) {
+	var count int
+	err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM episodes WHERE state = ?`, domain.EpisodeStateQueued).Scan(&count)
+	return count, err
+}
+
+// CountNewEpisodes returns the count of episodes in NEW state.
+func (s *Store) CountNewEpisodes(ctx context.Context) (int, error) {
+	var count int
+	err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM episodes WHERE state = ?`, domain.EpisodeStateNew).Scan(&count)
+	return count, err
+}
+
+// CountFailedDownloads returns the count of queued episodes whose download
+// has failed at least once.
+func (s *Store) CountFailedDownloads(ctx context.Context) (int, error) {
+	var count int
+	err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM episodes WHERE state = ? AND COALESCE(retry_count, 0) > 0`, domain.EpisodeStateQueued).Scan(&count)
+	return count, err
+}
+
+// CountDownloadedEpisodes returns the count of episodes listed by ListDownloadedEpisodes.
+func (s *Store) CountDownloadedEpisodes(ctx context.Context) (int, error) {
+	var count int
+	err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM episodes
+WHERE state IN (?, ?, ?) OR (state = ? AND file_path IS NOT NULL AND file_path != '')`,
+		domain.EpisodeStateDownloaded, domain.EpisodeStateDeleted, domain.EpisodeStateCorrupt, domain.EpisodeStatePlayed).Scan(&count)
+	return count, err
+}
+
+// DatabaseStats returns the schema version, file size and row counts of the
+// database. The full-text index and SQLite's own tables are left out.
+func (s *Store) DatabaseStats(ctx context.Context) (domain.DatabaseStats, error) {
+	stats := domain.DatabaseStats{Tables: map[string]int{}, EpisodeStates: map[string]int{}}
+	if err := s.db.QueryRowContext(ctx, "PRAGMA user_version").Scan(&stats.SchemaVersion); err != nil {
+		return stats, err
+	}
+	var pageCount, pageSize int64
+	if err := s.db.QueryRowContext(ctx, "PRAGMA page_count").Scan(&pageCount); err != nil {
+		return stats, err
+	}
+	if err := s.db.QueryRowContext(ctx, "PRAGMA page_size").Scan(&pageSize); err != nil {
+		return stats, err
+	}
+	stats.SizeBytes = pageCount * pageSize
+
+	rows, err := s.db.QueryContext(ctx, `SELECT name FROM sqlite_master
+WHERE type = 'table' AND name NOT LIKE 'sqlite\_%' ESCAPE '\' AND name NOT LIKE 'episodes\_fts%' ESCAPE '\'
+ORDER BY name`)
+	if err != nil {
+		return stats, err
+	}
+	var tables []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return stats, err
+		}
+		tables = append(tables, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return stats, err
+	}
+	for _, table := range tables {
+		var count int
+		if err := s.db.QueryRowContext(ctx, fmt.Sprintf(`SELECT COUNT(*) FROM "%s"`, strings.ReplaceAll(table, `"`, `""`))).Scan(&count); err != nil {
+			return stats, err
+		}
+		stats.Tables[table] = count
+	}
+
+	rows, err = s.db.QueryContext(ctx, "SELECT state, COUNT(*) FROM episodes GROUP BY state")
+	if err != nil {
+		return stats, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var state string
+		var count int
+		if err := rows.Scan(&state, &count); err != nil {
+			return stats, err
+		}
+		stats.EpisodeStates[state] = count
+	}
+	return stats, rows.Err()
+}
+
+// FindDanglingFiles scans the download directory and returns files that are not tracked in the database.
+// The skipped directories, such as the temporary download directory and the trash, are left out when
+// they live under the download root.
+func (s *Store) FindDanglingFiles(ctx context.Context, downloadRoot string, skipDirs ...string) ([]domain.DanglingFile, error) {
+	if downloadRoot == "" {
+		return nil, nil
+	}
+
+	// Get all file paths from the database
+	rows, err := s.db.QueryContext(ctx, `SELECT file_path FROM episodes WHERE file_path IS NOT NULL AND file_path != ''`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	knownFiles := make(map[string]bool)
+	for rows.Next() {
+		var filePath string
+		if err := rows.Scan(&filePath); err != nil {
+			return nil, err
+		}
+		knownFiles[filePath] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Scan download directory for all files
+	var danglingFiles []domain.DanglingFile
+	err = filepath.Walk(downloadRoot, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil // Skip files we can't access
+		}
+		if info.IsDir() {
+			for _, dir := range skipDirs {
+				if dir != "" && filepath.Clean(path) == filepath.Clean(dir) {
+					return filepath.SkipDir // Partial downloads and trashed files are not dangling
+				}
+			}
+			return nil // Skip directories
+		}
+		// Check if this file is in the database
+		if !knownFiles[path] {
+			danglingFiles = append(danglingFiles, domain.DanglingFile{
+				Path:      path,
+				SizeBytes: info.Size(),
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return danglingFiles, nil
+}
+
+// ListDuplicateDownloads groups downloaded episodes whose files share the same hash.
+// Within a group, the earliest download comes first.
+func (s *Store) ListDuplicateDownloads(ctx context.Context) ([]domain.DuplicateGroup, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT hash, id, file_path FROM episodes
+WHERE state = ? AND file_path IS NOT NULL AND file_path != '' AND hash IN (
+    SELECT hash FROM episodes
+    WHERE state = ? AND hash IS NOT NULL AND hash != '' AND file_path IS NOT NULL AND file_path != ''
+    GROUP BY hash HAVING COUNT(*) > 1
+)
+ORDER BY hash, downloaded_at, id`, domain.EpisodeStateDownloaded, domain.EpisodeStateDownloaded)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var groups []domain.DuplicateGroup
+	for rows.Next() {
+		var hash, id, filePath string
+		if err := rows.Scan(&hash, &id, &filePath); err != nil {
+			return nil, err
+		}
+		if len(groups) == 0 || groups[len(groups)-1].Hash != hash {
+			groups = append(groups, domain.DuplicateGroup{Hash: hash})
+		}
+		group := &groups[len(groups)-1]
+		group.EpisodeIDs = append(group.EpisodeIDs, id)
+		group.FilePaths = append(group.FilePaths, filePath)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+// FindDownloadedFileByHash returns the file path of another downloaded episode with the given hash.
+func (s *Store) FindDownloadedFileByHash(ctx context.Context, hash, excludeEpisodeID string) (string, error) {
+	var filePath string
+	err := s.db.QueryRowContext(ctx, `SELECT file_path FROM episodes
+WHERE state = ? AND hash = ? AND id != ? AND file_path IS NOT NULL AND file_path != ''
+ORDER BY downloaded_at LIMIT 1`, domain.EpisodeStateDownloaded, hash, excludeEpisodeID).Scan(&filePath)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", nil
+		}
+		return "", err
+	}
+	return filePath, nil
+}
+
+// ListEpisodeFiles returns the IDs of episodes whose downloaded file is still
+// kept, optionally for a single podcast.
+func (s *Store) ListEpisodeFiles(ctx context.Context, podcastID string) ([]string, error) {
+	query := `SELECT id FROM episodes
+WHERE state IN (?, ?, ?) AND file_path IS NOT NULL AND file_path != ''`
+	args := []any{domain.EpisodeStateDownloaded, domain.EpisodeStateCorrupt, domain.EpisodeStatePlayed}
+	if podcastID != "" {
+		query += " AND podcast_id = ?"
+		args = append(args, podcastID)
+	}
+	rows, err := s.db.QueryContext(ctx, query+" ORDER BY podcast_id, published_at, id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// EpisodeWithFilePath returns the ID of an episode other than exceptID whose
+// recorded file is filePath, or "" when there is none.
+func (s *Store) EpisodeWithFilePath(ctx context.Context, filePath, exceptID string) (string, error) {
+	var id string
+	err := s.db.QueryRowContext(ctx, "SELECT id FROM episodes WHERE file_path = ? AND id != ? LIMIT 1", filePath, exceptID).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return id, err
+}
+
+// UpdateEpisodeFilePath records that an episode's file moved from oldPath to
+// newPath. It reports false, changing nothing, when the recorded path is no
+// longer oldPath.
+func (s *Store) UpdateEpisodeFilePath(ctx context.Context, episodeID, oldPath, newPath string) (bool, error) {
+	var affected int64
+	err := s.withRetry(ctx, func() error {
+		res, err := s.db.ExecContext(ctx, "UPDATE episodes SET file_path = ? WHERE id = ? AND file_path = ?", newPath, episodeID, oldPath)
+		if err != nil {
+			return err
+		}
+		affected, err = res.RowsAffected()
+		return err
+	})
+	return affected > 0, err
+}
+
+// ListAdoptionCandidates returns episodes without a downloaded file that an
+// existing audio file could be matched to. Queued episodes and downloads a
+// worker holds are left out, as their file is on its way.
+func (s *Store) ListAdoptionCandidates(ctx context.Context) ([]domain.AdoptionCandidate, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT e.id, e.title, p.title, e.enclosure_url, COALESCE(e.size_bytes, 0), COALESCE(e.hash, '')
+FROM episodes e
+JOIN podcasts p ON p.id = e.podcast_id
+WHERE e.state NOT IN (?, ?)
+AND e.id NOT IN (SELECT episode_id FROM downloads WHERE claimed_at IS NOT NULL)`, domain.EpisodeStateDownloaded, domain.EpisodeStateQueued)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	candidates := make([]domain.AdoptionCandidate, 0, 128)
+	for rows.Next() {
+		var candidate domain.AdoptionCandidate
+		if err := rows.Scan(&candidate.ID, &candidate.Title, &candidate.PodcastTitle, &candidate.EnclosureURL, &candidate.SizeBytes, &candidate.Hash); err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, candidate)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return candidates, nil
+}
+
+// MarkAllEpisodesSeen marks every NEW episode as SEEN and returns how many
+// changed.
+func (s *Store) MarkAllEpisodesSeen(ctx context.Context) (int, error) {
+	res, err := s.db.ExecContext(ctx, "UPDATE episodes SET state = ? WHERE state = ?", domain.EpisodeStateSeen, domain.EpisodeStateNew)
+	if err != nil {
+		return 0, err
+	}
+	affected, err := res.RowsAffected()
+	return int(affected), err
+}
+
+// MarkPodcastEpisodesSeen marks the NEW episodes of one podcast as SEEN and
+// returns how many changed.
+func (s *Store) MarkPodcastEpisodesSeen(ctx context.Context, podcastID string) (int, error) {
+	res, err := s.db.ExecContext(ctx, "UPDATE episodes SET state = ? WHERE state = ? AND podcast_id = ?", domain.EpisodeStateSeen, domain.EpisodeStateNew, podcastID)
+	if err != nil {
+		return 0, err
+	}
+	affected, err := res.RowsAffected()
+	return int(affected), err
+}
+
+// IgnorePodcastEpisodes marks the NEW and SEEN episodes of one podcast as
+// IGNORED and returns how many changed. Queued, downloaded and played
+// episodes keep their state.
+func (s *Store) IgnorePodcastEpisodes(ctx context.Context, podcastID string) (int, error) {
+	res, err := s.db.ExecContext(ctx, "UPDATE episodes SET state = ? WHERE podcast_id = ? AND state IN (?, ?)",
+		domain.EpisodeStateIgnored, podcastID, domain.EpisodeStateNew, domain.EpisodeStateSeen)
+	if err != nil {
+		return 0, err
+	}
+	affected, err := res.RowsAffected()
+	return int(affected), err
+}
+
+func (s *Store) GetEpisodeInfo(ctx context.Context, episodeID string) (domain.EpisodeInfo, error) {
+	var info domain.EpisodeInfo
+	var published sql.NullString
+	var filePath sql.NullString
+	var hash sql.NullString
+	err := s.db.QueryRowContext(ctx, `SELECT e.id, e.title, COALESCE(e.description, ''), e.state, e.published_at, e.file_path, e.enclosure_url, COALESCE(e.enclosure_type, ''), e.hash, e.size_bytes, COALESCE(e.retry_count, 0), COALESCE(e.last_error, ''), COALESCE(e.position_seconds, 0), COALESCE(e.duration_seconds, 0),
+COALESCE(e.season, 0), COALESCE(e.episode_number, 0), COALESCE(e.image_url, ''), COALESCE(e.chapters_url, ''), p.id, p.title, COALESCE(p.artwork_url, ''), COALESCE(p.download_dir, '')
+FROM episodes e
+JOIN podcasts p ON p.id = e.podcast_id
+WHERE e.id = ?`, episodeID).
+		Scan(&info.ID, &info.Title, &info.Description, &info.State, &published, &filePath, &info.EnclosureURL, &info.MimeType, &hash, &info.SizeBytes, &info.RetryCount, &info.LastError, &info.PositionSec, &info.DurationSec,
+			&info.Season, &info.Number, &info.ImageURL, &info.ChaptersURL, &info.PodcastID, &info.PodcastTitle, &info.PodcastArtworkURL, &info.DownloadDir)
+	if err != nil {
+		return domain.EpisodeInfo{}, err
+	}
+	if published.Valid {
+		if parsed, err := time.Parse(time.RFC3339Nano, published.String); err == nil {
+			info.PublishedAt = parsed
+			info.HasPublish = true
+		} else if parsed, err := time.Parse(time.RFC3339, published.String); err == nil {
+			info.PublishedAt = parsed
+			info.HasPublish = true
+		}
+	}
+	if filePath.Valid {
+		info.FilePath = filePath.String
+	}
+	if hash.Valid {
+		info.Hash = hash.String
+	}
+	if info.Soundbites, err = s.listSoundbites(ctx, episodeID); err != nil {
+		return domain.EpisodeInfo{}, err
+	}
+	return info, nil
+}
+
+// listSoundbites returns the podcast:soundbite highlights of an episode in
+// feed order.
+func (s *Store) listSoundbites(ctx context.Context, episodeID string) ([]domain.Soundbite, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT start_seconds, duration_seconds, title FROM episode_soundbites WHERE episode_id = ? ORDER BY position", episodeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var bites []domain.Soundbite
+	for rows.Next() {
+		var bite domain.Soundbite
+		if err := rows.Scan(&bite.StartSec, &bite.DurationSec, &bite.Title); err != nil {
+			return nil, err
+		}
+		bites = append(bites, bite)
+	}
+	return bites, rows.Err()
+}
+
+// MarkPlayed sets an episode to PLAYED with its position at the end and
+// records when.
+func (s *Store) MarkPlayed(ctx context.Context, episodeID string) error {
+	_, err := s.db.ExecContext(ctx, "UPDATE episodes SET state = ?, position_seconds = COALESCE(duration_seconds, 0), played_at = ? WHERE id = ?",
+		domain.EpisodeStatePlayed, time.Now().UTC().Format(time.RFC3339Nano), episodeID)
+	return err
+}
+
+func (s *Store) UpdateEpisodeState(ctx context.Context, episodeID, state string) error {
+	_, err := s.db.ExecContext(ctx, "UPDATE episodes SET state = ? WHERE id = ?", state, episodeID)
+	return err
+}
+
+// ListDownloadedFiles returns the file of every DOWNLOADED episode by
+// episode ID.
+func (s *Store) ListDownloadedFiles(ctx context.Context) (map[string]string, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, file_path FROM episodes WHERE state = ? AND file_path IS NOT NULL AND file_path != ''`, domain.EpisodeStateDownloaded)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	files := map[string]string{}
+	for rows.Next() {
+		var id, filePath string
+		if err := rows.Scan(&id, &filePath); err != nil {
+			return nil, err
+		}
+		files[id] = filePath
+	}
+	return files, rows.Err()
+}
+
+// MarkFilesDeleted sets episodes whose file, given by episode ID, is gone to
+// DELETED. Episodes no longer DOWNLOADED at that path are left alone. The
+// IDs of the episodes it changed are returned.
+func (s *Store) MarkFilesDeleted(ctx context.Context, files map[string]string) ([]string, error) {
+	ids := make([]string, 0, len(files))
+	for id := range files {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var marked []string
+	err := s.withRetry(ctx, func() error {
+		marked = marked[:0]
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		committed := false
+		defer func() {
+			if !committed {
+				tx.Rollback()
+			}
+		}()
+
+		for start := 0; start < len(ids); start += updateBatchSize {
+			batch := ids[start:min(start+updateBatchSize, len(ids))]
+			args := []any{domain.EpisodeStateDownloaded}
+			for _, id := range batch {
+				args = append(args, id)
+			}
+			rows, err := tx.QueryContext(ctx, "SELECT id, file_path FROM episodes WHERE state = ? AND id IN ("+placeholders(len(batch))+")", args...)
+			if err != nil {
+				return err
+			}
+			var gone []string
+			for rows.Next() {
+				var id string
+				var filePath sql.NullString
+				if err := rows.Scan(&id, &filePath); err != nil {
+					rows.Close()
+					return err
+				}
+				if filePath.String == files[id] {
+					gone = append(gone, id)
+				}
+			}
+			rows.Close()
+			if err := rows.Err(); err != nil {
+				return err
+			}
+			if err := setStates(ctx, tx, gone, domain.EpisodeStateDownloaded, domain.EpisodeStateDeleted); err != nil {
+				return err
+			}
+			marked = append(marked, gone...)
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		committed = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(marked)
+	return marked, nil
+}
+
+// CorrectQueuedStates checks all queued episodes and updates their state to DOWNLOADED
+// if the file already exists on the filesystem. This fixes episodes stuck in QUEUED state.
+// Episodes still waiting in the download queue are pending re-downloads and are left alone.
+func (s *Store) CorrectQueuedStates(ctx context.Context) error {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, file_path FROM episodes
+WHERE state = ? AND file_path IS NOT NULL AND file_path != ''
+AND id NOT IN (SELECT episode_id FROM downloads)`, domain.EpisodeStateQueued)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var episodesToUpdate []string
+	for rows.Next() {
+		var id, filePath string
+		if err := rows.Scan(&id, &filePath); err != nil {
+			return err
+		}
+		// Check if file exists
+		if _, err := os.Stat(filePath); err == nil {
+			// File exists, should be marked as DOWNLOADED
+			episodesToUpdate = append(episodesToUpdate, id)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+	if len(episodesToUpdate) == 0 {
+		return nil
+	}
+
+	// Update all episodes with existing files at once
+	return s.withRetry(ctx, func() error {
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		committed := false
+		defer func() {
+			if !committed {
+				tx.Rollback()
+			}
+		}()
+
+		if err := setStates(ctx, tx, episodesToUpdate, domain.EpisodeStateQueued, domain.EpisodeStateDownloaded); err != nil {
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		committed = true
+		return nil
+	})
+}
+
+// updateBatchSize bounds how many episodes a batched statement names, well
+// below SQLite's limit on bound parameters.
+const updateBatchSize = 500
+
+// setStates moves the episodes in ids that are in state from to state to,
+// updateBatchSize episodes per statement.
+func setStates(ctx context.Context, tx *sql.Tx, ids []string, from, to string) error {
+	for start := 0; start < len(ids); start += updateBatchSize {
+		batch := ids[start:min(start+updateBatchSize, len(ids))]
+		args := []any{to, from}
+		for _, id := range batch {
+			args = append(args, id)
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE episodes SET state = ? WHERE state = ? AND id IN ("+placeholders(len(batch))+")", args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// placeholders returns n comma-separated bind parameters for an IN list.
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+func (s *Store) RemoveFromQueue(ctx context.Context, episodeID string) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM downloads WHERE episode_id = ?", episodeID)
+	return err
+}
+
+// PauseDownload holds a queued download back from workers. It reports false
+// when the episode is not queued or already paused. A worker transferring the
+// episode keeps going until it is told to stop.
+func (s *Store) PauseDownload(ctx context.Context, episodeID string) (bool, error) {
+	res, err := s.db.ExecContext(ctx, "UPDATE downloads SET paused_at = ? WHERE episode_id = ? AND paused_at IS NULL", time.Now().UTC(), episodeID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	return affected > 0, err
+}
+
+// ResumeDownload makes a paused download claimable again. It reports false
+// when the episode is not paused.
+func (s *Store) ResumeDownload(ctx context.Context, episodeID string) (bool, error) {
+	res, err := s.db.ExecContext(ctx, "UPDATE downloads SET paused_at = NULL WHERE episode_id = ? AND paused_at IS NOT NULL", episodeID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	return affected > 0, err
+}
+
+// SetDownloadPriority changes the priority of a queued download. It reports
+// false when the episode is not in the queue.
+func (s *Store) SetDownloadPriority(ctx context.Context, episodeID string, priority int) (bool, error) {
+	res, err := s.db.ExecContext(ctx, "UPDATE downloads SET priority = ? WHERE episode_id = ?", priority, episodeID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	return affected > 0, err
+}
+
+// RequeueEpisode moves a failed download to the back of the queue and releases
+// its claim. It is not claimed again until queued anew, which resets retries.
+func (s *Store) RequeueEpisode(ctx context.Context, episodeID string) error {
+	return s.withRetry(ctx, func() error {
+		_, err := s.db.ExecContext(ctx, `INSERT INTO downloads (episode_id, enqueued_at, priority)
+VALUES (?, ?, 0)
+ON CONFLICT(episode_id) DO UPDATE SET enqueued_at = excluded.enqueued_at, claimed_at = NULL`, episodeID, time.Now().UTC())
+		return err
+	})
+}
+
+// DropFromQueue removes an episode from the download queue and sets its state.
+func (s *Store) DropFromQueue(ctx context.Context, episodeID, state string) error {
+	return s.withRetry(ctx, func() error {
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		committed := false
+		defer func() {
+			if !committed {
+				tx.Rollback()
+			}
+		}()
+
+		if _, err := tx.ExecContext(ctx, "UPDATE episodes SET state = ?, retry_count = 0 WHERE id = ?", state, episodeID); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM downloads WHERE episode_id = ?", episodeID); err != nil {
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		committed = true
+		return nil
+	})
+}
+
+// IsDownloadActive reports whether a worker currently holds the claim on an
+// episode's queue entry.
+func (s *Store) IsDownloadActive(ctx context.Context, episodeID string) (bool, error) {
+	var active bool
+	err := s.db.QueryRowContext(ctx, "SELECT claimed_at IS NOT NULL FROM downloads WHERE episode_id = ?", episodeID).Scan(&active)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return active, err
+}
+
+// ReleaseClaim returns a claimed download to the waiting queue so it is picked
+// up again, resetting retries spent before the interruption.
+func (s *Store) ReleaseClaim(ctx context.Context, episodeID string) error {
+	return s.withRetry(ctx, func() error {
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		committed := false
+		defer func() {
+			if !committed {
+				tx.Rollback()
+			}
+		}()
+
+		if _, err := tx.ExecContext(ctx, "UPDATE downloads SET claimed_at = NULL WHERE episode_id = ?", episodeID); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE episodes SET retry_count = 0 WHERE id = ?", episodeID); err != nil {
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		committed = true
+		return nil
+	})
+}
+
+func (s *Store) EnqueueEpisode(ctx context.Context, episodeID string) error {
+	return s.withRetry(ctx, func() error {
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		committed := false
+		defer func() {
+			if !committed {
+				tx.Rollback()
+			}
+		}()
+
+		var active bool
+		err = tx.QueryRowContext(ctx, "SELECT claimed_at IS NOT NULL FROM downloads WHERE episode_id = ?", episodeID).Scan(&active)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		if active {
+			return ErrAlreadyDownloading
+		}
+
+		if _, err := tx.ExecContext(ctx, "UPDATE episodes SET state = ?, retry_count = 0 WHERE id = ?", domain.EpisodeStateQueued, episodeID); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT INTO downloads (episode_id, enqueued_at, priority)
+VALUES (?, ?, 0)
+ON CONFLICT(episode_id) DO UPDATE SET enqueued_at = excluded.enqueued_at, paused_at = NULL`, episodeID, time.Now().UTC()); err != nil {
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		committed = true
+		return nil
+	})
+}
+
+// EnqueueEpisodesByState queues every episode in the given state, optionally
+// limited to one podcast, and returns how many were queued.
+func (s *Store) EnqueueEpisodesByState(ctx context.Context, state, podcastID string) (int, error) {
+	var queued int
+	err := s.withRetry(ctx, func() error {
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
 		}
-		knownFiles[filePath] = true
-	}
-	if err := rows.Err(); err != nil {
-		return nil, err
-	}
+		committed := false
+		defer func() {
+			if !committed {
+				tx.Rollback()
+			}
+		}()
 
-	// Scan download directory for all files
-	var danglingFiles []domain.DanglingFile
-	err = filepath.Walk(downloadRoot, func(path string, info os.FileInfo, err error) error {
+		query := "SELECT id FROM episodes WHERE state = ? AND id NOT IN (SELECT episode_id FROM downloads WHERE claimed_at IS NOT NULL)"
+		args := []interface{}{state}
+		if podcastID != "" {
+			query += " AND podcast_id = ?"
+			args = append(args, podcastID)
+		}
+		rows, err := tx.QueryContext(ctx, query, args...)
 		if err != nil {
-			return nil // Skip files we can't access
+			return err
 		}
-		if info.IsDir() {
-			return nil // Skip directories
+		var ids []string
+		for rows.Next() {
+			var id string
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return err
+			}
+			ids = append(ids, id)
 		}
-		// Check if this file is in the database
-		if !knownFiles[path] {
-			danglingFiles = append(danglingFiles, domain.DanglingFile{
-				Path:      path,
-				SizeBytes: info.Size(),
-			})
+		if err := rows.Close(); err != nil {
+			return err
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		now := time.Now().UTC()
+		for _, id := range ids {
+			if _, err := tx.ExecContext(ctx, "UPDATE episodes SET state = ?, retry_count = 0 WHERE id = ?", domain.EpisodeStateQueued, id); err != nil {
+				return err
+			}
+			if _, err := tx.ExecContext(ctx, `INSERT INTO downloads (episode_id, enqueued_at, priority)
+VALUES (?, ?, 0)
+ON CONFLICT(episode_id) DO UPDATE SET enqueued_at = excluded.enqueued_at`, id, now); err != nil {
+				return err
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			return err
 		}
+		committed = true
+		queued = len(ids)
 		return nil
 	})
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
-
-	return danglingFiles, nil
-}
-
-func (s *Store) MarkAllEpisodesSeen(ctx context.Context) error {
-	_, err := s.db.ExecContext(ctx, "UPDATE episodes SET state = ? WHERE state = ?", domain.EpisodeStateSeen, domain.EpisodeStateNew)
-	return err
+	return queued, nil
 }
 
-func (s *Store) GetEpisodeInfo(ctx context.Context, episodeID string) (domain.EpisodeInfo, error) {
-	var info domain.EpisodeInfo
-	var published sql.NullString
-	var filePath sql.NullString
-	var hash sql.NullString
-	err := s.db.QueryRowContext(ctx, `SELECT e.id, e.title, COALESCE(e.description, ''), e.state, e.published_at, e.file_path, e.enclosure_url, e.hash, e.size_bytes, p.id, p.title
-FROM episodes e
-JOIN podcasts p ON p.id = e.podcast_id
-WHERE e.id = ?`, episodeID).
-		Scan(&info.ID, &info.Title, &info.Description, &info.State, &published, &filePath, &info.EnclosureURL, &hash, &info.SizeBytes, &info.PodcastID, &info.PodcastTitle)
-	if err != nil {
-		return domain.EpisodeInfo{}, err
+// SetEpisodeCorrupt marks a DOWNLOADED episode CORRUPT, or with corrupt
+// false a CORRUPT one DOWNLOADED again. It reports whether the state changed.
+func (s *Store) SetEpisodeCorrupt(ctx context.Context, episodeID string, corrupt bool) (bool, error) {
+	from, to := domain.EpisodeStateDownloaded, domain.EpisodeStateCorrupt
+	if !corrupt {
+		from, to = to, from
 	}
-	if published.Valid {
-		if parsed, err := time.Parse(time.RFC3339Nano, published.String); err == nil {
-			info.PublishedAt = parsed
-			info.HasPublish = true
-		} else if parsed, err := time.Parse(time.RFC3339, published.String); err == nil {
-			info.PublishedAt = parsed
-			info.HasPublish = true
+	var affected int64
+	err := s.withRetry(ctx, func() error {
+		res, err := s.db.ExecContext(ctx, "UPDATE episodes SET state = ? WHERE id = ? AND state = ?", to, episodeID, from)
+		if err != nil {
+			return err
 		}
-	}
-	if filePath.Valid {
-		info.FilePath = filePath.String
-	}
-	if hash.Valid {
-		info.Hash = hash.String
-	}
-	return info, nil
+		affected, err = res.RowsAffected()
+		return err
+	})
+	return affected > 0, err
 }
 
-func (s *Store) UpdateEpisodeState(ctx context.Context, episodeID, state string) error {
-	_, err := s.db.ExecContext(ctx, "UPDATE episodes SET state = ? WHERE id = ?", state, episodeID)
-	return err
+// EnqueueEpisodes queues the episodes with the given IDs in one transaction
+// and returns how many were queued. Ignored episodes, downloads a worker
+// holds and queued episodes that have not failed are left alone.
+func (s *Store) EnqueueEpisodes(ctx context.Context, episodeIDs []string) (int, error) {
+	return s.updateEpisodes(ctx, episodeIDs, func(tx *sql.Tx, id string, now time.Time) (bool, error) {
+		res, err := tx.ExecContext(ctx, `UPDATE episodes SET state = ?, retry_count = 0
+WHERE id = ? AND state != ? AND NOT (state = ? AND COALESCE(retry_count, 0) = 0)
+AND id NOT IN (SELECT episode_id FROM downloads WHERE claimed_at IS NOT NULL)`,
+			domain.EpisodeStateQueued, id, domain.EpisodeStateIgnored, domain.EpisodeStateQueued)
+		if err != nil {
+			return false, err
+		}
+		if affected, err := res.RowsAffected(); err != nil || affected == 0 {
+			return false, err
+		}
+		_, err = tx.ExecContext(ctx, `INSERT INTO downloads (episode_id, enqueued_at, priority)
+VALUES (?, ?, 0)
+ON CONFLICT(episode_id) DO UPDATE SET enqueued_at = excluded.enqueued_at, paused_at = NULL`, id, now)
+		return err == nil, err
+	})
 }
 
-// CheckAndUpdateDeletedFiles checks all downloaded episodes and marks those with
-// missing files as DELETED.
-func (s *Store) CheckAndUpdateDeletedFiles(ctx context.Context) error {
-	rows, err := s.db.QueryContext(ctx, `SELECT id, file_path FROM episodes WHERE state = ? AND file_path IS NOT NULL AND file_path != ''`, domain.EpisodeStateDownloaded)
+// EnqueueLatestEpisodes queues those of the n newest episodes of a podcast
+// that are NEW or SEEN, in one transaction, and returns how many were
+// queued. Episodes without a publish date count as oldest.
+func (s *Store) EnqueueLatestEpisodes(ctx context.Context, podcastID string, n int) (int, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id FROM episodes WHERE podcast_id = ?
+ORDER BY CASE WHEN published_at IS NULL OR published_at = '' THEN 1 ELSE 0 END, published_at DESC, id
+LIMIT ?`, podcastID, n)
 	if err != nil {
-		return err
+		return 0, err
 	}
-	defer rows.Close()
-
-	var episodesToUpdate []string
+	var ids []string
 	for rows.Next() {
-		var id, filePath string
-		if err := rows.Scan(&id, &filePath); err != nil {
-			return err
-		}
-		// Check if file exists
-		if _, err := os.Stat(filePath); os.IsNotExist(err) {
-			episodesToUpdate = append(episodesToUpdate, id)
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
 		}
+		ids = append(ids, id)
+	}
+	if err := rows.Close(); err != nil {
+		return 0, err
 	}
 	if err := rows.Err(); err != nil {
-		return err
+		return 0, err
 	}
 
-	// Update all episodes with missing files
-	for _, id := range episodesToUpdate {
-		if err := s.UpdateEpisodeState(ctx, id, domain.EpisodeStateDeleted); err != nil {
-			return err
+	return s.updateEpisodes(ctx, ids, func(tx *sql.Tx, id string, now time.Time) (bool, error) {
+		res, err := tx.ExecContext(ctx, "UPDATE episodes SET state = ?, retry_count = 0 WHERE id = ? AND state IN (?, ?)",
+			domain.EpisodeStateQueued, id, domain.EpisodeStateNew, domain.EpisodeStateSeen)
+		if err != nil {
+			return false, err
 		}
-	}
+		if affected, err := res.RowsAffected(); err != nil || affected == 0 {
+			return false, err
+		}
+		_, err = tx.ExecContext(ctx, `INSERT INTO downloads (episode_id, enqueued_at, priority)
+VALUES (?, ?, 0)
+ON CONFLICT(episode_id) DO UPDATE SET enqueued_at = excluded.enqueued_at, paused_at = NULL`, id, now)
+		return err == nil, err
+	})
+}
 
-	return nil
+// IgnoreEpisodes marks the episodes with the given IDs as IGNORED and drops
+// them from the download queue in one transaction, returning how many were
+// ignored. Downloads a worker holds are left alone.
+func (s *Store) IgnoreEpisodes(ctx context.Context, episodeIDs []string) (int, error) {
+	return s.updateEpisodes(ctx, episodeIDs, func(tx *sql.Tx, id string, _ time.Time) (bool, error) {
+		res, err := tx.ExecContext(ctx, `UPDATE episodes SET state = ?
+WHERE id = ? AND state != ? AND id NOT IN (SELECT episode_id FROM downloads WHERE claimed_at IS NOT NULL)`,
+			domain.EpisodeStateIgnored, id, domain.EpisodeStateIgnored)
+		if err != nil {
+			return false, err
+		}
+		if affected, err := res.RowsAffected(); err != nil || affected == 0 {
+			return false, err
+		}
+		_, err = tx.ExecContext(ctx, "DELETE FROM downloads WHERE episode_id = ?", id)
+		return err == nil, err
+	})
 }
 
-// CorrectQueuedStates checks all queued episodes and updates their state to DOWNLOADED
-// if the file already exists on the filesystem. This fixes episodes stuck in QUEUED state.
-func (s *Store) CorrectQueuedStates(ctx context.Context) error {
-	rows, err := s.db.QueryContext(ctx, `SELECT id, file_path FROM episodes WHERE state = ? AND file_path IS NOT NULL AND file_path != ''`, domain.EpisodeStateQueued)
-	if err != nil {
-		return err
-	}
-	defer rows.Close()
+// MarkEpisodesSeen marks the NEW and IGNORED episodes among the given IDs as
+// SEEN in one transaction and returns how many changed.
+func (s *Store) MarkEpisodesSeen(ctx context.Context, episodeIDs []string) (int, error) {
+	return s.updateEpisodes(ctx, episodeIDs, func(tx *sql.Tx, id string, _ time.Time) (bool, error) {
+		res, err := tx.ExecContext(ctx, "UPDATE episodes SET state = ? WHERE id = ? AND state IN (?, ?)",
+			domain.EpisodeStateSeen, id, domain.EpisodeStateNew, domain.EpisodeStateIgnored)
+		if err != nil {
+			return false, err
+		}
+		affected, err := res.RowsAffected()
+		return affected > 0, err
+	})
+}
 
-	var episodesToUpdate []string
-	for rows.Next() {
-		var id, filePath string
-		if err := rows.Scan(&id, &filePath); err != nil {
+// updateEpisodes applies update to each episode in one transaction and
+// counts the episodes it reports as changed.
+func (s *Store) updateEpisodes(ctx context.Context, episodeIDs []string, update func(tx *sql.Tx, id string, now time.Time) (bool, error)) (int, error) {
+	var changed int
+	err := s.withRetry(ctx, func() error {
+		changed = 0
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
 			return err
 		}
-		// Check if file exists
-		if _, err := os.Stat(filePath); err == nil {
-			// File exists, should be marked as DOWNLOADED
-			episodesToUpdate = append(episodesToUpdate, id)
-		}
-	}
-	if err := rows.Err(); err != nil {
-		return err
-	}
+		committed := false
+		defer func() {
+			if !committed {
+				tx.Rollback()
+			}
+		}()
 
-	// Update all episodes with existing files
-	for _, id := range episodesToUpdate {
-		if err := s.UpdateEpisodeState(ctx, id, domain.EpisodeStateDownloaded); err != nil {
+		now := time.Now().UTC()
+		for _, id := range episodeIDs {
+			ok, err := update(tx, id, now)
+			if err != nil {
+				return err
+			}
+			if ok {
+				changed++
+			}
+		}
+		if err := tx.Commit(); err != nil {
 			return err
 		}
+		committed = true
+		return nil
+	})
+	if err != nil {
+		return 0, err
 	}
-
-	return nil
-}
-
-func (s *Store) RemoveFromQueue(ctx context.Context, episodeID string) error {
-	_, err := s.db.ExecContext(ctx, "DELETE FROM downloads WHERE episode_id = ?", episodeID)
-	return err
-}
-
-func (s *Store) RequeueEpisode(ctx context.Context, episodeID string) error {
-	_, err := s.db.ExecContext(ctx, `INSERT INTO downloads (episode_id, enqueued_at, priority)
-VALUES (?, ?, 0)
-ON CONFLICT(episode_id) DO UPDATE SET enqueued_at = excluded.enqueued_at`, episodeID, time.Now().UTC())
-	return err
+	return changed, nil
 }
 
-func (s *Store) EnqueueEpisode(ctx context.Context, episodeID string) error {
+func (s *Store) PersistDownloadResult(ctx context.Context, episodeID, finalPath, hash string) error {
 	return s.withRetry(ctx, func() error {
 		tx, err := s.db.BeginTx(ctx, nil)
 		if err != nil {
@@ -496,12 +1931,16 @@ func (s *Store) EnqueueEpisode(ctx context.Context, episodeID string) error {
 			}
 		}()
 
-		if _, err := tx.ExecContext(ctx, "UPDATE episodes SET state = ?, retry_count = 0 WHERE id = ?", domain.EpisodeStateQueued, episodeID); err != nil {
+		now := time.Now().UTC().Format(time.RFC3339Nano)
+		if _, err := tx.ExecContext(ctx, "UPDATE episodes SET state = ?, downloaded_at = ?, file_path = ?, hash = ?, retry_count = 0, last_error = '', transcoded_at = NULL WHERE id = ?", domain.EpisodeStateDownloaded, now, finalPath, hash, episodeID); err != nil {
 			return err
 		}
-		if _, err := tx.ExecContext(ctx, `INSERT INTO downloads (episode_id, enqueued_at, priority)
-VALUES (?, ?, 0)
-ON CONFLICT(episode_id) DO UPDATE SET enqueued_at = excluded.enqueued_at`, episodeID, time.Now().UTC()); err != nil {
+		// Remove episode from downloads table since it's now successfully downloaded
+		if _, err := tx.ExecContext(ctx, "DELETE FROM downloads WHERE episode_id = ?", episodeID); err != nil {
+			return err
+		}
+		// A new file has not been post-processed yet
+		if _, err := tx.ExecContext(ctx, "DELETE FROM post_process WHERE episode_id = ?", episodeID); err != nil {
 			return err
 		}
 		if err := tx.Commit(); err != nil {
@@ -512,7 +1951,10 @@ ON CONFLICT(episode_id) DO UPDATE SET enqueued_at = excluded.enqueued_at`, episo
 	})
 }
 
-func (s *Store) PersistDownloadResult(ctx context.Context, episodeID, finalPath, hash string) error {
+// RecordTranscode records the file a download was transcoded to. Its size
+// replaces the enclosure length, which refreshes then leave alone until the
+// episode is downloaded again.
+func (s *Store) RecordTranscode(ctx context.Context, episodeID, filePath, hash string, size int64) error {
 	return s.withRetry(ctx, func() error {
 		tx, err := s.db.BeginTx(ctx, nil)
 		if err != nil {
@@ -525,12 +1967,7 @@ func (s *Store) PersistDownloadResult(ctx context.Context, episodeID, finalPath,
 			}
 		}()
 
-		now := time.Now().UTC().Format(time.RFC3339Nano)
-		if _, err := tx.ExecContext(ctx, "UPDATE episodes SET state = ?, downloaded_at = ?, file_path = ?, hash = ?, retry_count = 0 WHERE id = ?", domain.EpisodeStateDownloaded, now, finalPath, hash, episodeID); err != nil {
-			return err
-		}
-		// Remove episode from downloads table since it's now successfully downloaded
-		if _, err := tx.ExecContext(ctx, "DELETE FROM downloads WHERE episode_id = ?", episodeID); err != nil {
+		if err := recordProcessedFile(ctx, tx, episodeID, filePath, hash, size); err != nil {
 			return err
 		}
 		if err := tx.Commit(); err != nil {
@@ -541,11 +1978,35 @@ func (s *Store) PersistDownloadResult(ctx context.Context, episodeID, finalPath,
 	})
 }
 
-func (s *Store) IncrementRetryCount(ctx context.Context, episodeID string) error {
-	_, err := s.db.ExecContext(ctx, "UPDATE episodes SET retry_count = retry_count + 1 WHERE id = ?", episodeID)
+// recordProcessedFile stores the file a download became after transcoding
+// or post-processing. When the content changed, its size replaces the
+// enclosure length and transcoded_at is set, as by RecordTranscode.
+func recordProcessedFile(ctx context.Context, tx *sql.Tx, episodeID, filePath, hash string, size int64) error {
+	_, err := tx.ExecContext(ctx, `UPDATE episodes SET
+size_bytes = CASE WHEN hash IS ? THEN size_bytes ELSE ? END,
+transcoded_at = CASE WHEN hash IS ? THEN transcoded_at ELSE ? END,
+file_path = ?, hash = ?
+WHERE id = ?`,
+		hash, size, hash, time.Now().UTC().Format(time.RFC3339Nano), filePath, hash, episodeID)
 	return err
 }
 
+func (s *Store) IncrementRetryCount(ctx context.Context, episodeID string) error {
+	return s.withRetry(ctx, func() error {
+		_, err := s.db.ExecContext(ctx, "UPDATE episodes SET retry_count = retry_count + 1 WHERE id = ?", episodeID)
+		return err
+	})
+}
+
+// RecordDownloadFailure counts a failed download attempt and keeps its
+// error, which stays until a download of the episode succeeds.
+func (s *Store) RecordDownloadFailure(ctx context.Context, episodeID, message string) error {
+	return s.withRetry(ctx, func() error {
+		_, err := s.db.ExecContext(ctx, "UPDATE episodes SET retry_count = retry_count + 1, last_error = ? WHERE id = ?", message, episodeID)
+		return err
+	})
+}
+
 func (s *Store) ClaimNextDownload(ctx context.Context) (string, error) {
 	var episodeID string
 	err := s.withRetry(ctx, func() error {
@@ -562,7 +2023,11 @@ func (s *Store) ClaimNextDownload(ctx context.Context) (string, error) {
 
 		episodeID = ""
 		now := time.Now().UTC().Format(time.RFC3339Nano)
-		err = tx.QueryRowContext(ctx, `SELECT episode_id FROM downloads WHERE claimed_at IS NULL ORDER BY priority DESC, enqueued_at LIMIT 1`).Scan(&episodeID)
+		// Downloads that exhausted their retries wait until they are queued again.
+		err = tx.QueryRowContext(ctx, `SELECT d.episode_id FROM downloads d
+JOIN episodes e ON e.id = d.episode_id
+WHERE d.claimed_at IS NULL AND d.paused_at IS NULL AND COALESCE(e.retry_count, 0) = 0
+ORDER BY d.priority DESC, d.enqueued_at LIMIT 1`).Scan(&episodeID)
 		if err != nil {
 			if errors.Is(err, sql.ErrNoRows) {
 				return ErrNoDownloadTask
@@ -594,6 +2059,59 @@ func (s *Store) ClaimNextDownload(ctx context.Context) (string, error) {
 	return episodeID, nil
 }
 
+// SubscriptionByGUID reports whether a podcast with the given podcast:guid
+// is subscribed, and its title. An empty guid never matches.
+func (s *Store) SubscriptionByGUID(ctx context.Context, guid string) (bool, string, error) {
+	if guid == "" {
+		return false, "", nil
+	}
+	var title string
+	err := s.db.QueryRowContext(ctx, "SELECT title FROM podcasts WHERE podcast_guid = ? LIMIT 1", guid).Scan(&title)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, "", nil
+		}
+		return false, "", err
+	}
+	return true, title, nil
+}
+
+// FindSubscription returns the podcast with the given podcast:guid or, when
+// none has it, feed URL. Empty keys are not looked up.
+func (s *Store) FindSubscription(ctx context.Context, guid, feedURL string) (domain.Podcast, bool, error) {
+	for _, lookup := range []struct{ column, value string }{{"podcast_guid", guid}, {"feed_url", feedURL}} {
+		if lookup.value == "" {
+			continue
+		}
+		var podcast domain.Podcast
+		err := s.db.QueryRowContext(ctx, "SELECT id, title, feed_url, subscribed_at FROM podcasts WHERE "+lookup.column+" = ? LIMIT 1", lookup.value).
+			Scan(&podcast.ID, &podcast.Title, &podcast.FeedURL, &podcast.CreatedAt)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return domain.Podcast{}, false, err
+		}
+		return podcast, true, nil
+	}
+	return domain.Podcast{}, false, nil
+}
+
+// SetTitleAndFeedURL changes a podcast's title and feed URL. The next
+// refresh fetches the new URL, and its title replaces this one unless the
+// feed has none. It reports false for an unknown podcast.
+func (s *Store) SetTitleAndFeedURL(ctx context.Context, podcastID, title, feedURL string) (bool, error) {
+	res, err := s.db.ExecContext(ctx, "UPDATE podcasts SET title = ?, feed_url = ? WHERE id = ?", title, feedURL, podcastID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
 func (s *Store) HasSubscriptionByFeedURL(ctx context.Context, feedURL string) (bool, error) {
 	var count int
 	if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM podcasts WHERE feed_url = ?", feedURL).Scan(&count); err != nil {
@@ -603,28 +2121,46 @@ func (s *Store) HasSubscriptionByFeedURL(ctx context.Context, feedURL string) (b
 }
 
 func (s *Store) ListPodcastExports(ctx context.Context) ([]domain.PodcastExport, error) {
-	rows, err := s.db.QueryContext(ctx, "SELECT title, feed_url FROM podcasts ORDER BY LOWER(title)")
+	rows, err := s.db.QueryContext(ctx, "SELECT id, title, feed_url, COALESCE(podcast_guid, ''), COALESCE(download_dir, '') FROM podcasts ORDER BY LOWER(title)")
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
+	var ids []string
 	exports := make([]domain.PodcastExport, 0, 16)
 	for rows.Next() {
+		var id string
 		var export domain.PodcastExport
-		if err := rows.Scan(&export.Title, &export.FeedURL); err != nil {
+		if err := rows.Scan(&id, &export.Title, &export.FeedURL, &export.GUID, &export.DownloadDir); err != nil {
 			return nil, err
 		}
+		ids = append(ids, id)
 		exports = append(exports, export)
 	}
 	if err := rows.Err(); err != nil {
 		return nil, err
 	}
+	rows.Close()
+
+	tags, err := s.listTags(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i, id := range ids {
+		exports[i].Tags = tags[id]
+	}
 	return exports, nil
 }
 
 var ErrNoDownloadTask = errors.New("no download task available")
 
+// ErrAlreadyDownloading is returned when queueing an episode a worker is
+// currently downloading.
+var ErrAlreadyDownloading = errors.New("episode is already downloading")
+
+// withRetry runs the write fn through write, retrying it with a growing
+// backoff while another process holds the database's write lock.
 func (s *Store) withRetry(ctx context.Context, fn func() error) error {
 	const attempts = 5
 	var err error
@@ -632,7 +2168,7 @@ func (s *Store) withRetry(ctx context.Context, fn func() error) error {
 		if ctx.Err() != nil {
 			return ctx.Err()
 		}
-		err = fn()
+		err = s.write(ctx, fn)
 		if err == nil {
 			return nil
 		}
@@ -650,6 +2186,20 @@ func (s *Store) withRetry(ctx context.Context, fn func() error) error {
 	return err
 }
 
+// write runs fn once no other write of the store is running. Download
+// workers finishing at the same time queue up here in order, instead of
+// contending for SQLite's write lock and backing off on SQLITE_BUSY. Waiting
+// for a turn ends when ctx is cancelled.
+func (s *Store) write(ctx context.Context, fn func() error) error {
+	select {
+	case s.writeSlot <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-s.writeSlot }()
+	return fn()
+}
+
 func waitWithContext(ctx context.Context, d time.Duration) error {
 	if d <= 0 {
 		select {
@@ -3,7 +3,11 @@ package repository_test
 import (
 	"context"
 	"errors"
+	"fmt"
+	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -96,7 +100,7 @@ func TestStoreSaveSubscriptionAndSummaries(t *testing.T) {
 		t.Errorf("summary total count = %d, want 2", summary.TotalCount)
 	}
 
-	episodes, err := store.ListEpisodes(ctx)
+	episodes, err := store.ListEpisodes(ctx, repository.EpisodeListing{}, repository.Page{})
 	if err != nil {
 		t.Fatalf("ListEpisodes: %v", err)
 	}
@@ -187,6 +191,33 @@ func TestStoreSaveSubscriptionAndSummaries(t *testing.T) {
 	}
 }
 
+func TestSaveSubscriptionEpisodesReturnsNewEpisodes(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newTestStore(t)
+
+	data := domain.SubscriptionData{
+		Podcast: domain.Podcast{ID: "pod-1", Title: "Test Podcast", FeedURL: "http://example.com/feed.xml"},
+		Episodes: []domain.EpisodeInput{
+			{ID: "ep-1", Title: "Episode One", Enclosure: "http://example.com/ep1.mp3"},
+		},
+	}
+	if _, err := store.SaveSubscription(ctx, data); err != nil {
+		t.Fatalf("SaveSubscription: %v", err)
+	}
+
+	data.Episodes = append(data.Episodes,
+		domain.EpisodeInput{ID: "ep-2", Title: " Episode Two ", Enclosure: "http://example.com/ep2.mp3"},
+		domain.EpisodeInput{ID: "ep-3", Title: "No enclosure"},
+	)
+	added, err := store.SaveSubscriptionEpisodes(ctx, data)
+	if err != nil {
+		t.Fatalf("SaveSubscriptionEpisodes: %v", err)
+	}
+	want := []domain.AddedEpisode{{ID: "ep-2", Title: "Episode Two"}}
+	if !reflect.DeepEqual(added, want) {
+		t.Fatalf("added = %+v, want %+v", added, want)
+	}
+}
 func TestStoreQueueAndDownloadLifecycle(t *testing.T) {
 	ctx := context.Background()
 	store, lookupRetry := newTestStore(t)
@@ -211,6 +242,9 @@ func TestStoreQueueAndDownloadLifecycle(t *testing.T) {
 	if _, err := store.SaveSubscription(ctx, data); err != nil {
 		t.Fatalf("SaveSubscription: %v", err)
 	}
+	if count, err := store.CountNewEpisodes(ctx); err != nil || count != 1 {
+		t.Fatalf("CountNewEpisodes = %d, %v; want 1", count, err)
+	}
 
 	if err := store.IncrementRetryCount(ctx, "queue-ep-1"); err != nil {
 		t.Fatalf("IncrementRetryCount: %v", err)
@@ -246,6 +280,20 @@ func TestStoreQueueAndDownloadLifecycle(t *testing.T) {
 		t.Fatalf("expected ErrNoDownloadTask, got %v", err)
 	}
 
+	if err := store.RecordDownloadFailure(ctx, "queue-ep-1", "download failed: 503 Service Unavailable"); err != nil {
+		t.Fatalf("RecordDownloadFailure: %v", err)
+	}
+	queued, err := store.ListQueuedEpisodes(ctx)
+	if err != nil {
+		t.Fatalf("ListQueuedEpisodes: %v", err)
+	}
+	if len(queued) != 1 || queued[0].RetryCount != 1 || queued[0].LastError != "download failed: 503 Service Unavailable" {
+		t.Fatalf("expected the failure on the queue entry, got %+v", queued)
+	}
+	if count, err := store.CountFailedDownloads(ctx); err != nil || count != 1 {
+		t.Fatalf("CountFailedDownloads = %d, %v; want 1", count, err)
+	}
+
 	finalPath := "/downloads/queue-ep-1.mp3"
 	hash := "hash123"
 	if err := store.PersistDownloadResult(ctx, "queue-ep-1", finalPath, hash); err != nil {
@@ -265,6 +313,12 @@ func TestStoreQueueAndDownloadLifecycle(t *testing.T) {
 	if info.Hash != hash {
 		t.Fatalf("hash = %s, want %s", info.Hash, hash)
 	}
+	if info.LastError != "" {
+		t.Fatalf("last error after persist = %q, want it cleared", info.LastError)
+	}
+	if count, err := store.CountFailedDownloads(ctx); err != nil || count != 0 {
+		t.Fatalf("CountFailedDownloads after persist = %d, %v; want 0", count, err)
+	}
 
 	if err := store.EnqueueEpisode(ctx, "queue-ep-1"); err != nil {
 		t.Fatalf("EnqueueEpisode second time: %v", err)
@@ -277,6 +331,256 @@ func TestStoreQueueAndDownloadLifecycle(t *testing.T) {
 	}
 }
 
+func TestConcurrentWorkerWritesDoNotFail(t *testing.T) {
+	ctx := context.Background()
+	store, lookupRetry := newTestStore(t)
+
+	const workers, rounds = 8, 25
+	data := domain.SubscriptionData{Podcast: domain.Podcast{ID: "pod", Title: "Podcast", FeedURL: "http://example.com/feed.xml", CreatedAt: time.Now().UTC()}}
+	for i := 0; i < workers; i++ {
+		data.Episodes = append(data.Episodes, domain.EpisodeInput{ID: fmt.Sprintf("ep-%d", i), Title: "Episode", Enclosure: "http://example.com/ep.mp3"})
+	}
+	if _, err := store.SaveSubscription(ctx, data); err != nil {
+		t.Fatalf("SaveSubscription: %v", err)
+	}
+
+	errs := make(chan error, workers)
+	for i := 0; i < workers; i++ {
+		go func(id string) {
+			for r := 0; r < rounds; r++ {
+				if err := store.RecordDownloadFailure(ctx, id, "timeout"); err != nil {
+					errs <- err
+					return
+				}
+				if err := store.RequeueEpisode(ctx, id); err != nil {
+					errs <- err
+					return
+				}
+			}
+			errs <- store.PersistDownloadResult(ctx, id, "/podcasts/"+id+".mp3", "hash-"+id)
+		}(fmt.Sprintf("ep-%d", i))
+	}
+	for i := 0; i < workers; i++ {
+		if err := <-errs; err != nil {
+			t.Fatalf("concurrent write failed: %v", err)
+		}
+	}
+	for i := 0; i < workers; i++ {
+		if retry := lookupRetry(ctx, fmt.Sprintf("ep-%d", i)); retry != 0 {
+			t.Fatalf("retry count of ep-%d = %d, want 0 after the download succeeded", i, retry)
+		}
+	}
+	if count, err := store.CountDownloadedEpisodes(ctx); err != nil || count != workers {
+		t.Fatalf("CountDownloadedEpisodes = %d, %v; want %d", count, err, workers)
+	}
+}
+
+func TestRefreshKeepsSizeOfTranscodedDownload(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newTestStore(t)
+
+	data := domain.SubscriptionData{
+		Podcast:  domain.Podcast{ID: "pod", Title: "Podcast", FeedURL: "http://example.com/feed.xml", CreatedAt: time.Now().UTC()},
+		Episodes: []domain.EpisodeInput{{ID: "ep", Title: "Episode", Enclosure: "http://example.com/ep.mp3", SizeBytes: 1000}},
+	}
+	if _, err := store.SaveSubscription(ctx, data); err != nil {
+		t.Fatalf("SaveSubscription: %v", err)
+	}
+	size := func() int64 {
+		t.Helper()
+		info, err := store.GetEpisodeInfo(ctx, "ep")
+		if err != nil {
+			t.Fatalf("GetEpisodeInfo: %v", err)
+		}
+		return info.SizeBytes
+	}
+
+	if err := store.PersistDownloadResult(ctx, "ep", "/podcasts/ep.mp3", "hash"); err != nil {
+		t.Fatalf("PersistDownloadResult: %v", err)
+	}
+	if err := store.RecordTranscode(ctx, "ep", "/podcasts/ep.opus", "hash2", 400); err != nil {
+		t.Fatalf("RecordTranscode: %v", err)
+	}
+	if _, err := store.SaveSubscription(ctx, data); err != nil {
+		t.Fatalf("SaveSubscription: %v", err)
+	}
+	if got := size(); got != 400 {
+		t.Fatalf("size after refresh = %d, want the transcoded 400", got)
+	}
+
+	if err := store.PersistDownloadResult(ctx, "ep", "/podcasts/ep.mp3", "hash"); err != nil {
+		t.Fatalf("PersistDownloadResult: %v", err)
+	}
+	if _, err := store.SaveSubscription(ctx, data); err != nil {
+		t.Fatalf("SaveSubscription: %v", err)
+	}
+	if got := size(); got != 1000 {
+		t.Fatalf("size after a new download = %d, want the enclosure length 1000", got)
+	}
+
+	// A post_process step that leaves the file as it is keeps the size; one
+	// that rewrites it is recorded like a transcode
+	step := domain.PostProcessRecord{EpisodeID: "ep", Step: "archive", Status: domain.PostProcessDone}
+	if err := store.RecordPostProcessStep(ctx, step, "/podcasts/ep.mp3", "hash", 999); err != nil {
+		t.Fatalf("RecordPostProcessStep: %v", err)
+	}
+	if got := size(); got != 1000 {
+		t.Fatalf("size after an unchanged file = %d, want 1000", got)
+	}
+	step.Step = "opus"
+	if err := store.RecordPostProcessStep(ctx, step, "/podcasts/ep.opus", "hash3", 300); err != nil {
+		t.Fatalf("RecordPostProcessStep: %v", err)
+	}
+	if _, err := store.SaveSubscription(ctx, data); err != nil {
+		t.Fatalf("SaveSubscription: %v", err)
+	}
+	if got := size(); got != 300 {
+		t.Fatalf("size after an opus step and a refresh = %d, want 300", got)
+	}
+}
+
+func TestMarkFilesDeletedSkipsEpisodesDownloadedAgain(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newTestStore(t)
+
+	data := domain.SubscriptionData{
+		Podcast: domain.Podcast{ID: "pod", Title: "Podcast", FeedURL: "http://example.com/feed.xml", CreatedAt: time.Now().UTC()},
+		Episodes: []domain.EpisodeInput{
+			{ID: "ep1", Title: "One", Enclosure: "http://example.com/1.mp3"},
+			{ID: "ep2", Title: "Two", Enclosure: "http://example.com/2.mp3"},
+		},
+	}
+	if _, err := store.SaveSubscription(ctx, data); err != nil {
+		t.Fatalf("SaveSubscription: %v", err)
+	}
+	for _, id := range []string{"ep1", "ep2"} {
+		if err := store.PersistDownloadResult(ctx, id, "/podcasts/"+id+".mp3", "hash-"+id); err != nil {
+			t.Fatalf("PersistDownloadResult: %v", err)
+		}
+	}
+	files, err := store.ListDownloadedFiles(ctx)
+	if err != nil {
+		t.Fatalf("ListDownloadedFiles: %v", err)
+	}
+	if len(files) != 2 || files["ep1"] != "/podcasts/ep1.mp3" {
+		t.Fatalf("unexpected downloaded files %v", files)
+	}
+
+	// ep2 was downloaded again to another path since the files were listed
+	if err := store.PersistDownloadResult(ctx, "ep2", "/podcasts/ep2-new.mp3", "hash-new"); err != nil {
+		t.Fatalf("PersistDownloadResult: %v", err)
+	}
+	marked, err := store.MarkFilesDeleted(ctx, files)
+	if err != nil {
+		t.Fatalf("MarkFilesDeleted: %v", err)
+	}
+	if len(marked) != 1 || marked[0] != "ep1" {
+		t.Fatalf("marked %v, want only ep1", marked)
+	}
+	info, err := store.GetEpisodeInfo(ctx, "ep2")
+	if err != nil {
+		t.Fatalf("GetEpisodeInfo: %v", err)
+	}
+	if info.State != domain.EpisodeStateDownloaded {
+		t.Fatalf("ep2 state = %s, want DOWNLOADED", info.State)
+	}
+}
+
+func TestMarkFilesDeletedBatchesLargeLibraries(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newTestStore(t)
+
+	// More episodes than one batched statement names
+	const episodes = 1100
+	data := domain.SubscriptionData{
+		Podcast: domain.Podcast{ID: "pod", Title: "Podcast", FeedURL: "http://example.com/feed.xml", CreatedAt: time.Now().UTC()},
+	}
+	for i := range episodes {
+		data.Episodes = append(data.Episodes, domain.EpisodeInput{ID: fmt.Sprintf("ep%04d", i), Title: fmt.Sprintf("Episode %d", i), Enclosure: fmt.Sprintf("http://example.com/%d.mp3", i)})
+	}
+	if _, err := store.SaveSubscription(ctx, data); err != nil {
+		t.Fatalf("SaveSubscription: %v", err)
+	}
+	for _, ep := range data.Episodes {
+		if err := store.PersistDownloadResult(ctx, ep.ID, "/podcasts/"+ep.ID+".mp3", "hash-"+ep.ID); err != nil {
+			t.Fatalf("PersistDownloadResult: %v", err)
+		}
+	}
+	files, err := store.ListDownloadedFiles(ctx)
+	if err != nil {
+		t.Fatalf("ListDownloadedFiles: %v", err)
+	}
+	// The last episode moved since the files were listed
+	files["ep1099"] = "/elsewhere/ep1099.mp3"
+
+	marked, err := store.MarkFilesDeleted(ctx, files)
+	if err != nil {
+		t.Fatalf("MarkFilesDeleted: %v", err)
+	}
+	if len(marked) != episodes-1 || marked[0] != "ep0000" || marked[len(marked)-1] != "ep1098" {
+		t.Fatalf("marked %d episodes, want all but ep1099", len(marked))
+	}
+	remaining, err := store.ListDownloadedFiles(ctx)
+	if err != nil {
+		t.Fatalf("ListDownloadedFiles: %v", err)
+	}
+	if len(remaining) != 1 || remaining["ep1099"] == "" {
+		t.Fatalf("unexpected downloaded files %v", remaining)
+	}
+}
+
+func TestSaveSubscriptionPrunesEpisodesBeyondKeep(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newTestStore(t)
+
+	now := time.Now().UTC()
+	data := domain.SubscriptionData{
+		Podcast: domain.Podcast{ID: "pod", Title: "Daily News", FeedURL: "http://example.com/feed.xml", CreatedAt: now},
+	}
+	for i := range 6 {
+		published := now.Add(-time.Duration(i) * 24 * time.Hour)
+		data.Episodes = append(data.Episodes, domain.EpisodeInput{ID: fmt.Sprintf("day%d", i), Title: fmt.Sprintf("Day %d", i), Enclosure: fmt.Sprintf("http://example.com/%d.mp3", i), PublishedAt: &published})
+	}
+	if _, err := store.SaveSubscription(ctx, data); err != nil {
+		t.Fatalf("SaveSubscription: %v", err)
+	}
+	// An old download is kept beyond the cap
+	if err := store.PersistDownloadResult(ctx, "day4", "/podcasts/day4.mp3", "hash"); err != nil {
+		t.Fatalf("PersistDownloadResult: %v", err)
+	}
+
+	data.KeepEpisodes = 2
+	published := now.Add(time.Hour)
+	data.Episodes = append(data.Episodes, domain.EpisodeInput{ID: "latest", Title: "Latest", Enclosure: "http://example.com/latest.mp3", PublishedAt: &published})
+	added, err := store.SaveSubscriptionEpisodes(ctx, data)
+	if err != nil {
+		t.Fatalf("SaveSubscriptionEpisodes: %v", err)
+	}
+	if len(added) != 1 || added[0].ID != "latest" {
+		t.Fatalf("added %v, want only the latest episode", added)
+	}
+	episodes, err := store.ListEpisodes(ctx, repository.EpisodeListing{}, repository.Page{})
+	if err != nil {
+		t.Fatalf("ListEpisodes: %v", err)
+	}
+	var ids []string
+	for _, ep := range episodes {
+		ids = append(ids, ep.Episode.ID)
+	}
+	if got := strings.Join(ids, ","); got != "latest,day0,day4" {
+		t.Fatalf("stored episodes %s, want latest,day0,day4", got)
+	}
+
+	// Pruned episodes still in the feed are not added again
+	added, err = store.SaveSubscriptionEpisodes(ctx, data)
+	if err != nil {
+		t.Fatalf("SaveSubscriptionEpisodes: %v", err)
+	}
+	if len(added) != 0 {
+		t.Fatalf("expected pruned episodes to stay archived, added %v", added)
+	}
+}
+
 func TestListQueuedEpisodesIncludesDownloadedEpisodes(t *testing.T) {
 	ctx := context.Background()
 	store, _ := newTestStore(t)
@@ -369,3 +673,845 @@ func TestListQueuedEpisodesIncludesDownloadedEpisodes(t *testing.T) {
 		t.Errorf("downloaded episode state = %s, want %s", downloaded[0].Episode.State, domain.EpisodeStateDownloaded)
 	}
 }
+
+func TestListDuplicateDownloadsGroupsByHash(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newTestStore(t)
+
+	data := domain.SubscriptionData{
+		Podcast: domain.Podcast{
+			ID:        "dup-pod",
+			Title:     "Duplicate Podcast",
+			FeedURL:   "http://example.com/dup.xml",
+			CreatedAt: time.Now().UTC(),
+		},
+		Episodes: []domain.EpisodeInput{
+			{ID: "dup-1", Title: "Promo A", Enclosure: "http://example.com/a.mp3"},
+			{ID: "dup-2", Title: "Promo B", Enclosure: "http://example.com/b.mp3"},
+			{ID: "unique", Title: "Unique", Enclosure: "http://example.com/c.mp3"},
+		},
+	}
+	if _, err := store.SaveSubscription(ctx, data); err != nil {
+		t.Fatalf("SaveSubscription: %v", err)
+	}
+
+	results := map[string]string{"dup-1": "same", "dup-2": "same", "unique": "other"}
+	for _, id := range []string{"dup-1", "dup-2", "unique"} {
+		if err := store.PersistDownloadResult(ctx, id, "/downloads/"+id+".mp3", results[id]); err != nil {
+			t.Fatalf("PersistDownloadResult %s: %v", id, err)
+		}
+	}
+
+	groups, err := store.ListDuplicateDownloads(ctx)
+	if err != nil {
+		t.Fatalf("ListDuplicateDownloads: %v", err)
+	}
+	if len(groups) != 1 {
+		t.Fatalf("duplicate groups = %d, want 1", len(groups))
+	}
+	if groups[0].Hash != "same" || len(groups[0].FilePaths) != 2 {
+		t.Fatalf("unexpected group: %+v", groups[0])
+	}
+
+	path, err := store.FindDownloadedFileByHash(ctx, "same", "dup-2")
+	if err != nil {
+		t.Fatalf("FindDownloadedFileByHash: %v", err)
+	}
+	if path != "/downloads/dup-1.mp3" {
+		t.Fatalf("FindDownloadedFileByHash = %q, want /downloads/dup-1.mp3", path)
+	}
+
+	path, err = store.FindDownloadedFileByHash(ctx, "other", "unique")
+	if err != nil {
+		t.Fatalf("FindDownloadedFileByHash unique: %v", err)
+	}
+	if path != "" {
+		t.Fatalf("expected no duplicate for unique episode, got %q", path)
+	}
+}
+
+func TestEnqueueEpisodesByState(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newTestStore(t)
+
+	for _, podcastID := range []string{"pod-a", "pod-b"} {
+		data := domain.SubscriptionData{
+			Podcast: domain.Podcast{
+				ID:        podcastID,
+				Title:     podcastID,
+				FeedURL:   "http://example.com/" + podcastID + ".xml",
+				CreatedAt: time.Now().UTC(),
+			},
+			Episodes: []domain.EpisodeInput{
+				{ID: podcastID + "-deleted", Title: "Deleted", Enclosure: "http://example.com/" + podcastID + "-1.mp3"},
+				{ID: podcastID + "-kept", Title: "Kept", Enclosure: "http://example.com/" + podcastID + "-2.mp3"},
+			},
+		}
+		if _, err := store.SaveSubscription(ctx, data); err != nil {
+			t.Fatalf("SaveSubscription %s: %v", podcastID, err)
+		}
+		if err := store.UpdateEpisodeState(ctx, podcastID+"-deleted", domain.EpisodeStateDeleted); err != nil {
+			t.Fatalf("UpdateEpisodeState: %v", err)
+		}
+	}
+
+	queued, err := store.EnqueueEpisodesByState(ctx, domain.EpisodeStateDeleted, "pod-a")
+	if err != nil {
+		t.Fatalf("EnqueueEpisodesByState pod-a: %v", err)
+	}
+	if queued != 1 {
+		t.Fatalf("queued for pod-a = %d, want 1", queued)
+	}
+
+	info, err := store.GetEpisodeInfo(ctx, "pod-b-deleted")
+	if err != nil {
+		t.Fatalf("GetEpisodeInfo: %v", err)
+	}
+	if info.State != domain.EpisodeStateDeleted {
+		t.Fatalf("pod-b episode state = %s, want %s", info.State, domain.EpisodeStateDeleted)
+	}
+
+	queued, err = store.EnqueueEpisodesByState(ctx, domain.EpisodeStateDeleted, "")
+	if err != nil {
+		t.Fatalf("EnqueueEpisodesByState all: %v", err)
+	}
+	if queued != 1 {
+		t.Fatalf("queued across podcasts = %d, want 1", queued)
+	}
+
+	list, err := store.ListQueuedEpisodes(ctx)
+	if err != nil {
+		t.Fatalf("ListQueuedEpisodes: %v", err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("queued episodes = %d, want 2", len(list))
+	}
+}
+
+func TestListQueuedEpisodesMarksClaimedAsActive(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newTestStore(t)
+
+	data := domain.SubscriptionData{
+		Podcast: domain.Podcast{
+			ID:        "podcast-active",
+			Title:     "Active Podcast",
+			FeedURL:   "http://example.com/active.xml",
+			CreatedAt: time.Now().UTC(),
+		},
+		Episodes: []domain.EpisodeInput{
+			{ID: "ep-1", Title: "One", Enclosure: "http://example.com/1.mp3"},
+			{ID: "ep-2", Title: "Two", Enclosure: "http://example.com/2.mp3"},
+			{ID: "ep-3", Title: "Three", Enclosure: "http://example.com/3.mp3"},
+		},
+	}
+	if _, err := store.SaveSubscription(ctx, data); err != nil {
+		t.Fatalf("SaveSubscription: %v", err)
+	}
+	for _, id := range []string{"ep-1", "ep-2", "ep-3"} {
+		if err := store.EnqueueEpisode(ctx, id); err != nil {
+			t.Fatalf("EnqueueEpisode %s: %v", id, err)
+		}
+	}
+	claimed, err := store.ClaimNextDownload(ctx)
+	if err != nil {
+		t.Fatalf("ClaimNextDownload: %v", err)
+	}
+
+	queued, err := store.ListQueuedEpisodes(ctx)
+	if err != nil {
+		t.Fatalf("ListQueuedEpisodes: %v", err)
+	}
+	if len(queued) != 3 {
+		t.Fatalf("queued episodes = %d, want 3", len(queued))
+	}
+	if queued[0].Episode.ID != claimed || !queued[0].Active || queued[0].ClaimedAt.IsZero() {
+		t.Fatalf("expected claimed episode %s first and active, got %+v", claimed, queued[0])
+	}
+	for _, result := range queued[1:] {
+		if result.Active || !result.ClaimedAt.IsZero() {
+			t.Fatalf("expected %s to be waiting", result.Episode.ID)
+		}
+	}
+}
+
+func TestEnqueueEpisodeRejectsActiveDownload(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newTestStore(t)
+
+	data := domain.SubscriptionData{
+		Podcast: domain.Podcast{
+			ID:        "podcast-claimed",
+			Title:     "Claimed Podcast",
+			FeedURL:   "http://example.com/claimed.xml",
+			CreatedAt: time.Now().UTC(),
+		},
+		Episodes: []domain.EpisodeInput{
+			{ID: "ep-1", Title: "One", Enclosure: "http://example.com/1.mp3"},
+		},
+	}
+	if _, err := store.SaveSubscription(ctx, data); err != nil {
+		t.Fatalf("SaveSubscription: %v", err)
+	}
+	if err := store.EnqueueEpisode(ctx, "ep-1"); err != nil {
+		t.Fatalf("EnqueueEpisode: %v", err)
+	}
+	if _, err := store.ClaimNextDownload(ctx); err != nil {
+		t.Fatalf("ClaimNextDownload: %v", err)
+	}
+
+	active, err := store.IsDownloadActive(ctx, "ep-1")
+	if err != nil || !active {
+		t.Fatalf("IsDownloadActive = %v, %v; want true", active, err)
+	}
+	if err := store.EnqueueEpisode(ctx, "ep-1"); !errors.Is(err, repository.ErrAlreadyDownloading) {
+		t.Fatalf("EnqueueEpisode err = %v, want ErrAlreadyDownloading", err)
+	}
+	queued, err := store.ListQueuedEpisodes(ctx)
+	if err != nil {
+		t.Fatalf("ListQueuedEpisodes: %v", err)
+	}
+	if len(queued) != 1 || !queued[0].Active {
+		t.Fatalf("expected claim to survive re-queue attempt, got %+v", queued)
+	}
+
+	if err := store.ReleaseClaim(ctx, "ep-1"); err != nil {
+		t.Fatalf("ReleaseClaim: %v", err)
+	}
+	if err := store.EnqueueEpisode(ctx, "ep-1"); err != nil {
+		t.Fatalf("EnqueueEpisode after release: %v", err)
+	}
+}
+
+func TestSummariesCountPlayedEpisodes(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newTestStore(t)
+
+	data := domain.SubscriptionData{
+		Podcast: domain.Podcast{
+			ID:        "podcast-played",
+			Title:     "Played Podcast",
+			FeedURL:   "http://example.com/played.xml",
+			CreatedAt: time.Now().UTC(),
+		},
+		Episodes: []domain.EpisodeInput{
+			{ID: "ep-1", Title: "One", Enclosure: "http://example.com/1.mp3", DurationSec: 1800},
+			{ID: "ep-2", Title: "Two", Enclosure: "http://example.com/2.mp3"},
+			{ID: "ep-3", Title: "Three", Enclosure: "http://example.com/3.mp3"},
+		},
+	}
+	if _, err := store.SaveSubscription(ctx, data); err != nil {
+		t.Fatalf("SaveSubscription: %v", err)
+	}
+	if err := store.PersistDownloadResult(ctx, "ep-2", "/tmp/two.mp3", "hash"); err != nil {
+		t.Fatalf("PersistDownloadResult: %v", err)
+	}
+	if err := store.MarkPlayed(ctx, "ep-1"); err != nil {
+		t.Fatalf("MarkPlayed: %v", err)
+	}
+	if err := store.UpdateEpisodeState(ctx, "ep-3", domain.EpisodeStateIgnored); err != nil {
+		t.Fatalf("UpdateEpisodeState: %v", err)
+	}
+
+	summaries, err := store.ListSubscriptionSummaries(ctx)
+	if err != nil {
+		t.Fatalf("ListSubscriptionSummaries: %v", err)
+	}
+	if len(summaries) != 1 {
+		t.Fatalf("expected 1 summary, got %d", len(summaries))
+	}
+	if got := summaries[0]; got.PlayedCount != 1 || got.UnplayedCount != 1 || got.TotalCount != 3 {
+		t.Fatalf("played/unplayed/total = %d/%d/%d, want 1/1/3", got.PlayedCount, got.UnplayedCount, got.TotalCount)
+	}
+
+	info, err := store.GetEpisodeInfo(ctx, "ep-1")
+	if err != nil {
+		t.Fatalf("GetEpisodeInfo: %v", err)
+	}
+	if info.State != domain.EpisodeStatePlayed || info.DurationSec != 1800 || info.PositionSec != 1800 {
+		t.Fatalf("unexpected played episode info: %+v", info)
+	}
+}
+
+func TestQueryEpisodes(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newTestStore(t)
+
+	day := func(d int) *time.Time {
+		published := time.Date(2024, 3, d, 12, 0, 0, 0, time.UTC)
+		return &published
+	}
+	data := domain.SubscriptionData{
+		Podcast: domain.Podcast{ID: "news", Title: "Daily News", FeedURL: "http://example.com/news.xml", CreatedAt: time.Now().UTC()},
+		Episodes: []domain.EpisodeInput{
+			{ID: "ep-1", Title: "Monday Briefing", Enclosure: "http://example.com/1.mp3", PublishedAt: day(4), SizeBytes: 20 << 20, DurationSec: 1200},
+			{ID: "ep-2", Title: "Tuesday 100% Special", Enclosure: "http://example.com/2.mp3", PublishedAt: day(5), SizeBytes: 150 << 20, DurationSec: 5400},
+			{ID: "ep-3", Title: "Wednesday Briefing", Enclosure: "http://example.com/3.mp3", PublishedAt: day(6), SizeBytes: 30 << 20},
+		},
+	}
+	if _, err := store.SaveSubscription(ctx, data); err != nil {
+		t.Fatalf("SaveSubscription: %v", err)
+	}
+	if err := store.PersistDownloadResult(ctx, "ep-1", "/tmp/one.mp3", "hash"); err != nil {
+		t.Fatalf("PersistDownloadResult: %v", err)
+	}
+
+	for _, tc := range []struct {
+		expr string
+		want []string
+	}{
+		{"title ~ briefing", []string{"ep-3", "ep-1"}},
+		{"title ~ '100%'", []string{"ep-2"}},
+		{"state = downloaded or size > 100MB", []string{"ep-2", "ep-1"}},
+		{"podcast = 'daily news' and not (published >= 2024-03-05)", []string{"ep-1"}},
+		{"duration > 60", []string{"ep-2"}},
+		{"duration < 60", []string{"ep-1"}},
+		{"order by size desc limit 2", []string{"ep-2", "ep-3"}},
+		{"title !~ briefing order by title", []string{"ep-2"}},
+		{"id = \"'; DROP TABLE episodes; --\"", nil},
+	} {
+		results, err := store.QueryEpisodes(ctx, tc.expr)
+		if err != nil {
+			t.Fatalf("QueryEpisodes(%s): %v", tc.expr, err)
+		}
+		var got []string
+		for _, r := range results {
+			got = append(got, r.Episode.ID)
+		}
+		if strings.Join(got, ",") != strings.Join(tc.want, ",") {
+			t.Fatalf("QueryEpisodes(%s) = %v, want %v", tc.expr, got, tc.want)
+		}
+	}
+
+	for _, expr := range []string{"title", "color = red", "size ~ 10", "published > yesterday", "(title ~ a", "title ~ a limit 0", "title = 'open"} {
+		if _, err := store.QueryEpisodes(ctx, expr); !errors.Is(err, repository.ErrInvalidQuery) {
+			t.Fatalf("QueryEpisodes(%s) error = %v, want ErrInvalidQuery", expr, err)
+		}
+	}
+}
+
+func TestSearchEpisodes(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newTestStore(t)
+
+	data := domain.SubscriptionData{
+		Podcast: domain.Podcast{ID: "science", Title: "Science Hour", FeedURL: "http://example.com/science.xml", CreatedAt: time.Now().UTC()},
+		Episodes: []domain.EpisodeInput{
+			{ID: "ep-1", Title: "Solar Power", Description: "Why panels got cheap.", Enclosure: "http://example.com/1.mp3"},
+			{ID: "ep-2", Title: "Weather", Description: "Climate change and the solar cycle.", Enclosure: "http://example.com/2.mp3"},
+			{ID: "ep-3", Title: "Café Chemistry", Description: "Change your climate, brew better.", Enclosure: "http://example.com/3.mp3"},
+		},
+	}
+	if _, err := store.SaveSubscription(ctx, data); err != nil {
+		t.Fatalf("SaveSubscription: %v", err)
+	}
+
+	search := func(terms ...string) string {
+		t.Helper()
+		results, err := store.SearchEpisodes(ctx, terms, repository.Page{})
+		if err != nil {
+			t.Fatalf("SearchEpisodes(%q): %v", terms, err)
+		}
+		var got []string
+		for _, r := range results {
+			got = append(got, r.Episode.ID)
+		}
+		return strings.Join(got, ",")
+	}
+	for _, tc := range []struct {
+		terms []string
+		want  string
+	}{
+		{[]string{"solar"}, "ep-1,ep-2"}, // The title match ranks first
+		{[]string{"climate change"}, "ep-2"},
+		{[]string{"climate", "change"}, "ep-2,ep-3"},
+		{[]string{"sol*"}, "ep-1,ep-2"},
+		{[]string{"cafe"}, "ep-3"},
+		{[]string{`panels" OR "weather`}, ""},
+		{[]string{"*"}, ""},
+		{[]string{"-", "solar"}, "ep-1,ep-2"},
+	} {
+		if got := search(tc.terms...); got != tc.want {
+			t.Fatalf("SearchEpisodes(%q) = %s, want %s", tc.terms, got, tc.want)
+		}
+	}
+
+	page, err := store.SearchEpisodes(ctx, []string{"solar"}, repository.Page{Offset: 1, Limit: 1})
+	if err != nil || len(page) != 1 || page[0].Episode.ID != "ep-2" {
+		t.Fatalf("SearchEpisodes second page = %+v, %v; want ep-2", page, err)
+	}
+
+	// A refresh that changes the description updates the index.
+	data.Episodes[0].Description = "Wind turbines instead."
+	if _, err := store.SaveSubscription(ctx, data); err != nil {
+		t.Fatalf("SaveSubscription again: %v", err)
+	}
+	if got := search("panels"); got != "" {
+		t.Fatalf("expected the old description to be gone, got %s", got)
+	}
+	if got := search("turbines"); got != "ep-1" {
+		t.Fatalf("expected the new description to be found, got %s", got)
+	}
+
+	if _, err := store.DeleteSubscription(ctx, "science"); err != nil {
+		t.Fatalf("DeleteSubscription: %v", err)
+	}
+	if got := search("solar"); got != "" {
+		t.Fatalf("expected no results after unsubscribing, got %s", got)
+	}
+}
+
+func TestSaveSubscriptionKeepsEpisodeMetadata(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newTestStore(t)
+
+	podcast := domain.Podcast{ID: "pod-meta", Title: "Metadata", FeedURL: "http://example.com/meta.xml"}
+	episode := domain.EpisodeInput{
+		ID: "ep-1", Title: "One", Enclosure: "http://example.com/1.mp3",
+		Season: 2, Number: 14, ImageURL: "http://example.com/1.jpg", ChaptersURL: "http://example.com/1.json",
+		Soundbites: []domain.Soundbite{{StartSec: 73.5, DurationSec: 60, Title: "Best bit"}, {StartSec: 600, DurationSec: 30}},
+	}
+	if _, err := store.SaveSubscription(ctx, domain.SubscriptionData{Podcast: podcast, Episodes: []domain.EpisodeInput{episode}}); err != nil {
+		t.Fatalf("SaveSubscription: %v", err)
+	}
+	info, err := store.GetEpisodeInfo(ctx, "ep-1")
+	if err != nil {
+		t.Fatalf("GetEpisodeInfo: %v", err)
+	}
+	if info.Season != 2 || info.Number != 14 || info.ImageURL != episode.ImageURL || info.ChaptersURL != episode.ChaptersURL {
+		t.Fatalf("unexpected episode metadata: %+v", info)
+	}
+	if fmt.Sprint(info.Soundbites) != fmt.Sprint(episode.Soundbites) {
+		t.Fatalf("unexpected soundbites: %+v", info.Soundbites)
+	}
+
+	// A refresh reflects what the feed says now.
+	episode.Number, episode.ChaptersURL = 15, ""
+	episode.Soundbites = episode.Soundbites[1:]
+	if _, err := store.SaveSubscription(ctx, domain.SubscriptionData{Podcast: podcast, Episodes: []domain.EpisodeInput{episode}}); err != nil {
+		t.Fatalf("SaveSubscription: %v", err)
+	}
+	if info, err = store.GetEpisodeInfo(ctx, "ep-1"); err != nil || info.Number != 15 || info.ChaptersURL != "" || len(info.Soundbites) != 1 {
+		t.Fatalf("metadata after refresh: %+v, %v", info, err)
+	}
+}
+
+func TestSaveSubscriptionMatchesPermalinkEpisodes(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newTestStore(t)
+
+	podcast := domain.Podcast{ID: "pod-1", Title: "Permalinks", FeedURL: "http://example.com/feed.xml"}
+	// Saved by an earlier version, keyed by the permalink GUID.
+	legacy := domain.SubscriptionData{Podcast: podcast, Episodes: []domain.EpisodeInput{
+		{ID: "https://example.com/posts/1", Title: "One", Enclosure: "http://cdn.example.com/1.mp3"},
+		{ID: "https://example.com/posts/2", Title: "Two", Enclosure: "http://cdn.example.com/2.mp3"},
+	}}
+	if _, err := store.SaveSubscription(ctx, legacy); err != nil {
+		t.Fatalf("SaveSubscription: %v", err)
+	}
+	if err := store.MarkPlayed(ctx, "https://example.com/posts/1"); err != nil {
+		t.Fatalf("MarkPlayed: %v", err)
+	}
+
+	// Now keyed by enclosure; the second permalink moved to a new URL scheme.
+	refreshed := domain.SubscriptionData{Podcast: podcast, Episodes: []domain.EpisodeInput{
+		{ID: "http://cdn.example.com/1.mp3", LinkID: "https://example.com/posts/1", Title: "One", Enclosure: "http://cdn.example.com/1.mp3"},
+		{ID: "http://cdn.example.com/2.mp3", LinkID: "https://example.com/2024/two", Title: "Two", Enclosure: "http://cdn.example.com/2.mp3"},
+		{ID: "http://cdn.example.com/3.mp3", LinkID: "https://example.com/2024/three", Title: "Three", Enclosure: "http://cdn.example.com/3.mp3"},
+	}}
+	added, err := store.SaveSubscription(ctx, refreshed)
+	if err != nil {
+		t.Fatalf("SaveSubscription: %v", err)
+	}
+	if added != 1 {
+		t.Fatalf("expected only the third episode to be new, got %d", added)
+	}
+
+	episodes, err := store.ListEpisodes(ctx, repository.EpisodeListing{}, repository.Page{})
+	if err != nil {
+		t.Fatalf("ListEpisodes: %v", err)
+	}
+	ids := map[string]string{}
+	for _, ep := range episodes {
+		ids[ep.Episode.Title] = ep.Episode.ID
+	}
+	if len(episodes) != 3 || ids["One"] != "https://example.com/posts/1" || ids["Two"] != "https://example.com/posts/2" || ids["Three"] != "http://cdn.example.com/3.mp3" {
+		t.Fatalf("unexpected episodes %v", ids)
+	}
+	info, err := store.GetEpisodeInfo(ctx, "https://example.com/posts/1")
+	if err != nil {
+		t.Fatalf("GetEpisodeInfo: %v", err)
+	}
+	if info.State != domain.EpisodeStatePlayed {
+		t.Fatalf("expected played state to survive, got %s", info.State)
+	}
+}
+
+func TestListDuePodcastsFollowsSchedule(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newTestStore(t)
+
+	now := time.Now().UTC()
+	for _, podcast := range []domain.Podcast{
+		{ID: "due", Title: "Due", FeedURL: "http://example.com/due.xml", NextRefresh: now.Add(-time.Minute)},
+		{ID: "later", Title: "Later", FeedURL: "http://example.com/later.xml", NextRefresh: now.Add(time.Hour)},
+		{ID: "unscheduled", Title: "Unscheduled", FeedURL: "http://example.com/new.xml"},
+	} {
+		if _, err := store.SaveSubscription(ctx, domain.SubscriptionData{Podcast: podcast}); err != nil {
+			t.Fatalf("SaveSubscription(%s): %v", podcast.ID, err)
+		}
+	}
+
+	due, err := store.ListDuePodcasts(ctx, now)
+	if err != nil {
+		t.Fatalf("ListDuePodcasts: %v", err)
+	}
+	if len(due) != 2 || due[0].ID != "unscheduled" || due[1].ID != "due" {
+		t.Fatalf("unexpected due podcasts %+v", due)
+	}
+
+	if err := store.SetNextRefresh(ctx, "due", now.Add(2*time.Hour)); err != nil {
+		t.Fatalf("SetNextRefresh: %v", err)
+	}
+	due, err = store.ListDuePodcasts(ctx, now.Add(90*time.Minute))
+	if err != nil {
+		t.Fatalf("ListDuePodcasts: %v", err)
+	}
+	if len(due) != 2 || due[0].ID != "unscheduled" || due[1].ID != "later" {
+		t.Fatalf("unexpected due podcasts after rescheduling %+v", due)
+	}
+
+	summaries, err := store.ListSubscriptionSummaries(ctx)
+	if err != nil {
+		t.Fatalf("ListSubscriptionSummaries: %v", err)
+	}
+	for _, summary := range summaries {
+		if summary.ID == "later" && !summary.NextRefresh.Equal(now.Add(time.Hour).Truncate(time.Second)) {
+			t.Fatalf("unexpected next refresh %v", summary.NextRefresh)
+		}
+	}
+}
+
+func TestListWebSubTopics(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newTestStore(t)
+
+	for _, podcast := range []domain.Podcast{
+		{ID: "self", Title: "B Self", FeedURL: "http://example.com/b.xml", WebSubHub: "https://hub.example.com/", WebSubTopic: "https://example.com/b"},
+		{ID: "noself", Title: "A No Self", FeedURL: "http://example.com/a.xml", WebSubHub: "https://hub.example.com/"},
+		{ID: "nohub", Title: "C No Hub", FeedURL: "http://example.com/c.xml"},
+	} {
+		if _, err := store.SaveSubscription(ctx, domain.SubscriptionData{Podcast: podcast}); err != nil {
+			t.Fatalf("SaveSubscription(%s): %v", podcast.ID, err)
+		}
+	}
+
+	topics, err := store.ListWebSubTopics(ctx)
+	if err != nil {
+		t.Fatalf("ListWebSubTopics: %v", err)
+	}
+	want := []domain.WebSubTopic{
+		{PodcastID: "noself", Title: "A No Self", Hub: "https://hub.example.com/", Topic: "http://example.com/a.xml"},
+		{PodcastID: "self", Title: "B Self", Hub: "https://hub.example.com/", Topic: "https://example.com/b"},
+	}
+	if len(topics) != len(want) || topics[0] != want[0] || topics[1] != want[1] {
+		t.Fatalf("unexpected topics %+v", topics)
+	}
+}
+
+func TestSubscriptionByGUIDAndExport(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newTestStore(t)
+
+	guid := "917393e3-1b1e-5cef-ace4-edaa54e1f810"
+	podcast := domain.Podcast{ID: "pod", Title: "Pod", FeedURL: "http://example.com/old.xml", GUID: guid}
+	if _, err := store.SaveSubscription(ctx, domain.SubscriptionData{Podcast: podcast}); err != nil {
+		t.Fatalf("SaveSubscription: %v", err)
+	}
+	// A refresh of a feed that drops the tag keeps the known guid.
+	podcast.GUID = ""
+	if _, err := store.SaveSubscription(ctx, domain.SubscriptionData{Podcast: podcast}); err != nil {
+		t.Fatalf("SaveSubscription: %v", err)
+	}
+
+	if exists, title, err := store.SubscriptionByGUID(ctx, guid); err != nil || !exists || title != "Pod" {
+		t.Fatalf("SubscriptionByGUID() = %v, %q, %v", exists, title, err)
+	}
+	if exists, _, err := store.SubscriptionByGUID(ctx, ""); err != nil || exists {
+		t.Fatalf("empty guid should not match: %v, %v", exists, err)
+	}
+
+	exports, err := store.ListPodcastExports(ctx)
+	if err != nil {
+		t.Fatalf("ListPodcastExports: %v", err)
+	}
+	if len(exports) != 1 || exports[0].GUID != guid {
+		t.Fatalf("unexpected exports %+v", exports)
+	}
+}
+
+func TestSaveSubscriptionReplacesFunding(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newTestStore(t)
+
+	podcast := domain.Podcast{ID: "pod", Title: "Pod", FeedURL: "http://example.com/feed.xml", Funding: []domain.Funding{
+		{URL: "https://example.com/donate", Label: "Donate"},
+		{URL: "https://example.com/patreon"},
+	}}
+	if _, err := store.SaveSubscription(ctx, domain.SubscriptionData{Podcast: podcast}); err != nil {
+		t.Fatalf("SaveSubscription: %v", err)
+	}
+	summaries, err := store.ListSubscriptionSummaries(ctx)
+	if err != nil {
+		t.Fatalf("ListSubscriptionSummaries: %v", err)
+	}
+	if len(summaries) != 1 || len(summaries[0].Funding) != 2 || summaries[0].Funding[0] != podcast.Funding[0] || summaries[0].Funding[1] != podcast.Funding[1] {
+		t.Fatalf("unexpected funding %+v", summaries)
+	}
+
+	podcast.Funding = podcast.Funding[1:]
+	if _, err := store.SaveSubscription(ctx, domain.SubscriptionData{Podcast: podcast}); err != nil {
+		t.Fatalf("SaveSubscription: %v", err)
+	}
+	summaries, err = store.ListSubscriptionSummaries(ctx)
+	if err != nil {
+		t.Fatalf("ListSubscriptionSummaries: %v", err)
+	}
+	if len(summaries[0].Funding) != 1 || summaries[0].Funding[0].URL != "https://example.com/patreon" {
+		t.Fatalf("expected funding to be replaced, got %+v", summaries[0].Funding)
+	}
+}
+
+func TestSaveSubscriptionKeepsLanguage(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newTestStore(t)
+
+	podcast := domain.Podcast{ID: "pod", Title: "Pod", FeedURL: "http://example.com/feed.xml", Language: "de-at"}
+	if _, err := store.SaveSubscription(ctx, domain.SubscriptionData{Podcast: podcast}); err != nil {
+		t.Fatalf("SaveSubscription: %v", err)
+	}
+	podcast.Language = ""
+	if _, err := store.SaveSubscription(ctx, domain.SubscriptionData{Podcast: podcast}); err != nil {
+		t.Fatalf("SaveSubscription: %v", err)
+	}
+	summaries, err := store.ListSubscriptionSummaries(ctx)
+	if err != nil {
+		t.Fatalf("ListSubscriptionSummaries: %v", err)
+	}
+	if len(summaries) != 1 || summaries[0].Language != "de-at" {
+		t.Fatalf("expected the stored language to survive a feed without one, got %+v", summaries)
+	}
+}
+
+func TestSaveSubscriptionStoresArtworkURL(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newTestStore(t)
+
+	podcast := domain.Podcast{ID: "pod", Title: "Pod", FeedURL: "http://example.com/feed.xml", ArtworkURL: "https://example.com/cover.jpg"}
+	if _, err := store.SaveSubscription(ctx, domain.SubscriptionData{Podcast: podcast}); err != nil {
+		t.Fatalf("SaveSubscription: %v", err)
+	}
+	podcast.ArtworkURL = "https://example.com/new-cover.jpg"
+	if _, err := store.SaveSubscription(ctx, domain.SubscriptionData{Podcast: podcast}); err != nil {
+		t.Fatalf("SaveSubscription: %v", err)
+	}
+	summaries, err := store.ListSubscriptionSummaries(ctx)
+	if err != nil {
+		t.Fatalf("ListSubscriptionSummaries: %v", err)
+	}
+	if len(summaries) != 1 || summaries[0].ArtworkURL != podcast.ArtworkURL {
+		t.Fatalf("expected the artwork URL to be updated, got %+v", summaries)
+	}
+}
+
+func TestSetDownloadDirSurvivesRefresh(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newTestStore(t)
+
+	if ok, err := store.SetDownloadDir(ctx, "pod", "/music"); err != nil || ok {
+		t.Fatalf("expected no podcast to update, got %v, %v", ok, err)
+	}
+	podcast := domain.Podcast{ID: "pod", Title: "Pod", FeedURL: "http://example.com/feed.xml"}
+	if _, err := store.SaveSubscription(ctx, domain.SubscriptionData{Podcast: podcast}); err != nil {
+		t.Fatalf("SaveSubscription: %v", err)
+	}
+	if ok, err := store.SetDownloadDir(ctx, "pod", "/music"); err != nil || !ok {
+		t.Fatalf("SetDownloadDir: %v, %v", ok, err)
+	}
+	if _, err := store.SaveSubscription(ctx, domain.SubscriptionData{Podcast: podcast}); err != nil {
+		t.Fatalf("SaveSubscription: %v", err)
+	}
+	summaries, err := store.ListSubscriptionSummaries(ctx)
+	if err != nil {
+		t.Fatalf("ListSubscriptionSummaries: %v", err)
+	}
+	if len(summaries) != 1 || summaries[0].DownloadDir != "/music" {
+		t.Fatalf("expected the download directory to be kept, got %+v", summaries)
+	}
+}
+
+func TestSaveSubscriptionReplacesValue(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newTestStore(t)
+
+	podcast := domain.Podcast{ID: "pod", Title: "Pod", FeedURL: "http://example.com/feed.xml", Value: []domain.ValueBlock{
+		{Type: "lightning", Method: "keysend", Suggested: "0.00000005000", Recipients: []domain.ValueRecipient{
+			{Name: "Host", Type: "node", Address: "02d5", Split: 90},
+			{Name: "App", Type: "node", Address: "03ae", Split: 1, Fee: true},
+		}},
+		{Type: "hive", Recipients: []domain.ValueRecipient{{Address: "podcaster", Split: 100}}},
+	}}
+	if _, err := store.SaveSubscription(ctx, domain.SubscriptionData{Podcast: podcast}); err != nil {
+		t.Fatalf("SaveSubscription: %v", err)
+	}
+	summaries, err := store.ListSubscriptionSummaries(ctx)
+	if err != nil {
+		t.Fatalf("ListSubscriptionSummaries: %v", err)
+	}
+	if len(summaries) != 1 || fmt.Sprint(summaries[0].Value) != fmt.Sprint(podcast.Value) {
+		t.Fatalf("unexpected value blocks %+v", summaries)
+	}
+
+	podcast.Value = podcast.Value[1:]
+	if _, err := store.SaveSubscription(ctx, domain.SubscriptionData{Podcast: podcast}); err != nil {
+		t.Fatalf("SaveSubscription: %v", err)
+	}
+	summaries, err = store.ListSubscriptionSummaries(ctx)
+	if err != nil {
+		t.Fatalf("ListSubscriptionSummaries: %v", err)
+	}
+	if fmt.Sprint(summaries[0].Value) != fmt.Sprint(podcast.Value) {
+		t.Fatalf("expected value blocks to be replaced, got %+v", summaries[0].Value)
+	}
+}
+
+func TestListEpisodesByPodcast(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newTestStore(t)
+
+	now := time.Now().UTC()
+	for _, id := range []string{"pod-a", "pod-b"} {
+		data := domain.SubscriptionData{
+			Podcast: domain.Podcast{ID: id, Title: id, FeedURL: "http://example.com/" + id + ".xml", CreatedAt: now},
+			Episodes: []domain.EpisodeInput{
+				{ID: id + "-1", Title: "One", Enclosure: "http://example.com/" + id + "-1.mp3"},
+				{ID: id + "-2", Title: "Two", Enclosure: "http://example.com/" + id + "-2.mp3"},
+			},
+		}
+		if _, err := store.SaveSubscription(ctx, data); err != nil {
+			t.Fatalf("SaveSubscription(%s): %v", id, err)
+		}
+	}
+
+	episodes, err := store.ListEpisodesByPodcast(ctx, "pod-b", repository.EpisodeListing{}, repository.Page{})
+	if err != nil {
+		t.Fatalf("ListEpisodesByPodcast: %v", err)
+	}
+	if len(episodes) != 2 || episodes[0].PodcastID != "pod-b" || episodes[1].PodcastID != "pod-b" {
+		t.Fatalf("expected the two episodes of pod-b, got %+v", episodes)
+	}
+	if page, err := store.ListEpisodesByPodcast(ctx, "pod-b", repository.EpisodeListing{}, repository.Page{Offset: 1, Limit: 5}); err != nil || len(page) != 1 || page[0].Episode.ID != episodes[1].Episode.ID {
+		t.Fatalf("expected the second episode of pod-b on the second page, got %+v, %v", page, err)
+	}
+
+	if count, err := store.MarkPodcastEpisodesSeen(ctx, "pod-b"); err != nil || count != 2 {
+		t.Fatalf("MarkPodcastEpisodesSeen = %d, %v; want 2", count, err)
+	}
+	if count, err := store.CountNewEpisodes(ctx); err != nil || count != 2 {
+		t.Fatalf("expected only pod-a's episodes to stay new, got %d, %v", count, err)
+	}
+}
+
+func TestBulkEpisodeUpdates(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newTestStore(t)
+
+	data := domain.SubscriptionData{
+		Podcast: domain.Podcast{ID: "pod-bulk", Title: "Bulk", FeedURL: "http://example.com/bulk.xml", CreatedAt: time.Now().UTC()},
+		Episodes: []domain.EpisodeInput{
+			{ID: "bulk-1", Title: "One", Enclosure: "http://example.com/bulk-1.mp3"},
+			{ID: "bulk-2", Title: "Two", Enclosure: "http://example.com/bulk-2.mp3"},
+			{ID: "bulk-3", Title: "Three", Enclosure: "http://example.com/bulk-3.mp3"},
+		},
+	}
+	if _, err := store.SaveSubscription(ctx, data); err != nil {
+		t.Fatalf("SaveSubscription: %v", err)
+	}
+
+	if count, err := store.IgnoreEpisodes(ctx, []string{"bulk-3"}); err != nil || count != 1 {
+		t.Fatalf("IgnoreEpisodes = %d, %v; want 1", count, err)
+	}
+	// The ignored episode and the unknown ID are skipped
+	if count, err := store.EnqueueEpisodes(ctx, []string{"bulk-1", "bulk-2", "bulk-3", "missing"}); err != nil || count != 2 {
+		t.Fatalf("EnqueueEpisodes = %d, %v; want 2", count, err)
+	}
+	if count, err := store.EnqueueEpisodes(ctx, []string{"bulk-1"}); err != nil || count != 0 {
+		t.Fatalf("EnqueueEpisodes of a queued episode = %d, %v; want 0", count, err)
+	}
+	queued, err := store.ListQueuedEpisodes(ctx)
+	if err != nil || len(queued) != 2 {
+		t.Fatalf("expected two queued episodes, got %+v, %v", queued, err)
+	}
+
+	if count, err := store.IgnoreEpisodes(ctx, []string{"bulk-1", "bulk-3"}); err != nil || count != 1 {
+		t.Fatalf("IgnoreEpisodes = %d, %v; want 1", count, err)
+	}
+	if queued, err := store.ListQueuedEpisodes(ctx); err != nil || len(queued) != 1 || queued[0].Episode.ID != "bulk-2" {
+		t.Fatalf("expected only bulk-2 left in the queue, got %+v, %v", queued, err)
+	}
+
+	// Only the ignored episodes are marked seen; bulk-2 stays queued
+	if count, err := store.MarkEpisodesSeen(ctx, []string{"bulk-1", "bulk-2", "bulk-3"}); err != nil || count != 2 {
+		t.Fatalf("MarkEpisodesSeen = %d, %v; want 2", count, err)
+	}
+	for id, want := range map[string]string{"bulk-1": domain.EpisodeStateSeen, "bulk-2": domain.EpisodeStateQueued, "bulk-3": domain.EpisodeStateSeen} {
+		info, err := store.GetEpisodeInfo(ctx, id)
+		if err != nil || info.State != want {
+			t.Fatalf("state of %s = %q, %v; want %s", id, info.State, err, want)
+		}
+	}
+}
+
+func TestListDownloadedEpisodesReportsSizeOnDisk(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newTestStore(t)
+
+	data := domain.SubscriptionData{
+		Podcast: domain.Podcast{ID: "size-pod", Title: "Sizes", FeedURL: "http://example.com/sizes.xml", CreatedAt: time.Now().UTC()},
+		Episodes: []domain.EpisodeInput{
+			{ID: "whole", Title: "Whole", Enclosure: "http://example.com/whole.mp3", SizeBytes: 1000},
+			{ID: "truncated", Title: "Truncated", Enclosure: "http://example.com/truncated.mp3", SizeBytes: 1000},
+		},
+	}
+	if _, err := store.SaveSubscription(ctx, data); err != nil {
+		t.Fatalf("SaveSubscription: %v", err)
+	}
+	dir := t.TempDir()
+	for id, size := range map[string]int{"whole": 1010, "truncated": 400} {
+		path := filepath.Join(dir, id+".mp3")
+		if err := os.WriteFile(path, make([]byte, size), 0o644); err != nil {
+			t.Fatalf("write file: %v", err)
+		}
+		if err := store.EnqueueEpisode(ctx, id); err != nil {
+			t.Fatalf("EnqueueEpisode(%s): %v", id, err)
+		}
+		if err := store.PersistDownloadResult(ctx, id, path, "hash-"+id); err != nil {
+			t.Fatalf("PersistDownloadResult(%s): %v", id, err)
+		}
+	}
+
+	downloaded, err := store.ListDownloadedEpisodes(ctx)
+	if err != nil {
+		t.Fatalf("ListDownloadedEpisodes: %v", err)
+	}
+	sizes := map[string]domain.EpisodeResult{}
+	for _, result := range downloaded {
+		sizes[result.Episode.ID] = result
+	}
+	if whole := sizes["whole"]; whole.DiskBytes != 1010 || whole.SizeMismatch() {
+		t.Fatalf("expected the whole file to match its reported size, got %+v", whole)
+	}
+	if truncated := sizes["truncated"]; truncated.DiskBytes != 400 || !truncated.SizeMismatch() {
+		t.Fatalf("expected the truncated file to be flagged, got %+v", truncated)
+	}
+}
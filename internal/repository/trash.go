@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"podsink/internal/domain"
+)
+
+// TrashEpisodeFile records that an episode's file was moved to the trash and
+// sets the episode to state in one transaction. An older trash entry of the
+// episode is replaced; its file is returned so it can be removed.
+func (s *Store) TrashEpisodeFile(ctx context.Context, entry domain.TrashEntry, state string) (string, error) {
+	var replaced string
+	err := s.withRetry(ctx, func() error {
+		replaced = ""
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		committed := false
+		defer func() {
+			if !committed {
+				tx.Rollback()
+			}
+		}()
+
+		err = tx.QueryRowContext(ctx, "SELECT trash_path FROM trash WHERE episode_id = ?", entry.EpisodeID).Scan(&replaced)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT OR REPLACE INTO trash (episode_id, trash_path, original_path, state, trashed_at) VALUES (?, ?, ?, ?, ?)`,
+			entry.EpisodeID, entry.TrashPath, entry.OriginalPath, entry.State, entry.TrashedAt.UTC().Format(time.RFC3339Nano)); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE episodes SET state = ? WHERE id = ?", state, entry.EpisodeID); err != nil {
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		committed = true
+		return nil
+	})
+	return replaced, err
+}
+
+// ListTrash returns the files in the trash, latest first.
+func (s *Store) ListTrash(ctx context.Context) ([]domain.TrashEntry, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT t.episode_id, COALESCE(e.title, ''), COALESCE(p.title, ''), t.trash_path, t.original_path, t.state, t.trashed_at
+FROM trash t
+LEFT JOIN episodes e ON e.id = t.episode_id
+LEFT JOIN podcasts p ON p.id = e.podcast_id
+ORDER BY t.trashed_at DESC, t.episode_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []domain.TrashEntry{}
+	for rows.Next() {
+		var entry domain.TrashEntry
+		var trashedAt string
+		if err := rows.Scan(&entry.EpisodeID, &entry.Title, &entry.PodcastTitle, &entry.TrashPath, &entry.OriginalPath, &entry.State, &trashedAt); err != nil {
+			return nil, err
+		}
+		if parsed, err := time.Parse(time.RFC3339Nano, trashedAt); err == nil {
+			entry.TrashedAt = parsed
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// RestoreTrashedFile records that a trashed file is back at its original
+// path: the episode returns to the state it had before deletion and the
+// trash entry is removed. It reports false, changing nothing, when the
+// episode was downloaded again or no longer exists.
+func (s *Store) RestoreTrashedFile(ctx context.Context, entry domain.TrashEntry) (bool, error) {
+	var restored bool
+	err := s.withRetry(ctx, func() error {
+		restored = false
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		committed := false
+		defer func() {
+			if !committed {
+				tx.Rollback()
+			}
+		}()
+
+		res, err := tx.ExecContext(ctx, "UPDATE episodes SET state = ?, file_path = ? WHERE id = ? AND state IN (?, ?)",
+			entry.State, entry.OriginalPath, entry.EpisodeID, domain.EpisodeStateDeleted, domain.EpisodeStatePlayed)
+		if err != nil {
+			return err
+		}
+		if affected, err := res.RowsAffected(); err != nil || affected == 0 {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM trash WHERE episode_id = ?", entry.EpisodeID); err != nil {
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		committed = true
+		restored = true
+		return nil
+	})
+	return restored, err
+}
+
+// RemoveTrashEntry forgets a trash entry once its file is gone.
+func (s *Store) RemoveTrashEntry(ctx context.Context, episodeID string) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM trash WHERE episode_id = ?", episodeID)
+	return err
+}
@@ -0,0 +1,216 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// schemaVersionKey is the metadata key holding the version of the last
+// migration applied.
+const schemaVersionKey = "schema_version"
+
+// migration upgrades the schema by one version. Steps check whether their
+// change is already there, because databases migrated before versions were
+// recorded may have any of them.
+type migration struct {
+	version     int
+	description string
+	apply       func(tx *sql.Tx) error
+}
+
+// column is a column added by a migration, with its type and default.
+type column struct {
+	name, definition string
+}
+
+// migrations upgrade a database to SchemaVersion, in version order. Append
+// new steps at the end and raise SchemaVersion to match; never change or
+// reorder a step that has been released.
+var migrations = []migration{
+	{1, "add episodes.size_bytes", addColumns("episodes", column{"size_bytes", "INTEGER DEFAULT 0"})},
+	{2, "let download workers claim queued episodes", addColumns("downloads", column{"claimed_at", "TIMESTAMP"})},
+	{3, "track when each feed was last fetched", addColumns("podcasts", column{"last_refreshed_at", "TIMESTAMP"})},
+	{4, "track playback position and duration", addColumns("episodes",
+		column{"position_seconds", "INTEGER DEFAULT 0"},
+		column{"duration_seconds", "INTEGER DEFAULT 0"})},
+	{5, "remember the MIME type of each enclosure", addColumns("episodes", column{"enclosure_type", "TEXT DEFAULT ''"})},
+	{6, "let queued downloads be paused", addColumns("downloads", column{"paused_at", "TIMESTAMP"})},
+	{7, "schedule feed refreshes individually", addColumns("podcasts", column{"next_refresh_at", "TIMESTAMP"})},
+	{8, "remember WebSub hubs advertised by feeds", addColumns("podcasts",
+		column{"websub_hub", "TEXT"},
+		column{"websub_topic", "TEXT"})},
+	{9, "identify podcasts by podcast:guid", addPodcastGUID},
+	{10, "keep iTunes and podcast namespace episode metadata", addColumns("episodes",
+		column{"season", "INTEGER DEFAULT 0"},
+		column{"episode_number", "INTEGER DEFAULT 0"},
+		column{"image_url", "TEXT DEFAULT ''"},
+		column{"chapters_url", "TEXT DEFAULT ''"})},
+	{11, "add podcasts.language", addColumns("podcasts", column{"language", "TEXT DEFAULT ''"})},
+	{12, "remember each podcast's cover art", addColumns("podcasts", column{"artwork_url", "TEXT DEFAULT ''"})},
+	{13, "let a podcast download outside download_root", addColumns("podcasts", column{"download_dir", "TEXT DEFAULT ''"})},
+	{14, "keep why the last download attempt failed", addColumns("episodes", column{"last_error", "TEXT DEFAULT ''"})},
+	{15, "index episode titles and descriptions for full-text search", addEpisodeSearchIndex},
+	// Refreshes keep the size of a transcoded file
+	{16, "remember when a download was transcoded", addColumns("episodes", column{"transcoded_at", "TIMESTAMP"})},
+	// For exported episode actions
+	{17, "remember when an episode was marked played", addColumns("episodes", column{"played_at", "TIMESTAMP"})},
+}
+
+// migrate applies the migrations newer than the database's schema version,
+// each in its own transaction together with the new version, so an
+// interrupted upgrade resumes at the step that failed. A database written
+// by a newer podsink keeps its version.
+func migrate(db *sql.DB) error {
+	current, recorded, err := schemaVersion(db)
+	if err != nil {
+		return fmt.Errorf("read schema version: %w", err)
+	}
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		if err := applyMigration(db, m); err != nil {
+			return fmt.Errorf("migration %d (%s): %w", m.version, m.description, err)
+		}
+		current, recorded = m.version, true
+	}
+	if !recorded {
+		if err := setSchemaVersion(db, current); err != nil {
+			return fmt.Errorf("record schema version: %w", err)
+		}
+	}
+	return nil
+}
+
+func applyMigration(db *sql.DB, m migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			tx.Rollback()
+		}
+	}()
+
+	if err := m.apply(tx); err != nil {
+		return err
+	}
+	if err := setSchemaVersion(tx, m.version); err != nil {
+		return fmt.Errorf("record schema version: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	committed = true
+	return nil
+}
+
+// schemaVersion returns the version of the last migration applied to db
+// and whether it is recorded in metadata. Databases from before it was kept
+// there only have PRAGMA user_version, which is 0 when no version was
+// recorded at all.
+func schemaVersion(db *sql.DB) (int, bool, error) {
+	var value string
+	err := db.QueryRow("SELECT value FROM metadata WHERE key = ?", schemaVersionKey).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		var version int
+		err := db.QueryRow("PRAGMA user_version").Scan(&version)
+		return version, false, err
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	version, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid %s %q", schemaVersionKey, value)
+	}
+	return version, true, nil
+}
+
+// execer is a *sql.DB or *sql.Tx.
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// setSchemaVersion records version in metadata and PRAGMA user_version.
+func setSchemaVersion(db execer, version int) error {
+	if _, err := db.Exec(`INSERT INTO metadata (key, value) VALUES (?, ?)
+ON CONFLICT(key) DO UPDATE SET value = excluded.value`, schemaVersionKey, strconv.Itoa(version)); err != nil {
+		return err
+	}
+	_, err := db.Exec(fmt.Sprintf("PRAGMA user_version = %d", version))
+	return err
+}
+
+// addColumns returns a step adding the columns table does not have yet.
+func addColumns(table string, columns ...column) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		for _, c := range columns {
+			exists, err := hasColumn(tx, table, c.name)
+			if err != nil {
+				return fmt.Errorf("check %s column: %w", c.name, err)
+			}
+			if exists {
+				continue
+			}
+			if _, err := tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, c.name, c.definition)); err != nil {
+				return fmt.Errorf("add %s column: %w", c.name, err)
+			}
+		}
+		return nil
+	}
+}
+
+func hasColumn(tx *sql.Tx, table, name string) (bool, error) {
+	var exists bool
+	err := tx.QueryRow("SELECT COUNT(*) > 0 FROM pragma_table_info(?) WHERE name = ?", table, name).Scan(&exists)
+	return exists, err
+}
+
+func addPodcastGUID(tx *sql.Tx) error {
+	if err := addColumns("podcasts", column{"podcast_guid", "TEXT"})(tx); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("CREATE INDEX IF NOT EXISTS idx_podcasts_guid ON podcasts(podcast_guid)"); err != nil {
+		return fmt.Errorf("create podcast_guid index: %w", err)
+	}
+	return nil
+}
+
+// addEpisodeSearchIndex creates the full-text index of episodes. It reads
+// from episodes by rowid, triggers keep it in sync, and it is built from
+// the existing episodes once.
+func addEpisodeSearchIndex(tx *sql.Tx) error {
+	var exists bool
+	if err := tx.QueryRow("SELECT COUNT(*) > 0 FROM sqlite_master WHERE type = 'table' AND name = 'episodes_fts'").Scan(&exists); err != nil {
+		return fmt.Errorf("check episodes_fts table: %w", err)
+	}
+	if exists {
+		return nil
+	}
+	stmts := []string{
+		`CREATE VIRTUAL TABLE episodes_fts USING fts5(title, description, content='episodes', content_rowid='rowid', tokenize='unicode61 remove_diacritics 2')`,
+		`CREATE TRIGGER episodes_fts_insert AFTER INSERT ON episodes BEGIN
+            INSERT INTO episodes_fts(rowid, title, description) VALUES (new.rowid, new.title, new.description);
+        END`,
+		`CREATE TRIGGER episodes_fts_delete AFTER DELETE ON episodes BEGIN
+            INSERT INTO episodes_fts(episodes_fts, rowid, title, description) VALUES ('delete', old.rowid, old.title, old.description);
+        END`,
+		`CREATE TRIGGER episodes_fts_update AFTER UPDATE OF title, description ON episodes
+        WHEN old.title IS NOT new.title OR old.description IS NOT new.description BEGIN
+            INSERT INTO episodes_fts(episodes_fts, rowid, title, description) VALUES ('delete', old.rowid, old.title, old.description);
+            INSERT INTO episodes_fts(rowid, title, description) VALUES (new.rowid, new.title, new.description);
+        END`,
+		`INSERT INTO episodes_fts(episodes_fts) VALUES ('rebuild')`,
+	}
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("create episode search index: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+func TestMigrationsAreNumberedInOrder(t *testing.T) {
+	for i, m := range migrations {
+		if m.version != i+1 {
+			t.Fatalf("migrations[%d] has version %d, want %d", i, m.version, i+1)
+		}
+	}
+	if last := migrations[len(migrations)-1].version; last != SchemaVersion {
+		t.Fatalf("last migration is %d, SchemaVersion is %d", last, SchemaVersion)
+	}
+}
+
+func TestOpenMigratesDatabaseWithoutVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.db")
+	old, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	// The schema of the first release, before versions were recorded
+	for _, stmt := range []string{
+		`CREATE TABLE podcasts (id TEXT PRIMARY KEY, title TEXT NOT NULL, feed_url TEXT NOT NULL, subscribed_at TIMESTAMP NOT NULL)`,
+		`CREATE TABLE episodes (id TEXT PRIMARY KEY, podcast_id TEXT NOT NULL REFERENCES podcasts(id) ON DELETE CASCADE,
+            title TEXT NOT NULL, description TEXT, state TEXT NOT NULL, published_at TIMESTAMP, downloaded_at TIMESTAMP,
+            file_path TEXT, enclosure_url TEXT NOT NULL, hash TEXT, retry_count INTEGER DEFAULT 0)`,
+		`CREATE TABLE downloads (episode_id TEXT PRIMARY KEY REFERENCES episodes(id) ON DELETE CASCADE, enqueued_at TIMESTAMP NOT NULL)`,
+		`INSERT INTO podcasts VALUES ('pod1', 'Example Podcast', 'https://example.com/feed.xml', '2024-01-01T00:00:00Z')`,
+		`INSERT INTO episodes (id, podcast_id, title, description, state, enclosure_url) VALUES ('ep1', 'pod1', 'Kept episode', 'Searchable', 'NEW', 'https://example.com/ep1.mp3')`,
+	} {
+		if _, err := old.Exec(stmt); err != nil {
+			t.Fatalf("create old schema: %v", err)
+		}
+	}
+	old.Close()
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer db.Close()
+
+	for table, columns := range map[string][]string{
+		"episodes":  {"size_bytes", "position_seconds", "duration_seconds", "last_error", "played_at"},
+		"podcasts":  {"last_refreshed_at", "podcast_guid", "download_dir"},
+		"downloads": {"claimed_at", "paused_at"},
+	} {
+		for _, name := range columns {
+			var exists bool
+			if err := db.QueryRow("SELECT COUNT(*) > 0 FROM pragma_table_info(?) WHERE name = ?", table, name).Scan(&exists); err != nil || !exists {
+				t.Fatalf("expected %s.%s after migrating, err = %v", table, name, err)
+			}
+		}
+	}
+	var matches int
+	if err := db.QueryRow("SELECT COUNT(*) FROM episodes_fts WHERE episodes_fts MATCH 'searchable'").Scan(&matches); err != nil || matches != 1 {
+		t.Fatalf("expected the search index to cover existing episodes, got %d, %v", matches, err)
+	}
+	assertSchemaVersion(t, db, SchemaVersion)
+}
+
+func TestOpenRunsOnlyPendingMigrations(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.db")
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	assertSchemaVersion(t, db, SchemaVersion)
+
+	// Pretend the last migration has not run yet; reopening must run it
+	// again and leave the others alone.
+	if _, err := db.Exec("ALTER TABLE episodes DROP COLUMN played_at"); err != nil {
+		t.Fatalf("drop column: %v", err)
+	}
+	if _, err := db.Exec("ALTER TABLE episodes DROP COLUMN transcoded_at"); err != nil {
+		t.Fatalf("drop column: %v", err)
+	}
+	if err := setSchemaVersion(db, SchemaVersion-1); err != nil {
+		t.Fatalf("setSchemaVersion() error = %v", err)
+	}
+	db.Close()
+
+	db, err = Open(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer db.Close()
+	var played, transcoded bool
+	if err := db.QueryRow(`SELECT
+    EXISTS (SELECT 1 FROM pragma_table_info('episodes') WHERE name = 'played_at'),
+    EXISTS (SELECT 1 FROM pragma_table_info('episodes') WHERE name = 'transcoded_at')`).Scan(&played, &transcoded); err != nil {
+		t.Fatalf("check columns: %v", err)
+	}
+	if !played || transcoded {
+		t.Fatalf("expected only the pending migration to run, played_at = %t, transcoded_at = %t", played, transcoded)
+	}
+	assertSchemaVersion(t, db, SchemaVersion)
+}
+
+func assertSchemaVersion(t *testing.T, db *sql.DB, want int) {
+	t.Helper()
+	version, recorded, err := schemaVersion(db)
+	if err != nil || !recorded || version != want {
+		t.Fatalf("schema_version = %d (recorded %t, err %v), want %d", version, recorded, err, want)
+	}
+	var userVersion int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&userVersion); err != nil || userVersion != want {
+		t.Fatalf("user_version = %d, %v; want %d", userVersion, err, want)
+	}
+}
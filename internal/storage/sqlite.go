@@ -3,26 +3,81 @@ package storage
 import (
 	"database/sql"
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	_ "modernc.org/sqlite"
 )
 
-// Open initialises the SQLite database and applies the base schema.
+// SchemaVersion is the version of the last migration. It is recorded as
+// schema_version in the metadata table and in PRAGMA user_version once the
+// migrations are applied, so support bundles can tell which schema a
+// database has.
+const SchemaVersion = 17
+
+// DefaultBusyTimeout is how long a connection waits for another one's
+// write lock when Tuning leaves it unset.
+const DefaultBusyTimeout = 5 * time.Second
+
+// Tuning adjusts SQLite for slow storage such as SD cards or network
+// filesystems. Zero values keep SQLite's defaults, except BusyTimeout,
+// which falls back to DefaultBusyTimeout. The pragmas are applied to every
+// connection of the pool.
+type Tuning struct {
+	BusyTimeout  time.Duration
+	CacheSizeKB  int    // Page cache per connection
+	Synchronous  string // OFF, NORMAL, FULL or EXTRA
+	MmapSizeMB   int    // Memory-mapped I/O, 0 disables it
+	MaxOpenConns int    // 0 leaves the pool unbounded
+}
+
+// dsn returns the connection string for path with the tuning's pragmas and
+// extra, which are applied in order.
+func (t Tuning) dsn(path string, extra ...string) string {
+	busyTimeout := t.BusyTimeout
+	if busyTimeout <= 0 {
+		busyTimeout = DefaultBusyTimeout
+	}
+	pragmas := append([]string{fmt.Sprintf("busy_timeout(%d)", busyTimeout.Milliseconds())}, extra...)
+	if t.CacheSizeKB > 0 {
+		// A negative cache_size is in KiB rather than pages
+		pragmas = append(pragmas, fmt.Sprintf("cache_size(-%d)", t.CacheSizeKB))
+	}
+	if synchronous := strings.ToUpper(strings.TrimSpace(t.Synchronous)); synchronous != "" {
+		pragmas = append(pragmas, "synchronous("+synchronous+")")
+	}
+	if t.MmapSizeMB > 0 {
+		pragmas = append(pragmas, fmt.Sprintf("mmap_size(%d)", int64(t.MmapSizeMB)<<20))
+	}
+	query := url.Values{"_pragma": pragmas}
+	return "file:" + (&url.URL{Path: path}).EscapedPath() + "?" + query.Encode()
+}
+
+// Open initialises the SQLite database with SQLite's default tuning and
+// applies the base schema.
 func Open(path string) (*sql.DB, error) {
+	return OpenTuned(path, Tuning{})
+}
+
+// OpenTuned is Open with tuning applied.
+func OpenTuned(path string, tuning Tuning) (*sql.DB, error) {
 	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
 		return nil, err
 	}
 
-	db, err := sql.Open("sqlite", path)
+	db, err := sql.Open("sqlite", tuning.dsn(path, "foreign_keys(1)"))
 	if err != nil {
 		return nil, fmt.Errorf("open database: %w", err)
 	}
+	db.SetMaxOpenConns(tuning.MaxOpenConns)
 
-	if err := applyPragmas(db); err != nil {
+	// WAL mode is stored in the database file, so once is enough
+	if _, err := db.Exec("PRAGMA journal_mode=WAL"); err != nil {
 		db.Close()
-		return nil, err
+		return nil, fmt.Errorf("apply pragma journal_mode: %w", err)
 	}
 	if err := applySchema(db); err != nil {
 		db.Close()
@@ -32,19 +87,28 @@ func Open(path string) (*sql.DB, error) {
 	return db, nil
 }
 
-func applyPragmas(db *sql.DB) error {
-	pragmas := []string{
-		"PRAGMA journal_mode=WAL",
-		"PRAGMA foreign_keys=ON",
-		"PRAGMA busy_timeout=5000",
-	}
+// OpenReadOnly opens an existing database without write access, for
+// browsing while another process owns it. The schema is used as found, so
+// the database must have been opened by this version before.
+func OpenReadOnly(path string) (*sql.DB, error) {
+	return OpenReadOnlyTuned(path, Tuning{})
+}
 
-	for _, pragma := range pragmas {
-		if _, err := db.Exec(pragma); err != nil {
-			return fmt.Errorf("apply pragma %s: %w", pragma, err)
-		}
+// OpenReadOnlyTuned is OpenReadOnly with tuning applied.
+func OpenReadOnlyTuned(path string, tuning Tuning) (*sql.DB, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("open database: %w", err)
 	}
-	return nil
+	db, err := sql.Open("sqlite", tuning.dsn(path, "query_only(1)")+"&mode=ro")
+	if err != nil {
+		return nil, fmt.Errorf("open database: %w", err)
+	}
+	db.SetMaxOpenConns(tuning.MaxOpenConns)
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("open database: %w", err)
+	}
+	return db, nil
 }
 
 func applySchema(db *sql.DB) error {
@@ -75,10 +139,82 @@ func applySchema(db *sql.DB) error {
             episode_id TEXT PRIMARY KEY REFERENCES episodes(id) ON DELETE CASCADE,
             enqueued_at TIMESTAMP NOT NULL,
             priority INTEGER NOT NULL DEFAULT 0
+        );`,
+		`CREATE TABLE IF NOT EXISTS podcast_funding (
+            podcast_id TEXT NOT NULL REFERENCES podcasts(id) ON DELETE CASCADE,
+            position INTEGER NOT NULL,
+            url TEXT NOT NULL,
+            label TEXT NOT NULL DEFAULT '',
+            PRIMARY KEY (podcast_id, position)
+        );`,
+		`CREATE TABLE IF NOT EXISTS podcast_value (
+            podcast_id TEXT NOT NULL REFERENCES podcasts(id) ON DELETE CASCADE,
+            position INTEGER NOT NULL,
+            value_type TEXT NOT NULL DEFAULT '',
+            method TEXT NOT NULL DEFAULT '',
+            suggested TEXT NOT NULL DEFAULT '',
+            PRIMARY KEY (podcast_id, position)
+        );`,
+		`CREATE TABLE IF NOT EXISTS podcast_value_recipients (
+            podcast_id TEXT NOT NULL REFERENCES podcasts(id) ON DELETE CASCADE,
+            block INTEGER NOT NULL,
+            position INTEGER NOT NULL,
+            name TEXT NOT NULL DEFAULT '',
+            recipient_type TEXT NOT NULL DEFAULT '',
+            address TEXT NOT NULL,
+            split INTEGER NOT NULL DEFAULT 0,
+            fee INTEGER NOT NULL DEFAULT 0,
+            PRIMARY KEY (podcast_id, block, position)
+        );`,
+		`CREATE TABLE IF NOT EXISTS episode_soundbites (
+            episode_id TEXT NOT NULL REFERENCES episodes(id) ON DELETE CASCADE,
+            position INTEGER NOT NULL,
+            start_seconds REAL NOT NULL,
+            duration_seconds REAL NOT NULL,
+            title TEXT NOT NULL DEFAULT '',
+            PRIMARY KEY (episode_id, position)
         );`,
 		`CREATE TABLE IF NOT EXISTS metadata (
             key TEXT PRIMARY KEY,
             value TEXT NOT NULL
+        );`,
+		// Outlives the podcasts it names, so there is no foreign key.
+		`CREATE TABLE IF NOT EXISTS subscription_history (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            podcast_id TEXT NOT NULL,
+            title TEXT NOT NULL,
+            feed_url TEXT NOT NULL DEFAULT '',
+            action TEXT NOT NULL,
+            source TEXT NOT NULL DEFAULT '',
+            at TEXT NOT NULL
+        );`,
+		`CREATE INDEX IF NOT EXISTS idx_subscription_history_podcast ON subscription_history(podcast_id);`,
+		`CREATE TABLE IF NOT EXISTS trash (
+            episode_id TEXT PRIMARY KEY,
+            trash_path TEXT NOT NULL,
+            original_path TEXT NOT NULL,
+            state TEXT NOT NULL,
+            trashed_at TEXT NOT NULL
+        );`,
+		`CREATE TABLE IF NOT EXISTS post_process (
+            episode_id TEXT NOT NULL REFERENCES episodes(id) ON DELETE CASCADE,
+            step TEXT NOT NULL,
+            status TEXT NOT NULL,
+            error TEXT NOT NULL DEFAULT '',
+            updated_at TEXT NOT NULL,
+            PRIMARY KEY (episode_id, step)
+        );`,
+		// Episodes pruned by max_stored_episodes, so refreshes do not add
+		// them again.
+		`CREATE TABLE IF NOT EXISTS archived_episodes (
+            id TEXT PRIMARY KEY,
+            podcast_id TEXT NOT NULL REFERENCES podcasts(id) ON DELETE CASCADE,
+            archived_at TEXT NOT NULL
+        );`,
+		`CREATE TABLE IF NOT EXISTS podcast_tags (
+            podcast_id TEXT NOT NULL REFERENCES podcasts(id) ON DELETE CASCADE,
+            tag TEXT NOT NULL,
+            PRIMARY KEY (podcast_id, tag)
         );`,
 	}
 
@@ -88,50 +224,10 @@ func applySchema(db *sql.DB) error {
 		}
 	}
 
-	// Apply migrations for existing databases
-	if err := applyMigrations(db); err != nil {
+	// Bring databases created by earlier versions up to date
+	if err := migrate(db); err != nil {
 		return err
 	}
 
 	return nil
 }
-
-func applyMigrations(db *sql.DB) error {
-	// Migration 1: Add size_bytes column if it doesn't exist
-	var columnExists bool
-	err := db.QueryRow(`
-		SELECT COUNT(*) > 0
-		FROM pragma_table_info('episodes')
-		WHERE name = 'size_bytes'
-	`).Scan(&columnExists)
-	if err != nil {
-		return fmt.Errorf("check size_bytes column: %w", err)
-	}
-
-	if !columnExists {
-		_, err := db.Exec(`ALTER TABLE episodes ADD COLUMN size_bytes INTEGER DEFAULT 0`)
-		if err != nil {
-			return fmt.Errorf("add size_bytes column: %w", err)
-		}
-	}
-
-	// Migration 2: Add claimed_at column to downloads table if it doesn't exist
-	var claimedColumnExists bool
-	err = db.QueryRow(`
-		SELECT COUNT(*) > 0
-		FROM pragma_table_info('downloads')
-		WHERE name = 'claimed_at'
-	`).Scan(&claimedColumnExists)
-	if err != nil {
-		return fmt.Errorf("check claimed_at column: %w", err)
-	}
-
-	if !claimedColumnExists {
-		_, err := db.Exec(`ALTER TABLE downloads ADD COLUMN claimed_at TIMESTAMP`)
-		if err != nil {
-			return fmt.Errorf("add claimed_at column: %w", err)
-		}
-	}
-
-	return nil
-}
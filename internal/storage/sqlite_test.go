@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestOpenTunedAppliesPragmasToEveryConnection(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.db")
+	tuning := Tuning{BusyTimeout: 1500 * time.Millisecond, CacheSizeKB: 4096, Synchronous: "normal", MmapSizeMB: 8, MaxOpenConns: 2}
+	db, err := OpenTuned(path, tuning)
+	if err != nil {
+		t.Fatalf("OpenTuned() error = %v", err)
+	}
+	defer db.Close()
+	if got := db.Stats().MaxOpenConnections; got != 2 {
+		t.Fatalf("MaxOpenConnections = %d, want 2", got)
+	}
+
+	// Hold one connection so the queries below run on the other
+	held, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Conn() error = %v", err)
+	}
+	defer held.Close()
+	for pragma, want := range map[string]int64{
+		"busy_timeout": 1500,
+		"cache_size":   -4096,
+		"synchronous":  1, // NORMAL
+		"mmap_size":    8 << 20,
+		"foreign_keys": 1,
+	} {
+		var got int64
+		if err := db.QueryRow("PRAGMA " + pragma).Scan(&got); err != nil {
+			t.Fatalf("PRAGMA %s: %v", pragma, err)
+		}
+		if got != want {
+			t.Fatalf("PRAGMA %s = %d, want %d", pragma, got, want)
+		}
+	}
+	var mode string
+	if err := db.QueryRow("PRAGMA journal_mode").Scan(&mode); err != nil || mode != "wal" {
+		t.Fatalf("journal_mode = %q, %v; want wal", mode, err)
+	}
+
+	readOnly, err := OpenReadOnlyTuned(path, Tuning{})
+	if err != nil {
+		t.Fatalf("OpenReadOnlyTuned() error = %v", err)
+	}
+	defer readOnly.Close()
+	var timeout int64
+	if err := readOnly.QueryRow("PRAGMA busy_timeout").Scan(&timeout); err != nil || timeout != DefaultBusyTimeout.Milliseconds() {
+		t.Fatalf("read-only busy_timeout = %d, %v; want the default", timeout, err)
+	}
+	if _, err := readOnly.Exec("CREATE TABLE scratch (id INTEGER)"); err == nil {
+		t.Fatal("expected a read-only database to refuse writes")
+	}
+}
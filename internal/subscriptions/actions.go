@@ -0,0 +1,87 @@
+package subscriptions
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"podsink/internal/gpodder"
+)
+
+// ErrNoActionsToExport is returned by ExportActions when no episode was
+// downloaded or played yet.
+var ErrNoActionsToExport = errors.New("no episode activity to export")
+
+// ExportActions writes a download action for every downloaded episode and a
+// play action for every played one to filePath, as the JSON array gpodder.net
+// accepts for uploads, and returns how many it wrote. Actions are recorded
+// against device, or gpodder.DefaultDevice when empty, oldest first; play
+// actions of episodes played before play times were recorded have no
+// timestamp and come last.
+func (s *Service) ExportActions(ctx context.Context, filePath, device string) (int, error) {
+	filePath = strings.TrimSpace(filePath)
+	if filePath == "" {
+		return 0, errors.New("file path cannot be empty")
+	}
+	if strings.TrimSpace(device) == "" {
+		device = gpodder.DefaultDevice
+	}
+
+	activity, err := s.store.ListEpisodeActivity(ctx)
+	if err != nil {
+		return 0, err
+	}
+	actions := make([]gpodder.EpisodeAction, 0, len(activity))
+	for _, record := range activity {
+		if !record.DownloadedAt.IsZero() {
+			actions = append(actions, gpodder.EpisodeAction{
+				Podcast:   record.FeedURL,
+				Episode:   record.EnclosureURL,
+				Device:    device,
+				Action:    gpodder.ActionDownload,
+				Timestamp: gpodder.FormatTimestamp(record.DownloadedAt),
+			})
+		}
+		if record.Played {
+			action := gpodder.EpisodeAction{
+				Podcast:  record.FeedURL,
+				Episode:  record.EnclosureURL,
+				Device:   device,
+				Action:   gpodder.ActionPlay,
+				Position: record.PositionSec,
+				Total:    record.DurationSec,
+			}
+			if action.Position <= 0 {
+				action.Position = record.DurationSec
+			}
+			if !record.PlayedAt.IsZero() {
+				action.Timestamp = gpodder.FormatTimestamp(record.PlayedAt)
+			}
+			actions = append(actions, action)
+		}
+	}
+	if len(actions) == 0 {
+		return 0, ErrNoActionsToExport
+	}
+	// The layout sorts chronologically as text
+	sort.SliceStable(actions, func(i, j int) bool {
+		a, b := actions[i].Timestamp, actions[j].Timestamp
+		if a == "" || b == "" {
+			return a != "" && b == ""
+		}
+		return a < b
+	})
+
+	data, err := json.MarshalIndent(actions, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	if err := os.WriteFile(filePath, append(data, '\n'), 0o644); err != nil {
+		return 0, fmt.Errorf("write file: %w", err)
+	}
+	return len(actions), nil
+}
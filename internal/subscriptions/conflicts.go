@@ -0,0 +1,101 @@
+package subscriptions
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"podsink/internal/domain"
+	"podsink/internal/opml"
+)
+
+// How an OPML import handles an outline for a podcast that is subscribed
+// under another title or feed URL.
+const (
+	// ConflictKeep leaves the subscription as it is.
+	ConflictKeep = "keep"
+	// ConflictImported takes the outline's title and feed URL.
+	ConflictImported = "imported"
+	// ConflictMerge keeps the title and takes the outline's feed URL.
+	ConflictMerge = "merge"
+	// ConflictAsk changes nothing and lists the conflict in the result, to
+	// be resolved with ResolveImportConflict.
+	ConflictAsk = "ask"
+)
+
+// ConflictPolicies lists the values ImportOPML accepts for onConflict.
+var ConflictPolicies = []string{ConflictKeep, ConflictImported, ConflictMerge, ConflictAsk}
+
+// importConflict compares an outline with the podcast it matched and
+// reports whether they differ in title or feed URL. Outlines without a
+// title only conflict by URL.
+func importConflict(local domain.Podcast, sub opml.Subscription) (domain.ImportConflict, bool) {
+	conflict := domain.ImportConflict{
+		PodcastID:       local.ID,
+		Title:           local.Title,
+		FeedURL:         local.FeedURL,
+		ImportedTitle:   strings.TrimSpace(sub.Title),
+		ImportedFeedURL: strings.TrimSpace(sub.FeedURL),
+		Tags:            sub.Tags,
+	}
+	titleDiffers := conflict.ImportedTitle != "" && !strings.EqualFold(conflict.ImportedTitle, strings.TrimSpace(local.Title))
+	return conflict, titleDiffers || conflict.ImportedFeedURL != local.FeedURL
+}
+
+// ResolveImportConflict applies choice, ConflictImported or ConflictMerge,
+// to a conflict an import reported; ConflictKeep changes nothing. Both add
+// the outline's groups as tags. A feed URL another subscription has is not
+// taken. It reports whether the subscription changed.
+func (s *Service) ResolveImportConflict(ctx context.Context, conflict domain.ImportConflict, choice string) (bool, error) {
+	title := conflict.Title
+	switch choice {
+	case ConflictKeep:
+		return false, nil
+	case ConflictImported:
+		if conflict.ImportedTitle != "" {
+			title = conflict.ImportedTitle
+		}
+	case ConflictMerge:
+	default:
+		return false, fmt.Errorf("unknown conflict choice %q, must be one of %s, %s or %s", choice, ConflictKeep, ConflictImported, ConflictMerge)
+	}
+
+	feedURL := conflict.FeedURL
+	if conflict.ImportedFeedURL != "" && conflict.ImportedFeedURL != conflict.FeedURL {
+		other, taken, err := s.store.FindSubscription(ctx, "", conflict.ImportedFeedURL)
+		if err != nil {
+			return false, err
+		}
+		if !taken || other.ID == conflict.PodcastID {
+			feedURL = conflict.ImportedFeedURL
+		}
+	}
+	changed := false
+	if title != conflict.Title || feedURL != conflict.FeedURL {
+		ok, err := s.store.SetTitleAndFeedURL(ctx, conflict.PodcastID, title, feedURL)
+		if err != nil {
+			return false, err
+		}
+		if !ok {
+			return false, nil
+		}
+		changed = true
+	}
+
+	tags, err := s.store.PodcastTags(ctx, conflict.PodcastID)
+	if err != nil {
+		return changed, err
+	}
+	for _, tag := range conflict.Tags {
+		podcastID, tag, err := tagArgs(conflict.PodcastID, tag)
+		if err != nil || slices.ContainsFunc(tags, func(t string) bool { return strings.EqualFold(t, tag) }) {
+			continue
+		}
+		if _, err := s.store.AddTag(ctx, podcastID, tag); err != nil {
+			return changed, err
+		}
+		changed = true
+	}
+	return changed, nil
+}
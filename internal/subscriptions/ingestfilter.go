@@ -0,0 +1,213 @@
+package subscriptions
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"podsink/internal/config"
+	"podsink/internal/domain"
+	"podsink/internal/hooks"
+)
+
+// FilterInput is written as JSON to an ingest filter's standard input.
+type FilterInput struct {
+	Podcast  FilterPodcast   `json:"podcast"`
+	Episodes []FilterEpisode `json:"episodes"`
+}
+
+// FilterPodcast describes the podcast whose feed added the episodes.
+type FilterPodcast struct {
+	ID      string   `json:"id"`
+	Title   string   `json:"title"`
+	FeedURL string   `json:"feed_url"`
+	Tags    []string `json:"tags"`
+}
+
+// FilterEpisode describes a new episode to an ingest filter. State is
+// "new" unless a filter before changed it.
+type FilterEpisode struct {
+	ID           string     `json:"id"`
+	Title        string     `json:"title"`
+	Description  string     `json:"description,omitempty"`
+	PublishedAt  *time.Time `json:"published_at,omitempty"`
+	EnclosureURL string     `json:"enclosure_url"`
+	DurationSec  int        `json:"duration_seconds,omitempty"`
+	SizeBytes    int64      `json:"size_bytes,omitempty"`
+	State        string     `json:"state"`
+}
+
+// FilterOutput is read from an ingest filter's standard output; printing
+// nothing changes nothing. Episodes may be set to "seen", "ignored" or
+// "queued"; episodes the input did not list are left alone.
+type FilterOutput struct {
+	Episodes []struct {
+		ID    string `json:"id"`
+		State string `json:"state"`
+	} `json:"episodes"`
+	AddTags    []string `json:"add_tags"`
+	RemoveTags []string `json:"remove_tags"`
+}
+
+// SetIngestFilters sets the commands run on the episodes a subscription or
+// refresh adds.
+func (s *Service) SetIngestFilters(filters []config.IngestFilter) {
+	s.ingestFilters.Store(&filters)
+}
+
+// filterAdded runs the ingest filters, one after the other, on the episodes
+// a subscription or refresh added to a podcast, all of them within the feed
+// timeout. A filter that fails or prints invalid output is logged and
+// skipped; it never fails the subscription or refresh.
+func (s *Service) filterAdded(ctx context.Context, podcast domain.Podcast, added []domain.AddedEpisode) {
+	filters := s.ingestFilters.Load()
+	if filters == nil || len(*filters) == 0 || len(added) == 0 {
+		return
+	}
+	if timeout := time.Duration(s.feedTimeout.Load()); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	tags, err := s.store.PodcastTags(ctx, podcast.ID)
+	if err != nil {
+		log.Printf("ingest filters for %s: %v", podcast.Title, err)
+		return
+	}
+	input := FilterInput{
+		Podcast:  FilterPodcast{ID: podcast.ID, Title: podcast.Title, FeedURL: podcast.FeedURL, Tags: tags},
+		Episodes: make([]FilterEpisode, 0, len(added)),
+	}
+	for _, episode := range added {
+		info, err := s.store.GetEpisodeInfo(ctx, episode.ID)
+		if err != nil {
+			log.Printf("ingest filters for %s: %v", podcast.Title, err)
+			return
+		}
+		var published *time.Time
+		if info.HasPublish {
+			published = &info.PublishedAt
+		}
+		input.Episodes = append(input.Episodes, FilterEpisode{
+			ID:           info.ID,
+			Title:        info.Title,
+			Description:  info.Description,
+			PublishedAt:  published,
+			EnclosureURL: info.EnclosureURL,
+			DurationSec:  info.DurationSec,
+			SizeBytes:    info.SizeBytes,
+			State:        strings.ToLower(info.State),
+		})
+	}
+
+	for _, filter := range *filters {
+		output, err := runFilter(ctx, filter.Command, input)
+		if err != nil {
+			if ctx.Err() != nil {
+				if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+					log.Printf("ingest filter %s: stopped for %s after the feed timeout", filter.Name, podcast.Title)
+				}
+				return
+			}
+			log.Printf("ingest filter %s: %v", filter.Name, err)
+			continue
+		}
+		if err := s.applyFilterOutput(ctx, &input, output); err != nil {
+			log.Printf("ingest filter %s: %v", filter.Name, err)
+		}
+	}
+}
+
+// applyFilterOutput applies what a filter printed and updates input to
+// match, so the next filter sees the result.
+func (s *Service) applyFilterOutput(ctx context.Context, input *FilterInput, output FilterOutput) error {
+	byState := map[string][]string{}
+	for _, change := range output.Episodes {
+		i := slices.IndexFunc(input.Episodes, func(episode FilterEpisode) bool { return episode.ID == change.ID })
+		if i < 0 {
+			continue
+		}
+		state := strings.ToLower(strings.TrimSpace(change.State))
+		switch state {
+		case "seen", "ignored", "queued":
+		default:
+			return fmt.Errorf("episode %s: unsupported state %q, must be seen, ignored or queued", change.ID, change.State)
+		}
+		byState[state] = append(byState[state], change.ID)
+		input.Episodes[i].State = state
+	}
+	if ids := byState["seen"]; len(ids) > 0 {
+		if _, err := s.store.MarkEpisodesSeen(ctx, ids); err != nil {
+			return err
+		}
+	}
+	if ids := byState["ignored"]; len(ids) > 0 {
+		if _, err := s.store.IgnoreEpisodes(ctx, ids); err != nil {
+			return err
+		}
+	}
+	if ids := byState["queued"]; len(ids) > 0 {
+		count, err := s.store.EnqueueEpisodes(ctx, ids)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			s.events.Publish(domain.Event{Kind: domain.EventEpisodeState, PodcastID: input.Podcast.ID, State: domain.EpisodeStateQueued})
+		}
+	}
+
+	for _, tag := range output.AddTags {
+		podcastID, tag, err := tagArgs(input.Podcast.ID, tag)
+		if err != nil {
+			continue
+		}
+		if _, err := s.store.AddTag(ctx, podcastID, tag); err != nil {
+			return err
+		}
+		if !slices.ContainsFunc(input.Podcast.Tags, func(t string) bool { return strings.EqualFold(t, tag) }) {
+			input.Podcast.Tags = append(input.Podcast.Tags, tag)
+		}
+	}
+	for _, tag := range output.RemoveTags {
+		podcastID, tag, err := tagArgs(input.Podcast.ID, tag)
+		if err != nil {
+			continue
+		}
+		if _, err := s.store.RemoveTag(ctx, podcastID, tag); err != nil {
+			return err
+		}
+		input.Podcast.Tags = slices.DeleteFunc(input.Podcast.Tags, func(t string) bool { return strings.EqualFold(t, tag) })
+	}
+	return nil
+}
+
+// runFilter runs command like a hook command, with input as JSON on
+// standard input, and parses its standard output.
+func runFilter(ctx context.Context, command string, input FilterInput) (FilterOutput, error) {
+	payload, err := json.Marshal(input)
+	if err != nil {
+		return FilterOutput{}, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, hooks.Timeout)
+	defer cancel()
+	env := hooks.Environment(hooks.Payload{Event: config.IngestFilterEvent, PodcastID: input.Podcast.ID, PodcastTitle: input.Podcast.Title})
+	stdout, err := hooks.Command(ctx, strings.TrimSpace(command), payload, env)
+	if err != nil {
+		return FilterOutput{}, err
+	}
+
+	var output FilterOutput
+	if len(bytes.TrimSpace(stdout)) == 0 {
+		return output, nil
+	}
+	if err := json.Unmarshal(stdout, &output); err != nil {
+		return FilterOutput{}, fmt.Errorf("parse output: %w", err)
+	}
+	return output, nil
+}
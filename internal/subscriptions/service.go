@@ -3,13 +3,19 @@ package subscriptions
 import (
 	"context"
 	"crypto/sha256"
+	"database/sql"
 	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"os"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"podsink/internal/config"
 	"podsink/internal/domain"
 	"podsink/internal/feeds"
 	"podsink/internal/itunes"
@@ -20,36 +26,128 @@ import (
 var (
 	ErrMissingPodcastID        = errors.New("podcast ID cannot be empty")
 	ErrMissingFeedURL          = errors.New("podcast feed URL missing")
+	ErrMissingTag              = errors.New("tag cannot be empty")
 	ErrAlreadySubscribed       = errors.New("already subscribed")
 	ErrNoSubscriptionsToExport = errors.New("no subscriptions to export")
 	ErrNoSubscriptionsInOPML   = errors.New("no subscriptions found in OPML file")
+	ErrNoSubscriptionsInJSON   = errors.New("no subscriptions found in settings file")
+	ErrFeedTimeout             = errors.New("feed timed out")
 )
 
+// refreshWorkers bounds how many feeds a refresh fetches at once, so one
+// slow feed does not hold up the rest.
+const refreshWorkers = 4
+
 type SubscribeResult struct {
 	Title string
 	Added int
 }
 
+// ImportResult summarises an OPML import. An outline that fails is listed
+// in Errors, in file order, and does not stop the others. Subscriptions an
+// outline changed under ConflictImported or ConflictMerge count as Updated;
+// conflicts left alone count as Skipped and, under ConflictAsk, are listed
+// in Conflicts.
 type ImportResult struct {
-	Imported int
-	Skipped  int
-	Errors   []string
+	Imported  int
+	Skipped   int
+	Updated   int
+	Errors    []domain.ImportFailure
+	Conflicts []domain.ImportConflict
 }
 
 type Service struct {
-	store      *repository.Store
-	httpClient *http.Client
-	itunes     *itunes.Client
+	store             *repository.Store
+	httpClient        *http.Client
+	directory         itunes.Directory
+	events            domain.EventPublisher
+	stripTrackers     atomic.Bool
+	maxFeedBytes      atomic.Int64
+	refreshInterval   atomic.Int64 // time.Duration
+	feedTimeout       atomic.Int64 // time.Duration
+	importConcurrency atomic.Int64
+	maxStoredEpisodes atomic.Int64
+	ingestFilters     atomic.Pointer[[]config.IngestFilter]
+}
+
+func NewService(store *repository.Store, client *http.Client, directory itunes.Directory, events domain.EventPublisher) *Service {
+	if events == nil {
+		events = domain.DiscardEvents
+	}
+	return &Service{store: store, httpClient: client, directory: directory, events: events}
+}
+
+// SetStripTrackingPrefixes controls whether tracking redirect prefixes are
+// removed from enclosure URLs when feeds are stored.
+func (s *Service) SetStripTrackingPrefixes(enabled bool) {
+	s.stripTrackers.Store(enabled)
+}
+
+// SetMaxFeedSizeMB limits how much of a feed is read before fetching fails
+// with feeds.ErrFeedTooLarge. 0 disables the limit.
+func (s *Service) SetMaxFeedSizeMB(mb int) {
+	s.maxFeedBytes.Store(int64(mb) << 20)
+}
+
+// SetRefreshIntervalMinutes sets the base interval between scheduled
+// refreshes of a feed. Feed hints such as <ttl> may lengthen it per feed.
+func (s *Service) SetRefreshIntervalMinutes(minutes int) {
+	s.refreshInterval.Store(int64(time.Duration(minutes) * time.Minute))
+}
+
+// SetFeedTimeoutSeconds limits how long a refresh or OPML import waits for
+// one feed before reporting it as failed. 0 disables the limit.
+func (s *Service) SetFeedTimeoutSeconds(seconds int) {
+	s.feedTimeout.Store(int64(time.Duration(seconds) * time.Second))
+}
+
+// SetMaxStoredEpisodes caps the episodes stored per podcast. Feeds saved
+// afterwards have their oldest episodes beyond the cap archived, unless they
+// were downloaded or started. 0 keeps all.
+func (s *Service) SetMaxStoredEpisodes(episodes int) {
+	s.maxStoredEpisodes.Store(int64(episodes))
 }
 
-func NewService(store *repository.Store, client *http.Client, itunesClient *itunes.Client) *Service {
-	return &Service{store: store, httpClient: client, itunes: itunesClient}
+// SetImportConcurrency sets how many feeds an OPML import fetches at once.
+func (s *Service) SetImportConcurrency(workers int) {
+	s.importConcurrency.Store(int64(workers))
+}
+
+// nextRefresh schedules the next refresh of a feed fetched just now.
+func (s *Service) nextRefresh(feed feeds.Podcast) time.Time {
+	return feed.Schedule.Next(time.Now().UTC(), time.Duration(s.refreshInterval.Load()))
+}
+
+func (s *Service) fetch(ctx context.Context, feedURL string) (feeds.Podcast, []feeds.Episode, error) {
+	return feeds.Fetch(ctx, s.httpClient, feedURL, s.maxFeedBytes.Load())
+}
+
+// fetchSubscribed fetches a feed for a refresh or an OPML import, giving up
+// once the feed timeout has passed.
+func (s *Service) fetchSubscribed(ctx context.Context, feedURL string) (feeds.Podcast, []feeds.Episode, error) {
+	timeout := time.Duration(s.feedTimeout.Load())
+	if timeout <= 0 {
+		return s.fetch(ctx, feedURL)
+	}
+	fetchCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	feedInfo, episodes, err := s.fetch(fetchCtx, feedURL)
+	if err != nil && ctx.Err() == nil && errors.Is(fetchCtx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("%w after %s", ErrFeedTimeout, timeout)
+	}
+	return feedInfo, episodes, err
 }
 
 func (s *Service) Summaries(ctx context.Context) ([]domain.SubscriptionSummary, error) {
 	return s.store.ListSubscriptionSummaries(ctx)
 }
 
+// WebSubTopics lists the subscriptions that can be followed through a
+// WebSub hub.
+func (s *Service) WebSubTopics(ctx context.Context) ([]domain.WebSubTopic, error) {
+	return s.store.ListWebSubTopics(ctx)
+}
+
 func (s *Service) IsSubscribed(ctx context.Context, podcastID string) (bool, string, error) {
 	return s.store.SubscriptionExists(ctx, podcastID)
 }
@@ -73,10 +171,10 @@ func (s *Service) Subscribe(ctx context.Context, podcast itunes.Podcast) (Subscr
 
 	meta := podcast
 	if strings.TrimSpace(meta.FeedURL) == "" {
-		if s.itunes == nil {
+		if s.directory == nil {
 			return SubscribeResult{}, ErrMissingFeedURL
 		}
-		meta, err = s.itunes.LookupPodcast(ctx, podcastID)
+		meta, err = s.directory.LookupPodcast(ctx, podcastID)
 		if err != nil {
 			return SubscribeResult{}, err
 		}
@@ -87,43 +185,58 @@ func (s *Service) Subscribe(ctx context.Context, podcast itunes.Podcast) (Subscr
 		return SubscribeResult{}, ErrMissingFeedURL
 	}
 
-	feedInfo, episodes, err := feeds.Fetch(ctx, s.httpClient, feedURL)
+	feedInfo, episodes, err := s.fetch(ctx, feedURL)
 	if err != nil {
 		return SubscribeResult{}, err
 	}
+	// The same podcast may be subscribed under another feed URL or ID.
+	guidExists, existing, err := s.store.SubscriptionByGUID(ctx, feedInfo.GUID)
+	if err != nil {
+		return SubscribeResult{}, err
+	}
+	if guidExists {
+		return SubscribeResult{Title: existing}, ErrAlreadySubscribed
+	}
 
 	title = fallbackTitle(feedInfo.Title, fallbackTitle(meta.Title, podcastID))
+	language := feedInfo.Language
+	if language == "" {
+		language = feeds.NormalizeLanguage(meta.Language)
+	}
+	artworkURL := feedInfo.ImageURL
+	if artworkURL == "" {
+		artworkURL = strings.TrimSpace(meta.Artwork)
+	}
 
 	data := domain.SubscriptionData{
 		Podcast: domain.Podcast{
-			ID:        meta.ID,
-			Title:     title,
-			FeedURL:   feedURL,
-			CreatedAt: time.Now().UTC(),
+			ID:          meta.ID,
+			Title:       title,
+			FeedURL:     feedURL,
+			CreatedAt:   time.Now().UTC(),
+			NextRefresh: s.nextRefresh(feedInfo),
+			WebSubHub:   feedInfo.Hub,
+			WebSubTopic: feedInfo.Self,
+			GUID:        feedInfo.GUID,
+			Language:    language,
+			ArtworkURL:  artworkURL,
+			Tags:        genreTags(meta.Genre),
+			Funding:     fundingLinks(feedInfo.Funding),
+			Value:       valueBlocks(feedInfo.Value),
 		},
-		Episodes: make([]domain.EpisodeInput, 0, len(episodes)),
-	}
-
-	for _, ep := range episodes {
-		var published *time.Time
-		if !ep.PublishedAt.IsZero() {
-			t := ep.PublishedAt.UTC()
-			published = &t
-		}
-		data.Episodes = append(data.Episodes, domain.EpisodeInput{
-			ID:          strings.TrimSpace(ep.ID),
-			Title:       ep.Title,
-			Description: ep.Description,
-			PublishedAt: published,
-			Enclosure:   ep.Enclosure,
-		})
+		Episodes:     s.episodeInputs(episodes),
+		KeepEpisodes: int(s.maxStoredEpisodes.Load()),
 	}
 
-	added, err := s.store.SaveSubscription(ctx, data)
+	added, err := s.store.SaveSubscriptionEpisodes(ctx, data)
 	if err != nil {
 		return SubscribeResult{}, err
 	}
-	return SubscribeResult{Title: title, Added: added}, nil
+	s.recordHistory(ctx, data.Podcast, domain.SubscriptionAdded, domain.SourceDirectory)
+	s.filterAdded(ctx, data.Podcast, added)
+	s.events.Publish(domain.Event{Kind: domain.EventSubscribed, PodcastID: meta.ID, Title: title})
+	s.events.Publish(domain.Event{Kind: domain.EventRefreshFinished, PodcastID: meta.ID, Title: title})
+	return SubscribeResult{Title: title, Added: len(added)}, nil
 }
 
 func (s *Service) Unsubscribe(ctx context.Context, podcastID string) (bool, error) {
@@ -131,7 +244,84 @@ func (s *Service) Unsubscribe(ctx context.Context, podcastID string) (bool, erro
 	if podcastID == "" {
 		return false, ErrMissingPodcastID
 	}
-	return s.store.DeleteSubscription(ctx, podcastID)
+	// The title and feed URL are read first so the history can name the
+	// podcast once it is gone.
+	podcast, err := s.store.GetPodcast(ctx, podcastID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return false, err
+	}
+	deleted, err := s.store.DeleteSubscription(ctx, podcastID)
+	if err != nil || !deleted {
+		return deleted, err
+	}
+	s.recordHistory(ctx, podcast, domain.SubscriptionRemoved, domain.SourceUser)
+	return true, nil
+}
+
+// History lists subscription changes, latest first, optionally only those
+// of podcasts matching filter by ID, title or feed URL.
+func (s *Service) History(ctx context.Context, filter string) ([]domain.SubscriptionEvent, error) {
+	return s.store.ListSubscriptionEvents(ctx, filter)
+}
+
+// recordHistory adds a subscription change to the history. The change has
+// already happened, so a failure to record it is only logged.
+func (s *Service) recordHistory(ctx context.Context, podcast domain.Podcast, action, source string) {
+	event := domain.SubscriptionEvent{
+		PodcastID: podcast.ID,
+		Title:     podcast.Title,
+		FeedURL:   podcast.FeedURL,
+		Action:    action,
+		Source:    source,
+	}
+	if err := s.store.RecordSubscriptionEvent(ctx, event); err != nil {
+		log.Printf("record %s %s: %v", action, podcast.ID, err)
+	}
+}
+
+// SetDownloadDir makes a podcast download below dir instead of
+// download_root; an empty dir restores the default. It reports false when
+// the podcast is not subscribed.
+func (s *Service) SetDownloadDir(ctx context.Context, podcastID, dir string) (bool, error) {
+	podcastID = strings.TrimSpace(podcastID)
+	if podcastID == "" {
+		return false, ErrMissingPodcastID
+	}
+	return s.store.SetDownloadDir(ctx, podcastID, dir)
+}
+
+// Tag adds a tag to a subscribed podcast. It reports false when the podcast
+// is not subscribed.
+func (s *Service) Tag(ctx context.Context, podcastID, tag string) (bool, error) {
+	podcastID, tag, err := tagArgs(podcastID, tag)
+	if err != nil {
+		return false, err
+	}
+	return s.store.AddTag(ctx, podcastID, tag)
+}
+
+// Untag removes a tag from a podcast. It reports false when the podcast
+// does not have the tag.
+func (s *Service) Untag(ctx context.Context, podcastID, tag string) (bool, error) {
+	podcastID, tag, err := tagArgs(podcastID, tag)
+	if err != nil {
+		return false, err
+	}
+	return s.store.RemoveTag(ctx, podcastID, tag)
+}
+
+// tagArgs trims a podcast ID and tag. Nested tags are written like OPML
+// groups, e.g. "News/Tech".
+func tagArgs(podcastID, tag string) (string, string, error) {
+	podcastID = strings.TrimSpace(podcastID)
+	if podcastID == "" {
+		return "", "", ErrMissingPodcastID
+	}
+	tag = strings.Trim(strings.TrimSpace(tag), opml.TagSeparator)
+	if tag == "" {
+		return "", "", ErrMissingTag
+	}
+	return podcastID, tag, nil
 }
 
 func (s *Service) ExportOPML(ctx context.Context, filePath string) (int, error) {
@@ -156,7 +346,7 @@ func (s *Service) ExportOPML(ctx context.Context, filePath string) (int, error)
 
 	subs := make([]opml.Subscription, len(exports))
 	for i, export := range exports {
-		subs[i] = opml.Subscription{Title: export.Title, FeedURL: export.FeedURL}
+		subs[i] = opml.Subscription{Title: export.Title, FeedURL: export.FeedURL, GUID: export.GUID, Tags: export.Tags}
 	}
 
 	if err := opml.Export(file, subs); err != nil {
@@ -166,11 +356,20 @@ func (s *Service) ExportOPML(ctx context.Context, filePath string) (int, error)
 	return len(subs), nil
 }
 
-func (s *Service) ImportOPML(ctx context.Context, filePath string) (ImportResult, error) {
+// ImportOPML subscribes to the outlines of an OPML file. onConflict, one of
+// ConflictPolicies, says what happens to outlines of podcasts subscribed
+// under another title or feed URL; empty means ConflictKeep.
+func (s *Service) ImportOPML(ctx context.Context, filePath, onConflict string) (ImportResult, error) {
 	filePath = strings.TrimSpace(filePath)
 	if filePath == "" {
 		return ImportResult{}, errors.New("file path cannot be empty")
 	}
+	if onConflict == "" {
+		onConflict = ConflictKeep
+	}
+	if err := checkConflictPolicy(onConflict); err != nil {
+		return ImportResult{}, err
+	}
 
 	file, err := os.Open(filePath)
 	if err != nil {
@@ -185,64 +384,519 @@ func (s *Service) ImportOPML(ctx context.Context, filePath string) (ImportResult
 	if len(subs) == 0 {
 		return ImportResult{}, ErrNoSubscriptionsInOPML
 	}
+	entries := make([]importEntry, len(subs))
+	for i, sub := range subs {
+		entries[i] = importEntry{Subscription: sub}
+	}
+	return s.importSubscriptions(ctx, entries, onConflict)
+}
 
-	var result ImportResult
-	for _, sub := range subs {
-		has, err := s.store.HasSubscriptionByFeedURL(ctx, sub.FeedURL)
-		if err != nil {
-			result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", sub.Title, err))
-			continue
+// checkConflictPolicy returns an error unless policy is one of
+// ConflictPolicies.
+func checkConflictPolicy(policy string) error {
+	if !slices.Contains(ConflictPolicies, policy) {
+		return fmt.Errorf("unknown conflict policy %q, must be one of %s", policy, strings.Join(ConflictPolicies, ", "))
+	}
+	return nil
+}
+
+// importEntry is a subscription to import: an OPML outline, whose groups
+// are its tags, or a podcast of a settings export, which also carries its
+// download directory.
+type importEntry struct {
+	opml.Subscription
+	DownloadDir string
+}
+
+// importSubscriptions subscribes to subs, as ImportOPML describes.
+func (s *Service) importSubscriptions(ctx context.Context, subs []importEntry, onConflict string) (ImportResult, error) {
+	// opml.Import lists a feed in several groups once, so every outline
+	// is fetched.
+	outcomes := make([]importOutcome, len(subs))
+
+	jobs := make(chan int)
+	imported := make(chan importOutcome)
+	var saving sync.Mutex
+	var wg sync.WaitGroup
+	for range min(s.importWorkers(), len(subs)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				imported <- s.importOutline(ctx, i, subs[i], onConflict, &saving)
+			}
+		}()
+	}
+	go func() {
+		defer close(jobs)
+		for i := range subs {
+			select {
+			case jobs <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(imported)
+	}()
+
+	progress := domain.Event{Kind: domain.EventImportProgress, FeedsTotal: len(subs)}
+	s.events.Publish(progress)
+	for outcome := range imported {
+		outcomes[outcome.index] = outcome
+		progress.FeedsDone++
+		switch {
+		case outcome.err != nil:
+			progress.Failed++
+		case !outcome.skipped:
+			progress.Added++
 		}
-		if has {
+		progress.Title, progress.Err = outcome.title, outcome.err
+		s.events.Publish(progress)
+	}
+
+	var result ImportResult
+	for i, outcome := range outcomes {
+		switch {
+		case !outcome.done:
+		case outcome.err != nil:
+			result.Errors = append(result.Errors, domain.ImportFailure{Title: outcome.title, FeedURL: subs[i].FeedURL, Err: outcome.err.Error()})
+		case outcome.updated:
+			result.Updated++
+		case outcome.skipped:
 			result.Skipped++
-			continue
+			if outcome.conflict != nil {
+				result.Conflicts = append(result.Conflicts, *outcome.conflict)
+			}
+		default:
+			result.Imported++
+		}
+	}
+	if result.Imported > 0 {
+		s.events.Publish(domain.Event{Kind: domain.EventRefreshFinished})
+	}
+	return result, ctx.Err()
+}
+
+// importOutcome is the outcome of importing subs[index] from an OPML file.
+type importOutcome struct {
+	index    int
+	done     bool
+	skipped  bool // Already subscribed
+	updated  bool // Already subscribed, and changed by the conflict policy
+	conflict *domain.ImportConflict
+	title    string
+	err      error
+}
+
+// importWorkers returns how many feeds an OPML import fetches at once.
+func (s *Service) importWorkers() int {
+	return max(int(s.importConcurrency.Load()), 1)
+}
+
+// importOutline subscribes to one OPML outline. Feeds are fetched
+// concurrently but saved one at a time under saving, so two outlines of the
+// same podcast cannot both be subscribed.
+func (s *Service) importOutline(ctx context.Context, index int, sub importEntry, onConflict string, saving *sync.Mutex) importOutcome {
+	outcome := importOutcome{index: index, done: true, title: fallbackTitle(sub.Title, sub.FeedURL)}
+	// podcast:guid identifies a podcast across URL changes, so it is
+	// checked before the feed URL.
+	local, has, err := s.store.FindSubscription(ctx, sub.GUID, sub.FeedURL)
+	if err != nil {
+		outcome.err = err
+		return outcome
+	}
+	if has {
+		return s.importExisting(ctx, outcome, local, sub.Subscription, onConflict)
+	}
+
+	feedInfo, episodes, err := s.fetchSubscribed(ctx, sub.FeedURL)
+	if err != nil {
+		outcome.err = err
+		return outcome
+	}
+	saving.Lock()
+	defer saving.Unlock()
+	result, err := s.saveNewSubscription(ctx, sub.FeedURL, sub.Title, domain.Podcast{Tags: sub.Tags, DownloadDir: sub.DownloadDir}, domain.SourceOPML, feedInfo, episodes)
+	switch {
+	case errors.Is(err, ErrAlreadySubscribed):
+		// The feed names a podcast subscribed under another URL.
+		local, has, err := s.store.FindSubscription(ctx, feedInfo.GUID, "")
+		if err != nil || !has {
+			outcome.skipped, outcome.err = err == nil, err
+			return outcome
 		}
+		return s.importExisting(ctx, outcome, local, sub.Subscription, onConflict)
+	case err != nil:
+		outcome.err = err
+	default:
+		outcome.title = result.Title
+	}
+	return outcome
+}
 
-		feedInfo, episodes, err := feeds.Fetch(ctx, s.httpClient, sub.FeedURL)
+// importExisting handles an outline of a subscribed podcast: it is skipped
+// unless it differs from the subscription and onConflict changes that.
+func (s *Service) importExisting(ctx context.Context, outcome importOutcome, local domain.Podcast, sub opml.Subscription, onConflict string) importOutcome {
+	outcome.skipped = true
+	conflict, differs := importConflict(local, sub)
+	if !differs {
+		return outcome
+	}
+	switch onConflict {
+	case ConflictAsk:
+		outcome.conflict = &conflict
+	case ConflictImported, ConflictMerge:
+		changed, err := s.ResolveImportConflict(ctx, conflict, onConflict)
 		if err != nil {
-			result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", sub.Title, err))
-			continue
+			outcome.skipped, outcome.err = false, err
+			return outcome
 		}
+		outcome.updated = changed
+	}
+	return outcome
+}
 
-		podcastID := fmt.Sprintf("opml-%x", sha256.Sum256([]byte(sub.FeedURL)))[:16]
-		title := fallbackTitle(feedInfo.Title, fallbackTitle(sub.Title, "Untitled Podcast"))
+// SubscribeFeed subscribes to a feed by URL, for podcasts that are not
+// listed in the iTunes directory.
+func (s *Service) SubscribeFeed(ctx context.Context, feedURL string) (SubscribeResult, error) {
+	feedURL = strings.TrimSpace(feedURL)
+	if feedURL == "" {
+		return SubscribeResult{}, ErrMissingFeedURL
+	}
+	has, err := s.store.HasSubscriptionByFeedURL(ctx, feedURL)
+	if err != nil {
+		return SubscribeResult{}, err
+	}
+	if has {
+		return SubscribeResult{Title: feedURL}, ErrAlreadySubscribed
+	}
+	result, err := s.subscribeFeed(ctx, feedURL, "", domain.SourceFeedURL)
+	if err != nil {
+		// The result names the existing podcast on ErrAlreadySubscribed.
+		return result, err
+	}
+	s.events.Publish(domain.Event{Kind: domain.EventRefreshFinished, Title: result.Title})
+	return result, nil
+}
 
-		data := domain.SubscriptionData{
-			Podcast: domain.Podcast{
-				ID:        podcastID,
-				Title:     title,
-				FeedURL:   sub.FeedURL,
-				CreatedAt: time.Now().UTC(),
-			},
-			Episodes: make([]domain.EpisodeInput, 0, len(episodes)),
-		}
+// subscribeFeed fetches a feed by URL and saves it as a new subscription,
+// using title when the feed has none. source is kept in the history.
+func (s *Service) subscribeFeed(ctx context.Context, feedURL, title, source string) (SubscribeResult, error) {
+	feedInfo, episodes, err := s.fetch(ctx, feedURL)
+	if err != nil {
+		return SubscribeResult{}, err
+	}
+	return s.saveNewSubscription(ctx, feedURL, title, domain.Podcast{}, source, feedInfo, episodes)
+}
+
+// saveNewSubscription saves a fetched feed as a new subscription with the
+// tags and download directory of settings.
+func (s *Service) saveNewSubscription(ctx context.Context, feedURL, title string, settings domain.Podcast, source string, feedInfo feeds.Podcast, episodes []feeds.Episode) (SubscribeResult, error) {
+	// The same podcast may be subscribed under another feed URL or ID.
+	guidExists, existing, err := s.store.SubscriptionByGUID(ctx, feedInfo.GUID)
+	if err != nil {
+		return SubscribeResult{}, err
+	}
+	if guidExists {
+		return SubscribeResult{Title: existing}, ErrAlreadySubscribed
+	}
+
+	podcastID := fmt.Sprintf("opml-%x", sha256.Sum256([]byte(feedURL)))[:16]
+	title = fallbackTitle(feedInfo.Title, fallbackTitle(title, "Untitled Podcast"))
+
+	data := domain.SubscriptionData{
+		Podcast: domain.Podcast{
+			ID:          podcastID,
+			Title:       title,
+			FeedURL:     feedURL,
+			CreatedAt:   time.Now().UTC(),
+			NextRefresh: s.nextRefresh(feedInfo),
+			WebSubHub:   feedInfo.Hub,
+			WebSubTopic: feedInfo.Self,
+			GUID:        feedInfo.GUID,
+			Language:    feedInfo.Language,
+			ArtworkURL:  feedInfo.ImageURL,
+			Tags:        settings.Tags,
+			DownloadDir: settings.DownloadDir,
+			Funding:     fundingLinks(feedInfo.Funding),
+			Value:       valueBlocks(feedInfo.Value),
+		},
+		Episodes:     s.episodeInputs(episodes),
+		KeepEpisodes: int(s.maxStoredEpisodes.Load()),
+	}
+
+	added, err := s.store.SaveSubscriptionEpisodes(ctx, data)
+	if err != nil {
+		return SubscribeResult{}, err
+	}
+	s.recordHistory(ctx, data.Podcast, domain.SubscriptionAdded, source)
+	s.filterAdded(ctx, data.Podcast, added)
+	s.events.Publish(domain.Event{Kind: domain.EventSubscribed, PodcastID: data.Podcast.ID, Title: title})
+	return SubscribeResult{Title: title, Added: len(added)}, nil
+}
+
+// RefreshResult summarises a refresh of subscribed feeds. A feed that
+// fails is listed in Failures and does not stop the others.
+type RefreshResult struct {
+	Refreshed int
+	Added     int
+	Podcasts  []PodcastRefresh
+	Failures  []domain.RefreshFailure
+}
+
+// PodcastRefresh reports how many episodes one feed refresh added.
+type PodcastRefresh struct {
+	ID    string
+	Title string
+	Added int
+}
+
+// Refresh fetches every subscribed feed and merges new episodes.
+func (s *Service) Refresh(ctx context.Context) (RefreshResult, error) {
+	podcasts, err := s.store.ListPodcasts(ctx)
+	if err != nil {
+		return RefreshResult{}, err
+	}
+	return s.refresh(ctx, podcasts)
+}
+
+// RefreshDue fetches the feeds whose scheduled refresh time has come. It
+// returns an empty result without publishing an event when none are due.
+func (s *Service) RefreshDue(ctx context.Context) (RefreshResult, error) {
+	podcasts, err := s.store.ListDuePodcasts(ctx, time.Now())
+	if err != nil {
+		return RefreshResult{}, err
+	}
+	if len(podcasts) == 0 {
+		return RefreshResult{}, nil
+	}
+	return s.refresh(ctx, podcasts)
+}
+
+// RefreshPodcast fetches a single subscribed feed.
+func (s *Service) RefreshPodcast(ctx context.Context, podcastID string) (RefreshResult, error) {
+	podcastID = strings.TrimSpace(podcastID)
+	if podcastID == "" {
+		return RefreshResult{}, ErrMissingPodcastID
+	}
+	podcast, err := s.store.GetPodcast(ctx, podcastID)
+	if err != nil {
+		return RefreshResult{}, err
+	}
+	return s.refresh(ctx, []domain.Podcast{podcast})
+}
 
-		for _, ep := range episodes {
-			var published *time.Time
-			if !ep.PublishedAt.IsZero() {
-				t := ep.PublishedAt.UTC()
-				published = &t
+// fetchedFeed is the outcome of fetching podcasts[index] during a refresh.
+type fetchedFeed struct {
+	index    int
+	feedInfo feeds.Podcast
+	episodes []feeds.Episode
+	err      error
+}
+
+// refresh fetches the feeds concurrently and stores them one at a time as
+// they arrive. The result lists podcasts and failures in the given order.
+func (s *Service) refresh(ctx context.Context, podcasts []domain.Podcast) (RefreshResult, error) {
+	jobs := make(chan int)
+	fetched := make(chan fetchedFeed)
+	var wg sync.WaitGroup
+	for range min(refreshWorkers, len(podcasts)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				feedInfo, episodes, err := s.fetchSubscribed(ctx, podcasts[i].FeedURL)
+				fetched <- fetchedFeed{index: i, feedInfo: feedInfo, episodes: episodes, err: err}
+			}
+		}()
+	}
+	go func() {
+		defer close(jobs)
+		for i := range podcasts {
+			select {
+			case jobs <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(fetched)
+	}()
+
+	added := make([]int, len(podcasts))
+	stored := make([]domain.Podcast, len(podcasts))
+	newEpisodes := make([][]domain.AddedEpisode, len(podcasts))
+	errs := make([]error, len(podcasts))
+	done := make([]bool, len(podcasts))
+	progress := domain.Event{Kind: domain.EventRefreshProgress, FeedsTotal: len(podcasts)}
+	s.events.Publish(progress)
+	for feed := range fetched {
+		podcast := podcasts[feed.index]
+		done[feed.index] = true
+		err := feed.err
+		if err == nil {
+			stored[feed.index], newEpisodes[feed.index], err = s.saveRefresh(ctx, podcast, feed.feedInfo, feed.episodes)
+			added[feed.index] = len(newEpisodes[feed.index])
+		}
+		if err != nil {
+			errs[feed.index] = err
+			progress.Failed++
+			// Retry a failing feed after the regular interval, not on every check.
+			next := time.Now().Add(time.Duration(s.refreshInterval.Load()))
+			if err := s.store.SetNextRefresh(ctx, podcast.ID, next); err != nil {
+				log.Printf("reschedule refresh of %s: %v", podcast.Title, err)
 			}
-			data.Episodes = append(data.Episodes, domain.EpisodeInput{
-				ID:          strings.TrimSpace(ep.ID),
-				Title:       ep.Title,
-				Description: ep.Description,
-				PublishedAt: published,
-				Enclosure:   ep.Enclosure,
-			})
 		}
+		progress.FeedsDone++
+		progress.Added += added[feed.index]
+		progress.PodcastID, progress.Title, progress.Err = podcast.ID, podcast.Title, err
+		s.events.Publish(progress)
+	}
 
-		if _, err := s.store.SaveSubscription(ctx, data); err != nil {
-			result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", title, err))
+	// Ingest filters run once every feed is stored, so a slow filter holds
+	// up neither the fetches nor the other feeds. episode_added follows them.
+	for i, episodes := range newEpisodes {
+		if len(episodes) == 0 {
 			continue
 		}
+		if ctx.Err() == nil {
+			s.filterAdded(ctx, stored[i], episodes)
+		}
+		for _, episode := range episodes {
+			s.events.Publish(domain.Event{Kind: domain.EventEpisodeAdded, EpisodeID: episode.ID, PodcastID: stored[i].ID, PodcastTitle: stored[i].Title, Title: episode.Title})
+		}
+	}
 
-		result.Imported++
+	var result RefreshResult
+	for i, podcast := range podcasts {
+		switch {
+		case !done[i]:
+		case errs[i] != nil:
+			result.Failures = append(result.Failures, domain.RefreshFailure{PodcastID: podcast.ID, Title: podcast.Title, Err: errs[i].Error()})
+		default:
+			result.Refreshed++
+			result.Added += added[i]
+			result.Podcasts = append(result.Podcasts, PodcastRefresh{ID: podcast.ID, Title: podcast.Title, Added: added[i]})
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return result, err
 	}
 
+	// The finished event names the podcast when all new episodes are from one.
+	finished := domain.Event{Kind: domain.EventRefreshFinished, Added: result.Added}
+	for _, podcast := range result.Podcasts {
+		if podcast.Added > 0 && podcast.Added == result.Added {
+			finished.PodcastID, finished.Title = podcast.ID, podcast.Title
+		}
+	}
+	s.events.Publish(finished)
 	return result, nil
 }
 
+// saveRefresh merges a fetched feed into its subscription and returns the
+// podcast as stored and the episodes it added.
+func (s *Service) saveRefresh(ctx context.Context, podcast domain.Podcast, feedInfo feeds.Podcast, episodes []feeds.Episode) (domain.Podcast, []domain.AddedEpisode, error) {
+	data := domain.SubscriptionData{
+		Podcast: domain.Podcast{
+			ID:          podcast.ID,
+			Title:       fallbackTitle(feedInfo.Title, podcast.Title),
+			FeedURL:     podcast.FeedURL,
+			CreatedAt:   podcast.CreatedAt,
+			NextRefresh: s.nextRefresh(feedInfo),
+			WebSubHub:   feedInfo.Hub,
+			WebSubTopic: feedInfo.Self,
+			GUID:        feedInfo.GUID,
+			Language:    feedInfo.Language,
+			ArtworkURL:  feedInfo.ImageURL,
+			Funding:     fundingLinks(feedInfo.Funding),
+			Value:       valueBlocks(feedInfo.Value),
+		},
+		Episodes:     s.episodeInputs(episodes),
+		KeepEpisodes: int(s.maxStoredEpisodes.Load()),
+	}
+	added, err := s.store.SaveSubscriptionEpisodes(ctx, data)
+	if err != nil {
+		return domain.Podcast{}, nil, err
+	}
+	return data.Podcast, added, nil
+}
+
+func (s *Service) episodeInputs(episodes []feeds.Episode) []domain.EpisodeInput {
+	strip := s.stripTrackers.Load()
+	inputs := make([]domain.EpisodeInput, 0, len(episodes))
+	for _, ep := range episodes {
+		enclosure := ep.Enclosure
+		if strip {
+			enclosure = feeds.StripTrackingPrefixes(enclosure)
+		}
+		var published *time.Time
+		if !ep.PublishedAt.IsZero() {
+			t := ep.PublishedAt.UTC()
+			published = &t
+		}
+		inputs = append(inputs, domain.EpisodeInput{
+			ID:          strings.TrimSpace(ep.ID),
+			Title:       ep.Title,
+			Description: ep.Description,
+			PublishedAt: published,
+			Enclosure:   enclosure,
+			MimeType:    ep.MimeType,
+			SizeBytes:   ep.SizeBytes,
+			DurationSec: ep.DurationSec,
+			Season:      ep.Season,
+			Number:      ep.Number,
+			ImageURL:    ep.ImageURL,
+			ChaptersURL: ep.ChaptersURL,
+			Soundbites:  soundbites(ep.Soundbites),
+			LinkID:      ep.LinkID,
+		})
+	}
+	return inputs
+}
+
+func fundingLinks(links []feeds.Funding) []domain.Funding {
+	if len(links) == 0 {
+		return nil
+	}
+	out := make([]domain.Funding, len(links))
+	for i, link := range links {
+		out[i] = domain.Funding{URL: link.URL, Label: link.Label}
+	}
+	return out
+}
+
+func valueBlocks(blocks []feeds.ValueBlock) []domain.ValueBlock {
+	if len(blocks) == 0 {
+		return nil
+	}
+	out := make([]domain.ValueBlock, len(blocks))
+	for i, block := range blocks {
+		recipients := make([]domain.ValueRecipient, len(block.Recipients))
+		for j, r := range block.Recipients {
+			recipients[j] = domain.ValueRecipient{Name: r.Name, Type: r.Type, Address: r.Address, Split: r.Split, Fee: r.Fee}
+		}
+		out[i] = domain.ValueBlock{Type: block.Type, Method: block.Method, Suggested: block.Suggested, Recipients: recipients}
+	}
+	return out
+}
+
+func soundbites(bites []feeds.Soundbite) []domain.Soundbite {
+	if len(bites) == 0 {
+		return nil
+	}
+	out := make([]domain.Soundbite, len(bites))
+	for i, bite := range bites {
+		out[i] = domain.Soundbite{StartSec: bite.StartSec, DurationSec: bite.DurationSec, Title: bite.Title}
+	}
+	return out
+}
+
 func fallbackTitle(values ...string) string {
 	for _, value := range values {
 		trimmed := strings.TrimSpace(value)
@@ -252,3 +906,11 @@ func fallbackTitle(values ...string) string {
 	}
 	return "Untitled Podcast"
 }
+
+// genreTags returns the directory's genre of a podcast as its first tag.
+func genreTags(genre string) []string {
+	if genre = strings.TrimSpace(genre); genre != "" {
+		return []string{genre}
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package subscriptions
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"podsink/internal/opml"
+)
+
+// settingsVersion is the version of the settings file format ExportSettings
+// writes.
+const settingsVersion = 1
+
+// settingsFile is a JSON export of the subscriptions with their settings.
+type settingsFile struct {
+	Version  int               `json:"version"`
+	Podcasts []podcastSettings `json:"podcasts"`
+}
+
+// podcastSettings is one subscription of a settings file.
+type podcastSettings struct {
+	Title       string   `json:"title"`
+	FeedURL     string   `json:"feed_url"`
+	GUID        string   `json:"guid,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	DownloadDir string   `json:"download_dir,omitempty"`
+}
+
+// IsSettingsFile reports whether filePath names a JSON settings file rather
+// than OPML, by its extension.
+func IsSettingsFile(filePath string) bool {
+	return strings.EqualFold(filepath.Ext(strings.TrimSpace(filePath)), ".json")
+}
+
+// ExportSettings writes the subscriptions with their tags and download
+// directories to filePath as JSON and returns how many it wrote.
+func (s *Service) ExportSettings(ctx context.Context, filePath string) (int, error) {
+	filePath = strings.TrimSpace(filePath)
+	if filePath == "" {
+		return 0, errors.New("file path cannot be empty")
+	}
+
+	exports, err := s.store.ListPodcastExports(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if len(exports) == 0 {
+		return 0, ErrNoSubscriptionsToExport
+	}
+
+	doc := settingsFile{Version: settingsVersion, Podcasts: make([]podcastSettings, len(exports))}
+	for i, export := range exports {
+		doc.Podcasts[i] = podcastSettings{
+			Title:       export.Title,
+			FeedURL:     export.FeedURL,
+			GUID:        export.GUID,
+			Tags:        export.Tags,
+			DownloadDir: export.DownloadDir,
+		}
+	}
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	if err := os.WriteFile(filePath, append(data, '\n'), 0o644); err != nil {
+		return 0, fmt.Errorf("write file: %w", err)
+	}
+	return len(doc.Podcasts), nil
+}
+
+// ImportSettings subscribes to the podcasts of a file ExportSettings wrote,
+// as ImportOPML does for outlines. Podcasts it subscribes get the file's
+// tags and download directory; subscriptions already present are left to
+// onConflict like OPML outlines.
+func (s *Service) ImportSettings(ctx context.Context, filePath, onConflict string) (ImportResult, error) {
+	filePath = strings.TrimSpace(filePath)
+	if filePath == "" {
+		return ImportResult{}, errors.New("file path cannot be empty")
+	}
+	if onConflict == "" {
+		onConflict = ConflictKeep
+	}
+	if err := checkConflictPolicy(onConflict); err != nil {
+		return ImportResult{}, err
+	}
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return ImportResult{}, fmt.Errorf("open file: %w", err)
+	}
+	var doc settingsFile
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return ImportResult{}, fmt.Errorf("read settings file: %w", err)
+	}
+	if doc.Version > settingsVersion {
+		return ImportResult{}, fmt.Errorf("settings file version %d is newer than this podsink supports (%d)", doc.Version, settingsVersion)
+	}
+
+	subs := make([]importEntry, 0, len(doc.Podcasts))
+	for _, podcast := range doc.Podcasts {
+		feedURL := strings.TrimSpace(podcast.FeedURL)
+		if feedURL == "" {
+			continue
+		}
+		subs = append(subs, importEntry{
+			Subscription: opml.Subscription{
+				Title:   strings.TrimSpace(podcast.Title),
+				FeedURL: feedURL,
+				GUID:    opml.NormalizeGUID(podcast.GUID),
+				Tags:    podcast.Tags,
+			},
+			DownloadDir: strings.TrimSpace(podcast.DownloadDir),
+		})
+	}
+	if len(subs) == 0 {
+		return ImportResult{}, ErrNoSubscriptionsInJSON
+	}
+	return s.importSubscriptions(ctx, subs, onConflict)
+}
@@ -0,0 +1,290 @@
+package subscriptions
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"podsink/internal/domain"
+	"podsink/internal/gpodder"
+)
+
+// Metadata keys holding gpodder sync progress between runs.
+const (
+	syncFeedsKey        = "gpodder.synced_feeds"
+	syncSubsSinceKey    = "gpodder.subscriptions_since"
+	syncActionsSinceKey = "gpodder.actions_since"
+	syncPushedKey       = "gpodder.actions_pushed_at"
+	syncPositionsKey    = "gpodder.synced_positions"
+)
+
+// SyncResult summarises a sync with a gpodder server.
+type SyncResult struct {
+	Subscribed    int // remote subscriptions added locally
+	RemoteRemoved int // feeds removed on another device, kept locally
+	Pushed        int // local subscription changes uploaded
+	ActionsSent   int // download actions uploaded
+	PlaysSent     int // play actions with a new position uploaded
+	MarkedSeen    int // NEW episodes marked SEEN from remote actions
+	Errors        []string
+}
+
+// Sync exchanges subscriptions and episode actions with a gpodder server.
+// Feeds subscribed elsewhere are added here, local subscription changes,
+// downloads and play positions are uploaded, and episodes played or downloaded on other devices
+// are marked SEEN. Feeds removed elsewhere are reported but kept locally.
+func (s *Service) Sync(ctx context.Context, client *gpodder.Client) (SyncResult, error) {
+	var result SyncResult
+
+	synced, err := s.syncedFeeds(ctx)
+	if err != nil {
+		return result, err
+	}
+	since, err := s.metadataInt(ctx, syncSubsSinceKey)
+	if err != nil {
+		return result, err
+	}
+	local, err := s.localFeeds(ctx)
+	if err != nil {
+		return result, err
+	}
+
+	changes, err := client.PullSubscriptions(ctx, since)
+	if err != nil {
+		return result, err
+	}
+	for _, feedURL := range changes.Add {
+		synced[feedURL] = true
+		if local[feedURL] {
+			continue
+		}
+		if _, err := s.subscribeFeed(ctx, feedURL, "", domain.SourceSync); err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", feedURL, err))
+			continue
+		}
+		local[feedURL] = true
+		result.Subscribed++
+	}
+	for _, feedURL := range changes.Remove {
+		if local[feedURL] {
+			// Still tracked as synced so it is not uploaded again.
+			result.RemoteRemoved++
+			continue
+		}
+		delete(synced, feedURL)
+	}
+
+	var add, remove []string
+	for feedURL := range local {
+		if !synced[feedURL] {
+			add = append(add, feedURL)
+		}
+	}
+	for feedURL := range synced {
+		if !local[feedURL] {
+			remove = append(remove, feedURL)
+		}
+	}
+	sort.Strings(add)
+	sort.Strings(remove)
+
+	next := changes.Timestamp
+	if len(add) > 0 || len(remove) > 0 {
+		if next, err = client.PushSubscriptions(ctx, add, remove); err != nil {
+			return result, err
+		}
+		result.Pushed = len(add) + len(remove)
+		for _, feedURL := range add {
+			synced[feedURL] = true
+		}
+		for _, feedURL := range remove {
+			delete(synced, feedURL)
+		}
+	}
+	if err := s.saveSyncedFeeds(ctx, synced); err != nil {
+		return result, err
+	}
+	if err := s.store.SetMetadata(ctx, syncSubsSinceKey, strconv.FormatInt(next, 10)); err != nil {
+		return result, err
+	}
+
+	if err := s.syncActions(ctx, client, &result); err != nil {
+		return result, err
+	}
+
+	if result.Subscribed > 0 || result.MarkedSeen > 0 {
+		s.events.Publish(domain.Event{Kind: domain.EventRefreshFinished})
+	}
+	return result, nil
+}
+
+// syncActions pulls remote play and download actions, then uploads downloads
+// finished here since the last sync and play positions that changed since
+// they were last uploaded. The pull's timestamp is the next cursor, so
+// actions other devices upload meanwhile are not skipped; this device's own
+// actions coming back are ignored.
+func (s *Service) syncActions(ctx context.Context, client *gpodder.Client, result *SyncResult) error {
+	since, err := s.metadataInt(ctx, syncActionsSinceKey)
+	if err != nil {
+		return err
+	}
+	actions, next, err := client.PullEpisodeActions(ctx, since)
+	if err != nil {
+		return err
+	}
+	var seen []string
+	for _, action := range actions {
+		if action.Device == client.Device() {
+			continue
+		}
+		if action.Action == gpodder.ActionPlay || action.Action == gpodder.ActionDownload {
+			seen = append(seen, action.Episode)
+		}
+	}
+	if len(seen) > 0 {
+		if result.MarkedSeen, err = s.store.MarkSeenByEnclosure(ctx, seen); err != nil {
+			return err
+		}
+	}
+
+	pushedAt, err := s.store.GetMetadata(ctx, syncPushedKey)
+	if err != nil {
+		return err
+	}
+	var cutoff time.Time
+	if pushedAt != "" {
+		cutoff, _ = time.Parse(time.RFC3339Nano, pushedAt)
+	}
+	positions, err := s.syncedPositions(ctx)
+	if err != nil {
+		return err
+	}
+	activity, err := s.store.ListEpisodeActivity(ctx)
+	if err != nil {
+		return err
+	}
+	var upload []gpodder.EpisodeAction
+	latest := cutoff
+	now := time.Now()
+	for _, record := range activity {
+		if record.DownloadedAt.After(cutoff) {
+			upload = append(upload, gpodder.EpisodeAction{
+				Podcast:   record.FeedURL,
+				Episode:   record.EnclosureURL,
+				Action:    gpodder.ActionDownload,
+				Timestamp: gpodder.FormatTimestamp(record.DownloadedAt),
+			})
+			result.ActionsSent++
+			if record.DownloadedAt.After(latest) {
+				latest = record.DownloadedAt
+			}
+		}
+		position := record.PositionSec
+		if record.Played && position <= 0 {
+			position = record.DurationSec
+		}
+		if position <= 0 || positions[record.EnclosureURL] == position {
+			continue
+		}
+		played := record.PlayedAt
+		if played.IsZero() {
+			played = now
+		}
+		upload = append(upload, gpodder.EpisodeAction{
+			Podcast:   record.FeedURL,
+			Episode:   record.EnclosureURL,
+			Action:    gpodder.ActionPlay,
+			Timestamp: gpodder.FormatTimestamp(played),
+			Position:  position,
+			Total:     record.DurationSec,
+		})
+		positions[record.EnclosureURL] = position
+		result.PlaysSent++
+	}
+	if len(upload) > 0 {
+		if _, err := client.PushEpisodeActions(ctx, upload); err != nil {
+			return err
+		}
+		if err := s.store.SetMetadata(ctx, syncPushedKey, latest.Format(time.RFC3339Nano)); err != nil {
+			return err
+		}
+		if err := s.saveSyncedPositions(ctx, positions); err != nil {
+			return err
+		}
+	}
+	return s.store.SetMetadata(ctx, syncActionsSinceKey, strconv.FormatInt(next, 10))
+}
+
+// syncedPositions returns the play positions last uploaded, by enclosure URL.
+func (s *Service) syncedPositions(ctx context.Context) (map[string]int, error) {
+	value, err := s.store.GetMetadata(ctx, syncPositionsKey)
+	if err != nil {
+		return nil, err
+	}
+	positions := make(map[string]int)
+	for _, line := range strings.Split(value, "\n") {
+		enclosure, position, ok := strings.Cut(line, "\t")
+		if !ok {
+			continue
+		}
+		if n, err := strconv.Atoi(position); err == nil {
+			positions[enclosure] = n
+		}
+	}
+	return positions, nil
+}
+
+func (s *Service) saveSyncedPositions(ctx context.Context, positions map[string]int) error {
+	lines := make([]string, 0, len(positions))
+	for enclosure, position := range positions {
+		lines = append(lines, enclosure+"\t"+strconv.Itoa(position))
+	}
+	sort.Strings(lines)
+	return s.store.SetMetadata(ctx, syncPositionsKey, strings.Join(lines, "\n"))
+}
+
+func (s *Service) localFeeds(ctx context.Context) (map[string]bool, error) {
+	podcasts, err := s.store.ListPodcasts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	urls := make(map[string]bool, len(podcasts))
+	for _, podcast := range podcasts {
+		urls[podcast.FeedURL] = true
+	}
+	return urls, nil
+}
+
+func (s *Service) syncedFeeds(ctx context.Context) (map[string]bool, error) {
+	value, err := s.store.GetMetadata(ctx, syncFeedsKey)
+	if err != nil {
+		return nil, err
+	}
+	urls := make(map[string]bool)
+	for _, feedURL := range strings.Split(value, "\n") {
+		if feedURL != "" {
+			urls[feedURL] = true
+		}
+	}
+	return urls, nil
+}
+
+func (s *Service) saveSyncedFeeds(ctx context.Context, urls map[string]bool) error {
+	list := make([]string, 0, len(urls))
+	for feedURL := range urls {
+		list = append(list, feedURL)
+	}
+	sort.Strings(list)
+	return s.store.SetMetadata(ctx, syncFeedsKey, strings.Join(list, "\n"))
+}
+
+func (s *Service) metadataInt(ctx context.Context, key string) (int64, error) {
+	value, err := s.store.GetMetadata(ctx, key)
+	if err != nil || value == "" {
+		return 0, err
+	}
+	return strconv.ParseInt(value, 10, 64)
+}
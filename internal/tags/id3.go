@@ -0,0 +1,164 @@
+package tags
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"unicode/utf16"
+)
+
+// ID3v2.3 is written rather than v2.4 because more car stereos and
+// portable players read it.
+const id3Version = 3
+
+// writeMP3 writes a new ID3v2.3 tag followed by the audio of src. Frames of
+// an existing v2.3 tag that meta does not replace are kept; tags of other
+// versions are dropped.
+func writeMP3(dst io.Writer, src *os.File, meta Metadata) error {
+	kept, audioStart, err := readID3(src)
+	if err != nil {
+		return err
+	}
+
+	frames := id3Frames(meta)
+	replaced := make(map[string]bool, len(frames))
+	for _, frame := range frames {
+		replaced[frame.id] = true
+	}
+	for _, frame := range kept {
+		if !replaced[frame.id] {
+			frames = append(frames, frame)
+		}
+	}
+
+	var body bytes.Buffer
+	for _, frame := range frames {
+		body.WriteString(frame.id)
+		binary.Write(&body, binary.BigEndian, uint32(len(frame.data)))
+		body.Write([]byte{0, 0})
+		body.Write(frame.data)
+	}
+	if body.Len() >= 1<<28 {
+		return errors.New("ID3 tag too large")
+	}
+	header := []byte{'I', 'D', '3', id3Version, 0, 0}
+	header = append(header, syncsafe(body.Len())...)
+	if _, err := dst.Write(header); err != nil {
+		return err
+	}
+	if _, err := dst.Write(body.Bytes()); err != nil {
+		return err
+	}
+	if _, err := src.Seek(audioStart, io.SeekStart); err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, src)
+	return err
+}
+
+type id3Frame struct {
+	id   string
+	data []byte
+}
+
+// id3Frames builds the frames for the non-empty fields of meta.
+func id3Frames(meta Metadata) []id3Frame {
+	var frames []id3Frame
+	text := func(id, value string) {
+		if value != "" {
+			frames = append(frames, id3Frame{id, id3Text(value)})
+		}
+	}
+	text("TIT2", meta.Title)
+	text("TALB", meta.Podcast)
+	text("TPE1", meta.Podcast)
+	text("TCON", "Podcast")
+	if !meta.Published.IsZero() {
+		text("TYER", meta.Published.Format("2006"))
+		text("TDAT", meta.Published.Format("0201"))
+	}
+	if meta.Description != "" {
+		// Encoding, language, an empty short description, then the text
+		encoded := id3Text(meta.Description)
+		data := append([]byte{encoded[0]}, "eng"...)
+		if encoded[0] == 0 {
+			data = append(data, 0)
+		} else {
+			data = append(data, 0xFF, 0xFE, 0, 0)
+		}
+		data = append(data, encoded[1:]...)
+		frames = append(frames, id3Frame{"COMM", data})
+	}
+	if kind := coverType(meta.Cover); kind != "" {
+		// Latin-1 MIME type, front cover, empty description
+		data := append([]byte{0}, kind...)
+		data = append(data, 0, 3, 0)
+		frames = append(frames, id3Frame{"APIC", append(data, meta.Cover...)})
+	}
+	return frames
+}
+
+// id3Text encodes value behind its encoding byte: ISO-8859-1 when every
+// rune fits, otherwise little-endian UTF-16 with a byte order mark.
+func id3Text(value string) []byte {
+	latin := []byte{0}
+	for _, r := range value {
+		if r > 0xFF {
+			out := []byte{1, 0xFF, 0xFE}
+			for _, unit := range utf16.Encode([]rune(value)) {
+				out = append(out, byte(unit), byte(unit>>8))
+			}
+			return out
+		}
+		latin = append(latin, byte(r))
+	}
+	return latin
+}
+
+// readID3 returns the frames of an ID3v2.3 tag at the start of f and the
+// offset the audio starts at. A tag of another version, or one using
+// unsynchronisation or an extended header, is skipped without its frames.
+func readID3(f *os.File) ([]id3Frame, int64, error) {
+	header := make([]byte, 10)
+	if _, err := io.ReadFull(f, header); err != nil || !bytes.HasPrefix(header, []byte("ID3")) {
+		return nil, 0, nil
+	}
+	size := int64(unsyncsafe(header[6:10]))
+	end := 10 + size
+	if header[3] == 4 && header[5]&0x10 != 0 {
+		end += 10 // Footer
+	}
+	if header[3] != id3Version || header[5]&0xC0 != 0 {
+		return nil, end, nil
+	}
+
+	body := make([]byte, size)
+	if _, err := io.ReadFull(f, body); err != nil {
+		return nil, 0, err
+	}
+	var frames []id3Frame
+	for len(body) >= 10 && body[0] != 0 {
+		id := string(body[:4])
+		length := int(binary.BigEndian.Uint32(body[4:8]))
+		if length > len(body)-10 {
+			break
+		}
+		// Frames that are compressed or encrypted are dropped
+		if body[9]&0xC0 == 0 {
+			frames = append(frames, id3Frame{id, body[10 : 10+length]})
+		}
+		body = body[10+length:]
+	}
+	return frames, end, nil
+}
+
+// syncsafe encodes n in four bytes of seven bits each.
+func syncsafe(n int) []byte {
+	return []byte{byte(n >> 21 & 0x7F), byte(n >> 14 & 0x7F), byte(n >> 7 & 0x7F), byte(n & 0x7F)}
+}
+
+func unsyncsafe(b []byte) int {
+	return int(b[0]&0x7F)<<21 | int(b[1]&0x7F)<<14 | int(b[2]&0x7F)<<7 | int(b[3]&0x7F)
+}
@@ -0,0 +1,373 @@
+package tags
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"unicode/utf8"
+)
+
+const (
+	// maxMoovBytes bounds the movie box, which is read into memory.
+	maxMoovBytes = 64 << 20
+	// maxDescBytes is the length iTunes shows of the short description;
+	// the full text goes into ldes.
+	maxDescBytes = 255
+)
+
+// Data types of iTunes metadata items.
+const (
+	dataUTF8 = 1
+	dataJPEG = 13
+	dataPNG  = 14
+)
+
+// mp4Box is a top-level box of a file.
+type mp4Box struct {
+	typ         string
+	start, size int64
+	header      int64
+}
+
+// atom is a box held in memory. data aliases the buffer it was parsed from.
+type atom struct {
+	typ  string
+	data []byte
+}
+
+// writeMP4 copies src with iTunes metadata in moov/udta/meta/ilst. Items
+// meta does not set are kept. When the movie box grows or shrinks, chunk
+// offsets pointing behind it are moved along.
+func writeMP4(dst io.Writer, src *os.File, meta Metadata) error {
+	stat, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	boxes, err := topLevelBoxes(src, stat.Size())
+	if err != nil {
+		return err
+	}
+	moov := -1
+	for i, box := range boxes {
+		if box.typ == "moov" {
+			moov = i
+		}
+	}
+	if moov < 0 {
+		return errors.New("no movie box")
+	}
+	box := boxes[moov]
+	if box.size > maxMoovBytes {
+		return fmt.Errorf("movie box exceeds %d MB", maxMoovBytes>>20)
+	}
+	payload := make([]byte, box.size-box.header)
+	if _, err := src.ReadAt(payload, box.start+box.header); err != nil {
+		return err
+	}
+	tagged, err := tagMoov(payload, meta)
+	if err != nil {
+		return err
+	}
+	if delta := int64(len(tagged)+8) - box.size; delta != 0 {
+		if err := shiftChunkOffsets(tagged, box.start+box.size, delta); err != nil {
+			return err
+		}
+	}
+
+	for i, b := range boxes {
+		if i == moov {
+			if _, err := dst.Write(appendAtom(nil, "moov", tagged)); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, err := io.Copy(dst, io.NewSectionReader(src, b.start, b.size)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// topLevelBoxes lists the boxes of the file, which must start with ftyp.
+func topLevelBoxes(f *os.File, fileSize int64) ([]mp4Box, error) {
+	var boxes []mp4Box
+	header := make([]byte, 16)
+	for offset := int64(0); offset < fileSize; {
+		if _, err := f.ReadAt(header[:8], offset); err != nil {
+			return nil, fmt.Errorf("read box header: %w", err)
+		}
+		box := mp4Box{typ: string(header[4:8]), start: offset, size: int64(binary.BigEndian.Uint32(header)), header: 8}
+		switch box.size {
+		case 0:
+			box.size = fileSize - offset
+		case 1:
+			if _, err := f.ReadAt(header[8:16], offset+8); err != nil {
+				return nil, fmt.Errorf("read box header: %w", err)
+			}
+			box.size, box.header = int64(binary.BigEndian.Uint64(header[8:16])), 16
+		}
+		if box.size < box.header || box.size > fileSize-offset {
+			return nil, fmt.Errorf("invalid %q box size", box.typ)
+		}
+		if len(boxes) == 0 && box.typ != "ftyp" {
+			return nil, ErrUnsupported
+		}
+		boxes = append(boxes, box)
+		offset += box.size
+	}
+	return boxes, nil
+}
+
+// parseAtoms splits a box payload into its child boxes.
+func parseAtoms(b []byte) ([]atom, error) {
+	var atoms []atom
+	for len(b) > 0 {
+		if len(b) < 8 {
+			return nil, errors.New("truncated box")
+		}
+		size, header := uint64(binary.BigEndian.Uint32(b)), uint64(8)
+		switch size {
+		case 0:
+			size = uint64(len(b))
+		case 1:
+			if len(b) < 16 {
+				return nil, errors.New("truncated box")
+			}
+			size, header = binary.BigEndian.Uint64(b[8:16]), 16
+		}
+		if size < header || size > uint64(len(b)) {
+			return nil, fmt.Errorf("invalid %q box size", b[4:8])
+		}
+		atoms = append(atoms, atom{typ: string(b[4:8]), data: b[header:size]})
+		b = b[size:]
+	}
+	return atoms, nil
+}
+
+func appendAtom(out []byte, typ string, data []byte) []byte {
+	out = binary.BigEndian.AppendUint32(out, uint32(8+len(data)))
+	out = append(out, typ...)
+	return append(out, data...)
+}
+
+// tagMoov returns the movie box payload with the metadata replaced.
+func tagMoov(payload []byte, meta Metadata) ([]byte, error) {
+	children, err := parseAtoms(payload)
+	if err != nil {
+		return nil, err
+	}
+	var out []byte
+	found := false
+	for _, child := range children {
+		switch child.typ {
+		case "mvex":
+			return nil, errors.New("fragmented MP4 files are not supported")
+		case "udta":
+			data, err := tagUserData(child.data, meta)
+			if err != nil {
+				return nil, err
+			}
+			out, found = appendAtom(out, "udta", data), true
+		default:
+			out = appendAtom(out, child.typ, child.data)
+		}
+	}
+	if !found {
+		data, err := tagUserData(nil, meta)
+		if err != nil {
+			return nil, err
+		}
+		out = appendAtom(out, "udta", data)
+	}
+	return out, nil
+}
+
+func tagUserData(payload []byte, meta Metadata) ([]byte, error) {
+	children, err := parseAtoms(payload)
+	if err != nil {
+		return nil, err
+	}
+	var out []byte
+	found := false
+	for _, child := range children {
+		if child.typ != "meta" {
+			out = appendAtom(out, child.typ, child.data)
+			continue
+		}
+		data, err := tagMetaBox(child.data, meta)
+		if err != nil {
+			return nil, err
+		}
+		out, found = appendAtom(out, "meta", data), true
+	}
+	if !found {
+		data, err := tagMetaBox(nil, meta)
+		if err != nil {
+			return nil, err
+		}
+		out = appendAtom(out, "meta", data)
+	}
+	return out, nil
+}
+
+// tagMetaBox rewrites the payload of an iTunes meta box, a full box whose
+// children are a handler and the item list. A QuickTime-style meta box,
+// which has no version and flags, is replaced.
+func tagMetaBox(payload []byte, meta Metadata) ([]byte, error) {
+	var children []atom
+	if len(payload) >= 4 && binary.BigEndian.Uint32(payload) == 0 {
+		var err error
+		if children, err = parseAtoms(payload[4:]); err != nil {
+			return nil, err
+		}
+	}
+	out := []byte{0, 0, 0, 0}
+	hasHandler, hasItems := false, false
+	for _, child := range children {
+		hasHandler = hasHandler || child.typ == "hdlr"
+	}
+	if !hasHandler {
+		// Version and flags, predefined, handler type, reserved, empty name
+		handler := append(make([]byte, 8), "mdir"...)
+		handler = append(handler, "appl"...)
+		handler = append(handler, make([]byte, 9)...)
+		out = appendAtom(out, "hdlr", handler)
+	}
+	for _, child := range children {
+		if child.typ != "ilst" {
+			out = appendAtom(out, child.typ, child.data)
+			continue
+		}
+		items, err := tagItemList(child.data, meta)
+		if err != nil {
+			return nil, err
+		}
+		out, hasItems = appendAtom(out, "ilst", items), true
+	}
+	if !hasItems {
+		items, err := tagItemList(nil, meta)
+		if err != nil {
+			return nil, err
+		}
+		out = appendAtom(out, "ilst", items)
+	}
+	return out, nil
+}
+
+// tagItemList returns the existing items meta does not set, followed by
+// the items it does.
+func tagItemList(payload []byte, meta Metadata) ([]byte, error) {
+	existing, err := parseAtoms(payload)
+	if err != nil {
+		return nil, err
+	}
+	items := mp4Items(meta)
+	replaced := make(map[string]bool, len(items))
+	for _, item := range items {
+		replaced[item.typ] = true
+	}
+	var out []byte
+	for _, item := range existing {
+		if !replaced[item.typ] {
+			out = appendAtom(out, item.typ, item.data)
+		}
+	}
+	for _, item := range items {
+		out = appendAtom(out, item.typ, item.data)
+	}
+	return out, nil
+}
+
+// mp4Items builds the items for the non-empty fields of meta.
+func mp4Items(meta Metadata) []atom {
+	var items []atom
+	add := func(typ string, dataType uint32, value []byte) {
+		data := binary.BigEndian.AppendUint32(nil, dataType)
+		data = append(data, 0, 0, 0, 0) // Locale
+		items = append(items, atom{typ, appendAtom(nil, "data", append(data, value...))})
+	}
+	text := func(typ, value string) {
+		if value != "" {
+			add(typ, dataUTF8, []byte(value))
+		}
+	}
+	text("\xa9nam", meta.Title)
+	text("\xa9alb", meta.Podcast)
+	text("\xa9ART", meta.Podcast)
+	text("\xa9gen", "Podcast")
+	if !meta.Published.IsZero() {
+		text("\xa9day", meta.Published.UTC().Format("2006-01-02T15:04:05Z"))
+	}
+	text("desc", truncateUTF8(meta.Description, maxDescBytes))
+	text("ldes", meta.Description)
+	switch coverType(meta.Cover) {
+	case "image/jpeg":
+		add("covr", dataJPEG, meta.Cover)
+	case "image/png":
+		add("covr", dataPNG, meta.Cover)
+	}
+	return items
+}
+
+// truncateUTF8 shortens s to at most n bytes without splitting a rune.
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// shiftChunkOffsets adds delta to the sample chunk offsets in a movie box
+// payload that point at or behind boundary, the original end of the box.
+func shiftChunkOffsets(moov []byte, boundary, delta int64) error {
+	children, err := parseAtoms(moov)
+	if err != nil {
+		return err
+	}
+	for _, child := range children {
+		switch child.typ {
+		case "trak", "mdia", "minf", "stbl":
+			if err := shiftChunkOffsets(child.data, boundary, delta); err != nil {
+				return err
+			}
+		case "stco", "co64":
+			width := 4
+			if child.typ == "co64" {
+				width = 8
+			}
+			if len(child.data) < 8 {
+				return fmt.Errorf("truncated %s box", child.typ)
+			}
+			count := int(binary.BigEndian.Uint32(child.data[4:8]))
+			entries := child.data[8:]
+			if count > len(entries)/width {
+				return fmt.Errorf("truncated %s box", child.typ)
+			}
+			for i := 0; i < count; i++ {
+				entry := entries[i*width:]
+				if width == 4 {
+					offset := int64(binary.BigEndian.Uint32(entry))
+					if offset < boundary {
+						continue
+					}
+					if offset+delta < 0 || offset+delta > math.MaxUint32 {
+						return errors.New("chunk offset out of range after tagging")
+					}
+					binary.BigEndian.PutUint32(entry, uint32(offset+delta))
+				} else {
+					offset := int64(binary.BigEndian.Uint64(entry))
+					if offset >= boundary {
+						binary.BigEndian.PutUint64(entry, uint64(offset+delta))
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
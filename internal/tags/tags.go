@@ -0,0 +1,93 @@
+// Package tags writes episode metadata into downloaded audio files: an
+// ID3v2.3 tag for MP3 and iTunes-style atoms for M4A and other MP4 files.
+// Players and car stereos show these instead of the file name.
+package tags
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ErrUnsupported is returned for files that are neither MP3 nor MP4.
+var ErrUnsupported = errors.New("unsupported audio format")
+
+// Metadata is what gets written into a file. Empty fields are left out, so
+// values the file already carries for them are kept.
+type Metadata struct {
+	Title       string    // Episode title
+	Podcast     string    // Written as album and artist
+	Published   time.Time // Zero when the feed gives no date
+	Description string    // Plain text
+	Cover       []byte    // JPEG or PNG; other formats are skipped
+}
+
+// coverType returns the MIME type of a cover players accept, or "".
+func coverType(data []byte) string {
+	switch kind := http.DetectContentType(data); kind {
+	case "image/jpeg", "image/png":
+		return kind
+	}
+	return ""
+}
+
+// Write embeds meta into the file at path. The tagged copy is written next
+// to the file and renamed over it, so a failure leaves the file untouched.
+func Write(path string, meta Metadata) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	head := make([]byte, 12)
+	n, err := io.ReadFull(src, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return err
+	}
+	head = head[:n]
+	var write func(dst io.Writer, src *os.File, meta Metadata) error
+	switch {
+	case len(head) >= 8 && bytes.Equal(head[4:8], []byte("ftyp")):
+		write = writeMP4
+	case bytes.HasPrefix(head, []byte("ID3")) || (len(head) >= 2 && head[0] == 0xFF && head[1]&0xE0 == 0xE0):
+		write = writeMP3
+	default:
+		return ErrUnsupported
+	}
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	stat, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tags-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := write(tmp, src, meta); err != nil {
+		tmp.Close()
+		return fmt.Errorf("tag %s: %w", filepath.Base(path), err)
+	}
+	if err := tmp.Chmod(stat.Mode().Perm()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	src.Close()
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,156 @@
+package tags
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var testMeta = Metadata{
+	Title:       "Episode 1: Café",
+	Podcast:     "Test Cast",
+	Published:   time.Date(2024, 3, 9, 12, 0, 0, 0, time.UTC),
+	Description: "Notes ✓",
+	Cover:       []byte("\xff\xd8\xff\xe0\x00\x10JFIF\x00cover"),
+}
+
+func writeTemp(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("write %s: %v", name, err)
+	}
+	return path
+}
+
+func TestWriteMP3ReplacesFramesAndKeepsAudio(t *testing.T) {
+	audio := []byte{0xFF, 0xFB, 0x90, 0x00, 'a', 'u', 'd', 'i', 'o'}
+	var old bytes.Buffer
+	for _, frame := range []id3Frame{{"TIT2", []byte("\x00Old title")}, {"TPE2", []byte("\x00Band")}} {
+		old.WriteString(frame.id)
+		binary.Write(&old, binary.BigEndian, uint32(len(frame.data)))
+		old.Write([]byte{0, 0})
+		old.Write(frame.data)
+	}
+	old.Write(make([]byte, 16)) // Padding
+	file := append([]byte{'I', 'D', '3', 3, 0, 0}, syncsafe(old.Len())...)
+	file = append(append(file, old.Bytes()...), audio...)
+	path := writeTemp(t, "episode.mp3", file)
+
+	if err := Write(path, testMeta); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer f.Close()
+	frames, audioStart, err := readID3(f)
+	if err != nil {
+		t.Fatalf("readID3() error = %v", err)
+	}
+	got := make(map[string][]byte)
+	for _, frame := range frames {
+		got[frame.id] = frame.data
+	}
+	if string(got["TIT2"]) != "\x00Episode 1: Caf\xe9" {
+		t.Fatalf("unexpected TIT2 %q", got["TIT2"])
+	}
+	if string(got["TPE2"]) != "\x00Band" || string(got["TALB"]) != "\x00Test Cast" {
+		t.Fatalf("expected kept TPE2 and new TALB, got %q and %q", got["TPE2"], got["TALB"])
+	}
+	if string(got["TYER"]) != "\x002024" || string(got["TDAT"]) != "\x000903" {
+		t.Fatalf("unexpected date frames %q %q", got["TYER"], got["TDAT"])
+	}
+	if comment := got["COMM"]; !bytes.HasPrefix(comment, []byte("\x01eng\xff\xfe\x00\x00\xff\xfeN\x00")) {
+		t.Fatalf("expected a UTF-16 comment, got %q", comment)
+	}
+	if picture := got["APIC"]; !bytes.HasPrefix(picture, []byte("\x00image/jpeg\x00\x03\x00\xff\xd8")) {
+		t.Fatalf("unexpected APIC frame %q", picture)
+	}
+	rest := make([]byte, len(audio)+1)
+	n, _ := f.ReadAt(rest, audioStart)
+	if !bytes.Equal(rest[:n], audio) {
+		t.Fatalf("audio changed: %q", rest[:n])
+	}
+}
+
+func TestWriteMP4AddsItemsAndShiftsChunkOffsets(t *testing.T) {
+	box := func(typ string, children ...[]byte) []byte {
+		return appendAtom(nil, typ, bytes.Join(children, nil))
+	}
+	ftyp := box("ftyp", []byte("M4A \x00\x00\x00\x00M4A isom"))
+	// The chunk offset is patched once the moov size is known.
+	stco := box("stco", []byte{0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0})
+	moov := box("moov", box("mvhd", make([]byte, 100)), box("trak", box("mdia", box("minf", box("stbl", stco)))))
+	mdat := box("mdat", []byte("AUDIODATA"))
+	binary.BigEndian.PutUint32(moov[len(moov)-4:], uint32(len(ftyp)+len(moov)+8))
+	path := writeTemp(t, "episode.m4a", bytes.Join([][]byte{ftyp, moov, mdat}, nil))
+
+	if err := Write(path, testMeta); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	top, err := parseAtoms(data)
+	if err != nil || len(top) != 3 || top[1].typ != "moov" {
+		t.Fatalf("unexpected boxes %v, %v", top, err)
+	}
+	offsets := find(top[1].data, "trak", "mdia", "minf", "stbl", "stco")
+	offset := binary.BigEndian.Uint32(offsets[8:])
+	if got := string(data[offset : offset+9]); got != "AUDIODATA" {
+		t.Fatalf("chunk offset %d points at %q", offset, got)
+	}
+
+	items, err := parseAtoms(find(top[1].data, "udta", "meta")[4:])
+	if err != nil {
+		t.Fatalf("parse meta: %v", err)
+	}
+	var list []byte
+	for _, item := range items {
+		if item.typ == "ilst" {
+			list = item.data
+		}
+	}
+	if title := find(list, "\xa9nam", "data"); string(title[8:]) != testMeta.Title {
+		t.Fatalf("unexpected title item %q", title)
+	}
+	if day := find(list, "\xa9day", "data"); string(day[8:]) != "2024-03-09T12:00:00Z" {
+		t.Fatalf("unexpected date item %q", day)
+	}
+	if cover := find(list, "covr", "data"); binary.BigEndian.Uint32(cover) != dataJPEG {
+		t.Fatalf("unexpected cover type %d", binary.BigEndian.Uint32(cover))
+	}
+}
+
+func TestWriteRejectsUnknownFormats(t *testing.T) {
+	path := writeTemp(t, "episode.ogg", []byte("OggS\x00\x02rest"))
+	if err := Write(path, testMeta); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("expected ErrUnsupported, got %v", err)
+	}
+}
+
+// find returns the payload at the path of box types below data.
+func find(data []byte, path ...string) []byte {
+	for _, typ := range path {
+		atoms, err := parseAtoms(data)
+		if err != nil {
+			return nil
+		}
+		data = nil
+		for _, a := range atoms {
+			if a.typ == typ {
+				data = a.data
+				break
+			}
+		}
+	}
+	return data
+}
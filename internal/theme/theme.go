@@ -20,6 +20,8 @@ type Theme struct {
 	State        lipgloss.Style
 	Date         lipgloss.Style
 	Error        lipgloss.Style
+	Badge        lipgloss.Style // Counts of unread items next to menu entries
+	AlertBadge   lipgloss.Style // Counts of items that need attention
 }
 
 // Default is the canonical name of the built-in default theme.
@@ -38,6 +40,8 @@ var themes = map[string]Theme{
 		State:        lipgloss.NewStyle().Foreground(lipgloss.Color("214")),
 		Date:         lipgloss.NewStyle().Foreground(lipgloss.Color("246")),
 		Error:        lipgloss.NewStyle().Foreground(lipgloss.Color("196")),
+		Badge:        lipgloss.NewStyle().Foreground(lipgloss.Color("231")).Background(lipgloss.Color("99")),
+		AlertBadge:   lipgloss.NewStyle().Foreground(lipgloss.Color("231")).Background(lipgloss.Color("160")),
 	},
 	"high_contrast": {
 		Message:      lipgloss.NewStyle().Foreground(lipgloss.Color("51")).Bold(true),
@@ -51,6 +55,8 @@ var themes = map[string]Theme{
 		State:        lipgloss.NewStyle().Foreground(lipgloss.Color("220")).Bold(true),
 		Date:         lipgloss.NewStyle().Foreground(lipgloss.Color("33")),
 		Error:        lipgloss.NewStyle().Foreground(lipgloss.Color("196")).Bold(true),
+		Badge:        lipgloss.NewStyle().Foreground(lipgloss.Color("16")).Background(lipgloss.Color("51")).Bold(true),
+		AlertBadge:   lipgloss.NewStyle().Foreground(lipgloss.Color("231")).Background(lipgloss.Color("196")).Bold(true),
 	},
 }
 
@@ -0,0 +1,105 @@
+// Package watch notices files added to or removed from the download root
+// while podsink runs, so the library follows changes made outside of it
+// without waiting for the downloads view to be opened.
+package watch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// settleDelay collects the changes of a burst, such as a folder being
+// deleted, into one notification.
+const settleDelay = 500 * time.Millisecond
+
+// Watcher reports changes below a directory until stopped.
+type Watcher struct {
+	root    string
+	skip    []string
+	signals chan struct{}
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// Start watches root and the folders below it, except the skip folders and
+// their contents, and calls changed once files were added, removed or
+// renamed. changed is never called concurrently.
+func Start(ctx context.Context, root string, skip []string, changed func(context.Context)) (*Watcher, error) {
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, &os.PathError{Op: "watch", Path: root, Err: os.ErrInvalid}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	w := &Watcher{root: filepath.Clean(root), signals: make(chan struct{}, 1), cancel: cancel}
+	for _, dir := range skip {
+		if dir != "" {
+			w.skip = append(w.skip, filepath.Clean(dir))
+		}
+	}
+	if err := w.watch(ctx); err != nil {
+		cancel()
+		w.wg.Wait()
+		return nil, err
+	}
+	w.wg.Add(1)
+	go w.run(ctx, changed)
+	return w, nil
+}
+
+// Stop ends watching and waits for a running changed call to return.
+func (w *Watcher) Stop() {
+	if w == nil {
+		return
+	}
+	w.cancel()
+	w.wg.Wait()
+}
+
+func (w *Watcher) run(ctx context.Context, changed func(context.Context)) {
+	defer w.wg.Done()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-w.signals:
+		}
+		timer := time.NewTimer(settleDelay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		// Changes during the delay are covered by this call
+		select {
+		case <-w.signals:
+		default:
+		}
+		changed(ctx)
+	}
+}
+
+// changed records that something below the root changed.
+func (w *Watcher) changed() {
+	select {
+	case w.signals <- struct{}{}:
+	default:
+	}
+}
+
+// skipped reports whether path is a skip folder or inside one.
+func (w *Watcher) skipped(path string) bool {
+	for _, dir := range w.skip {
+		if path == dir || strings.HasPrefix(path, dir+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,121 @@
+//go:build linux
+
+package watch
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+const watchMask = syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO | syscall.IN_CLOSE_WRITE
+
+// watch follows the tree with inotify, adding a watch for every folder.
+func (w *Watcher) watch(ctx context.Context) error {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return os.NewSyscallError("inotify_init1", err)
+	}
+	// A non-blocking descriptor is read through the runtime poller, so
+	// closing the file ends a pending read.
+	file := os.NewFile(uintptr(fd), "inotify")
+	dirs := map[int32]string{}
+	if err := w.addTree(fd, dirs, w.root); err != nil {
+		file.Close()
+		return err
+	}
+	w.wg.Add(2)
+	go func() {
+		defer w.wg.Done()
+		<-ctx.Done()
+		file.Close()
+	}()
+	go func() {
+		defer w.wg.Done()
+		w.read(file, fd, dirs)
+	}()
+	return nil
+}
+
+// addTree watches dir and the folders below it.
+func (w *Watcher) addTree(fd int, dirs map[int32]string, dir string) error {
+	return filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if path != w.root && errors.Is(err, fs.ErrNotExist) {
+				// Removed again before it could be watched
+				return nil
+			}
+			return err
+		}
+		if !entry.IsDir() {
+			return nil
+		}
+		if w.skipped(path) {
+			return filepath.SkipDir
+		}
+		wd, err := syscall.InotifyAddWatch(fd, path, watchMask)
+		if err != nil {
+			return os.NewSyscallError("inotify_add_watch", err)
+		}
+		dirs[int32(wd)] = path
+		return nil
+	})
+}
+
+// read reports the events of the tree until the file is closed. Folders
+// created or moved into it are watched as they appear.
+func (w *Watcher) read(file *os.File, fd int, dirs map[int32]string) {
+	buf := make([]byte, 64<<10)
+	for {
+		n, err := file.Read(buf)
+		if err != nil {
+			if !errors.Is(err, os.ErrClosed) {
+				log.Printf("watch %s: %v", w.root, err)
+			}
+			return
+		}
+		changed := false
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			wd := int32(binary.NativeEndian.Uint32(buf[offset:]))
+			mask := binary.NativeEndian.Uint32(buf[offset+4:])
+			nameLen := int(binary.NativeEndian.Uint32(buf[offset+12:]))
+			start := offset + syscall.SizeofInotifyEvent
+			offset = start + nameLen
+			if offset > n {
+				break
+			}
+			name := strings.TrimRight(string(buf[start:offset]), "\x00")
+
+			switch {
+			case mask&syscall.IN_Q_OVERFLOW != 0:
+				changed = true
+			case mask&syscall.IN_IGNORED != 0:
+				delete(dirs, wd)
+			default:
+				dir, ok := dirs[wd]
+				if !ok {
+					continue
+				}
+				path := filepath.Join(dir, name)
+				if w.skipped(path) {
+					continue
+				}
+				if mask&syscall.IN_ISDIR != 0 && mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0 {
+					if err := w.addTree(fd, dirs, path); err != nil {
+						log.Printf("watch %s: %v", path, err)
+					}
+				}
+				changed = true
+			}
+		}
+		if changed {
+			w.changed()
+		}
+	}
+}
@@ -0,0 +1,84 @@
+//go:build !linux
+
+package watch
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"log"
+	"maps"
+	"path/filepath"
+	"time"
+)
+
+// pollInterval is how often the tree is scanned on platforms without
+// inotify.
+const pollInterval = 5 * time.Second
+
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+// watch scans the tree every pollInterval and compares it with the last
+// scan.
+func (w *Watcher) watch(ctx context.Context) error {
+	last, err := w.snapshot()
+	if err != nil {
+		return err
+	}
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			current, err := w.snapshot()
+			if err != nil {
+				log.Printf("watch %s: %v", w.root, err)
+				continue
+			}
+			if !maps.Equal(current, last) {
+				last = current
+				w.changed()
+			}
+		}
+	}()
+	return nil
+}
+
+// snapshot returns the size and modification time of every file in the
+// tree outside the skip folders.
+func (w *Watcher) snapshot() (map[string]fileState, error) {
+	files := map[string]fileState{}
+	err := filepath.WalkDir(w.root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if path != w.root && errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if entry.IsDir() {
+			if w.skipped(path) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		files[path] = fileState{size: info.Size(), modTime: info.ModTime()}
+		return nil
+	})
+	return files, err
+}
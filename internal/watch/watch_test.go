@@ -0,0 +1,69 @@
+package watch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestWatcherReportsChangesOutsideSkippedFolders(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("the tree is only polled every few seconds on this platform")
+	}
+	root := t.TempDir()
+	skip := filepath.Join(root, ".tmp")
+	for _, dir := range []string{skip, filepath.Join(root, "Podcast")} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+	}
+	episode := filepath.Join(root, "Podcast", "episode.mp3")
+	if err := os.WriteFile(episode, []byte("audio"), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+
+	changes := make(chan struct{}, 8)
+	w, err := Start(context.Background(), root, []string{skip}, func(context.Context) { changes <- struct{}{} })
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer w.Stop()
+
+	expect := func(want bool, what string) {
+		t.Helper()
+		select {
+		case <-changes:
+			if !want {
+				t.Fatalf("unexpected change reported after %s", what)
+			}
+		case <-time.After(settleDelay + time.Second):
+			if want {
+				t.Fatalf("no change reported after %s", what)
+			}
+		}
+	}
+
+	if err := os.WriteFile(filepath.Join(skip, "partial.mp3"), []byte("aud"), 0o644); err != nil {
+		t.Fatalf("write partial: %v", err)
+	}
+	expect(false, "writing to the skipped folder")
+
+	if err := os.Remove(episode); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	expect(true, "removing a file")
+
+	// Files in folders created after the start are followed too
+	added := filepath.Join(root, "New Podcast")
+	if err := os.MkdirAll(added, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	expect(true, "creating a folder")
+	if err := os.WriteFile(filepath.Join(added, "other.mp3"), []byte("audio"), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	expect(true, "adding a file to a new folder")
+}
@@ -0,0 +1,350 @@
+// Package websub follows feeds that advertise a WebSub (formerly
+// PubSubHubbub) hub. Hubs call back into the HTTP API listener when a feed
+// changes, and the feed is refreshed right away instead of at its next
+// scheduled refresh.
+package websub
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kballard/go-shellquote"
+
+	"podsink/internal/app"
+	"podsink/internal/domain"
+)
+
+const (
+	// CallbackPrefix is the path below which hubs reach the subscriber.
+	CallbackPrefix = "/websub/"
+
+	// leaseSeconds is the subscription lifetime requested from hubs, which
+	// may grant a different one.
+	leaseSeconds = 7 * 24 * 60 * 60
+	// renewBefore renews a subscription this long before its lease ends.
+	renewBefore = 24 * time.Hour
+	// retryAfter re-sends a request the hub has not verified or has denied.
+	retryAfter = time.Hour
+	// checkInterval is how often subscriptions are compared with the feeds.
+	checkInterval = 10 * time.Minute
+	// maxNotificationBytes bounds the body read to check a signature.
+	maxNotificationBytes = 16 << 20
+)
+
+// Controller is the part of the application the subscriber needs.
+type Controller interface {
+	Execute(ctx context.Context, input string) (app.CommandResult, error)
+	WebSubTopics(ctx context.Context) ([]domain.WebSubTopic, error)
+}
+
+type subscription struct {
+	topic         domain.WebSubTopic
+	secret        string
+	requestedAt   time.Time
+	verified      bool
+	expires       time.Time
+	unsubscribing bool
+}
+
+// Subscriber keeps hub subscriptions for all feeds with a hub in step with
+// the subscribed podcasts and refreshes a podcast when its hub notifies it.
+type Subscriber struct {
+	ctrl     Controller
+	client   *http.Client
+	callback string // Public base URL of the HTTP listener
+	now      func() time.Time
+
+	mu         sync.Mutex
+	ctx        context.Context
+	subs       map[string]*subscription // By podcast ID
+	refreshing map[string]bool
+}
+
+// New creates a subscriber whose callbacks are callbackURL followed by
+// CallbackPrefix and the podcast ID.
+func New(ctrl Controller, client *http.Client, callbackURL string) *Subscriber {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &Subscriber{
+		ctrl:       ctrl,
+		client:     client,
+		callback:   strings.TrimRight(callbackURL, "/"),
+		now:        time.Now,
+		ctx:        context.Background(),
+		subs:       make(map[string]*subscription),
+		refreshing: make(map[string]bool),
+	}
+}
+
+// Run subscribes to the hubs of all feeds that advertise one and keeps the
+// subscriptions renewed until ctx is cancelled. Hubs are asked to drop
+// subscriptions for podcasts that are no longer subscribed.
+func (s *Subscriber) Run(ctx context.Context) {
+	s.mu.Lock()
+	s.ctx = ctx
+	s.mu.Unlock()
+
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+	for {
+		s.Sync(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sync sends the subscribe and unsubscribe requests that are due.
+func (s *Subscriber) Sync(ctx context.Context) {
+	topics, err := s.ctrl.WebSubTopics(ctx)
+	if err != nil {
+		log.Printf("websub: list topics: %v", err)
+		return
+	}
+	now := s.now()
+
+	type request struct {
+		mode string
+		sub  subscription
+	}
+	var requests []request
+	s.mu.Lock()
+	wanted := make(map[string]bool, len(topics))
+	for _, topic := range topics {
+		wanted[topic.PodcastID] = true
+		sub := s.subs[topic.PodcastID]
+		current := sub != nil && !sub.unsubscribing && sub.topic.Hub == topic.Hub && sub.topic.Topic == topic.Topic
+		if current && !s.due(sub, now) {
+			continue
+		}
+		if current {
+			// A renewal keeps the secret, so notifications signed with it
+			// stay valid until the hub verifies the request.
+			sub.requestedAt = now
+		} else {
+			// New, or the feed moved to another hub or URL; an old
+			// subscription is left to expire.
+			sub = &subscription{topic: topic, secret: newSecret(), requestedAt: now}
+			s.subs[topic.PodcastID] = sub
+		}
+		requests = append(requests, request{mode: "subscribe", sub: *sub})
+	}
+	for id, sub := range s.subs {
+		if wanted[id] {
+			continue
+		}
+		if sub.unsubscribing && now.Sub(sub.requestedAt) < retryAfter {
+			continue
+		}
+		if sub.unsubscribing && now.Sub(sub.requestedAt) >= 2*retryAfter {
+			// The hub never confirmed; give up.
+			delete(s.subs, id)
+			continue
+		}
+		sub.unsubscribing = true
+		sub.requestedAt = now
+		requests = append(requests, request{mode: "unsubscribe", sub: *sub})
+	}
+	s.mu.Unlock()
+
+	for _, req := range requests {
+		if err := s.send(ctx, req.mode, req.sub); err != nil {
+			log.Printf("websub: %s %s at %s: %v", req.mode, req.sub.topic.Topic, req.sub.topic.Hub, err)
+		}
+	}
+}
+
+// due reports whether a subscription should be requested again: the lease
+// is about to end, or the hub has not verified the last request in time.
+// Requests are not repeated within retryAfter.
+func (s *Subscriber) due(sub *subscription, now time.Time) bool {
+	if now.Sub(sub.requestedAt) < retryAfter {
+		return false
+	}
+	return !sub.verified || now.After(sub.expires.Add(-renewBefore))
+}
+
+func (s *Subscriber) send(ctx context.Context, mode string, sub subscription) error {
+	form := url.Values{
+		"hub.mode":     {mode},
+		"hub.topic":    {sub.topic.Topic},
+		"hub.callback": {s.callback + CallbackPrefix + url.PathEscape(sub.topic.PodcastID)},
+	}
+	if mode == "subscribe" {
+		form.Set("hub.lease_seconds", strconv.Itoa(leaseSeconds))
+		form.Set("hub.secret", sub.secret)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.topic.Hub, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("hub answered %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// Handler serves the callbacks below CallbackPrefix: GET for the hub's
+// intent verification and denials, POST for content notifications.
+func (s *Subscriber) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+CallbackPrefix+"{id}", s.verify)
+	mux.HandleFunc("POST "+CallbackPrefix+"{id}", s.notify)
+	return mux
+}
+
+func (s *Subscriber) verify(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	query := r.URL.Query()
+	mode, topic := query.Get("hub.mode"), query.Get("hub.topic")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sub := s.subs[id]
+	if sub == nil || sub.topic.Topic != topic {
+		http.NotFound(w, r)
+		return
+	}
+	switch {
+	case mode == "subscribe" && !sub.unsubscribing:
+		lease, err := strconv.Atoi(query.Get("hub.lease_seconds"))
+		if err != nil || lease <= 0 {
+			lease = leaseSeconds
+		}
+		sub.verified = true
+		sub.expires = s.now().Add(time.Duration(lease) * time.Second)
+	case mode == "unsubscribe" && sub.unsubscribing:
+		delete(s.subs, id)
+	case mode == "denied":
+		// Retried after retryAfter; the feed is still polled meanwhile.
+		log.Printf("websub: hub %s denied subscription to %s: %s", sub.topic.Hub, topic, query.Get("hub.reason"))
+		sub.verified = false
+		sub.requestedAt = s.now()
+		w.WriteHeader(http.StatusOK)
+		return
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	io.WriteString(w, query.Get("hub.challenge"))
+}
+
+func (s *Subscriber) notify(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	s.mu.Lock()
+	sub := s.subs[id]
+	var secret string
+	if sub != nil && !sub.unsubscribing {
+		secret = sub.secret
+	}
+	s.mu.Unlock()
+	if secret == "" {
+		// Tells the hub the subscription is gone.
+		w.WriteHeader(http.StatusGone)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxNotificationBytes))
+	if err != nil {
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		return
+	}
+	// Unsigned or forged notifications are acknowledged but ignored, as
+	// the WebSub spec requires.
+	w.WriteHeader(http.StatusAccepted)
+	if !validSignature(r.Header.Get("X-Hub-Signature"), secret, body) {
+		log.Printf("websub: ignoring notification for %s with invalid signature", sub.topic.Title)
+		return
+	}
+	s.refresh(id)
+}
+
+// refresh refreshes a podcast in the background, folding notifications that
+// arrive while it is already being refreshed into that refresh.
+func (s *Subscriber) refresh(podcastID string) {
+	s.mu.Lock()
+	if s.refreshing[podcastID] {
+		s.mu.Unlock()
+		return
+	}
+	s.refreshing[podcastID] = true
+	ctx := s.ctx
+	s.mu.Unlock()
+
+	go func() {
+		defer func() {
+			s.mu.Lock()
+			delete(s.refreshing, podcastID)
+			s.mu.Unlock()
+		}()
+		result, err := s.ctrl.Execute(ctx, shellquote.Join("refresh", podcastID))
+		if err != nil {
+			log.Printf("websub: refresh %s: %v", podcastID, err)
+			return
+		}
+		log.Printf("websub: %s", strings.TrimSpace(result.Message))
+	}()
+}
+
+// validSignature checks an X-Hub-Signature header of the form
+// "sha256=<hex HMAC of the body>".
+func validSignature(header, secret string, body []byte) bool {
+	method, signature, ok := strings.Cut(strings.TrimSpace(header), "=")
+	if !ok {
+		return false
+	}
+	var newHash func() hash.Hash
+	switch strings.ToLower(method) {
+	case "sha1":
+		newHash = sha1.New
+	case "sha256":
+		newHash = sha256.New
+	case "sha384":
+		newHash = sha512.New384
+	case "sha512":
+		newHash = sha512.New
+	default:
+		return false
+	}
+	want, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(newHash, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), want)
+}
+
+func newSecret() string {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		panic(fmt.Sprintf("websub: generate secret: %v", err))
+	}
+	return hex.EncodeToString(buf)
+}
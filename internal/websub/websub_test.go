@@ -0,0 +1,209 @@
+package websub
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"podsink/internal/app"
+	"podsink/internal/domain"
+)
+
+type fakeController struct {
+	mu       sync.Mutex
+	topics   []domain.WebSubTopic
+	commands []string
+	ran      chan string
+}
+
+func (f *fakeController) Execute(_ context.Context, input string) (app.CommandResult, error) {
+	f.mu.Lock()
+	f.commands = append(f.commands, input)
+	f.mu.Unlock()
+	f.ran <- input
+	return app.CommandResult{Message: "Refreshed 1 feed"}, nil
+}
+
+func (f *fakeController) WebSubTopics(context.Context) ([]domain.WebSubTopic, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]domain.WebSubTopic(nil), f.topics...), nil
+}
+
+// fakeHub records subscription requests and verifies them against the
+// callback straight away, like a real hub does asynchronously.
+type fakeHub struct {
+	t        *testing.T
+	mu       sync.Mutex
+	requests []url.Values
+	secret   string
+}
+
+func (h *fakeHub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		h.t.Errorf("hub: parse form: %v", err)
+		return
+	}
+	h.mu.Lock()
+	h.requests = append(h.requests, r.PostForm)
+	if r.PostForm.Get("hub.mode") == "subscribe" {
+		h.secret = r.PostForm.Get("hub.secret")
+	}
+	h.mu.Unlock()
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (h *fakeHub) verify(form url.Values, lease string) (int, string) {
+	h.t.Helper()
+	query := url.Values{
+		"hub.mode":          {form.Get("hub.mode")},
+		"hub.topic":         {form.Get("hub.topic")},
+		"hub.challenge":     {"c-123"},
+		"hub.lease_seconds": {lease},
+	}
+	resp, err := http.Get(form.Get("hub.callback") + "?" + query.Encode())
+	if err != nil {
+		h.t.Fatalf("verify: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+func notify(t *testing.T, callback, secret, body string) int {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodPost, callback, strings.NewReader(body))
+	if secret != "" {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(body))
+		req.Header.Set("X-Hub-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("notify: %v", err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestSubscriberLifecycle(t *testing.T) {
+	hub := &fakeHub{t: t}
+	hubServer := httptest.NewServer(hub)
+	defer hubServer.Close()
+
+	ctrl := &fakeController{
+		topics: []domain.WebSubTopic{{PodcastID: "pod 1", Title: "Pod", Hub: hubServer.URL, Topic: "https://example.com/feed.xml"}},
+		ran:    make(chan string, 4),
+	}
+	var sub *Subscriber
+	callbackServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sub.Handler().ServeHTTP(w, r)
+	}))
+	defer callbackServer.Close()
+	sub = New(ctrl, hubServer.Client(), callbackServer.URL+"/")
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	sub.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	sub.Sync(ctx)
+	if len(hub.requests) != 1 {
+		t.Fatalf("expected one subscribe request, got %d", len(hub.requests))
+	}
+	form := hub.requests[0]
+	if form.Get("hub.mode") != "subscribe" || form.Get("hub.topic") != "https://example.com/feed.xml" ||
+		form.Get("hub.callback") != callbackServer.URL+"/websub/pod%201" || len(hub.secret) != 64 {
+		t.Fatalf("unexpected subscribe request %v", form)
+	}
+
+	if status, body := hub.verify(form, "3600"); status != http.StatusOK || body != "c-123" {
+		t.Fatalf("verification: %d %q", status, body)
+	}
+	bad := url.Values{"hub.mode": {"subscribe"}, "hub.topic": {"https://evil.example/feed"}, "hub.callback": form["hub.callback"]}
+	if status, _ := hub.verify(bad, "3600"); status != http.StatusNotFound {
+		t.Fatalf("verification of unknown topic: status %d, want 404", status)
+	}
+
+	// A notification signed with the secret triggers a refresh; forged and
+	// unsigned ones are acknowledged and ignored.
+	callback := form.Get("hub.callback")
+	if status := notify(t, callback, "wrong", "<rss/>"); status != http.StatusAccepted {
+		t.Fatalf("forged notification: status %d", status)
+	}
+	if status := notify(t, callback, "", "<rss/>"); status != http.StatusAccepted {
+		t.Fatalf("unsigned notification: status %d", status)
+	}
+	if status := notify(t, callback, hub.secret, "<rss/>"); status != http.StatusAccepted {
+		t.Fatalf("notification: status %d", status)
+	}
+	select {
+	case cmd := <-ctrl.ran:
+		if cmd != "refresh 'pod 1'" {
+			t.Fatalf("unexpected command %q", cmd)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("notification did not refresh the podcast")
+	}
+	if len(ctrl.commands) != 1 {
+		t.Fatalf("only the signed notification should refresh, got %q", ctrl.commands)
+	}
+
+	// Nothing is sent while the lease is valid; near its end it is renewed
+	// with the same secret.
+	secret := hub.secret
+	now = now.Add(30 * time.Minute)
+	sub.Sync(ctx)
+	if len(hub.requests) != 1 {
+		t.Fatalf("expected no request during the lease, got %d", len(hub.requests))
+	}
+	now = now.Add(2 * time.Hour)
+	sub.Sync(ctx)
+	if len(hub.requests) != 2 || hub.secret != secret {
+		t.Fatalf("expected a renewal with the same secret, got %d requests", len(hub.requests))
+	}
+
+	// Unsubscribed podcasts are dropped at the hub.
+	ctrl.mu.Lock()
+	ctrl.topics = nil
+	ctrl.mu.Unlock()
+	sub.Sync(ctx)
+	if len(hub.requests) != 3 || hub.requests[2].Get("hub.mode") != "unsubscribe" {
+		t.Fatalf("expected an unsubscribe request, got %v", hub.requests)
+	}
+	if status := notify(t, callback, secret, "<rss/>"); status != http.StatusGone {
+		t.Fatalf("notification after unsubscribe: status %d, want 410", status)
+	}
+	if status, body := hub.verify(hub.requests[2], ""); status != http.StatusOK || body != "c-123" {
+		t.Fatalf("unsubscribe verification: %d %q", status, body)
+	}
+	if len(sub.subs) != 0 {
+		t.Fatalf("expected no subscriptions left, got %d", len(sub.subs))
+	}
+}
+
+func TestValidSignature(t *testing.T) {
+	body := []byte("payload")
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write(body)
+	good := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	cases := map[string]bool{
+		good:                  true,
+		strings.ToUpper(good): true,
+		"sha256=zz":           false,
+		"md5=abcd":            false,
+		"":                    false,
+	}
+	for header, want := range cases {
+		if got := validSignature(header, "secret", body); got != want {
+			t.Errorf("validSignature(%q) = %v, want %v", header, got, want)
+		}
+	}
+}